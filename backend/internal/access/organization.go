@@ -29,6 +29,14 @@ func HasOrganizationAccess(c echo.Context, params HasOrganizationAccessParams) e
 		return api.ErrForbiddenNoAccess
 	}
 
+	// Organization API keys and personal access tokens belong to a single organization, and can't reach any other
+	if middleware.IsApiKeyRequest(c) || middleware.IsPersonalAccessTokenRequest(c) {
+		organizationID, err := middleware.GetOrganizationIDFromJWT(c)
+		if err != nil || organizationID != params.OrganizationID {
+			return api.ErrForbiddenNoAccess
+		}
+	}
+
 	return nil
 }
 
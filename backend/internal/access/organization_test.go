@@ -146,6 +146,116 @@ func TestHasOrganizationAccess(t *testing.T) {
 		err := HasOrganizationAccess(c, params)
 		require.NoError(t, err) // Empty scopes means no requirements, so access is granted
 	})
+
+	t.Run("ApiKeyForSameOrganization", func(t *testing.T) {
+		scopes := []constants.UserScope{
+			constants.UserScopeOrganizationRead,
+		}
+		organizationID := uuid.New()
+		organizationIDString := organizationID.String()
+		apiKeyID := uuid.New().String()
+		claims := &authentication.JwtClaims{
+			Scopes:         &scopes,
+			OrganizationId: &organizationIDString,
+			ApiKeyId:       &apiKeyID,
+		}
+		c := createTestContext(t, claims)
+
+		params := HasOrganizationAccessParams{
+			OrganizationID: organizationID,
+			Scopes:         []constants.UserScope{constants.UserScopeOrganizationRead},
+		}
+
+		err := HasOrganizationAccess(c, params)
+		require.NoError(t, err)
+	})
+
+	t.Run("ApiKeyForOtherOrganization", func(t *testing.T) {
+		scopes := []constants.UserScope{
+			constants.UserScopeOrganizationRead,
+		}
+		organizationIDString := uuid.New().String()
+		apiKeyID := uuid.New().String()
+		claims := &authentication.JwtClaims{
+			Scopes:         &scopes,
+			OrganizationId: &organizationIDString,
+			ApiKeyId:       &apiKeyID,
+		}
+		c := createTestContext(t, claims)
+
+		params := HasOrganizationAccessParams{
+			OrganizationID: uuid.New(),
+			Scopes:         []constants.UserScope{constants.UserScopeOrganizationRead},
+		}
+
+		err := HasOrganizationAccess(c, params)
+		require.ErrorIs(t, err, api.ErrForbiddenNoAccess)
+	})
+
+	t.Run("PersonalAccessTokenForSameOrganization", func(t *testing.T) {
+		scopes := []constants.UserScope{
+			constants.UserScopeOrganizationRead,
+		}
+		organizationID := uuid.New()
+		organizationIDString := organizationID.String()
+		accessTokenID := uuid.New().String()
+		claims := &authentication.JwtClaims{
+			Scopes:                &scopes,
+			OrganizationId:        &organizationIDString,
+			PersonalAccessTokenId: &accessTokenID,
+		}
+		c := createTestContext(t, claims)
+
+		params := HasOrganizationAccessParams{
+			OrganizationID: organizationID,
+			Scopes:         []constants.UserScope{constants.UserScopeOrganizationRead},
+		}
+
+		err := HasOrganizationAccess(c, params)
+		require.NoError(t, err)
+	})
+
+	t.Run("PersonalAccessTokenForOtherOrganization", func(t *testing.T) {
+		scopes := []constants.UserScope{
+			constants.UserScopeOrganizationRead,
+		}
+		organizationIDString := uuid.New().String()
+		accessTokenID := uuid.New().String()
+		claims := &authentication.JwtClaims{
+			Scopes:                &scopes,
+			OrganizationId:        &organizationIDString,
+			PersonalAccessTokenId: &accessTokenID,
+		}
+		c := createTestContext(t, claims)
+
+		params := HasOrganizationAccessParams{
+			OrganizationID: uuid.New(),
+			Scopes:         []constants.UserScope{constants.UserScopeOrganizationRead},
+		}
+
+		err := HasOrganizationAccess(c, params)
+		require.ErrorIs(t, err, api.ErrForbiddenNoAccess)
+	})
+
+	t.Run("PersonalAccessTokenWithoutOrganization", func(t *testing.T) {
+		scopes := []constants.UserScope{
+			constants.UserScopeOrganizationRead,
+		}
+		accessTokenID := uuid.New().String()
+		claims := &authentication.JwtClaims{
+			Scopes:                &scopes,
+			PersonalAccessTokenId: &accessTokenID,
+		}
+		c := createTestContext(t, claims)
+
+		params := HasOrganizationAccessParams{
+			OrganizationID: uuid.New(),
+			Scopes:         []constants.UserScope{constants.UserScopeOrganizationRead},
+		}
+
+		err := HasOrganizationAccess(c, params)
+		require.ErrorIs(t, err, api.ErrForbiddenNoAccess)
+	})
 }
 
 func TestHasAdminAccess(t *testing.T) {
@@ -1,10 +1,65 @@
 package api
 
+import "github.com/google/uuid"
+
 // Custom type of error
 type ApiError struct {
-	Message string `json:"message"`
+	Message string        `json:"message"`
+	Code    string        `json:"code,omitempty"`
+	Errors  []FieldError  `json:"errors,omitempty"` // Set when the request broke one or more validation rules
+	Meta    *ApiErrorMeta `json:"meta,omitempty"`
+}
+
+// ApiErrorMeta holds what the frontend needs to recover from an error
+type ApiErrorMeta struct {
+	OrganizationID string `json:"organizationId,omitempty"` // Organization to sign in with single sign-on to
 }
 
 func (e ApiError) Error() string {
 	return e.Message
 }
+
+// ScimError is the error response format of the SCIM endpoints, which SCIM clients expect instead of ApiError
+type ScimError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+// FieldError describes a single validation rule a field broke
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned by the service layer for validation that can't be expressed with struct tags.
+// It wraps a sentinel error, so it can still be checked with errors.Is().
+type ValidationError struct {
+	Err    error
+	Code   string
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// SsoRequiredError is returned when the user has to sign in through an organization's identity provider. It wraps
+// ErrSsoRequired, so it can still be checked with errors.Is().
+type SsoRequiredError struct {
+	OrganizationID uuid.UUID
+}
+
+func (e *SsoRequiredError) Error() string {
+	return ErrSsoRequired.Error()
+}
+
+func (e *SsoRequiredError) Unwrap() error {
+	return ErrSsoRequired
+}
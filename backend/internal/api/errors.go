@@ -19,6 +19,59 @@ var (
 	ErrMissingAuthorizationHeader       = errors.New("missing authorization header")
 	ErrInvalidAuthorizationFormat       = errors.New("invalid authorization format")
 	ErrInvalidToken                     = errors.New("invalid token")
+	ErrTokenExpired                     = errors.New("token has expired")
+	ErrTokenMalformed                   = errors.New("token is malformed")
+	ErrTokenInvalidIssuer               = errors.New("token was not issued by a trusted issuer")
+	ErrTokenInvalidAudience             = errors.New("token was not issued for this audience")
+	ErrTokenRefreshRequired             = errors.New("token has been revoked and must be refreshed")
+	ErrReauthenticationRequired         = errors.New("token has been revoked, please sign in again")
+	ErrInvalidRefreshToken              = errors.New("invalid refresh token")
+	ErrMfaInvalidCode                   = errors.New("invalid multi-factor authentication code")
+	ErrMfaChallengeLocked               = errors.New("too many invalid multi-factor authentication codes, please sign in again")
+	ErrMfaAlreadyEnabled                = errors.New("multi-factor authentication is already enabled")
+	ErrMfaNotEnabled                    = errors.New("multi-factor authentication is not enabled")
+	ErrMfaEnrollmentNotStarted          = errors.New("multi-factor authentication enrollment has not been started")
+	ErrPasskeyChallengeInvalid          = errors.New("passkey challenge is invalid or has expired")
+	ErrPasskeyVerificationFailed        = errors.New("passkey verification failed")
+	ErrInvalidPasswordResetToken        = errors.New("password reset link is invalid or has expired")
+	ErrInvalidEmailVerificationToken    = errors.New("email verification link is invalid or has expired")
+	ErrEmailNotVerified                 = errors.New("email address is not verified")
+	ErrEmailAlreadyVerified             = errors.New("email address is already verified")
+	ErrOAuthStateInvalid                = errors.New("oauth state is invalid or has expired")
+	ErrOAuthRedirectUriNotAllowed       = errors.New("oauth redirect uri is not allowed")
+	ErrMagicLinkLoginDisabled           = errors.New("signing in with an email link is not enabled")
+	ErrInvalidMagicLinkToken            = errors.New("sign in link is invalid or has expired")
+	ErrLastLoginMethod                  = errors.New("you can't remove your only way to sign in")
+	ErrPasswordAlreadySet               = errors.New("a password is already set for this account")
+	ErrTooManyLoginAttempts             = errors.New("too many failed sign in attempts, please try again later")
+	ErrTooManyEmailRequests             = errors.New("too many emails requested, please try again later")
+	ErrPersonalAccessTokenNotAllowed    = errors.New("personal access tokens can't be used for this request")
+	ErrPersonalAccessTokenScopeDenied   = errors.New("personal access tokens can only be granted scopes you have")
+	ErrApiKeyNotAllowed                 = errors.New("organization api keys can't be used for this request")
+	ErrApiKeyScopeDenied                = errors.New("organization api keys can't be granted this scope")
+	ErrPasswordPolicyViolation          = errors.New("password does not meet the password requirements")
+	ErrSsoRequired                      = errors.New("your organization requires signing in with single sign-on")
+	ErrSamlLoginInvalid                 = errors.New("single sign-on login is invalid or has expired")
+	ErrSamlResponseInvalid              = errors.New("single sign-on response is invalid")
+	ErrSamlMetadataInvalid              = errors.New("saml identity provider metadata is invalid")
+	ErrSamlCertificateInvalid           = errors.New("saml identity provider certificate is invalid")
+	ErrSamlEmailInUse                   = errors.New("an account with this email already exists and its domain isn't verified by this organization")
+	ErrSamlEmailDomainNotVerified       = errors.New("your email's domain isn't verified by this organization")
+	ErrDomainInvalid                    = errors.New("domain is invalid")
+	ErrDomainAlreadyClaimed             = errors.New("this domain has already been claimed by this organization")
+	ErrDomainAlreadyVerified            = errors.New("this domain has already been verified by another organization")
+	ErrDomainVerificationFailed         = errors.New("the domain's verification TXT record wasn't found")
+	ErrOrganizationNotJoinable          = errors.New("you can't join this organization with your email address")
+	ErrJoinApprovalRequired             = errors.New("joining this organization requires an admin's approval")
+	ErrJoinApprovalNotRequired          = errors.New("this organization can be joined without an admin's approval")
+	ErrJoinRequestAlreadyExists         = errors.New("you have already asked to join this organization")
+	ErrJoinRequestNotPending            = errors.New("join request is no longer pending")
+	ErrImpersonationReasonRequired      = errors.New("a reason is required to impersonate a user")
+	ErrImpersonationReadOnly            = errors.New("this impersonation session is read-only")
+	ErrImpersonationEnded               = errors.New("the impersonation session has ended")
+	ErrSoleOrganizationAdmin            = errors.New("you're the only admin of an organization, make someone else an admin or delete the organization first")
+	ErrCurrentPasswordRequired          = errors.New("enter your current password, or sign in again, to change how you sign in")
+	ErrCurrentPasswordInvalid           = errors.New("current password is incorrect")
 
 	// Resource not found errors
 	ErrMembershipNotFound      = errors.New("membership not found")
@@ -29,12 +82,45 @@ var (
 	ErrUserAlreadyMember       = errors.New("user is already a member of this organization")
 	ErrUserNotFound            = errors.New("user not found")
 	ErrUserEmailAlreadyExists  = errors.New("a user with this email already exists")
+	ErrPasskeyNotFound         = errors.New("passkey not found")
+	ErrPasskeyAlreadyExists    = errors.New("this passkey is already registered")
+	ErrOAuthProviderNotFound   = errors.New("oauth provider not found")
+	ErrUserIdentityNotFound    = errors.New("linked account not found")
+	ErrUserIdentityLinked      = errors.New("this account is already linked to another user")
+	ErrLoginLockoutNotFound    = errors.New("login lockout not found")
+	ErrUserSessionNotFound     = errors.New("session not found")
+	ErrAccessTokenNotFound     = errors.New("personal access token not found")
+	ErrApiKeyNotFound          = errors.New("api key not found")
+	ErrSamlConnectionNotFound  = errors.New("saml connection not found")
+	ErrDomainNotFound          = errors.New("domain not found")
+	ErrJoinRequestNotFound     = errors.New("join request not found")
+	ErrUserDataExportNotFound  = errors.New("data export not found")
 
 	// Invalid ID errors
 	ErrInvalidOrganizationID = errors.New("invalid organization id")
 	ErrInvalidUserID         = errors.New("invalid user id")
 	ErrInvalidMembershipID   = errors.New("invalid membership id")
 	ErrInvalidInvitationID   = errors.New("invalid invitation id")
+	ErrInvalidPasskeyID      = errors.New("invalid passkey id")
+	ErrInvalidUserIdentityID = errors.New("invalid linked account id")
+	ErrInvalidLoginLockoutID = errors.New("invalid login lockout id")
+	ErrInvalidUserSessionID  = errors.New("invalid session id")
+	ErrInvalidAccessTokenID  = errors.New("invalid personal access token id")
+	ErrInvalidApiKeyID       = errors.New("invalid api key id")
+	ErrInvalidDomainID       = errors.New("invalid domain id")
+	ErrInvalidJoinRequestID  = errors.New("invalid join request id")
+
+	// SCIM errors
+	ErrScimApiKeyRequired    = errors.New("scim requests have to be authenticated with an organization api key")
+	ErrScimResourceNotFound  = errors.New("resource not found")
+	ErrScimUserExists        = errors.New("a user with this userName is already provisioned")
+	ErrScimDomainNotVerified = errors.New("userName must be on one of the organization's verified domains")
+	ErrScimInvalidFilter     = errors.New("filter is not supported, only eq comparisons of filterable attributes are")
+	ErrScimInvalidSyntax     = errors.New("request is not valid scim")
+	ErrScimInvalidUserName   = errors.New("userName has to be an email address")
+	ErrScimUserNameImmutable = errors.New("userName can't be changed")
+	ErrScimInvalidPatch      = errors.New("patch operation is not supported")
+	ErrScimInvalidMember     = errors.New("group members have to be users provisioned in the organization")
 
 	// Stripe webhook errors
 	ErrStripeWebhookSecretNotConfigured = errors.New("stripe webhook secret not configured")
@@ -44,6 +130,24 @@ var (
 	ErrStripeWebhookEventUnhandled      = errors.New("stripe webhook event unhandled")
 )
 
+// Machine readable error codes for errors that the client is expected to act on
+const (
+	ErrorCodeTokenRefreshRequired     = "token_refresh_required"
+	ErrorCodeReauthenticationRequired = "reauthentication_required"
+	ErrorCodeTokenExpired             = "token_expired"
+	ErrorCodeTokenMalformed           = "token_malformed"
+	ErrorCodeTokenInvalidIssuer       = "token_invalid_issuer"
+	ErrorCodeTokenInvalidAudience     = "token_invalid_audience"
+	ErrorCodeEmailNotVerified         = "email_not_verified"
+	ErrorCodeTooManyLoginAttempts     = "too_many_login_attempts"
+	ErrorCodePasswordPolicyViolation  = "password_policy_violation"
+	ErrorCodeSsoRequired              = "sso_required"
+	ErrorCodeJoinApprovalRequired     = "join_approval_required"
+	ErrorCodeImpersonationReadOnly    = "impersonation_read_only"
+	ErrorCodeImpersonationEnded       = "impersonation_ended"
+	ErrorCodeCurrentPasswordRequired  = "current_password_required"
+)
+
 // IsUniqueConstraintViolation checks if an error is a PostgreSQL unique constraint violation
 // It checks for PostgreSQL error code 23505 (unique_violation)
 func IsUniqueConstraintViolation(err error) bool {
@@ -49,3 +49,75 @@ func ParseOrganizationInvitationIDFromParams(c echo.Context) (uuid.UUID, error)
 	}
 	return paramInvitationID, nil
 }
+
+// ParsePasskeyIDFromParams parses passkey ID from URL parameter
+func ParsePasskeyIDFromParams(c echo.Context) (uuid.UUID, error) {
+	paramPasskeyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return uuid.Nil, ErrInvalidPasskeyID
+	}
+	return paramPasskeyID, nil
+}
+
+// ParseUserIdentityIDFromParams parses user identity ID from URL parameter
+func ParseUserIdentityIDFromParams(c echo.Context) (uuid.UUID, error) {
+	paramIdentityID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return uuid.Nil, ErrInvalidUserIdentityID
+	}
+	return paramIdentityID, nil
+}
+
+// ParseLoginLockoutIDFromParams parses login lockout ID from URL parameter
+func ParseLoginLockoutIDFromParams(c echo.Context) (uuid.UUID, error) {
+	paramLockoutID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return uuid.Nil, ErrInvalidLoginLockoutID
+	}
+	return paramLockoutID, nil
+}
+
+// ParseUserSessionIDFromParams parses session ID from URL parameter
+func ParseUserSessionIDFromParams(c echo.Context) (uuid.UUID, error) {
+	paramSessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return uuid.Nil, ErrInvalidUserSessionID
+	}
+	return paramSessionID, nil
+}
+
+// ParseAccessTokenIDFromParams parses personal access token ID from URL parameter
+func ParseAccessTokenIDFromParams(c echo.Context) (uuid.UUID, error) {
+	paramAccessTokenID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return uuid.Nil, ErrInvalidAccessTokenID
+	}
+	return paramAccessTokenID, nil
+}
+
+// ParseApiKeyIDFromParams parses API key ID from URL parameter
+func ParseApiKeyIDFromParams(c echo.Context) (uuid.UUID, error) {
+	paramApiKeyID, err := uuid.Parse(c.Param("apiKeyId"))
+	if err != nil {
+		return uuid.Nil, ErrInvalidApiKeyID
+	}
+	return paramApiKeyID, nil
+}
+
+// ParseDomainIDFromParams parses organization domain ID from URL parameter
+func ParseDomainIDFromParams(c echo.Context) (uuid.UUID, error) {
+	paramDomainID, err := uuid.Parse(c.Param("domainId"))
+	if err != nil {
+		return uuid.Nil, ErrInvalidDomainID
+	}
+	return paramDomainID, nil
+}
+
+// ParseJoinRequestIDFromParams parses organization join request ID from URL parameter
+func ParseJoinRequestIDFromParams(c echo.Context) (uuid.UUID, error) {
+	paramJoinRequestID, err := uuid.Parse(c.Param("joinRequestId"))
+	if err != nil {
+		return uuid.Nil, ErrInvalidJoinRequestID
+	}
+	return paramJoinRequestID, nil
+}
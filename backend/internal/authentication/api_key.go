@@ -0,0 +1,105 @@
+package authentication
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"reece.start/internal/api"
+	"reece.start/internal/constants"
+	"reece.start/internal/models"
+)
+
+// ApiKeyPrefix starts every organization API key, so they can be told apart from JWTs and personal access tokens
+// and recognized by secret scanners if they are leaked
+const ApiKeyPrefix = "rs_key_"
+
+// GenerateApiKey generates a new organization API key.
+// Returns the key to hand to the admin, the hash that should be stored in the database, and the prefix to display.
+func GenerateApiKey() (string, string, string, error) {
+	return generatePrefixedToken(ApiKeyPrefix)
+}
+
+// Scopes organization API keys can be granted. Keys are long lived and shared with other systems, so they are limited
+// to reading the organization and routine changes. Nothing that deletes data, hands out roles, or changes billing, API
+// keys, how members sign in or which domains can join. New scopes have to be added here deliberately.
+var apiKeyGrantableScopes = []constants.UserScope{
+	constants.UserScopeOrganizationRead,
+	constants.UserScopeOrganizationUpdate,
+	constants.UserScopeOrganizationMembershipsList,
+	constants.UserScopeOrganizationMembershipsRead,
+	constants.UserScopeOrganizationInvitationsList,
+	constants.UserScopeOrganizationInvitationsRead,
+	constants.UserScopeOrganizationJoinRequestsList,
+	constants.UserScopeOrganizationJoinRequestsUpdate,
+
+	// Provisioning is what identity providers use keys for. The organization's admins decide which users and groups
+	// the identity provider manages.
+	constants.UserScopeOrganizationScimProvision,
+}
+
+// IsApiKey checks if a bearer token is an organization API key rather than a JWT
+func IsApiKey(token string) bool {
+	return strings.HasPrefix(token, ApiKeyPrefix)
+}
+
+// GetApiKeyGrantableScopes returns the scopes an organization API key can be given
+func GetApiKeyGrantableScopes() []constants.UserScope {
+	return slices.Clone(apiKeyGrantableScopes)
+}
+
+// AuthenticateApiKey looks up an organization API key, and returns claims for the organization it belongs to.
+// The claims have no user, so endpoints that act on a user reject them. The previous secret of a rotated key is
+// accepted until its overlap window ends. Also reports whether this use was recorded as the key's last use, which
+// happens at most once per interval.
+func AuthenticateApiKey(db *gorm.DB, token string, ipAddress string) (*JwtClaims, bool, error) {
+	now := time.Now()
+	hash := HashOpaqueToken(token)
+
+	var apiKey models.OrganizationApiKey
+	err := db.Where("token_hash = ? OR (previous_token_hash = ? AND previous_token_expires_at > ?)", hash, hash, now).First(&apiKey).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, api.ErrInvalidToken
+		}
+		return nil, false, err
+	}
+
+	// Make sure the organization hasn't been deleted
+	var organization models.Organization
+	err = db.First(&organization, apiKey.OrganizationID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, api.ErrInvalidToken
+		}
+		return nil, false, err
+	}
+
+	scopes := filterScopes(apiKey.Scopes, apiKeyGrantableScopes)
+
+	recorded, err := recordPrefixedTokenUse(db, &models.OrganizationApiKey{}, apiKey.ID, now, ipAddress)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Keys never have the admin role, so admin endpoints are out of reach
+	role := constants.UserRoleDefault
+	isImpersonating := false
+	apiKeyId := apiKey.ID.String()
+	organizationId := apiKey.OrganizationID.String()
+
+	return &JwtClaims{
+		OrganizationId:  &organizationId,
+		Scopes:          &scopes,
+		Role:            &role,
+		IsImpersonating: &isImpersonating,
+		ApiKeyId:        &apiKeyId,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:  "api-key:" + apiKeyId,
+			IssuedAt: jwt.NewNumericDate(apiKey.CreatedAt),
+		},
+	}, recorded, nil
+}
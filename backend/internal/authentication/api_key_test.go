@@ -0,0 +1,85 @@
+package authentication
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"reece.start/internal/constants"
+)
+
+func TestGenerateApiKey(t *testing.T) {
+	t.Run("ReturnsPrefixedKeyAndMatchingHash", func(t *testing.T) {
+		key, hash, prefix, err := GenerateApiKey()
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(key, ApiKeyPrefix))
+		require.True(t, strings.HasPrefix(key, prefix))
+		require.Greater(t, len(prefix), len(ApiKeyPrefix))
+		require.Equal(t, HashOpaqueToken(key), hash)
+	})
+
+	t.Run("GeneratesUniqueKeys", func(t *testing.T) {
+		first, _, _, err := GenerateApiKey()
+		require.NoError(t, err)
+		second, _, _, err := GenerateApiKey()
+		require.NoError(t, err)
+		require.NotEqual(t, first, second)
+	})
+}
+
+func TestIsApiKey(t *testing.T) {
+	key, _, _, err := GenerateApiKey()
+	require.NoError(t, err)
+	require.True(t, IsApiKey(key))
+	require.False(t, IsPersonalAccessToken(key))
+
+	token, _, _, err := GeneratePersonalAccessToken()
+	require.NoError(t, err)
+	require.False(t, IsApiKey(token))
+	require.False(t, IsApiKey("eyJhbGciOiJIUzI1NiJ9.e30.signature"))
+}
+
+func TestGetApiKeyGrantableScopes(t *testing.T) {
+	scopes := GetApiKeyGrantableScopes()
+	require.Contains(t, scopes, constants.UserScopeOrganizationRead)
+	require.Contains(t, scopes, constants.UserScopeOrganizationMembershipsList)
+	require.Contains(t, scopes, constants.UserScopeOrganizationJoinRequestsUpdate)
+	require.Contains(t, scopes, constants.UserScopeOrganizationScimProvision)
+
+	// Keys can't delete anything
+	require.NotContains(t, scopes, constants.UserScopeOrganizationDelete)
+	require.NotContains(t, scopes, constants.UserScopeOrganizationMembershipsDelete)
+	require.NotContains(t, scopes, constants.UserScopeOrganizationInvitationsDelete)
+
+	// Or hand out roles
+	require.NotContains(t, scopes, constants.UserScopeOrganizationMembershipsCreate)
+	require.NotContains(t, scopes, constants.UserScopeOrganizationMembershipsUpdate)
+	require.NotContains(t, scopes, constants.UserScopeOrganizationInvitationsCreate)
+
+	// Or change billing
+	require.NotContains(t, scopes, constants.UserScopeOrganizationStripeUpdate)
+	require.NotContains(t, scopes, constants.UserScopeOrganizationBillingUpdate)
+
+	// Or manage keys
+	require.NotContains(t, scopes, constants.UserScopeOrganizationApiKeysList)
+	require.NotContains(t, scopes, constants.UserScopeOrganizationApiKeysCreate)
+	require.NotContains(t, scopes, constants.UserScopeOrganizationApiKeysUpdate)
+	require.NotContains(t, scopes, constants.UserScopeOrganizationApiKeysDelete)
+
+	// Or how members sign in
+	require.NotContains(t, scopes, constants.UserScopeOrganizationSamlRead)
+	require.NotContains(t, scopes, constants.UserScopeOrganizationSamlUpdate)
+	require.NotContains(t, scopes, constants.UserScopeOrganizationSamlDelete)
+
+	// Or which domains can join the organization
+	require.NotContains(t, scopes, constants.UserScopeOrganizationDomainsList)
+	require.NotContains(t, scopes, constants.UserScopeOrganizationDomainsCreate)
+	require.NotContains(t, scopes, constants.UserScopeOrganizationDomainsUpdate)
+	require.NotContains(t, scopes, constants.UserScopeOrganizationDomainsDelete)
+
+	// Nor reach beyond organization admin scopes
+	require.NotContains(t, scopes, constants.UserScopeAdmin)
+	for _, scope := range scopes {
+		require.Contains(t, constants.OrganizationRoleToScopes[constants.OrganizationRoleAdmin], scope)
+	}
+}
@@ -0,0 +1,120 @@
+package authentication
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"reece.start/internal/configuration"
+)
+
+// Hashes are grouped by the first characters of the hash like the Have I Been Pwned range API, so a lookup only
+// searches the hashes that share the password's prefix
+const breachedPasswordPrefixLength = 5
+
+// BreachedPasswords is a list of SHA-1 hashes of passwords that have appeared in data breaches
+type BreachedPasswords struct {
+	// Hash prefix -> sorted hash suffixes
+	ranges map[string][]string
+	count  int
+}
+
+// Breached password lists are loaded once per config, since they are read from disk
+var breachedPasswordLists sync.Map
+
+// GetBreachedPasswords returns the breached password list for the given config, loading it on first use.
+// Returns nil if no list is configured.
+func GetBreachedPasswords(config *configuration.Config) (*BreachedPasswords, error) {
+	if config.BreachedPasswordsFile == "" {
+		return nil, nil
+	}
+
+	if list, ok := breachedPasswordLists.Load(config); ok {
+		return list.(*BreachedPasswords), nil
+	}
+
+	list, err := LoadBreachedPasswords(config.BreachedPasswordsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	actual, _ := breachedPasswordLists.LoadOrStore(config, list)
+	return actual.(*BreachedPasswords), nil
+}
+
+// LoadBreachedPasswords reads a breached password list. Each line is a hex encoded SHA-1 hash, optionally followed by
+// :count. Blank lines are skipped.
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := &BreachedPasswords{ranges: make(map[string][]string)}
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		hash, _, _ := strings.Cut(line, ":")
+		hash = strings.ToUpper(hash)
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("invalid SHA-1 hash on line %d of %s", lineNumber, path)
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			return nil, fmt.Errorf("invalid SHA-1 hash on line %d of %s", lineNumber, path)
+		}
+
+		prefix := hash[:breachedPasswordPrefixLength]
+		list.ranges[prefix] = append(list.ranges[prefix], hash[breachedPasswordPrefixLength:])
+		list.count++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for prefix := range list.ranges {
+		slices.Sort(list.ranges[prefix])
+	}
+
+	return list, nil
+}
+
+// Count returns the number of hashes in the list
+func (b *BreachedPasswords) Count() int {
+	return b.count
+}
+
+// Contains checks if a password is in the list
+func (b *BreachedPasswords) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes := b.ranges[hash[:breachedPasswordPrefixLength]]
+	_, found := slices.BinarySearch(suffixes, hash[breachedPasswordPrefixLength:])
+	return found
+}
+
+// IsBreachedPassword checks a password against the configured breached password list.
+// Always returns false if no list is configured.
+func IsBreachedPassword(config *configuration.Config, password string) (bool, error) {
+	list, err := GetBreachedPasswords(config)
+	if err != nil || list == nil {
+		return false, err
+	}
+
+	return list.Contains(password), nil
+}
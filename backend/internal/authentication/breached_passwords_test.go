@@ -0,0 +1,87 @@
+package authentication
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	testconfig "reece.start/test/config"
+)
+
+// writeBreachedPasswordsFile writes a breached password list with the given passwords and returns its path
+func writeBreachedPasswordsFile(t *testing.T, passwords ...string) string {
+	lines := make([]string, 0, len(passwords))
+	for i, password := range passwords {
+		sum := sha1.Sum([]byte(password))
+		hash := hex.EncodeToString(sum[:])
+		if i%2 == 0 {
+			// Mix both formats and cases, like the different downloads
+			lines = append(lines, strings.ToUpper(hash)+":42")
+		} else {
+			lines = append(lines, hash)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n\n"), 0o600)
+	require.NoError(t, err)
+	return path
+}
+
+func TestLoadBreachedPasswords(t *testing.T) {
+	t.Run("ContainsListedPasswords", func(t *testing.T) {
+		path := writeBreachedPasswordsFile(t, "hunter2-breached", "another breached one", "third")
+
+		list, err := LoadBreachedPasswords(path)
+		require.NoError(t, err)
+		require.Equal(t, 3, list.Count())
+		require.True(t, list.Contains("hunter2-breached"))
+		require.True(t, list.Contains("another breached one"))
+		require.True(t, list.Contains("third"))
+		require.False(t, list.Contains("correct horse battery staple"))
+	})
+
+	t.Run("MissingFile", func(t *testing.T) {
+		_, err := LoadBreachedPasswords(filepath.Join(t.TempDir(), "missing.txt"))
+		require.Error(t, err)
+	})
+
+	t.Run("InvalidHash", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "breached.txt")
+		err := os.WriteFile(path, []byte("not-a-hash:12\n"), 0o600)
+		require.NoError(t, err)
+
+		_, err = LoadBreachedPasswords(path)
+		require.Error(t, err)
+	})
+}
+
+func TestIsBreachedPassword(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		config := testconfig.CreateTestConfig()
+
+		breached, err := IsBreachedPassword(config, "hunter2-breached")
+		require.NoError(t, err)
+		require.False(t, breached)
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		config := testconfig.CreateTestConfig()
+		config.BreachedPasswordsFile = writeBreachedPasswordsFile(t, "hunter2-breached")
+
+		breached, err := IsBreachedPassword(config, "hunter2-breached")
+		require.NoError(t, err)
+		require.True(t, breached)
+
+		breached, err = IsBreachedPassword(config, "correct horse battery staple")
+		require.NoError(t, err)
+		require.False(t, breached)
+
+		err = CheckPasswordPolicy(config, PasswordPolicyParams{Password: "hunter2-breached"})
+		require.Equal(t, []string{PasswordRuleBreached}, getPasswordPolicyRules(t, err))
+	})
+}
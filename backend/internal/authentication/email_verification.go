@@ -0,0 +1,51 @@
+package authentication
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"reece.start/internal/api"
+	"reece.start/internal/configuration"
+)
+
+const emailVerificationAudienceSuffix = "/email-verification"
+
+// EmailVerificationClaims are the claims of the token sent in an email verification link.
+// The token is bound to the address it was sent to, so it stops working if the user changes their email in the meantime.
+type EmailVerificationClaims struct {
+	jwt.RegisteredClaims
+	UserId string `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+// CreateEmailVerificationJWT creates a token proving the user received an email at the given address
+func CreateEmailVerificationJWT(config *configuration.Config, userId uuid.UUID, email string) (string, error) {
+	keyRing, err := GetKeyRing(config)
+	if err != nil {
+		return "", err
+	}
+
+	expiry := time.Duration(config.EmailVerificationTokenExpirationTime) * time.Second
+	return keyRing.Sign(EmailVerificationClaims{
+		UserId:           userId.String(),
+		Email:            email,
+		RegisteredClaims: getPurposeRegisteredClaims(config, emailVerificationAudienceSuffix, userId, expiry),
+	})
+}
+
+// ValidateEmailVerificationJWT validates an email verification token and returns the user and address it was issued for
+func ValidateEmailVerificationJWT(config *configuration.Config, tokenString string) (uuid.UUID, string, error) {
+	claims := &EmailVerificationClaims{}
+	err := parsePurposeJWT(config, tokenString, emailVerificationAudienceSuffix, claims)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+
+	userId, err := uuid.Parse(claims.UserId)
+	if err != nil || claims.Email == "" {
+		return uuid.Nil, "", api.ErrInvalidToken
+	}
+
+	return userId, claims.Email, nil
+}
@@ -0,0 +1,55 @@
+package authentication
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	testconfig "reece.start/test/config"
+)
+
+func TestEmailVerificationJWT(t *testing.T) {
+	t.Run("RoundTrip", func(t *testing.T) {
+		config := testconfig.CreateTestConfig()
+		userId := uuid.New()
+
+		token, err := CreateEmailVerificationJWT(config, userId, "verify@example.com")
+		require.NoError(t, err)
+
+		parsedUserId, email, err := ValidateEmailVerificationJWT(config, token)
+		require.NoError(t, err)
+		require.Equal(t, userId, parsedUserId)
+		require.Equal(t, "verify@example.com", email)
+	})
+
+	t.Run("CannotBeUsedAsSessionToken", func(t *testing.T) {
+		config := testconfig.CreateTestConfig()
+
+		token, err := CreateEmailVerificationJWT(config, uuid.New(), "verify@example.com")
+		require.NoError(t, err)
+
+		_, err = ValidateJWT(config, token)
+		require.Error(t, err)
+	})
+
+	t.Run("MfaChallengeIsNotAVerificationToken", func(t *testing.T) {
+		config := testconfig.CreateTestConfig()
+
+		token, err := CreateMfaChallengeJWT(config, uuid.New())
+		require.NoError(t, err)
+
+		_, _, err = ValidateEmailVerificationJWT(config, token)
+		require.Error(t, err)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		config := testconfig.CreateTestConfig()
+		config.EmailVerificationTokenExpirationTime = -120
+
+		token, err := CreateEmailVerificationJWT(config, uuid.New(), "verify@example.com")
+		require.NoError(t, err)
+
+		_, _, err = ValidateEmailVerificationJWT(config, token)
+		require.Error(t, err)
+	})
+}
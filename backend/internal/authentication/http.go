@@ -0,0 +1,22 @@
+package authentication
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"reece.start/internal/configuration"
+)
+
+// JwksEndpoint publishes the public keys of the key ring so other services can verify our tokens without being able to sign them
+func JwksEndpoint(config *configuration.Config) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		keyRing, err := GetKeyRing(config)
+		if err != nil {
+			return err
+		}
+
+		// Verifiers are expected to cache the key set, new keys should be published well before they start signing tokens
+		c.Response().Header().Set("Cache-Control", "public, max-age=300")
+		return c.JSON(http.StatusOK, keyRing.Jwks())
+	}
+}
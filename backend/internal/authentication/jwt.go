@@ -1,6 +1,7 @@
 package authentication
 
 import (
+	"errors"
 	"log/slog"
 	"time"
 
@@ -21,6 +22,23 @@ type JwtClaims struct {
 	Role                *constants.UserRole         `json:"role"`
 	IsImpersonating     *bool                       `json:"is_impersonating"`
 	ImpersonatingUserId *string                     `json:"impersonating_user_id"` // The actual user id of the authenticated user
+
+	// Logged impersonation the token was issued for, and whether it can only read
+	ImpersonationSessionId *string `json:"impersonation_session_id,omitempty"`
+	IsReadOnly             *bool   `json:"is_read_only,omitempty"`
+
+	// Set when the request was authenticated with a personal access token instead of a JWT. Never part of a signed token.
+	PersonalAccessTokenId *string `json:"-"`
+	// Set when the request was authenticated with an organization API key. There is no user behind these claims.
+	ApiKeyId *string `json:"-"`
+}
+
+// Principal identifies who is acting with these claims, for logs and analytics
+func (c *JwtClaims) Principal() string {
+	if c.ApiKeyId != nil {
+		return "api-key:" + *c.ApiKeyId
+	}
+	return "user:" + c.UserId
 }
 
 type JwtOptions struct {
@@ -31,7 +49,11 @@ type JwtOptions struct {
 	Role                *constants.UserRole
 	IsImpersonating     *bool
 	ImpersonatingUserId *uuid.UUID
+	SessionId           *uuid.UUID
 	CustomExpiry        *time.Time
+
+	ImpersonationSessionId *uuid.UUID
+	IsReadOnly             *bool
 }
 
 func CreateJWT(config *configuration.Config, options JwtOptions) (string, error) {
@@ -41,8 +63,15 @@ func CreateJWT(config *configuration.Config, options JwtOptions) (string, error)
 	activeOrganizationId := getActiveOrganizationIdFromOptions(options)
 	expiresAt := getExpiryFromOptions(config, options)
 	impersonatingUserId := getImpersonatingUserIdFromOptions(options)
+	sessionId := getSessionIdFromOptions(options)
+	impersonationSessionId := getImpersonationSessionIdFromOptions(options)
+
+	keyRing, err := GetKeyRing(config)
+	if err != nil {
+		return "", err
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, JwtClaims{
+	return keyRing.Sign(JwtClaims{
 		UserId:              userIdString,
 		OrganizationId:      activeOrganizationId,
 		OrganizationRole:    options.OrganizationRole,
@@ -50,6 +79,10 @@ func CreateJWT(config *configuration.Config, options JwtOptions) (string, error)
 		Role:                options.Role,
 		IsImpersonating:     options.IsImpersonating,
 		ImpersonatingUserId: impersonatingUserId,
+
+		ImpersonationSessionId: impersonationSessionId,
+		IsReadOnly:             options.IsReadOnly,
+
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: expiresAt,
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -57,22 +90,34 @@ func CreateJWT(config *configuration.Config, options JwtOptions) (string, error)
 			Issuer:    config.JwtIssuer,
 			Subject:   userIdString,
 			Audience:  jwt.ClaimStrings{config.JwtAudience},
+			ID:        sessionId,
 		},
 	})
-
-	return token.SignedString([]byte(config.JwtSecret))
 }
 
 func ValidateJWT(config *configuration.Config, tokenString string) (*JwtClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JwtClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(config.JwtSecret), nil
-	})
-
+	keyRing, err := GetKeyRing(config)
 	if err != nil {
-		slog.Error("Error parsing JWT token", "error", err, "token", tokenString)
 		return nil, err
 	}
 
+	token, err := jwt.ParseWithClaims(
+		tokenString,
+		&JwtClaims{},
+		keyRing.Keyfunc,
+		jwt.WithValidMethods(keyRing.ValidMethods()),
+		jwt.WithIssuer(config.JwtIssuer),
+		jwt.WithAudience(config.JwtAudience),
+		jwt.WithLeeway(time.Duration(config.JwtLeeway)*time.Second),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+
+	if err != nil {
+		slog.Info("Rejected JWT token", "error", err)
+		return nil, mapJwtError(err)
+	}
+
 	if claims, ok := token.Claims.(*JwtClaims); ok && token.Valid {
 		return claims, nil
 	}
@@ -81,6 +126,22 @@ func ValidateJWT(config *configuration.Config, tokenString string) (*JwtClaims,
 	return nil, api.ErrForbiddenNoAccess
 }
 
+// mapJwtError maps errors from the jwt library to API errors, so clients can tell an expired token from a forged one
+func mapJwtError(err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return api.ErrTokenMalformed
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return api.ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return api.ErrTokenInvalidIssuer
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return api.ErrTokenInvalidAudience
+	default:
+		return api.ErrInvalidToken
+	}
+}
+
 func getActiveOrganizationIdFromOptions(options JwtOptions) *string {
 	if options.OrganizationId != nil {
 		orgIdString := options.OrganizationId.String()
@@ -105,3 +166,18 @@ func getImpersonatingUserIdFromOptions(options JwtOptions) *string {
 	}
 	return nil
 }
+
+func getSessionIdFromOptions(options JwtOptions) string {
+	if options.SessionId != nil {
+		return options.SessionId.String()
+	}
+	return ""
+}
+
+func getImpersonationSessionIdFromOptions(options JwtOptions) *string {
+	if options.ImpersonationSessionId != nil {
+		impersonationSessionIdString := options.ImpersonationSessionId.String()
+		return &impersonationSessionIdString
+	}
+	return nil
+}
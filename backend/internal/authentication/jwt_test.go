@@ -4,9 +4,11 @@ import (
 	"testing"
 	"time"
 
+	"reece.start/internal/api"
 	"reece.start/internal/constants"
 	testconfig "reece.start/test/config"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 )
@@ -35,6 +37,23 @@ func TestCreateJWT(t *testing.T) {
 		require.Equal(t, config.JwtIssuer, claims.Issuer)
 		require.Equal(t, config.JwtAudience, claims.Audience[0])
 		require.Equal(t, options.UserId.String(), claims.Subject)
+		require.Empty(t, claims.ID)
+	})
+
+	t.Run("WithSession", func(t *testing.T) {
+		config := testconfig.CreateTestConfig()
+		sessionId := uuid.New()
+		options := JwtOptions{
+			UserId:    uuid.New(),
+			SessionId: &sessionId,
+		}
+
+		token, err := CreateJWT(config, options)
+		require.NoError(t, err)
+
+		claims, err := ValidateJWT(config, token)
+		require.NoError(t, err)
+		require.Equal(t, sessionId.String(), claims.ID)
 	})
 
 	t.Run("WithOrganization", func(t *testing.T) {
@@ -71,15 +90,19 @@ func TestCreateJWT(t *testing.T) {
 		}
 		isImpersonating := true
 		impersonatingUserId := uuid.New()
+		impersonationSessionId := uuid.New()
+		isReadOnly := true
 
 		options := JwtOptions{
-			UserId:              uuid.New(),
-			OrganizationId:      &orgId,
-			OrganizationRole:    &orgRole,
-			Role:                &userRole,
-			Scopes:              &scopes,
-			IsImpersonating:     &isImpersonating,
-			ImpersonatingUserId: &impersonatingUserId,
+			UserId:                 uuid.New(),
+			OrganizationId:         &orgId,
+			OrganizationRole:       &orgRole,
+			Role:                   &userRole,
+			Scopes:                 &scopes,
+			IsImpersonating:        &isImpersonating,
+			ImpersonatingUserId:    &impersonatingUserId,
+			ImpersonationSessionId: &impersonationSessionId,
+			IsReadOnly:             &isReadOnly,
 		}
 
 		token, err := CreateJWT(config, options)
@@ -99,6 +122,9 @@ func TestCreateJWT(t *testing.T) {
 		require.NotNil(t, claims.IsImpersonating)
 		require.True(t, *claims.IsImpersonating)
 		require.Equal(t, impersonatingUserId.String(), *claims.ImpersonatingUserId)
+		require.Equal(t, impersonationSessionId.String(), *claims.ImpersonationSessionId)
+		require.NotNil(t, claims.IsReadOnly)
+		require.True(t, *claims.IsReadOnly)
 	})
 
 	t.Run("WithCustomExpiry", func(t *testing.T) {
@@ -240,6 +266,7 @@ func TestValidateJWT(t *testing.T) {
 
 		// Check that error message contains expiration-related text
 		require.Contains(t, err.Error(), "expired")
+		require.ErrorIs(t, err, api.ErrTokenExpired)
 	})
 
 	t.Run("MalformedToken", func(t *testing.T) {
@@ -247,7 +274,7 @@ func TestValidateJWT(t *testing.T) {
 		malformedToken := "not.a.valid.jwt.token"
 
 		claims, err := ValidateJWT(config, malformedToken)
-		require.Error(t, err)
+		require.ErrorIs(t, err, api.ErrTokenMalformed)
 		require.Nil(t, claims)
 	})
 
@@ -272,11 +299,9 @@ func TestValidateJWT(t *testing.T) {
 		require.NoError(t, err)
 
 		// Validate with original config
-		// Note: jwt-go v5 doesn't validate issuer by default, so this should still succeed
 		claims, err := ValidateJWT(config, wrongIssuerToken)
-		require.NoError(t, err)
-		require.NotNil(t, claims)
-		require.Equal(t, "wrong-issuer", claims.Issuer)
+		require.ErrorIs(t, err, api.ErrTokenInvalidIssuer)
+		require.Nil(t, claims)
 	})
 
 	t.Run("WrongAudience", func(t *testing.T) {
@@ -291,12 +316,66 @@ func TestValidateJWT(t *testing.T) {
 		wrongAudienceToken, err := CreateJWT(wrongAudienceConfig, options)
 		require.NoError(t, err)
 
-		// Validate with original config
-		// Note: jwt-go v5 doesn't validate audience by default, so this should still succeed
+		// Validate with original config, e.g. a staging token used against production
 		claims, err := ValidateJWT(config, wrongAudienceToken)
+		require.ErrorIs(t, err, api.ErrTokenInvalidAudience)
+		require.Nil(t, claims)
+	})
+
+	t.Run("ExpiredWithinLeeway", func(t *testing.T) {
+		config := testconfig.CreateTestConfig()
+		recentlyExpired := time.Now().Add(-10 * time.Second)
+		options := JwtOptions{
+			UserId:       uuid.New(),
+			CustomExpiry: &recentlyExpired,
+		}
+
+		token, err := CreateJWT(config, options)
+		require.NoError(t, err)
+
+		claims, err := ValidateJWT(config, token)
 		require.NoError(t, err)
 		require.NotNil(t, claims)
-		require.Contains(t, claims.Audience, "wrong-audience")
+
+		config.JwtLeeway = 0
+		claims, err = ValidateJWT(config, token)
+		require.ErrorIs(t, err, api.ErrTokenExpired)
+		require.Nil(t, claims)
+	})
+
+	t.Run("MissingExpiry", func(t *testing.T) {
+		config := testconfig.CreateTestConfig()
+
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, JwtClaims{
+			UserId: uuid.New().String(),
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:   config.JwtIssuer,
+				Audience: jwt.ClaimStrings{config.JwtAudience},
+			},
+		}).SignedString([]byte(config.JwtSecret))
+		require.NoError(t, err)
+
+		claims, err := ValidateJWT(config, token)
+		require.ErrorIs(t, err, api.ErrInvalidToken)
+		require.Nil(t, claims)
+	})
+
+	t.Run("UnexpectedAlgorithm", func(t *testing.T) {
+		config := testconfig.CreateTestConfig()
+
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, JwtClaims{
+			UserId: uuid.New().String(),
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+				Issuer:    config.JwtIssuer,
+				Audience:  jwt.ClaimStrings{config.JwtAudience},
+			},
+		}).SignedString([]byte(config.JwtSecret))
+		require.NoError(t, err)
+
+		claims, err := ValidateJWT(config, token)
+		require.ErrorIs(t, err, api.ErrInvalidToken)
+		require.Nil(t, claims)
 	})
 }
 
@@ -0,0 +1,302 @@
+package authentication
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"reece.start/internal/configuration"
+)
+
+// JwtKey is a single key in the key ring, identified by its kid.
+// Keys without a private key can only be used to verify tokens, e.g. a retired key whose tokens haven't expired yet.
+type JwtKey struct {
+	Id         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+}
+
+// KeyRing holds the keys used to sign and verify JWTs.
+// If no asymmetric keys are configured, tokens are signed and verified with the shared JwtSecret using HS256.
+type KeyRing struct {
+	signingKey *JwtKey
+	keys       map[string]*JwtKey
+	secret     []byte
+
+	// Tokens signed with the shared secret are still verified until then, after moving to asymmetric keys
+	secretAcceptedUntil time.Time
+}
+
+// Jwk is the public part of a key in JWK format (RFC 7517)
+type Jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	// RSA public key parameters
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// Ed25519 public key parameters
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type Jwks struct {
+	Keys []Jwk `json:"keys"`
+}
+
+// Key rings are parsed once per config, since keys may be read from disk
+var keyRings sync.Map
+
+// GetKeyRing returns the key ring for the given config, loading it on first use
+func GetKeyRing(config *configuration.Config) (*KeyRing, error) {
+	if keyRing, ok := keyRings.Load(config); ok {
+		return keyRing.(*KeyRing), nil
+	}
+
+	keyRing, err := NewKeyRing(config)
+	if err != nil {
+		return nil, err
+	}
+
+	actual, _ := keyRings.LoadOrStore(config, keyRing)
+	return actual.(*KeyRing), nil
+}
+
+// NewKeyRing loads the signing keys from the config.
+// JwtSigningKeyFiles and JwtSigningKeys are comma separated lists of `kid:value` pairs, where the value is either a
+// path to a PEM file or a base64 encoded PEM key. Only the key matching JwtSigningKeyId is used to sign new tokens,
+// every other key is kept around to verify tokens that were signed before a rotation.
+func NewKeyRing(config *configuration.Config) (*KeyRing, error) {
+	keyRing := &KeyRing{
+		keys:                make(map[string]*JwtKey),
+		secret:              []byte(config.JwtSecret),
+		secretAcceptedUntil: config.JwtSecretAcceptedUntil,
+	}
+
+	err := parseKeyList(config.JwtSigningKeyFiles, func(kid string, value string) error {
+		data, err := os.ReadFile(value)
+		if err != nil {
+			return fmt.Errorf("reading jwt signing key %s: %w", kid, err)
+		}
+		return keyRing.addPemKey(kid, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = parseKeyList(config.JwtSigningKeys, func(kid string, value string) error {
+		data, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return fmt.Errorf("decoding jwt signing key %s: %w", kid, err)
+		}
+		return keyRing.addPemKey(kid, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keyRing.keys) == 0 {
+		return keyRing, nil
+	}
+
+	signingKey, ok := keyRing.keys[config.JwtSigningKeyId]
+	if !ok {
+		return nil, fmt.Errorf("jwt signing key id %q does not match any configured key", config.JwtSigningKeyId)
+	}
+
+	if signingKey.PrivateKey == nil {
+		return nil, fmt.Errorf("jwt signing key %q has no private key", config.JwtSigningKeyId)
+	}
+
+	keyRing.signingKey = signingKey
+	return keyRing, nil
+}
+
+// Sign signs the claims with the active signing key
+func (k *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	if k.signingKey == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(k.secret)
+	}
+
+	token := jwt.NewWithClaims(k.signingKey.Method, claims)
+	token.Header["kid"] = k.signingKey.Id
+	return token.SignedString(k.signingKey.PrivateKey)
+}
+
+// Keyfunc resolves the key used to verify a token from its kid header.
+// The token's algorithm has to match the algorithm of the key, otherwise a token could pick how its own signature is checked.
+func (k *KeyRing) Keyfunc(token *jwt.Token) (any, error) {
+	if len(k.keys) == 0 {
+		if token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+			return nil, errors.New("unexpected jwt signing algorithm")
+		}
+		return k.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+
+	// Tokens signed with the shared secret before the move to asymmetric keys don't have a kid
+	if kid == "" && token.Method.Alg() == jwt.SigningMethodHS256.Alg() {
+		if !k.acceptsSecret() {
+			return nil, errors.New("jwt signed with the shared secret is no longer accepted")
+		}
+		return k.secret, nil
+	}
+
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, errors.New("unknown jwt signing key")
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("unexpected jwt signing algorithm")
+	}
+
+	return key.PublicKey, nil
+}
+
+// ValidMethods returns the algorithms accepted when verifying tokens
+func (k *KeyRing) ValidMethods() []string {
+	if len(k.keys) == 0 {
+		return []string{jwt.SigningMethodHS256.Alg()}
+	}
+
+	methods := make([]string, 0, 3)
+	for _, key := range k.keys {
+		if !slices.Contains(methods, key.Method.Alg()) {
+			methods = append(methods, key.Method.Alg())
+		}
+	}
+
+	if k.acceptsSecret() {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	return methods
+}
+
+// acceptsSecret reports whether tokens signed with the shared secret are still verified after moving to asymmetric keys
+func (k *KeyRing) acceptsSecret() bool {
+	return len(k.secret) > 0 && time.Now().Before(k.secretAcceptedUntil)
+}
+
+// Jwks returns the public keys of the key ring in JWKS format, sorted by kid.
+// Empty when tokens are signed with the shared secret, since that can't be published.
+func (k *KeyRing) Jwks() Jwks {
+	jwks := Jwks{Keys: make([]Jwk, 0, len(k.keys))}
+
+	for _, key := range k.keys {
+		jwk := Jwk{
+			Kid: key.Id,
+			Use: "sig",
+			Alg: key.Method.Alg(),
+		}
+
+		switch publicKey := key.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		return jwks.Keys[i].Kid < jwks.Keys[j].Kid
+	})
+
+	return jwks
+}
+
+func (k *KeyRing) addPemKey(kid string, data []byte) error {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return fmt.Errorf("jwt signing key %s is not PEM encoded", kid)
+	}
+
+	key := &JwtKey{Id: kid}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("parsing jwt signing key %s: %w", kid, err)
+		}
+		key.PublicKey = publicKey
+	case "RSA PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("parsing jwt signing key %s: %w", kid, err)
+		}
+		key.PrivateKey = privateKey
+		key.PublicKey = privateKey.Public()
+	default:
+		privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("parsing jwt signing key %s: %w", kid, err)
+		}
+		signer, ok := privateKey.(crypto.Signer)
+		if !ok {
+			return fmt.Errorf("jwt signing key %s is not an RSA or Ed25519 key", kid)
+		}
+		key.PrivateKey = signer
+		key.PublicKey = signer.Public()
+	}
+
+	switch key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return fmt.Errorf("jwt signing key %s is not an RSA or Ed25519 key", kid)
+	}
+
+	if _, exists := k.keys[kid]; exists {
+		return fmt.Errorf("duplicate jwt signing key id %s", kid)
+	}
+
+	k.keys[kid] = key
+	return nil
+}
+
+func parseKeyList(list string, add func(kid string, value string) error) error {
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		kid, value, ok := strings.Cut(entry, ":")
+		if !ok || kid == "" || value == "" {
+			return fmt.Errorf("invalid jwt signing key entry %q, expected kid:value", entry)
+		}
+
+		if err := add(kid, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,255 @@
+package authentication
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	testconfig "reece.start/test/config"
+)
+
+func generateRsaPem(t *testing.T) []byte {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+}
+
+func generateEd25519Pem(t *testing.T) ([]byte, []byte) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	privateDer, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	publicDer, err := x509.MarshalPKIXPublicKey(publicKey)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDer}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDer})
+}
+
+func writeKeyFile(t *testing.T, data []byte) string {
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	return path
+}
+
+func TestKeyRing(t *testing.T) {
+	t.Run("FallsBackToSharedSecret", func(t *testing.T) {
+		config := testconfig.CreateTestConfig()
+
+		token, err := CreateJWT(config, JwtOptions{UserId: uuid.New()})
+		require.NoError(t, err)
+
+		parsed, _, err := jwt.NewParser().ParseUnverified(token, &JwtClaims{})
+		require.NoError(t, err)
+		require.Equal(t, jwt.SigningMethodHS256.Alg(), parsed.Method.Alg())
+
+		keyRing, err := GetKeyRing(config)
+		require.NoError(t, err)
+		require.Empty(t, keyRing.Jwks().Keys)
+	})
+
+	t.Run("SignsWithRsaKeyFromFile", func(t *testing.T) {
+		config := testconfig.CreateTestConfig()
+		config.JwtSigningKeyFiles = "rsa-1:" + writeKeyFile(t, generateRsaPem(t))
+		config.JwtSigningKeyId = "rsa-1"
+
+		userId := uuid.New()
+		token, err := CreateJWT(config, JwtOptions{UserId: userId})
+		require.NoError(t, err)
+
+		parsed, _, err := jwt.NewParser().ParseUnverified(token, &JwtClaims{})
+		require.NoError(t, err)
+		require.Equal(t, jwt.SigningMethodRS256.Alg(), parsed.Method.Alg())
+		require.Equal(t, "rsa-1", parsed.Header["kid"])
+
+		claims, err := ValidateJWT(config, token)
+		require.NoError(t, err)
+		require.Equal(t, userId.String(), claims.UserId)
+	})
+
+	t.Run("SignsWithInlineEd25519Key", func(t *testing.T) {
+		privatePem, _ := generateEd25519Pem(t)
+		config := testconfig.CreateTestConfig()
+		config.JwtSigningKeys = "ed-1:" + base64.StdEncoding.EncodeToString(privatePem)
+		config.JwtSigningKeyId = "ed-1"
+
+		token, err := CreateJWT(config, JwtOptions{UserId: uuid.New()})
+		require.NoError(t, err)
+
+		parsed, _, err := jwt.NewParser().ParseUnverified(token, &JwtClaims{})
+		require.NoError(t, err)
+		require.Equal(t, jwt.SigningMethodEdDSA.Alg(), parsed.Method.Alg())
+
+		_, err = ValidateJWT(config, token)
+		require.NoError(t, err)
+	})
+
+	t.Run("VerifiesTokensSignedWithPreviousKey", func(t *testing.T) {
+		oldKey := writeKeyFile(t, generateRsaPem(t))
+		newKey := writeKeyFile(t, generateRsaPem(t))
+
+		oldConfig := testconfig.CreateTestConfig()
+		oldConfig.JwtSigningKeyFiles = "old:" + oldKey
+		oldConfig.JwtSigningKeyId = "old"
+
+		token, err := CreateJWT(oldConfig, JwtOptions{UserId: uuid.New()})
+		require.NoError(t, err)
+
+		// Stage the rotation: sign with the new key, keep the old one for verification
+		rotatedConfig := testconfig.CreateTestConfig()
+		rotatedConfig.JwtSigningKeyFiles = "old:" + oldKey + ",new:" + newKey
+		rotatedConfig.JwtSigningKeyId = "new"
+
+		_, err = ValidateJWT(rotatedConfig, token)
+		require.NoError(t, err)
+
+		newToken, err := CreateJWT(rotatedConfig, JwtOptions{UserId: uuid.New()})
+		require.NoError(t, err)
+		parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &JwtClaims{})
+		require.NoError(t, err)
+		require.Equal(t, "new", parsed.Header["kid"])
+
+		// Once the old key is removed its tokens are rejected
+		retiredConfig := testconfig.CreateTestConfig()
+		retiredConfig.JwtSigningKeyFiles = "new:" + newKey
+		retiredConfig.JwtSigningKeyId = "new"
+
+		_, err = ValidateJWT(retiredConfig, token)
+		require.Error(t, err)
+	})
+
+	t.Run("VerifiesWithPublicKeyOnly", func(t *testing.T) {
+		privatePem, publicPem := generateEd25519Pem(t)
+
+		signingConfig := testconfig.CreateTestConfig()
+		signingConfig.JwtSigningKeyFiles = "ed-1:" + writeKeyFile(t, privatePem)
+		signingConfig.JwtSigningKeyId = "ed-1"
+
+		token, err := CreateJWT(signingConfig, JwtOptions{UserId: uuid.New()})
+		require.NoError(t, err)
+
+		verifyingConfig := testconfig.CreateTestConfig()
+		verifyingConfig.JwtSigningKeyFiles = "ed-1:" + writeKeyFile(t, publicPem) + ",ed-2:" + writeKeyFile(t, generateRsaPem(t))
+		verifyingConfig.JwtSigningKeyId = "ed-2"
+
+		_, err = ValidateJWT(verifyingConfig, token)
+		require.NoError(t, err)
+	})
+
+	t.Run("RejectsSharedSecretTokensWhenUsingKeys", func(t *testing.T) {
+		config := testconfig.CreateTestConfig()
+		token, err := CreateJWT(config, JwtOptions{UserId: uuid.New()})
+		require.NoError(t, err)
+
+		config.JwtSigningKeyFiles = "rsa-1:" + writeKeyFile(t, generateRsaPem(t))
+		config.JwtSigningKeyId = "rsa-1"
+		keyRings.Delete(config)
+
+		_, err = ValidateJWT(config, token)
+		require.Error(t, err)
+	})
+
+	t.Run("VerifiesSharedSecretTokensDuringTransition", func(t *testing.T) {
+		config := testconfig.CreateTestConfig()
+		userId := uuid.New()
+		token, err := CreateJWT(config, JwtOptions{UserId: userId})
+		require.NoError(t, err)
+
+		// Stage the move to keys: sign with the new key, keep verifying tokens signed with the shared secret
+		config.JwtSigningKeyFiles = "rsa-1:" + writeKeyFile(t, generateRsaPem(t))
+		config.JwtSigningKeyId = "rsa-1"
+		config.JwtSecretAcceptedUntil = time.Now().Add(time.Hour)
+		keyRings.Delete(config)
+
+		claims, err := ValidateJWT(config, token)
+		require.NoError(t, err)
+		require.Equal(t, userId.String(), claims.UserId)
+
+		newToken, err := CreateJWT(config, JwtOptions{UserId: userId})
+		require.NoError(t, err)
+		parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &JwtClaims{})
+		require.NoError(t, err)
+		require.Equal(t, jwt.SigningMethodRS256.Alg(), parsed.Method.Alg())
+
+		// Once the transition is over the shared secret tokens are rejected
+		config.JwtSecretAcceptedUntil = time.Now().Add(-time.Minute)
+		keyRings.Delete(config)
+
+		_, err = ValidateJWT(config, token)
+		require.Error(t, err)
+	})
+
+	t.Run("RejectsAlgorithmMismatch", func(t *testing.T) {
+		keyPem := generateRsaPem(t)
+		config := testconfig.CreateTestConfig()
+		config.JwtSigningKeyFiles = "rsa-1:" + writeKeyFile(t, keyPem)
+		config.JwtSigningKeyId = "rsa-1"
+		config.JwtSecretAcceptedUntil = time.Now().Add(time.Hour) // Even while shared secret tokens are still accepted
+
+		// Sign an HS256 token using the PEM bytes as the secret, claiming the RSA key's kid
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, JwtClaims{UserId: uuid.New().String()})
+		token.Header["kid"] = "rsa-1"
+		tokenString, err := token.SignedString(keyPem)
+		require.NoError(t, err)
+
+		_, err = ValidateJWT(config, tokenString)
+		require.Error(t, err)
+	})
+
+	t.Run("PublishesJwks", func(t *testing.T) {
+		_, edPublicPem := generateEd25519Pem(t)
+		config := testconfig.CreateTestConfig()
+		config.JwtSigningKeyFiles = "b-rsa:" + writeKeyFile(t, generateRsaPem(t)) + ",a-ed:" + writeKeyFile(t, edPublicPem)
+		config.JwtSigningKeyId = "b-rsa"
+
+		keyRing, err := NewKeyRing(config)
+		require.NoError(t, err)
+
+		jwks := keyRing.Jwks()
+		require.Len(t, jwks.Keys, 2)
+
+		require.Equal(t, "a-ed", jwks.Keys[0].Kid)
+		require.Equal(t, "OKP", jwks.Keys[0].Kty)
+		require.Equal(t, "Ed25519", jwks.Keys[0].Crv)
+		require.Equal(t, "EdDSA", jwks.Keys[0].Alg)
+		require.NotEmpty(t, jwks.Keys[0].X)
+
+		require.Equal(t, "b-rsa", jwks.Keys[1].Kid)
+		require.Equal(t, "RSA", jwks.Keys[1].Kty)
+		require.Equal(t, "RS256", jwks.Keys[1].Alg)
+		require.Equal(t, "AQAB", jwks.Keys[1].E)
+		require.NotEmpty(t, jwks.Keys[1].N)
+	})
+
+	t.Run("InvalidConfiguration", func(t *testing.T) {
+		_, publicPem := generateEd25519Pem(t)
+
+		config := testconfig.CreateTestConfig()
+		config.JwtSigningKeyFiles = "rsa-1:" + writeKeyFile(t, generateRsaPem(t))
+		config.JwtSigningKeyId = "missing"
+		_, err := NewKeyRing(config)
+		require.Error(t, err)
+
+		config = testconfig.CreateTestConfig()
+		config.JwtSigningKeyFiles = "ed-1:" + writeKeyFile(t, publicPem)
+		config.JwtSigningKeyId = "ed-1"
+		_, err = NewKeyRing(config)
+		require.Error(t, err)
+
+		config = testconfig.CreateTestConfig()
+		config.JwtSigningKeys = "not-a-pair"
+		_, err = NewKeyRing(config)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,87 @@
+package authentication
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"reece.start/internal/api"
+	"reece.start/internal/configuration"
+)
+
+const (
+	mfaChallengeAudienceSuffix = "/mfa-challenge"
+	mfaChallengeExpiry         = 5 * time.Minute
+
+	recoveryCodeCount = 10
+)
+
+// MfaChallengeClaims are the claims of the short lived token returned by a password login when the user has MFA enabled.
+// It proves the password was correct and has to be exchanged, along with a valid code, for a session token.
+type MfaChallengeClaims struct {
+	jwt.RegisteredClaims
+	UserId string `json:"user_id"`
+}
+
+// CreateMfaChallengeJWT creates an MFA challenge token for the user
+func CreateMfaChallengeJWT(config *configuration.Config, userId uuid.UUID) (string, error) {
+	keyRing, err := GetKeyRing(config)
+	if err != nil {
+		return "", err
+	}
+
+	return keyRing.Sign(MfaChallengeClaims{
+		UserId:           userId.String(),
+		RegisteredClaims: getPurposeRegisteredClaims(config, mfaChallengeAudienceSuffix, userId, mfaChallengeExpiry),
+	})
+}
+
+// ValidateMfaChallengeJWT validates an MFA challenge token and returns the ID of the user it was issued to, and when
+func ValidateMfaChallengeJWT(config *configuration.Config, tokenString string) (uuid.UUID, time.Time, error) {
+	claims := &MfaChallengeClaims{}
+	err := parsePurposeJWT(config, tokenString, mfaChallengeAudienceSuffix, claims)
+	if err != nil {
+		return uuid.Nil, time.Time{}, err
+	}
+
+	userId, err := uuid.Parse(claims.UserId)
+	if err != nil || claims.IssuedAt == nil {
+		return uuid.Nil, time.Time{}, api.ErrInvalidToken
+	}
+
+	return userId, claims.IssuedAt.Time, nil
+}
+
+// GenerateRecoveryCodes generates a set of one time MFA recovery codes.
+// Returns the codes to show to the user once, and the hashes that should be stored in the database.
+func GenerateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+
+		// 16 base32 characters, grouped to make them easier to copy down
+		encoded := strings.ToLower(totpEncoding.EncodeToString(b))
+		code := encoded[0:4] + "-" + encoded[4:8] + "-" + encoded[8:12] + "-" + encoded[12:16]
+
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode hashes a recovery code for storage and lookup, ignoring case and separators
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
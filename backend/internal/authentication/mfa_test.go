@@ -0,0 +1,64 @@
+package authentication
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	testconfig "reece.start/test/config"
+)
+
+func TestMfaChallengeJWT(t *testing.T) {
+	t.Run("RoundTrip", func(t *testing.T) {
+		config := testconfig.CreateTestConfig()
+		userId := uuid.New()
+
+		token, err := CreateMfaChallengeJWT(config, userId)
+		require.NoError(t, err)
+
+		parsedUserId, issuedAt, err := ValidateMfaChallengeJWT(config, token)
+		require.NoError(t, err)
+		require.Equal(t, userId, parsedUserId)
+		require.WithinDuration(t, time.Now(), issuedAt, 2*time.Second)
+	})
+
+	t.Run("CannotBeUsedAsSessionToken", func(t *testing.T) {
+		config := testconfig.CreateTestConfig()
+
+		token, err := CreateMfaChallengeJWT(config, uuid.New())
+		require.NoError(t, err)
+
+		_, err = ValidateJWT(config, token)
+		require.Error(t, err)
+	})
+
+	t.Run("SessionTokenIsNotAChallenge", func(t *testing.T) {
+		config := testconfig.CreateTestConfig()
+
+		token, err := CreateJWT(config, JwtOptions{UserId: uuid.New()})
+		require.NoError(t, err)
+
+		_, _, err = ValidateMfaChallengeJWT(config, token)
+		require.Error(t, err)
+	})
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, hashes, err := GenerateRecoveryCodes()
+	require.NoError(t, err)
+	require.Len(t, codes, recoveryCodeCount)
+	require.Len(t, hashes, recoveryCodeCount)
+
+	seen := make(map[string]bool)
+	for i, code := range codes {
+		require.Len(t, code, 19)
+		require.Equal(t, HashRecoveryCode(code), hashes[i])
+		require.False(t, seen[code])
+		seen[code] = true
+	}
+
+	// Codes are matched regardless of case and separators
+	require.Equal(t, HashRecoveryCode(codes[0]), HashRecoveryCode(" "+strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))+" "))
+}
@@ -0,0 +1,382 @@
+package authentication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+	"golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/microsoft"
+	"reece.start/internal/api"
+	"reece.start/internal/configuration"
+)
+
+// OAuth providers users can sign in with. The name is used in the /oauth/:provider/callback route and stored on
+// linked identities, so it shouldn't change once users have signed in with a provider.
+const (
+	OAuthProviderGoogle    = "google"
+	OAuthProviderGithub    = "github"
+	OAuthProviderMicrosoft = "microsoft"
+	OAuthProviderOidc      = "oidc"
+)
+
+// OAuthProfile is the identity returned by a provider once an authorization code has been exchanged
+type OAuthProfile struct {
+	Subject       string // Stable ID of the user at the provider
+	Email         string
+	EmailVerified bool // Whether the provider vouches that the user owns Email
+	Name          string
+	ProfileImage  string
+}
+
+// OAuthProvider exchanges authorization codes for the user's profile at an identity provider
+type OAuthProvider struct {
+	Name         string
+	clientId     string
+	clientSecret string
+	scopes       []string
+
+	// Fetches the user's profile with an authorized client
+	fetchProfile func(ctx context.Context, client *http.Client, userInfoUrl string) (*OAuthProfile, error)
+
+	// Generic OIDC providers only know their issuer, the endpoints are discovered on first use
+	issuerUrl   string
+	mu          sync.Mutex
+	endpoint    oauth2.Endpoint
+	userInfoUrl string
+}
+
+// Providers are created once per config, since OIDC discovery results are kept on the provider
+var oauthProviders sync.Map
+
+// GetOAuthProvider returns the provider with the given name, if it is configured
+func GetOAuthProvider(config *configuration.Config, name string) (*OAuthProvider, error) {
+	providers, ok := oauthProviders.Load(config)
+	if !ok {
+		providers, _ = oauthProviders.LoadOrStore(config, NewOAuthProviders(config))
+	}
+
+	provider, ok := providers.(map[string]*OAuthProvider)[name]
+	if !ok {
+		return nil, api.ErrOAuthProviderNotFound
+	}
+
+	return provider, nil
+}
+
+// NewOAuthProviders creates the providers that have a client ID configured
+func NewOAuthProviders(config *configuration.Config) map[string]*OAuthProvider {
+	providers := make(map[string]*OAuthProvider)
+
+	if config.GoogleOAuthClientId != "" {
+		providers[OAuthProviderGoogle] = &OAuthProvider{
+			Name:         OAuthProviderGoogle,
+			clientId:     config.GoogleOAuthClientId,
+			clientSecret: config.GoogleOAuthClientSecret,
+			scopes: []string{
+				"https://www.googleapis.com/auth/userinfo.email",
+				"https://www.googleapis.com/auth/userinfo.profile",
+			},
+			endpoint:     google.Endpoint,
+			userInfoUrl:  "https://www.googleapis.com/oauth2/v2/userinfo",
+			fetchProfile: fetchGoogleProfile,
+		}
+	}
+
+	if config.GithubOAuthClientId != "" {
+		providers[OAuthProviderGithub] = &OAuthProvider{
+			Name:         OAuthProviderGithub,
+			clientId:     config.GithubOAuthClientId,
+			clientSecret: config.GithubOAuthClientSecret,
+			scopes:       []string{"read:user", "user:email"},
+			endpoint:     github.Endpoint,
+			userInfoUrl:  "https://api.github.com/user",
+			fetchProfile: fetchGithubProfile,
+		}
+	}
+
+	if config.MicrosoftOAuthClientId != "" {
+		providers[OAuthProviderMicrosoft] = &OAuthProvider{
+			Name:         OAuthProviderMicrosoft,
+			clientId:     config.MicrosoftOAuthClientId,
+			clientSecret: config.MicrosoftOAuthClientSecret,
+			scopes:       []string{"openid", "email", "profile"},
+			endpoint:     microsoft.AzureADEndpoint(config.MicrosoftOAuthTenant),
+			userInfoUrl:  "https://graph.microsoft.com/oidc/userinfo",
+			fetchProfile: fetchOidcProfile,
+		}
+	}
+
+	if config.OidcOAuthClientId != "" && config.OidcOAuthIssuerUrl != "" {
+		providers[OAuthProviderOidc] = &OAuthProvider{
+			Name:         OAuthProviderOidc,
+			clientId:     config.OidcOAuthClientId,
+			clientSecret: config.OidcOAuthClientSecret,
+			scopes:       strings.Fields(config.OidcOAuthScopes),
+			issuerUrl:    strings.TrimSuffix(config.OidcOAuthIssuerUrl, "/"),
+			fetchProfile: fetchOidcProfile,
+		}
+	}
+
+	return providers
+}
+
+// OAuthAuthorization is the state of an authorization started by the server, which the callback has to present
+type OAuthAuthorization struct {
+	State        string
+	CodeVerifier string // PKCE (RFC 7636) verifier, only its S256 challenge is sent in the authorization URL
+	Nonce        string // Checked against the ID token's nonce claim, for providers that return one
+}
+
+// NewOAuthAuthorization generates a random state, PKCE verifier and nonce for a new authorization
+func NewOAuthAuthorization() (*OAuthAuthorization, error) {
+	state, _, err := GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, _, err := GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	return &OAuthAuthorization{
+		State:        state,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		Nonce:        nonce,
+	}, nil
+}
+
+// IsAllowedOAuthRedirectUri checks the redirect URI against the configured allowlist.
+// Only exact matches are allowed, so an authorization code can't be sent anywhere else.
+func IsAllowedOAuthRedirectUri(config *configuration.Config, redirectUri string) bool {
+	for _, allowed := range strings.Split(config.OAuthRedirectUris, ",") {
+		if strings.TrimSpace(allowed) == redirectUri && redirectUri != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthCodeURL returns the URL to send the user to in order to start the authorization
+func (p *OAuthProvider) AuthCodeURL(ctx context.Context, redirectUri string, authorization *OAuthAuthorization) (string, error) {
+	oauth2Config, _, err := p.getOAuth2Config(ctx, redirectUri)
+	if err != nil {
+		return "", err
+	}
+
+	return oauth2Config.AuthCodeURL(
+		authorization.State,
+		oauth2.S256ChallengeOption(authorization.CodeVerifier),
+		oauth2.SetAuthURLParam("nonce", authorization.Nonce),
+	), nil
+}
+
+// Exchange exchanges the authorization code returned to redirectUri for the user's profile.
+// The authorization has to be the one the code was issued for, otherwise the provider rejects the PKCE verifier.
+func (p *OAuthProvider) Exchange(ctx context.Context, code string, redirectUri string, authorization *OAuthAuthorization) (*OAuthProfile, error) {
+	oauth2Config, userInfoUrl, err := p.getOAuth2Config(ctx, redirectUri)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := oauth2Config.Exchange(ctx, code, oauth2.VerifierOption(authorization.CodeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("exchanging %s authorization code: %w", p.Name, err)
+	}
+
+	// The ID token came straight from the token endpoint over TLS, so its signature doesn't need to be checked
+	// (OpenID Connect Core 3.1.3.7), but its nonce has to match the one sent when the authorization was started
+	if idToken, ok := token.Extra("id_token").(string); ok && idToken != "" {
+		claims := jwt.MapClaims{}
+		if _, _, err := jwt.NewParser().ParseUnverified(idToken, claims); err != nil {
+			return nil, fmt.Errorf("parsing %s id token: %w", p.Name, err)
+		}
+
+		if nonce, _ := claims["nonce"].(string); nonce != authorization.Nonce {
+			return nil, fmt.Errorf("%s id token nonce does not match", p.Name)
+		}
+	}
+
+	profile, err := p.fetchProfile(ctx, oauth2Config.Client(ctx, token), userInfoUrl)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s profile: %w", p.Name, err)
+	}
+
+	if profile.Subject == "" {
+		return nil, fmt.Errorf("%s profile has no subject", p.Name)
+	}
+
+	return profile, nil
+}
+
+func (p *OAuthProvider) getOAuth2Config(ctx context.Context, redirectUri string) (*oauth2.Config, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Failed discoveries aren't cached, so a provider that was briefly unavailable is retried on the next login
+	if p.issuerUrl != "" && p.userInfoUrl == "" {
+		if err := p.discover(ctx); err != nil {
+			return nil, "", err
+		}
+	}
+
+	return &oauth2.Config{
+		ClientID:     p.clientId,
+		ClientSecret: p.clientSecret,
+		RedirectURL:  redirectUri,
+		Scopes:       p.scopes,
+		Endpoint:     p.endpoint,
+	}, p.userInfoUrl, nil
+}
+
+// discover reads the provider's endpoints from its OpenID Connect discovery document
+func (p *OAuthProvider) discover(ctx context.Context) error {
+	var discovery struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+
+	err := getOAuthJson(ctx, http.DefaultClient, p.issuerUrl+"/.well-known/openid-configuration", &discovery)
+	if err != nil {
+		return fmt.Errorf("discovering %s endpoints: %w", p.Name, err)
+	}
+
+	// The discovery document has to be for the configured issuer, otherwise it could point logins at another provider
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.issuerUrl {
+		return fmt.Errorf("%s discovery document is for issuer %q, expected %q", p.Name, discovery.Issuer, p.issuerUrl)
+	}
+
+	if discovery.TokenEndpoint == "" || discovery.UserInfoEndpoint == "" {
+		return fmt.Errorf("%s discovery document is missing the token or userinfo endpoint", p.Name)
+	}
+
+	p.endpoint = oauth2.Endpoint{
+		AuthURL:  discovery.AuthorizationEndpoint,
+		TokenURL: discovery.TokenEndpoint,
+	}
+	p.userInfoUrl = discovery.UserInfoEndpoint
+
+	return nil
+}
+
+func fetchGoogleProfile(ctx context.Context, client *http.Client, userInfoUrl string) (*OAuthProfile, error) {
+	var userInfo struct {
+		ID            string `json:"id"`
+		Email         string `json:"email"`
+		VerifiedEmail bool   `json:"verified_email"`
+		Name          string `json:"name"`
+		Picture       string `json:"picture"`
+	}
+
+	if err := getOAuthJson(ctx, client, userInfoUrl, &userInfo); err != nil {
+		return nil, err
+	}
+
+	return &OAuthProfile{
+		Subject:       userInfo.ID,
+		Email:         userInfo.Email,
+		EmailVerified: userInfo.VerifiedEmail,
+		Name:          userInfo.Name,
+		ProfileImage:  userInfo.Picture,
+	}, nil
+}
+
+func fetchGithubProfile(ctx context.Context, client *http.Client, userInfoUrl string) (*OAuthProfile, error) {
+	var userInfo struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		AvatarUrl string `json:"avatar_url"`
+	}
+
+	if err := getOAuthJson(ctx, client, userInfoUrl, &userInfo); err != nil {
+		return nil, err
+	}
+
+	// The profile only includes the public email, the primary one has to be looked up separately
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+
+	if err := getOAuthJson(ctx, client, userInfoUrl+"/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	profile := &OAuthProfile{
+		Subject:      strconv.FormatInt(userInfo.ID, 10),
+		Name:         userInfo.Name,
+		ProfileImage: userInfo.AvatarUrl,
+	}
+
+	if profile.Name == "" {
+		profile.Name = userInfo.Login
+	}
+
+	for _, email := range emails {
+		if email.Primary {
+			profile.Email = email.Email
+			profile.EmailVerified = email.Verified
+			break
+		}
+	}
+
+	return profile, nil
+}
+
+func fetchOidcProfile(ctx context.Context, client *http.Client, userInfoUrl string) (*OAuthProfile, error) {
+	var userInfo struct {
+		Subject       string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified any    `json:"email_verified"` // Some providers send this as a string
+		Name          string `json:"name"`
+		Picture       string `json:"picture"`
+	}
+
+	if err := getOAuthJson(ctx, client, userInfoUrl, &userInfo); err != nil {
+		return nil, err
+	}
+
+	// Providers that don't send email_verified (e.g. Microsoft) are treated as not having verified the email
+	emailVerified := userInfo.EmailVerified == true || userInfo.EmailVerified == "true"
+
+	return &OAuthProfile{
+		Subject:       userInfo.Subject,
+		Email:         userInfo.Email,
+		EmailVerified: emailVerified,
+		Name:          userInfo.Name,
+		ProfileImage:  userInfo.Picture,
+	}, nil
+}
+
+func getOAuthJson(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("unexpected status " + resp.Status + " from " + url)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,119 @@
+package authentication
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"reece.start/internal/api"
+	testconfig "reece.start/test/config"
+	"reece.start/test/mocks"
+)
+
+func TestOAuthProviders(t *testing.T) {
+	t.Run("OnlyConfiguredProvidersAreEnabled", func(t *testing.T) {
+		config := testconfig.CreateTestConfig()
+		config.GithubOAuthClientId = "github-client"
+
+		providers := NewOAuthProviders(config)
+		require.Len(t, providers, 1)
+		require.Contains(t, providers, OAuthProviderGithub)
+
+		_, err := GetOAuthProvider(config, OAuthProviderGoogle)
+		require.ErrorIs(t, err, api.ErrOAuthProviderNotFound)
+	})
+
+	t.Run("ExchangesCodeWithDiscoveredOidcProvider", func(t *testing.T) {
+		server := mocks.NewOIDCServer(t)
+		config := testconfig.CreateTestConfig()
+		server.Configure(config)
+
+		provider, err := GetOAuthProvider(config, OAuthProviderOidc)
+		require.NoError(t, err)
+
+		authorization, err := NewOAuthAuthorization()
+		require.NoError(t, err)
+
+		authorizationUrl, err := provider.AuthCodeURL(t.Context(), "http://localhost:3000/oauth/oidc/callback", authorization)
+		require.NoError(t, err)
+		require.Contains(t, authorizationUrl, "code_challenge_method=S256")
+		require.Contains(t, authorizationUrl, "state="+authorization.State)
+
+		code := server.IssueCode(mocks.OIDCUser{
+			Subject:       "subject-1",
+			Email:         "oidc@example.com",
+			EmailVerified: true,
+			Name:          "OIDC User",
+			Picture:       "https://example.com/picture.png",
+		}, authorizationUrl)
+
+		profile, err := provider.Exchange(t.Context(), code, "http://localhost:3000/oauth/oidc/callback", authorization)
+		require.NoError(t, err)
+		require.Equal(t, "subject-1", profile.Subject)
+		require.Equal(t, "oidc@example.com", profile.Email)
+		require.True(t, profile.EmailVerified)
+		require.Equal(t, "OIDC User", profile.Name)
+		require.Equal(t, "https://example.com/picture.png", profile.ProfileImage)
+
+		// Codes can only be exchanged once
+		_, err = provider.Exchange(t.Context(), code, "http://localhost:3000/oauth/oidc/callback", authorization)
+		require.Error(t, err)
+	})
+
+	t.Run("RejectsWrongCodeVerifier", func(t *testing.T) {
+		server := mocks.NewOIDCServer(t)
+		config := testconfig.CreateTestConfig()
+		server.Configure(config)
+
+		provider, err := GetOAuthProvider(config, OAuthProviderOidc)
+		require.NoError(t, err)
+
+		authorization, err := NewOAuthAuthorization()
+		require.NoError(t, err)
+		authorizationUrl, err := provider.AuthCodeURL(t.Context(), "http://localhost:3000/oauth/oidc/callback", authorization)
+		require.NoError(t, err)
+
+		otherAuthorization, err := NewOAuthAuthorization()
+		require.NoError(t, err)
+
+		code := server.IssueCode(mocks.OIDCUser{Subject: "subject-1"}, authorizationUrl)
+		_, err = provider.Exchange(t.Context(), code, "http://localhost:3000/oauth/oidc/callback", otherAuthorization)
+		require.Error(t, err)
+	})
+
+	t.Run("RejectsMismatchedNonce", func(t *testing.T) {
+		server := mocks.NewOIDCServer(t)
+		config := testconfig.CreateTestConfig()
+		server.Configure(config)
+
+		provider, err := GetOAuthProvider(config, OAuthProviderOidc)
+		require.NoError(t, err)
+
+		authorization, err := NewOAuthAuthorization()
+		require.NoError(t, err)
+		authorizationUrl, err := provider.AuthCodeURL(t.Context(), "http://localhost:3000/oauth/oidc/callback", authorization)
+		require.NoError(t, err)
+
+		code := server.IssueCode(mocks.OIDCUser{Subject: "subject-1"}, authorizationUrl)
+		authorization.Nonce = "another-nonce"
+		_, err = provider.Exchange(t.Context(), code, "http://localhost:3000/oauth/oidc/callback", authorization)
+		require.ErrorContains(t, err, "nonce")
+	})
+
+	t.Run("RejectsDiscoveryForAnotherIssuer", func(t *testing.T) {
+		server := mocks.NewOIDCServer(t)
+		config := testconfig.CreateTestConfig()
+		server.Configure(config)
+		// Same server, but the discovery document names a different issuer than the one configured
+		config.OidcOAuthIssuerUrl = strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+		provider, err := GetOAuthProvider(config, OAuthProviderOidc)
+		require.NoError(t, err)
+
+		authorization, err := NewOAuthAuthorization()
+		require.NoError(t, err)
+
+		_, err = provider.AuthCodeURL(t.Context(), "http://localhost:3000/oauth/oidc/callback", authorization)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,73 @@
+package authentication
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"reece.start/internal/constants"
+)
+
+// How much of a prefixed token, after its prefix, is stored in the clear to identify it
+const prefixedTokenDisplayLength = 6
+
+// Last used times of prefixed tokens are only written once per interval, so busy scripts and integrations don't
+// cause a write on every request
+const prefixedTokenLastUsedInterval = time.Minute
+
+// GenerateOpaqueToken generates a random token, e.g. for refresh tokens or links sent by email.
+// Returns the token to hand to the user and the hash that should be stored in the database.
+func GenerateOpaqueToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, HashOpaqueToken(token), nil
+}
+
+// HashOpaqueToken hashes an opaque token for storage and lookup.
+// The tokens are 256 bits of randomness, so a fast hash is sufficient (unlike passwords).
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// generatePrefixedToken generates a long lived token that starts with prefix, like personal access tokens and API keys.
+// Returns the token to hand to the user, the hash that should be stored in the database, and the prefix to display.
+func generatePrefixedToken(prefix string) (string, string, string, error) {
+	secret, _, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	token := prefix + secret
+	return token, HashOpaqueToken(token), token[:len(prefix)+prefixedTokenDisplayLength], nil
+}
+
+// filterScopes returns the stored scopes that are also in allowedScopes
+func filterScopes(storedScopes string, allowedScopes []constants.UserScope) []constants.UserScope {
+	scopes := make([]constants.UserScope, 0)
+	for _, scope := range ParseScopes(storedScopes) {
+		if slices.Contains(allowedScopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// recordPrefixedTokenUse records when and from where a prefixed token was used, at most once per interval unless the
+// IP address changed. model is the token's model, e.g. &models.PersonalAccessToken{}. Reports whether the use was
+// recorded.
+func recordPrefixedTokenUse(db *gorm.DB, model any, id uuid.UUID, now time.Time, ipAddress string) (bool, error) {
+	result := db.Model(model).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ? OR last_used_ip <> ?)", id, now.Add(-prefixedTokenLastUsedInterval), ipAddress).
+		Updates(map[string]any{"last_used_at": now, "last_used_ip": ipAddress})
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,131 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"reece.start/internal/api"
+	"reece.start/internal/constants"
+	"reece.start/internal/dns"
+	"reece.start/internal/models"
+)
+
+// Hostname labels, which are at most 63 letters, digits and hyphens, and can't start or end with a hyphen
+var domainLabelRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// NormalizeDomain lowercases a domain and removes a trailing dot. Returns api.ErrDomainInvalid unless the result is a
+// hostname with at least two labels.
+func NormalizeDomain(domain string) (string, error) {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+
+	labels := strings.Split(domain, ".")
+	if len(domain) > 253 || len(labels) < 2 {
+		return "", api.ErrDomainInvalid
+	}
+
+	for _, label := range labels {
+		if !domainLabelRegex.MatchString(label) {
+			return "", api.ErrDomainInvalid
+		}
+	}
+
+	return domain, nil
+}
+
+// GetEmailDomain returns the lowercase domain of an email address, or an empty string if it doesn't have one
+func GetEmailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+
+	return strings.ToLower(email[at+1:])
+}
+
+// GetDomainVerificationRecord returns the name and value of the TXT record that proves ownership of the domain
+func GetDomainVerificationRecord(domain string, verificationToken string) (string, string) {
+	return constants.OrganizationDomainVerificationRecordPrefix + domain,
+		constants.OrganizationDomainVerificationValuePrefix + verificationToken
+}
+
+// VerifyDomainOwnership checks that the domain has the TXT record for the verification token.
+// Returns api.ErrDomainVerificationFailed if it doesn't, including when the lookup fails.
+func VerifyDomainOwnership(ctx context.Context, resolver dns.Resolver, domain string, verificationToken string) error {
+	name, value := GetDomainVerificationRecord(domain, verificationToken)
+
+	records, err := resolver.LookupTXT(ctx, name)
+	if err != nil {
+		slog.Info("Domain verification TXT lookup failed", "domain", domain, "error", err)
+		return api.ErrDomainVerificationFailed
+	}
+
+	for _, record := range records {
+		if strings.TrimSpace(record) == value {
+			return nil
+		}
+	}
+
+	return api.ErrDomainVerificationFailed
+}
+
+// GetJoinableOrganizationDomain returns the verified domain that lets the user join an organization, or nil if
+// there is none. Users have to have verified their email address, and users who left or were removed from the
+// organization can't join it again through the domain.
+func GetJoinableOrganizationDomain(tx *gorm.DB, user *models.User) (*models.OrganizationDomain, error) {
+	if user.EmailVerifiedAt == nil {
+		return nil, nil
+	}
+
+	emailDomain := GetEmailDomain(user.Email)
+	if emailDomain == "" {
+		return nil, nil
+	}
+
+	// Only one organization can verify a domain
+	var domain models.OrganizationDomain
+	err := tx.Where("domain = ? AND verified_at IS NOT NULL", emailDomain).First(&domain).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// Memberships are soft deleted, so this also finds memberships that were removed
+	var count int64
+	err = tx.Unscoped().Model(&models.OrganizationMembership{}).
+		Where("organization_id = ? AND user_id = ?", domain.OrganizationID, user.ID).
+		Count(&count).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if count > 0 {
+		return nil, nil
+	}
+
+	return &domain, nil
+}
+
+// IsVerifiedOrganizationDomain returns whether the organization has verified the domain of the email address
+func IsVerifiedOrganizationDomain(tx *gorm.DB, organizationId uuid.UUID, email string) (bool, error) {
+	emailDomain := GetEmailDomain(email)
+	if emailDomain == "" {
+		return false, nil
+	}
+
+	var count int64
+	err := tx.Model(&models.OrganizationDomain{}).
+		Where("organization_id = ? AND domain = ? AND verified_at IS NOT NULL", organizationId, emailDomain).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
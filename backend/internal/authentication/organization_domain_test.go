@@ -0,0 +1,70 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"reece.start/internal/api"
+)
+
+type stubTxtResolver map[string][]string
+
+func (r stubTxtResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	records, ok := r[name]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return records, nil
+}
+
+func TestNormalizeDomain(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		for input, expected := range map[string]string{
+			"example.com":           "example.com",
+			"  Example.COM.  ":      "example.com",
+			"mail.example.co.uk":    "mail.example.co.uk",
+			"xn--bcher-kva.ch":      "xn--bcher-kva.ch",
+			"my-company.example.io": "my-company.example.io",
+		} {
+			domain, err := NormalizeDomain(input)
+			require.NoError(t, err, input)
+			assert.Equal(t, expected, domain)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, input := range []string{"", "localhost", "user@example.com", "-example.com", "example-.com", "exa mple.com", "example..com", "https://example.com"} {
+			_, err := NormalizeDomain(input)
+			assert.ErrorIs(t, err, api.ErrDomainInvalid, input)
+		}
+	})
+}
+
+func TestGetEmailDomain(t *testing.T) {
+	assert.Equal(t, "example.com", GetEmailDomain("User@Example.com"))
+	assert.Equal(t, "example.com", GetEmailDomain(`"a@b"@example.com`))
+	assert.Equal(t, "", GetEmailDomain("not-an-email"))
+}
+
+func TestVerifyDomainOwnership(t *testing.T) {
+	name, value := GetDomainVerificationRecord("example.com", "token")
+	assert.Equal(t, "_reece-start-verification.example.com", name)
+	assert.Equal(t, "reece-start-verification=token", value)
+
+	t.Run("RecordFound", func(t *testing.T) {
+		resolver := stubTxtResolver{name: {"v=spf1 -all", value}}
+		assert.NoError(t, VerifyDomainOwnership(context.Background(), resolver, "example.com", "token"))
+	})
+
+	t.Run("WrongToken", func(t *testing.T) {
+		resolver := stubTxtResolver{name: {"reece-start-verification=other"}}
+		assert.ErrorIs(t, VerifyDomainOwnership(context.Background(), resolver, "example.com", "token"), api.ErrDomainVerificationFailed)
+	})
+
+	t.Run("LookupFails", func(t *testing.T) {
+		assert.ErrorIs(t, VerifyDomainOwnership(context.Background(), stubTxtResolver{}, "example.com", "token"), api.ErrDomainVerificationFailed)
+	})
+}
@@ -0,0 +1,102 @@
+package authentication
+
+import (
+	"strings"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"reece.start/internal/configuration"
+	"reece.start/internal/models"
+)
+
+// Ceremonies a stored passkey challenge can be used for
+const (
+	PasskeyCeremonyRegistration = "registration"
+	PasskeyCeremonyLogin        = "login"
+)
+
+// NewWebAuthn creates the WebAuthn relying party used for passkey registration and login
+func NewWebAuthn(config *configuration.Config) (*webauthn.WebAuthn, error) {
+	origins := make([]string, 0)
+	for _, origin := range strings.Split(config.WebauthnRpOrigins, ",") {
+		origin = strings.TrimSpace(origin)
+		if origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+
+	return webauthn.New(&webauthn.Config{
+		RPID:          config.WebauthnRpId,
+		RPDisplayName: config.WebauthnRpDisplayName,
+		RPOrigins:     origins,
+	})
+}
+
+// PasskeyUser adapts a user and their registered passkeys to the webauthn.User interface
+type PasskeyUser struct {
+	User     *models.User
+	Passkeys []models.Passkey
+}
+
+// WebAuthnID is the user handle stored on the authenticator, which identifies the user during a passwordless login
+func (u *PasskeyUser) WebAuthnID() []byte {
+	return u.User.ID[:]
+}
+
+func (u *PasskeyUser) WebAuthnName() string {
+	return u.User.Email
+}
+
+func (u *PasskeyUser) WebAuthnDisplayName() string {
+	return u.User.Name
+}
+
+func (u *PasskeyUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.Passkeys))
+	for _, passkey := range u.Passkeys {
+		credentials = append(credentials, PasskeyToCredential(passkey))
+	}
+	return credentials
+}
+
+// PasskeyToCredential converts a stored passkey to the credential record used by the webauthn library
+func PasskeyToCredential(passkey models.Passkey) webauthn.Credential {
+	transports := make([]protocol.AuthenticatorTransport, 0)
+	for _, transport := range strings.Split(passkey.Transports, ",") {
+		if transport != "" {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+	}
+
+	return webauthn.Credential{
+		ID:              passkey.CredentialID,
+		PublicKey:       passkey.PublicKey,
+		AttestationType: passkey.AttestationType,
+		Transport:       transports,
+		Flags:           webauthn.NewCredentialFlags(protocol.AuthenticatorFlags(passkey.Flags)),
+		Authenticator: webauthn.Authenticator{
+			AAGUID:    passkey.AAGUID,
+			SignCount: uint32(passkey.SignCount),
+		},
+	}
+}
+
+// CredentialToPasskey converts a newly registered credential to a passkey that can be stored for the user
+func CredentialToPasskey(credential *webauthn.Credential, user *models.User, name string) models.Passkey {
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	return models.Passkey{
+		UserID:          user.ID,
+		Name:            name,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      strings.Join(transports, ","),
+		AAGUID:          credential.Authenticator.AAGUID,
+		Flags:           uint8(credential.Flags.ProtocolValue()),
+		SignCount:       int64(credential.Authenticator.SignCount),
+	}
+}
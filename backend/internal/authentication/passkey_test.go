@@ -0,0 +1,58 @@
+package authentication
+
+import (
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"reece.start/internal/models"
+	testconfig "reece.start/test/config"
+)
+
+func TestNewWebAuthn(t *testing.T) {
+	config := testconfig.CreateTestConfig()
+	config.WebauthnRpOrigins = "https://app.example.com, https://example.com,"
+
+	webAuthn, err := NewWebAuthn(config)
+	require.NoError(t, err)
+	require.Equal(t, config.WebauthnRpId, webAuthn.Config.RPID)
+	require.Equal(t, []string{"https://app.example.com", "https://example.com"}, webAuthn.Config.RPOrigins)
+}
+
+func TestPasskeyCredentialConversion(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Name: "Test User", Email: "passkey@example.com"}
+
+	flags := protocol.FlagUserPresent | protocol.FlagUserVerified | protocol.FlagBackupEligible
+	credential := &webauthn.Credential{
+		ID:              []byte("credential-id"),
+		PublicKey:       []byte("public-key"),
+		AttestationType: "none",
+		Transport:       []protocol.AuthenticatorTransport{protocol.Internal, protocol.Hybrid},
+		Flags:           webauthn.NewCredentialFlags(flags),
+		Authenticator: webauthn.Authenticator{
+			AAGUID:    []byte("aaguid"),
+			SignCount: 7,
+		},
+	}
+
+	passkey := CredentialToPasskey(credential, user, "Laptop")
+	require.Equal(t, user.ID, passkey.UserID)
+	require.Equal(t, "Laptop", passkey.Name)
+	require.Equal(t, "internal,hybrid", passkey.Transports)
+	require.Equal(t, int64(7), passkey.SignCount)
+
+	converted := PasskeyToCredential(passkey)
+	require.Equal(t, credential.ID, converted.ID)
+	require.Equal(t, credential.PublicKey, converted.PublicKey)
+	require.Equal(t, credential.Transport, converted.Transport)
+	require.Equal(t, credential.Authenticator.SignCount, converted.Authenticator.SignCount)
+	require.Equal(t, flags, converted.Flags.ProtocolValue())
+	require.True(t, converted.Flags.BackupEligible)
+
+	passkeyUser := &PasskeyUser{User: user, Passkeys: []models.Passkey{passkey}}
+	require.Equal(t, user.ID[:], passkeyUser.WebAuthnID())
+	require.Equal(t, user.Email, passkeyUser.WebAuthnName())
+	require.Len(t, passkeyUser.WebAuthnCredentials(), 1)
+}
@@ -1,20 +1,132 @@
 package authentication
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 	"reece.start/internal/configuration"
 )
 
-func HashPassword(password string, config *configuration.Config) ([]byte, error) {
-	cost := 14 // Production cost
+// Passwords are stored in the PHC string format, e.g. $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>, so the
+// parameters a hash was made with are kept alongside it and can be upgraded later.
+// Hashes made before argon2id was introduced are bcrypt hashes, which start with $2a$ or $2b$.
+const argon2idPrefix = "$argon2id$"
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+func getArgon2Params(config *configuration.Config) argon2Params {
 	if config.Test {
-		cost = 4 // Fast cost for tests (about 1000x faster)
+		// Fast parameters for tests
+		return argon2Params{memory: 64, iterations: 1, parallelism: 1}
+	}
+
+	return argon2Params{
+		memory:      uint32(config.PasswordHashMemory),
+		iterations:  uint32(config.PasswordHashIterations),
+		parallelism: uint8(config.PasswordHashParallelism),
+	}
+}
+
+// HashPassword hashes a password with argon2id using the configured parameters
+func HashPassword(password string, config *configuration.Config) ([]byte, error) {
+	params := getArgon2Params(config)
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
 	}
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), cost)
-	return bytes, err
+
+	key := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, argon2KeyLength)
+
+	encoded := fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		params.memory,
+		params.iterations,
+		params.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+	return []byte(encoded), nil
 }
 
+// CheckPasswordHash checks a password against a stored hash, which can be an argon2id or a legacy bcrypt hash
 func CheckPasswordHash(password string, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+	if !strings.HasPrefix(hash, argon2idPrefix) {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		return err == nil
+	}
+
+	params, salt, key, err := decodeArgon2idHash(hash)
+	if err != nil {
+		return false
+	}
+
+	otherKey := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, otherKey) == 1
+}
+
+// PasswordNeedsRehash checks if a stored hash was made with bcrypt or with different argon2id parameters than the
+// configured ones, and should be replaced the next time the password is known
+func PasswordNeedsRehash(hash string, config *configuration.Config) bool {
+	if !strings.HasPrefix(hash, argon2idPrefix) {
+		return true
+	}
+
+	params, _, key, err := decodeArgon2idHash(hash)
+	if err != nil {
+		return true
+	}
+
+	return params != getArgon2Params(config) || len(key) != argon2KeyLength
+}
+
+func decodeArgon2idHash(hash string) (argon2Params, []byte, []byte, error) {
+	// The leading $ gives an empty first part: "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return argon2Params{}, nil, nil, fmt.Errorf("argon2id hash has %d parts", len(parts))
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return argon2Params{}, nil, nil, err
+	}
+	if version != argon2.Version {
+		return argon2Params{}, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	var params argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return argon2Params{}, nil, nil, err
+	}
+	if params.iterations < 1 || params.parallelism < 1 {
+		return argon2Params{}, nil, nil, fmt.Errorf("invalid argon2 parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return argon2Params{}, nil, nil, err
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return argon2Params{}, nil, nil, err
+	}
+
+	return params, salt, key, nil
 }
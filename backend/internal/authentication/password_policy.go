@@ -0,0 +1,120 @@
+package authentication
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"reece.start/internal/api"
+	"reece.start/internal/configuration"
+)
+
+// Rules reported in password policy violations, so clients can show a message for each one
+const (
+	PasswordRuleMinLength = "min_length"
+	PasswordRuleMaxLength = "max_length"
+	PasswordRuleCommon    = "common"
+	PasswordRuleUserInfo  = "user_info"
+	PasswordRuleBreached  = "breached"
+)
+
+// Parts of the user's name or email shorter than this are too likely to show up in passwords by chance
+const passwordUserInfoMinLength = 3
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+var commonPasswords = func() map[string]bool {
+	passwords := make(map[string]bool)
+	for _, line := range strings.Split(commonPasswordsFile, "\n") {
+		if password := strings.TrimSpace(line); password != "" {
+			passwords[password] = true
+		}
+	}
+	return passwords
+}()
+
+type PasswordPolicyParams struct {
+	Password string
+	Name     string // Name of the user the password is for, if known
+	Email    string // Email of the user the password is for, if known
+}
+
+// CheckPasswordPolicy checks a password against the configured password policy.
+// Returns an api.ValidationError listing every rule the password breaks, or nil if it is allowed.
+func CheckPasswordPolicy(config *configuration.Config, params PasswordPolicyParams) error {
+	violations := make([]api.FieldError, 0)
+	violate := func(rule string, message string) {
+		violations = append(violations, api.FieldError{Field: "password", Rule: rule, Message: message})
+	}
+
+	length := utf8.RuneCountInString(params.Password)
+	if length < config.PasswordMinLength {
+		violate(PasswordRuleMinLength, fmt.Sprintf("must be at least %d characters", config.PasswordMinLength))
+	}
+	if config.PasswordMaxLength > 0 && length > config.PasswordMaxLength {
+		violate(PasswordRuleMaxLength, fmt.Sprintf("must be at most %d characters", config.PasswordMaxLength))
+	}
+
+	if config.PasswordRejectCommon && isCommonPassword(params.Password) {
+		violate(PasswordRuleCommon, "is too common")
+	}
+
+	if config.PasswordRejectUserInfo && containsUserInfo(params.Password, params.Name, params.Email) {
+		violate(PasswordRuleUserInfo, "can't contain your name or email")
+	}
+
+	breached, err := IsBreachedPassword(config, params.Password)
+	if err != nil {
+		return err
+	}
+	if breached {
+		violate(PasswordRuleBreached, "has appeared in a data breach")
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return &api.ValidationError{
+		Err:    api.ErrPasswordPolicyViolation,
+		Code:   api.ErrorCodePasswordPolicyViolation,
+		Errors: violations,
+	}
+}
+
+// isCommonPassword checks the password against the built in list, ignoring case and the digits and symbols people
+// tend to tack on the end, e.g. Password123!
+func isCommonPassword(password string) bool {
+	normalized := strings.ToLower(password)
+	if commonPasswords[normalized] {
+		return true
+	}
+
+	stripped := strings.TrimRightFunc(normalized, func(r rune) bool {
+		return unicode.IsDigit(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+	})
+	return stripped != "" && commonPasswords[stripped]
+}
+
+// containsUserInfo checks if the password contains the user's email, the part of it before the @, or any part of
+// their name, ignoring case
+func containsUserInfo(password string, name string, email string) bool {
+	normalized := strings.ToLower(password)
+
+	parts := strings.Fields(strings.ToLower(name))
+	if email != "" {
+		email = strings.ToLower(email)
+		localPart, _, _ := strings.Cut(email, "@")
+		parts = append(parts, email, localPart)
+	}
+
+	for _, part := range parts {
+		if utf8.RuneCountInString(part) >= passwordUserInfoMinLength && strings.Contains(normalized, part) {
+			return true
+		}
+	}
+	return false
+}
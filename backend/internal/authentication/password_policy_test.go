@@ -0,0 +1,106 @@
+package authentication
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"reece.start/internal/api"
+	testconfig "reece.start/test/config"
+)
+
+// getPasswordPolicyRules returns the rules broken by a password
+func getPasswordPolicyRules(t *testing.T, err error) []string {
+	var validationErr *api.ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	require.ErrorIs(t, err, api.ErrPasswordPolicyViolation)
+	require.Equal(t, api.ErrorCodePasswordPolicyViolation, validationErr.Code)
+
+	rules := make([]string, 0, len(validationErr.Errors))
+	for _, fieldErr := range validationErr.Errors {
+		require.Equal(t, "password", fieldErr.Field)
+		require.NotEmpty(t, fieldErr.Message)
+		rules = append(rules, fieldErr.Rule)
+	}
+	return rules
+}
+
+func TestCheckPasswordPolicy(t *testing.T) {
+	config := testconfig.CreateTestConfig()
+	config.PasswordRejectCommon = true
+	config.PasswordRejectUserInfo = true
+
+	t.Run("AllowsGoodPassword", func(t *testing.T) {
+		err := CheckPasswordPolicy(config, PasswordPolicyParams{
+			Password: "correct horse battery staple",
+			Name:     "Jane Doe",
+			Email:    "jane@example.com",
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("TooShort", func(t *testing.T) {
+		err := CheckPasswordPolicy(config, PasswordPolicyParams{Password: "x7#kq"})
+		require.Equal(t, []string{PasswordRuleMinLength}, getPasswordPolicyRules(t, err))
+	})
+
+	t.Run("TooLong", func(t *testing.T) {
+		err := CheckPasswordPolicy(config, PasswordPolicyParams{Password: strings.Repeat("x7#kq", 30)})
+		require.Equal(t, []string{PasswordRuleMaxLength}, getPasswordPolicyRules(t, err))
+	})
+
+	t.Run("Common", func(t *testing.T) {
+		for _, password := range []string{"password", "Password123!", "QWERTY2024", "12345678"} {
+			err := CheckPasswordPolicy(config, PasswordPolicyParams{Password: password})
+			require.Equal(t, []string{PasswordRuleCommon}, getPasswordPolicyRules(t, err), password)
+		}
+	})
+
+	t.Run("ContainsName", func(t *testing.T) {
+		err := CheckPasswordPolicy(config, PasswordPolicyParams{
+			Password: "ilovejane-forever",
+			Name:     "Jane Doe",
+			Email:    "someone@example.com",
+		})
+		require.Equal(t, []string{PasswordRuleUserInfo}, getPasswordPolicyRules(t, err))
+	})
+
+	t.Run("ContainsEmail", func(t *testing.T) {
+		err := CheckPasswordPolicy(config, PasswordPolicyParams{
+			Password: "JSMITH-was-here",
+			Name:     "Someone",
+			Email:    "jsmith@example.com",
+		})
+		require.Equal(t, []string{PasswordRuleUserInfo}, getPasswordPolicyRules(t, err))
+	})
+
+	t.Run("ShortNamePartsAreIgnored", func(t *testing.T) {
+		err := CheckPasswordPolicy(config, PasswordPolicyParams{
+			Password: "correct horse battery staple",
+			Name:     "Al Ba",
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("ReportsEveryRule", func(t *testing.T) {
+		err := CheckPasswordPolicy(config, PasswordPolicyParams{
+			Password: "jane",
+			Name:     "Jane Doe",
+		})
+		require.ElementsMatch(t, []string{PasswordRuleMinLength, PasswordRuleUserInfo}, getPasswordPolicyRules(t, err))
+	})
+
+	t.Run("RulesCanBeDisabled", func(t *testing.T) {
+		lenientConfig := *config
+		lenientConfig.PasswordRejectCommon = false
+		lenientConfig.PasswordRejectUserInfo = false
+		lenientConfig.PasswordMaxLength = 0
+
+		err := CheckPasswordPolicy(&lenientConfig, PasswordPolicyParams{
+			Password: "password" + strings.Repeat("jane", 50),
+			Name:     "Jane Doe",
+		})
+		require.NoError(t, err)
+	})
+}
@@ -1,9 +1,11 @@
 package authentication
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 	testconfig "reece.start/test/config"
 )
 
@@ -27,3 +29,72 @@ func TestCheckPasswordHash(t *testing.T) {
 	require.NotNil(t, hashedPassword)
 	require.True(t, CheckPasswordHash(password, string(hashedPassword)))
 }
+
+func TestCheckPasswordHashWrongPassword(t *testing.T) {
+	config := testconfig.CreateTestConfig()
+
+	hashedPassword, err := HashPassword("testPassword123!", config)
+
+	require.NoError(t, err)
+	require.False(t, CheckPasswordHash("wrongPassword123!", string(hashedPassword)))
+}
+
+func TestCheckPasswordHashLongPassword(t *testing.T) {
+	// bcrypt ignored everything after 72 bytes, argon2id uses the whole password
+	config := testconfig.CreateTestConfig()
+	password := strings.Repeat("a", 80)
+
+	hashedPassword, err := HashPassword(password, config)
+
+	require.NoError(t, err)
+	require.True(t, CheckPasswordHash(password, string(hashedPassword)))
+	require.False(t, CheckPasswordHash(strings.Repeat("a", 72)+"bbbbbbbb", string(hashedPassword)))
+}
+
+func TestCheckPasswordHashLegacyBcrypt(t *testing.T) {
+	password := "testPassword123!"
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+
+	require.NoError(t, err)
+	require.True(t, CheckPasswordHash(password, string(hashedPassword)))
+	require.False(t, CheckPasswordHash("wrongPassword123!", string(hashedPassword)))
+}
+
+func TestCheckPasswordHashInvalidHash(t *testing.T) {
+	require.False(t, CheckPasswordHash("testPassword123!", ""))
+	require.False(t, CheckPasswordHash("testPassword123!", "$argon2id$v=19$m=64,t=0,p=1$c2FsdA$a2V5"))
+	require.False(t, CheckPasswordHash("testPassword123!", "$argon2id$v=19$garbage"))
+}
+
+func TestPasswordNeedsRehash(t *testing.T) {
+	config := testconfig.CreateTestConfig()
+
+	t.Run("CurrentParameters", func(t *testing.T) {
+		hashedPassword, err := HashPassword("testPassword123!", config)
+		require.NoError(t, err)
+		require.False(t, PasswordNeedsRehash(string(hashedPassword), config))
+	})
+
+	t.Run("LegacyBcrypt", func(t *testing.T) {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte("testPassword123!"), bcrypt.MinCost)
+		require.NoError(t, err)
+		require.True(t, PasswordNeedsRehash(string(hashedPassword), config))
+	})
+
+	t.Run("OutdatedParameters", func(t *testing.T) {
+		productionConfig := *config
+		productionConfig.Test = false
+		productionConfig.PasswordHashMemory = 128
+		productionConfig.PasswordHashIterations = 1
+		productionConfig.PasswordHashParallelism = 1
+
+		hashedPassword, err := HashPassword("testPassword123!", &productionConfig)
+		require.NoError(t, err)
+		require.True(t, CheckPasswordHash("testPassword123!", string(hashedPassword)))
+		require.True(t, PasswordNeedsRehash(string(hashedPassword), config))
+
+		productionConfig.PasswordHashIterations = 2
+		require.True(t, PasswordNeedsRehash(string(hashedPassword), &productionConfig))
+	})
+}
@@ -0,0 +1,46 @@
+package authentication
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"reece.start/internal/constants"
+)
+
+func TestGeneratePersonalAccessToken(t *testing.T) {
+	t.Run("ReturnsPrefixedTokenAndMatchingHash", func(t *testing.T) {
+		token, hash, prefix, err := GeneratePersonalAccessToken()
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(token, PersonalAccessTokenPrefix))
+		require.True(t, strings.HasPrefix(token, prefix))
+		require.Greater(t, len(prefix), len(PersonalAccessTokenPrefix))
+		require.Equal(t, HashOpaqueToken(token), hash)
+	})
+
+	t.Run("GeneratesUniqueTokens", func(t *testing.T) {
+		first, _, _, err := GeneratePersonalAccessToken()
+		require.NoError(t, err)
+		second, _, _, err := GeneratePersonalAccessToken()
+		require.NoError(t, err)
+		require.NotEqual(t, first, second)
+	})
+}
+
+func TestIsPersonalAccessToken(t *testing.T) {
+	token, _, _, err := GeneratePersonalAccessToken()
+	require.NoError(t, err)
+	require.True(t, IsPersonalAccessToken(token))
+	require.False(t, IsPersonalAccessToken("eyJhbGciOiJIUzI1NiJ9.e30.signature"))
+}
+
+func TestScopesRoundTrip(t *testing.T) {
+	t.Run("Scopes", func(t *testing.T) {
+		scopes := []constants.UserScope{constants.UserScopeOrganizationRead, constants.UserScopeOrganizationUpdate}
+		require.Equal(t, scopes, ParseScopes(FormatScopes(scopes)))
+	})
+
+	t.Run("NoScopes", func(t *testing.T) {
+		require.Empty(t, ParseScopes(FormatScopes(nil)))
+	})
+}
@@ -0,0 +1,47 @@
+package authentication
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"reece.start/internal/configuration"
+)
+
+// Tokens issued for a single purpose, like an MFA challenge or an email verification link, are signed with the same
+// keys as session tokens but for their own audience, so they can't be used as a session token or for another purpose.
+
+func getPurposeRegisteredClaims(config *configuration.Config, audienceSuffix string, userId uuid.UUID, expiry time.Duration) jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		Issuer:    config.JwtIssuer,
+		Subject:   userId.String(),
+		Audience:  jwt.ClaimStrings{config.JwtAudience + audienceSuffix},
+	}
+}
+
+func parsePurposeJWT(config *configuration.Config, tokenString string, audienceSuffix string, claims jwt.Claims) error {
+	keyRing, err := GetKeyRing(config)
+	if err != nil {
+		return err
+	}
+
+	_, err = jwt.ParseWithClaims(
+		tokenString,
+		claims,
+		keyRing.Keyfunc,
+		jwt.WithValidMethods(keyRing.ValidMethods()),
+		jwt.WithIssuer(config.JwtIssuer),
+		jwt.WithAudience(config.JwtAudience+audienceSuffix),
+		jwt.WithLeeway(time.Duration(config.JwtLeeway)*time.Second),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return mapJwtError(err)
+	}
+
+	return nil
+}
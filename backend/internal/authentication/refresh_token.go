@@ -0,0 +1,12 @@
+package authentication
+
+// GenerateRefreshToken generates a new opaque refresh token.
+// Returns the token to hand to the client and the hash that should be stored in the database.
+func GenerateRefreshToken() (string, string, error) {
+	return GenerateOpaqueToken()
+}
+
+// HashRefreshToken hashes a refresh token for storage and lookup
+func HashRefreshToken(token string) string {
+	return HashOpaqueToken(token)
+}
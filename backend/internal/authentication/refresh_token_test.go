@@ -0,0 +1,25 @@
+package authentication
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateRefreshToken(t *testing.T) {
+	t.Run("ReturnsTokenAndMatchingHash", func(t *testing.T) {
+		token, hash, err := GenerateRefreshToken()
+		require.NoError(t, err)
+		require.NotEmpty(t, token)
+		require.Equal(t, HashRefreshToken(token), hash)
+		require.NotEqual(t, token, hash)
+	})
+
+	t.Run("GeneratesUniqueTokens", func(t *testing.T) {
+		first, _, err := GenerateRefreshToken()
+		require.NoError(t, err)
+		second, _, err := GenerateRefreshToken()
+		require.NoError(t, err)
+		require.NotEqual(t, first, second)
+	})
+}
@@ -0,0 +1,202 @@
+package authentication
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"reece.start/internal/api"
+	"reece.start/internal/models"
+	"reece.start/internal/utils"
+)
+
+// Process-local cache of user token revocation state so the auth middleware doesn't hit Postgres on every request.
+// Entries expire after the configured TTL, which bounds how long a revocation written by another instance can be missed.
+var userRevocationCache = newTokenRevocationCache()
+
+type revocationCacheEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// revocationCache caches revocation state by the ID of the user or session it belongs to
+type revocationCache[V any] struct {
+	mu      sync.RWMutex
+	entries map[uuid.UUID]revocationCacheEntry[V]
+}
+
+func newRevocationCache[V any]() *revocationCache[V] {
+	return &revocationCache[V]{
+		entries: make(map[uuid.UUID]revocationCacheEntry[V]),
+	}
+}
+
+func newTokenRevocationCache() *revocationCache[models.UserTokenRevocation] {
+	return newRevocationCache[models.UserTokenRevocation]()
+}
+
+func (c *revocationCache[V]) get(id uuid.UUID, ttl time.Duration, load func() (V, error)) (V, error) {
+	now := time.Now()
+
+	c.mu.RLock()
+	entry, ok := c.entries[id]
+	c.mu.RUnlock()
+
+	if ok && now.Before(entry.expiresAt) {
+		return entry.value, nil
+	}
+
+	value, err := load()
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+
+	// A zero TTL disables caching entirely
+	if ttl > 0 {
+		c.mu.Lock()
+		c.entries[id] = revocationCacheEntry[V]{
+			value:     value,
+			expiresAt: now.Add(ttl),
+		}
+		c.mu.Unlock()
+	}
+
+	return value, nil
+}
+
+func (c *revocationCache[V]) invalidate(id uuid.UUID) {
+	c.mu.Lock()
+	delete(c.entries, id)
+	c.mu.Unlock()
+}
+
+// invalidateTokenRevocation clears the user's cached revocation state once tx is over. Clearing it any earlier would
+// let a concurrent request cache the state from before the revocation was committed.
+func invalidateTokenRevocation(tx *gorm.DB, userID uuid.UUID) {
+	utils.AfterTransaction(tx, func() {
+		userRevocationCache.invalidate(userID)
+	})
+}
+
+// GetTokenRevocation returns the token revocation state for a user, using the process-local cache when possible
+func GetTokenRevocation(db *gorm.DB, userID uuid.UUID, ttl time.Duration) (models.UserTokenRevocation, error) {
+	return userRevocationCache.get(userID, ttl, func() (models.UserTokenRevocation, error) {
+		var user models.User
+		err := db.Model(&models.User{}).Select("id", "revocation_last_valid_issued_at", "revocation_can_refresh").First(&user, userID).Error
+		if err != nil {
+			return models.UserTokenRevocation{}, err
+		}
+		return user.Revocation, nil
+	})
+}
+
+// RevokeOtherUserSessions invalidates every token issued to the user before now, and revokes all of their sessions,
+// refresh tokens and personal access tokens except for the given session. The client of that session has to be issued
+// new tokens to keep using it.
+func RevokeOtherUserSessions(tx *gorm.DB, userID uuid.UUID, currentSessionID uuid.UUID) error {
+	now := time.Now()
+	err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]any{
+		"revocation_can_refresh":          false,
+		"revocation_last_valid_issued_at": now,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	err = tx.Model(&models.RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", userID).Update("revoked_at", now).Error
+	if err != nil {
+		return err
+	}
+
+	var sessionIDs []uuid.UUID
+	err = tx.Model(&models.UserSession{}).Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, currentSessionID).Pluck("id", &sessionIDs).Error
+	if err != nil {
+		return err
+	}
+
+	err = RevokeSessions(tx, sessionIDs)
+	if err != nil {
+		return err
+	}
+
+	err = revokePersonalAccessTokens(tx, userID)
+	if err != nil {
+		return err
+	}
+
+	invalidateTokenRevocation(tx, userID)
+	return nil
+}
+
+// RevokeUserTokens invalidates every token issued to the user before now.
+// If canRefresh is true the client may exchange its old token for a new one, otherwise the user has to re-authenticate
+// and all of the user's sessions, refresh tokens and personal access tokens are revoked as well.
+func RevokeUserTokens(tx *gorm.DB, userID uuid.UUID, canRefresh bool) error {
+	now := time.Now()
+	err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]any{
+		"revocation_can_refresh":          canRefresh,
+		"revocation_last_valid_issued_at": now,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	if !canRefresh {
+		err = tx.Model(&models.RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", userID).Update("revoked_at", now).Error
+		if err != nil {
+			return err
+		}
+
+		var sessionIDs []uuid.UUID
+		err = tx.Model(&models.UserSession{}).Where("user_id = ? AND revoked_at IS NULL", userID).Pluck("id", &sessionIDs).Error
+		if err != nil {
+			return err
+		}
+
+		err = RevokeSessions(tx, sessionIDs)
+		if err != nil {
+			return err
+		}
+
+		err = revokePersonalAccessTokens(tx, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	invalidateTokenRevocation(tx, userID)
+	return nil
+}
+
+// revokePersonalAccessTokens deletes the user's personal access tokens. They are long lived and can't be refreshed, so
+// they are only kept when the user's tokens are revoked to pick up changed permissions, which they check on every use.
+func revokePersonalAccessTokens(tx *gorm.DB, userID uuid.UUID) error {
+	return tx.Unscoped().Where("user_id = ?", userID).Delete(&models.PersonalAccessToken{}).Error
+}
+
+// CheckTokenRevocation checks the token's issued at time against the user's revocation state.
+// Returns api.ErrTokenRefreshRequired if the token was revoked but can be refreshed,
+// and api.ErrReauthenticationRequired if the user needs to sign in again.
+func CheckTokenRevocation(claims *JwtClaims, revocation models.UserTokenRevocation) error {
+	if revocation.LastValidIssuedAt == nil {
+		return nil
+	}
+
+	if claims.IssuedAt == nil {
+		return api.ErrReauthenticationRequired
+	}
+
+	// iat only has second precision, so compare against the revocation time truncated to the second.
+	// Otherwise a token minted in the same second as the revocation would be rejected.
+	if !claims.IssuedAt.Time.Before(revocation.LastValidIssuedAt.Truncate(time.Second)) {
+		return nil
+	}
+
+	if revocation.CanRefresh {
+		return api.ErrTokenRefreshRequired
+	}
+
+	return api.ErrReauthenticationRequired
+}
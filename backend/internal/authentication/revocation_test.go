@@ -0,0 +1,175 @@
+package authentication
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"reece.start/internal/api"
+	"reece.start/internal/models"
+	"reece.start/internal/utils"
+	testdb "reece.start/test/db"
+)
+
+func TestCheckTokenRevocation(t *testing.T) {
+	issuedAt := time.Now().Truncate(time.Second)
+	claims := &JwtClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt: jwt.NewNumericDate(issuedAt),
+		},
+	}
+
+	t.Run("NeverRevoked", func(t *testing.T) {
+		err := CheckTokenRevocation(claims, models.UserTokenRevocation{CanRefresh: true})
+		require.NoError(t, err)
+	})
+
+	t.Run("IssuedAfterRevocation", func(t *testing.T) {
+		revokedAt := issuedAt.Add(-time.Minute)
+		err := CheckTokenRevocation(claims, models.UserTokenRevocation{LastValidIssuedAt: &revokedAt, CanRefresh: true})
+		require.NoError(t, err)
+	})
+
+	t.Run("IssuedInSameSecondAsRevocation", func(t *testing.T) {
+		revokedAt := issuedAt.Add(500 * time.Millisecond)
+		err := CheckTokenRevocation(claims, models.UserTokenRevocation{LastValidIssuedAt: &revokedAt, CanRefresh: false})
+		require.NoError(t, err)
+	})
+
+	t.Run("RevokedCanRefresh", func(t *testing.T) {
+		revokedAt := issuedAt.Add(time.Minute)
+		err := CheckTokenRevocation(claims, models.UserTokenRevocation{LastValidIssuedAt: &revokedAt, CanRefresh: true})
+		require.ErrorIs(t, err, api.ErrTokenRefreshRequired)
+	})
+
+	t.Run("RevokedCannotRefresh", func(t *testing.T) {
+		revokedAt := issuedAt.Add(time.Minute)
+		err := CheckTokenRevocation(claims, models.UserTokenRevocation{LastValidIssuedAt: &revokedAt, CanRefresh: false})
+		require.ErrorIs(t, err, api.ErrReauthenticationRequired)
+	})
+
+	t.Run("MissingIssuedAt", func(t *testing.T) {
+		revokedAt := issuedAt.Add(-time.Minute)
+		err := CheckTokenRevocation(&JwtClaims{}, models.UserTokenRevocation{LastValidIssuedAt: &revokedAt, CanRefresh: true})
+		require.ErrorIs(t, err, api.ErrReauthenticationRequired)
+	})
+}
+
+func TestTokenRevocationCache(t *testing.T) {
+	t.Run("CachesWithinTtl", func(t *testing.T) {
+		cache := newTokenRevocationCache()
+		userID := uuid.New()
+		loads := 0
+		load := func() (models.UserTokenRevocation, error) {
+			loads++
+			return models.UserTokenRevocation{CanRefresh: true}, nil
+		}
+
+		_, err := cache.get(userID, time.Minute, load)
+		require.NoError(t, err)
+		_, err = cache.get(userID, time.Minute, load)
+		require.NoError(t, err)
+		require.Equal(t, 1, loads)
+	})
+
+	t.Run("ZeroTtlDisablesCaching", func(t *testing.T) {
+		cache := newTokenRevocationCache()
+		userID := uuid.New()
+		loads := 0
+		load := func() (models.UserTokenRevocation, error) {
+			loads++
+			return models.UserTokenRevocation{}, nil
+		}
+
+		_, err := cache.get(userID, 0, load)
+		require.NoError(t, err)
+		_, err = cache.get(userID, 0, load)
+		require.NoError(t, err)
+		require.Equal(t, 2, loads)
+	})
+
+	t.Run("Invalidate", func(t *testing.T) {
+		cache := newTokenRevocationCache()
+		userID := uuid.New()
+		loads := 0
+		load := func() (models.UserTokenRevocation, error) {
+			loads++
+			return models.UserTokenRevocation{}, nil
+		}
+
+		_, err := cache.get(userID, time.Minute, load)
+		require.NoError(t, err)
+		cache.invalidate(userID)
+		_, err = cache.get(userID, time.Minute, load)
+		require.NoError(t, err)
+		require.Equal(t, 2, loads)
+	})
+
+	t.Run("LoadErrorIsNotCached", func(t *testing.T) {
+		cache := newTokenRevocationCache()
+		userID := uuid.New()
+		loadErr := errors.New("load failed")
+
+		_, err := cache.get(userID, time.Minute, func() (models.UserTokenRevocation, error) {
+			return models.UserTokenRevocation{}, loadErr
+		})
+		require.ErrorIs(t, err, loadErr)
+
+		revocation, err := cache.get(userID, time.Minute, func() (models.UserTokenRevocation, error) {
+			return models.UserTokenRevocation{CanRefresh: true}, nil
+		})
+		require.NoError(t, err)
+		require.True(t, revocation.CanRefresh)
+	})
+}
+
+func TestRevokeUserTokensCache(t *testing.T) {
+	db := testdb.SetupDB(t)
+
+	t.Run("InvalidatedOnceTransactionIsOver", func(t *testing.T) {
+		user := models.User{Name: "Revoked User", Email: "revoked-cache@example.com"}
+		require.NoError(t, db.Create(&user).Error)
+
+		// Cache the state from before the revocation
+		revocation, err := GetTokenRevocation(db, user.ID, time.Minute)
+		require.NoError(t, err)
+		require.Nil(t, revocation.LastValidIssuedAt)
+
+		ctx, runAfterTransaction := utils.WithAfterTransaction(t.Context())
+		err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return RevokeUserTokens(tx, user.ID, false)
+		})
+		require.NoError(t, err)
+
+		// Still cached until the deferred work runs, so nothing could have cached the uncommitted state in between
+		revocation, err = GetTokenRevocation(db, user.ID, time.Minute)
+		require.NoError(t, err)
+		require.Nil(t, revocation.LastValidIssuedAt)
+
+		runAfterTransaction()
+
+		revocation, err = GetTokenRevocation(db, user.ID, time.Minute)
+		require.NoError(t, err)
+		require.NotNil(t, revocation.LastValidIssuedAt)
+		require.False(t, revocation.CanRefresh)
+	})
+
+	t.Run("InvalidatedRightAwayOutsideRequest", func(t *testing.T) {
+		user := models.User{Name: "Revoked User", Email: "revoked-cache-direct@example.com"}
+		require.NoError(t, db.Create(&user).Error)
+
+		_, err := GetTokenRevocation(db, user.ID, time.Minute)
+		require.NoError(t, err)
+
+		require.NoError(t, RevokeUserTokens(db, user.ID, true))
+
+		revocation, err := GetTokenRevocation(db, user.ID, time.Minute)
+		require.NoError(t, err)
+		require.NotNil(t, revocation.LastValidIssuedAt)
+		require.True(t, revocation.CanRefresh)
+	})
+}
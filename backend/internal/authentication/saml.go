@@ -0,0 +1,324 @@
+package authentication
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+
+	"github.com/crewjam/saml"
+	"github.com/google/uuid"
+	"reece.start/internal/api"
+	"reece.start/internal/configuration"
+	"reece.start/internal/models"
+)
+
+// Every organization is a separate SAML service provider, identified by its metadata URL, so the same identity
+// provider can be connected to several organizations.
+
+// SamlIdentityProvider is the provider SAML identities are stored under. Subjects are prefixed with the organization,
+// since NameIDs are only unique within an identity provider.
+const SamlIdentityProvider = "saml"
+
+// SamlIdpSettings are the parts of an identity provider's metadata needed to sign users in with it
+type SamlIdpSettings struct {
+	EntityID    string
+	SsoUrl      string
+	Certificate string // PEM encoded signing certificates
+}
+
+// SamlServiceProviderUrls are the URLs an organization's identity provider needs to know about
+type SamlServiceProviderUrls struct {
+	EntityID string // The metadata URL doubles as the entity ID
+	AcsUrl   string
+}
+
+// SamlAuthnRequest is an authentication request to send the user to the identity provider with
+type SamlAuthnRequest struct {
+	Url       string
+	RequestID string
+}
+
+// SamlProfile is the user an identity provider vouched for
+type SamlProfile struct {
+	Subject string
+	Email   string
+	Name    string
+}
+
+// ParseSamlIdpMetadata reads the entity ID, HTTP-Redirect single sign-on URL and signing certificates from an identity
+// provider's metadata. Metadata with several entities uses the first identity provider.
+func ParseSamlIdpMetadata(metadata string) (*SamlIdpSettings, error) {
+	var descriptor saml.EntityDescriptor
+	err := xml.Unmarshal([]byte(metadata), &descriptor)
+	if err != nil {
+		var entities saml.EntitiesDescriptor
+		if xml.Unmarshal([]byte(metadata), &entities) != nil {
+			return nil, api.ErrSamlMetadataInvalid
+		}
+
+		found := false
+		for _, entity := range entities.EntityDescriptors {
+			if len(entity.IDPSSODescriptors) > 0 {
+				descriptor = entity
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, api.ErrSamlMetadataInvalid
+		}
+	}
+
+	settings := SamlIdpSettings{EntityID: descriptor.EntityID}
+
+	var certificates bytes.Buffer
+	for _, idpDescriptor := range descriptor.IDPSSODescriptors {
+		for _, service := range idpDescriptor.SingleSignOnServices {
+			if service.Binding == saml.HTTPRedirectBinding && settings.SsoUrl == "" {
+				settings.SsoUrl = service.Location
+			}
+		}
+
+		for _, keyDescriptor := range idpDescriptor.KeyDescriptors {
+			if keyDescriptor.Use != "" && keyDescriptor.Use != "signing" {
+				continue
+			}
+
+			for _, certificate := range keyDescriptor.KeyInfo.X509Data.X509Certificates {
+				der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(certificate.Data), ""))
+				if err != nil {
+					return nil, api.ErrSamlCertificateInvalid
+				}
+				_ = pem.Encode(&certificates, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+			}
+		}
+	}
+
+	if settings.EntityID == "" || settings.SsoUrl == "" || certificates.Len() == 0 {
+		return nil, api.ErrSamlMetadataInvalid
+	}
+
+	settings.Certificate = certificates.String()
+	if _, err := ParseSamlCertificates(settings.Certificate); err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+// ParseSamlCertificates parses one or more PEM encoded certificates. Identity providers can list a new certificate
+// alongside the current one while they rotate keys.
+func ParseSamlCertificates(certificatePem string) ([]*x509.Certificate, error) {
+	certificates := make([]*x509.Certificate, 0)
+
+	rest := []byte(certificatePem)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, api.ErrSamlCertificateInvalid
+		}
+
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, api.ErrSamlCertificateInvalid
+		}
+		certificates = append(certificates, certificate)
+	}
+
+	if len(certificates) == 0 || len(bytes.TrimSpace(rest)) > 0 {
+		return nil, api.ErrSamlCertificateInvalid
+	}
+
+	return certificates, nil
+}
+
+// GetSamlServiceProviderUrls returns the service provider URLs of an organization
+func GetSamlServiceProviderUrls(config *configuration.Config, organizationID uuid.UUID) SamlServiceProviderUrls {
+	baseUrl := strings.TrimRight(config.ApiUrl, "/") + "/saml/" + organizationID.String()
+	return SamlServiceProviderUrls{
+		EntityID: baseUrl + "/metadata",
+		AcsUrl:   baseUrl + "/acs",
+	}
+}
+
+// NewSamlServiceProvider creates the service provider of an organization. The connection is optional, without it the
+// service provider can only describe itself.
+func NewSamlServiceProvider(config *configuration.Config, organizationID uuid.UUID, connection *models.OrganizationSamlConnection) (*saml.ServiceProvider, error) {
+	urls := GetSamlServiceProviderUrls(config, organizationID)
+
+	metadataUrl, err := url.Parse(urls.EntityID)
+	if err != nil {
+		return nil, err
+	}
+	acsUrl, err := url.Parse(urls.AcsUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	sp := &saml.ServiceProvider{
+		EntityID:    urls.EntityID,
+		MetadataURL: *metadataUrl,
+		AcsURL:      *acsUrl,
+		// Persistent NameIDs stay the same across logins, so they can identify the user
+		AuthnNameIDFormat: saml.PersistentNameIDFormat,
+	}
+
+	if connection == nil {
+		return sp, nil
+	}
+
+	certificates, err := ParseSamlCertificates(connection.IdpCertificate)
+	if err != nil {
+		return nil, err
+	}
+
+	keyDescriptors := make([]saml.KeyDescriptor, 0, len(certificates))
+	for _, certificate := range certificates {
+		keyDescriptors = append(keyDescriptors, saml.KeyDescriptor{
+			Use: "signing",
+			KeyInfo: saml.KeyInfo{
+				X509Data: saml.X509Data{
+					X509Certificates: []saml.X509Certificate{
+						{Data: base64.StdEncoding.EncodeToString(certificate.Raw)},
+					},
+				},
+			},
+		})
+	}
+
+	sp.IDPMetadata = &saml.EntityDescriptor{
+		EntityID: connection.IdpEntityID,
+		IDPSSODescriptors: []saml.IDPSSODescriptor{
+			{
+				SSODescriptor: saml.SSODescriptor{
+					RoleDescriptor: saml.RoleDescriptor{
+						KeyDescriptors: keyDescriptors,
+					},
+				},
+				SingleSignOnServices: []saml.Endpoint{
+					{Binding: saml.HTTPRedirectBinding, Location: connection.IdpSsoUrl},
+				},
+			},
+		},
+	}
+
+	return sp, nil
+}
+
+// GetSamlServiceProviderMetadata returns the metadata of an organization's service provider, which the organization
+// uploads to their identity provider
+func GetSamlServiceProviderMetadata(config *configuration.Config, organizationID uuid.UUID) ([]byte, error) {
+	sp, err := NewSamlServiceProvider(config, organizationID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := sp.Metadata()
+
+	// Responses are only accepted with the HTTP-POST binding
+	for i := range metadata.SPSSODescriptors {
+		services := make([]saml.IndexedEndpoint, 0)
+		for _, service := range metadata.SPSSODescriptors[i].AssertionConsumerServices {
+			if service.Binding == saml.HTTPPostBinding {
+				services = append(services, service)
+			}
+		}
+		metadata.SPSSODescriptors[i].AssertionConsumerServices = services
+	}
+
+	return xml.MarshalIndent(metadata, "", "  ")
+}
+
+// MakeSamlAuthnRequest creates an authentication request for the HTTP-Redirect binding. The relay state comes back
+// with the response, and has to be URL safe.
+func MakeSamlAuthnRequest(sp *saml.ServiceProvider, relayState string) (*SamlAuthnRequest, error) {
+	request, err := sp.MakeAuthenticationRequest(sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return nil, err
+	}
+
+	redirectUrl, err := request.Redirect(relayState, sp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SamlAuthnRequest{
+		Url:       redirectUrl.String(),
+		RequestID: request.ID,
+	}, nil
+}
+
+// ParseSamlResponse verifies a base64 encoded response posted to the assertion consumer service, which has to be
+// signed by the identity provider and in response to the given request. The email and name are read from the
+// attributes the connection maps them to. The email falls back to the NameID if it's an email address.
+// Errors describe why the response was rejected, and shouldn't be shown to the user.
+func ParseSamlResponse(sp *saml.ServiceProvider, connection *models.OrganizationSamlConnection, samlResponse string, requestID string) (*SamlProfile, error) {
+	response, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return nil, fmt.Errorf("decoding saml response: %w", err)
+	}
+
+	assertion, err := sp.ParseXMLResponse(response, []string{requestID}, sp.AcsURL)
+	if err != nil {
+		var invalidResponseErr *saml.InvalidResponseError
+		if errors.As(err, &invalidResponseErr) {
+			return nil, fmt.Errorf("invalid saml response: %w", invalidResponseErr.PrivateErr)
+		}
+		return nil, err
+	}
+
+	if assertion.Subject == nil || assertion.Subject.NameID == nil || assertion.Subject.NameID.Value == "" {
+		return nil, errors.New("saml assertion has no subject")
+	}
+	nameID := assertion.Subject.NameID
+
+	profile := SamlProfile{
+		Subject: nameID.Value,
+		Email:   getSamlAttribute(assertion, connection.EmailAttribute),
+		Name:    getSamlAttribute(assertion, connection.NameAttribute),
+	}
+
+	if profile.Email == "" {
+		profile.Email = nameID.Value
+	}
+
+	address, err := mail.ParseAddress(profile.Email)
+	if err != nil || address.Address != profile.Email {
+		return nil, errors.New("saml assertion has no email address")
+	}
+
+	// Transient NameIDs change with every login, so the email is the only stable identifier
+	if nameID.Format == string(saml.TransientNameIDFormat) {
+		profile.Subject = profile.Email
+	}
+
+	return &profile, nil
+}
+
+// getSamlAttribute returns the first value of the attribute with the given name or friendly name
+func getSamlAttribute(assertion *saml.Assertion, name string) string {
+	if name == "" {
+		return ""
+	}
+
+	for _, statement := range assertion.AttributeStatements {
+		for _, attribute := range statement.Attributes {
+			if (attribute.Name == name || attribute.FriendlyName == name) && len(attribute.Values) > 0 {
+				return strings.TrimSpace(attribute.Values[0].Value)
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,163 @@
+package authentication
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/crewjam/saml"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"reece.start/internal/api"
+	"reece.start/internal/models"
+	testconfig "reece.start/test/config"
+	"reece.start/test/mocks"
+)
+
+func newTestSamlConnection(t *testing.T, idp *mocks.SAMLIdentityProvider) *models.OrganizationSamlConnection {
+	settings, err := ParseSamlIdpMetadata(idp.MetadataXML(t))
+	require.NoError(t, err)
+
+	return &models.OrganizationSamlConnection{
+		IdpEntityID:    settings.EntityID,
+		IdpSsoUrl:      settings.SsoUrl,
+		IdpCertificate: settings.Certificate,
+		EmailAttribute: "email",
+		NameAttribute:  "displayName",
+	}
+}
+
+func TestParseSamlIdpMetadata(t *testing.T) {
+	idp := mocks.NewSAMLIdentityProvider(t)
+
+	settings, err := ParseSamlIdpMetadata(idp.MetadataXML(t))
+	require.NoError(t, err)
+	require.Equal(t, idp.EntityID, settings.EntityID)
+	require.Equal(t, idp.SsoUrl, settings.SsoUrl)
+	require.Equal(t, idp.CertificatePem, settings.Certificate)
+
+	_, err = ParseSamlIdpMetadata("not xml")
+	require.ErrorIs(t, err, api.ErrSamlMetadataInvalid)
+}
+
+func TestParseSamlCertificates(t *testing.T) {
+	idp := mocks.NewSAMLIdentityProvider(t)
+	otherIdp := mocks.NewSAMLIdentityProvider(t)
+
+	certificates, err := ParseSamlCertificates(idp.CertificatePem)
+	require.NoError(t, err)
+	require.Len(t, certificates, 1)
+
+	// A new certificate can be listed alongside the current one
+	certificates, err = ParseSamlCertificates(idp.CertificatePem + otherIdp.CertificatePem)
+	require.NoError(t, err)
+	require.Len(t, certificates, 2)
+
+	_, err = ParseSamlCertificates("")
+	require.ErrorIs(t, err, api.ErrSamlCertificateInvalid)
+
+	_, err = ParseSamlCertificates(idp.CertificatePem + "trailing garbage")
+	require.ErrorIs(t, err, api.ErrSamlCertificateInvalid)
+}
+
+func TestGetSamlServiceProviderMetadata(t *testing.T) {
+	config := testconfig.CreateTestConfig()
+	organizationID := uuid.New()
+
+	metadata, err := GetSamlServiceProviderMetadata(config, organizationID)
+	require.NoError(t, err)
+
+	var descriptor saml.EntityDescriptor
+	require.NoError(t, xml.Unmarshal(metadata, &descriptor))
+
+	urls := GetSamlServiceProviderUrls(config, organizationID)
+	require.Equal(t, urls.EntityID, descriptor.EntityID)
+	require.Len(t, descriptor.SPSSODescriptors, 1)
+	require.Len(t, descriptor.SPSSODescriptors[0].AssertionConsumerServices, 1)
+	require.Equal(t, saml.HTTPPostBinding, descriptor.SPSSODescriptors[0].AssertionConsumerServices[0].Binding)
+	require.Equal(t, urls.AcsUrl, descriptor.SPSSODescriptors[0].AssertionConsumerServices[0].Location)
+}
+
+func TestParseSamlResponse(t *testing.T) {
+	config := testconfig.CreateTestConfig()
+	organizationID := uuid.New()
+
+	idp := mocks.NewSAMLIdentityProvider(t)
+	metadata, err := GetSamlServiceProviderMetadata(config, organizationID)
+	require.NoError(t, err)
+	idp.RegisterServiceProvider(t, metadata)
+
+	connection := newTestSamlConnection(t, idp)
+	sp, err := NewSamlServiceProvider(config, organizationID, connection)
+	require.NoError(t, err)
+
+	user := mocks.SAMLUser{NameID: "user-123", Email: "saml@example.com", Name: "SAML User"}
+
+	t.Run("Valid", func(t *testing.T) {
+		request, err := MakeSamlAuthnRequest(sp, "relay-state")
+		require.NoError(t, err)
+
+		response, relayState := idp.Respond(t, request.Url, user)
+		require.Equal(t, "relay-state", relayState)
+
+		profile, err := ParseSamlResponse(sp, connection, response, request.RequestID)
+		require.NoError(t, err)
+		require.Equal(t, "user-123", profile.Subject)
+		require.Equal(t, "saml@example.com", profile.Email)
+		require.Equal(t, "SAML User", profile.Name)
+	})
+
+	t.Run("DifferentRequest", func(t *testing.T) {
+		request, err := MakeSamlAuthnRequest(sp, "relay-state")
+		require.NoError(t, err)
+		otherRequest, err := MakeSamlAuthnRequest(sp, "relay-state")
+		require.NoError(t, err)
+
+		response, _ := idp.Respond(t, request.Url, user)
+
+		_, err = ParseSamlResponse(sp, connection, response, otherRequest.RequestID)
+		require.Error(t, err)
+	})
+
+	t.Run("UntrustedCertificate", func(t *testing.T) {
+		otherIdp := mocks.NewSAMLIdentityProvider(t)
+		untrustedConnection := *connection
+		untrustedConnection.IdpCertificate = otherIdp.CertificatePem
+
+		untrustedSp, err := NewSamlServiceProvider(config, organizationID, &untrustedConnection)
+		require.NoError(t, err)
+
+		request, err := MakeSamlAuthnRequest(untrustedSp, "relay-state")
+		require.NoError(t, err)
+
+		response, _ := idp.Respond(t, request.Url, user)
+
+		_, err = ParseSamlResponse(untrustedSp, &untrustedConnection, response, request.RequestID)
+		require.Error(t, err)
+	})
+
+	t.Run("EmailFromNameID", func(t *testing.T) {
+		request, err := MakeSamlAuthnRequest(sp, "relay-state")
+		require.NoError(t, err)
+
+		response, _ := idp.Respond(t, request.Url, mocks.SAMLUser{NameID: "nameid@example.com"})
+
+		profile, err := ParseSamlResponse(sp, connection, response, request.RequestID)
+		require.NoError(t, err)
+		require.Equal(t, "nameid@example.com", profile.Email)
+	})
+
+	t.Run("NoEmail", func(t *testing.T) {
+		request, err := MakeSamlAuthnRequest(sp, "relay-state")
+		require.NoError(t, err)
+
+		response, _ := idp.Respond(t, request.Url, mocks.SAMLUser{NameID: "user-123"})
+
+		_, err = ParseSamlResponse(sp, connection, response, request.RequestID)
+		require.Error(t, err)
+	})
+
+	t.Run("NotBase64", func(t *testing.T) {
+		_, err := ParseSamlResponse(sp, connection, "not base64!", "id")
+		require.Error(t, err)
+	})
+}
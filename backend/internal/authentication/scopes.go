@@ -0,0 +1,36 @@
+package authentication
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"reece.start/internal/constants"
+	"reece.start/internal/models"
+)
+
+type selectMembershipRole struct {
+	Role *constants.OrganizationRole
+}
+
+// GetGrantedScopes returns the scopes the user is granted by their role, and by their membership role if an organization is given.
+// Returns gorm.ErrRecordNotFound if the user isn't a member of the organization.
+func GetGrantedScopes(tx *gorm.DB, user *models.User, organizationID *uuid.UUID) ([]constants.UserScope, *constants.OrganizationRole, error) {
+	var membership selectMembershipRole
+	scopes := make([]constants.UserScope, 0)
+
+	if organizationID != nil && *organizationID != uuid.Nil {
+		err := tx.Model(&models.OrganizationMembership{}).Where("user_id = ? AND organization_id = ?", user.ID, organizationID).Select("role").First(&membership).Error
+		if err != nil {
+			return nil, nil, err
+		}
+
+		organizationScopes := constants.OrganizationRoleToScopes[constants.OrganizationRole(*membership.Role)]
+		scopes = append(scopes, organizationScopes...)
+	}
+
+	if user.Role != "" {
+		userScopes := constants.UserRoleToScopes[constants.UserRole(user.Role)]
+		scopes = append(scopes, userScopes...)
+	}
+
+	return scopes, membership.Role, nil
+}
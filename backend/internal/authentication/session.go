@@ -0,0 +1,56 @@
+package authentication
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"reece.start/internal/models"
+	"reece.start/internal/utils"
+)
+
+// Process-local cache of whether a session has been revoked, expiring after the same TTL as the user revocation cache
+var sessionRevocationCache = newRevocationCache[bool]()
+
+// IsSessionRevoked checks if the session has been signed out, using the process-local cache when possible.
+// Sessions that no longer exist are treated as revoked.
+func IsSessionRevoked(db *gorm.DB, sessionID uuid.UUID, ttl time.Duration) (bool, error) {
+	return sessionRevocationCache.get(sessionID, ttl, func() (bool, error) {
+		var session models.UserSession
+		err := db.Model(&models.UserSession{}).Select("id", "revoked_at").Where("id = ?", sessionID).First(&session).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return true, nil
+			}
+			return false, err
+		}
+		return session.RevokedAt != nil, nil
+	})
+}
+
+// RevokeSessions signs out the given sessions, revoking the refresh tokens issued to them as well
+func RevokeSessions(tx *gorm.DB, sessionIDs []uuid.UUID) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	err := tx.Model(&models.UserSession{}).Where("id IN ? AND revoked_at IS NULL", sessionIDs).Update("revoked_at", now).Error
+	if err != nil {
+		return err
+	}
+
+	err = tx.Model(&models.RefreshToken{}).Where("family_id IN ? AND revoked_at IS NULL", sessionIDs).Update("revoked_at", now).Error
+	if err != nil {
+		return err
+	}
+
+	// Cleared once tx is over for the same reason as the user revocation cache
+	utils.AfterTransaction(tx, func() {
+		for _, sessionID := range sessionIDs {
+			sessionRevocationCache.invalidate(sessionID)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,81 @@
+package authentication
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTotp(t *testing.T) {
+	t.Run("MatchesRfc6238TestVector", func(t *testing.T) {
+		// RFC 6238 appendix B, SHA1 secret "12345678901234567890" at T=59 is 94287082 (last 6 digits used here)
+		secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+		code, err := GenerateTotpCode(secret, time.Unix(59, 0))
+		require.NoError(t, err)
+		require.Equal(t, "287082", code)
+	})
+
+	t.Run("ValidatesCurrentCode", func(t *testing.T) {
+		secret, err := GenerateTotpSecret()
+		require.NoError(t, err)
+
+		now := time.Now()
+		code, err := GenerateTotpCode(secret, now)
+		require.NoError(t, err)
+
+		step, ok := ValidateTotpCode(secret, code, now, 0)
+		require.True(t, ok)
+		require.Equal(t, getTotpStep(now), step)
+	})
+
+	t.Run("AllowsClockDrift", func(t *testing.T) {
+		secret, err := GenerateTotpSecret()
+		require.NoError(t, err)
+
+		now := time.Now()
+		previous, err := GenerateTotpCode(secret, now.Add(-totpPeriod))
+		require.NoError(t, err)
+		_, ok := ValidateTotpCode(secret, previous, now, 0)
+		require.True(t, ok)
+
+		tooOld, err := GenerateTotpCode(secret, now.Add(-3*totpPeriod))
+		require.NoError(t, err)
+		_, ok = ValidateTotpCode(secret, tooOld, now, 0)
+		require.False(t, ok)
+	})
+
+	t.Run("RejectsReplayedCode", func(t *testing.T) {
+		secret, err := GenerateTotpSecret()
+		require.NoError(t, err)
+
+		now := time.Now()
+		code, err := GenerateTotpCode(secret, now)
+		require.NoError(t, err)
+
+		step, ok := ValidateTotpCode(secret, code, now, 0)
+		require.True(t, ok)
+
+		_, ok = ValidateTotpCode(secret, code, now, step)
+		require.False(t, ok)
+	})
+
+	t.Run("RejectsWrongCode", func(t *testing.T) {
+		secret, err := GenerateTotpSecret()
+		require.NoError(t, err)
+
+		_, ok := ValidateTotpCode(secret, "12345", time.Now(), 0)
+		require.False(t, ok)
+		_, ok = ValidateTotpCode(secret, "abcdef", time.Now(), 0)
+		require.False(t, ok)
+	})
+
+	t.Run("BuildsOtpauthUri", func(t *testing.T) {
+		uri := GetTotpUri("reece-start", "user@example.com", "SECRET")
+		require.True(t, strings.HasPrefix(uri, "otpauth://totp/reece-start:user@example.com?"))
+		require.Contains(t, uri, "secret=SECRET")
+		require.Contains(t, uri, "issuer=reece-start")
+	})
+}
@@ -3,11 +3,28 @@ package constants
 type ApiType string
 
 const (
-	ApiTypeUser                   ApiType = "user"
-	ApiTypeOrganization           ApiType = "organization"
-	ApiTypeToken                  ApiType = "token"
-	ApiTypeOrganizationMembership ApiType = "organization-membership"
-	ApiTypeOrganizationInvitation ApiType = "organization-invitation"
-	ApiTypeStripeAccountLink      ApiType = "stripe-account-link"
-	ApiTypeStripeDashboardLink    ApiType = "stripe-dashboard-link"
+	ApiTypeUser                       ApiType = "user"
+	ApiTypeOrganization               ApiType = "organization"
+	ApiTypeToken                      ApiType = "token"
+	ApiTypeTotpEnrollment             ApiType = "totp-enrollment"
+	ApiTypeMfaRecoveryCodes           ApiType = "mfa-recovery-codes"
+	ApiTypePasskey                    ApiType = "passkey"
+	ApiTypePasskeyChallenge           ApiType = "passkey-challenge"
+	ApiTypeOAuthAuthorization         ApiType = "oauth-authorization"
+	ApiTypeSamlAuthorization          ApiType = "saml-authorization"
+	ApiTypeUserIdentity               ApiType = "user-identity"
+	ApiTypeLoginLockout               ApiType = "login-lockout"
+	ApiTypeImpersonation              ApiType = "impersonation"
+	ApiTypeUserSession                ApiType = "user-session"
+	ApiTypeUserDataExport             ApiType = "user-data-export"
+	ApiTypeAccessToken                ApiType = "access-token"
+	ApiTypeOrganizationMembership     ApiType = "organization-membership"
+	ApiTypeOrganizationInvitation     ApiType = "organization-invitation"
+	ApiTypeOrganizationApiKey         ApiType = "organization-api-key"
+	ApiTypeOrganizationSamlConnection ApiType = "organization-saml-connection"
+	ApiTypeOrganizationDomain         ApiType = "organization-domain"
+	ApiTypeOrganizationJoinRequest    ApiType = "organization-join-request"
+	ApiTypeJoinableOrganization       ApiType = "joinable-organization"
+	ApiTypeStripeAccountLink          ApiType = "stripe-account-link"
+	ApiTypeStripeDashboardLink        ApiType = "stripe-dashboard-link"
 )
@@ -0,0 +1,13 @@
+package constants
+
+type CredentialChange string
+
+// Changes to how a user signs in that they are emailed about
+const (
+	CredentialChangePassword      CredentialChange = "password"
+	CredentialChangePasswordAdded CredentialChange = "password_added" // Set by a user who signed up without one
+	CredentialChangeEmail         CredentialChange = "email"
+	CredentialChangePasskey       CredentialChange = "passkey"
+	CredentialChangeIdentity      CredentialChange = "identity" // Linked an OAuth provider account
+	CredentialChangeAccessToken   CredentialChange = "access_token"
+)
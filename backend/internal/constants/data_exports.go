@@ -0,0 +1,9 @@
+package constants
+
+type UserDataExportStatus string
+
+const (
+	UserDataExportStatusPending   UserDataExportStatus = "pending"   // Waiting for the background job to put the archive together
+	UserDataExportStatusCompleted UserDataExportStatus = "completed" // Archive stored and download link emailed
+	UserDataExportStatusFailed    UserDataExportStatus = "failed"    // The background job gave up
+)
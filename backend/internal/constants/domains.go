@@ -0,0 +1,24 @@
+package constants
+
+type OrganizationDomainJoinPolicy string
+type OrganizationJoinRequestStatus string
+
+// How users with a verified email address at an organization's verified domain can join it
+const (
+	OrganizationDomainJoinPolicyAutoJoin OrganizationDomainJoinPolicy = "auto_join" // Added as members the next time they sign in
+	OrganizationDomainJoinPolicyPrompt   OrganizationDomainJoinPolicy = "prompt"    // Offered to join, and added once they accept
+	OrganizationDomainJoinPolicyApproval OrganizationDomainJoinPolicy = "approval"  // Can ask to join, and added once an admin approves
+)
+
+const (
+	OrganizationJoinRequestStatusPending  OrganizationJoinRequestStatus = "pending"
+	OrganizationJoinRequestStatusApproved OrganizationJoinRequestStatus = "approved"
+	OrganizationJoinRequestStatusDenied   OrganizationJoinRequestStatus = "denied"
+)
+
+// DNS TXT record an organization proves it owns a domain with. The record is set on the prefixed name, e.g.
+// _reece-start-verification.example.com, with the prefixed verification token as its value.
+const (
+	OrganizationDomainVerificationRecordPrefix = "_reece-start-verification."
+	OrganizationDomainVerificationValuePrefix  = "reece-start-verification="
+)
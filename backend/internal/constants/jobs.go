@@ -4,4 +4,14 @@ type JobKind string
 
 const (
 	JobKindOrganizationInvitationEmail JobKind = "OrganizationInvitationEmail"
+	JobKindPasswordResetEmail          JobKind = "PasswordResetEmail"
+	JobKindEmailVerificationEmail      JobKind = "EmailVerificationEmail"
+	JobKindMagicLinkEmail              JobKind = "MagicLinkEmail"
+	JobKindLoginLockoutEmail           JobKind = "LoginLockoutEmail"
+	JobKindImpersonationEmail          JobKind = "ImpersonationEmail"
+	JobKindUserDeletion                JobKind = "UserDeletion"
+	JobKindUserDeletionCancelledEmail  JobKind = "UserDeletionCancelledEmail"
+	JobKindUserDataExport              JobKind = "UserDataExport"
+	JobKindUserDataExportExpiry        JobKind = "UserDataExportExpiry"
+	JobKindCredentialChangeEmail       JobKind = "CredentialChangeEmail"
 )
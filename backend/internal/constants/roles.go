@@ -21,6 +21,10 @@ var UserRoleToScopes = map[UserRole][]UserScope{
 		UserScopeAdminUsersList,
 		UserScopeAdminUsersRead,
 		UserScopeAdminUsersImpersonate,
+		UserScopeAdminUsersMfaReset,
+		UserScopeAdminLoginLockoutsList,
+		UserScopeAdminLoginLockoutsDelete,
+		UserScopeAdminImpersonationsList,
 	},
 	UserRoleDefault: {},
 }
@@ -44,6 +48,20 @@ var OrganizationRoleToScopes = map[OrganizationRole][]UserScope{
 		UserScopeOrganizationInvitationsDelete,
 		UserScopeOrganizationStripeUpdate,
 		UserScopeOrganizationBillingUpdate,
+		UserScopeOrganizationApiKeysList,
+		UserScopeOrganizationApiKeysCreate,
+		UserScopeOrganizationApiKeysUpdate,
+		UserScopeOrganizationApiKeysDelete,
+		UserScopeOrganizationSamlRead,
+		UserScopeOrganizationSamlUpdate,
+		UserScopeOrganizationSamlDelete,
+		UserScopeOrganizationScimProvision,
+		UserScopeOrganizationDomainsList,
+		UserScopeOrganizationDomainsCreate,
+		UserScopeOrganizationDomainsUpdate,
+		UserScopeOrganizationDomainsDelete,
+		UserScopeOrganizationJoinRequestsList,
+		UserScopeOrganizationJoinRequestsUpdate,
 	},
 
 	// Grant limited (mostly read scopes) to the member
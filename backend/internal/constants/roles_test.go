@@ -17,6 +17,10 @@ func TestUserRoleToScopes(t *testing.T) {
 			UserScopeAdminUsersList,
 			UserScopeAdminUsersRead,
 			UserScopeAdminUsersImpersonate,
+			UserScopeAdminUsersMfaReset,
+			UserScopeAdminLoginLockoutsList,
+			UserScopeAdminLoginLockoutsDelete,
+			UserScopeAdminImpersonationsList,
 		}
 
 		require.Equal(t, len(expectedScopes), len(scopes), "Admin role should have correct number of scopes")
@@ -80,6 +84,20 @@ func TestUserRoleToScopes(t *testing.T) {
 			UserScopeOrganizationInvitationsDelete,
 			UserScopeOrganizationStripeUpdate,
 			UserScopeOrganizationBillingUpdate,
+			UserScopeOrganizationApiKeysList,
+			UserScopeOrganizationApiKeysCreate,
+			UserScopeOrganizationApiKeysUpdate,
+			UserScopeOrganizationApiKeysDelete,
+			UserScopeOrganizationSamlRead,
+			UserScopeOrganizationSamlUpdate,
+			UserScopeOrganizationSamlDelete,
+			UserScopeOrganizationScimProvision,
+			UserScopeOrganizationDomainsList,
+			UserScopeOrganizationDomainsCreate,
+			UserScopeOrganizationDomainsUpdate,
+			UserScopeOrganizationDomainsDelete,
+			UserScopeOrganizationJoinRequestsList,
+			UserScopeOrganizationJoinRequestsUpdate,
 		}
 
 		for _, orgScope := range organizationScopes {
@@ -119,6 +137,20 @@ func TestOrganizationRoleToScopes(t *testing.T) {
 			UserScopeOrganizationInvitationsDelete,
 			UserScopeOrganizationStripeUpdate,
 			UserScopeOrganizationBillingUpdate,
+			UserScopeOrganizationApiKeysList,
+			UserScopeOrganizationApiKeysCreate,
+			UserScopeOrganizationApiKeysUpdate,
+			UserScopeOrganizationApiKeysDelete,
+			UserScopeOrganizationSamlRead,
+			UserScopeOrganizationSamlUpdate,
+			UserScopeOrganizationSamlDelete,
+			UserScopeOrganizationScimProvision,
+			UserScopeOrganizationDomainsList,
+			UserScopeOrganizationDomainsCreate,
+			UserScopeOrganizationDomainsUpdate,
+			UserScopeOrganizationDomainsDelete,
+			UserScopeOrganizationJoinRequestsList,
+			UserScopeOrganizationJoinRequestsUpdate,
 		}
 
 		require.Equal(t, len(expectedScopes), len(scopes), "Organization admin role should have correct number of scopes")
@@ -173,6 +205,20 @@ func TestOrganizationRoleToScopes(t *testing.T) {
 			UserScopeOrganizationInvitationsDelete,
 			UserScopeOrganizationStripeUpdate,
 			UserScopeOrganizationBillingUpdate,
+			UserScopeOrganizationApiKeysList,
+			UserScopeOrganizationApiKeysCreate,
+			UserScopeOrganizationApiKeysUpdate,
+			UserScopeOrganizationApiKeysDelete,
+			UserScopeOrganizationSamlRead,
+			UserScopeOrganizationSamlUpdate,
+			UserScopeOrganizationSamlDelete,
+			UserScopeOrganizationScimProvision,
+			UserScopeOrganizationDomainsList,
+			UserScopeOrganizationDomainsCreate,
+			UserScopeOrganizationDomainsUpdate,
+			UserScopeOrganizationDomainsDelete,
+			UserScopeOrganizationJoinRequestsList,
+			UserScopeOrganizationJoinRequestsUpdate,
 		}
 
 		for _, writeScope := range writeScopes {
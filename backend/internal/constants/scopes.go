@@ -4,25 +4,43 @@ type UserScope string
 
 const (
 	// Organization
-	UserScopeOrganizationRead              UserScope = "organization:read"
-	UserScopeOrganizationUpdate            UserScope = "organization:update"
-	UserScopeOrganizationDelete            UserScope = "organization:delete"
-	UserScopeOrganizationMembershipsList   UserScope = "organization:memberships:list"
-	UserScopeOrganizationMembershipsRead   UserScope = "organization:memberships:read"
-	UserScopeOrganizationMembershipsCreate UserScope = "organization:memberships:create"
-	UserScopeOrganizationMembershipsUpdate UserScope = "organization:memberships:update"
-	UserScopeOrganizationMembershipsDelete UserScope = "organization:memberships:delete"
-	UserScopeOrganizationInvitationsList   UserScope = "organization:invitations:list"
-	UserScopeOrganizationInvitationsRead   UserScope = "organization:invitations:read"
-	UserScopeOrganizationInvitationsCreate UserScope = "organization:invitations:create"
-	UserScopeOrganizationInvitationsUpdate UserScope = "organization:invitations:update"
-	UserScopeOrganizationInvitationsDelete UserScope = "organization:invitations:delete"
-	UserScopeOrganizationStripeUpdate      UserScope = "organization:stripe:update"
-	UserScopeOrganizationBillingUpdate     UserScope = "organization:billing:update"
+	UserScopeOrganizationRead               UserScope = "organization:read"
+	UserScopeOrganizationUpdate             UserScope = "organization:update"
+	UserScopeOrganizationDelete             UserScope = "organization:delete"
+	UserScopeOrganizationMembershipsList    UserScope = "organization:memberships:list"
+	UserScopeOrganizationMembershipsRead    UserScope = "organization:memberships:read"
+	UserScopeOrganizationMembershipsCreate  UserScope = "organization:memberships:create"
+	UserScopeOrganizationMembershipsUpdate  UserScope = "organization:memberships:update"
+	UserScopeOrganizationMembershipsDelete  UserScope = "organization:memberships:delete"
+	UserScopeOrganizationInvitationsList    UserScope = "organization:invitations:list"
+	UserScopeOrganizationInvitationsRead    UserScope = "organization:invitations:read"
+	UserScopeOrganizationInvitationsCreate  UserScope = "organization:invitations:create"
+	UserScopeOrganizationInvitationsUpdate  UserScope = "organization:invitations:update"
+	UserScopeOrganizationInvitationsDelete  UserScope = "organization:invitations:delete"
+	UserScopeOrganizationStripeUpdate       UserScope = "organization:stripe:update"
+	UserScopeOrganizationBillingUpdate      UserScope = "organization:billing:update"
+	UserScopeOrganizationApiKeysList        UserScope = "organization:api-keys:list"
+	UserScopeOrganizationApiKeysCreate      UserScope = "organization:api-keys:create"
+	UserScopeOrganizationApiKeysUpdate      UserScope = "organization:api-keys:update"
+	UserScopeOrganizationApiKeysDelete      UserScope = "organization:api-keys:delete"
+	UserScopeOrganizationSamlRead           UserScope = "organization:saml:read"
+	UserScopeOrganizationSamlUpdate         UserScope = "organization:saml:update"
+	UserScopeOrganizationSamlDelete         UserScope = "organization:saml:delete"
+	UserScopeOrganizationScimProvision      UserScope = "organization:scim:provision"
+	UserScopeOrganizationDomainsList        UserScope = "organization:domains:list"
+	UserScopeOrganizationDomainsCreate      UserScope = "organization:domains:create"
+	UserScopeOrganizationDomainsUpdate      UserScope = "organization:domains:update"
+	UserScopeOrganizationDomainsDelete      UserScope = "organization:domains:delete"
+	UserScopeOrganizationJoinRequestsList   UserScope = "organization:join-requests:list"
+	UserScopeOrganizationJoinRequestsUpdate UserScope = "organization:join-requests:update"
 
 	// Admin
-	UserScopeAdmin                 UserScope = "admin"
-	UserScopeAdminUsersList        UserScope = "admin:users:list"
-	UserScopeAdminUsersRead        UserScope = "admin:users:read"
-	UserScopeAdminUsersImpersonate UserScope = "admin:users:impersonate"
+	UserScopeAdmin                    UserScope = "admin"
+	UserScopeAdminUsersList           UserScope = "admin:users:list"
+	UserScopeAdminUsersRead           UserScope = "admin:users:read"
+	UserScopeAdminUsersImpersonate    UserScope = "admin:users:impersonate"
+	UserScopeAdminUsersMfaReset       UserScope = "admin:users:mfa:reset"
+	UserScopeAdminLoginLockoutsList   UserScope = "admin:login-lockouts:list"
+	UserScopeAdminLoginLockoutsDelete UserScope = "admin:login-lockouts:delete"
+	UserScopeAdminImpersonationsList  UserScope = "admin:impersonations:list"
 )
@@ -5,4 +5,5 @@ type StorageBucket string
 const (
 	StorageBucketUserLogos         StorageBucket = "user-logos"
 	StorageBucketOrganizationLogos StorageBucket = "organization-logos"
+	StorageBucketUserDataExports   StorageBucket = "user-data-exports"
 )
@@ -6,11 +6,66 @@ import (
 )
 
 func Migrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+	// Checked before migrating, since migrating is what adds the column
+	hasEmailVerification := db.Migrator().HasColumn(&models.User{}, "email_verified_at")
+
+	err := db.AutoMigrate(
 		&models.User{},
 		&models.Organization{},
 		&models.OrganizationMembership{},
 		&models.OrganizationInvitation{},
 		&models.OrganizationPlanPeriod{},
+		&models.RefreshToken{},
+		&models.MfaRecoveryCode{},
+		&models.Passkey{},
+		&models.PasskeyChallenge{},
+		&models.PasswordResetToken{},
+		&models.MagicLinkToken{},
+		&models.UserIdentity{},
+		&models.OAuthState{},
+		&models.LoginThrottle{},
+		&models.UserSession{},
+		&models.PersonalAccessToken{},
+		&models.OrganizationApiKey{},
+		&models.OrganizationSamlConnection{},
+		&models.SamlLogin{},
+		&models.OrganizationDomain{},
+		&models.OrganizationJoinRequest{},
+		&models.ImpersonationSession{},
+		&models.UserDataExport{},
 	)
+	if err != nil {
+		return err
+	}
+
+	if !hasEmailVerification {
+		err = backfillEmailVerification(db)
+		if err != nil {
+			return err
+		}
+	}
+
+	return migrateGoogleIdentities(db)
+}
+
+// backfillEmailVerification treats users who signed up before emails were verified as verified, so they can still
+// accept invitations and do the other things that now need a verified email. It only runs when the column is added.
+func backfillEmailVerification(db *gorm.DB) error {
+	return db.Exec(`UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL`).Error
+}
+
+// migrateGoogleIdentities copies Google accounts linked through the old users.google_id column into user identities.
+// The column is left in place, and rows that were already copied are skipped, so this is safe to run on every start.
+func migrateGoogleIdentities(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.User{}, "google_id") {
+		return nil
+	}
+
+	return db.Exec(`
+		INSERT INTO user_identities (created_at, updated_at, user_id, provider, subject, email, profile_image)
+		SELECT NOW(), NOW(), id, 'google', google_id, email, COALESCE(google_profile_image, '')
+		FROM users
+		WHERE google_id IS NOT NULL AND google_id <> '' AND deleted_at IS NULL
+		ON CONFLICT (provider, subject) DO NOTHING
+	`).Error
 }
@@ -0,0 +1,16 @@
+package dns
+
+import (
+	"context"
+	"net"
+)
+
+// Resolver looks up DNS records. Tests replace it with a stub, so they don't depend on real DNS servers.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// NewResolver returns a resolver that uses the system's DNS configuration
+func NewResolver() Resolver {
+	return net.DefaultResolver
+}
@@ -1,6 +1,9 @@
 package server
 
 import (
+	"bytes"
+	"strings"
+
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	appMiddleware "reece.start/internal/middleware"
@@ -11,8 +14,17 @@ import (
 func NewEcho(deps appMiddleware.AppDependencies) *echo.Echo {
 	e := echo.New()
 
-	// Add logging middleware
-	e.Use(middleware.Logger())
+	// Use the client IP from X-Forwarded-For, but only when the request came through a proxy on a private network,
+	// otherwise clients could set any IP they like (this matters for login throttling)
+	e.IPExtractor = echo.ExtractIPFromXFFHeader()
+
+	// Add logging middleware. Requests are attributed to whoever made them, either a user or an organization API key.
+	loggerConfig := middleware.DefaultLoggerConfig
+	loggerConfig.Format = strings.TrimSuffix(loggerConfig.Format, "}\n") + `,"principal":"${custom}"}` + "\n"
+	loggerConfig.CustomTagFunc = func(c echo.Context, buf *bytes.Buffer) (int, error) {
+		return buf.WriteString(appMiddleware.GetPrincipalFromJWT(c))
+	}
+	e.Use(middleware.LoggerWithConfig(loggerConfig))
 
 	// Add error handling middleware
 	e.Use(appMiddleware.ErrorHandlingMiddleware)
@@ -23,6 +35,9 @@ func NewEcho(deps appMiddleware.AppDependencies) *echo.Echo {
 	// Add content type middleware
 	e.Use(appMiddleware.ContentTypeMiddleware)
 
+	// Add middleware that runs work deferred until the request's transactions are over
+	e.Use(appMiddleware.AfterTransactionMiddleware)
+
 	// Add dependency injection middleware
 	e.Use(appMiddleware.DependencyInjectionMiddleware(deps))
 
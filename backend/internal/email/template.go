@@ -5,7 +5,9 @@ import (
 	"html/template"
 	"os"
 	"path/filepath"
+	"time"
 
+	"reece.start/internal/constants"
 	"reece.start/internal/models"
 )
 
@@ -32,6 +34,135 @@ func (params OrganizationInvitationEmailTemplateParams) ApplyHtmlTemplate() (str
 	})
 }
 
+type PasswordResetEmailTemplateParams struct {
+	User               models.User
+	Token              string
+	ExpiresAt          time.Time
+	FrontendUrl        string
+	ServiceName        string
+	ServiceDescription string
+}
+
+func (params PasswordResetEmailTemplateParams) ApplyHtmlTemplate() (string, error) {
+	return applyHtmlTemplate(HtmlTemplateParams{
+		Template: "passwordResetEmail",
+		Params:   params,
+	})
+}
+
+type MagicLinkEmailTemplateParams struct {
+	User               models.User
+	Token              string
+	ExpiresAt          time.Time
+	FrontendUrl        string
+	ServiceName        string
+	ServiceDescription string
+}
+
+func (params MagicLinkEmailTemplateParams) ApplyHtmlTemplate() (string, error) {
+	return applyHtmlTemplate(HtmlTemplateParams{
+		Template: "magicLinkEmail",
+		Params:   params,
+	})
+}
+
+type EmailVerificationEmailTemplateParams struct {
+	User               models.User
+	Email              string
+	Token              string
+	FrontendUrl        string
+	ServiceName        string
+	ServiceDescription string
+}
+
+func (params EmailVerificationEmailTemplateParams) ApplyHtmlTemplate() (string, error) {
+	return applyHtmlTemplate(HtmlTemplateParams{
+		Template: "emailVerificationEmail",
+		Params:   params,
+	})
+}
+
+type LoginLockoutEmailTemplateParams struct {
+	User               models.User
+	LockedUntil        time.Time
+	FrontendUrl        string
+	ServiceName        string
+	ServiceDescription string
+}
+
+func (params LoginLockoutEmailTemplateParams) ApplyHtmlTemplate() (string, error) {
+	return applyHtmlTemplate(HtmlTemplateParams{
+		Template: "loginLockoutEmail",
+		Params:   params,
+	})
+}
+
+type UserDeletionCancelledEmailTemplateParams struct {
+	User               models.User
+	FrontendUrl        string
+	ServiceName        string
+	ServiceDescription string
+}
+
+func (params UserDeletionCancelledEmailTemplateParams) ApplyHtmlTemplate() (string, error) {
+	return applyHtmlTemplate(HtmlTemplateParams{
+		Template: "userDeletionCancelledEmail",
+		Params:   params,
+	})
+}
+
+type ImpersonationEmailTemplateParams struct {
+	User               models.User
+	Reason             string
+	ReadOnly           bool
+	StartedAt          time.Time
+	ExpiresAt          time.Time
+	FrontendUrl        string
+	ServiceName        string
+	ServiceDescription string
+}
+
+func (params ImpersonationEmailTemplateParams) ApplyHtmlTemplate() (string, error) {
+	return applyHtmlTemplate(HtmlTemplateParams{
+		Template: "impersonationEmail",
+		Params:   params,
+	})
+}
+
+type UserDataExportEmailTemplateParams struct {
+	User               models.User
+	DownloadUrl        string
+	ExpiresAt          time.Time
+	FrontendUrl        string
+	ServiceName        string
+	ServiceDescription string
+}
+
+func (params UserDataExportEmailTemplateParams) ApplyHtmlTemplate() (string, error) {
+	return applyHtmlTemplate(HtmlTemplateParams{
+		Template: "userDataExportEmail",
+		Params:   params,
+	})
+}
+
+type CredentialChangeEmailTemplateParams struct {
+	User               models.User
+	Change             constants.CredentialChange
+	NewEmail           string // Set when the email address was changed
+	Detail             string // Name of the passkey, access token or OAuth provider that was added
+	ChangedAt          time.Time
+	FrontendUrl        string
+	ServiceName        string
+	ServiceDescription string
+}
+
+func (params CredentialChangeEmailTemplateParams) ApplyHtmlTemplate() (string, error) {
+	return applyHtmlTemplate(HtmlTemplateParams{
+		Template: "credentialChangeEmail",
+		Params:   params,
+	})
+}
+
 func applyHtmlTemplate(params HtmlTemplateParams) (string, error) {
 	// Resolve template path relative to backend directory
 	// This ensures templates can be found regardless of the current working directory
@@ -42,9 +173,9 @@ func applyHtmlTemplate(params HtmlTemplateParams) (string, error) {
 		return "", err
 	}
 
-	// Execute the template
+	// Execute the requested template, the glob contains every template
 	buffer := bytes.Buffer{}
-	err = tmpl.Execute(&buffer, params.Params)
+	err = tmpl.ExecuteTemplate(&buffer, params.Template+".html", params.Params)
 	if err != nil {
 		return "", err
 	}
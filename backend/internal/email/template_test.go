@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -163,3 +164,272 @@ func TestApplyHtmlTemplate(t *testing.T) {
 		assert.Contains(t, html, "href=")
 	})
 }
+
+func TestPasswordResetEmailTemplateParams(t *testing.T) {
+	t.Run("ApplyHtmlTemplate", func(t *testing.T) {
+		cleanup := setupTemplateTest(t)
+		defer cleanup()
+
+		params := PasswordResetEmailTemplateParams{
+			User: models.User{
+				Name:  "John Doe",
+				Email: "john@example.com",
+			},
+			Token:              "reset-token",
+			ExpiresAt:          time.Date(2025, 1, 2, 15, 4, 0, 0, time.UTC),
+			FrontendUrl:        "http://localhost:3000",
+			ServiceName:        constants.ServiceName,
+			ServiceDescription: constants.ServiceDescription,
+		}
+
+		html, err := params.ApplyHtmlTemplate()
+
+		require.NoError(t, err)
+		assert.Contains(t, html, "John Doe")
+		assert.Contains(t, html, "http://localhost:3000/reset-password?token=reset-token")
+		assert.Contains(t, html, "15:04 UTC on Jan 2, 2025")
+		assert.Contains(t, html, constants.ServiceDescription)
+
+		// Each template renders on its own, not the first one in the directory
+		assert.NotContains(t, html, "invited you to join")
+	})
+}
+
+func TestMagicLinkEmailTemplateParams(t *testing.T) {
+	t.Run("ApplyHtmlTemplate", func(t *testing.T) {
+		cleanup := setupTemplateTest(t)
+		defer cleanup()
+
+		params := MagicLinkEmailTemplateParams{
+			User: models.User{
+				Name:  "John Doe",
+				Email: "john@example.com",
+			},
+			Token:              "magic-link-token",
+			ExpiresAt:          time.Date(2025, 1, 2, 15, 4, 0, 0, time.UTC),
+			FrontendUrl:        "http://localhost:3000",
+			ServiceName:        constants.ServiceName,
+			ServiceDescription: constants.ServiceDescription,
+		}
+
+		html, err := params.ApplyHtmlTemplate()
+
+		require.NoError(t, err)
+		assert.Contains(t, html, "John Doe")
+		assert.Contains(t, html, "http://localhost:3000/magic-link?token=magic-link-token")
+		assert.Contains(t, html, "15:04 UTC on Jan 2, 2025")
+		assert.Contains(t, html, constants.ServiceDescription)
+	})
+}
+
+func TestEmailVerificationEmailTemplateParams(t *testing.T) {
+	t.Run("ApplyHtmlTemplate", func(t *testing.T) {
+		cleanup := setupTemplateTest(t)
+		defer cleanup()
+
+		params := EmailVerificationEmailTemplateParams{
+			User: models.User{
+				Name:  "John Doe",
+				Email: "john@example.com",
+			},
+			Email:              "john.new@example.com",
+			Token:              "verification-token",
+			FrontendUrl:        "http://localhost:3000",
+			ServiceName:        constants.ServiceName,
+			ServiceDescription: constants.ServiceDescription,
+		}
+
+		html, err := params.ApplyHtmlTemplate()
+
+		require.NoError(t, err)
+		assert.Contains(t, html, "John Doe")
+		assert.Contains(t, html, "john.new@example.com")
+		assert.Contains(t, html, "http://localhost:3000/verify-email?token=verification-token")
+		assert.Contains(t, html, constants.ServiceDescription)
+	})
+}
+
+func TestLoginLockoutEmailTemplateParams(t *testing.T) {
+	t.Run("ApplyHtmlTemplate", func(t *testing.T) {
+		cleanup := setupTemplateTest(t)
+		defer cleanup()
+
+		params := LoginLockoutEmailTemplateParams{
+			User: models.User{
+				Name:  "John Doe",
+				Email: "john@example.com",
+			},
+			LockedUntil:        time.Date(2025, 1, 2, 15, 4, 0, 0, time.UTC),
+			FrontendUrl:        "http://localhost:3000",
+			ServiceName:        constants.ServiceName,
+			ServiceDescription: constants.ServiceDescription,
+		}
+
+		html, err := params.ApplyHtmlTemplate()
+
+		require.NoError(t, err)
+		assert.Contains(t, html, "John Doe")
+		assert.Contains(t, html, "15:04 UTC on Jan 2, 2025")
+		assert.Contains(t, html, "http://localhost:3000/signin")
+		assert.Contains(t, html, constants.ServiceDescription)
+	})
+}
+
+func TestUserDeletionCancelledEmailTemplateParams(t *testing.T) {
+	t.Run("ApplyHtmlTemplate", func(t *testing.T) {
+		cleanup := setupTemplateTest(t)
+		defer cleanup()
+
+		params := UserDeletionCancelledEmailTemplateParams{
+			User: models.User{
+				Name:  "John Doe",
+				Email: "john@example.com",
+			},
+			FrontendUrl:        "http://localhost:3000",
+			ServiceName:        constants.ServiceName,
+			ServiceDescription: constants.ServiceDescription,
+		}
+
+		html, err := params.ApplyHtmlTemplate()
+
+		require.NoError(t, err)
+		assert.Contains(t, html, "John Doe")
+		assert.Contains(t, html, "only admin of an organization")
+		assert.Contains(t, html, "http://localhost:3000")
+		assert.Contains(t, html, constants.ServiceDescription)
+	})
+}
+
+func TestImpersonationEmailTemplateParams(t *testing.T) {
+	t.Run("ApplyHtmlTemplate", func(t *testing.T) {
+		cleanup := setupTemplateTest(t)
+		defer cleanup()
+
+		params := ImpersonationEmailTemplateParams{
+			User: models.User{
+				Name:  "John Doe",
+				Email: "john@example.com",
+			},
+			Reason:             "Investigating support ticket <1234>",
+			ReadOnly:           true,
+			StartedAt:          time.Date(2025, 1, 2, 15, 4, 0, 0, time.UTC),
+			ExpiresAt:          time.Date(2025, 1, 2, 16, 4, 0, 0, time.UTC),
+			FrontendUrl:        "http://localhost:3000",
+			ServiceName:        constants.ServiceName,
+			ServiceDescription: constants.ServiceDescription,
+		}
+
+		html, err := params.ApplyHtmlTemplate()
+
+		require.NoError(t, err)
+		assert.Contains(t, html, "John Doe")
+		assert.Contains(t, html, "Investigating support ticket &lt;1234&gt;")
+		assert.Contains(t, html, "only view your account")
+		assert.Contains(t, html, "15:04 UTC on Jan 2, 2025")
+		assert.Contains(t, html, "16:04 UTC on Jan 2, 2025")
+		assert.Contains(t, html, constants.ServiceDescription)
+	})
+}
+
+func TestUserDataExportEmailTemplateParams(t *testing.T) {
+	t.Run("ApplyHtmlTemplate", func(t *testing.T) {
+		cleanup := setupTemplateTest(t)
+		defer cleanup()
+
+		params := UserDataExportEmailTemplateParams{
+			User: models.User{
+				Name:  "John Doe",
+				Email: "john@example.com",
+			},
+			DownloadUrl:        "http://localhost:9000/user-data-exports/archive.zip?X-Amz-Signature=abc",
+			ExpiresAt:          time.Date(2025, 1, 3, 15, 4, 0, 0, time.UTC),
+			FrontendUrl:        "http://localhost:3000",
+			ServiceName:        constants.ServiceName,
+			ServiceDescription: constants.ServiceDescription,
+		}
+
+		html, err := params.ApplyHtmlTemplate()
+
+		require.NoError(t, err)
+		assert.Contains(t, html, "John Doe")
+		assert.Contains(t, html, "http://localhost:9000/user-data-exports/archive.zip?X-Amz-Signature=abc")
+		assert.Contains(t, html, "15:04 UTC on Jan 3, 2025")
+		assert.Contains(t, html, constants.ServiceDescription)
+	})
+}
+
+func TestCredentialChangeEmailTemplateParams(t *testing.T) {
+	t.Run("PasswordChanged", func(t *testing.T) {
+		cleanup := setupTemplateTest(t)
+		defer cleanup()
+
+		params := CredentialChangeEmailTemplateParams{
+			User: models.User{
+				Name:  "John Doe",
+				Email: "john@example.com",
+			},
+			Change:             constants.CredentialChangePassword,
+			ChangedAt:          time.Date(2025, 1, 2, 15, 4, 0, 0, time.UTC),
+			FrontendUrl:        "http://localhost:3000",
+			ServiceName:        constants.ServiceName,
+			ServiceDescription: constants.ServiceDescription,
+		}
+
+		html, err := params.ApplyHtmlTemplate()
+
+		require.NoError(t, err)
+		assert.Contains(t, html, "John Doe")
+		assert.Contains(t, html, "password of your")
+		assert.Contains(t, html, "15:04 UTC on Jan 2, 2025")
+		assert.Contains(t, html, constants.ServiceDescription)
+	})
+
+	t.Run("EmailChanged", func(t *testing.T) {
+		cleanup := setupTemplateTest(t)
+		defer cleanup()
+
+		params := CredentialChangeEmailTemplateParams{
+			User: models.User{
+				Name:  "John Doe",
+				Email: "john@example.com",
+			},
+			Change:             constants.CredentialChangeEmail,
+			NewEmail:           "johnny@example.com",
+			ChangedAt:          time.Date(2025, 1, 2, 15, 4, 0, 0, time.UTC),
+			FrontendUrl:        "http://localhost:3000",
+			ServiceName:        constants.ServiceName,
+			ServiceDescription: constants.ServiceDescription,
+		}
+
+		html, err := params.ApplyHtmlTemplate()
+
+		require.NoError(t, err)
+		assert.Contains(t, html, "email address of your")
+		assert.Contains(t, html, "johnny@example.com")
+		assert.NotContains(t, html, "password of your")
+	})
+
+	t.Run("PasskeyAdded", func(t *testing.T) {
+		cleanup := setupTemplateTest(t)
+		defer cleanup()
+
+		params := CredentialChangeEmailTemplateParams{
+			User: models.User{
+				Name:  "John Doe",
+				Email: "john@example.com",
+			},
+			Change:             constants.CredentialChangePasskey,
+			Detail:             "Work laptop",
+			ChangedAt:          time.Date(2025, 1, 2, 15, 4, 0, 0, time.UTC),
+			FrontendUrl:        "http://localhost:3000",
+			ServiceName:        constants.ServiceName,
+			ServiceDescription: constants.ServiceDescription,
+		}
+
+		html, err := params.ApplyHtmlTemplate()
+
+		require.NoError(t, err)
+		assert.Contains(t, html, "passkey named Work laptop")
+		assert.NotContains(t, html, "password of your")
+	})
+}
@@ -4,24 +4,29 @@ import (
 	"context"
 	"database/sql"
 
+	"github.com/minio/minio-go/v7"
 	"github.com/riverqueue/river"
 	"github.com/riverqueue/river/riverdriver/riverdatabasesql"
 	stripeGo "github.com/stripe/stripe-go/v83"
 	"gorm.io/gorm"
 	"reece.start/internal/configuration"
 	"reece.start/internal/organizations"
+	"reece.start/internal/posthog"
 	"reece.start/internal/stripe"
+	"reece.start/internal/users"
 
 	"github.com/resend/resend-go/v2"
 )
 
 type RiverClientConfig struct {
-	SQLDB        *sql.DB
-	GormDB       *gorm.DB
-	Config       *configuration.Config
-	ResendClient *resend.Client
-	StripeClient *stripeGo.Client
-	StartWorkers bool // If true, starts the worker listener
+	SQLDB         *sql.DB
+	GormDB        *gorm.DB
+	Config        *configuration.Config
+	ResendClient  *resend.Client
+	StripeClient  *stripeGo.Client
+	MinioClient   *minio.Client
+	PostHogClient *posthog.Client
+	StartWorkers  bool // If true, starts the worker listener
 }
 
 // NewRiverClient creates and optionally starts a River client with all workers registered
@@ -59,6 +64,57 @@ func addWorkers(workers *river.Workers, cfg RiverClientConfig) {
 		Config:       cfg.Config,
 		ResendClient: cfg.ResendClient,
 	})
+	river.AddWorker(workers, &users.PasswordResetEmailJobWorker{
+		DB:           cfg.GormDB,
+		Config:       cfg.Config,
+		ResendClient: cfg.ResendClient,
+	})
+	river.AddWorker(workers, &users.MagicLinkEmailJobWorker{
+		DB:           cfg.GormDB,
+		Config:       cfg.Config,
+		ResendClient: cfg.ResendClient,
+	})
+	river.AddWorker(workers, &users.EmailVerificationEmailJobWorker{
+		DB:           cfg.GormDB,
+		Config:       cfg.Config,
+		ResendClient: cfg.ResendClient,
+	})
+	river.AddWorker(workers, &users.LoginLockoutEmailJobWorker{
+		DB:           cfg.GormDB,
+		Config:       cfg.Config,
+		ResendClient: cfg.ResendClient,
+	})
+	river.AddWorker(workers, &users.ImpersonationEmailJobWorker{
+		DB:           cfg.GormDB,
+		Config:       cfg.Config,
+		ResendClient: cfg.ResendClient,
+	})
+	river.AddWorker(workers, &users.CredentialChangeEmailJobWorker{
+		DB:           cfg.GormDB,
+		Config:       cfg.Config,
+		ResendClient: cfg.ResendClient,
+	})
+	river.AddWorker(workers, &users.UserDeletionCancelledEmailJobWorker{
+		DB:           cfg.GormDB,
+		Config:       cfg.Config,
+		ResendClient: cfg.ResendClient,
+	})
+	river.AddWorker(workers, &users.UserDataExportJobWorker{
+		DB:           cfg.GormDB,
+		Config:       cfg.Config,
+		ResendClient: cfg.ResendClient,
+		MinioClient:  cfg.MinioClient,
+	})
+	river.AddWorker(workers, &users.UserDataExportExpiryJobWorker{
+		DB:          cfg.GormDB,
+		MinioClient: cfg.MinioClient,
+	})
+	river.AddWorker(workers, &users.UserDeletionJobWorker{
+		DB:            cfg.GormDB,
+		Config:        cfg.Config,
+		MinioClient:   cfg.MinioClient,
+		PostHogClient: cfg.PostHogClient,
+	})
 	river.AddWorker(workers, &stripe.SnapshotWebhookProcessingJobWorker{
 		DB:           cfg.GormDB,
 		Config:       cfg.Config,
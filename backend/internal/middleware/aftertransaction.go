@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+	"reece.start/internal/utils"
+)
+
+// AfterTransactionMiddleware runs the work handlers defer with utils.AfterTransaction once they return, by which
+// point the transactions they started with the request's context are over
+func AfterTransactionMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx, runAfterTransaction := utils.WithAfterTransaction(c.Request().Context())
+		defer runAfterTransaction()
+
+		c.SetRequest(c.Request().WithContext(ctx))
+		return next(c)
+	}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"reece.start/internal/utils"
+)
+
+func TestAfterTransactionMiddleware(t *testing.T) {
+	t.Run("RunsDeferredWorkAfterHandler", func(t *testing.T) {
+		e := echo.New()
+
+		calls := 0
+		handler := func(c echo.Context) error {
+			tx := &gorm.DB{Statement: &gorm.Statement{Context: c.Request().Context()}}
+			utils.AfterTransaction(tx, func() { calls++ })
+
+			assert.Equal(t, 0, calls)
+			return c.NoContent(http.StatusNoContent)
+		}
+		e.GET("/test", handler, AfterTransactionMiddleware)
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("RunsDeferredWorkWhenHandlerFails", func(t *testing.T) {
+		e := echo.New()
+
+		calls := 0
+		handler := func(c echo.Context) error {
+			tx := &gorm.DB{Statement: &gorm.Statement{Context: c.Request().Context()}}
+			utils.AfterTransaction(tx, func() { calls++ })
+			return echo.ErrBadRequest
+		}
+		e.GET("/test", handler, AfterTransactionMiddleware)
+
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil))
+
+		assert.Equal(t, 1, calls)
+	})
+}
@@ -2,18 +2,54 @@ package middleware
 
 import (
 	"errors"
+	"net/http"
 	"strings"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 	"reece.start/internal/api"
 	"reece.start/internal/authentication"
 	"reece.start/internal/configuration"
 	"reece.start/internal/constants"
+	"reece.start/internal/posthog"
 )
 
-// JWT Authentication middleware
+type jwtAuthOptions struct {
+	// Accept revoked tokens as long as the user is allowed to refresh them
+	allowRefresh bool
+	// Accept personal access tokens and organization API keys in place of a JWT
+	allowLongLivedTokens bool
+	// Accept writes from read-only impersonation sessions
+	allowReadOnlyWrites bool
+}
+
+// JWT Authentication middleware. Personal access tokens and organization API keys are accepted as well.
 func JwtAuthMiddleware(config *configuration.Config) echo.MiddlewareFunc {
+	return jwtAuthMiddleware(config, jwtAuthOptions{allowLongLivedTokens: true})
+}
+
+// JWT Authentication middleware for the token refresh endpoint.
+// Tokens that were revoked are still accepted as long as the user is allowed to refresh them.
+func JwtRefreshAuthMiddleware(config *configuration.Config) echo.MiddlewareFunc {
+	return jwtAuthMiddleware(config, jwtAuthOptions{allowRefresh: true, allowReadOnlyWrites: true})
+}
+
+// JWT Authentication middleware for the endpoint that issues tokens for a signed in user.
+// Read-only impersonation sessions can use it as well, so the admin can switch organizations or stop impersonating.
+func JwtTokenAuthMiddleware(config *configuration.Config) echo.MiddlewareFunc {
+	return jwtAuthMiddleware(config, jwtAuthOptions{allowReadOnlyWrites: true})
+}
+
+// JWT Authentication middleware for endpoints that manage the user's credentials and sessions.
+// Only a signed in user can use these, so personal access tokens and organization API keys are rejected.
+func JwtSessionAuthMiddleware(config *configuration.Config) echo.MiddlewareFunc {
+	return jwtAuthMiddleware(config, jwtAuthOptions{})
+}
+
+func jwtAuthMiddleware(config *configuration.Config, options jwtAuthOptions) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			tokenString, err := getTokenFromRequest(c)
@@ -22,10 +58,62 @@ func JwtAuthMiddleware(config *configuration.Config) echo.MiddlewareFunc {
 				return err
 			}
 
+			if authentication.IsPersonalAccessToken(tokenString) {
+				if !options.allowLongLivedTokens {
+					return api.ErrPersonalAccessTokenNotAllowed
+				}
+
+				claims, err := authenticatePersonalAccessToken(c, tokenString)
+				if err != nil {
+					return err
+				}
+
+				// Tokens are deleted when the user has to sign in again, this also rejects any created while that happened.
+				// Personal access tokens can't be refreshed, they already carry the user's current scopes.
+				err = checkTokenRevocation(c, config, claims)
+				if err != nil && !errors.Is(err, api.ErrTokenRefreshRequired) {
+					return err
+				}
+
+				c.Set("claims", claims)
+				return next(c)
+			}
+
+			if authentication.IsApiKey(tokenString) {
+				if !options.allowLongLivedTokens {
+					return api.ErrApiKeyNotAllowed
+				}
+
+				claims, err := authenticateApiKey(c, tokenString)
+				if err != nil {
+					return err
+				}
+
+				c.Set("claims", claims)
+				return next(c)
+			}
+
 			// Validate the token
 			claims, err := authentication.ValidateJWT(config, tokenString)
 			if err != nil {
-				return api.ErrInvalidToken
+				return err
+			}
+
+			// Reject tokens issued before the user's tokens were revoked
+			err = checkTokenRevocation(c, config, claims)
+			if err != nil && !(options.allowRefresh && errors.Is(err, api.ErrTokenRefreshRequired)) {
+				return err
+			}
+
+			// Reject tokens issued to sessions that have been signed out
+			err = checkSessionRevocation(c, config, claims)
+			if err != nil {
+				return err
+			}
+
+			// Admins impersonating someone read-only can look around, but not change anything
+			if isReadOnly(claims) && !options.allowReadOnlyWrites && !isReadRequest(c) {
+				return api.ErrImpersonationReadOnly
 			}
 
 			// Store claims in context
@@ -35,9 +123,14 @@ func JwtAuthMiddleware(config *configuration.Config) echo.MiddlewareFunc {
 	}
 }
 
-// GetUserIDFromJWT extracts the user ID from the JWT claims in the context
+// GetUserIDFromJWT extracts the user ID from the JWT claims in the context.
+// Organization API keys don't act as a user, so they are rejected.
 func GetUserIDFromJWT(c echo.Context) (uuid.UUID, error) {
 	claims := c.Get("claims").(*authentication.JwtClaims)
+	if claims.ApiKeyId != nil {
+		return uuid.Nil, api.ErrApiKeyNotAllowed
+	}
+
 	userID, err := uuid.Parse(claims.UserId)
 	if err != nil {
 		return uuid.Nil, err
@@ -45,6 +138,40 @@ func GetUserIDFromJWT(c echo.Context) (uuid.UUID, error) {
 	return userID, nil
 }
 
+// GetOrganizationIDFromJWT extracts the active organization ID from the JWT claims in the context
+func GetOrganizationIDFromJWT(c echo.Context) (uuid.UUID, error) {
+	claims := c.Get("claims").(*authentication.JwtClaims)
+	if claims.OrganizationId == nil {
+		return uuid.Nil, errors.New("organization ID is not set")
+	}
+
+	organizationID, err := uuid.Parse(*claims.OrganizationId)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return organizationID, nil
+}
+
+// GetIssuedAtFromJWT returns the issued at time of the JWT in the context
+func GetIssuedAtFromJWT(c echo.Context) *jwt.NumericDate {
+	claims := c.Get("claims").(*authentication.JwtClaims)
+	return claims.IssuedAt
+}
+
+// GetSessionIDFromJWT extracts the session ID from the JWT claims in the context
+func GetSessionIDFromJWT(c echo.Context) (uuid.UUID, error) {
+	claims := c.Get("claims").(*authentication.JwtClaims)
+	if claims.ID == "" {
+		return uuid.Nil, errors.New("session ID is not set")
+	}
+
+	sessionID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return sessionID, nil
+}
+
 func GetRoleFromJWT(c echo.Context) (constants.UserRole, error) {
 	claims := c.Get("claims").(*authentication.JwtClaims)
 	if claims.Role == nil {
@@ -75,6 +202,155 @@ func GetImpersonatingUserIDFromJWT(c echo.Context) (uuid.UUID, error) {
 	return impersonatingUserID, nil
 }
 
+// GetImpersonationSessionIDFromJWT extracts the ID of the impersonation session the JWT was issued for
+func GetImpersonationSessionIDFromJWT(c echo.Context) (uuid.UUID, error) {
+	claims := c.Get("claims").(*authentication.JwtClaims)
+
+	if claims.ImpersonationSessionId == nil {
+		return uuid.Nil, errors.New("impersonation session ID is not set")
+	}
+
+	impersonationSessionID, err := uuid.Parse(*claims.ImpersonationSessionId)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return impersonationSessionID, nil
+}
+
+// IsApiKeyRequest checks if the request was authenticated with an organization API key
+func IsApiKeyRequest(c echo.Context) bool {
+	claims, ok := c.Get("claims").(*authentication.JwtClaims)
+	return ok && claims != nil && claims.ApiKeyId != nil
+}
+
+// IsPersonalAccessTokenRequest checks if the request was authenticated with a personal access token
+func IsPersonalAccessTokenRequest(c echo.Context) bool {
+	claims, ok := c.Get("claims").(*authentication.JwtClaims)
+	return ok && claims != nil && claims.PersonalAccessTokenId != nil
+}
+
+// GetApiKeyIDFromJWT extracts the ID of the organization API key the request was authenticated with
+func GetApiKeyIDFromJWT(c echo.Context) (uuid.UUID, error) {
+	claims := c.Get("claims").(*authentication.JwtClaims)
+	if claims.ApiKeyId == nil {
+		return uuid.Nil, errors.New("api key ID is not set")
+	}
+
+	apiKeyID, err := uuid.Parse(*claims.ApiKeyId)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return apiKeyID, nil
+}
+
+// GetPrincipalFromJWT identifies who is making the request for logs and analytics, either a user or an organization
+// API key. Returns an empty string for unauthenticated requests.
+func GetPrincipalFromJWT(c echo.Context) string {
+	claims, ok := c.Get("claims").(*authentication.JwtClaims)
+	if !ok || claims == nil {
+		return ""
+	}
+	return claims.Principal()
+}
+
+func authenticatePersonalAccessToken(c echo.Context, tokenString string) (*authentication.JwtClaims, error) {
+	// Personal access tokens are looked up in the database on every request, so there is nothing to check them against without it
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok || db == nil {
+		return nil, api.ErrInvalidToken
+	}
+
+	return authentication.AuthenticatePersonalAccessToken(db.WithContext(c.Request().Context()), tokenString, c.RealIP())
+}
+
+func authenticateApiKey(c echo.Context, tokenString string) (*authentication.JwtClaims, error) {
+	// API keys are looked up in the database on every request, so there is nothing to check them against without it
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok || db == nil {
+		return nil, api.ErrInvalidToken
+	}
+
+	claims, recorded, err := authentication.AuthenticateApiKey(db.WithContext(c.Request().Context()), tokenString, c.RealIP())
+	if err != nil {
+		return nil, err
+	}
+
+	// Usage is only recorded once per interval, which also keeps the analytics events to a reasonable volume
+	if posthogClient, ok := c.Get("posthogClient").(*posthog.Client); ok && posthogClient != nil && recorded {
+		posthogClient.Capture(
+			claims.Principal(),
+			"api key used",
+			map[string]any{
+				"api_key_id":      *claims.ApiKeyId,
+				"organization_id": *claims.OrganizationId,
+				"principal_type":  "api-key",
+			},
+		)
+	}
+
+	return claims, nil
+}
+
+func checkTokenRevocation(c echo.Context, config *configuration.Config, claims *authentication.JwtClaims) error {
+	// The database is provided by DependencyInjectionMiddleware, without it there is no revocation state to check against
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok || db == nil {
+		return nil
+	}
+
+	userID, err := uuid.Parse(claims.UserId)
+	if err != nil {
+		return api.ErrInvalidToken
+	}
+
+	revocation, err := authentication.GetTokenRevocation(db, userID, time.Duration(config.JwtRevocationCacheTtl)*time.Second)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// The user no longer exists
+			return api.ErrReauthenticationRequired
+		}
+		return err
+	}
+
+	return authentication.CheckTokenRevocation(claims, revocation)
+}
+
+func checkSessionRevocation(c echo.Context, config *configuration.Config, claims *authentication.JwtClaims) error {
+	// Tokens issued before sessions were tracked don't have a session ID, and are only valid until they expire
+	if claims.ID == "" {
+		return nil
+	}
+
+	db, ok := c.Get("db").(*gorm.DB)
+	if !ok || db == nil {
+		return nil
+	}
+
+	sessionID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return api.ErrInvalidToken
+	}
+
+	revoked, err := authentication.IsSessionRevoked(db, sessionID, time.Duration(config.JwtRevocationCacheTtl)*time.Second)
+	if err != nil {
+		return err
+	}
+
+	if revoked {
+		return api.ErrReauthenticationRequired
+	}
+	return nil
+}
+
+func isReadOnly(claims *authentication.JwtClaims) bool {
+	return claims.IsReadOnly != nil && *claims.IsReadOnly
+}
+
+func isReadRequest(c echo.Context) bool {
+	method := c.Request().Method
+	return method == http.MethodGet || method == http.MethodHead
+}
+
 func getTokenFromRequest(c echo.Context) (string, error) {
 	tokenString, err := getTokenFromCookie(c)
 	if err == nil && tokenString != "" {
@@ -173,7 +173,43 @@ func TestJwtAuthMiddleware(t *testing.T) {
 		var apiErr api.ApiError
 		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
 		require.NoError(t, err)
-		assert.Equal(t, "invalid token", apiErr.Message)
+		assert.Equal(t, api.ErrTokenMalformed.Error(), apiErr.Message)
+		assert.Equal(t, api.ErrorCodeTokenMalformed, apiErr.Code)
+	})
+
+	t.Run("WrongAudience", func(t *testing.T) {
+		config := testconfig.CreateTestConfig()
+		e := echo.New()
+
+		// Add error handling middleware
+		e.Use(ErrorHandlingMiddleware)
+
+		handler := func(c echo.Context) error {
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"success": true,
+			})
+		}
+
+		middleware := JwtAuthMiddleware(config)
+		e.GET("/test", handler, middleware)
+
+		// Token minted for another environment
+		stagingConfig := testconfig.CreateTestConfig()
+		stagingConfig.JwtAudience = "https://staging.example.com"
+		token, err := authentication.CreateJWT(stagingConfig, authentication.JwtOptions{UserId: uuid.New()})
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		var apiErr api.ApiError
+		err = json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrorCodeTokenInvalidAudience, apiErr.Code)
 	})
 
 	t.Run("MissingToken", func(t *testing.T) {
@@ -246,6 +282,65 @@ func TestJwtAuthMiddleware(t *testing.T) {
 	})
 }
 
+func TestJwtAuthMiddlewareReadOnlyImpersonation(t *testing.T) {
+	config := testconfig.CreateTestConfig()
+
+	isImpersonating := true
+	isReadOnly := true
+	impersonatingUserID := uuid.New()
+	token, err := authentication.CreateJWT(config, authentication.JwtOptions{
+		UserId:              uuid.New(),
+		IsImpersonating:     &isImpersonating,
+		ImpersonatingUserId: &impersonatingUserID,
+		IsReadOnly:          &isReadOnly,
+	})
+	require.NoError(t, err)
+
+	setup := func() *echo.Echo {
+		e := echo.New()
+		e.Use(ErrorHandlingMiddleware)
+
+		handler := func(c echo.Context) error {
+			return c.NoContent(http.StatusNoContent)
+		}
+
+		e.GET("/test", handler, JwtAuthMiddleware(config))
+		e.POST("/test", handler, JwtAuthMiddleware(config))
+		e.POST("/token", handler, JwtTokenAuthMiddleware(config))
+		e.POST("/refresh", handler, JwtRefreshAuthMiddleware(config))
+		return e
+	}
+
+	request := func(e *echo.Echo, method string, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("ReadsAllowed", func(t *testing.T) {
+		rec := request(setup(), http.MethodGet, "/test")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	})
+
+	t.Run("WritesBlocked", func(t *testing.T) {
+		rec := request(setup(), http.MethodPost, "/test")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrorCodeImpersonationReadOnly, apiErr.Code)
+	})
+
+	t.Run("TokenEndpointsAllowed", func(t *testing.T) {
+		e := setup()
+		assert.Equal(t, http.StatusNoContent, request(e, http.MethodPost, "/token").Code)
+		assert.Equal(t, http.StatusNoContent, request(e, http.MethodPost, "/refresh").Code)
+	})
+}
+
 func TestGetUserIDFromJWT(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		config := testconfig.CreateTestConfig()
@@ -285,6 +380,23 @@ func TestGetUserIDFromJWT(t *testing.T) {
 		assert.Equal(t, uuid.Nil, userID)
 	})
 
+	t.Run("ApiKey", func(t *testing.T) {
+		e := echo.New()
+
+		// Claims of an organization API key have no user
+		apiKeyID := uuid.New().String()
+		claims := &authentication.JwtClaims{
+			ApiKeyId: &apiKeyID,
+		}
+
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/test", nil), httptest.NewRecorder())
+		c.Set("claims", claims)
+
+		userID, err := GetUserIDFromJWT(c)
+		require.ErrorIs(t, err, api.ErrApiKeyNotAllowed)
+		assert.Equal(t, uuid.Nil, userID)
+	})
+
 	t.Run("MissingClaims", func(t *testing.T) {
 		e := echo.New()
 		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/test", nil), httptest.NewRecorder())
@@ -296,6 +408,45 @@ func TestGetUserIDFromJWT(t *testing.T) {
 	})
 }
 
+func TestGetSessionIDFromJWT(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		config := testconfig.CreateTestConfig()
+		e := echo.New()
+
+		testSessionID := uuid.New()
+		token, err := authentication.CreateJWT(config, authentication.JwtOptions{
+			UserId:    uuid.New(),
+			SessionId: &testSessionID,
+		})
+		require.NoError(t, err)
+
+		claims, err := authentication.ValidateJWT(config, token)
+		require.NoError(t, err)
+
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/test", nil), httptest.NewRecorder())
+		c.Set("claims", claims)
+
+		sessionID, err := GetSessionIDFromJWT(c)
+		require.NoError(t, err)
+		assert.Equal(t, testSessionID, sessionID)
+	})
+
+	t.Run("MissingSessionID", func(t *testing.T) {
+		e := echo.New()
+
+		claims := &authentication.JwtClaims{
+			UserId: uuid.New().String(),
+		}
+
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/test", nil), httptest.NewRecorder())
+		c.Set("claims", claims)
+
+		sessionID, err := GetSessionIDFromJWT(c)
+		require.Error(t, err)
+		assert.Equal(t, uuid.Nil, sessionID)
+	})
+}
+
 func TestGetRoleFromJWT(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		config := testconfig.CreateTestConfig()
@@ -446,7 +597,83 @@ func TestGetImpersonatingUserIDFromJWT(t *testing.T) {
 	})
 }
 
+func TestGetImpersonationSessionIDFromJWT(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		config := testconfig.CreateTestConfig()
+		e := echo.New()
+
+		impersonationSessionID := uuid.New()
+		token, err := authentication.CreateJWT(config, authentication.JwtOptions{
+			UserId:                 uuid.New(),
+			ImpersonationSessionId: &impersonationSessionID,
+		})
+		require.NoError(t, err)
+
+		claims, err := authentication.ValidateJWT(config, token)
+		require.NoError(t, err)
+
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/test", nil), httptest.NewRecorder())
+		c.Set("claims", claims)
+
+		sessionID, err := GetImpersonationSessionIDFromJWT(c)
+		require.NoError(t, err)
+		assert.Equal(t, impersonationSessionID, sessionID)
+	})
+
+	t.Run("MissingImpersonationSessionID", func(t *testing.T) {
+		config := testconfig.CreateTestConfig()
+		e := echo.New()
+
+		token, err := authentication.CreateJWT(config, authentication.JwtOptions{
+			UserId: uuid.New(),
+		})
+		require.NoError(t, err)
+
+		claims, err := authentication.ValidateJWT(config, token)
+		require.NoError(t, err)
+
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/test", nil), httptest.NewRecorder())
+		c.Set("claims", claims)
+
+		sessionID, err := GetImpersonationSessionIDFromJWT(c)
+		require.Error(t, err)
+		assert.Equal(t, uuid.Nil, sessionID)
+	})
+}
+
 // Test private functions by testing through public API
+func TestGetPrincipalFromJWT(t *testing.T) {
+	t.Run("User", func(t *testing.T) {
+		e := echo.New()
+		userID := uuid.New().String()
+
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/test", nil), httptest.NewRecorder())
+		c.Set("claims", &authentication.JwtClaims{UserId: userID})
+
+		assert.Equal(t, "user:"+userID, GetPrincipalFromJWT(c))
+		assert.False(t, IsApiKeyRequest(c))
+	})
+
+	t.Run("ApiKey", func(t *testing.T) {
+		e := echo.New()
+		apiKeyID := uuid.New().String()
+
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/test", nil), httptest.NewRecorder())
+		c.Set("claims", &authentication.JwtClaims{ApiKeyId: &apiKeyID})
+
+		assert.Equal(t, "api-key:"+apiKeyID, GetPrincipalFromJWT(c))
+		assert.True(t, IsApiKeyRequest(c))
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		e := echo.New()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/test", nil), httptest.NewRecorder())
+
+		assert.Equal(t, "", GetPrincipalFromJWT(c))
+		assert.False(t, IsApiKeyRequest(c))
+	})
+}
+
 func TestGetTokenFromRequest(t *testing.T) {
 	t.Run("CookieFirst", func(t *testing.T) {
 		config := testconfig.CreateTestConfig()
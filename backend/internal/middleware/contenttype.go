@@ -4,6 +4,7 @@ import (
 	"log/slog"
 	"net/http"
 	"slices"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 	"reece.start/internal/api"
@@ -11,9 +12,19 @@ import (
 
 var allowedContentTypes = []string{"application/json", "application/json; charset=utf-8"}
 
+// Paths used by third party clients that don't send a JSON Content-Type. Well-known endpoints are fetched without one,
+// SAML identity providers post responses through the browser as a form, and SCIM clients send application/scim+json.
+var exemptPathPrefixes = []string{"/.well-known/", "/saml/", "/scim/"}
+
 // Content-Type middleware
 func ContentTypeMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
+		for _, prefix := range exemptPathPrefixes {
+			if strings.HasPrefix(c.Request().URL.Path, prefix) {
+				return next(c)
+			}
+		}
+
 		if c.Request().Header.Get("Content-Type") == "" {
 			return c.JSON(http.StatusUnsupportedMediaType, api.ApiError{
 				Message: "missing_content_type",
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/labstack/echo/v4"
@@ -238,4 +239,60 @@ func TestContentTypeMiddleware(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, "missing_content_type", apiErr.Message)
 	})
+
+	t.Run("WellKnownPathWithoutContentType", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"keys": []interface{}{},
+			})
+		}
+
+		middleware := ContentTypeMiddleware
+		e.GET("/.well-known/jwks.json", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+	t.Run("SamlFormPost", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return c.NoContent(http.StatusSeeOther)
+		}
+
+		middleware := ContentTypeMiddleware
+		e.POST("/saml/:id/acs", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodPost, "/saml/org/acs", strings.NewReader("SAMLResponse=response"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusSeeOther, rec.Code)
+	})
+	t.Run("ScimJSON", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return c.NoContent(http.StatusCreated)
+		}
+
+		middleware := ContentTypeMiddleware
+		e.POST("/scim/v2/:id/Users", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodPost, "/scim/v2/org/Users", strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/scim+json")
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	})
 }
@@ -10,6 +10,7 @@ import (
 	stripeGo "github.com/stripe/stripe-go/v83"
 	"gorm.io/gorm"
 	"reece.start/internal/configuration"
+	"reece.start/internal/dns"
 	"reece.start/internal/posthog"
 )
 
@@ -21,6 +22,7 @@ type AppDependencies struct {
 	ResendClient  *resend.Client
 	StripeClient  *stripeGo.Client
 	PostHogClient *posthog.Client
+	DnsResolver   dns.Resolver
 }
 
 // Middleware to inject config and database into context
@@ -34,6 +36,7 @@ func DependencyInjectionMiddleware(dependencies AppDependencies) echo.Middleware
 			c.Set("resendClient", dependencies.ResendClient)
 			c.Set("stripeClient", dependencies.StripeClient)
 			c.Set("posthogClient", dependencies.PostHogClient)
+			c.Set("dnsResolver", dependencies.DnsResolver)
 			return next(c)
 		}
 	}
@@ -67,3 +70,7 @@ func GetStripeClient(c echo.Context) *stripeGo.Client {
 func GetPostHogClient(c echo.Context) *posthog.Client {
 	return c.Get("posthogClient").(*posthog.Client)
 }
+
+func GetDnsResolver(c echo.Context) dns.Resolver {
+	return c.Get("dnsResolver").(dns.Resolver)
+}
@@ -21,6 +21,26 @@ func ErrorHandlingMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 		}
 
 		// Handle specific business logic errors first
+		var validationErr *api.ValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusBadRequest, api.ApiError{
+				Message: validationErr.Error(),
+				Code:    validationErr.Code,
+				Errors:  validationErr.Errors,
+			})
+			return nil
+		}
+
+		var ssoRequiredErr *api.SsoRequiredError
+		if errors.As(err, &ssoRequiredErr) {
+			c.JSON(http.StatusForbidden, api.ApiError{
+				Message: ssoRequiredErr.Error(),
+				Code:    api.ErrorCodeSsoRequired,
+				Meta:    &api.ApiErrorMeta{OrganizationID: ssoRequiredErr.OrganizationID.String()},
+			})
+			return nil
+		}
+
 		if errors.Is(err, api.ErrForbiddenNoAdminAccess) {
 			return respondWithError(c, http.StatusForbidden, err)
 		}
@@ -45,6 +65,270 @@ func ErrorHandlingMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 			return respondWithError(c, http.StatusUnauthorized, err)
 		}
 
+		if errors.Is(err, api.ErrTokenExpired) {
+			return respondWithErrorCode(c, http.StatusUnauthorized, err, api.ErrorCodeTokenExpired)
+		}
+
+		if errors.Is(err, api.ErrTokenMalformed) {
+			return respondWithErrorCode(c, http.StatusUnauthorized, err, api.ErrorCodeTokenMalformed)
+		}
+
+		if errors.Is(err, api.ErrTokenInvalidIssuer) {
+			return respondWithErrorCode(c, http.StatusUnauthorized, err, api.ErrorCodeTokenInvalidIssuer)
+		}
+
+		if errors.Is(err, api.ErrTokenInvalidAudience) {
+			return respondWithErrorCode(c, http.StatusUnauthorized, err, api.ErrorCodeTokenInvalidAudience)
+		}
+
+		if errors.Is(err, api.ErrTokenRefreshRequired) {
+			return respondWithErrorCode(c, http.StatusUnauthorized, err, api.ErrorCodeTokenRefreshRequired)
+		}
+
+		if errors.Is(err, api.ErrReauthenticationRequired) {
+			return respondWithErrorCode(c, http.StatusUnauthorized, err, api.ErrorCodeReauthenticationRequired)
+		}
+
+		if errors.Is(err, api.ErrInvalidRefreshToken) {
+			return respondWithErrorCode(c, http.StatusUnauthorized, err, api.ErrorCodeReauthenticationRequired)
+		}
+
+		if errors.Is(err, api.ErrMfaInvalidCode) {
+			return respondWithError(c, http.StatusUnauthorized, err)
+		}
+
+		if errors.Is(err, api.ErrMfaChallengeLocked) {
+			return respondWithError(c, http.StatusUnauthorized, err)
+		}
+
+		if errors.Is(err, api.ErrMfaAlreadyEnabled) {
+			return respondWithError(c, http.StatusConflict, err)
+		}
+
+		if errors.Is(err, api.ErrMfaNotEnabled) {
+			return respondWithError(c, http.StatusBadRequest, err)
+		}
+
+		if errors.Is(err, api.ErrMfaEnrollmentNotStarted) {
+			return respondWithError(c, http.StatusBadRequest, err)
+		}
+
+		if errors.Is(err, api.ErrPasskeyChallengeInvalid) {
+			return respondWithError(c, http.StatusBadRequest, err)
+		}
+
+		if errors.Is(err, api.ErrPasskeyVerificationFailed) {
+			return respondWithError(c, http.StatusUnauthorized, err)
+		}
+
+		if errors.Is(err, api.ErrInvalidPasswordResetToken) {
+			return respondWithError(c, http.StatusBadRequest, err)
+		}
+
+		if errors.Is(err, api.ErrInvalidEmailVerificationToken) {
+			return respondWithError(c, http.StatusBadRequest, err)
+		}
+
+		if errors.Is(err, api.ErrEmailNotVerified) {
+			return respondWithErrorCode(c, http.StatusForbidden, err, api.ErrorCodeEmailNotVerified)
+		}
+
+		if errors.Is(err, api.ErrEmailAlreadyVerified) {
+			return respondWithError(c, http.StatusConflict, err)
+		}
+
+		if errors.Is(err, api.ErrMagicLinkLoginDisabled) {
+			return respondWithError(c, http.StatusForbidden, err)
+		}
+
+		if errors.Is(err, api.ErrInvalidMagicLinkToken) {
+			return respondWithError(c, http.StatusUnauthorized, err)
+		}
+
+		if errors.Is(err, api.ErrOAuthStateInvalid) {
+			return respondWithError(c, http.StatusBadRequest, err)
+		}
+
+		if errors.Is(err, api.ErrOAuthRedirectUriNotAllowed) {
+			return respondWithError(c, http.StatusBadRequest, err)
+		}
+
+		if errors.Is(err, api.ErrOAuthProviderNotFound) {
+			return respondWithError(c, http.StatusNotFound, err)
+		}
+
+		if errors.Is(err, api.ErrUserIdentityNotFound) {
+			return respondWithError(c, http.StatusNotFound, err)
+		}
+
+		if errors.Is(err, api.ErrUserIdentityLinked) {
+			return respondWithError(c, http.StatusConflict, err)
+		}
+
+		if errors.Is(err, api.ErrLastLoginMethod) {
+			return respondWithError(c, http.StatusConflict, err)
+		}
+
+		if errors.Is(err, api.ErrPasswordAlreadySet) {
+			return respondWithError(c, http.StatusConflict, err)
+		}
+
+		if errors.Is(err, api.ErrPersonalAccessTokenNotAllowed) {
+			return respondWithError(c, http.StatusForbidden, err)
+		}
+
+		if errors.Is(err, api.ErrPersonalAccessTokenScopeDenied) {
+			return respondWithError(c, http.StatusForbidden, err)
+		}
+
+		if errors.Is(err, api.ErrTooManyLoginAttempts) {
+			return respondWithErrorCode(c, http.StatusTooManyRequests, err, api.ErrorCodeTooManyLoginAttempts)
+		}
+
+		if errors.Is(err, api.ErrTooManyEmailRequests) {
+			return respondWithError(c, http.StatusTooManyRequests, err)
+		}
+
+		if errors.Is(err, api.ErrLoginLockoutNotFound) {
+			return respondWithError(c, http.StatusNotFound, err)
+		}
+
+		if errors.Is(err, api.ErrUserSessionNotFound) {
+			return respondWithError(c, http.StatusNotFound, err)
+		}
+
+		if errors.Is(err, api.ErrAccessTokenNotFound) {
+			return respondWithError(c, http.StatusNotFound, err)
+		}
+
+		if errors.Is(err, api.ErrApiKeyNotAllowed) {
+			return respondWithError(c, http.StatusForbidden, err)
+		}
+
+		if errors.Is(err, api.ErrApiKeyScopeDenied) {
+			return respondWithError(c, http.StatusForbidden, err)
+		}
+
+		if errors.Is(err, api.ErrApiKeyNotFound) {
+			return respondWithError(c, http.StatusNotFound, err)
+		}
+
+		if errors.Is(err, api.ErrSsoRequired) {
+			return respondWithErrorCode(c, http.StatusForbidden, err, api.ErrorCodeSsoRequired)
+		}
+
+		if errors.Is(err, api.ErrSamlLoginInvalid) {
+			return respondWithError(c, http.StatusBadRequest, err)
+		}
+
+		if errors.Is(err, api.ErrSamlResponseInvalid) {
+			return respondWithError(c, http.StatusUnauthorized, err)
+		}
+
+		if errors.Is(err, api.ErrSamlMetadataInvalid) {
+			return respondWithError(c, http.StatusBadRequest, err)
+		}
+
+		if errors.Is(err, api.ErrSamlCertificateInvalid) {
+			return respondWithError(c, http.StatusBadRequest, err)
+		}
+
+		if errors.Is(err, api.ErrSamlEmailInUse) {
+			return respondWithError(c, http.StatusConflict, err)
+		}
+
+		if errors.Is(err, api.ErrSamlEmailDomainNotVerified) {
+			return respondWithError(c, http.StatusForbidden, err)
+		}
+
+		if errors.Is(err, api.ErrSamlConnectionNotFound) {
+			return respondWithError(c, http.StatusNotFound, err)
+		}
+
+		if errors.Is(err, api.ErrDomainInvalid) {
+			return respondWithError(c, http.StatusBadRequest, err)
+		}
+
+		if errors.Is(err, api.ErrDomainAlreadyClaimed) {
+			return respondWithError(c, http.StatusConflict, err)
+		}
+
+		if errors.Is(err, api.ErrDomainAlreadyVerified) {
+			return respondWithError(c, http.StatusConflict, err)
+		}
+
+		if errors.Is(err, api.ErrDomainVerificationFailed) {
+			return respondWithError(c, http.StatusBadRequest, err)
+		}
+
+		if errors.Is(err, api.ErrOrganizationNotJoinable) {
+			return respondWithError(c, http.StatusForbidden, err)
+		}
+
+		if errors.Is(err, api.ErrJoinApprovalRequired) {
+			return respondWithErrorCode(c, http.StatusForbidden, err, api.ErrorCodeJoinApprovalRequired)
+		}
+
+		if errors.Is(err, api.ErrJoinApprovalNotRequired) {
+			return respondWithError(c, http.StatusConflict, err)
+		}
+
+		if errors.Is(err, api.ErrJoinRequestAlreadyExists) {
+			return respondWithError(c, http.StatusConflict, err)
+		}
+
+		if errors.Is(err, api.ErrJoinRequestNotPending) {
+			return respondWithError(c, http.StatusConflict, err)
+		}
+
+		if errors.Is(err, api.ErrForbiddenImpersonationNotAllowed) {
+			return respondWithError(c, http.StatusForbidden, err)
+		}
+
+		if errors.Is(err, api.ErrImpersonationReasonRequired) {
+			return respondWithError(c, http.StatusBadRequest, err)
+		}
+
+		if errors.Is(err, api.ErrImpersonationReadOnly) {
+			return respondWithErrorCode(c, http.StatusForbidden, err, api.ErrorCodeImpersonationReadOnly)
+		}
+
+		if errors.Is(err, api.ErrImpersonationEnded) {
+			return respondWithErrorCode(c, http.StatusUnauthorized, err, api.ErrorCodeImpersonationEnded)
+		}
+
+		if errors.Is(err, api.ErrSoleOrganizationAdmin) {
+			return respondWithError(c, http.StatusConflict, err)
+		}
+
+		if errors.Is(err, api.ErrCurrentPasswordRequired) {
+			return respondWithErrorCode(c, http.StatusForbidden, err, api.ErrorCodeCurrentPasswordRequired)
+		}
+
+		if errors.Is(err, api.ErrCurrentPasswordInvalid) {
+			return respondWithError(c, http.StatusForbidden, err)
+		}
+
+		if errors.Is(err, api.ErrDomainNotFound) {
+			return respondWithError(c, http.StatusNotFound, err)
+		}
+
+		if errors.Is(err, api.ErrJoinRequestNotFound) {
+			return respondWithError(c, http.StatusNotFound, err)
+		}
+
+		if errors.Is(err, api.ErrUserDataExportNotFound) {
+			return respondWithError(c, http.StatusNotFound, err)
+		}
+
+		if errors.Is(err, api.ErrPasskeyNotFound) {
+			return respondWithError(c, http.StatusNotFound, err)
+		}
+
+		if errors.Is(err, api.ErrPasskeyAlreadyExists) {
+			return respondWithError(c, http.StatusConflict, err)
+		}
+
 		if errors.Is(err, api.ErrMembershipNotFound) {
 			return respondWithError(c, http.StatusNotFound, err)
 		}
@@ -82,6 +366,38 @@ func ErrorHandlingMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 			return respondWithError(c, http.StatusBadRequest, err)
 		}
 
+		if errors.Is(err, api.ErrInvalidPasskeyID) {
+			return respondWithError(c, http.StatusBadRequest, err)
+		}
+
+		if errors.Is(err, api.ErrInvalidUserIdentityID) {
+			return respondWithError(c, http.StatusBadRequest, err)
+		}
+
+		if errors.Is(err, api.ErrInvalidLoginLockoutID) {
+			return respondWithError(c, http.StatusBadRequest, err)
+		}
+
+		if errors.Is(err, api.ErrInvalidUserSessionID) {
+			return respondWithError(c, http.StatusBadRequest, err)
+		}
+
+		if errors.Is(err, api.ErrInvalidAccessTokenID) {
+			return respondWithError(c, http.StatusBadRequest, err)
+		}
+
+		if errors.Is(err, api.ErrInvalidApiKeyID) {
+			return respondWithError(c, http.StatusBadRequest, err)
+		}
+
+		if errors.Is(err, api.ErrInvalidDomainID) {
+			return respondWithError(c, http.StatusBadRequest, err)
+		}
+
+		if errors.Is(err, api.ErrInvalidJoinRequestID) {
+			return respondWithError(c, http.StatusBadRequest, err)
+		}
+
 		if errors.Is(err, api.ErrStripeWebhookSecretNotConfigured) {
 			return respondWithError(c, http.StatusBadRequest, err)
 		}
@@ -161,3 +477,11 @@ func respondWithError(c echo.Context, statusCode int, err error) error {
 	})
 	return nil
 }
+
+func respondWithErrorCode(c echo.Context, statusCode int, err error, code string) error {
+	c.JSON(statusCode, api.ApiError{
+		Message: err.Error(),
+		Code:    code,
+	})
+	return nil
+}
@@ -3,10 +3,12 @@ package middleware
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -106,6 +108,1662 @@ func TestErrorHandlingMiddleware(t *testing.T) {
 		assert.Equal(t, api.ErrUnauthorizedInvalidLogin.Error(), apiErr.Message)
 	})
 
+	t.Run("ErrTokenExpired", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrTokenExpired
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrTokenExpired.Error(), apiErr.Message)
+		assert.Equal(t, api.ErrorCodeTokenExpired, apiErr.Code)
+	})
+
+	t.Run("ErrTokenMalformed", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrTokenMalformed
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrTokenMalformed.Error(), apiErr.Message)
+		assert.Equal(t, api.ErrorCodeTokenMalformed, apiErr.Code)
+	})
+
+	t.Run("ErrTokenInvalidIssuer", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrTokenInvalidIssuer
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrTokenInvalidIssuer.Error(), apiErr.Message)
+		assert.Equal(t, api.ErrorCodeTokenInvalidIssuer, apiErr.Code)
+	})
+
+	t.Run("ErrTokenInvalidAudience", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrTokenInvalidAudience
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrTokenInvalidAudience.Error(), apiErr.Message)
+		assert.Equal(t, api.ErrorCodeTokenInvalidAudience, apiErr.Code)
+	})
+
+	t.Run("ErrMfaInvalidCode", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrMfaInvalidCode
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrMfaInvalidCode.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrMfaChallengeLocked", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrMfaChallengeLocked
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrMfaChallengeLocked.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrMfaAlreadyEnabled", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrMfaAlreadyEnabled
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrMfaAlreadyEnabled.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrMfaNotEnabled", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrMfaNotEnabled
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrMfaNotEnabled.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrMfaEnrollmentNotStarted", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrMfaEnrollmentNotStarted
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrMfaEnrollmentNotStarted.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrPasskeyChallengeInvalid", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrPasskeyChallengeInvalid
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrPasskeyChallengeInvalid.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrPasskeyVerificationFailed", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrPasskeyVerificationFailed
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrPasskeyVerificationFailed.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrPasskeyNotFound", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrPasskeyNotFound
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrPasskeyNotFound.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrPasskeyAlreadyExists", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrPasskeyAlreadyExists
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrPasskeyAlreadyExists.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrInvalidPasskeyID", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrInvalidPasskeyID
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrInvalidPasskeyID.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrInvalidPasswordResetToken", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrInvalidPasswordResetToken
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrInvalidPasswordResetToken.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrInvalidEmailVerificationToken", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrInvalidEmailVerificationToken
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrInvalidEmailVerificationToken.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrEmailNotVerified", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrEmailNotVerified
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrEmailNotVerified.Error(), apiErr.Message)
+		assert.Equal(t, api.ErrorCodeEmailNotVerified, apiErr.Code)
+	})
+
+	t.Run("ErrEmailAlreadyVerified", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrEmailAlreadyVerified
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrEmailAlreadyVerified.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrMagicLinkLoginDisabled", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrMagicLinkLoginDisabled
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrMagicLinkLoginDisabled.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrInvalidMagicLinkToken", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrInvalidMagicLinkToken
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrInvalidMagicLinkToken.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrOAuthProviderNotFound", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrOAuthProviderNotFound
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrOAuthProviderNotFound.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrOAuthStateInvalid", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrOAuthStateInvalid
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrOAuthStateInvalid.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrOAuthRedirectUriNotAllowed", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrOAuthRedirectUriNotAllowed
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrOAuthRedirectUriNotAllowed.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrUserIdentityNotFound", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrUserIdentityNotFound
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrUserIdentityNotFound.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrUserIdentityLinked", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrUserIdentityLinked
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrUserIdentityLinked.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrLastLoginMethod", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrLastLoginMethod
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrLastLoginMethod.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrPasswordAlreadySet", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrPasswordAlreadySet
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrPasswordAlreadySet.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrInvalidUserIdentityID", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrInvalidUserIdentityID
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrInvalidUserIdentityID.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrTooManyLoginAttempts", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrTooManyLoginAttempts
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrTooManyLoginAttempts.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrTooManyEmailRequests", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrTooManyEmailRequests
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrTooManyEmailRequests.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrSsoRequired", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrSsoRequired
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrSsoRequired.Error(), apiErr.Message)
+		assert.Equal(t, api.ErrorCodeSsoRequired, apiErr.Code)
+	})
+
+	t.Run("SsoRequiredError", func(t *testing.T) {
+		e := echo.New()
+		organizationID := uuid.New()
+
+		handler := func(c echo.Context) error {
+			return &api.SsoRequiredError{OrganizationID: organizationID}
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrSsoRequired.Error(), apiErr.Message)
+		assert.Equal(t, api.ErrorCodeSsoRequired, apiErr.Code)
+		require.NotNil(t, apiErr.Meta)
+		assert.Equal(t, organizationID.String(), apiErr.Meta.OrganizationID)
+	})
+
+	t.Run("ErrForbiddenImpersonationNotAllowed", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrForbiddenImpersonationNotAllowed
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrForbiddenImpersonationNotAllowed.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrImpersonationReasonRequired", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrImpersonationReasonRequired
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrImpersonationReasonRequired.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrImpersonationReadOnly", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrImpersonationReadOnly
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrImpersonationReadOnly.Error(), apiErr.Message)
+		assert.Equal(t, api.ErrorCodeImpersonationReadOnly, apiErr.Code)
+	})
+
+	t.Run("ErrImpersonationEnded", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrImpersonationEnded
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrImpersonationEnded.Error(), apiErr.Message)
+		assert.Equal(t, api.ErrorCodeImpersonationEnded, apiErr.Code)
+	})
+
+	t.Run("ErrSoleOrganizationAdmin", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrSoleOrganizationAdmin
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrSoleOrganizationAdmin.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrCurrentPasswordRequired", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrCurrentPasswordRequired
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrCurrentPasswordRequired.Error(), apiErr.Message)
+		assert.Equal(t, api.ErrorCodeCurrentPasswordRequired, apiErr.Code)
+	})
+
+	t.Run("ErrCurrentPasswordInvalid", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrCurrentPasswordInvalid
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrCurrentPasswordInvalid.Error(), apiErr.Message)
+	})
+
+	t.Run("ValidationError", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return fmt.Errorf("wrapped: %w", &api.ValidationError{
+				Err:  api.ErrPasswordPolicyViolation,
+				Code: api.ErrorCodePasswordPolicyViolation,
+				Errors: []api.FieldError{
+					{Field: "password", Rule: "min_length", Message: "must be at least 8 characters"},
+					{Field: "password", Rule: "common", Message: "is too common"},
+				},
+			})
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrPasswordPolicyViolation.Error(), apiErr.Message)
+		assert.Equal(t, api.ErrorCodePasswordPolicyViolation, apiErr.Code)
+		require.Len(t, apiErr.Errors, 2)
+		assert.Equal(t, "min_length", apiErr.Errors[0].Rule)
+		assert.Equal(t, "common", apiErr.Errors[1].Rule)
+	})
+
+	t.Run("ErrLoginLockoutNotFound", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrLoginLockoutNotFound
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrLoginLockoutNotFound.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrInvalidLoginLockoutID", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrInvalidLoginLockoutID
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrInvalidLoginLockoutID.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrUserSessionNotFound", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrUserSessionNotFound
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrUserSessionNotFound.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrInvalidUserSessionID", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrInvalidUserSessionID
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrInvalidUserSessionID.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrPersonalAccessTokenNotAllowed", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrPersonalAccessTokenNotAllowed
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrPersonalAccessTokenNotAllowed.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrPersonalAccessTokenScopeDenied", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrPersonalAccessTokenScopeDenied
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrPersonalAccessTokenScopeDenied.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrAccessTokenNotFound", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrAccessTokenNotFound
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrAccessTokenNotFound.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrInvalidAccessTokenID", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrInvalidAccessTokenID
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrInvalidAccessTokenID.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrApiKeyNotAllowed", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrApiKeyNotAllowed
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrApiKeyNotAllowed.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrApiKeyScopeDenied", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrApiKeyScopeDenied
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrApiKeyScopeDenied.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrApiKeyNotFound", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrApiKeyNotFound
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrApiKeyNotFound.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrInvalidApiKeyID", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrInvalidApiKeyID
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrInvalidApiKeyID.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrSamlLoginInvalid", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrSamlLoginInvalid
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrSamlLoginInvalid.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrSamlResponseInvalid", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrSamlResponseInvalid
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrSamlResponseInvalid.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrSamlMetadataInvalid", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrSamlMetadataInvalid
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrSamlMetadataInvalid.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrSamlCertificateInvalid", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrSamlCertificateInvalid
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrSamlCertificateInvalid.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrSamlEmailInUse", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrSamlEmailInUse
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrSamlEmailInUse.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrSamlEmailDomainNotVerified", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrSamlEmailDomainNotVerified
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrSamlEmailDomainNotVerified.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrSamlConnectionNotFound", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrSamlConnectionNotFound
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrSamlConnectionNotFound.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrDomainInvalid", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrDomainInvalid
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrDomainInvalid.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrDomainAlreadyClaimed", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrDomainAlreadyClaimed
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrDomainAlreadyClaimed.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrDomainAlreadyVerified", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrDomainAlreadyVerified
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrDomainAlreadyVerified.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrDomainVerificationFailed", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrDomainVerificationFailed
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrDomainVerificationFailed.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrOrganizationNotJoinable", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrOrganizationNotJoinable
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrOrganizationNotJoinable.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrJoinApprovalRequired", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrJoinApprovalRequired
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrJoinApprovalRequired.Error(), apiErr.Message)
+		assert.Equal(t, api.ErrorCodeJoinApprovalRequired, apiErr.Code)
+	})
+
+	t.Run("ErrJoinApprovalNotRequired", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrJoinApprovalNotRequired
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrJoinApprovalNotRequired.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrJoinRequestAlreadyExists", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrJoinRequestAlreadyExists
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrJoinRequestAlreadyExists.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrJoinRequestNotPending", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrJoinRequestNotPending
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrJoinRequestNotPending.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrDomainNotFound", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrDomainNotFound
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrDomainNotFound.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrJoinRequestNotFound", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrJoinRequestNotFound
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrJoinRequestNotFound.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrUserDataExportNotFound", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrUserDataExportNotFound
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrUserDataExportNotFound.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrTokenRefreshRequired", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrTokenRefreshRequired
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrTokenRefreshRequired.Error(), apiErr.Message)
+		assert.Equal(t, api.ErrorCodeTokenRefreshRequired, apiErr.Code)
+	})
+
+	t.Run("ErrReauthenticationRequired", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrReauthenticationRequired
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrReauthenticationRequired.Error(), apiErr.Message)
+		assert.Equal(t, api.ErrorCodeReauthenticationRequired, apiErr.Code)
+	})
+
+	t.Run("ErrInvalidRefreshToken", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrInvalidRefreshToken
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrInvalidRefreshToken.Error(), apiErr.Message)
+		assert.Equal(t, api.ErrorCodeReauthenticationRequired, apiErr.Code)
+	})
+
 	t.Run("ErrMembershipNotFound", func(t *testing.T) {
 		e := echo.New()
 
@@ -304,6 +1962,50 @@ func TestErrorHandlingMiddleware(t *testing.T) {
 		assert.Equal(t, api.ErrInvalidInvitationID.Error(), apiErr.Message)
 	})
 
+	t.Run("ErrInvalidDomainID", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrInvalidDomainID
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrInvalidDomainID.Error(), apiErr.Message)
+	})
+
+	t.Run("ErrInvalidJoinRequestID", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return api.ErrInvalidJoinRequestID
+		}
+
+		middleware := ErrorHandlingMiddleware
+		e.GET("/test", handler, middleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var apiErr api.ApiError
+		err := json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		require.NoError(t, err)
+		assert.Equal(t, api.ErrInvalidJoinRequestID.Error(), apiErr.Message)
+	})
+
 	t.Run("ErrStripeWebhookSecretNotConfigured", func(t *testing.T) {
 		e := echo.New()
 
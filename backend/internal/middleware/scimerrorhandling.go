@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"reece.start/internal/api"
+	"reece.start/internal/constants"
+)
+
+type scimErrorMapping struct {
+	err      error
+	status   int
+	scimType string
+}
+
+// Errors the SCIM endpoints can return, including the authentication errors of the API key they're called with
+var scimErrorMappings = []scimErrorMapping{
+	{api.ErrScimResourceNotFound, http.StatusNotFound, ""},
+	{api.ErrScimUserExists, http.StatusConflict, "uniqueness"},
+	{api.ErrScimInvalidFilter, http.StatusBadRequest, "invalidFilter"},
+	{api.ErrScimInvalidSyntax, http.StatusBadRequest, "invalidSyntax"},
+	{api.ErrScimInvalidUserName, http.StatusBadRequest, "invalidValue"},
+	{api.ErrScimDomainNotVerified, http.StatusBadRequest, "invalidValue"},
+	{api.ErrScimUserNameImmutable, http.StatusBadRequest, "mutability"},
+	{api.ErrScimInvalidPatch, http.StatusBadRequest, "invalidValue"},
+	{api.ErrScimInvalidMember, http.StatusBadRequest, "invalidValue"},
+	{api.ErrScimApiKeyRequired, http.StatusForbidden, ""},
+	{api.ErrForbiddenNoAccess, http.StatusForbidden, ""},
+	{api.ErrInvalidOrganizationID, http.StatusNotFound, ""},
+	{api.ErrMissingAuthorizationHeader, http.StatusUnauthorized, ""},
+	{api.ErrInvalidAuthorizationFormat, http.StatusUnauthorized, ""},
+	{api.ErrInvalidToken, http.StatusUnauthorized, ""},
+	{api.ErrTokenExpired, http.StatusUnauthorized, ""},
+	{api.ErrTokenMalformed, http.StatusUnauthorized, ""},
+	{api.ErrTokenInvalidIssuer, http.StatusUnauthorized, ""},
+	{api.ErrTokenInvalidAudience, http.StatusUnauthorized, ""},
+	{api.ErrTokenRefreshRequired, http.StatusUnauthorized, ""},
+	{api.ErrReauthenticationRequired, http.StatusUnauthorized, ""},
+}
+
+// ScimErrorHandlingMiddleware converts errors from the SCIM endpoints to the SCIM error format. It has to run before
+// authentication, so authentication errors are converted too.
+func ScimErrorHandlingMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		err := next(c)
+		if err == nil {
+			return nil
+		}
+
+		for _, mapping := range scimErrorMappings {
+			if errors.Is(err, mapping.err) {
+				return respondWithScimError(c, mapping.status, mapping.scimType, err.Error())
+			}
+		}
+
+		// Errors from Echo itself, such as unknown routes
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return respondWithScimError(c, httpErr.Code, "", http.StatusText(httpErr.Code))
+		}
+
+		slog.Error("Unhandled SCIM error", "error", err)
+
+		return respondWithScimError(c, http.StatusInternalServerError, "", "internal server error")
+	}
+}
+
+func respondWithScimError(c echo.Context, statusCode int, scimType string, detail string) error {
+	c.Response().Header().Set(echo.HeaderContentType, "application/scim+json; charset=utf-8")
+	c.JSON(statusCode, api.ScimError{
+		Schemas:  []string{constants.ScimSchemaError},
+		Status:   strconv.Itoa(statusCode),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+	return nil
+}
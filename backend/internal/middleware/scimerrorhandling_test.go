@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"reece.start/internal/api"
+	"reece.start/internal/constants"
+)
+
+func serveScimError(t *testing.T, err error) (*httptest.ResponseRecorder, api.ScimError) {
+	e := echo.New()
+
+	handler := func(c echo.Context) error {
+		return err
+	}
+
+	e.GET("/test", handler, ScimErrorHandlingMiddleware)
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	rec := httptest.NewRecorder()
+
+	e.ServeHTTP(rec, req)
+
+	var scimErr api.ScimError
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &scimErr))
+	return rec, scimErr
+}
+
+func TestScimErrorHandlingMiddleware(t *testing.T) {
+	t.Run("NoError", func(t *testing.T) {
+		e := echo.New()
+
+		handler := func(c echo.Context) error {
+			return c.NoContent(http.StatusNoContent)
+		}
+
+		e.GET("/test", handler, ScimErrorHandlingMiddleware)
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	})
+
+	t.Run("ErrScimUserExists", func(t *testing.T) {
+		rec, scimErr := serveScimError(t, api.ErrScimUserExists)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Equal(t, []string{constants.ScimSchemaError}, scimErr.Schemas)
+		assert.Equal(t, "409", scimErr.Status)
+		assert.Equal(t, "uniqueness", scimErr.ScimType)
+		assert.Equal(t, api.ErrScimUserExists.Error(), scimErr.Detail)
+	})
+
+	t.Run("ErrScimDomainNotVerified", func(t *testing.T) {
+		rec, scimErr := serveScimError(t, api.ErrScimDomainNotVerified)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "invalidValue", scimErr.ScimType)
+		assert.Equal(t, api.ErrScimDomainNotVerified.Error(), scimErr.Detail)
+	})
+
+	t.Run("ErrScimInvalidFilter", func(t *testing.T) {
+		rec, scimErr := serveScimError(t, api.ErrScimInvalidFilter)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "invalidFilter", scimErr.ScimType)
+	})
+
+	t.Run("WrappedError", func(t *testing.T) {
+		rec, scimErr := serveScimError(t, fmt.Errorf("patch: %w", api.ErrScimResourceNotFound))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "404", scimErr.Status)
+		assert.Empty(t, scimErr.ScimType)
+	})
+
+	t.Run("AuthenticationError", func(t *testing.T) {
+		rec, scimErr := serveScimError(t, api.ErrInvalidToken)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, "401", scimErr.Status)
+	})
+
+	t.Run("EchoError", func(t *testing.T) {
+		rec, scimErr := serveScimError(t, echo.ErrMethodNotAllowed)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Equal(t, "405", scimErr.Status)
+	})
+
+	t.Run("UnknownError", func(t *testing.T) {
+		rec, scimErr := serveScimError(t, errors.New("database exploded"))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, "500", scimErr.Status)
+		assert.NotContains(t, scimErr.Detail, "database")
+	})
+}
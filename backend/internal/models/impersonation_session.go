@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ImpersonationSession records an admin signing in as another user, and why they did.
+// Tokens issued while impersonating carry the session's ID and stop working once it has ended or expired.
+type ImpersonationSession struct {
+	gorm.Model
+	ID uuid.UUID `gorm:"type:uuid;default:gen_random_uuid()"`
+	// Cleared when the user is deleted, the session is kept for auditing
+	ImpersonatorUserID *uuid.UUID `gorm:"type:uuid;index"`
+	ImpersonatedUserID *uuid.UUID `gorm:"type:uuid;index"`
+	Reason             string     `gorm:"not null"`
+
+	// Read-only sessions can only make GET requests
+	ReadOnly bool `gorm:"not null;default:false"`
+	// Whether the impersonated user was emailed about the session
+	UserNotified bool `gorm:"not null;default:false"`
+
+	// Client the admin impersonated the user from
+	UserAgent string
+	IpAddress string
+
+	ExpiresAt time.Time `gorm:"not null"`
+	// Set when the admin stopped impersonating the user
+	EndedAt *time.Time
+
+	// Relationships
+	ImpersonatorUser *User `gorm:"foreignKey:ImpersonatorUserID;constraint:OnDelete:SET NULL"`
+	ImpersonatedUser *User `gorm:"foreignKey:ImpersonatedUserID;constraint:OnDelete:SET NULL"`
+}
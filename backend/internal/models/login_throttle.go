@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LoginThrottle counts recent failed password logins for an account email or a client IP address, so repeated
+// failures can be slowed down and eventually locked out. Accounts are tracked by email whether or not a user has that
+// email, so throttling doesn't reveal which emails are registered. Invalid MFA codes are counted per user ID, and
+// emails with magic links or password reset links per address and IP.
+type LoginThrottle struct {
+	gorm.Model
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid()"`
+	Scope          string     `gorm:"not null;uniqueIndex:idx_login_throttle_scope_target"` // "account", "ip", "mfa", "email" or "email_ip"
+	Target         string     `gorm:"not null;uniqueIndex:idx_login_throttle_scope_target"` // Lowercased email, IP address or user ID
+	FailedAttempts int        `gorm:"not null;default:0"`
+	LastFailedAt   time.Time  `gorm:"not null"`
+	NextAttemptAt  *time.Time // Attempts before this time are rejected, because of a delay or a lockout
+	LockedUntil    *time.Time `gorm:"index"` // Set while locked out after too many failed attempts
+}
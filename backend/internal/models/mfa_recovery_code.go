@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MfaRecoveryCode is a one time code that can be used instead of a TOTP code, e.g. when the user lost their authenticator.
+// Only the hash of the code is stored, the code itself is shown to the user once when it is generated.
+type MfaRecoveryCode struct {
+	gorm.Model
+	ID       uuid.UUID `gorm:"type:uuid;default:gen_random_uuid()"`
+	UserID   uuid.UUID `gorm:"type:uuid;not null;index"`
+	CodeHash string    `gorm:"not null;index"`
+	UsedAt   *time.Time
+
+	// Relationships
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OAuthState stores an OAuth authorization started by the server until the provider redirects back to the callback.
+// Each state can only be used once. Only the hash of the state is stored, since it is sent through the browser.
+type OAuthState struct {
+	gorm.Model
+	ID           uuid.UUID `gorm:"type:uuid;default:gen_random_uuid()"`
+	Provider     string    `gorm:"not null"`
+	StateHash    string    `gorm:"not null;uniqueIndex"`
+	RedirectUri  string    `gorm:"not null"`
+	CodeVerifier string    `gorm:"not null"`
+	Nonce        string    `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+}
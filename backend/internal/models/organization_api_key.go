@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OrganizationApiKey is a credential owned by an organization rather than a person, used by integrations that act on
+// behalf of the organization. It is limited to the organization scopes chosen when it was created.
+type OrganizationApiKey struct {
+	gorm.Model
+	ID             uuid.UUID `gorm:"type:uuid;default:gen_random_uuid()"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;not null;index"`
+	Name           string    `gorm:"not null;size:100"`
+
+	TokenHash   string `gorm:"not null;uniqueIndex"`
+	TokenPrefix string `gorm:"not null"` // Start of the key, so admins can tell their keys apart
+	Scopes      string // Comma separated list of scopes
+
+	// After a rotation the previous secret keeps working until it expires, so integrations can switch over
+	PreviousTokenHash      string `gorm:"index"`
+	PreviousTokenExpiresAt *time.Time
+
+	// Admin who created the key, kept for auditing
+	CreatedByUserID *uuid.UUID `gorm:"type:uuid"`
+
+	LastUsedAt *time.Time
+	LastUsedIp string
+
+	// Relationships
+	Organization Organization `gorm:"foreignKey:OrganizationID;constraint:OnDelete:CASCADE"`
+	CreatedBy    *User        `gorm:"foreignKey:CreatedByUserID;constraint:OnDelete:SET NULL"`
+}
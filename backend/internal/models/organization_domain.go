@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OrganizationDomain is an email domain an organization has claimed. Once the organization proves it owns the domain
+// with a DNS TXT record, users with a verified email address at the domain can join it according to the join policy.
+type OrganizationDomain struct {
+	gorm.Model
+	ID             uuid.UUID `gorm:"type:uuid;default:gen_random_uuid()"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_organization_domain"`
+	Domain         string    `gorm:"not null;size:253;uniqueIndex:idx_organization_domain;uniqueIndex:idx_verified_domain,where:verified_at IS NOT NULL"` // Lowercase, without a trailing dot
+
+	// Ownership verification. Several organizations can claim a domain, but only one of them can verify it.
+	VerificationToken string `gorm:"not null"`
+	VerifiedAt        *time.Time
+
+	// How users with the domain join, and the role they're given
+	JoinPolicy  string `gorm:"not null;size:20;default:'prompt'"`
+	DefaultRole string `gorm:"not null;size:20;default:'member'"`
+
+	// Relationships
+	Organization Organization `gorm:"foreignKey:OrganizationID;constraint:OnDelete:CASCADE"`
+}
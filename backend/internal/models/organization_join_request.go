@@ -0,0 +1,21 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OrganizationJoinRequest is a request to join an organization through one of its verified domains, for domains
+// whose join policy requires an admin's approval
+type OrganizationJoinRequest struct {
+	gorm.Model
+	ID             uuid.UUID `gorm:"type:uuid;default:gen_random_uuid()"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;not null;index"`
+	UserID         uuid.UUID `gorm:"type:uuid;not null;index"`
+	Role           string    `gorm:"not null;size:20"` // Role the user is given once approved
+	Status         string    `gorm:"not null;size:20"`
+
+	// Relationships
+	User         User         `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Organization Organization `gorm:"foreignKey:OrganizationID;constraint:OnDelete:CASCADE"`
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -12,6 +14,12 @@ type OrganizationMembership struct {
 	OrganizationID uuid.UUID `gorm:"type:uuid;not null;index"`
 	Role           string    `gorm:"not null;size:20;default:'member'"`
 
+	// ID the organization's identity provider knows the member by, when provisioned over SCIM
+	ScimExternalID string `gorm:"index"`
+	// Set when the identity provider deleted the member. The membership stays soft deleted, like any other removal, so
+	// the user can't rejoin through one of the organization's verified domains.
+	ScimDeprovisionedAt *time.Time
+
 	// Relationships
 	User         User         `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
 	Organization Organization `gorm:"foreignKey:OrganizationID;constraint:OnDelete:CASCADE"`
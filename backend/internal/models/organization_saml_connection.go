@@ -0,0 +1,32 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OrganizationSamlConnection is the SAML identity provider an organization's members can sign in with.
+// Users signing in through it for the first time are added to the organization with the default role.
+type OrganizationSamlConnection struct {
+	gorm.Model
+	ID             uuid.UUID `gorm:"type:uuid;default:gen_random_uuid()"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex"`
+
+	// Identity provider
+	IdpEntityID    string `gorm:"not null"`
+	IdpSsoUrl      string `gorm:"not null"`
+	IdpCertificate string `gorm:"not null"` // PEM encoded signing certificates
+
+	// Attribute mapping. Attributes are matched by name or friendly name.
+	EmailAttribute string `gorm:"not null"`
+	NameAttribute  string `gorm:"not null"`
+
+	// Role given to users added to the organization when they first sign in
+	DefaultRole string `gorm:"not null;size:20;default:'member'"`
+
+	// Whether members have to sign in through the identity provider instead of with a password or OAuth provider
+	SsoRequired bool `gorm:"not null;default:false"`
+
+	// Relationships
+	Organization Organization `gorm:"foreignKey:OrganizationID;constraint:OnDelete:CASCADE"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Passkey is a WebAuthn credential registered by a user, which can be used to log in without a password
+type Passkey struct {
+	gorm.Model
+	ID     uuid.UUID `gorm:"type:uuid;default:gen_random_uuid()"`
+	UserID uuid.UUID `gorm:"type:uuid;not null;index"`
+	Name   string    `gorm:"not null;size:100"`
+
+	// Credential record (https://www.w3.org/TR/webauthn-3/#credential-record)
+	CredentialID    []byte `gorm:"not null;uniqueIndex"`
+	PublicKey       []byte `gorm:"not null"`
+	AttestationType string
+	Transports      string // Comma separated list of transports the authenticator supports
+	AAGUID          []byte
+	Flags           uint8 `gorm:"not null;default:0"` // Raw authenticator data flags, needed to check the backup flags on login
+
+	// The signature counter is compared on each login to detect cloned authenticators. Logins with a counter that
+	// didn't increase are rejected.
+	SignCount  int64 `gorm:"not null;default:0"`
+	LastUsedAt *time.Time
+
+	// Relationships
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+// PasskeyChallenge stores the server side state of a WebAuthn ceremony between the begin and finish requests.
+// Each challenge can only be used once.
+type PasskeyChallenge struct {
+	gorm.Model
+	ID        uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid()"`
+	UserID    *uuid.UUID `gorm:"type:uuid;index"` // Not set for passwordless logins, since the user isn't known until the passkey is used
+	Ceremony  string     `gorm:"not null;size:20"`
+	Session   string     `gorm:"type:text;not null"` // JSON encoded webauthn.SessionData
+	ExpiresAt time.Time  `gorm:"not null;index"`
+
+	// Relationships
+	User *User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PersonalAccessToken is a long lived credential a user creates to call the API from scripts.
+// It is limited to the scopes chosen when it was created, and to the scopes the user still has when it is used.
+type PersonalAccessToken struct {
+	gorm.Model
+	ID     uuid.UUID `gorm:"type:uuid;default:gen_random_uuid()"`
+	UserID uuid.UUID `gorm:"type:uuid;not null;index"`
+	Name   string    `gorm:"not null;size:100"`
+
+	TokenHash   string `gorm:"not null;uniqueIndex"`
+	TokenPrefix string `gorm:"not null"` // Start of the token, so users can tell their tokens apart
+	Scopes      string // Comma separated list of scopes
+
+	// Organization whose scopes the token can use, if any
+	OrganizationID *uuid.UUID `gorm:"type:uuid"`
+
+	ExpiresAt  *time.Time // Never expires if nil
+	LastUsedAt *time.Time
+	LastUsedIp string
+
+	// Relationships
+	User         User          `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Organization *Organization `gorm:"foreignKey:OrganizationID;constraint:OnDelete:CASCADE"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RefreshToken is an opaque, single use credential that can be exchanged for a new access token.
+// Every rotation creates a new token in the same family, so reusing an already rotated token can be detected and the whole family revoked.
+type RefreshToken struct {
+	gorm.Model
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid()"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	FamilyID  uuid.UUID `gorm:"type:uuid;not null;index"`
+	TokenHash string    `gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+
+	// Context the access tokens minted from this refresh token are scoped to
+	OrganizationID      *uuid.UUID `gorm:"type:uuid"`
+	ImpersonatingUserID *uuid.UUID `gorm:"type:uuid"`
+	// Impersonation the tokens were issued for, if any
+	ImpersonationSessionID *uuid.UUID `gorm:"type:uuid"`
+
+	// Set once the token has been exchanged for a new one
+	RotatedAt *time.Time
+	// Set when the token (or its whole family) has been revoked
+	RevokedAt *time.Time
+
+	// Relationships
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SamlLogin stores a SAML login started by the server. The state is sent to the identity provider as the relay state,
+// and the assertion consumer service records the user along with a single use code once the response is verified.
+// The frontend then exchanges the code and state for a session. Only hashes are stored, since both pass through the
+// browser.
+type SamlLogin struct {
+	gorm.Model
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid()"`
+	OrganizationID uuid.UUID  `gorm:"type:uuid;not null;index"`
+	StateHash      string     `gorm:"not null;uniqueIndex"`
+	RequestID      string     `gorm:"not null"` // ID of the AuthnRequest, which the response has to be in response to
+	UserID         *uuid.UUID `gorm:"type:uuid"`
+	CodeHash       *string    `gorm:"uniqueIndex"`
+	ExpiresAt      time.Time  `gorm:"not null;index"`
+}
@@ -17,24 +17,40 @@ type UserTokenRevocation struct {
 	CanRefresh bool `gorm:"not null;default:true"`
 }
 
+// TOTP based multi-factor authentication state
+type UserMfa struct {
+	// Base32 encoded TOTP secret. Set when enrollment starts, but only enforced once Enabled is true
+	TotpSecret string
+
+	// If true, a password login has to be completed with a TOTP or recovery code before a session token is issued
+	Enabled bool `gorm:"not null;default:false"`
+
+	// Time step of the last accepted TOTP code, so the same code can't be used twice
+	LastUsedStep int64 `gorm:"not null;default:0"`
+}
+
 type User struct {
 	gorm.Model
 	ID                 uuid.UUID `gorm:"type:uuid;default:gen_random_uuid()"`
 	Name               string    `gorm:"not null"`
 	Email              string    `gorm:"index:idx_email,unique;not null"`
+	EmailVerifiedAt    *time.Time
+	PendingEmail       string // New address the user asked to change to, only applied once it is verified
 	HashedPassword     []byte
 	LogoFileStorageKey string
 
-	// OAuth fields
-	GoogleId           string `gorm:"index:idx_google_id"`
-	GoogleProfileImage string
+	// Set when the user asked for their account to be deleted. The account is deleted at this time, unless the user
+	// signs in again before then.
+	DeletionScheduledAt *time.Time
 
 	// Control fields
 	Revocation UserTokenRevocation `gorm:"embedded;embeddedPrefix:revocation_"`
+	Mfa        UserMfa             `gorm:"embedded;embeddedPrefix:mfa_"`
 
 	// Admin fields
 	Role string `gorm:"not null;size:20;default:'default'"`
 
 	// Relationships
 	OrganizationMemberships []OrganizationMembership `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Identities              []UserIdentity           `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
 }
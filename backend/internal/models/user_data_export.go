@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UserDataExport is an archive of the personal data stored about a user, put together in the background when they
+// ask for a copy of it
+type UserDataExport struct {
+	gorm.Model
+	ID     uuid.UUID `gorm:"type:uuid;default:gen_random_uuid()"`
+	UserID uuid.UUID `gorm:"type:uuid;not null;index"`
+	Status string    `gorm:"not null;size:20"`
+
+	// Key of the archive in the user data exports bucket, set once it has been stored
+	FileStorageKey string
+	CompletedAt    *time.Time
+	// When the emailed download link stops working
+	ExpiresAt *time.Time
+
+	// Relationships
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UserIdentity links a user to an account at an OAuth provider.
+// A user can have one identity per provider account, and sign in with any of them.
+type UserIdentity struct {
+	gorm.Model
+	ID           uuid.UUID `gorm:"type:uuid;default:gen_random_uuid()"`
+	UserID       uuid.UUID `gorm:"type:uuid;not null;index"`
+	Provider     string    `gorm:"not null;uniqueIndex:idx_user_identity_provider_subject"`
+	Subject      string    `gorm:"not null;uniqueIndex:idx_user_identity_provider_subject"` // Stable ID of the user at the provider
+	Email        string    // Email reported by the provider at the last sign in, which may differ from the user's email
+	ProfileImage string
+
+	// Relationships
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UserSession is a device the user is signed in on.
+// The session ID is the `jti` of every access token issued to the device and the family ID of its refresh tokens,
+// so revoking the session signs the device out.
+type UserSession struct {
+	gorm.Model
+	ID     uuid.UUID `gorm:"type:uuid;default:gen_random_uuid()"`
+	UserID uuid.UUID `gorm:"type:uuid;not null;index"`
+
+	// Client the session was last used from
+	UserAgent string
+	IpAddress string
+
+	// Updated every time a token is issued for the session
+	LastSeenAt time.Time `gorm:"not null"`
+	ExpiresAt  time.Time `gorm:"not null"`
+
+	// Set when the session has been signed out
+	RevokedAt *time.Time
+
+	// Relationships
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
@@ -13,6 +13,7 @@ import (
 	"reece.start/internal/api"
 	"reece.start/internal/configuration"
 	"reece.start/internal/constants"
+	"reece.start/internal/dns"
 	"reece.start/internal/models"
 	"reece.start/internal/posthog"
 )
@@ -301,6 +302,222 @@ type DeclineOrganizationInvitationResponse struct {
 	Included []interface{}              `json:"included,omitempty"`
 }
 
+// Organization API Key API Types
+type OrganizationApiKeyAttributes struct {
+	Name                   string                `json:"name"`
+	TokenPrefix            string                `json:"tokenPrefix"`
+	Scopes                 []constants.UserScope `json:"scopes"`
+	CreatedAt              time.Time             `json:"createdAt"`
+	PreviousTokenExpiresAt *time.Time            `json:"previousTokenExpiresAt,omitempty"` // Set while the secret from before a rotation still works
+	LastUsedAt             *time.Time            `json:"lastUsedAt,omitempty"`
+	LastUsedIp             string                `json:"lastUsedIp,omitempty"`
+}
+
+type CreateOrganizationApiKeyAttributes struct {
+	Name   string                `json:"name" validate:"required,min=1,max=100"`
+	Scopes []constants.UserScope `json:"scopes" validate:"required,min=1,dive,required"`
+}
+
+type RotateOrganizationApiKeyAttributes struct {
+	// How long the current secret keeps working after the rotation (in seconds), defaults to the configured overlap
+	OverlapSeconds *int `json:"overlapSeconds" validate:"omitempty,min=0,max=2592000"`
+}
+
+type OrganizationApiKeyRelationships struct {
+	Organization OrganizationRelationshipData `json:"organization"`
+}
+
+type OrganizationApiKeyMeta struct {
+	Token string `json:"token"` // Only returned when the key is created or rotated
+}
+
+type OrganizationApiKeyData struct {
+	Id            string                          `json:"id"`
+	Type          constants.ApiType               `json:"type"`
+	Attributes    OrganizationApiKeyAttributes    `json:"attributes"`
+	Relationships OrganizationApiKeyRelationships `json:"relationships"`
+	Meta          *OrganizationApiKeyMeta         `json:"meta,omitempty"`
+}
+
+type CreateOrganizationApiKeyRequest struct {
+	Data struct {
+		Type       constants.ApiType                  `json:"type" validate:"oneof=organization-api-key"`
+		Attributes CreateOrganizationApiKeyAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type RotateOrganizationApiKeyRequest struct {
+	Data struct {
+		Type       constants.ApiType                  `json:"type" validate:"oneof=organization-api-key"`
+		Attributes RotateOrganizationApiKeyAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type OrganizationApiKeyResponse struct {
+	Data OrganizationApiKeyData `json:"data"`
+}
+
+type OrganizationApiKeysResponse struct {
+	Data []OrganizationApiKeyData `json:"data"`
+}
+
+// Organization SAML Connection API Types
+type OrganizationSamlConnectionAttributes struct {
+	IdpEntityId    string    `json:"idpEntityId"`
+	IdpSsoUrl      string    `json:"idpSsoUrl"`
+	IdpCertificate string    `json:"idpCertificate"`
+	EmailAttribute string    `json:"emailAttribute"`
+	NameAttribute  string    `json:"nameAttribute"`
+	DefaultRole    string    `json:"defaultRole"`
+	SsoRequired    bool      `json:"ssoRequired"`
+	SpEntityId     string    `json:"spEntityId"` // Service provider details the identity provider has to be configured with
+	SpAcsUrl       string    `json:"spAcsUrl"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+type UpdateOrganizationSamlConnectionAttributes struct {
+	// Identity provider metadata XML. The entity ID, SSO URL and certificate are read from it unless they are given.
+	IdpMetadata    string `json:"idpMetadata,omitempty"`
+	IdpEntityId    string `json:"idpEntityId,omitempty" validate:"omitempty,max=1024"`
+	IdpSsoUrl      string `json:"idpSsoUrl,omitempty" validate:"omitempty,url"`
+	IdpCertificate string `json:"idpCertificate,omitempty"` // PEM encoded signing certificates
+	EmailAttribute string `json:"emailAttribute,omitempty" validate:"omitempty,max=255"`
+	NameAttribute  string `json:"nameAttribute,omitempty" validate:"omitempty,max=255"`
+	DefaultRole    string `json:"defaultRole,omitempty" validate:"omitempty,oneof=admin member"`
+	SsoRequired    bool   `json:"ssoRequired"`
+}
+
+type OrganizationSamlConnectionRelationships struct {
+	Organization OrganizationRelationshipData `json:"organization"`
+}
+
+type OrganizationSamlConnectionData struct {
+	Id            string                                  `json:"id"`
+	Type          constants.ApiType                       `json:"type"`
+	Attributes    OrganizationSamlConnectionAttributes    `json:"attributes"`
+	Relationships OrganizationSamlConnectionRelationships `json:"relationships"`
+}
+
+type UpdateOrganizationSamlConnectionRequest struct {
+	Data struct {
+		Type       constants.ApiType                          `json:"type" validate:"oneof=organization-saml-connection"`
+		Attributes UpdateOrganizationSamlConnectionAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type OrganizationSamlConnectionResponse struct {
+	Data OrganizationSamlConnectionData `json:"data"`
+}
+
+// Organization Domain API Types
+type OrganizationDomainAttributes struct {
+	Domain      string     `json:"domain"`
+	JoinPolicy  string     `json:"joinPolicy"`
+	DefaultRole string     `json:"defaultRole"`
+	VerifiedAt  *time.Time `json:"verifiedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+type CreateOrganizationDomainAttributes struct {
+	Domain      string `json:"domain" validate:"required,max=253"`
+	JoinPolicy  string `json:"joinPolicy,omitempty" validate:"omitempty,oneof=auto_join prompt approval"`
+	DefaultRole string `json:"defaultRole,omitempty" validate:"omitempty,oneof=admin member"`
+}
+
+type UpdateOrganizationDomainAttributes struct {
+	JoinPolicy  *string `json:"joinPolicy,omitempty" validate:"omitempty,oneof=auto_join prompt approval"`
+	DefaultRole *string `json:"defaultRole,omitempty" validate:"omitempty,oneof=admin member"`
+}
+
+type OrganizationDomainRelationships struct {
+	Organization OrganizationRelationshipData `json:"organization"`
+}
+
+// TXT record the organization has to add to the domain's DNS to verify it
+type OrganizationDomainMeta struct {
+	VerificationRecordName  string `json:"verificationRecordName"`
+	VerificationRecordValue string `json:"verificationRecordValue"`
+}
+
+type OrganizationDomainData struct {
+	Id            string                          `json:"id"`
+	Type          constants.ApiType               `json:"type"`
+	Attributes    OrganizationDomainAttributes    `json:"attributes"`
+	Relationships OrganizationDomainRelationships `json:"relationships"`
+	Meta          OrganizationDomainMeta          `json:"meta"`
+}
+
+type CreateOrganizationDomainRequest struct {
+	Data struct {
+		Type       constants.ApiType                  `json:"type" validate:"oneof=organization-domain"`
+		Attributes CreateOrganizationDomainAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type UpdateOrganizationDomainRequest struct {
+	Data struct {
+		Type       constants.ApiType                  `json:"type" validate:"oneof=organization-domain"`
+		Attributes UpdateOrganizationDomainAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type OrganizationDomainResponse struct {
+	Data OrganizationDomainData `json:"data"`
+}
+
+type OrganizationDomainsResponse struct {
+	Data []OrganizationDomainData `json:"data"`
+}
+
+// Organization Join Request API Types
+type OrganizationJoinRequestAttributes struct {
+	Role      string    `json:"role"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type OrganizationJoinRequestRelationships struct {
+	User         UserRelationshipData         `json:"user"`
+	Organization OrganizationRelationshipData `json:"organization"`
+}
+
+type OrganizationJoinRequestData struct {
+	Id            string                               `json:"id"`
+	Type          constants.ApiType                    `json:"type"`
+	Attributes    OrganizationJoinRequestAttributes    `json:"attributes"`
+	Relationships OrganizationJoinRequestRelationships `json:"relationships"`
+}
+
+type OrganizationJoinRequestResponse struct {
+	Data OrganizationJoinRequestData `json:"data"`
+}
+
+type OrganizationJoinRequestsResponse struct {
+	Data     []OrganizationJoinRequestData `json:"data"`
+	Included []interface{}                 `json:"included,omitempty"`
+}
+
+// Organizations the authenticated user can join through the domain of their email address
+type JoinableOrganizationAttributes struct {
+	Name              string `json:"name"`
+	Description       string `json:"description"`
+	Domain            string `json:"domain"`
+	JoinPolicy        string `json:"joinPolicy"`
+	JoinRequestStatus string `json:"joinRequestStatus,omitempty"` // Set once the user has asked to join
+}
+
+type JoinableOrganizationData struct {
+	Id         string                         `json:"id"`
+	Type       constants.ApiType              `json:"type"`
+	Attributes JoinableOrganizationAttributes `json:"attributes"`
+}
+
+type JoinableOrganizationsResponse struct {
+	Data []JoinableOrganizationData `json:"data"`
+}
+
 // Service request/response types
 type CreateOrganizationParams struct {
 	Name                string
@@ -480,6 +697,157 @@ type DeclineOrganizationInvitationServiceRequest struct {
 	MinioClient  *minio.Client
 }
 
+// Organization API Key Service Types
+type GetOrganizationApiKeysServiceRequest struct {
+	OrganizationID uuid.UUID
+	Tx             *gorm.DB
+}
+
+type CreateOrganizationApiKeyParams struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+	Name           string
+	Scopes         []constants.UserScope
+}
+
+type CreateOrganizationApiKeyServiceRequest struct {
+	Params CreateOrganizationApiKeyParams
+	Tx     *gorm.DB
+}
+
+type RotateOrganizationApiKeyParams struct {
+	OrganizationID uuid.UUID
+	ApiKeyID       uuid.UUID
+	Overlap        time.Duration
+}
+
+type RotateOrganizationApiKeyServiceRequest struct {
+	Params RotateOrganizationApiKeyParams
+	Tx     *gorm.DB
+}
+
+type OrganizationApiKeyDto struct {
+	ApiKey *models.OrganizationApiKey
+	Token  string
+}
+
+type DeleteOrganizationApiKeyServiceRequest struct {
+	OrganizationID uuid.UUID
+	ApiKeyID       uuid.UUID
+	Tx             *gorm.DB
+}
+
+// Organization SAML Connection Service Types
+type GetOrganizationSamlConnectionServiceRequest struct {
+	OrganizationID uuid.UUID
+	Tx             *gorm.DB
+}
+
+type UpdateOrganizationSamlConnectionParams struct {
+	OrganizationID uuid.UUID
+	IdpMetadata    string
+	IdpEntityID    string
+	IdpSsoUrl      string
+	IdpCertificate string
+	EmailAttribute string
+	NameAttribute  string
+	DefaultRole    string
+	SsoRequired    bool
+}
+
+type UpdateOrganizationSamlConnectionServiceRequest struct {
+	Params UpdateOrganizationSamlConnectionParams
+	Tx     *gorm.DB
+}
+
+type DeleteOrganizationSamlConnectionServiceRequest struct {
+	OrganizationID uuid.UUID
+	Tx             *gorm.DB
+}
+
+// Organization Domain Service Types
+type GetOrganizationDomainsServiceRequest struct {
+	OrganizationID uuid.UUID
+	Tx             *gorm.DB
+}
+
+type CreateOrganizationDomainParams struct {
+	OrganizationID uuid.UUID
+	Domain         string
+	JoinPolicy     string
+	DefaultRole    string
+}
+
+type CreateOrganizationDomainServiceRequest struct {
+	Params CreateOrganizationDomainParams
+	Tx     *gorm.DB
+}
+
+type UpdateOrganizationDomainParams struct {
+	OrganizationID uuid.UUID
+	DomainID       uuid.UUID
+	JoinPolicy     *string
+	DefaultRole    *string
+}
+
+type UpdateOrganizationDomainServiceRequest struct {
+	Params UpdateOrganizationDomainParams
+	Tx     *gorm.DB
+}
+
+type VerifyOrganizationDomainServiceRequest struct {
+	OrganizationID uuid.UUID
+	DomainID       uuid.UUID
+	Tx             *gorm.DB
+	DnsResolver    dns.Resolver
+}
+
+type DeleteOrganizationDomainServiceRequest struct {
+	OrganizationID uuid.UUID
+	DomainID       uuid.UUID
+	Tx             *gorm.DB
+}
+
+// Organization Join Service Types
+type JoinableOrganizationDto struct {
+	Domain       *models.OrganizationDomain
+	Organization *models.Organization
+	JoinRequest  *models.OrganizationJoinRequest
+}
+
+type GetJoinableOrganizationsServiceRequest struct {
+	UserID uuid.UUID
+	Tx     *gorm.DB
+}
+
+type JoinOrganizationServiceRequest struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+	Tx             *gorm.DB
+}
+
+type CreateOrganizationJoinRequestServiceRequest struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+	Tx             *gorm.DB
+}
+
+type GetOrganizationJoinRequestsServiceRequest struct {
+	OrganizationID uuid.UUID
+	Tx             *gorm.DB
+}
+
+type ReviewOrganizationJoinRequestParams struct {
+	OrganizationID uuid.UUID
+	JoinRequestID  uuid.UUID
+	Approve        bool
+}
+
+type ReviewOrganizationJoinRequestServiceRequest struct {
+	Params ReviewOrganizationJoinRequestParams
+	Tx     *gorm.DB
+}
+
 type UpdateOrganizationStripeInformationServiceRequest struct {
 	Organization  *models.Organization
 	StripeAccount stripeGo.V2CoreAccount
@@ -3,13 +3,17 @@ package organizations
 import (
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 	"reece.start/internal/access"
 	"reece.start/internal/api"
+	"reece.start/internal/authentication"
+	"reece.start/internal/configuration"
 	"reece.start/internal/constants"
 	"reece.start/internal/middleware"
+	"reece.start/internal/models"
 )
 
 func CreateOrganizationEndpoint(c echo.Context, req CreateOrganizationRequest) error {
@@ -655,6 +659,580 @@ func DeclineOrganizationInvitationEndpoint(c echo.Context, req DeclineOrganizati
 	return c.JSON(http.StatusOK, response)
 }
 
+// Organization API Key Endpoints
+func GetOrganizationApiKeysEndpoint(c echo.Context) error {
+	paramOrgID, err := api.ParseOrganizationIDFromParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := access.HasOrganizationAccess(c, access.HasOrganizationAccessParams{
+		OrganizationID: paramOrgID,
+		Scopes:         []constants.UserScope{constants.UserScopeOrganizationApiKeysList},
+	}); err != nil {
+		return err
+	}
+
+	db := middleware.GetDB(c)
+
+	apiKeys, err := getOrganizationApiKeys(GetOrganizationApiKeysServiceRequest{
+		OrganizationID: paramOrgID,
+		Tx:             db,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, mapApiKeysToResponse(apiKeys))
+}
+
+func CreateOrganizationApiKeyEndpoint(c echo.Context, req CreateOrganizationApiKeyRequest) error {
+	paramOrgID, err := api.ParseOrganizationIDFromParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := access.HasOrganizationAccess(c, access.HasOrganizationAccessParams{
+		OrganizationID: paramOrgID,
+		Scopes:         []constants.UserScope{constants.UserScopeOrganizationApiKeysCreate},
+	}); err != nil {
+		return err
+	}
+
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err
+	}
+
+	db := middleware.GetDB(c)
+
+	apiKey, err := createOrganizationApiKey(CreateOrganizationApiKeyServiceRequest{
+		Params: CreateOrganizationApiKeyParams{
+			OrganizationID: paramOrgID,
+			UserID:         userID,
+			Name:           req.Data.Attributes.Name,
+			Scopes:         req.Data.Attributes.Scopes,
+		},
+		Tx: db.WithContext(c.Request().Context()),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, mapApiKeyDtoToResponse(apiKey))
+}
+
+func RotateOrganizationApiKeyEndpoint(c echo.Context, req RotateOrganizationApiKeyRequest) error {
+	paramOrgID, err := api.ParseOrganizationIDFromParams(c)
+	if err != nil {
+		return err
+	}
+
+	paramApiKeyID, err := api.ParseApiKeyIDFromParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := access.HasOrganizationAccess(c, access.HasOrganizationAccessParams{
+		OrganizationID: paramOrgID,
+		Scopes:         []constants.UserScope{constants.UserScopeOrganizationApiKeysUpdate},
+	}); err != nil {
+		return err
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+
+	overlap := time.Duration(config.ApiKeyRotationOverlap) * time.Second
+	if req.Data.Attributes.OverlapSeconds != nil {
+		overlap = time.Duration(*req.Data.Attributes.OverlapSeconds) * time.Second
+	}
+
+	apiKey, err := rotateOrganizationApiKey(RotateOrganizationApiKeyServiceRequest{
+		Params: RotateOrganizationApiKeyParams{
+			OrganizationID: paramOrgID,
+			ApiKeyID:       paramApiKeyID,
+			Overlap:        overlap,
+		},
+		Tx: db.WithContext(c.Request().Context()),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, mapApiKeyDtoToResponse(apiKey))
+}
+
+func DeleteOrganizationApiKeyEndpoint(c echo.Context) error {
+	paramOrgID, err := api.ParseOrganizationIDFromParams(c)
+	if err != nil {
+		return err
+	}
+
+	paramApiKeyID, err := api.ParseApiKeyIDFromParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := access.HasOrganizationAccess(c, access.HasOrganizationAccessParams{
+		OrganizationID: paramOrgID,
+		Scopes:         []constants.UserScope{constants.UserScopeOrganizationApiKeysDelete},
+	}); err != nil {
+		return err
+	}
+
+	db := middleware.GetDB(c)
+
+	err = deleteOrganizationApiKey(DeleteOrganizationApiKeyServiceRequest{
+		OrganizationID: paramOrgID,
+		ApiKeyID:       paramApiKeyID,
+		Tx:             db,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Organization SAML Connection Endpoints
+func GetOrganizationSamlConnectionEndpoint(c echo.Context) error {
+	paramOrgID, err := api.ParseOrganizationIDFromParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := access.HasOrganizationAccess(c, access.HasOrganizationAccessParams{
+		OrganizationID: paramOrgID,
+		Scopes:         []constants.UserScope{constants.UserScopeOrganizationSamlRead},
+	}); err != nil {
+		return err
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+
+	connection, err := getOrganizationSamlConnection(GetOrganizationSamlConnectionServiceRequest{
+		OrganizationID: paramOrgID,
+		Tx:             db,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, mapSamlConnectionToResponse(connection, config))
+}
+
+func UpdateOrganizationSamlConnectionEndpoint(c echo.Context, req UpdateOrganizationSamlConnectionRequest) error {
+	paramOrgID, err := api.ParseOrganizationIDFromParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := access.HasOrganizationAccess(c, access.HasOrganizationAccessParams{
+		OrganizationID: paramOrgID,
+		Scopes:         []constants.UserScope{constants.UserScopeOrganizationSamlUpdate},
+	}); err != nil {
+		return err
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+	attributes := req.Data.Attributes
+
+	var connection *models.OrganizationSamlConnection
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		connection, err = updateOrganizationSamlConnection(UpdateOrganizationSamlConnectionServiceRequest{
+			Params: UpdateOrganizationSamlConnectionParams{
+				OrganizationID: paramOrgID,
+				IdpMetadata:    attributes.IdpMetadata,
+				IdpEntityID:    attributes.IdpEntityId,
+				IdpSsoUrl:      attributes.IdpSsoUrl,
+				IdpCertificate: attributes.IdpCertificate,
+				EmailAttribute: attributes.EmailAttribute,
+				NameAttribute:  attributes.NameAttribute,
+				DefaultRole:    attributes.DefaultRole,
+				SsoRequired:    attributes.SsoRequired,
+			},
+			Tx: tx,
+		})
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, mapSamlConnectionToResponse(connection, config))
+}
+
+func DeleteOrganizationSamlConnectionEndpoint(c echo.Context) error {
+	paramOrgID, err := api.ParseOrganizationIDFromParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := access.HasOrganizationAccess(c, access.HasOrganizationAccessParams{
+		OrganizationID: paramOrgID,
+		Scopes:         []constants.UserScope{constants.UserScopeOrganizationSamlDelete},
+	}); err != nil {
+		return err
+	}
+
+	db := middleware.GetDB(c)
+
+	err = deleteOrganizationSamlConnection(DeleteOrganizationSamlConnectionServiceRequest{
+		OrganizationID: paramOrgID,
+		Tx:             db,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Organization Domain Endpoints
+func GetOrganizationDomainsEndpoint(c echo.Context) error {
+	paramOrgID, err := api.ParseOrganizationIDFromParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := access.HasOrganizationAccess(c, access.HasOrganizationAccessParams{
+		OrganizationID: paramOrgID,
+		Scopes:         []constants.UserScope{constants.UserScopeOrganizationDomainsList},
+	}); err != nil {
+		return err
+	}
+
+	db := middleware.GetDB(c)
+
+	domains, err := getOrganizationDomains(GetOrganizationDomainsServiceRequest{
+		OrganizationID: paramOrgID,
+		Tx:             db,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, mapDomainsToResponse(domains))
+}
+
+func CreateOrganizationDomainEndpoint(c echo.Context, req CreateOrganizationDomainRequest) error {
+	paramOrgID, err := api.ParseOrganizationIDFromParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := access.HasOrganizationAccess(c, access.HasOrganizationAccessParams{
+		OrganizationID: paramOrgID,
+		Scopes:         []constants.UserScope{constants.UserScopeOrganizationDomainsCreate},
+	}); err != nil {
+		return err
+	}
+
+	db := middleware.GetDB(c)
+	attributes := req.Data.Attributes
+
+	domain, err := createOrganizationDomain(CreateOrganizationDomainServiceRequest{
+		Params: CreateOrganizationDomainParams{
+			OrganizationID: paramOrgID,
+			Domain:         attributes.Domain,
+			JoinPolicy:     attributes.JoinPolicy,
+			DefaultRole:    attributes.DefaultRole,
+		},
+		Tx: db.WithContext(c.Request().Context()),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, OrganizationDomainResponse{Data: mapDomainToData(domain)})
+}
+
+func UpdateOrganizationDomainEndpoint(c echo.Context, req UpdateOrganizationDomainRequest) error {
+	paramOrgID, err := api.ParseOrganizationIDFromParams(c)
+	if err != nil {
+		return err
+	}
+
+	paramDomainID, err := api.ParseDomainIDFromParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := access.HasOrganizationAccess(c, access.HasOrganizationAccessParams{
+		OrganizationID: paramOrgID,
+		Scopes:         []constants.UserScope{constants.UserScopeOrganizationDomainsUpdate},
+	}); err != nil {
+		return err
+	}
+
+	db := middleware.GetDB(c)
+	attributes := req.Data.Attributes
+
+	var domain *models.OrganizationDomain
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		domain, err = updateOrganizationDomain(UpdateOrganizationDomainServiceRequest{
+			Params: UpdateOrganizationDomainParams{
+				OrganizationID: paramOrgID,
+				DomainID:       paramDomainID,
+				JoinPolicy:     attributes.JoinPolicy,
+				DefaultRole:    attributes.DefaultRole,
+			},
+			Tx: tx,
+		})
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, OrganizationDomainResponse{Data: mapDomainToData(domain)})
+}
+
+func VerifyOrganizationDomainEndpoint(c echo.Context) error {
+	paramOrgID, err := api.ParseOrganizationIDFromParams(c)
+	if err != nil {
+		return err
+	}
+
+	paramDomainID, err := api.ParseDomainIDFromParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := access.HasOrganizationAccess(c, access.HasOrganizationAccessParams{
+		OrganizationID: paramOrgID,
+		Scopes:         []constants.UserScope{constants.UserScopeOrganizationDomainsUpdate},
+	}); err != nil {
+		return err
+	}
+
+	db := middleware.GetDB(c)
+	dnsResolver := middleware.GetDnsResolver(c)
+
+	// Not in a transaction, so it isn't held open during the DNS lookup
+	domain, err := verifyOrganizationDomain(VerifyOrganizationDomainServiceRequest{
+		OrganizationID: paramOrgID,
+		DomainID:       paramDomainID,
+		Tx:             db.WithContext(c.Request().Context()),
+		DnsResolver:    dnsResolver,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, OrganizationDomainResponse{Data: mapDomainToData(domain)})
+}
+
+func DeleteOrganizationDomainEndpoint(c echo.Context) error {
+	paramOrgID, err := api.ParseOrganizationIDFromParams(c)
+	if err != nil {
+		return err
+	}
+
+	paramDomainID, err := api.ParseDomainIDFromParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := access.HasOrganizationAccess(c, access.HasOrganizationAccessParams{
+		OrganizationID: paramOrgID,
+		Scopes:         []constants.UserScope{constants.UserScopeOrganizationDomainsDelete},
+	}); err != nil {
+		return err
+	}
+
+	db := middleware.GetDB(c)
+
+	err = deleteOrganizationDomain(DeleteOrganizationDomainServiceRequest{
+		OrganizationID: paramOrgID,
+		DomainID:       paramDomainID,
+		Tx:             db,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Organization Join Endpoints
+func GetJoinableOrganizationsEndpoint(c echo.Context) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err
+	}
+
+	db := middleware.GetDB(c)
+
+	joinable, err := getJoinableOrganizations(GetJoinableOrganizationsServiceRequest{
+		UserID: userID,
+		Tx:     db,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, mapJoinableOrganizationsToResponse(joinable))
+}
+
+func JoinOrganizationEndpoint(c echo.Context) error {
+	paramOrgID, err := api.ParseOrganizationIDFromParams(c)
+	if err != nil {
+		return err
+	}
+
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err
+	}
+
+	db := middleware.GetDB(c)
+
+	var membership *OrganizationMembershipDto
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		membership, err = joinOrganization(JoinOrganizationServiceRequest{
+			OrganizationID: paramOrgID,
+			UserID:         userID,
+			Tx:             tx,
+		})
+		return err
+	})
+
+	if err != nil {
+		return err // Middleware will handle all error types
+	}
+
+	return c.JSON(http.StatusCreated, CreateOrganizationMembershipResponse{
+		Data: mapMembershipToResponse(membership),
+	})
+}
+
+func CreateOrganizationJoinRequestEndpoint(c echo.Context) error {
+	paramOrgID, err := api.ParseOrganizationIDFromParams(c)
+	if err != nil {
+		return err
+	}
+
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err
+	}
+
+	db := middleware.GetDB(c)
+
+	var joinRequest *models.OrganizationJoinRequest
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		joinRequest, err = createOrganizationJoinRequest(CreateOrganizationJoinRequestServiceRequest{
+			OrganizationID: paramOrgID,
+			UserID:         userID,
+			Tx:             tx,
+		})
+		return err
+	})
+
+	if err != nil {
+		return err // Middleware will handle all error types
+	}
+
+	return c.JSON(http.StatusCreated, OrganizationJoinRequestResponse{Data: mapJoinRequestToData(joinRequest)})
+}
+
+func GetOrganizationJoinRequestsEndpoint(c echo.Context) error {
+	paramOrgID, err := api.ParseOrganizationIDFromParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := access.HasOrganizationAccess(c, access.HasOrganizationAccessParams{
+		OrganizationID: paramOrgID,
+		Scopes:         []constants.UserScope{constants.UserScopeOrganizationJoinRequestsList},
+	}); err != nil {
+		return err
+	}
+
+	db := middleware.GetDB(c)
+
+	joinRequests, err := getOrganizationJoinRequests(GetOrganizationJoinRequestsServiceRequest{
+		OrganizationID: paramOrgID,
+		Tx:             db,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, mapJoinRequestsToResponse(joinRequests))
+}
+
+func ApproveOrganizationJoinRequestEndpoint(c echo.Context) error {
+	return reviewOrganizationJoinRequestEndpoint(c, true)
+}
+
+func DenyOrganizationJoinRequestEndpoint(c echo.Context) error {
+	return reviewOrganizationJoinRequestEndpoint(c, false)
+}
+
+func reviewOrganizationJoinRequestEndpoint(c echo.Context, approve bool) error {
+	paramOrgID, err := api.ParseOrganizationIDFromParams(c)
+	if err != nil {
+		return err
+	}
+
+	paramJoinRequestID, err := api.ParseJoinRequestIDFromParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := access.HasOrganizationAccess(c, access.HasOrganizationAccessParams{
+		OrganizationID: paramOrgID,
+		Scopes:         []constants.UserScope{constants.UserScopeOrganizationJoinRequestsUpdate},
+	}); err != nil {
+		return err
+	}
+
+	db := middleware.GetDB(c)
+
+	var joinRequest *models.OrganizationJoinRequest
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		joinRequest, err = reviewOrganizationJoinRequest(ReviewOrganizationJoinRequestServiceRequest{
+			Params: ReviewOrganizationJoinRequestParams{
+				OrganizationID: paramOrgID,
+				JoinRequestID:  paramJoinRequestID,
+				Approve:        approve,
+			},
+			Tx: tx,
+		})
+		return err
+	})
+
+	if err != nil {
+		return err // Middleware will handle all error types
+	}
+
+	return c.JSON(http.StatusOK, OrganizationJoinRequestResponse{Data: mapJoinRequestToData(joinRequest)})
+}
+
 func CreateStripeOnboardingLinkEndpoint(c echo.Context) error {
 	paramOrgID, err := api.ParseOrganizationIDFromString(c.Param("id"))
 	if err != nil {
@@ -900,3 +1478,183 @@ func mapInvitationsToResponse(invitations []*OrganizationInvitationDto) GetOrgan
 	}
 	return GetOrganizationInvitationsResponse{Data: data}
 }
+
+func mapApiKeyToData(apiKey *models.OrganizationApiKey) OrganizationApiKeyData {
+	data := OrganizationApiKeyData{
+		Id:   apiKey.ID.String(),
+		Type: constants.ApiTypeOrganizationApiKey,
+		Attributes: OrganizationApiKeyAttributes{
+			Name:        apiKey.Name,
+			TokenPrefix: apiKey.TokenPrefix,
+			Scopes:      authentication.ParseScopes(apiKey.Scopes),
+			CreatedAt:   apiKey.CreatedAt,
+			LastUsedAt:  apiKey.LastUsedAt,
+			LastUsedIp:  apiKey.LastUsedIp,
+		},
+		Relationships: OrganizationApiKeyRelationships{
+			Organization: OrganizationRelationshipData{
+				Data: OrganizationRelationshipDataObject{
+					Id:   apiKey.OrganizationID.String(),
+					Type: constants.ApiTypeOrganization,
+				},
+			},
+		},
+	}
+
+	// Only show when the previous secret stops working while it still works
+	if apiKey.PreviousTokenExpiresAt != nil && apiKey.PreviousTokenExpiresAt.After(time.Now()) {
+		data.Attributes.PreviousTokenExpiresAt = apiKey.PreviousTokenExpiresAt
+	}
+
+	return data
+}
+
+func mapApiKeyDtoToResponse(dto *OrganizationApiKeyDto) OrganizationApiKeyResponse {
+	data := mapApiKeyToData(dto.ApiKey)
+	data.Meta = &OrganizationApiKeyMeta{
+		Token: dto.Token,
+	}
+	return OrganizationApiKeyResponse{Data: data}
+}
+
+func mapApiKeysToResponse(apiKeys []models.OrganizationApiKey) OrganizationApiKeysResponse {
+	data := make([]OrganizationApiKeyData, 0, len(apiKeys))
+	for i := range apiKeys {
+		data = append(data, mapApiKeyToData(&apiKeys[i]))
+	}
+	return OrganizationApiKeysResponse{Data: data}
+}
+
+func mapSamlConnectionToResponse(connection *models.OrganizationSamlConnection, config *configuration.Config) OrganizationSamlConnectionResponse {
+	urls := authentication.GetSamlServiceProviderUrls(config, connection.OrganizationID)
+
+	return OrganizationSamlConnectionResponse{
+		Data: OrganizationSamlConnectionData{
+			Id:   connection.ID.String(),
+			Type: constants.ApiTypeOrganizationSamlConnection,
+			Attributes: OrganizationSamlConnectionAttributes{
+				IdpEntityId:    connection.IdpEntityID,
+				IdpSsoUrl:      connection.IdpSsoUrl,
+				IdpCertificate: connection.IdpCertificate,
+				EmailAttribute: connection.EmailAttribute,
+				NameAttribute:  connection.NameAttribute,
+				DefaultRole:    connection.DefaultRole,
+				SsoRequired:    connection.SsoRequired,
+				SpEntityId:     urls.EntityID,
+				SpAcsUrl:       urls.AcsUrl,
+				CreatedAt:      connection.CreatedAt,
+				UpdatedAt:      connection.UpdatedAt,
+			},
+			Relationships: OrganizationSamlConnectionRelationships{
+				Organization: OrganizationRelationshipData{
+					Data: OrganizationRelationshipDataObject{
+						Id:   connection.OrganizationID.String(),
+						Type: constants.ApiTypeOrganization,
+					},
+				},
+			},
+		},
+	}
+}
+
+func mapDomainToData(domain *models.OrganizationDomain) OrganizationDomainData {
+	recordName, recordValue := authentication.GetDomainVerificationRecord(domain.Domain, domain.VerificationToken)
+
+	return OrganizationDomainData{
+		Id:   domain.ID.String(),
+		Type: constants.ApiTypeOrganizationDomain,
+		Attributes: OrganizationDomainAttributes{
+			Domain:      domain.Domain,
+			JoinPolicy:  domain.JoinPolicy,
+			DefaultRole: domain.DefaultRole,
+			VerifiedAt:  domain.VerifiedAt,
+			CreatedAt:   domain.CreatedAt,
+			UpdatedAt:   domain.UpdatedAt,
+		},
+		Relationships: OrganizationDomainRelationships{
+			Organization: OrganizationRelationshipData{
+				Data: OrganizationRelationshipDataObject{
+					Id:   domain.OrganizationID.String(),
+					Type: constants.ApiTypeOrganization,
+				},
+			},
+		},
+		Meta: OrganizationDomainMeta{
+			VerificationRecordName:  recordName,
+			VerificationRecordValue: recordValue,
+		},
+	}
+}
+
+func mapDomainsToResponse(domains []models.OrganizationDomain) OrganizationDomainsResponse {
+	data := make([]OrganizationDomainData, 0, len(domains))
+	for i := range domains {
+		data = append(data, mapDomainToData(&domains[i]))
+	}
+	return OrganizationDomainsResponse{Data: data}
+}
+
+func mapJoinRequestToData(joinRequest *models.OrganizationJoinRequest) OrganizationJoinRequestData {
+	return OrganizationJoinRequestData{
+		Id:   joinRequest.ID.String(),
+		Type: constants.ApiTypeOrganizationJoinRequest,
+		Attributes: OrganizationJoinRequestAttributes{
+			Role:      joinRequest.Role,
+			Status:    joinRequest.Status,
+			CreatedAt: joinRequest.CreatedAt,
+		},
+		Relationships: OrganizationJoinRequestRelationships{
+			User: UserRelationshipData{
+				Data: UserRelationshipDataObject{
+					Id:   joinRequest.UserID.String(),
+					Type: constants.ApiTypeUser,
+				},
+			},
+			Organization: OrganizationRelationshipData{
+				Data: OrganizationRelationshipDataObject{
+					Id:   joinRequest.OrganizationID.String(),
+					Type: constants.ApiTypeOrganization,
+				},
+			},
+		},
+	}
+}
+
+func mapJoinRequestsToResponse(joinRequests []models.OrganizationJoinRequest) OrganizationJoinRequestsResponse {
+	data := make([]OrganizationJoinRequestData, 0, len(joinRequests))
+	included := make([]interface{}, 0, len(joinRequests))
+	for i := range joinRequests {
+		data = append(data, mapJoinRequestToData(&joinRequests[i]))
+		included = append(included, UserIncludedData{
+			Id:   joinRequests[i].User.ID.String(),
+			Type: constants.ApiTypeUser,
+			Attributes: UserIncludedAttributes{
+				Name:  joinRequests[i].User.Name,
+				Email: joinRequests[i].User.Email,
+			},
+		})
+	}
+	return OrganizationJoinRequestsResponse{Data: data, Included: included}
+}
+
+func mapJoinableOrganizationsToResponse(joinable []*JoinableOrganizationDto) JoinableOrganizationsResponse {
+	data := make([]JoinableOrganizationData, 0, len(joinable))
+	for _, dto := range joinable {
+		attributes := JoinableOrganizationAttributes{
+			Name:        dto.Organization.Name,
+			Description: dto.Organization.Description,
+			Domain:      dto.Domain.Domain,
+			JoinPolicy:  dto.Domain.JoinPolicy,
+		}
+		if dto.JoinRequest != nil {
+			attributes.JoinRequestStatus = dto.JoinRequest.Status
+		}
+
+		data = append(data, JoinableOrganizationData{
+			Id:         dto.Organization.ID.String(),
+			Type:       constants.ApiTypeJoinableOrganization,
+			Attributes: attributes,
+		})
+	}
+	return JoinableOrganizationsResponse{Data: data}
+}
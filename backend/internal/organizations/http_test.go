@@ -2,7 +2,10 @@ package organizations_test
 
 import (
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -10,6 +13,7 @@ import (
 	"reece.start/internal/constants"
 	"reece.start/internal/models"
 	"reece.start/test"
+	"reece.start/test/mocks"
 )
 
 // createTokenWithOrganizationContext creates a token with organization context for testing
@@ -713,9 +717,10 @@ func TestAcceptOrganizationInvitationEndpoint(t *testing.T) {
 	tc.UnmarshalResponse(rec, &createResponse)
 	invitationID := createResponse["data"].(map[string]interface{})["id"].(string)
 
-	// Create the invitee user
+	// Create the invitee user, who hasn't verified their email yet
 	inviteeUser, _, inviteeToken := test.CreateTestUserWithOptions(t, tc, test.TestUserOptions{
-		Email: inviteeEmail,
+		Email:           inviteeEmail,
+		UnverifiedEmail: true,
 	})
 
 	// Make request to accept invitation
@@ -725,19 +730,30 @@ func TestAcceptOrganizationInvitationEndpoint(t *testing.T) {
 			"type": constants.ApiTypeOrganizationInvitation,
 		},
 	}
-	rec = tc.MakeAuthenticatedRequest(
-		http.MethodPost,
-		"/organization-invitations/"+invitationID+"/accept",
-		acceptReqBody,
-		inviteeToken,
-	)
+	acceptInvitation := func() *httptest.ResponseRecorder {
+		return tc.MakeAuthenticatedRequest(
+			http.MethodPost,
+			"/organization-invitations/"+invitationID+"/accept",
+			acceptReqBody,
+			inviteeToken,
+		)
+	}
+
+	// Only the owner of the address can accept the invitation
+	rec = acceptInvitation()
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	err := tc.DB.Model(inviteeUser).Update("email_verified_at", time.Now()).Error
+	require.NoError(t, err)
+
+	rec = acceptInvitation()
 
 	// Assert response
 	assert.Equal(t, http.StatusOK, rec.Code)
 
 	// Verify invitation status is accepted
 	var invitation models.OrganizationInvitation
-	err := tc.DB.Where("id = ?", invitationID).First(&invitation).Error
+	err = tc.DB.Where("id = ?", invitationID).First(&invitation).Error
 	require.NoError(t, err)
 	assert.Equal(t, string(constants.OrganizationInvitationStatusAccepted), invitation.Status)
 
@@ -821,3 +837,614 @@ func TestDeclineOrganizationInvitationEndpoint(t *testing.T) {
 	err = tc.DB.Where("organization_id = ? AND user_id = ?", org.ID, inviteeUser.ID).First(&membership).Error
 	require.Error(t, err)
 }
+
+// createApiKey creates an organization API key and returns its ID and secret
+func createApiKey(t *testing.T, tc *test.TestContext, token string, orgID uuid.UUID, scopes []constants.UserScope) (string, string) {
+	reqBody := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type": constants.ApiTypeOrganizationApiKey,
+			"attributes": map[string]interface{}{
+				"name":   "Integration",
+				"scopes": scopes,
+			},
+		},
+	}
+	rec := tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+orgID.String()+"/api-keys", reqBody, token)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var response map[string]interface{}
+	tc.UnmarshalResponse(rec, &response)
+	data := response["data"].(map[string]interface{})
+	meta := data["meta"].(map[string]interface{})
+	return data["id"].(string), meta["token"].(string)
+}
+
+func TestOrganizationApiKeyEndpoints(t *testing.T) {
+	t.Run("CreateAndList", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+
+		user, org, initialToken := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		token := createTokenWithOrganizationContext(t, tc, initialToken, org.ID)
+
+		apiKeyID, apiKey := createApiKey(t, tc, token, org.ID, []constants.UserScope{constants.UserScopeOrganizationRead})
+		assert.Contains(t, apiKey, "rs_key_")
+
+		var stored models.OrganizationApiKey
+		err := tc.DB.First(&stored, "id = ?", apiKeyID).Error
+		require.NoError(t, err)
+		assert.NotContains(t, stored.TokenHash, apiKey)
+		assert.Equal(t, user.ID, *stored.CreatedByUserID)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodGet, "/organizations/"+org.ID.String()+"/api-keys", nil, token)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response map[string]interface{}
+		tc.UnmarshalResponse(rec, &response)
+		data := response["data"].([]interface{})
+		require.Len(t, data, 1)
+		apiKeyData := data[0].(map[string]interface{})
+		assert.Equal(t, apiKeyID, apiKeyData["id"])
+		assert.Nil(t, apiKeyData["meta"])
+	})
+
+	t.Run("MemberCantCreate", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+
+		_, org, initialToken := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleMember)
+		token := createTokenWithOrganizationContext(t, tc, initialToken, org.ID)
+
+		reqBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"type": constants.ApiTypeOrganizationApiKey,
+				"attributes": map[string]interface{}{
+					"name":   "Integration",
+					"scopes": []constants.UserScope{constants.UserScopeOrganizationRead},
+				},
+			},
+		}
+		rec := tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+org.ID.String()+"/api-keys", reqBody, token)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("CantGrantApiKeyScopes", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+
+		_, org, initialToken := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		token := createTokenWithOrganizationContext(t, tc, initialToken, org.ID)
+
+		reqBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"type": constants.ApiTypeOrganizationApiKey,
+				"attributes": map[string]interface{}{
+					"name":   "Integration",
+					"scopes": []constants.UserScope{constants.UserScopeOrganizationApiKeysCreate},
+				},
+			},
+		}
+		rec := tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+org.ID.String()+"/api-keys", reqBody, token)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("CantGrantDestructiveScopes", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+
+		_, org, initialToken := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		token := createTokenWithOrganizationContext(t, tc, initialToken, org.ID)
+
+		for _, scope := range []constants.UserScope{
+			constants.UserScopeOrganizationDelete,
+			constants.UserScopeOrganizationMembershipsUpdate,
+			constants.UserScopeOrganizationMembershipsDelete,
+			constants.UserScopeOrganizationInvitationsCreate,
+		} {
+			reqBody := map[string]interface{}{
+				"data": map[string]interface{}{
+					"type": constants.ApiTypeOrganizationApiKey,
+					"attributes": map[string]interface{}{
+						"name":   "Integration",
+						"scopes": []constants.UserScope{constants.UserScopeOrganizationRead, scope},
+					},
+				},
+			}
+			rec := tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+org.ID.String()+"/api-keys", reqBody, token)
+			assert.Equal(t, http.StatusForbidden, rec.Code, scope)
+		}
+
+		var count int64
+		require.NoError(t, tc.DB.Model(&models.OrganizationApiKey{}).Where("organization_id = ?", org.ID).Count(&count).Error)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("AuthenticatesAsOrganization", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+
+		_, org, initialToken := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		token := createTokenWithOrganizationContext(t, tc, initialToken, org.ID)
+		_, apiKey := createApiKey(t, tc, token, org.ID, []constants.UserScope{constants.UserScopeOrganizationRead})
+
+		// The key can read its organization
+		rec := tc.MakeAuthenticatedRequest(http.MethodGet, "/organizations/"+org.ID.String(), nil, apiKey)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		// But only with the scopes it was given
+		reqBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"type": constants.ApiTypeOrganization,
+				"attributes": map[string]interface{}{
+					"name": "Updated Name",
+				},
+			},
+		}
+		rec = tc.MakeAuthenticatedRequest(http.MethodPatch, "/organizations/"+org.ID.String(), reqBody, apiKey)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		// It doesn't act as a user
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me", nil, apiKey)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		// And can't manage API keys
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/organizations/"+org.ID.String()+"/api-keys", nil, apiKey)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		var stored models.OrganizationApiKey
+		err := tc.DB.Where("organization_id = ?", org.ID).First(&stored).Error
+		require.NoError(t, err)
+		assert.NotNil(t, stored.LastUsedAt)
+	})
+
+	t.Run("CantAccessOtherOrganization", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+
+		_, org1, initialToken := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		_, org2, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		token := createTokenWithOrganizationContext(t, tc, initialToken, org1.ID)
+		_, apiKey := createApiKey(t, tc, token, org1.ID, []constants.UserScope{constants.UserScopeOrganizationRead})
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodGet, "/organizations/"+org2.ID.String(), nil, apiKey)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("RotateWithOverlap", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+
+		_, org, initialToken := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		token := createTokenWithOrganizationContext(t, tc, initialToken, org.ID)
+		apiKeyID, oldApiKey := createApiKey(t, tc, token, org.ID, []constants.UserScope{constants.UserScopeOrganizationRead})
+
+		reqBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"type":       constants.ApiTypeOrganizationApiKey,
+				"attributes": map[string]interface{}{},
+			},
+		}
+		rec := tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+org.ID.String()+"/api-keys/"+apiKeyID+"/rotate", reqBody, token)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response map[string]interface{}
+		tc.UnmarshalResponse(rec, &response)
+		data := response["data"].(map[string]interface{})
+		newApiKey := data["meta"].(map[string]interface{})["token"].(string)
+		assert.NotEqual(t, oldApiKey, newApiKey)
+		assert.NotNil(t, data["attributes"].(map[string]interface{})["previousTokenExpiresAt"])
+
+		// Both secrets work during the overlap
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/organizations/"+org.ID.String(), nil, oldApiKey)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/organizations/"+org.ID.String(), nil, newApiKey)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("RotateWithoutOverlap", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+
+		_, org, initialToken := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		token := createTokenWithOrganizationContext(t, tc, initialToken, org.ID)
+		apiKeyID, oldApiKey := createApiKey(t, tc, token, org.ID, []constants.UserScope{constants.UserScopeOrganizationRead})
+
+		reqBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"type": constants.ApiTypeOrganizationApiKey,
+				"attributes": map[string]interface{}{
+					"overlapSeconds": 0,
+				},
+			},
+		}
+		rec := tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+org.ID.String()+"/api-keys/"+apiKeyID+"/rotate", reqBody, token)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/organizations/"+org.ID.String(), nil, oldApiKey)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+
+		_, org, initialToken := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		token := createTokenWithOrganizationContext(t, tc, initialToken, org.ID)
+		apiKeyID, apiKey := createApiKey(t, tc, token, org.ID, []constants.UserScope{constants.UserScopeOrganizationRead})
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodDelete, "/organizations/"+org.ID.String()+"/api-keys/"+apiKeyID, nil, token)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/organizations/"+org.ID.String(), nil, apiKey)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodDelete, "/organizations/"+org.ID.String()+"/api-keys/"+apiKeyID, nil, token)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestOrganizationSamlConnectionEndpoints(t *testing.T) {
+	updateSamlConnection := func(tc *test.TestContext, token string, orgID uuid.UUID, attributes map[string]interface{}) *httptest.ResponseRecorder {
+		reqBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"type":       constants.ApiTypeOrganizationSamlConnection,
+				"attributes": attributes,
+			},
+		}
+		return tc.MakeAuthenticatedRequest(http.MethodPut, "/organizations/"+orgID.String()+"/saml", reqBody, token)
+	}
+
+	t.Run("CreateFromMetadataUpdateAndDelete", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		idp := mocks.NewSAMLIdentityProvider(t)
+
+		_, org, initialToken := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		token := createTokenWithOrganizationContext(t, tc, initialToken, org.ID)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodGet, "/organizations/"+org.ID.String()+"/saml", nil, token)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = updateSamlConnection(tc, token, org.ID, map[string]interface{}{
+			"idpMetadata": idp.MetadataXML(t),
+		})
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response map[string]interface{}
+		tc.UnmarshalResponse(rec, &response)
+		attributes := response["data"].(map[string]interface{})["attributes"].(map[string]interface{})
+		assert.Equal(t, idp.EntityID, attributes["idpEntityId"])
+		assert.Equal(t, idp.SsoUrl, attributes["idpSsoUrl"])
+		assert.Equal(t, "email", attributes["emailAttribute"])
+		assert.Equal(t, string(constants.OrganizationRoleMember), attributes["defaultRole"])
+		assert.Equal(t, false, attributes["ssoRequired"])
+		assert.Equal(t, tc.Config.ApiUrl+"/saml/"+org.ID.String()+"/acs", attributes["spAcsUrl"])
+
+		rec = updateSamlConnection(tc, token, org.ID, map[string]interface{}{
+			"idpEntityId":    idp.EntityID,
+			"idpSsoUrl":      idp.SsoUrl,
+			"idpCertificate": idp.CertificatePem,
+			"emailAttribute": "mail",
+			"ssoRequired":    true,
+		})
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var connection models.OrganizationSamlConnection
+		require.NoError(t, tc.DB.Where("organization_id = ?", org.ID).First(&connection).Error)
+		assert.Equal(t, "mail", connection.EmailAttribute)
+		assert.True(t, connection.SsoRequired)
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodDelete, "/organizations/"+org.ID.String()+"/saml", nil, token)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/organizations/"+org.ID.String()+"/saml", nil, token)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("InvalidCertificate", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		idp := mocks.NewSAMLIdentityProvider(t)
+
+		_, org, initialToken := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		token := createTokenWithOrganizationContext(t, tc, initialToken, org.ID)
+
+		rec := updateSamlConnection(tc, token, org.ID, map[string]interface{}{
+			"idpEntityId":    idp.EntityID,
+			"idpSsoUrl":      idp.SsoUrl,
+			"idpCertificate": "not a certificate",
+		})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("MemberCantUpdate", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		idp := mocks.NewSAMLIdentityProvider(t)
+
+		_, org, initialToken := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleMember)
+		token := createTokenWithOrganizationContext(t, tc, initialToken, org.ID)
+
+		rec := updateSamlConnection(tc, token, org.ID, map[string]interface{}{
+			"idpMetadata": idp.MetadataXML(t),
+		})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
+
+func TestOrganizationDomainEndpoints(t *testing.T) {
+	claimDomain := func(tc *test.TestContext, token string, orgID uuid.UUID, attributes map[string]interface{}) *httptest.ResponseRecorder {
+		reqBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"type":       constants.ApiTypeOrganizationDomain,
+				"attributes": attributes,
+			},
+		}
+		return tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+orgID.String()+"/domains", reqBody, token)
+	}
+
+	t.Run("ClaimVerifyUpdateAndDelete", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+
+		_, org, initialToken := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		token := createTokenWithOrganizationContext(t, tc, initialToken, org.ID)
+		domain := "acme-" + uuid.New().String()[:8] + ".example.com"
+
+		rec := claimDomain(tc, token, org.ID, map[string]interface{}{"domain": strings.ToUpper(domain) + "."})
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		var response map[string]interface{}
+		tc.UnmarshalResponse(rec, &response)
+		data := response["data"].(map[string]interface{})
+		domainID := data["id"].(string)
+		attributes := data["attributes"].(map[string]interface{})
+		meta := data["meta"].(map[string]interface{})
+		assert.Equal(t, domain, attributes["domain"])
+		assert.Equal(t, string(constants.OrganizationDomainJoinPolicyPrompt), attributes["joinPolicy"])
+		assert.Equal(t, string(constants.OrganizationRoleMember), attributes["defaultRole"])
+		assert.Nil(t, attributes["verifiedAt"])
+		assert.Equal(t, "_reece-start-verification."+domain, meta["verificationRecordName"])
+
+		// The TXT record hasn't been added yet
+		rec = tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+org.ID.String()+"/domains/"+domainID+"/verify", nil, token)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		tc.DnsResolver.SetTXTRecords(meta["verificationRecordName"].(string), "v=spf1 -all", meta["verificationRecordValue"].(string))
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+org.ID.String()+"/domains/"+domainID+"/verify", nil, token)
+		require.Equal(t, http.StatusOK, rec.Code)
+		tc.UnmarshalResponse(rec, &response)
+		assert.NotNil(t, response["data"].(map[string]interface{})["attributes"].(map[string]interface{})["verifiedAt"])
+
+		reqBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"type": constants.ApiTypeOrganizationDomain,
+				"attributes": map[string]interface{}{
+					"joinPolicy":  constants.OrganizationDomainJoinPolicyAutoJoin,
+					"defaultRole": constants.OrganizationRoleAdmin,
+				},
+			},
+		}
+		rec = tc.MakeAuthenticatedRequest(http.MethodPatch, "/organizations/"+org.ID.String()+"/domains/"+domainID, reqBody, token)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var stored models.OrganizationDomain
+		require.NoError(t, tc.DB.Where("id = ?", domainID).First(&stored).Error)
+		assert.Equal(t, string(constants.OrganizationDomainJoinPolicyAutoJoin), stored.JoinPolicy)
+		assert.Equal(t, string(constants.OrganizationRoleAdmin), stored.DefaultRole)
+		assert.NotNil(t, stored.VerifiedAt)
+
+		// Claiming the same domain again conflicts
+		rec = claimDomain(tc, token, org.ID, map[string]interface{}{"domain": domain})
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/organizations/"+org.ID.String()+"/domains", nil, token)
+		require.Equal(t, http.StatusOK, rec.Code)
+		tc.UnmarshalResponse(rec, &response)
+		assert.Len(t, response["data"].([]interface{}), 1)
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodDelete, "/organizations/"+org.ID.String()+"/domains/"+domainID, nil, token)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodDelete, "/organizations/"+org.ID.String()+"/domains/"+domainID, nil, token)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("InvalidDomain", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+
+		_, org, initialToken := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		token := createTokenWithOrganizationContext(t, tc, initialToken, org.ID)
+
+		rec := claimDomain(tc, token, org.ID, map[string]interface{}{"domain": "user@example.com"})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("OnlyOneOrganizationCanVerify", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		domain := "acme-" + uuid.New().String()[:8] + ".example.com"
+
+		_, org, initialToken := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		token := createTokenWithOrganizationContext(t, tc, initialToken, org.ID)
+		createVerifiedDomain(t, tc, token, org.ID, domain, constants.OrganizationDomainJoinPolicyPrompt)
+
+		_, otherOrg, otherInitialToken := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		otherToken := createTokenWithOrganizationContext(t, tc, otherInitialToken, otherOrg.ID)
+
+		// Claiming works, so an organization can't block others by claiming a domain it doesn't own
+		rec := claimDomain(tc, otherToken, otherOrg.ID, map[string]interface{}{"domain": domain})
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		var response map[string]interface{}
+		tc.UnmarshalResponse(rec, &response)
+		data := response["data"].(map[string]interface{})
+		meta := data["meta"].(map[string]interface{})
+		tc.DnsResolver.SetTXTRecords(meta["verificationRecordName"].(string), meta["verificationRecordValue"].(string))
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+otherOrg.ID.String()+"/domains/"+data["id"].(string)+"/verify", nil, otherToken)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("MemberCantClaim", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+
+		_, org, initialToken := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleMember)
+		token := createTokenWithOrganizationContext(t, tc, initialToken, org.ID)
+
+		rec := claimDomain(tc, token, org.ID, map[string]interface{}{"domain": "example.com"})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
+
+// createVerifiedDomain claims and verifies a domain for the organization, and returns the domain's ID
+func createVerifiedDomain(t *testing.T, tc *test.TestContext, token string, orgID uuid.UUID, domain string, joinPolicy constants.OrganizationDomainJoinPolicy) string {
+	reqBody := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type": constants.ApiTypeOrganizationDomain,
+			"attributes": map[string]interface{}{
+				"domain":     domain,
+				"joinPolicy": joinPolicy,
+			},
+		},
+	}
+	rec := tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+orgID.String()+"/domains", reqBody, token)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var response map[string]interface{}
+	tc.UnmarshalResponse(rec, &response)
+	data := response["data"].(map[string]interface{})
+	meta := data["meta"].(map[string]interface{})
+	tc.DnsResolver.SetTXTRecords(meta["verificationRecordName"].(string), meta["verificationRecordValue"].(string))
+
+	rec = tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+orgID.String()+"/domains/"+data["id"].(string)+"/verify", nil, token)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	return data["id"].(string)
+}
+
+func TestOrganizationDomainJoin(t *testing.T) {
+	setup := func(t *testing.T, joinPolicy constants.OrganizationDomainJoinPolicy) (*test.TestContext, *models.Organization, string, string) {
+		tc := test.SetupEchoTest(t)
+		domain := "acme-" + uuid.New().String()[:8] + ".example.com"
+
+		_, org, initialToken := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		adminToken := createTokenWithOrganizationContext(t, tc, initialToken, org.ID)
+		createVerifiedDomain(t, tc, adminToken, org.ID, domain, joinPolicy)
+
+		return tc, org, adminToken, domain
+	}
+
+	countMemberships := func(t *testing.T, tc *test.TestContext, orgID uuid.UUID, userID uuid.UUID) int64 {
+		var count int64
+		require.NoError(t, tc.DB.Model(&models.OrganizationMembership{}).Where("organization_id = ? AND user_id = ?", orgID, userID).Count(&count).Error)
+		return count
+	}
+
+	t.Run("AutoJoinOnSignIn", func(t *testing.T) {
+		tc, org, _, domain := setup(t, constants.OrganizationDomainJoinPolicyAutoJoin)
+
+		user, password, _ := test.CreateTestUserWithOptions(t, tc, test.TestUserOptions{Email: "jane@" + domain})
+		test.LoginTestUser(t, tc, user.Email, password)
+
+		var membership models.OrganizationMembership
+		require.NoError(t, tc.DB.Where("organization_id = ? AND user_id = ?", org.ID, user.ID).First(&membership).Error)
+		assert.Equal(t, string(constants.OrganizationRoleMember), membership.Role)
+
+		// Removed users aren't added again
+		require.NoError(t, tc.DB.Delete(&membership).Error)
+		test.LoginTestUser(t, tc, user.Email, password)
+		assert.Equal(t, int64(0), countMemberships(t, tc, org.ID, user.ID))
+	})
+
+	t.Run("AutoJoinRequiresVerifiedEmail", func(t *testing.T) {
+		tc, org, _, domain := setup(t, constants.OrganizationDomainJoinPolicyAutoJoin)
+
+		user, password, _ := test.CreateTestUserWithOptions(t, tc, test.TestUserOptions{Email: "jane@" + domain, UnverifiedEmail: true})
+		test.LoginTestUser(t, tc, user.Email, password)
+
+		assert.Equal(t, int64(0), countMemberships(t, tc, org.ID, user.ID))
+	})
+
+	t.Run("PromptedToJoin", func(t *testing.T) {
+		tc, org, _, domain := setup(t, constants.OrganizationDomainJoinPolicyPrompt)
+
+		user, password, _ := test.CreateTestUserWithOptions(t, tc, test.TestUserOptions{Email: "jane@" + domain})
+		token := test.LoginTestUser(t, tc, user.Email, password)
+		assert.Equal(t, int64(0), countMemberships(t, tc, org.ID, user.ID))
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodGet, "/organizations/joinable", nil, token)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response map[string]interface{}
+		tc.UnmarshalResponse(rec, &response)
+		joinable := response["data"].([]interface{})
+		require.Len(t, joinable, 1)
+		assert.Equal(t, org.ID.String(), joinable[0].(map[string]interface{})["id"])
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+org.ID.String()+"/join", nil, token)
+		require.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, int64(1), countMemberships(t, tc, org.ID, user.ID))
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/organizations/joinable", nil, token)
+		require.Equal(t, http.StatusOK, rec.Code)
+		tc.UnmarshalResponse(rec, &response)
+		assert.Empty(t, response["data"])
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+org.ID.String()+"/join", nil, token)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("OtherDomainsCantJoin", func(t *testing.T) {
+		tc, org, _, _ := setup(t, constants.OrganizationDomainJoinPolicyPrompt)
+
+		_, _, token := test.CreateTestUser(t, tc)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+org.ID.String()+"/join", nil, token)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+org.ID.String()+"/join-requests", nil, token)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("ApprovalRequired", func(t *testing.T) {
+		tc, org, adminToken, domain := setup(t, constants.OrganizationDomainJoinPolicyApproval)
+
+		user, _, token := test.CreateTestUserWithOptions(t, tc, test.TestUserOptions{Email: "jane@" + domain})
+		deniedUser, _, deniedToken := test.CreateTestUserWithOptions(t, tc, test.TestUserOptions{Email: "john@" + domain})
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+org.ID.String()+"/join", nil, token)
+		require.Equal(t, http.StatusForbidden, rec.Code)
+
+		var errResponse map[string]interface{}
+		tc.UnmarshalResponse(rec, &errResponse)
+		assert.Equal(t, "join_approval_required", errResponse["code"])
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+org.ID.String()+"/join-requests", nil, token)
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+org.ID.String()+"/join-requests", nil, token)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+org.ID.String()+"/join-requests", nil, deniedToken)
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/organizations/"+org.ID.String()+"/join-requests", nil, adminToken)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response map[string]interface{}
+		tc.UnmarshalResponse(rec, &response)
+		requests := response["data"].([]interface{})
+		require.Len(t, requests, 2)
+		assert.Len(t, response["included"], 2)
+
+		requestIDs := map[string]string{}
+		for _, request := range requests {
+			request := request.(map[string]interface{})
+			userID := request["relationships"].(map[string]interface{})["user"].(map[string]interface{})["data"].(map[string]interface{})["id"].(string)
+			requestIDs[userID] = request["id"].(string)
+		}
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+org.ID.String()+"/join-requests/"+requestIDs[user.ID.String()]+"/approve", nil, adminToken)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, int64(1), countMemberships(t, tc, org.ID, user.ID))
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+org.ID.String()+"/join-requests/"+requestIDs[user.ID.String()]+"/deny", nil, adminToken)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+org.ID.String()+"/join-requests/"+requestIDs[deniedUser.ID.String()]+"/deny", nil, adminToken)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, int64(0), countMemberships(t, tc, org.ID, deniedUser.ID))
+
+		// A denied request can't be repeated
+		rec = tc.MakeAuthenticatedRequest(http.MethodPost, "/organizations/"+org.ID.String()+"/join-requests", nil, deniedToken)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+}
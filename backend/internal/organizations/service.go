@@ -9,12 +9,15 @@ import (
 	"log/slog"
 	"net/http"
 	"net/url"
+	"slices"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/minio/minio-go/v7"
 	stripeGo "github.com/stripe/stripe-go/v83"
 	"gorm.io/gorm"
 	"reece.start/internal/api"
+	"reece.start/internal/authentication"
 	"reece.start/internal/constants"
 	"reece.start/internal/models"
 	"reece.start/internal/stripe"
@@ -328,8 +331,44 @@ func deleteOrganization(request DeleteOrganizationServiceRequest) error {
 	tx := request.Tx
 	organizationID := request.OrganizationID
 
+	// Revoke the tokens of every member, since their tokens may still be scoped to this organization
+	var memberUserIDs []uuid.UUID
+	err := tx.Model(&models.OrganizationMembership{}).Where("organization_id = ?", organizationID).Pluck("user_id", &memberUserIDs).Error
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range memberUserIDs {
+		err = authentication.RevokeUserTokens(tx, userID, true)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Delete organization memberships first (due to foreign key constraints)
-	err := tx.Where("organization_id = ?", organizationID).Delete(&models.OrganizationMembership{}).Error
+	err = tx.Where("organization_id = ?", organizationID).Delete(&models.OrganizationMembership{}).Error
+	if err != nil {
+		return err
+	}
+
+	// Delete the organization's API keys, so they stop working right away
+	err = tx.Unscoped().Where("organization_id = ?", organizationID).Delete(&models.OrganizationApiKey{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = tx.Unscoped().Where("organization_id = ?", organizationID).Delete(&models.OrganizationSamlConnection{}).Error
+	if err != nil {
+		return err
+	}
+
+	// Release the organization's domains, so another organization can verify them
+	err = tx.Unscoped().Where("organization_id = ?", organizationID).Delete(&models.OrganizationDomain{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = tx.Unscoped().Where("organization_id = ?", organizationID).Delete(&models.OrganizationJoinRequest{}).Error
 	if err != nil {
 		return err
 	}
@@ -501,7 +540,7 @@ func updateOrganizationMembership(request UpdateOrganizationMembershipServiceReq
 		membership.Role = *params.Role
 
 		// Also update the user's token revocation
-		err = tx.Model(&models.User{}).Where("id = ?", membership.User.ID).Update("revocation_can_refresh", true).Update("revocation_last_valid_issued_at", time.Now()).Error
+		err = authentication.RevokeUserTokens(tx, membership.User.ID, true)
 		if err != nil {
 			return nil, err
 		}
@@ -525,8 +564,20 @@ func deleteOrganizationMembership(request DeleteOrganizationMembershipServiceReq
 	tx := request.Tx
 	membershipID := request.MembershipID
 
+	var membership models.OrganizationMembership
+	err := tx.First(&membership, membershipID).Error
+	if err != nil {
+		return err
+	}
+
+	// Revoke the user's tokens so they can no longer act within the organization
+	err = authentication.RevokeUserTokens(tx, membership.UserID, true)
+	if err != nil {
+		return err
+	}
+
 	// Delete the membership
-	err := tx.Delete(&models.OrganizationMembership{}, membershipID).Error
+	err = tx.Delete(&models.OrganizationMembership{}, membershipID).Error
 	if err != nil {
 		return err
 	}
@@ -711,6 +762,11 @@ func acceptOrganizationInvitation(request AcceptOrganizationInvitationServiceReq
 		return nil, api.ErrInvitationEmailMismatch
 	}
 
+	// Only the owner of the address can accept an invitation sent to it
+	if user.EmailVerifiedAt == nil {
+		return nil, api.ErrEmailNotVerified
+	}
+
 	// Check if user is already a member of the organization
 	var existingMembership models.OrganizationMembership
 	err = tx.Where("user_id = ? AND organization_id = ?", userID, invitation.OrganizationID).
@@ -799,6 +855,595 @@ func declineOrganizationInvitation(request DeclineOrganizationInvitationServiceR
 	})
 }
 
+// Organization API Key Service Functions
+func getOrganizationApiKeys(request GetOrganizationApiKeysServiceRequest) ([]models.OrganizationApiKey, error) {
+	tx := request.Tx
+
+	var apiKeys []models.OrganizationApiKey
+	err := tx.Where("organization_id = ?", request.OrganizationID).Order("created_at DESC").Find(&apiKeys).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return apiKeys, nil
+}
+
+func createOrganizationApiKey(request CreateOrganizationApiKeyServiceRequest) (*OrganizationApiKeyDto, error) {
+	tx := request.Tx
+	params := request.Params
+
+	// Keys can only carry the scopes that are safe to hand to another system
+	grantableScopes := authentication.GetApiKeyGrantableScopes()
+	scopes := make([]constants.UserScope, 0, len(params.Scopes))
+	for _, scope := range params.Scopes {
+		if !slices.Contains(grantableScopes, scope) {
+			return nil, api.ErrApiKeyScopeDenied
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	token, tokenHash, tokenPrefix, err := authentication.GenerateApiKey()
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey := models.OrganizationApiKey{
+		OrganizationID:  params.OrganizationID,
+		Name:            params.Name,
+		TokenHash:       tokenHash,
+		TokenPrefix:     tokenPrefix,
+		Scopes:          authentication.FormatScopes(scopes),
+		CreatedByUserID: &params.UserID,
+	}
+
+	err = tx.Create(&apiKey).Error
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Created organization API key", "organizationID", params.OrganizationID, "apiKeyID", apiKey.ID, "userID", params.UserID)
+
+	return &OrganizationApiKeyDto{
+		ApiKey: &apiKey,
+		Token:  token,
+	}, nil
+}
+
+func rotateOrganizationApiKey(request RotateOrganizationApiKeyServiceRequest) (*OrganizationApiKeyDto, error) {
+	tx := request.Tx
+	params := request.Params
+
+	var apiKey models.OrganizationApiKey
+	err := tx.Where("id = ? AND organization_id = ?", params.ApiKeyID, params.OrganizationID).First(&apiKey).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrApiKeyNotFound
+		}
+		return nil, err
+	}
+
+	token, tokenHash, tokenPrefix, err := authentication.GenerateApiKey()
+	if err != nil {
+		return nil, err
+	}
+
+	// The current secret keeps working for the overlap window, so integrations can switch to the new one.
+	// A secret left over from an earlier rotation is dropped, only one previous secret is ever accepted.
+	previousTokenExpiresAt := time.Now().Add(params.Overlap)
+	apiKey.PreviousTokenHash = apiKey.TokenHash
+	apiKey.PreviousTokenExpiresAt = &previousTokenExpiresAt
+	apiKey.TokenHash = tokenHash
+	apiKey.TokenPrefix = tokenPrefix
+
+	err = tx.Save(&apiKey).Error
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Rotated organization API key", "organizationID", params.OrganizationID, "apiKeyID", apiKey.ID, "previousTokenExpiresAt", previousTokenExpiresAt)
+
+	return &OrganizationApiKeyDto{
+		ApiKey: &apiKey,
+		Token:  token,
+	}, nil
+}
+
+func deleteOrganizationApiKey(request DeleteOrganizationApiKeyServiceRequest) error {
+	tx := request.Tx
+
+	result := tx.Unscoped().Where("id = ? AND organization_id = ?", request.ApiKeyID, request.OrganizationID).Delete(&models.OrganizationApiKey{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return api.ErrApiKeyNotFound
+	}
+
+	slog.Info("Deleted organization API key", "organizationID", request.OrganizationID, "apiKeyID", request.ApiKeyID)
+
+	return nil
+}
+
+// Organization SAML Connection Service Functions
+func getOrganizationSamlConnection(request GetOrganizationSamlConnectionServiceRequest) (*models.OrganizationSamlConnection, error) {
+	tx := request.Tx
+
+	var connection models.OrganizationSamlConnection
+	err := tx.Where("organization_id = ?", request.OrganizationID).First(&connection).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrSamlConnectionNotFound
+		}
+		return nil, err
+	}
+
+	return &connection, nil
+}
+
+// updateOrganizationSamlConnection creates or replaces the organization's SAML connection
+func updateOrganizationSamlConnection(request UpdateOrganizationSamlConnectionServiceRequest) (*models.OrganizationSamlConnection, error) {
+	tx := request.Tx
+	params := request.Params
+
+	// Settings given directly take precedence over the uploaded metadata
+	settings := authentication.SamlIdpSettings{}
+	if params.IdpMetadata != "" {
+		metadataSettings, err := authentication.ParseSamlIdpMetadata(params.IdpMetadata)
+		if err != nil {
+			return nil, err
+		}
+		settings = *metadataSettings
+	}
+	if params.IdpEntityID != "" {
+		settings.EntityID = params.IdpEntityID
+	}
+	if params.IdpSsoUrl != "" {
+		settings.SsoUrl = params.IdpSsoUrl
+	}
+	if params.IdpCertificate != "" {
+		settings.Certificate = params.IdpCertificate
+	}
+
+	if settings.EntityID == "" || settings.SsoUrl == "" {
+		return nil, api.ErrSamlMetadataInvalid
+	}
+	if _, err := authentication.ParseSamlCertificates(settings.Certificate); err != nil {
+		return nil, err
+	}
+
+	var connection models.OrganizationSamlConnection
+	err := tx.Where("organization_id = ?", params.OrganizationID).First(&connection).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	connection.OrganizationID = params.OrganizationID
+	connection.IdpEntityID = settings.EntityID
+	connection.IdpSsoUrl = settings.SsoUrl
+	connection.IdpCertificate = settings.Certificate
+	connection.EmailAttribute = params.EmailAttribute
+	connection.NameAttribute = params.NameAttribute
+	connection.DefaultRole = params.DefaultRole
+	connection.SsoRequired = params.SsoRequired
+
+	if connection.EmailAttribute == "" {
+		connection.EmailAttribute = "email"
+	}
+	if connection.NameAttribute == "" {
+		connection.NameAttribute = "name"
+	}
+	if connection.DefaultRole == "" {
+		connection.DefaultRole = string(constants.OrganizationRoleMember)
+	}
+
+	err = tx.Save(&connection).Error
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Updated organization SAML connection", "organizationID", params.OrganizationID, "idpEntityID", connection.IdpEntityID, "ssoRequired", connection.SsoRequired)
+
+	return &connection, nil
+}
+
+func deleteOrganizationSamlConnection(request DeleteOrganizationSamlConnectionServiceRequest) error {
+	tx := request.Tx
+
+	result := tx.Unscoped().Where("organization_id = ?", request.OrganizationID).Delete(&models.OrganizationSamlConnection{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return api.ErrSamlConnectionNotFound
+	}
+
+	slog.Info("Deleted organization SAML connection", "organizationID", request.OrganizationID)
+
+	return nil
+}
+
+// Organization Domain Service Functions
+func getOrganizationDomains(request GetOrganizationDomainsServiceRequest) ([]models.OrganizationDomain, error) {
+	tx := request.Tx
+
+	var domains []models.OrganizationDomain
+	err := tx.Where("organization_id = ?", request.OrganizationID).Order("domain ASC").Find(&domains).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return domains, nil
+}
+
+func getOrganizationDomain(tx *gorm.DB, organizationID uuid.UUID, domainID uuid.UUID) (*models.OrganizationDomain, error) {
+	var domain models.OrganizationDomain
+	err := tx.Where("id = ? AND organization_id = ?", domainID, organizationID).First(&domain).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrDomainNotFound
+		}
+		return nil, err
+	}
+
+	return &domain, nil
+}
+
+// createOrganizationDomain claims a domain for the organization. Users can only join through it once it's verified.
+func createOrganizationDomain(request CreateOrganizationDomainServiceRequest) (*models.OrganizationDomain, error) {
+	tx := request.Tx
+	params := request.Params
+
+	name, err := authentication.NormalizeDomain(params.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	verificationToken, _, err := authentication.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	domain := models.OrganizationDomain{
+		OrganizationID:    params.OrganizationID,
+		Domain:            name,
+		VerificationToken: verificationToken,
+		JoinPolicy:        params.JoinPolicy,
+		DefaultRole:       params.DefaultRole,
+	}
+
+	if domain.JoinPolicy == "" {
+		domain.JoinPolicy = string(constants.OrganizationDomainJoinPolicyPrompt)
+	}
+	if domain.DefaultRole == "" {
+		domain.DefaultRole = string(constants.OrganizationRoleMember)
+	}
+
+	err = tx.Create(&domain).Error
+	if err != nil {
+		if api.IsUniqueConstraintViolation(err) {
+			return nil, api.ErrDomainAlreadyClaimed
+		}
+		return nil, err
+	}
+
+	slog.Info("Claimed organization domain", "organizationID", params.OrganizationID, "domain", domain.Domain)
+
+	return &domain, nil
+}
+
+func updateOrganizationDomain(request UpdateOrganizationDomainServiceRequest) (*models.OrganizationDomain, error) {
+	tx := request.Tx
+	params := request.Params
+
+	domain, err := getOrganizationDomain(tx, params.OrganizationID, params.DomainID)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.JoinPolicy != nil {
+		domain.JoinPolicy = *params.JoinPolicy
+	}
+	if params.DefaultRole != nil {
+		domain.DefaultRole = *params.DefaultRole
+	}
+
+	err = tx.Save(domain).Error
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Updated organization domain", "organizationID", params.OrganizationID, "domain", domain.Domain, "joinPolicy", domain.JoinPolicy, "defaultRole", domain.DefaultRole)
+
+	return domain, nil
+}
+
+// verifyOrganizationDomain checks the domain's verification TXT record, and marks the domain as verified if it has
+// one. Only one organization can verify a domain, so users with an email address at it can only join one organization.
+func verifyOrganizationDomain(request VerifyOrganizationDomainServiceRequest) (*models.OrganizationDomain, error) {
+	tx := request.Tx
+
+	domain, err := getOrganizationDomain(tx, request.OrganizationID, request.DomainID)
+	if err != nil {
+		return nil, err
+	}
+
+	if domain.VerifiedAt != nil {
+		return domain, nil
+	}
+
+	var count int64
+	err = tx.Model(&models.OrganizationDomain{}).Where("domain = ? AND verified_at IS NOT NULL", domain.Domain).Count(&count).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if count > 0 {
+		return nil, api.ErrDomainAlreadyVerified
+	}
+
+	err = authentication.VerifyDomainOwnership(tx.Statement.Context, request.DnsResolver, domain.Domain, domain.VerificationToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// The unique index on verified domains stops another organization verifying it at the same time
+	now := time.Now()
+	err = tx.Model(domain).Update("verified_at", now).Error
+	if err != nil {
+		if api.IsUniqueConstraintViolation(err) {
+			return nil, api.ErrDomainAlreadyVerified
+		}
+		return nil, err
+	}
+	domain.VerifiedAt = &now
+
+	slog.Info("Verified organization domain", "organizationID", request.OrganizationID, "domain", domain.Domain)
+
+	return domain, nil
+}
+
+func deleteOrganizationDomain(request DeleteOrganizationDomainServiceRequest) error {
+	tx := request.Tx
+
+	result := tx.Unscoped().Where("id = ? AND organization_id = ?", request.DomainID, request.OrganizationID).Delete(&models.OrganizationDomain{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return api.ErrDomainNotFound
+	}
+
+	slog.Info("Deleted organization domain", "organizationID", request.OrganizationID, "domainID", request.DomainID)
+
+	return nil
+}
+
+// Organization Join Service Functions
+
+// getJoinableOrganizations returns the organizations the user can join through the domain of their email address.
+// Only one organization can verify a domain, so there is at most one.
+func getJoinableOrganizations(request GetJoinableOrganizationsServiceRequest) ([]*JoinableOrganizationDto, error) {
+	tx := request.Tx
+
+	var user models.User
+	err := tx.First(&user, request.UserID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	domain, err := authentication.GetJoinableOrganizationDomain(tx, &user)
+	if err != nil {
+		return nil, err
+	}
+
+	if domain == nil {
+		return []*JoinableOrganizationDto{}, nil
+	}
+
+	var organization models.Organization
+	err = tx.First(&organization, domain.OrganizationID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	dto := &JoinableOrganizationDto{
+		Domain:       domain,
+		Organization: &organization,
+	}
+
+	var joinRequests []models.OrganizationJoinRequest
+	err = tx.Where("organization_id = ? AND user_id = ?", domain.OrganizationID, user.ID).Order("created_at DESC").Limit(1).Find(&joinRequests).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(joinRequests) > 0 {
+		dto.JoinRequest = &joinRequests[0]
+	}
+
+	return []*JoinableOrganizationDto{dto}, nil
+}
+
+// getJoinDomain returns the verified domain the user can join the organization through
+func getJoinDomain(tx *gorm.DB, organizationID uuid.UUID, userID uuid.UUID) (*models.OrganizationDomain, error) {
+	var user models.User
+	err := tx.First(&user, userID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	domain, err := authentication.GetJoinableOrganizationDomain(tx, &user)
+	if err != nil {
+		return nil, err
+	}
+
+	if domain == nil || domain.OrganizationID != organizationID {
+		return nil, api.ErrOrganizationNotJoinable
+	}
+
+	return domain, nil
+}
+
+// joinOrganization adds the user to an organization they can join through their email's domain without approval
+func joinOrganization(request JoinOrganizationServiceRequest) (*OrganizationMembershipDto, error) {
+	tx := request.Tx
+
+	domain, err := getJoinDomain(tx, request.OrganizationID, request.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if domain.JoinPolicy == string(constants.OrganizationDomainJoinPolicyApproval) {
+		return nil, api.ErrJoinApprovalRequired
+	}
+
+	membership := &models.OrganizationMembership{
+		UserID:         request.UserID,
+		OrganizationID: request.OrganizationID,
+		Role:           domain.DefaultRole,
+	}
+
+	err = tx.Create(&membership).Error
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("User joined organization through verified domain", "userID", request.UserID, "organizationID", request.OrganizationID, "domain", domain.Domain, "role", membership.Role)
+
+	// Reload with preloaded relationships
+	err = tx.Preload("User").Preload("Organization").First(&membership, membership.ID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &OrganizationMembershipDto{
+		Membership:   membership,
+		User:         &membership.User,
+		Organization: &membership.Organization,
+	}, nil
+}
+
+// createOrganizationJoinRequest asks to join an organization whose domain requires an admin's approval.
+// Users can only ask once, so a denied request can't be repeated.
+func createOrganizationJoinRequest(request CreateOrganizationJoinRequestServiceRequest) (*models.OrganizationJoinRequest, error) {
+	tx := request.Tx
+
+	domain, err := getJoinDomain(tx, request.OrganizationID, request.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if domain.JoinPolicy != string(constants.OrganizationDomainJoinPolicyApproval) {
+		return nil, api.ErrJoinApprovalNotRequired
+	}
+
+	var count int64
+	err = tx.Model(&models.OrganizationJoinRequest{}).Where("organization_id = ? AND user_id = ?", request.OrganizationID, request.UserID).Count(&count).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if count > 0 {
+		return nil, api.ErrJoinRequestAlreadyExists
+	}
+
+	joinRequest := models.OrganizationJoinRequest{
+		OrganizationID: request.OrganizationID,
+		UserID:         request.UserID,
+		Role:           domain.DefaultRole,
+		Status:         string(constants.OrganizationJoinRequestStatusPending),
+	}
+
+	err = tx.Create(&joinRequest).Error
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("User asked to join organization through verified domain", "userID", request.UserID, "organizationID", request.OrganizationID, "domain", domain.Domain)
+
+	return &joinRequest, nil
+}
+
+// getOrganizationJoinRequests returns the organization's pending join requests, oldest first
+func getOrganizationJoinRequests(request GetOrganizationJoinRequestsServiceRequest) ([]models.OrganizationJoinRequest, error) {
+	tx := request.Tx
+
+	var joinRequests []models.OrganizationJoinRequest
+	err := tx.Preload("User").
+		Where("organization_id = ? AND status = ?", request.OrganizationID, string(constants.OrganizationJoinRequestStatusPending)).
+		Order("created_at ASC").
+		Find(&joinRequests).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return joinRequests, nil
+}
+
+// reviewOrganizationJoinRequest approves or denies a pending join request. Approving it adds the user to the
+// organization with the role of the domain at the time they asked.
+func reviewOrganizationJoinRequest(request ReviewOrganizationJoinRequestServiceRequest) (*models.OrganizationJoinRequest, error) {
+	tx := request.Tx
+	params := request.Params
+
+	var joinRequest models.OrganizationJoinRequest
+	err := tx.Where("id = ? AND organization_id = ?", params.JoinRequestID, params.OrganizationID).First(&joinRequest).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrJoinRequestNotFound
+		}
+		return nil, err
+	}
+
+	if joinRequest.Status != string(constants.OrganizationJoinRequestStatusPending) {
+		return nil, api.ErrJoinRequestNotPending
+	}
+
+	joinRequest.Status = string(constants.OrganizationJoinRequestStatusDenied)
+
+	if params.Approve {
+		joinRequest.Status = string(constants.OrganizationJoinRequestStatusApproved)
+
+		var count int64
+		err = tx.Model(&models.OrganizationMembership{}).Where("organization_id = ? AND user_id = ?", joinRequest.OrganizationID, joinRequest.UserID).Count(&count).Error
+		if err != nil {
+			return nil, err
+		}
+
+		// The user may have been added some other way in the meantime
+		if count == 0 {
+			err = tx.Create(&models.OrganizationMembership{
+				UserID:         joinRequest.UserID,
+				OrganizationID: joinRequest.OrganizationID,
+				Role:           joinRequest.Role,
+			}).Error
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	err = tx.Save(&joinRequest).Error
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Reviewed organization join request", "joinRequestID", joinRequest.ID, "organizationID", joinRequest.OrganizationID, "userID", joinRequest.UserID, "status", joinRequest.Status)
+
+	return &joinRequest, nil
+}
+
 func updateOrganizationStripeInformation(request UpdateOrganizationStripeInformationServiceRequest) error {
 	organization := request.Organization
 	stripeAccount := request.StripeAccount
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/minio/minio-go/v7"
@@ -705,7 +706,8 @@ func TestAcceptOrganizationInvitation(t *testing.T) {
 		defer tx.Rollback()
 
 		invitingUser := &models.User{Name: "Inviting User", Email: "inviting@example.com"}
-		inviteeUser := &models.User{Name: "Invitee User", Email: "invitee@example.com"}
+		now := time.Now()
+		inviteeUser := &models.User{Name: "Invitee User", Email: "invitee@example.com", EmailVerifiedAt: &now}
 		organization := &models.Organization{Name: "Test Organization"}
 		tx.Create(invitingUser)
 		tx.Create(inviteeUser)
@@ -743,7 +745,8 @@ func TestAcceptOrganizationInvitation(t *testing.T) {
 		defer tx.Rollback()
 
 		invitingUser := &models.User{Name: "Inviting User", Email: "inviting@example.com"}
-		wrongUser := &models.User{Name: "Wrong User", Email: "wrong@example.com"}
+		now := time.Now()
+		wrongUser := &models.User{Name: "Wrong User", Email: "wrong@example.com", EmailVerifiedAt: &now}
 		organization := &models.Organization{Name: "Test Organization"}
 		tx.Create(invitingUser)
 		tx.Create(wrongUser)
@@ -774,7 +777,8 @@ func TestAcceptOrganizationInvitation(t *testing.T) {
 		defer tx.Rollback()
 
 		invitingUser := &models.User{Name: "Inviting User", Email: "inviting@example.com"}
-		inviteeUser := &models.User{Name: "Invitee User", Email: "invitee@example.com"}
+		now := time.Now()
+		inviteeUser := &models.User{Name: "Invitee User", Email: "invitee@example.com", EmailVerifiedAt: &now}
 		organization := &models.Organization{Name: "Test Organization"}
 		tx.Create(invitingUser)
 		tx.Create(inviteeUser)
@@ -799,6 +803,41 @@ func TestAcceptOrganizationInvitation(t *testing.T) {
 		assert.Error(t, err)
 		assert.True(t, errors.Is(err, api.ErrInvitationNotPending))
 	})
+
+	t.Run("returns error for unverified email", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		invitingUser := &models.User{Name: "Inviting User", Email: "inviting@example.com"}
+		inviteeUser := &models.User{Name: "Invitee User", Email: "invitee@example.com"}
+		organization := &models.Organization{Name: "Test Organization"}
+		tx.Create(invitingUser)
+		tx.Create(inviteeUser)
+		tx.Create(organization)
+
+		invitation := &models.OrganizationInvitation{
+			Email:          "invitee@example.com",
+			OrganizationID: organization.ID,
+			InvitingUserID: invitingUser.ID,
+			Role:           string(constants.OrganizationRoleAdmin),
+			Status:         string(constants.OrganizationInvitationStatusPending),
+		}
+		tx.Create(invitation)
+
+		_, err := acceptOrganizationInvitation(AcceptOrganizationInvitationServiceRequest{
+			InvitationID: invitation.ID,
+			UserID:       inviteeUser.ID,
+			Tx:           tx,
+			MinioClient:  minioClient,
+		})
+
+		assert.Error(t, err)
+		assert.True(t, errors.Is(err, api.ErrEmailNotVerified))
+
+		var count int64
+		tx.Model(&models.OrganizationMembership{}).Where("user_id = ?", inviteeUser.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
 }
 
 func TestDeclineOrganizationInvitation(t *testing.T) {
@@ -1,7 +1,14 @@
 package posthog
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/posthog/posthog-go"
 	"reece.start/internal/configuration"
@@ -11,6 +18,11 @@ import (
 type Client struct {
 	client  posthog.Client
 	enabled bool
+
+	// Private API used to delete persons, which needs a personal API key
+	apiHost        string
+	personalApiKey string
+	projectId      string
 }
 
 // NewClient creates a new PostHog client. Returns a no-op client if PostHog is not configured.
@@ -36,8 +48,11 @@ func NewClient(config *configuration.Config) *Client {
 
 	slog.Info("PostHog client initialized", "host", config.PostHogHost)
 	return &Client{
-		client:  client,
-		enabled: true,
+		client:         client,
+		enabled:        true,
+		apiHost:        config.PostHogApiHost,
+		personalApiKey: config.PostHogPersonalApiKey,
+		projectId:      config.PostHogProjectId,
 	}
 }
 
@@ -57,6 +72,42 @@ func (c *Client) Capture(distinctID string, event string, properties map[string]
 	}
 }
 
+// DeletePerson deletes the person with the distinct ID and their events. No-op if PostHog or its personal API key
+// is not configured.
+func (c *Client) DeletePerson(ctx context.Context, distinctID string) error {
+	if !c.enabled || c.personalApiKey == "" || c.projectId == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"distinct_ids":  []string{distinctID},
+		"delete_events": true,
+	})
+	if err != nil {
+		return err
+	}
+
+	endpoint := strings.TrimSuffix(c.apiHost, "/") + "/api/projects/" + url.PathEscape(c.projectId) + "/persons/bulk_delete/"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.personalApiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("failed to delete PostHog person, status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
 // Close closes the PostHog client and flushes any pending events.
 func (c *Client) Close() error {
 	if !c.enabled || c.client == nil {
@@ -5,9 +5,11 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"reece.start/internal/api"
+	"reece.start/internal/authentication"
 	"reece.start/internal/configuration"
 	appMiddleware "reece.start/internal/middleware"
 	"reece.start/internal/organizations"
+	"reece.start/internal/scim"
 	"reece.start/internal/stripe"
 	"reece.start/internal/users"
 )
@@ -16,18 +18,53 @@ import (
 func Register(e *echo.Echo, config *configuration.Config) {
 	// Create authentication middleware
 	auth := appMiddleware.JwtAuthMiddleware(config)
+	refreshAuth := appMiddleware.JwtRefreshAuthMiddleware(config)
+	sessionAuth := appMiddleware.JwtSessionAuthMiddleware(config)
+	tokenAuth := appMiddleware.JwtTokenAuthMiddleware(config)
 
 	// Health check
 	e.GET("/", func(c echo.Context) error {
 		return c.String(http.StatusOK, "Hello, World!")
 	})
 
+	// Public keys used to verify tokens
+	e.GET("/.well-known/jwks.json", authentication.JwksEndpoint(config))
+
 	// Public user routes (no authentication required)
 	e.POST("/users", api.Validated(users.CreateUserEndpoint))
 	e.POST("/users/login", api.Validated(users.LoginEndpoint))
+	e.POST("/users/login/mfa", api.Validated(users.LoginMfaEndpoint))
+	e.POST("/users/login/passkey/options", users.BeginPasskeyLoginEndpoint)
+	e.POST("/users/login/passkey", api.Validated(users.LoginPasskeyEndpoint))
+	e.POST("/users/login/magic-link/request", api.Validated(users.RequestMagicLinkEndpoint))
+	e.POST("/users/login/magic-link", api.Validated(users.LoginMagicLinkEndpoint))
+	e.POST("/users/login/saml", api.Validated(users.LoginSamlEndpoint))
+	e.POST("/users/password-reset", api.Validated(users.RequestPasswordResetEndpoint))
+	e.POST("/users/password-reset/confirm", api.Validated(users.ConfirmPasswordResetEndpoint))
+	e.POST("/users/email-verification/confirm", api.Validated(users.ConfirmEmailVerificationEndpoint))
 
 	// Public OAuth routes (no authentication required)
-	e.POST("/oauth/google/callback", api.Validated(users.GoogleOAuthCallbackEndpoint))
+	e.POST("/oauth/:provider/authorize", api.Validated(users.StartOAuthAuthorizationEndpoint))
+	e.POST("/oauth/:provider/callback", api.Validated(users.OAuthCallbackEndpoint))
+
+	// Public SAML routes (no authentication required). Each organization is a separate service provider.
+	e.GET("/saml/:id/metadata", users.SamlMetadataEndpoint)
+	e.POST("/saml/:id/login", users.StartSamlLoginEndpoint)
+	e.POST("/saml/:id/acs", users.SamlAcsEndpoint)
+
+	// SCIM provisioning routes, authenticated with an organization API key. Errors are returned in the SCIM format.
+	scimErrors := appMiddleware.ScimErrorHandlingMiddleware
+	e.GET("/scim/v2/:id/ServiceProviderConfig", scim.GetServiceProviderConfigEndpoint, scimErrors, auth)
+	e.GET("/scim/v2/:id/Users", scim.ListUsersEndpoint, scimErrors, auth)
+	e.POST("/scim/v2/:id/Users", scim.CreateUserEndpoint, scimErrors, auth)
+	e.GET("/scim/v2/:id/Users/:userId", scim.GetUserEndpoint, scimErrors, auth)
+	e.PUT("/scim/v2/:id/Users/:userId", scim.ReplaceUserEndpoint, scimErrors, auth)
+	e.PATCH("/scim/v2/:id/Users/:userId", scim.PatchUserEndpoint, scimErrors, auth)
+	e.DELETE("/scim/v2/:id/Users/:userId", scim.DeleteUserEndpoint, scimErrors, auth)
+	e.GET("/scim/v2/:id/Groups", scim.ListGroupsEndpoint, scimErrors, auth)
+	e.GET("/scim/v2/:id/Groups/:groupId", scim.GetGroupEndpoint, scimErrors, auth)
+	e.PUT("/scim/v2/:id/Groups/:groupId", scim.ReplaceGroupEndpoint, scimErrors, auth)
+	e.PATCH("/scim/v2/:id/Groups/:groupId", scim.PatchGroupEndpoint, scimErrors, auth)
 
 	// Webhook routes (no authentication required)
 	e.POST("/webhooks/stripe/account/snapshot", stripe.StripeSnapshotWebhookEndpoint)
@@ -35,9 +72,54 @@ func Register(e *echo.Echo, config *configuration.Config) {
 
 	// Protected user routes
 	e.GET("/users/me", users.GetAuthenticatedUserEndpoint, auth)
+	e.DELETE("/users/me", users.DeleteAuthenticatedUserEndpoint, sessionAuth)
 	e.GET("/users", api.ValidatedQuery(users.GetUsersEndpoint), auth)
-	e.POST("/users/me/token", api.Validated(users.CreateAuthenticatedUserTokenEndpoint), auth)
+	e.POST("/users/me/token", api.Validated(users.CreateAuthenticatedUserTokenEndpoint), tokenAuth)
+	e.POST("/users/me/token/refresh", users.RefreshAuthenticatedUserTokenEndpoint, refreshAuth)
+	e.POST("/users/token/rotate", api.Validated(users.RotateRefreshTokenEndpoint))
 	e.PATCH("/users/:id", api.Validated(users.UpdateUserEndpoint), auth)
+	e.POST("/users/me/email-verification", users.SendEmailVerificationEndpoint, auth)
+	e.DELETE("/users/:id/mfa", users.ResetUserMfaEndpoint, auth)
+
+	// Protected admin login lockout routes
+	e.GET("/login-lockouts", users.GetLoginLockoutsEndpoint, auth)
+	e.DELETE("/login-lockouts/:id", users.DeleteLoginLockoutEndpoint, auth)
+
+	// Protected admin impersonation log routes
+	e.GET("/impersonations", api.ValidatedQuery(users.GetImpersonationsEndpoint), auth)
+
+	// Protected MFA routes
+	e.POST("/users/me/mfa/totp", users.StartTotpEnrollmentEndpoint, sessionAuth)
+	e.POST("/users/me/mfa/totp/confirm", api.Validated(users.ConfirmTotpEnrollmentEndpoint), sessionAuth)
+	e.POST("/users/me/mfa/recovery-codes", api.Validated(users.RegenerateMfaRecoveryCodesEndpoint), sessionAuth)
+	e.POST("/users/me/mfa/disable", api.Validated(users.DisableMfaEndpoint), sessionAuth)
+
+	// Protected passkey routes
+	e.GET("/users/me/passkeys", users.GetPasskeysEndpoint, sessionAuth)
+	e.POST("/users/me/passkeys/options", users.BeginPasskeyRegistrationEndpoint, sessionAuth)
+	e.POST("/users/me/passkeys", api.Validated(users.CreatePasskeyEndpoint), sessionAuth)
+	e.PATCH("/users/me/passkeys/:id", api.Validated(users.UpdatePasskeyEndpoint), sessionAuth)
+	e.DELETE("/users/me/passkeys/:id", users.DeletePasskeyEndpoint, sessionAuth)
+
+	// Protected session routes
+	e.GET("/users/me/sessions", users.GetUserSessionsEndpoint, sessionAuth)
+	e.DELETE("/users/me/sessions", users.DeleteOtherUserSessionsEndpoint, sessionAuth)
+	e.DELETE("/users/me/sessions/:id", users.DeleteUserSessionEndpoint, sessionAuth)
+
+	// Protected personal access token routes
+	e.GET("/users/me/access-tokens", users.GetAccessTokensEndpoint, sessionAuth)
+	e.POST("/users/me/access-tokens", api.Validated(users.CreateAccessTokenEndpoint), sessionAuth)
+	e.DELETE("/users/me/access-tokens/:id", users.DeleteAccessTokenEndpoint, sessionAuth)
+
+	// Protected linked identity routes
+	e.GET("/users/me/identities", users.GetUserIdentitiesEndpoint, sessionAuth)
+	e.POST("/users/me/identities/:provider", api.Validated(users.LinkUserIdentityEndpoint), sessionAuth)
+	e.DELETE("/users/me/identities/:id", users.DeleteUserIdentityEndpoint, sessionAuth)
+	e.POST("/users/me/password", api.Validated(users.SetInitialPasswordEndpoint), sessionAuth)
+
+	// Protected user data export routes
+	e.POST("/users/me/export", users.CreateUserDataExportEndpoint, sessionAuth)
+	e.GET("/users/me/export", users.GetUserDataExportEndpoint, sessionAuth)
 
 	// Protected organization routes
 	e.GET("/organizations", organizations.GetOrganizationsEndpoint, auth)
@@ -51,6 +133,32 @@ func Register(e *echo.Echo, config *configuration.Config) {
 	e.POST("/organizations/:id/checkout-session", api.Validated(stripe.CreateCheckoutSessionEndpoint), auth)
 	e.POST("/organizations/:id/billing-portal-session", api.Validated(stripe.CreateBillingPortalSessionEndpoint), auth)
 
+	// Protected organization API key routes (keys can't be used to manage keys)
+	e.GET("/organizations/:id/api-keys", organizations.GetOrganizationApiKeysEndpoint, sessionAuth)
+	e.POST("/organizations/:id/api-keys", api.Validated(organizations.CreateOrganizationApiKeyEndpoint), sessionAuth)
+	e.POST("/organizations/:id/api-keys/:apiKeyId/rotate", api.Validated(organizations.RotateOrganizationApiKeyEndpoint), sessionAuth)
+	e.DELETE("/organizations/:id/api-keys/:apiKeyId", organizations.DeleteOrganizationApiKeyEndpoint, sessionAuth)
+
+	// Protected organization SAML connection routes
+	e.GET("/organizations/:id/saml", organizations.GetOrganizationSamlConnectionEndpoint, sessionAuth)
+	e.PUT("/organizations/:id/saml", api.Validated(organizations.UpdateOrganizationSamlConnectionEndpoint), sessionAuth)
+	e.DELETE("/organizations/:id/saml", organizations.DeleteOrganizationSamlConnectionEndpoint, sessionAuth)
+
+	// Protected organization domain routes
+	e.GET("/organizations/:id/domains", organizations.GetOrganizationDomainsEndpoint, sessionAuth)
+	e.POST("/organizations/:id/domains", api.Validated(organizations.CreateOrganizationDomainEndpoint), sessionAuth)
+	e.PATCH("/organizations/:id/domains/:domainId", api.Validated(organizations.UpdateOrganizationDomainEndpoint), sessionAuth)
+	e.POST("/organizations/:id/domains/:domainId/verify", organizations.VerifyOrganizationDomainEndpoint, sessionAuth)
+	e.DELETE("/organizations/:id/domains/:domainId", organizations.DeleteOrganizationDomainEndpoint, sessionAuth)
+
+	// Protected routes for joining organizations through the verified domain of the user's email address
+	e.GET("/organizations/joinable", organizations.GetJoinableOrganizationsEndpoint, sessionAuth)
+	e.POST("/organizations/:id/join", organizations.JoinOrganizationEndpoint, sessionAuth)
+	e.POST("/organizations/:id/join-requests", organizations.CreateOrganizationJoinRequestEndpoint, sessionAuth)
+	e.GET("/organizations/:id/join-requests", organizations.GetOrganizationJoinRequestsEndpoint, auth)
+	e.POST("/organizations/:id/join-requests/:joinRequestId/approve", organizations.ApproveOrganizationJoinRequestEndpoint, auth)
+	e.POST("/organizations/:id/join-requests/:joinRequestId/deny", organizations.DenyOrganizationJoinRequestEndpoint, auth)
+
 	// Protected organization membership routes
 	e.GET("/organization-memberships", api.ValidatedQuery(organizations.GetOrganizationMembershipsEndpoint), auth)
 	e.GET("/organization-memberships/:id", organizations.GetOrganizationMembershipEndpoint, auth)
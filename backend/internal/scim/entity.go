@@ -0,0 +1,362 @@
+package scim
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/riverqueue/river"
+	"gorm.io/gorm"
+	"reece.start/internal/configuration"
+	"reece.start/internal/constants"
+	"reece.start/internal/models"
+	"reece.start/internal/posthog"
+)
+
+// SCIM API Types. SCIM has its own resource format (RFC 7643), so these don't follow the rest of the API.
+
+type Meta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      *time.Time `json:"created,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	Location     string     `json:"location"`
+}
+
+type UserName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type UserEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type GroupReference struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+type UserResource struct {
+	Schemas     []string         `json:"schemas"`
+	ID          string           `json:"id,omitempty"`
+	ExternalID  string           `json:"externalId,omitempty"`
+	UserName    string           `json:"userName"`
+	Name        *UserName        `json:"name,omitempty"`
+	DisplayName string           `json:"displayName,omitempty"`
+	Emails      []UserEmail      `json:"emails,omitempty"`
+	Active      *bool            `json:"active,omitempty"`
+	Groups      []GroupReference `json:"groups,omitempty"`
+	Meta        *Meta            `json:"meta,omitempty"`
+}
+
+type GroupResource struct {
+	Schemas     []string         `json:"schemas"`
+	ID          string           `json:"id,omitempty"`
+	DisplayName string           `json:"displayName"`
+	Members     []GroupReference `json:"members"`
+	Meta        *Meta            `json:"meta,omitempty"`
+}
+
+type ListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []any    `json:"Resources"`
+}
+
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+type ServiceProviderConfigSupported struct {
+	Supported bool `json:"supported"`
+}
+
+type ServiceProviderConfigFilter struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+type ServiceProviderConfigBulk struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"`
+}
+
+type AuthenticationScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type ServiceProviderConfigResponse struct {
+	Schemas               []string                       `json:"schemas"`
+	Patch                 ServiceProviderConfigSupported `json:"patch"`
+	Bulk                  ServiceProviderConfigBulk      `json:"bulk"`
+	Filter                ServiceProviderConfigFilter    `json:"filter"`
+	ChangePassword        ServiceProviderConfigSupported `json:"changePassword"`
+	Sort                  ServiceProviderConfigSupported `json:"sort"`
+	Etag                  ServiceProviderConfigSupported `json:"etag"`
+	AuthenticationSchemes []AuthenticationScheme         `json:"authenticationSchemes"`
+}
+
+// Service Types
+
+type ListParams struct {
+	OrganizationID uuid.UUID
+	Filter         string
+	StartIndex     int
+	Count          int
+}
+
+type ScimUserDto struct {
+	User       *models.User
+	Membership *models.OrganizationMembership // Soft deleted while the user is deactivated
+}
+
+type ListUsersServiceRequest struct {
+	Params ListParams
+	Tx     *gorm.DB
+}
+
+type ListUsersServiceResponse struct {
+	Users        []ScimUserDto
+	TotalResults int
+	StartIndex   int
+}
+
+type GetUserServiceRequest struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+	Tx             *gorm.DB
+}
+
+type CreateUserParams struct {
+	OrganizationID uuid.UUID
+	ApiKeyID       uuid.UUID
+	Resource       UserResource
+}
+
+type CreateUserServiceRequest struct {
+	Params        CreateUserParams
+	Tx            *gorm.DB
+	RiverClient   *river.Client[*sql.Tx]
+	PostHogClient *posthog.Client
+}
+
+// CreateUserServiceResponse has either the provisioned user, or the invitation sent to a user who already has an
+// account
+// CreateUserServiceResponse holds either the provisioned user, or the invitation sent to a user who already has an
+// account along with that account
+type CreateUserServiceResponse struct {
+	User        *ScimUserDto
+	InvitedUser *models.User
+	Invitation  *models.OrganizationInvitation
+}
+
+type ReplaceUserParams struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+	Resource       UserResource
+}
+
+type ReplaceUserServiceRequest struct {
+	Params ReplaceUserParams
+	Tx     *gorm.DB
+}
+
+type PatchUserParams struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+	Operations     []PatchOperation
+}
+
+type PatchUserServiceRequest struct {
+	Params PatchUserParams
+	Tx     *gorm.DB
+}
+
+type DeleteUserServiceRequest struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+	Tx             *gorm.DB
+}
+
+type ScimGroupDto struct {
+	Role        constants.OrganizationRole
+	Memberships []models.OrganizationMembership // With the user preloaded
+}
+
+type ListGroupsServiceRequest struct {
+	Params ListParams
+	Tx     *gorm.DB
+}
+
+type ListGroupsServiceResponse struct {
+	Groups       []ScimGroupDto
+	TotalResults int
+	StartIndex   int
+}
+
+type GetGroupServiceRequest struct {
+	OrganizationID uuid.UUID
+	GroupID        string
+	Tx             *gorm.DB
+}
+
+type PatchGroupParams struct {
+	OrganizationID uuid.UUID
+	GroupID        string
+	Operations     []PatchOperation
+}
+
+type PatchGroupServiceRequest struct {
+	Params PatchGroupParams
+	Tx     *gorm.DB
+}
+
+type ReplaceGroupParams struct {
+	OrganizationID uuid.UUID
+	GroupID        string
+	Resource       GroupResource
+}
+
+type ReplaceGroupServiceRequest struct {
+	Params ReplaceGroupParams
+	Tx     *gorm.DB
+}
+
+// Mappers
+
+// getResourceLocation returns the URL of a resource in an organization's SCIM API
+func getResourceLocation(config *configuration.Config, organizationID uuid.UUID, resourceType string, id string) string {
+	return config.ApiUrl + "/scim/v2/" + organizationID.String() + "/" + resourceType + "/" + id
+}
+
+func mapUserToResource(dto *ScimUserDto, config *configuration.Config) UserResource {
+	user := dto.User
+	membership := dto.Membership
+
+	active := !membership.DeletedAt.Valid
+	lastModified := membership.UpdatedAt
+	if user.UpdatedAt.After(lastModified) {
+		lastModified = user.UpdatedAt
+	}
+
+	resource := UserResource{
+		Schemas:     []string{constants.ScimSchemaUser},
+		ID:          user.ID.String(),
+		ExternalID:  membership.ScimExternalID,
+		UserName:    user.Email,
+		Name:        &UserName{Formatted: user.Name},
+		DisplayName: user.Name,
+		Emails:      []UserEmail{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta: &Meta{
+			ResourceType: "User",
+			Created:      &membership.CreatedAt,
+			LastModified: &lastModified,
+			Location:     getResourceLocation(config, membership.OrganizationID, "Users", user.ID.String()),
+		},
+	}
+
+	// Deactivated users aren't in any group
+	if active {
+		resource.Groups = []GroupReference{
+			{
+				Value:   membership.Role,
+				Ref:     getResourceLocation(config, membership.OrganizationID, "Groups", membership.Role),
+				Display: membership.Role,
+			},
+		}
+	}
+
+	return resource
+}
+
+// mapInvitationToResource maps a user who was invited to the organization. They stay inactive until they accept.
+func mapInvitationToResource(user *models.User, invitation *models.OrganizationInvitation, externalID string, config *configuration.Config) UserResource {
+	active := false
+
+	return UserResource{
+		Schemas:     []string{constants.ScimSchemaUser},
+		ID:          user.ID.String(),
+		ExternalID:  externalID,
+		UserName:    user.Email,
+		Name:        &UserName{Formatted: user.Name},
+		DisplayName: user.Name,
+		Emails:      []UserEmail{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta: &Meta{
+			ResourceType: "User",
+			Created:      &invitation.CreatedAt,
+			LastModified: &invitation.UpdatedAt,
+			Location:     getResourceLocation(config, invitation.OrganizationID, "Users", user.ID.String()),
+		},
+	}
+}
+
+func mapGroupToResource(group *ScimGroupDto, organizationID uuid.UUID, config *configuration.Config) GroupResource {
+	members := make([]GroupReference, 0, len(group.Memberships))
+	for _, membership := range group.Memberships {
+		members = append(members, GroupReference{
+			Value:   membership.UserID.String(),
+			Ref:     getResourceLocation(config, organizationID, "Users", membership.UserID.String()),
+			Display: membership.User.Email,
+		})
+	}
+
+	return GroupResource{
+		Schemas:     []string{constants.ScimSchemaGroup},
+		ID:          string(group.Role),
+		DisplayName: string(group.Role),
+		Members:     members,
+		Meta: &Meta{
+			ResourceType: "Group",
+			Location:     getResourceLocation(config, organizationID, "Groups", string(group.Role)),
+		},
+	}
+}
+
+func mapUsersToListResponse(response *ListUsersServiceResponse, config *configuration.Config) ListResponse {
+	resources := make([]any, 0, len(response.Users))
+	for i := range response.Users {
+		resources = append(resources, mapUserToResource(&response.Users[i], config))
+	}
+
+	return ListResponse{
+		Schemas:      []string{constants.ScimSchemaListResponse},
+		TotalResults: response.TotalResults,
+		StartIndex:   response.StartIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+func mapGroupsToListResponse(response *ListGroupsServiceResponse, organizationID uuid.UUID, config *configuration.Config) ListResponse {
+	resources := make([]any, 0, len(response.Groups))
+	for i := range response.Groups {
+		resources = append(resources, mapGroupToResource(&response.Groups[i], organizationID, config))
+	}
+
+	return ListResponse{
+		Schemas:      []string{constants.ScimSchemaListResponse},
+		TotalResults: response.TotalResults,
+		StartIndex:   response.StartIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
@@ -0,0 +1,429 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"reece.start/internal/access"
+	"reece.start/internal/api"
+	"reece.start/internal/constants"
+	"reece.start/internal/middleware"
+)
+
+// Content type of SCIM requests and responses
+const contentType = "application/scim+json; charset=utf-8"
+
+func GetServiceProviderConfigEndpoint(c echo.Context) error {
+	_, err := checkScimAccess(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return respond(c, http.StatusOK, ServiceProviderConfigResponse{
+		Schemas: []string{constants.ScimSchemaServiceProviderConfig},
+		Patch:   ServiceProviderConfigSupported{Supported: true},
+		Bulk:    ServiceProviderConfigBulk{Supported: false},
+		Filter:  ServiceProviderConfigFilter{Supported: true, MaxResults: maxPageSize},
+		AuthenticationSchemes: []AuthenticationScheme{
+			{
+				Type:        "oauthbearertoken",
+				Name:        "Organization API Key",
+				Description: "An organization API key with the organization:scim:provision scope, sent as a bearer token",
+			},
+		},
+	})
+}
+
+func ListUsersEndpoint(c echo.Context) error {
+	organizationID, err := checkScimAccess(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+
+	response, err := listUsers(ListUsersServiceRequest{
+		Params: parseListParams(c, organizationID),
+		Tx:     db.WithContext(c.Request().Context()),
+	})
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return respond(c, http.StatusOK, mapUsersToListResponse(response, config))
+}
+
+func GetUserEndpoint(c echo.Context) error {
+	organizationID, err := checkScimAccess(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	userID, err := parseUserID(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+
+	user, err := getUser(GetUserServiceRequest{
+		OrganizationID: organizationID,
+		UserID:         userID,
+		Tx:             db.WithContext(c.Request().Context()),
+	})
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return respond(c, http.StatusOK, mapUserToResource(user, config))
+}
+
+func CreateUserEndpoint(c echo.Context) error {
+	organizationID, err := checkScimAccess(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	var resource UserResource
+	err = bind(c, &resource)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	apiKeyID, err := middleware.GetApiKeyIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+	riverClient := middleware.GetRiverClient(c)
+	posthogClient := middleware.GetPostHogClient(c)
+
+	var created *CreateUserServiceResponse
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		created, err = createUser(CreateUserServiceRequest{
+			Params: CreateUserParams{
+				OrganizationID: organizationID,
+				ApiKeyID:       apiKeyID,
+				Resource:       resource,
+			},
+			Tx:            tx,
+			RiverClient:   riverClient,
+			PostHogClient: posthogClient,
+		})
+		return err
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	// Users who already have an account are only added once they accept the invitation. Until then they're reported as
+	// accepted but inactive, so the identity provider doesn't retry.
+	if created.Invitation != nil {
+		response := mapInvitationToResource(created.InvitedUser, created.Invitation, resource.ExternalID, config)
+		c.Response().Header().Set(echo.HeaderLocation, response.Meta.Location)
+		return respond(c, http.StatusAccepted, response)
+	}
+
+	response := mapUserToResource(created.User, config)
+	c.Response().Header().Set(echo.HeaderLocation, response.Meta.Location)
+	return respond(c, http.StatusCreated, response)
+}
+
+func ReplaceUserEndpoint(c echo.Context) error {
+	organizationID, err := checkScimAccess(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	userID, err := parseUserID(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	var resource UserResource
+	err = bind(c, &resource)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+
+	var user *ScimUserDto
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		user, err = replaceUser(ReplaceUserServiceRequest{
+			Params: ReplaceUserParams{
+				OrganizationID: organizationID,
+				UserID:         userID,
+				Resource:       resource,
+			},
+			Tx: tx,
+		})
+		return err
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return respond(c, http.StatusOK, mapUserToResource(user, config))
+}
+
+func PatchUserEndpoint(c echo.Context) error {
+	organizationID, err := checkScimAccess(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	userID, err := parseUserID(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	var patch PatchRequest
+	err = bind(c, &patch)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+
+	var user *ScimUserDto
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		user, err = patchUser(PatchUserServiceRequest{
+			Params: PatchUserParams{
+				OrganizationID: organizationID,
+				UserID:         userID,
+				Operations:     patch.Operations,
+			},
+			Tx: tx,
+		})
+		return err
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return respond(c, http.StatusOK, mapUserToResource(user, config))
+}
+
+func DeleteUserEndpoint(c echo.Context) error {
+	organizationID, err := checkScimAccess(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	userID, err := parseUserID(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		return deleteUser(DeleteUserServiceRequest{
+			OrganizationID: organizationID,
+			UserID:         userID,
+			Tx:             tx,
+		})
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func ListGroupsEndpoint(c echo.Context) error {
+	organizationID, err := checkScimAccess(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+
+	response, err := listGroups(ListGroupsServiceRequest{
+		Params: parseListParams(c, organizationID),
+		Tx:     db.WithContext(c.Request().Context()),
+	})
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return respond(c, http.StatusOK, mapGroupsToListResponse(response, organizationID, config))
+}
+
+func GetGroupEndpoint(c echo.Context) error {
+	organizationID, err := checkScimAccess(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+
+	group, err := getGroup(GetGroupServiceRequest{
+		OrganizationID: organizationID,
+		GroupID:        c.Param("groupId"),
+		Tx:             db.WithContext(c.Request().Context()),
+	})
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return respond(c, http.StatusOK, mapGroupToResource(group, organizationID, config))
+}
+
+func PatchGroupEndpoint(c echo.Context) error {
+	organizationID, err := checkScimAccess(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	var patch PatchRequest
+	err = bind(c, &patch)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+
+	var group *ScimGroupDto
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		group, err = patchGroup(PatchGroupServiceRequest{
+			Params: PatchGroupParams{
+				OrganizationID: organizationID,
+				GroupID:        c.Param("groupId"),
+				Operations:     patch.Operations,
+			},
+			Tx: tx,
+		})
+		return err
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return respond(c, http.StatusOK, mapGroupToResource(group, organizationID, config))
+}
+
+func ReplaceGroupEndpoint(c echo.Context) error {
+	organizationID, err := checkScimAccess(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	var resource GroupResource
+	err = bind(c, &resource)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+
+	var group *ScimGroupDto
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		group, err = replaceGroup(ReplaceGroupServiceRequest{
+			Params: ReplaceGroupParams{
+				OrganizationID: organizationID,
+				GroupID:        c.Param("groupId"),
+				Resource:       resource,
+			},
+			Tx: tx,
+		})
+		return err
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return respond(c, http.StatusOK, mapGroupToResource(group, organizationID, config))
+}
+
+// checkScimAccess makes sure the request comes from an API key of the organization that can provision its members.
+// Returns the organization's ID.
+func checkScimAccess(c echo.Context) (uuid.UUID, error) {
+	organizationID, err := api.ParseOrganizationIDFromParams(c)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if !middleware.IsApiKeyRequest(c) {
+		return uuid.Nil, api.ErrScimApiKeyRequired
+	}
+
+	err = access.HasOrganizationAccess(c, access.HasOrganizationAccessParams{
+		OrganizationID: organizationID,
+		Scopes:         []constants.UserScope{constants.UserScopeOrganizationScimProvision},
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return organizationID, nil
+}
+
+// parseUserID parses the user ID in the URL. Unknown IDs are reported as missing resources.
+func parseUserID(c echo.Context) (uuid.UUID, error) {
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		return uuid.Nil, api.ErrScimResourceNotFound
+	}
+	return userID, nil
+}
+
+// parseListParams reads the filter and pagination query parameters of a list request
+func parseListParams(c echo.Context, organizationID uuid.UUID) ListParams {
+	params := ListParams{
+		OrganizationID: organizationID,
+		Filter:         c.QueryParam("filter"),
+		StartIndex:     1,
+		Count:          defaultPageSize,
+	}
+
+	if startIndex, err := strconv.Atoi(c.QueryParam("startIndex")); err == nil {
+		params.StartIndex = startIndex
+	}
+	if count, err := strconv.Atoi(c.QueryParam("count")); err == nil {
+		params.Count = count
+	}
+
+	return params
+}
+
+// bind decodes a SCIM request body. Echo's binder only handles application/json, and SCIM clients send
+// application/scim+json.
+func bind(c echo.Context, target any) error {
+	err := json.NewDecoder(c.Request().Body).Decode(target)
+	if err != nil {
+		return api.ErrScimInvalidSyntax
+	}
+	return nil
+}
+
+func respond(c echo.Context, status int, body any) error {
+	c.Response().Header().Set(echo.HeaderContentType, contentType)
+	return c.JSON(status, body)
+}
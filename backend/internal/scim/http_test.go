@@ -0,0 +1,455 @@
+package scim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"reece.start/internal/api"
+	"reece.start/internal/authentication"
+	"reece.start/internal/constants"
+	"reece.start/internal/models"
+	"reece.start/internal/scim"
+	"reece.start/test"
+)
+
+// createScimApiKey creates an organization API key with the given scopes
+func createScimApiKey(t *testing.T, tc *test.TestContext, orgID uuid.UUID, scopes ...constants.UserScope) string {
+	key, hash, prefix, err := authentication.GenerateApiKey()
+	require.NoError(t, err)
+
+	scopeNames := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scopeNames = append(scopeNames, string(scope))
+	}
+
+	apiKey := models.OrganizationApiKey{
+		OrganizationID: orgID,
+		Name:           "Identity provider",
+		TokenHash:      hash,
+		TokenPrefix:    prefix,
+		Scopes:         strings.Join(scopeNames, ","),
+	}
+	require.NoError(t, tc.DB.Create(&apiKey).Error)
+
+	return key
+}
+
+// Domain of the users the tests provision, which the organization has to have verified
+const scimDomain = "idp.example.com"
+
+// createVerifiedDomain adds a domain to the organization that is already verified, without going through DNS
+func createVerifiedDomain(t *testing.T, tc *test.TestContext, orgID uuid.UUID, domain string) {
+	now := time.Now()
+	require.NoError(t, tc.DB.Create(&models.OrganizationDomain{
+		OrganizationID:    orgID,
+		Domain:            domain,
+		VerificationToken: "verification-token",
+		VerifiedAt:        &now,
+		JoinPolicy:        string(constants.OrganizationDomainJoinPolicyApproval),
+		DefaultRole:       string(constants.OrganizationRoleMember),
+	}).Error)
+}
+
+func scimRequest(tc *test.TestContext, method string, path string, body interface{}, apiKey string) *httptest.ResponseRecorder {
+	return tc.MakeRequest(method, path, body, map[string]string{
+		"Authorization": "Bearer " + apiKey,
+		"Content-Type":  "application/scim+json",
+	})
+}
+
+func createScimUser(t *testing.T, tc *test.TestContext, orgID uuid.UUID, apiKey string, userName string, externalID string) scim.UserResource {
+	body := map[string]interface{}{
+		"schemas":    []string{constants.ScimSchemaUser},
+		"userName":   userName,
+		"externalId": externalID,
+		"name": map[string]interface{}{
+			"givenName":  "Provisioned",
+			"familyName": "User",
+		},
+		"active": true,
+	}
+
+	rec := scimRequest(tc, http.MethodPost, "/scim/v2/"+orgID.String()+"/Users", body, apiKey)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var resource scim.UserResource
+	tc.UnmarshalResponse(rec, &resource)
+	return resource
+}
+
+func TestScimAuthentication(t *testing.T) {
+	t.Run("RequiresApiKey", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, org, token := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+
+		rec := scimRequest(tc, http.MethodGet, "/scim/v2/"+org.ID.String()+"/Users", nil, token)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		var scimErr api.ScimError
+		tc.UnmarshalResponse(rec, &scimErr)
+		assert.Equal(t, []string{constants.ScimSchemaError}, scimErr.Schemas)
+		assert.Equal(t, "403", scimErr.Status)
+	})
+
+	t.Run("RequiresScope", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		apiKey := createScimApiKey(t, tc, org.ID, constants.UserScopeOrganizationRead)
+
+		rec := scimRequest(tc, http.MethodGet, "/scim/v2/"+org.ID.String()+"/Users", nil, apiKey)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("RequiresKeyOfOrganization", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		_, otherOrg, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		apiKey := createScimApiKey(t, tc, otherOrg.ID, constants.UserScopeOrganizationScimProvision)
+
+		rec := scimRequest(tc, http.MethodGet, "/scim/v2/"+org.ID.String()+"/Users", nil, apiKey)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+
+		rec := scimRequest(tc, http.MethodGet, "/scim/v2/"+org.ID.String()+"/Users", nil, authentication.ApiKeyPrefix+"unknown")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		var scimErr api.ScimError
+		tc.UnmarshalResponse(rec, &scimErr)
+		assert.Equal(t, "401", scimErr.Status)
+	})
+}
+
+func TestScimUserEndpoints(t *testing.T) {
+	t.Run("CreateAndGet", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		apiKey := createScimApiKey(t, tc, org.ID, constants.UserScopeOrganizationScimProvision)
+		createVerifiedDomain(t, tc, org.ID, scimDomain)
+
+		resource := createScimUser(t, tc, org.ID, apiKey, "scim-new@idp.example.com", "idp-1")
+		assert.Equal(t, "scim-new@idp.example.com", resource.UserName)
+		assert.Equal(t, "idp-1", resource.ExternalID)
+		assert.Equal(t, "Provisioned User", resource.DisplayName)
+		require.NotNil(t, resource.Active)
+		assert.True(t, *resource.Active)
+		require.Len(t, resource.Groups, 1)
+		assert.Equal(t, string(constants.OrganizationRoleMember), resource.Groups[0].Value)
+
+		var membership models.OrganizationMembership
+		require.NoError(t, tc.DB.Where("organization_id = ? AND user_id = ?", org.ID, resource.ID).First(&membership).Error)
+		assert.Equal(t, string(constants.OrganizationRoleMember), membership.Role)
+
+		rec := scimRequest(tc, http.MethodGet, "/scim/v2/"+org.ID.String()+"/Users/"+resource.ID, nil, apiKey)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Header().Get("Content-Type"), "application/scim+json")
+
+		// Provisioning the same user again conflicts
+		body := map[string]interface{}{"schemas": []string{constants.ScimSchemaUser}, "userName": "scim-new@idp.example.com"}
+		rec = scimRequest(tc, http.MethodPost, "/scim/v2/"+org.ID.String()+"/Users", body, apiKey)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		var scimErr api.ScimError
+		tc.UnmarshalResponse(rec, &scimErr)
+		assert.Equal(t, "uniqueness", scimErr.ScimType)
+	})
+
+	t.Run("InvitesExistingUser", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		admin, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		user, _, _ := test.CreateTestUser(t, tc)
+		apiKey := createScimApiKey(t, tc, org.ID, constants.UserScopeOrganizationScimProvision)
+		createVerifiedDomain(t, tc, org.ID, scimDomain)
+
+		body := map[string]interface{}{"schemas": []string{constants.ScimSchemaUser}, "userName": user.Email, "externalId": "idp-2"}
+		rec := scimRequest(tc, http.MethodPost, "/scim/v2/"+org.ID.String()+"/Users", body, apiKey)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+
+		// The IdP gets the pending user back, inactive until they accept
+		var resource scim.UserResource
+		tc.UnmarshalResponse(rec, &resource)
+		assert.Equal(t, user.ID.String(), resource.ID)
+		assert.Equal(t, "idp-2", resource.ExternalID)
+		require.NotNil(t, resource.Active)
+		assert.False(t, *resource.Active)
+
+		// The user isn't added until they accept the invitation
+		var count int64
+		require.NoError(t, tc.DB.Model(&models.OrganizationMembership{}).Where("organization_id = ? AND user_id = ?", org.ID, user.ID).Count(&count).Error)
+		assert.Equal(t, int64(0), count)
+
+		var invitation models.OrganizationInvitation
+		require.NoError(t, tc.DB.Where("organization_id = ? AND email = ?", org.ID, user.Email).First(&invitation).Error)
+		assert.Equal(t, string(constants.OrganizationInvitationStatusPending), invitation.Status)
+		assert.Equal(t, string(constants.OrganizationRoleMember), invitation.Role)
+		assert.Equal(t, admin.ID, invitation.InvitingUserID)
+
+		require.NoError(t, tc.DB.Raw("SELECT COUNT(*) FROM river_job WHERE kind = ?", string(constants.JobKindOrganizationInvitationEmail)).Scan(&count).Error)
+		assert.Equal(t, int64(1), count)
+
+		// Provisioning the user again doesn't send another invitation
+		rec = scimRequest(tc, http.MethodPost, "/scim/v2/"+org.ID.String()+"/Users", body, apiKey)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+
+		require.NoError(t, tc.DB.Model(&models.OrganizationInvitation{}).Where("organization_id = ? AND email = ?", org.ID, user.Email).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+
+		// The user's profile isn't changed
+		var stored models.User
+		require.NoError(t, tc.DB.First(&stored, user.ID).Error)
+		assert.Equal(t, user.Name, stored.Name)
+	})
+
+	t.Run("RejectsUnverifiedDomain", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		apiKey := createScimApiKey(t, tc, org.ID, constants.UserScopeOrganizationScimProvision)
+		createVerifiedDomain(t, tc, org.ID, scimDomain)
+
+		body := map[string]interface{}{"schemas": []string{constants.ScimSchemaUser}, "userName": "scim-new@unverified.example.com"}
+		rec := scimRequest(tc, http.MethodPost, "/scim/v2/"+org.ID.String()+"/Users", body, apiKey)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+
+		var scimErr api.ScimError
+		tc.UnmarshalResponse(rec, &scimErr)
+		assert.Equal(t, "invalidValue", scimErr.ScimType)
+
+		var count int64
+		require.NoError(t, tc.DB.Model(&models.User{}).Where("email = ?", "scim-new@unverified.example.com").Count(&count).Error)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("ListWithFilterAndPagination", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		apiKey := createScimApiKey(t, tc, org.ID, constants.UserScopeOrganizationScimProvision)
+		createVerifiedDomain(t, tc, org.ID, scimDomain)
+
+		createScimUser(t, tc, org.ID, apiKey, "scim-a@idp.example.com", "idp-a")
+		createScimUser(t, tc, org.ID, apiKey, "scim-b@idp.example.com", "idp-b")
+
+		// The admin who created the organization is listed too
+		rec := scimRequest(tc, http.MethodGet, "/scim/v2/"+org.ID.String()+"/Users?startIndex=2&count=1", nil, apiKey)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var list scim.ListResponse
+		tc.UnmarshalResponse(rec, &list)
+		assert.Equal(t, []string{constants.ScimSchemaListResponse}, list.Schemas)
+		assert.Equal(t, 3, list.TotalResults)
+		assert.Equal(t, 2, list.StartIndex)
+		assert.Equal(t, 1, list.ItemsPerPage)
+		require.Len(t, list.Resources, 1)
+		assert.Equal(t, "scim-a@idp.example.com", list.Resources[0].(map[string]interface{})["userName"])
+
+		rec = scimRequest(tc, http.MethodGet, "/scim/v2/"+org.ID.String()+`/Users?filter=userName+eq+"scim-b@idp.example.com"`, nil, apiKey)
+		require.Equal(t, http.StatusOK, rec.Code)
+		tc.UnmarshalResponse(rec, &list)
+		assert.Equal(t, 1, list.TotalResults)
+
+		rec = scimRequest(tc, http.MethodGet, "/scim/v2/"+org.ID.String()+`/Users?filter=externalId+eq+"idp-a"`, nil, apiKey)
+		require.Equal(t, http.StatusOK, rec.Code)
+		tc.UnmarshalResponse(rec, &list)
+		assert.Equal(t, 1, list.TotalResults)
+
+		rec = scimRequest(tc, http.MethodGet, "/scim/v2/"+org.ID.String()+`/Users?filter=userName+co+"scim"`, nil, apiKey)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("DeactivateAndReactivate", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		apiKey := createScimApiKey(t, tc, org.ID, constants.UserScopeOrganizationScimProvision)
+		createVerifiedDomain(t, tc, org.ID, scimDomain)
+		resource := createScimUser(t, tc, org.ID, apiKey, "scim-deactivate@idp.example.com", "idp-3")
+
+		// Some identity providers send booleans as strings
+		patch := map[string]interface{}{
+			"schemas": []string{constants.ScimSchemaPatchOp},
+			"Operations": []map[string]interface{}{
+				{"op": "Replace", "path": "active", "value": "False"},
+			},
+		}
+		rec := scimRequest(tc, http.MethodPatch, "/scim/v2/"+org.ID.String()+"/Users/"+resource.ID, patch, apiKey)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var patched scim.UserResource
+		tc.UnmarshalResponse(rec, &patched)
+		assert.False(t, *patched.Active)
+		assert.Empty(t, patched.Groups)
+
+		// The membership is removed and the user's tokens are revoked
+		var count int64
+		require.NoError(t, tc.DB.Model(&models.OrganizationMembership{}).Where("organization_id = ? AND user_id = ?", org.ID, resource.ID).Count(&count).Error)
+		assert.Equal(t, int64(0), count)
+
+		var user models.User
+		require.NoError(t, tc.DB.First(&user, "id = ?", resource.ID).Error)
+		assert.NotNil(t, user.Revocation.LastValidIssuedAt)
+
+		// Deactivated users are still listed
+		rec = scimRequest(tc, http.MethodGet, "/scim/v2/"+org.ID.String()+"/Users/"+resource.ID, nil, apiKey)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		patch = map[string]interface{}{
+			"schemas": []string{constants.ScimSchemaPatchOp},
+			"Operations": []map[string]interface{}{
+				{"op": "replace", "value": map[string]interface{}{"active": true}},
+			},
+		}
+		rec = scimRequest(tc, http.MethodPatch, "/scim/v2/"+org.ID.String()+"/Users/"+resource.ID, patch, apiKey)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		require.NoError(t, tc.DB.Model(&models.OrganizationMembership{}).Where("organization_id = ? AND user_id = ?", org.ID, resource.ID).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("ReplaceCantChangeUserName", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		apiKey := createScimApiKey(t, tc, org.ID, constants.UserScopeOrganizationScimProvision)
+		createVerifiedDomain(t, tc, org.ID, scimDomain)
+		resource := createScimUser(t, tc, org.ID, apiKey, "scim-replace@idp.example.com", "idp-4")
+
+		body := map[string]interface{}{
+			"schemas":    []string{constants.ScimSchemaUser},
+			"userName":   "scim-replace@idp.example.com",
+			"externalId": "idp-4-renamed",
+		}
+		rec := scimRequest(tc, http.MethodPut, "/scim/v2/"+org.ID.String()+"/Users/"+resource.ID, body, apiKey)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var replaced scim.UserResource
+		tc.UnmarshalResponse(rec, &replaced)
+		assert.Equal(t, "idp-4-renamed", replaced.ExternalID)
+
+		body["userName"] = "someone-else@idp.example.com"
+		rec = scimRequest(tc, http.MethodPut, "/scim/v2/"+org.ID.String()+"/Users/"+resource.ID, body, apiKey)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		apiKey := createScimApiKey(t, tc, org.ID, constants.UserScopeOrganizationScimProvision)
+		createVerifiedDomain(t, tc, org.ID, scimDomain)
+		resource := createScimUser(t, tc, org.ID, apiKey, "scim-delete@idp.example.com", "idp-5")
+
+		rec := scimRequest(tc, http.MethodDelete, "/scim/v2/"+org.ID.String()+"/Users/"+resource.ID, nil, apiKey)
+		require.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = scimRequest(tc, http.MethodGet, "/scim/v2/"+org.ID.String()+"/Users/"+resource.ID, nil, apiKey)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		// The account is kept
+		var user models.User
+		require.NoError(t, tc.DB.First(&user, "id = ?", resource.ID).Error)
+
+		// The membership is only soft deleted, so it still counts as having left the organization
+		var membership models.OrganizationMembership
+		require.NoError(t, tc.DB.Unscoped().Where("organization_id = ? AND user_id = ?", org.ID, resource.ID).First(&membership).Error)
+		assert.True(t, membership.DeletedAt.Valid)
+		assert.NotNil(t, membership.ScimDeprovisionedAt)
+
+		rec = scimRequest(tc, http.MethodGet, "/scim/v2/"+org.ID.String()+`/Users?filter=userName+eq+"scim-delete@idp.example.com"`, nil, apiKey)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var list scim.ListResponse
+		tc.UnmarshalResponse(rec, &list)
+		assert.Equal(t, 0, list.TotalResults)
+	})
+}
+
+func TestScimGroupEndpoints(t *testing.T) {
+	t.Run("ListGroups", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		admin, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		apiKey := createScimApiKey(t, tc, org.ID, constants.UserScopeOrganizationScimProvision)
+		createVerifiedDomain(t, tc, org.ID, scimDomain)
+
+		rec := scimRequest(tc, http.MethodGet, "/scim/v2/"+org.ID.String()+"/Groups", nil, apiKey)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var list scim.ListResponse
+		tc.UnmarshalResponse(rec, &list)
+		assert.Equal(t, 2, list.TotalResults)
+
+		rec = scimRequest(tc, http.MethodGet, "/scim/v2/"+org.ID.String()+`/Groups?filter=displayName+eq+"Admin"`, nil, apiKey)
+		require.Equal(t, http.StatusOK, rec.Code)
+		tc.UnmarshalResponse(rec, &list)
+		require.Equal(t, 1, list.TotalResults)
+
+		group := list.Resources[0].(map[string]interface{})
+		assert.Equal(t, string(constants.OrganizationRoleAdmin), group["id"])
+		members := group["members"].([]interface{})
+		require.Len(t, members, 1)
+		assert.Equal(t, admin.ID.String(), members[0].(map[string]interface{})["value"])
+	})
+
+	t.Run("AddAndRemoveMembers", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		apiKey := createScimApiKey(t, tc, org.ID, constants.UserScopeOrganizationScimProvision)
+		createVerifiedDomain(t, tc, org.ID, scimDomain)
+		resource := createScimUser(t, tc, org.ID, apiKey, "scim-group@idp.example.com", "idp-6")
+
+		patch := map[string]interface{}{
+			"schemas": []string{constants.ScimSchemaPatchOp},
+			"Operations": []map[string]interface{}{
+				{"op": "add", "path": "members", "value": []map[string]interface{}{{"value": resource.ID}}},
+			},
+		}
+		rec := scimRequest(tc, http.MethodPatch, "/scim/v2/"+org.ID.String()+"/Groups/admin", patch, apiKey)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var membership models.OrganizationMembership
+		require.NoError(t, tc.DB.Where("organization_id = ? AND user_id = ?", org.ID, resource.ID).First(&membership).Error)
+		assert.Equal(t, string(constants.OrganizationRoleAdmin), membership.Role)
+
+		patch = map[string]interface{}{
+			"schemas": []string{constants.ScimSchemaPatchOp},
+			"Operations": []map[string]interface{}{
+				{"op": "remove", "path": `members[value eq "` + resource.ID + `"]`},
+			},
+		}
+		rec = scimRequest(tc, http.MethodPatch, "/scim/v2/"+org.ID.String()+"/Groups/admin", patch, apiKey)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		require.NoError(t, tc.DB.Where("organization_id = ? AND user_id = ?", org.ID, resource.ID).First(&membership).Error)
+		assert.Equal(t, string(constants.OrganizationRoleMember), membership.Role)
+	})
+
+	t.Run("MembersMustBeProvisioned", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		outsider, _, _ := test.CreateTestUser(t, tc)
+		apiKey := createScimApiKey(t, tc, org.ID, constants.UserScopeOrganizationScimProvision)
+		createVerifiedDomain(t, tc, org.ID, scimDomain)
+
+		body := map[string]interface{}{
+			"schemas":     []string{constants.ScimSchemaGroup},
+			"displayName": "admin",
+			"members":     []map[string]interface{}{{"value": outsider.ID.String()}},
+		}
+		rec := scimRequest(tc, http.MethodPut, "/scim/v2/"+org.ID.String()+"/Groups/admin", body, apiKey)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("UnknownGroup", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		apiKey := createScimApiKey(t, tc, org.ID, constants.UserScopeOrganizationScimProvision)
+		createVerifiedDomain(t, tc, org.ID, scimDomain)
+
+		rec := scimRequest(tc, http.MethodGet, "/scim/v2/"+org.ID.String()+"/Groups/owners", nil, apiKey)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
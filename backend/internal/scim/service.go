@@ -0,0 +1,897 @@
+package scim
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/riverqueue/river"
+	"gorm.io/gorm"
+	"reece.start/internal/api"
+	"reece.start/internal/authentication"
+	"reece.start/internal/constants"
+	"reece.start/internal/models"
+	"reece.start/internal/organizations"
+	"reece.start/internal/utils"
+)
+
+// Members are provisioned as users with a membership in the organization. Deactivating a user soft deletes their
+// membership, the same as removing them from the organization, and activating them restores it. Deleting a user soft
+// deletes their membership too, and marks it deprovisioned so they're no longer listed. Groups are the organization's
+// roles, so adding a member to a group gives them that role.
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 100
+)
+
+// Groups in the order they're listed
+var groupRoles = []constants.OrganizationRole{constants.OrganizationRoleAdmin, constants.OrganizationRoleMember}
+
+// Members removed from every other group end up with this role
+const defaultRole = constants.OrganizationRoleMember
+
+// Filterable attributes
+var (
+	userFilterAttributes  = []string{"id", "userName", "externalId", "emails.value"}
+	groupFilterAttributes = []string{"id", "displayName"}
+)
+
+type filter struct {
+	Attribute string
+	Value     string
+}
+
+var filterPattern = regexp.MustCompile(`(?i)^\s*([a-z][a-z0-9.]*)\s+eq\s+("(?:[^"\\]|\\.)*")\s*$`)
+
+// Paths that remove a single group member, like members[value eq "id"]
+var memberPathPattern = regexp.MustCompile(`(?i)^members\[value\s+eq\s+"([^"]*)"\]$`)
+
+// parseFilter parses a filter, which can only be an eq comparison of one of the given attributes. Returns nil if there
+// is no filter. Attribute names are case insensitive, and are returned as they're spelled in attributes.
+func parseFilter(expression string, attributes []string) (*filter, error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, nil
+	}
+
+	matches := filterPattern.FindStringSubmatch(expression)
+	if matches == nil {
+		return nil, api.ErrScimInvalidFilter
+	}
+
+	value, err := strconv.Unquote(matches[2])
+	if err != nil {
+		return nil, api.ErrScimInvalidFilter
+	}
+
+	for _, attribute := range attributes {
+		if strings.EqualFold(attribute, matches[1]) {
+			return &filter{Attribute: attribute, Value: value}, nil
+		}
+	}
+
+	return nil, api.ErrScimInvalidFilter
+}
+
+// normalizePagination clamps the 1-based start index and page size to what the spec allows
+func normalizePagination(startIndex int, count int) (int, int) {
+	if startIndex < 1 {
+		startIndex = 1
+	}
+
+	if count < 0 {
+		count = 0
+	}
+	if count > maxPageSize {
+		count = maxPageSize
+	}
+
+	return startIndex, count
+}
+
+// User Service Functions
+
+// usersQuery returns the memberships that make up an organization's SCIM users. A user can have several memberships
+// if they were removed and added again, only the active or most recently deactivated one counts, unless it was
+// deprovisioned.
+func usersQuery(tx *gorm.DB, organizationID uuid.UUID, f *filter) *gorm.DB {
+	latestMemberships := tx.Unscoped().Model(&models.OrganizationMembership{}).
+		Select("DISTINCT ON (user_id) id").
+		Where("organization_id = ?", organizationID).
+		Order("user_id, deleted_at DESC NULLS FIRST, created_at DESC")
+
+	query := tx.Unscoped().Model(&models.OrganizationMembership{}).
+		Joins("JOIN users ON users.id = organization_memberships.user_id AND users.deleted_at IS NULL").
+		Where("organization_memberships.id IN (?)", latestMemberships).
+		Where("organization_memberships.scim_deprovisioned_at IS NULL")
+
+	if f == nil {
+		return query
+	}
+
+	switch f.Attribute {
+	case "id":
+		userID, err := uuid.Parse(f.Value)
+		if err != nil {
+			return query.Where("FALSE")
+		}
+		return query.Where("users.id = ?", userID)
+	case "userName", "emails.value":
+		return query.Where("users.email = ?", f.Value)
+	case "externalId":
+		return query.Where("organization_memberships.scim_external_id = ?", f.Value)
+	}
+
+	return query
+}
+
+func listUsers(request ListUsersServiceRequest) (*ListUsersServiceResponse, error) {
+	tx := request.Tx
+	params := request.Params
+
+	f, err := parseFilter(params.Filter, userFilterAttributes)
+	if err != nil {
+		return nil, err
+	}
+
+	startIndex, count := normalizePagination(params.StartIndex, params.Count)
+
+	var total int64
+	err = usersQuery(tx, params.OrganizationID, f).Count(&total).Error
+	if err != nil {
+		return nil, err
+	}
+
+	memberships := make([]models.OrganizationMembership, 0)
+	if count > 0 {
+		err = usersQuery(tx, params.OrganizationID, f).
+			Select("organization_memberships.*").
+			Preload("User").
+			Order("organization_memberships.created_at, organization_memberships.id").
+			Offset(startIndex - 1).
+			Limit(count).
+			Find(&memberships).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	users := make([]ScimUserDto, 0, len(memberships))
+	for i := range memberships {
+		users = append(users, ScimUserDto{User: &memberships[i].User, Membership: &memberships[i]})
+	}
+
+	return &ListUsersServiceResponse{
+		Users:        users,
+		TotalResults: int(total),
+		StartIndex:   startIndex,
+	}, nil
+}
+
+func getUser(request GetUserServiceRequest) (*ScimUserDto, error) {
+	return getScimUser(request.Tx, request.OrganizationID, request.UserID)
+}
+
+// getScimUser returns a user provisioned in the organization, along with their active or most recently deactivated
+// membership. Users whose membership was deprovisioned aren't found.
+func getScimUser(tx *gorm.DB, organizationID uuid.UUID, userID uuid.UUID) (*ScimUserDto, error) {
+	var membership models.OrganizationMembership
+	err := tx.Unscoped().
+		Where("organization_id = ? AND user_id = ?", organizationID, userID).
+		Order("deleted_at DESC NULLS FIRST, created_at DESC").
+		First(&membership).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrScimResourceNotFound
+		}
+		return nil, err
+	}
+
+	if membership.ScimDeprovisionedAt != nil {
+		return nil, api.ErrScimResourceNotFound
+	}
+
+	var user models.User
+	err = tx.First(&user, userID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrScimResourceNotFound
+		}
+		return nil, err
+	}
+
+	return &ScimUserDto{User: &user, Membership: &membership}, nil
+}
+
+// createUser provisions a user in the organization, and activates a deactivated user again. Users who already have an
+// account are invited to the organization instead, since the identity provider can assert any email address.
+func createUser(request CreateUserServiceRequest) (*CreateUserServiceResponse, error) {
+	tx := request.Tx
+	params := request.Params
+	resource := params.Resource
+	riverClient := request.RiverClient
+	posthogClient := request.PostHogClient
+
+	email := strings.TrimSpace(resource.UserName)
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return nil, api.ErrScimInvalidUserName
+	}
+
+	var user models.User
+	err = tx.Where("email = ?", email).First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var dto *ScimUserDto
+
+	if err == nil {
+		dto, err = getScimUser(tx, params.OrganizationID, user.ID)
+		if errors.Is(err, api.ErrScimResourceNotFound) {
+			invitation, err := inviteUser(tx, riverClient, params, user.Email)
+			if err != nil {
+				return nil, err
+			}
+
+			return &CreateUserServiceResponse{InvitedUser: &user, Invitation: invitation}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if !dto.Membership.DeletedAt.Valid {
+			return nil, api.ErrScimUserExists
+		}
+	} else {
+		// The identity provider controls which userName it sends, so it can only create accounts on domains the
+		// organization has proven it owns. Otherwise it could take an address before its owner signs up.
+		isVerifiedDomain, err := authentication.IsVerifiedOrganizationDomain(tx, params.OrganizationID, email)
+		if err != nil {
+			return nil, err
+		}
+		if !isVerifiedDomain {
+			return nil, api.ErrScimDomainNotVerified
+		}
+
+		user = models.User{
+			Name:           getResourceName(resource),
+			Email:          email,
+			HashedPassword: nil, // Provisioned users sign in with SSO or set a password through a reset
+		}
+
+		if user.Name == "" {
+			user.Name = email
+		}
+
+		if err := tx.Create(&user).Error; err != nil {
+			return nil, err
+		}
+
+		// Log user created event to PostHog (SCIM provisioning)
+		posthogClient.Capture(
+			user.ID.String(),
+			"user created",
+			map[string]any{
+				"user_id":       user.ID.String(),
+				"email":         user.Email,
+				"name":          user.Name,
+				"signup_method": "scim",
+			},
+		)
+
+		membership := models.OrganizationMembership{
+			UserID:         user.ID,
+			OrganizationID: params.OrganizationID,
+			Role:           string(defaultRole),
+			ScimExternalID: resource.ExternalID,
+		}
+		if err := tx.Create(&membership).Error; err != nil {
+			return nil, err
+		}
+
+		slog.Info("Provisioned SCIM user in organization", "userID", user.ID, "organizationID", params.OrganizationID)
+
+		dto = &ScimUserDto{User: &user, Membership: &membership}
+	}
+
+	// Users are created active unless the identity provider says otherwise
+	active := true
+	if resource.Active != nil {
+		active = *resource.Active
+	}
+
+	err = updateMembership(tx, dto, &resource.ExternalID, &active)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreateUserServiceResponse{User: dto}, nil
+}
+
+// inviteUser invites a user who already has an account to the organization, so they're only added once they accept.
+// A pending invitation is reused rather than sending another.
+func inviteUser(tx *gorm.DB, riverClient *river.Client[*sql.Tx], params CreateUserParams, email string) (*models.OrganizationInvitation, error) {
+	var invitation models.OrganizationInvitation
+	err := tx.Where("email = ? AND organization_id = ? AND status = ?", email, params.OrganizationID, string(constants.OrganizationInvitationStatusPending)).
+		First(&invitation).Error
+	if err == nil {
+		return &invitation, nil
+	}
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	invitingUserID, err := getInvitingUserID(tx, params.OrganizationID, params.ApiKeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	invitation = models.OrganizationInvitation{
+		Email:          email,
+		OrganizationID: params.OrganizationID,
+		InvitingUserID: invitingUserID,
+		Role:           string(defaultRole),
+		Status:         string(constants.OrganizationInvitationStatusPending),
+	}
+
+	err = tx.Create(&invitation).Error
+	if err != nil {
+		return nil, err
+	}
+
+	sqlTx := utils.GetGormSQLTx(tx)
+	_, err = riverClient.InsertTx(tx.Statement.Context, sqlTx, organizations.OrganizationInvitationEmailJobArgs{
+		InvitationId: invitation.ID,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enqueue invitation email job: %w", err)
+	}
+
+	slog.Info("Invited existing user to organization over SCIM", "invitationID", invitation.ID, "organizationID", params.OrganizationID)
+
+	return &invitation, nil
+}
+
+// getInvitingUserID returns who invitations sent over SCIM come from. That's the admin who created the API key, or the
+// organization's longest standing admin if they're no longer an admin of it.
+func getInvitingUserID(tx *gorm.DB, organizationID uuid.UUID, apiKeyID uuid.UUID) (uuid.UUID, error) {
+	var apiKey models.OrganizationApiKey
+	err := tx.Where("id = ? AND organization_id = ?", apiKeyID, organizationID).First(&apiKey).Error
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	var membership models.OrganizationMembership
+
+	if apiKey.CreatedByUserID != nil {
+		err = tx.Where("organization_id = ? AND user_id = ? AND role = ?", organizationID, *apiKey.CreatedByUserID, string(constants.OrganizationRoleAdmin)).
+			First(&membership).Error
+		if err == nil {
+			return membership.UserID, nil
+		}
+
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return uuid.Nil, err
+		}
+	}
+
+	err = tx.Where("organization_id = ? AND role = ?", organizationID, string(constants.OrganizationRoleAdmin)).
+		Order("created_at").
+		First(&membership).Error
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return membership.UserID, nil
+}
+
+// replaceUser replaces the parts of a user the organization manages. Attributes that belong to the user's profile are
+// ignored, since the user can be in several organizations.
+func replaceUser(request ReplaceUserServiceRequest) (*ScimUserDto, error) {
+	tx := request.Tx
+	params := request.Params
+	resource := params.Resource
+
+	dto, err := getScimUser(tx, params.OrganizationID, params.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.EqualFold(strings.TrimSpace(resource.UserName), dto.User.Email) {
+		return nil, api.ErrScimUserNameImmutable
+	}
+
+	err = updateMembership(tx, dto, &resource.ExternalID, resource.Active)
+	if err != nil {
+		return nil, err
+	}
+
+	return dto, nil
+}
+
+// patchUser applies patch operations to a user. Only active and externalId can be changed, other attributes belong to
+// the user's profile and are ignored.
+func patchUser(request PatchUserServiceRequest) (*ScimUserDto, error) {
+	tx := request.Tx
+	params := request.Params
+
+	dto, err := getScimUser(tx, params.OrganizationID, params.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var externalID *string
+	var active *bool
+
+	apply := func(attribute string, value json.RawMessage) error {
+		switch strings.ToLower(attribute) {
+		case "active":
+			parsed, err := parseBool(value)
+			if err != nil {
+				return err
+			}
+			active = &parsed
+		case "externalid":
+			var parsed string
+			if err := json.Unmarshal(value, &parsed); err != nil {
+				return api.ErrScimInvalidPatch
+			}
+			externalID = &parsed
+		case "username":
+			var parsed string
+			if err := json.Unmarshal(value, &parsed); err != nil {
+				return api.ErrScimInvalidPatch
+			}
+			if !strings.EqualFold(strings.TrimSpace(parsed), dto.User.Email) {
+				return api.ErrScimUserNameImmutable
+			}
+		}
+		return nil
+	}
+
+	for _, operation := range params.Operations {
+		op := strings.ToLower(operation.Op)
+
+		switch {
+		case op == "remove":
+			if strings.EqualFold(operation.Path, "externalId") {
+				empty := ""
+				externalID = &empty
+			}
+		case op != "add" && op != "replace":
+			return nil, api.ErrScimInvalidPatch
+		case operation.Path == "":
+			// Without a path, the value holds the attributes to set
+			var values map[string]json.RawMessage
+			if err := json.Unmarshal(operation.Value, &values); err != nil {
+				return nil, api.ErrScimInvalidPatch
+			}
+			for attribute, value := range values {
+				if err := apply(attribute, value); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			if err := apply(operation.Path, operation.Value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	err = updateMembership(tx, dto, externalID, active)
+	if err != nil {
+		return nil, err
+	}
+
+	return dto, nil
+}
+
+// deleteUser removes a user from the organization. The user's account is kept, since they can be in other
+// organizations.
+func deleteUser(request DeleteUserServiceRequest) error {
+	tx := request.Tx
+
+	dto, err := getScimUser(tx, request.OrganizationID, request.UserID)
+	if err != nil {
+		return err
+	}
+
+	// Revoke the user's tokens so they can no longer act within the organization
+	err = authentication.RevokeUserTokens(tx, dto.User.ID, true)
+	if err != nil {
+		return err
+	}
+
+	// The membership is soft deleted rather than removed, so the user can't rejoin through a verified domain
+	if !dto.Membership.DeletedAt.Valid {
+		err = tx.Delete(dto.Membership).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Unscoped().Model(dto.Membership).Update("scim_deprovisioned_at", time.Now()).Error
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Deprovisioned SCIM user from organization", "userID", dto.User.ID, "organizationID", request.OrganizationID)
+
+	return nil
+}
+
+// updateMembership updates the external ID of a user's membership, and deactivates or activates it. Nil values are
+// left unchanged.
+func updateMembership(tx *gorm.DB, dto *ScimUserDto, externalID *string, active *bool) error {
+	membership := dto.Membership
+
+	if externalID != nil && *externalID != membership.ScimExternalID {
+		err := tx.Unscoped().Model(membership).Update("scim_external_id", *externalID).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	if active == nil || *active == !membership.DeletedAt.Valid {
+		return nil
+	}
+
+	if *active {
+		err := tx.Unscoped().Model(membership).Update("deleted_at", nil).Error
+		if err != nil {
+			return err
+		}
+		membership.DeletedAt = gorm.DeletedAt{}
+
+		slog.Info("Activated SCIM user in organization", "userID", membership.UserID, "organizationID", membership.OrganizationID)
+		return nil
+	}
+
+	// Revoke the user's tokens so they can no longer act within the organization
+	err := authentication.RevokeUserTokens(tx, membership.UserID, true)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Delete(membership).Error
+	if err != nil {
+		return err
+	}
+	membership.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+
+	slog.Info("Deactivated SCIM user in organization", "userID", membership.UserID, "organizationID", membership.OrganizationID)
+	return nil
+}
+
+// getResourceName returns the name to create a user with
+func getResourceName(resource UserResource) string {
+	if resource.Name != nil {
+		if resource.Name.Formatted != "" {
+			return strings.TrimSpace(resource.Name.Formatted)
+		}
+		if name := strings.TrimSpace(resource.Name.GivenName + " " + resource.Name.FamilyName); name != "" {
+			return name
+		}
+	}
+	return strings.TrimSpace(resource.DisplayName)
+}
+
+// parseBool parses a boolean patch value. Some identity providers send booleans as strings, like "False".
+func parseBool(value json.RawMessage) (bool, error) {
+	var parsed bool
+	if err := json.Unmarshal(value, &parsed); err == nil {
+		return parsed, nil
+	}
+
+	var text string
+	if err := json.Unmarshal(value, &text); err != nil {
+		return false, api.ErrScimInvalidPatch
+	}
+
+	parsed, err := strconv.ParseBool(strings.ToLower(text))
+	if err != nil {
+		return false, api.ErrScimInvalidPatch
+	}
+
+	return parsed, nil
+}
+
+// Group Service Functions
+
+// getGroupRole returns the role a group maps to. Groups are identified by the role's name.
+func getGroupRole(groupID string) (constants.OrganizationRole, error) {
+	for _, role := range groupRoles {
+		if string(role) == groupID {
+			return role, nil
+		}
+	}
+	return "", api.ErrScimResourceNotFound
+}
+
+// getScimGroup returns the active members with a role
+func getScimGroup(tx *gorm.DB, organizationID uuid.UUID, role constants.OrganizationRole) (*ScimGroupDto, error) {
+	memberships := make([]models.OrganizationMembership, 0)
+	err := tx.Preload("User").
+		Where("organization_id = ? AND role = ?", organizationID, string(role)).
+		Order("created_at, id").
+		Find(&memberships).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &ScimGroupDto{Role: role, Memberships: memberships}, nil
+}
+
+func listGroups(request ListGroupsServiceRequest) (*ListGroupsServiceResponse, error) {
+	tx := request.Tx
+	params := request.Params
+
+	f, err := parseFilter(params.Filter, groupFilterAttributes)
+	if err != nil {
+		return nil, err
+	}
+
+	startIndex, count := normalizePagination(params.StartIndex, params.Count)
+
+	roles := make([]constants.OrganizationRole, 0, len(groupRoles))
+	for _, role := range groupRoles {
+		if f == nil || (f.Attribute == "id" && f.Value == string(role)) ||
+			(f.Attribute == "displayName" && strings.EqualFold(f.Value, string(role))) {
+			roles = append(roles, role)
+		}
+	}
+
+	groups := make([]ScimGroupDto, 0)
+	for i := startIndex - 1; i < len(roles) && len(groups) < count; i++ {
+		group, err := getScimGroup(tx, params.OrganizationID, roles[i])
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, *group)
+	}
+
+	return &ListGroupsServiceResponse{
+		Groups:       groups,
+		TotalResults: len(roles),
+		StartIndex:   startIndex,
+	}, nil
+}
+
+func getGroup(request GetGroupServiceRequest) (*ScimGroupDto, error) {
+	role, err := getGroupRole(request.GroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	return getScimGroup(request.Tx, request.OrganizationID, role)
+}
+
+// patchGroup adds and removes group members, which changes their role in the organization
+func patchGroup(request PatchGroupServiceRequest) (*ScimGroupDto, error) {
+	tx := request.Tx
+	params := request.Params
+
+	role, err := getGroupRole(params.GroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, operation := range params.Operations {
+		op := strings.ToLower(operation.Op)
+		path := strings.ToLower(operation.Path)
+
+		if op != "add" && op != "replace" && op != "remove" {
+			return nil, api.ErrScimInvalidPatch
+		}
+
+		// Without a path, the value holds the attributes to set. Only members can be changed.
+		if path == "" && op != "remove" {
+			var values struct {
+				Members *json.RawMessage `json:"members"`
+			}
+			if err := json.Unmarshal(operation.Value, &values); err != nil {
+				return nil, api.ErrScimInvalidPatch
+			}
+			if values.Members == nil {
+				continue
+			}
+			operation.Value = *values.Members
+			path = "members"
+		}
+
+		if matches := memberPathPattern.FindStringSubmatch(operation.Path); matches != nil && op == "remove" {
+			userID, err := uuid.Parse(matches[1])
+			if err != nil {
+				return nil, api.ErrScimInvalidMember
+			}
+			err = removeGroupMembers(tx, params.OrganizationID, role, []uuid.UUID{userID})
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if path != "members" {
+			// The display name is the role's name, so it can't be changed
+			if path == "displayname" {
+				continue
+			}
+			return nil, api.ErrScimInvalidPatch
+		}
+
+		userIDs, err := parseMembers(operation.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case "add":
+			err = addGroupMembers(tx, params.OrganizationID, role, userIDs)
+		case "replace":
+			err = replaceGroupMembers(tx, params.OrganizationID, role, userIDs)
+		case "remove":
+			// Removing without a value removes every member
+			if len(operation.Value) == 0 || string(operation.Value) == "null" {
+				err = replaceGroupMembers(tx, params.OrganizationID, role, nil)
+			} else {
+				err = removeGroupMembers(tx, params.OrganizationID, role, userIDs)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return getScimGroup(tx, params.OrganizationID, role)
+}
+
+// replaceGroup replaces the members of a group
+func replaceGroup(request ReplaceGroupServiceRequest) (*ScimGroupDto, error) {
+	tx := request.Tx
+	params := request.Params
+
+	role, err := getGroupRole(params.GroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]uuid.UUID, 0, len(params.Resource.Members))
+	for _, member := range params.Resource.Members {
+		userID, err := uuid.Parse(member.Value)
+		if err != nil {
+			return nil, api.ErrScimInvalidMember
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	err = replaceGroupMembers(tx, params.OrganizationID, role, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return getScimGroup(tx, params.OrganizationID, role)
+}
+
+// parseMembers parses the user IDs of a members patch value
+func parseMembers(value json.RawMessage) ([]uuid.UUID, error) {
+	if len(value) == 0 || string(value) == "null" {
+		return nil, nil
+	}
+
+	var members []GroupReference
+	if err := json.Unmarshal(value, &members); err != nil {
+		return nil, api.ErrScimInvalidPatch
+	}
+
+	userIDs := make([]uuid.UUID, 0, len(members))
+	for _, member := range members {
+		userID, err := uuid.Parse(member.Value)
+		if err != nil {
+			return nil, api.ErrScimInvalidMember
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, nil
+}
+
+// addGroupMembers gives active members of the organization the group's role
+func addGroupMembers(tx *gorm.DB, organizationID uuid.UUID, role constants.OrganizationRole, userIDs []uuid.UUID) error {
+	for _, userID := range userIDs {
+		var membership models.OrganizationMembership
+		err := tx.Where("organization_id = ? AND user_id = ?", organizationID, userID).First(&membership).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return api.ErrScimInvalidMember
+			}
+			return err
+		}
+
+		err = setMembershipRole(tx, &membership, role)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeGroupMembers gives members that have the group's role the default role. Every member without another role is
+// in the default role's group, so they can't be removed from it.
+func removeGroupMembers(tx *gorm.DB, organizationID uuid.UUID, role constants.OrganizationRole, userIDs []uuid.UUID) error {
+	if role == defaultRole || len(userIDs) == 0 {
+		return nil
+	}
+
+	memberships := make([]models.OrganizationMembership, 0)
+	err := tx.Where("organization_id = ? AND role = ? AND user_id IN ?", organizationID, string(role), userIDs).Find(&memberships).Error
+	if err != nil {
+		return err
+	}
+
+	for i := range memberships {
+		err = setMembershipRole(tx, &memberships[i], defaultRole)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replaceGroupMembers makes the given users the group's only members
+func replaceGroupMembers(tx *gorm.DB, organizationID uuid.UUID, role constants.OrganizationRole, userIDs []uuid.UUID) error {
+	var currentUserIDs []uuid.UUID
+	err := tx.Model(&models.OrganizationMembership{}).
+		Where("organization_id = ? AND role = ?", organizationID, string(role)).
+		Pluck("user_id", &currentUserIDs).Error
+	if err != nil {
+		return err
+	}
+
+	removedUserIDs := make([]uuid.UUID, 0)
+	for _, userID := range currentUserIDs {
+		if !slices.Contains(userIDs, userID) {
+			removedUserIDs = append(removedUserIDs, userID)
+		}
+	}
+
+	err = removeGroupMembers(tx, organizationID, role, removedUserIDs)
+	if err != nil {
+		return err
+	}
+
+	return addGroupMembers(tx, organizationID, role, userIDs)
+}
+
+// setMembershipRole changes a member's role, revoking their tokens so they pick up the new scopes
+func setMembershipRole(tx *gorm.DB, membership *models.OrganizationMembership, role constants.OrganizationRole) error {
+	if membership.Role == string(role) {
+		return nil
+	}
+
+	membership.Role = string(role)
+	err := tx.Save(membership).Error
+	if err != nil {
+		return err
+	}
+
+	err = authentication.RevokeUserTokens(tx, membership.UserID, true)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Changed SCIM user's role in organization", "userID", membership.UserID, "organizationID", membership.OrganizationID, "role", role)
+	return nil
+}
@@ -0,0 +1,72 @@
+package scim
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"reece.start/internal/api"
+)
+
+func TestParseFilter(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		f, err := parseFilter("  ", userFilterAttributes)
+		require.NoError(t, err)
+		assert.Nil(t, f)
+	})
+
+	t.Run("Eq", func(t *testing.T) {
+		f, err := parseFilter(`userName eq "user@example.com"`, userFilterAttributes)
+		require.NoError(t, err)
+		assert.Equal(t, &filter{Attribute: "userName", Value: "user@example.com"}, f)
+	})
+
+	t.Run("CaseInsensitive", func(t *testing.T) {
+		f, err := parseFilter(`USERNAME EQ "user@example.com"`, userFilterAttributes)
+		require.NoError(t, err)
+		assert.Equal(t, "userName", f.Attribute)
+	})
+
+	t.Run("EscapedQuote", func(t *testing.T) {
+		f, err := parseFilter(`externalId eq "a\"b"`, userFilterAttributes)
+		require.NoError(t, err)
+		assert.Equal(t, `a"b`, f.Value)
+	})
+
+	t.Run("UnsupportedOperator", func(t *testing.T) {
+		_, err := parseFilter(`userName co "example"`, userFilterAttributes)
+		assert.ErrorIs(t, err, api.ErrScimInvalidFilter)
+	})
+
+	t.Run("UnsupportedAttribute", func(t *testing.T) {
+		_, err := parseFilter(`title eq "Engineer"`, userFilterAttributes)
+		assert.ErrorIs(t, err, api.ErrScimInvalidFilter)
+	})
+
+	t.Run("LogicalOperator", func(t *testing.T) {
+		_, err := parseFilter(`userName eq "a@example.com" or userName eq "b@example.com"`, userFilterAttributes)
+		assert.ErrorIs(t, err, api.ErrScimInvalidFilter)
+	})
+}
+
+func TestNormalizePagination(t *testing.T) {
+	startIndex, count := normalizePagination(0, -1)
+	assert.Equal(t, 1, startIndex)
+	assert.Equal(t, 0, count)
+
+	startIndex, count = normalizePagination(5, 1000)
+	assert.Equal(t, 5, startIndex)
+	assert.Equal(t, maxPageSize, count)
+}
+
+func TestParseBool(t *testing.T) {
+	for value, expected := range map[string]bool{`true`: true, `false`: false, `"False"`: false, `"True"`: true} {
+		parsed, err := parseBool(json.RawMessage(value))
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, parsed, value)
+	}
+
+	_, err := parseBool(json.RawMessage(`"maybe"`))
+	assert.ErrorIs(t, err, api.ErrScimInvalidPatch)
+}
@@ -0,0 +1,100 @@
+package users
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resend/resend-go/v2"
+	"github.com/riverqueue/river"
+	"gorm.io/gorm"
+	"reece.start/internal/configuration"
+	"reece.start/internal/constants"
+	"reece.start/internal/email"
+	"reece.start/internal/models"
+)
+
+// Lets the user know how they sign in was changed, at the address they had before the change
+type CredentialChangeEmailJobArgs struct {
+	UserId    uuid.UUID                  `json:"userId"`
+	Change    constants.CredentialChange `json:"change"`
+	Email     string                     `json:"email"`    // Address the user had before the change
+	NewEmail  string                     `json:"newEmail"` // Only set for email changes
+	Detail    string                     `json:"detail"`   // Name of the passkey, access token or OAuth provider that was added
+	ChangedAt time.Time                  `json:"changedAt"`
+}
+
+func (CredentialChangeEmailJobArgs) Kind() string {
+	return string(constants.JobKindCredentialChangeEmail)
+}
+
+type CredentialChangeEmailJobWorker struct {
+	river.WorkerDefaults[CredentialChangeEmailJobArgs]
+	DB           *gorm.DB
+	Config       *configuration.Config
+	ResendClient *resend.Client
+}
+
+func (w *CredentialChangeEmailJobWorker) Work(ctx context.Context, job *river.Job[CredentialChangeEmailJobArgs]) error {
+	slog.Info("Sending credential change email", "userId", job.Args.UserId, "change", job.Args.Change)
+
+	var user models.User
+	err := w.DB.First(&user, job.Args.UserId).Error
+	if err != nil {
+		return err
+	}
+
+	html, err := email.CredentialChangeEmailTemplateParams{
+		User:               user,
+		Change:             job.Args.Change,
+		NewEmail:           job.Args.NewEmail,
+		Detail:             job.Args.Detail,
+		ChangedAt:          job.Args.ChangedAt,
+		FrontendUrl:        w.Config.FrontendUrl,
+		ServiceName:        constants.ServiceName,
+		ServiceDescription: constants.ServiceDescription,
+	}.ApplyHtmlTemplate()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = email.SendEmail(email.SendEmailRequest{
+		Params: email.SendEmailParams{
+			From:    string(constants.EmailSenderDefault),
+			To:      []string{job.Args.Email},
+			Subject: getCredentialChangeEmailSubject(job.Args.Change),
+			Html:    html,
+		},
+		ResendClient: w.ResendClient,
+		Config:       w.Config,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func getCredentialChangeEmailSubject(change constants.CredentialChange) string {
+	switch change {
+	case constants.CredentialChangeEmail:
+		return "Your " + constants.ServiceName + " email address was changed"
+	case constants.CredentialChangePasswordAdded:
+		return "A password was added to your " + constants.ServiceName + " account"
+	case constants.CredentialChangePasskey:
+		return "A passkey was added to your " + constants.ServiceName + " account"
+	case constants.CredentialChangeIdentity:
+		return "Your " + constants.ServiceName + " account was linked to another sign in"
+	case constants.CredentialChangeAccessToken:
+		return "A personal access token was created for your " + constants.ServiceName + " account"
+	}
+
+	return "Your " + constants.ServiceName + " password was changed"
+}
+
+func (w *CredentialChangeEmailJobWorker) Timeout(*river.Job[CredentialChangeEmailJobArgs]) time.Duration {
+	return 180 * time.Second
+}
@@ -0,0 +1,89 @@
+package users
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resend/resend-go/v2"
+	"github.com/riverqueue/river"
+	"gorm.io/gorm"
+	"reece.start/internal/authentication"
+	"reece.start/internal/configuration"
+	"reece.start/internal/constants"
+	"reece.start/internal/email"
+	"reece.start/internal/models"
+)
+
+type EmailVerificationEmailJobArgs struct {
+	UserId uuid.UUID `json:"userId"`
+	Email  string    `json:"email"` // The address to verify, which is the user's pending email when they are changing it
+}
+
+func (EmailVerificationEmailJobArgs) Kind() string {
+	return string(constants.JobKindEmailVerificationEmail)
+}
+
+type EmailVerificationEmailJobWorker struct {
+	river.WorkerDefaults[EmailVerificationEmailJobArgs]
+	DB           *gorm.DB
+	Config       *configuration.Config
+	ResendClient *resend.Client
+}
+
+func (w *EmailVerificationEmailJobWorker) Work(ctx context.Context, job *river.Job[EmailVerificationEmailJobArgs]) error {
+	slog.Info("Sending email verification email", "userId", job.Args.UserId)
+
+	var user models.User
+	err := w.DB.First(&user, job.Args.UserId).Error
+	if err != nil {
+		return err
+	}
+
+	// Skip if the address was verified or changed again before the job ran
+	if getEmailToVerify(&user) != job.Args.Email {
+		slog.Info("Skipping email verification email, address no longer needs to be verified", "userId", job.Args.UserId)
+		return nil
+	}
+
+	// The token is signed rather than stored, so it is only created here when the email is sent
+	token, err := authentication.CreateEmailVerificationJWT(w.Config, user.ID, job.Args.Email)
+	if err != nil {
+		return err
+	}
+
+	html, err := email.EmailVerificationEmailTemplateParams{
+		User:               user,
+		Email:              job.Args.Email,
+		Token:              token,
+		FrontendUrl:        w.Config.FrontendUrl,
+		ServiceName:        constants.ServiceName,
+		ServiceDescription: constants.ServiceDescription,
+	}.ApplyHtmlTemplate()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = email.SendEmail(email.SendEmailRequest{
+		Params: email.SendEmailParams{
+			From:    string(constants.EmailSenderDefault),
+			To:      []string{job.Args.Email},
+			Subject: "Verify your " + constants.ServiceName + " email address",
+			Html:    html,
+		},
+		ResendClient: w.ResendClient,
+		Config:       w.Config,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (w *EmailVerificationEmailJobWorker) Timeout(*river.Job[EmailVerificationEmailJobArgs]) time.Duration {
+	return 180 * time.Second
+}
@@ -0,0 +1,171 @@
+package users_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"reece.start/internal/authentication"
+	"reece.start/internal/constants"
+	"reece.start/internal/models"
+	"reece.start/test"
+)
+
+func countEmailVerificationJobs(t *testing.T, tc *test.TestContext, user *models.User, email string) int64 {
+	var jobCount int64
+	err := tc.DB.Raw(`
+		SELECT COUNT(*)
+		FROM river_job
+		WHERE kind = ? AND args->>'userId' = ? AND args->>'email' = ?
+	`, string(constants.JobKindEmailVerificationEmail), user.ID.String(), email).Scan(&jobCount).Error
+	require.NoError(t, err)
+	return jobCount
+}
+
+// createEmailVerificationToken creates the token the email job would have sent, since it isn't stored anywhere
+func createEmailVerificationToken(t *testing.T, tc *test.TestContext, user *models.User, email string) string {
+	token, err := authentication.CreateEmailVerificationJWT(tc.Config, user.ID, email)
+	require.NoError(t, err)
+	return token
+}
+
+func confirmEmailVerification(tc *test.TestContext, token string) int {
+	reqBody := map[string]interface{}{
+		"data": map[string]interface{}{
+			"attributes": map[string]interface{}{
+				"token": token,
+			},
+		},
+	}
+
+	return tc.MakeRequest(http.MethodPost, "/users/email-verification/confirm", reqBody, nil).Code
+}
+
+// changeEmail returns the new token the user is given, since changing the email revokes the old one
+func changeEmail(t *testing.T, tc *test.TestContext, user *models.User, token string, email string) string {
+	reqBody := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type": constants.ApiTypeUser,
+			"attributes": map[string]interface{}{
+				"email": email,
+			},
+		},
+	}
+
+	rec := tc.MakeAuthenticatedRequest(http.MethodPatch, "/users/"+user.ID.String(), reqBody, token)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var response map[string]interface{}
+	tc.UnmarshalResponse(rec, &response)
+	return response["data"].(map[string]interface{})["meta"].(map[string]interface{})["token"].(string)
+}
+
+func TestEmailVerificationEmailJob(t *testing.T) {
+	t.Run("EnqueuedOnSignup", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, _ := test.CreateTestUserWithOptions(t, tc, test.TestUserOptions{UnverifiedEmail: true})
+
+		assert.Nil(t, user.EmailVerifiedAt)
+		assert.Equal(t, int64(1), countEmailVerificationJobs(t, tc, user, user.Email))
+	})
+
+	t.Run("EnqueuedOnEmailChange", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, token := test.CreateTestUser(t, tc)
+
+		changeEmail(t, tc, user, token, "changed@example.com")
+
+		assert.Equal(t, int64(1), countEmailVerificationJobs(t, tc, user, "changed@example.com"))
+	})
+
+	t.Run("ExecutesSuccessfully", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, _ := test.CreateTestUserWithOptions(t, tc, test.TestUserOptions{UnverifiedEmail: true})
+
+		test.RunAllPendingRiverJobs(t, tc.DB, tc.RiverClient)
+
+		var completedJobCount int64
+		err := tc.DB.Raw(`
+		SELECT COUNT(*)
+		FROM river_job
+		WHERE kind = ? AND args->>'userId' = ? AND state = 'completed'
+	`, string(constants.JobKindEmailVerificationEmail), user.ID.String()).Scan(&completedJobCount).Error
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), completedJobCount)
+	})
+}
+
+func TestSendEmailVerificationEndpoint(t *testing.T) {
+	t.Run("ResendsForUnverifiedEmail", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, token := test.CreateTestUserWithOptions(t, tc, test.TestUserOptions{UnverifiedEmail: true})
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodPost, "/users/me/email-verification", nil, token)
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, int64(2), countEmailVerificationJobs(t, tc, user, user.Email))
+	})
+
+	t.Run("AlreadyVerified", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, _, token := test.CreateTestUser(t, tc)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodPost, "/users/me/email-verification", nil, token)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+}
+
+func TestConfirmEmailVerificationEndpoint(t *testing.T) {
+	t.Run("VerifiesSignupEmail", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, _ := test.CreateTestUserWithOptions(t, tc, test.TestUserOptions{UnverifiedEmail: true})
+
+		token := createEmailVerificationToken(t, tc, user, user.Email)
+		assert.Equal(t, http.StatusNoContent, confirmEmailVerification(tc, token))
+
+		var updatedUser models.User
+		require.NoError(t, tc.DB.First(&updatedUser, user.ID).Error)
+		assert.NotNil(t, updatedUser.EmailVerifiedAt)
+	})
+
+	t.Run("AppliesPendingEmail", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, token := test.CreateTestUser(t, tc)
+
+		changeEmail(t, tc, user, token, "changed@example.com")
+
+		// The email isn't changed until it is verified
+		var updatedUser models.User
+		require.NoError(t, tc.DB.First(&updatedUser, user.ID).Error)
+		assert.Equal(t, user.Email, updatedUser.Email)
+		assert.Equal(t, "changed@example.com", updatedUser.PendingEmail)
+
+		verificationToken := createEmailVerificationToken(t, tc, user, "changed@example.com")
+		assert.Equal(t, http.StatusNoContent, confirmEmailVerification(tc, verificationToken))
+
+		require.NoError(t, tc.DB.First(&updatedUser, user.ID).Error)
+		assert.Equal(t, "changed@example.com", updatedUser.Email)
+		assert.Empty(t, updatedUser.PendingEmail)
+		assert.NotNil(t, updatedUser.EmailVerifiedAt)
+
+		// The link can't be used again
+		assert.Equal(t, http.StatusBadRequest, confirmEmailVerification(tc, verificationToken))
+	})
+
+	t.Run("RejectsSupersededEmail", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, token := test.CreateTestUser(t, tc)
+
+		token = changeEmail(t, tc, user, token, "first@example.com")
+		changeEmail(t, tc, user, token, "second@example.com")
+
+		firstToken := createEmailVerificationToken(t, tc, user, "first@example.com")
+		assert.Equal(t, http.StatusBadRequest, confirmEmailVerification(tc, firstToken))
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+
+		assert.Equal(t, http.StatusBadRequest, confirmEmailVerification(tc, "not-a-real-token"))
+	})
+}
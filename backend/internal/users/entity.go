@@ -1,12 +1,17 @@
 package users
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/minio/minio-go/v7"
+	"github.com/riverqueue/river"
 	"gorm.io/gorm"
 	"reece.start/internal/api"
+	"reece.start/internal/authentication"
 	"reece.start/internal/configuration"
 	"reece.start/internal/constants"
 	"reece.start/internal/models"
@@ -21,7 +26,7 @@ type UserAttributes struct {
 
 type CreateUserAttributes struct {
 	UserAttributes
-	Password string `json:"password" validate:"required,min=8"`
+	Password string `json:"password" validate:"required"` // Checked against the password policy
 }
 
 type LoginUserAttributes struct {
@@ -32,16 +37,178 @@ type LoginUserAttributes struct {
 type UpdateUserAttributes struct {
 	Name     string `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
 	Email    string `json:"email,omitempty" validate:"omitempty,email"`
-	Password string `json:"password,omitempty" validate:"omitempty,min=8"`
+	Password string `json:"password,omitempty"` // Checked against the password policy
 	Logo     string `json:"logo,omitempty" validate:"omitempty,base64"`
+
+	// Required to change the email or password, unless the user signed in recently
+	CurrentPassword string `json:"currentPassword,omitempty"`
+}
+
+type StartOAuthAuthorizationAttributes struct {
+	RedirectUri string `json:"redirectUri" validate:"required,url"`
 }
 
-type GoogleOAuthCallbackAttributes struct {
+type OAuthAuthorizationAttributes struct {
+	AuthorizationUrl string    `json:"authorizationUrl"`
+	State            string    `json:"state"`
+	ExpiresAt        time.Time `json:"expiresAt"`
+}
+
+type OAuthCallbackAttributes struct {
 	Code        string `json:"code" validate:"required"`
 	State       string `json:"state" validate:"required"`
 	RedirectUri string `json:"redirectUri" validate:"required,url"`
 }
 
+type SamlAuthorizationAttributes struct {
+	AuthorizationUrl string    `json:"authorizationUrl"`
+	State            string    `json:"state"`
+	ExpiresAt        time.Time `json:"expiresAt"`
+}
+
+type LoginSamlAttributes struct {
+	Code  string `json:"code" validate:"required"`
+	State string `json:"state" validate:"required"`
+}
+
+type UserIdentityAttributes struct {
+	Provider  string    `json:"provider"`
+	Email     string    `json:"email,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type ImpersonationAttributes struct {
+	Reason       string     `json:"reason"`
+	ReadOnly     bool       `json:"readOnly"`
+	UserNotified bool       `json:"userNotified"`
+	UserAgent    string     `json:"userAgent"`
+	IpAddress    string     `json:"ipAddress"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	ExpiresAt    time.Time  `json:"expiresAt"`
+	EndedAt      *time.Time `json:"endedAt"`
+}
+
+type LoginLockoutAttributes struct {
+	Scope          string    `json:"scope"`  // "account" or "ip"
+	Target         string    `json:"target"` // Email or IP address
+	FailedAttempts int       `json:"failedAttempts"`
+	LastFailedAt   time.Time `json:"lastFailedAt"`
+	LockedUntil    time.Time `json:"lockedUntil"`
+}
+
+type UserSessionAttributes struct {
+	UserAgent  string    `json:"userAgent"`
+	IpAddress  string    `json:"ipAddress"`
+	Current    bool      `json:"current"` // Whether this is the session the request was made with
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+type UserDataExportAttributes struct {
+	Status      constants.UserDataExportStatus `json:"status"`
+	CreatedAt   time.Time                      `json:"createdAt"`
+	CompletedAt *time.Time                     `json:"completedAt,omitempty"`
+	ExpiresAt   *time.Time                     `json:"expiresAt,omitempty"` // When the emailed download link stops working
+}
+
+type AccessTokenAttributes struct {
+	Name        string                `json:"name"`
+	TokenPrefix string                `json:"tokenPrefix"`
+	Scopes      []constants.UserScope `json:"scopes"`
+	CreatedAt   time.Time             `json:"createdAt"`
+	ExpiresAt   *time.Time            `json:"expiresAt,omitempty"`
+	LastUsedAt  *time.Time            `json:"lastUsedAt,omitempty"`
+	LastUsedIp  string                `json:"lastUsedIp,omitempty"`
+}
+
+type CreateAccessTokenAttributes struct {
+	Name      string                `json:"name" validate:"required,min=1,max=100"`
+	Scopes    []constants.UserScope `json:"scopes" validate:"dive,required"`
+	ExpiresAt *time.Time            `json:"expiresAt" validate:"omitempty,gt"` // Never expires if omitted
+
+	// Required unless the user signed in recently
+	CurrentPassword string `json:"currentPassword,omitempty"`
+}
+
+type SetInitialPasswordAttributes struct {
+	Password string `json:"password" validate:"required"` // Checked against the password policy
+}
+
+type TotpCodeAttributes struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// Either a TOTP code or one of the user's recovery codes
+type MfaCodeAttributes struct {
+	Code         string `json:"code,omitempty" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recoveryCode,omitempty" validate:"required_without=Code"`
+}
+
+type LoginMfaAttributes struct {
+	MfaChallengeToken string `json:"mfaChallengeToken" validate:"required"`
+	MfaCodeAttributes
+}
+
+type TotpEnrollmentAttributes struct {
+	Secret     string `json:"secret"`
+	OtpauthUri string `json:"otpauthUri"`
+}
+
+type MfaRecoveryCodesAttributes struct {
+	Codes []string `json:"codes"`
+}
+
+type PasskeyAttributes struct {
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+type CreatePasskeyAttributes struct {
+	ChallengeId string          `json:"challengeId" validate:"required,uuid"`
+	Name        string          `json:"name" validate:"required,min=1,max=100"`
+	Credential  json.RawMessage `json:"credential" validate:"required"` // PublicKeyCredential returned by navigator.credentials.create()
+
+	// Required unless the user signed in recently
+	CurrentPassword string `json:"currentPassword,omitempty"`
+}
+
+type UpdatePasskeyAttributes struct {
+	Name string `json:"name" validate:"required,min=1,max=100"`
+}
+
+type LoginPasskeyAttributes struct {
+	ChallengeId string          `json:"challengeId" validate:"required,uuid"`
+	Credential  json.RawMessage `json:"credential" validate:"required"` // PublicKeyCredential returned by navigator.credentials.get()
+}
+
+// Options to pass to navigator.credentials.create() or navigator.credentials.get()
+type PasskeyChallengeAttributes struct {
+	Options any `json:"options"`
+}
+
+type RequestPasswordResetAttributes struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ConfirmPasswordResetAttributes struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"` // Checked against the password policy
+}
+
+type RequestMagicLinkAttributes struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type LoginMagicLinkAttributes struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ConfirmEmailVerificationAttributes struct {
+	Token string `json:"token" validate:"required"`
+}
+
 type UserTokenRevocation struct {
 	LastIssuedAt *time.Time `json:"lastIssuedAt,omitempty"`
 	CanRefresh   bool       `json:"canRefresh,omitempty"`
@@ -49,8 +216,16 @@ type UserTokenRevocation struct {
 
 type UserMeta struct {
 	Token               string              `json:"token,omitempty"`
+	RefreshToken        string              `json:"refreshToken,omitempty"`
 	LogoDistributionUrl string              `json:"logoDistributionUrl,omitempty"`
 	TokenRevocation     UserTokenRevocation `json:"tokenRevocation,omitempty"`
+	MfaEnabled          bool                `json:"mfaEnabled,omitempty"`
+	MfaRequired         bool                `json:"mfaRequired,omitempty"`
+	MfaChallengeToken   string              `json:"mfaChallengeToken,omitempty"`
+	EmailVerifiedAt     *time.Time          `json:"emailVerifiedAt,omitempty"`
+	PendingEmail        string              `json:"pendingEmail,omitempty"` // Email the user changed to, applied once it is verified
+	HasPassword         bool                `json:"hasPassword,omitempty"`
+	DeletionScheduledAt *time.Time          `json:"deletionScheduledAt,omitempty"` // Signing in again before then cancels the deletion
 }
 
 type UserData struct {
@@ -88,6 +263,11 @@ type UserRelationship struct {
 	Data UserRelationshipData `json:"data" validate:"required"`
 }
 
+// NullableUserRelationship is a relationship to a user who may have been deleted, in which case its data is null
+type NullableUserRelationship struct {
+	Data *UserRelationshipData `json:"data"`
+}
+
 type CreateAuthenticatedUserTokenRelationships struct {
 	Organization     *OrganizationRelationship `json:"organization"`
 	ImpersonatedUser *UserRelationship         `json:"impersonatedUser"`
@@ -95,6 +275,11 @@ type CreateAuthenticatedUserTokenRelationships struct {
 
 type CreateAuthenticatedUserTokenRequestMeta struct {
 	StopImpersonating bool `json:"stopImpersonating"`
+
+	// Options for starting to impersonate a user. Every impersonation is logged with the reason, which is required.
+	ImpersonationReason    string `json:"impersonationReason" validate:"max=500"`
+	ImpersonationReadOnly  bool   `json:"impersonationReadOnly"`
+	NotifyImpersonatedUser bool   `json:"notifyImpersonatedUser"`
 }
 
 type CreateAuthenticatedUserTokenData struct {
@@ -108,7 +293,11 @@ type CreateAuthenticatedUserTokenRequest struct {
 }
 
 type CreateAuthenticatedUserTokenResponseMeta struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+
+	// When the impersonation the tokens were issued for ends
+	ImpersonationExpiresAt *time.Time `json:"impersonationExpiresAt,omitempty"`
 }
 
 type CreateAuthenticatedUserTokenResponseData struct {
@@ -121,6 +310,21 @@ type CreateAuthenticatedUserTokenResponse struct {
 	Data CreateAuthenticatedUserTokenResponseData `json:"data"`
 }
 
+type RefreshAuthenticatedUserTokenResponse struct {
+	Data CreateAuthenticatedUserTokenResponseData `json:"data"`
+}
+
+type RotateRefreshTokenAttributes struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+type RotateRefreshTokenRequest struct {
+	Data struct {
+		Type       constants.ApiType            `json:"type" validate:"oneof=token"`
+		Attributes RotateRefreshTokenAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
 type GetUsersQuery struct {
 	Cursor string `query:"page[cursor]"`
 	Size   int    `query:"page[size]" validate:"min=1,max=100"`
@@ -148,9 +352,274 @@ type UpdateUserRequest struct {
 	} `json:"data"`
 }
 
-type GoogleOAuthCallbackRequest struct {
+type ConfirmTotpEnrollmentRequest struct {
+	Data struct {
+		Attributes TotpCodeAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type RegenerateMfaRecoveryCodesRequest struct {
+	Data struct {
+		Attributes TotpCodeAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type DisableMfaRequest struct {
+	Data struct {
+		Attributes MfaCodeAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type LoginMfaRequest struct {
+	Data struct {
+		Attributes LoginMfaAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type TotpEnrollmentResponse struct {
+	Data struct {
+		Type       constants.ApiType        `json:"type"`
+		Attributes TotpEnrollmentAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type MfaRecoveryCodesResponse struct {
+	Data struct {
+		Type       constants.ApiType          `json:"type"`
+		Attributes MfaRecoveryCodesAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type PasskeyData struct {
+	Id         string            `json:"id"`
+	Type       constants.ApiType `json:"type"`
+	Attributes PasskeyAttributes `json:"attributes"`
+}
+
+type PasskeyResponse struct {
+	Data PasskeyData `json:"data"`
+}
+
+type PasskeysResponse struct {
+	Data []PasskeyData `json:"data"`
+}
+
+type PasskeyChallengeResponse struct {
+	Data struct {
+		Id         string                     `json:"id"`
+		Type       constants.ApiType          `json:"type"`
+		Attributes PasskeyChallengeAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type CreatePasskeyRequest struct {
+	Data struct {
+		Attributes CreatePasskeyAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type UpdatePasskeyRequest struct {
+	Data struct {
+		Attributes UpdatePasskeyAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type LoginPasskeyRequest struct {
+	Data struct {
+		Attributes LoginPasskeyAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type RequestPasswordResetRequest struct {
+	Data struct {
+		Attributes RequestPasswordResetAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type ConfirmPasswordResetRequest struct {
+	Data struct {
+		Attributes ConfirmPasswordResetAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type RequestMagicLinkRequest struct {
+	Data struct {
+		Attributes RequestMagicLinkAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type LoginMagicLinkRequest struct {
+	Data struct {
+		Attributes LoginMagicLinkAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type ConfirmEmailVerificationRequest struct {
+	Data struct {
+		Attributes ConfirmEmailVerificationAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type StartOAuthAuthorizationRequest struct {
+	Data struct {
+		Attributes StartOAuthAuthorizationAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type OAuthAuthorizationResponse struct {
+	Data struct {
+		Type       constants.ApiType            `json:"type"`
+		Attributes OAuthAuthorizationAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type OAuthCallbackRequest struct {
+	Data struct {
+		Attributes OAuthCallbackAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type SamlAuthorizationResponse struct {
+	Data struct {
+		Type       constants.ApiType           `json:"type"`
+		Attributes SamlAuthorizationAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type LoginSamlRequest struct {
+	Data struct {
+		Attributes LoginSamlAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+type UserIdentityData struct {
+	Id         string                 `json:"id"`
+	Type       constants.ApiType      `json:"type"`
+	Attributes UserIdentityAttributes `json:"attributes"`
+}
+
+type UserIdentityResponse struct {
+	Data UserIdentityData `json:"data"`
+}
+
+type UserIdentitiesResponse struct {
+	Data []UserIdentityData `json:"data"`
+}
+
+type UserSessionData struct {
+	Id         string                `json:"id"`
+	Type       constants.ApiType     `json:"type"`
+	Attributes UserSessionAttributes `json:"attributes"`
+}
+
+type UserSessionsResponse struct {
+	Data []UserSessionData `json:"data"`
+}
+
+type UserDataExportResponse struct {
+	Data struct {
+		Id         string                   `json:"id"`
+		Type       constants.ApiType        `json:"type"`
+		Attributes UserDataExportAttributes `json:"attributes"`
+	} `json:"data"`
+}
+
+// Files in a user's data export archive
+type UserDataExportProfile struct {
+	Id              string     `json:"id"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	PendingEmail    string     `json:"pendingEmail,omitempty"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"`
+	Role            string     `json:"role"`
+	MfaEnabled      bool       `json:"mfaEnabled"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+}
+
+type UserDataExportMembership struct {
+	OrganizationId   string    `json:"organizationId"`
+	OrganizationName string    `json:"organizationName"`
+	Role             string    `json:"role"`
+	CreatedAt        time.Time `json:"createdAt"`
+}
+
+type UserDataExportInvitation struct {
+	Id               string    `json:"id"`
+	OrganizationId   string    `json:"organizationId"`
+	OrganizationName string    `json:"organizationName"`
+	Email            string    `json:"email"`
+	Role             string    `json:"role"`
+	Status           string    `json:"status"`
+	CreatedAt        time.Time `json:"createdAt"`
+}
+
+type AccessTokenRelationships struct {
+	Organization *OrganizationRelationship `json:"organization,omitempty"`
+}
+
+type AccessTokenMeta struct {
+	Token string `json:"token"` // Only returned when the token is created
+}
+
+type AccessTokenData struct {
+	Id            string                   `json:"id"`
+	Type          constants.ApiType        `json:"type"`
+	Attributes    AccessTokenAttributes    `json:"attributes"`
+	Relationships AccessTokenRelationships `json:"relationships"`
+	Meta          *AccessTokenMeta         `json:"meta,omitempty"`
+}
+
+type AccessTokenResponse struct {
+	Data AccessTokenData `json:"data"`
+}
+
+type AccessTokensResponse struct {
+	Data []AccessTokenData `json:"data"`
+}
+
+type CreateAccessTokenRequest struct {
+	Data struct {
+		Type          constants.ApiType           `json:"type" validate:"oneof=access-token"`
+		Attributes    CreateAccessTokenAttributes `json:"attributes"`
+		Relationships AccessTokenRelationships    `json:"relationships"`
+	} `json:"data"`
+}
+
+type LoginLockoutData struct {
+	Id         string                 `json:"id"`
+	Type       constants.ApiType      `json:"type"`
+	Attributes LoginLockoutAttributes `json:"attributes"`
+}
+
+type LoginLockoutsResponse struct {
+	Data []LoginLockoutData `json:"data"`
+}
+
+type GetImpersonationsQuery struct {
+	// Only return impersonations of or by this user
+	UserId string `query:"userId" validate:"omitempty,uuid"`
+}
+
+type ImpersonationRelationships struct {
+	Impersonator     NullableUserRelationship `json:"impersonator"`
+	ImpersonatedUser NullableUserRelationship `json:"impersonatedUser"`
+}
+
+type ImpersonationData struct {
+	Id            string                     `json:"id"`
+	Type          constants.ApiType          `json:"type"`
+	Attributes    ImpersonationAttributes    `json:"attributes"`
+	Relationships ImpersonationRelationships `json:"relationships"`
+}
+
+type ImpersonationsResponse struct {
+	Data []ImpersonationData `json:"data"`
+}
+
+type SetInitialPasswordRequest struct {
 	Data struct {
-		Attributes GoogleOAuthCallbackAttributes `json:"attributes"`
+		Attributes SetInitialPasswordAttributes `json:"attributes"`
 	} `json:"data"`
 }
 
@@ -160,14 +629,7 @@ type CreateUserParams struct {
 	Email    string
 	Password string
 	Timezone string
-}
-
-type GoogleOAuthUserParams struct {
-	Name            string
-	Email           string
-	GoogleId        string
-	ProfileImageUrl string
-	TokenExpiry     time.Time
+	Client   SessionClientParams
 }
 
 type CreateUserServiceRequest struct {
@@ -180,6 +642,7 @@ type CreateUserServiceRequest struct {
 type LoginUserParams struct {
 	Email    string
 	Password string
+	Client   SessionClientParams
 }
 
 type LoginUserServiceRequest struct {
@@ -187,6 +650,7 @@ type LoginUserServiceRequest struct {
 	Tx          *gorm.DB
 	Config      *configuration.Config
 	MinioClient *minio.Client
+	RiverClient *river.Client[*sql.Tx]
 }
 
 type GetUserByIDServiceRequest struct {
@@ -196,11 +660,24 @@ type GetUserByIDServiceRequest struct {
 }
 
 type UpdateUserParams struct {
-	UserID   uuid.UUID
-	Name     string
-	Email    string
-	Password string
-	Logo     string
+	UserID          uuid.UUID
+	Name            string
+	Email           string
+	Password        string
+	Logo            string
+	CurrentPassword string
+	SessionID       uuid.UUID  // uuid.Nil if the request wasn't made with a session
+	OrganizationID  *uuid.UUID // Organization of the session's token, kept when its tokens are replaced
+	IsImpersonating bool
+	Client          SessionClientParams
+}
+
+// ReauthenticationParams are what's needed to check that the user themselves is changing how they sign in
+type ReauthenticationParams struct {
+	CurrentPassword string    // Optional if the user signed in recently
+	SessionID       uuid.UUID // uuid.Nil if the request wasn't made with a session
+	IpAddress       string
+	IsImpersonating bool
 }
 
 type UpdateUserServiceRequest struct {
@@ -208,6 +685,15 @@ type UpdateUserServiceRequest struct {
 	Tx          *gorm.DB
 	MinioClient *minio.Client
 	Config      *configuration.Config
+	RiverClient *river.Client[*sql.Tx]
+}
+
+type RecordFailedReauthenticationServiceRequest struct {
+	UserID      uuid.UUID
+	IpAddress   string
+	Tx          *gorm.DB
+	Config      *configuration.Config
+	RiverClient *river.Client[*sql.Tx]
 }
 
 type GetUserLogoDistributionUrlServiceRequest struct {
@@ -235,9 +721,19 @@ type GetUsersServiceResponse struct {
 type UserDto struct {
 	User                *models.User
 	Token               string
+	RefreshToken        string
+	MfaChallengeToken   string
 	LogoDistributionUrl string
 }
 
+type AuthenticatedUserTokenDto struct {
+	AccessToken  string
+	RefreshToken string
+
+	// Set when the tokens were issued for an impersonation
+	ImpersonationExpiresAt *time.Time
+}
+
 type CreateAuthenticatedUserTokenServiceRequest struct {
 	Params CreateAuthenticatedUserTokenParams
 	Tx     *gorm.DB
@@ -249,63 +745,867 @@ type CreateAuthenticatedUserTokenParams struct {
 	OrganizationId      *uuid.UUID
 	ImpersonatingUserId *uuid.UUID
 	CustomExpiry        *time.Time
+
+	// Impersonation the tokens are issued for, which has to be active when ImpersonatingUserId is set
+	ImpersonationSessionId *uuid.UUID
+
+	// Session to issue the tokens for, a new session is started if nil.
+	// The session ID doubles as the family of the new refresh token.
+	SessionId *uuid.UUID
+	Client    SessionClientParams
 }
 
-type GoogleOAuthCallbackServiceRequest struct {
-	Params        GoogleOAuthCallbackParams
-	Tx            *gorm.DB
-	Config        *configuration.Config
-	MinioClient   *minio.Client
-	PostHogClient *posthog.Client
+// SessionClientParams describes the client a session is used from
+type SessionClientParams struct {
+	UserAgent string
+	IpAddress string
 }
 
-type GoogleOAuthCallbackParams struct {
-	Code        string
-	State       string
-	RedirectUri string
+type RefreshAuthenticatedUserTokenServiceRequest struct {
+	Params RefreshAuthenticatedUserTokenParams
+	Tx     *gorm.DB
+	Config *configuration.Config
 }
 
-type SelectMembershipRole struct {
-	Role *constants.OrganizationRole
+type RefreshAuthenticatedUserTokenParams struct {
+	UserId                 uuid.UUID
+	OrganizationId         *uuid.UUID
+	ImpersonatingUserId    *uuid.UUID
+	ImpersonationSessionId *uuid.UUID
+	SessionId              *uuid.UUID
+	IssuedAt               *jwt.NumericDate
+	Client                 SessionClientParams
 }
 
-type GetUsersCursor struct {
-	UserID    uuid.UUID
-	Direction string
+type RefreshAuthenticatedUserTokenServiceResponse struct {
+	Tokens              *AuthenticatedUserTokenDto
+	UserId              uuid.UUID
+	OrganizationId      *uuid.UUID
+	ImpersonatingUserId *uuid.UUID
 }
 
-// Type mappers
-func mapUserToResponse(params *UserDto) UserResponse {
-	return UserResponse{
-		Data: UserDataWithMeta{
-			UserData: UserData{
-				Id:   params.User.ID.String(),
-				Type: constants.ApiTypeUser,
-				Attributes: UserAttributes{
-					Name:  params.User.Name,
-					Email: params.User.Email,
-				},
-			},
-			Meta: UserMeta{
+type RotateRefreshTokenServiceRequest struct {
+	Params RotateRefreshTokenParams
+	Tx     *gorm.DB
+	Config *configuration.Config
+}
+
+type RotateRefreshTokenParams struct {
+	RefreshToken string
+	Client       SessionClientParams
+}
+
+type StartTotpEnrollmentServiceRequest struct {
+	UserID uuid.UUID
+	Tx     *gorm.DB
+	Config *configuration.Config
+}
+
+type TotpEnrollmentDto struct {
+	Secret     string
+	OtpauthUri string
+}
+
+type ConfirmTotpEnrollmentParams struct {
+	UserID uuid.UUID
+	Code   string
+}
+
+type ConfirmTotpEnrollmentServiceRequest struct {
+	Params ConfirmTotpEnrollmentParams
+	Tx     *gorm.DB
+}
+
+type RegenerateMfaRecoveryCodesParams struct {
+	UserID uuid.UUID
+	Code   string
+}
+
+type RegenerateMfaRecoveryCodesServiceRequest struct {
+	Params RegenerateMfaRecoveryCodesParams
+	Tx     *gorm.DB
+}
+
+type MfaRecoveryCodesDto struct {
+	Codes []string
+}
+
+type DisableMfaParams struct {
+	UserID       uuid.UUID
+	Code         string
+	RecoveryCode string
+}
+
+type DisableMfaServiceRequest struct {
+	Params DisableMfaParams
+	Tx     *gorm.DB
+}
+
+type ResetUserMfaServiceRequest struct {
+	UserID uuid.UUID
+	Tx     *gorm.DB
+}
+
+type LoginMfaParams struct {
+	MfaChallengeToken string
+	Code              string
+	RecoveryCode      string
+	Client            SessionClientParams
+}
+
+type LoginMfaServiceRequest struct {
+	Params      LoginMfaParams
+	Tx          *gorm.DB
+	Config      *configuration.Config
+	MinioClient *minio.Client
+	RiverClient *river.Client[*sql.Tx]
+}
+
+type PasskeyChallengeDto struct {
+	ChallengeId uuid.UUID
+	Options     any
+}
+
+type BeginPasskeyRegistrationServiceRequest struct {
+	UserID uuid.UUID
+	Tx     *gorm.DB
+	Config *configuration.Config
+}
+
+type FinishPasskeyRegistrationParams struct {
+	UserID           uuid.UUID
+	ChallengeId      uuid.UUID
+	Name             string
+	Credential       []byte
+	Reauthentication ReauthenticationParams
+}
+
+type FinishPasskeyRegistrationServiceRequest struct {
+	Params      FinishPasskeyRegistrationParams
+	Tx          *gorm.DB
+	Config      *configuration.Config
+	RiverClient *river.Client[*sql.Tx]
+}
+
+type GetPasskeysServiceRequest struct {
+	UserID uuid.UUID
+	Tx     *gorm.DB
+}
+
+type UpdatePasskeyParams struct {
+	UserID    uuid.UUID
+	PasskeyID uuid.UUID
+	Name      string
+}
+
+type UpdatePasskeyServiceRequest struct {
+	Params UpdatePasskeyParams
+	Tx     *gorm.DB
+}
+
+type DeletePasskeyServiceRequest struct {
+	UserID    uuid.UUID
+	PasskeyID uuid.UUID
+	Tx        *gorm.DB
+}
+
+type BeginPasskeyLoginServiceRequest struct {
+	Tx     *gorm.DB
+	Config *configuration.Config
+}
+
+type FinishPasskeyLoginParams struct {
+	ChallengeId uuid.UUID
+	Credential  []byte
+	Client      SessionClientParams
+}
+
+type FinishPasskeyLoginServiceRequest struct {
+	Params      FinishPasskeyLoginParams
+	Tx          *gorm.DB
+	Config      *configuration.Config
+	MinioClient *minio.Client
+}
+
+type RequestPasswordResetParams struct {
+	Email     string
+	IpAddress string
+}
+
+type RequestPasswordResetServiceRequest struct {
+	Params      RequestPasswordResetParams
+	Tx          *gorm.DB
+	Config      *configuration.Config
+	RiverClient *river.Client[*sql.Tx]
+}
+
+type ConfirmPasswordResetParams struct {
+	Token    string
+	Password string
+}
+
+type ConfirmPasswordResetServiceRequest struct {
+	Params      ConfirmPasswordResetParams
+	Tx          *gorm.DB
+	Config      *configuration.Config
+	RiverClient *river.Client[*sql.Tx]
+}
+
+type RequestMagicLinkParams struct {
+	Email     string
+	IpAddress string
+}
+
+type RequestMagicLinkServiceRequest struct {
+	Params      RequestMagicLinkParams
+	Tx          *gorm.DB
+	Config      *configuration.Config
+	RiverClient *river.Client[*sql.Tx]
+}
+
+type LoginMagicLinkParams struct {
+	Token  string
+	Client SessionClientParams
+}
+
+type LoginMagicLinkServiceRequest struct {
+	Params      LoginMagicLinkParams
+	Tx          *gorm.DB
+	Config      *configuration.Config
+	MinioClient *minio.Client
+}
+
+type SendEmailVerificationServiceRequest struct {
+	UserID      uuid.UUID
+	Tx          *gorm.DB
+	RiverClient *river.Client[*sql.Tx]
+}
+
+type ConfirmEmailVerificationParams struct {
+	Token string
+}
+
+type ConfirmEmailVerificationServiceRequest struct {
+	Params      ConfirmEmailVerificationParams
+	Tx          *gorm.DB
+	Config      *configuration.Config
+	RiverClient *river.Client[*sql.Tx]
+}
+
+type StartOAuthAuthorizationParams struct {
+	Provider    string
+	RedirectUri string
+}
+
+type StartOAuthAuthorizationServiceRequest struct {
+	Params StartOAuthAuthorizationParams
+	Tx     *gorm.DB
+	Config *configuration.Config
+}
+
+type OAuthAuthorizationDto struct {
+	AuthorizationUrl string
+	State            string
+	ExpiresAt        time.Time
+}
+
+type OAuthCallbackServiceRequest struct {
+	Params        OAuthCallbackParams
+	Tx            *gorm.DB
+	Config        *configuration.Config
+	MinioClient   *minio.Client
+	PostHogClient *posthog.Client
+}
+
+type OAuthCallbackParams struct {
+	Provider    string
+	Code        string
+	State       string
+	RedirectUri string
+	Client      SessionClientParams
+}
+
+type GetSamlMetadataServiceRequest struct {
+	OrganizationID uuid.UUID
+	Tx             *gorm.DB
+	Config         *configuration.Config
+}
+
+type StartSamlLoginServiceRequest struct {
+	OrganizationID uuid.UUID
+	Tx             *gorm.DB
+	Config         *configuration.Config
+}
+
+type SamlAuthorizationDto struct {
+	AuthorizationUrl string
+	State            string
+	ExpiresAt        time.Time
+}
+
+type ConsumeSamlResponseParams struct {
+	OrganizationID uuid.UUID
+	SamlResponse   string
+	RelayState     string
+}
+
+type ConsumeSamlResponseServiceRequest struct {
+	Params        ConsumeSamlResponseParams
+	Tx            *gorm.DB
+	Config        *configuration.Config
+	PostHogClient *posthog.Client
+}
+
+type SamlLoginCodeDto struct {
+	Code  string
+	State string
+}
+
+type LoginSamlParams struct {
+	Code   string
+	State  string
+	Client SessionClientParams
+}
+
+type LoginSamlServiceRequest struct {
+	Params      LoginSamlParams
+	Tx          *gorm.DB
+	Config      *configuration.Config
+	MinioClient *minio.Client
+}
+
+type RecordFailedLoginParams struct {
+	Email     string
+	IpAddress string
+	UserID    *uuid.UUID // Set if there is a user with the email
+}
+
+type RecordFailedLoginServiceRequest struct {
+	Params      RecordFailedLoginParams
+	Tx          *gorm.DB
+	Config      *configuration.Config
+	RiverClient *river.Client[*sql.Tx]
+}
+
+type StartImpersonationParams struct {
+	ImpersonatorUserId uuid.UUID
+	ImpersonatedUserId uuid.UUID
+	OrganizationId     *uuid.UUID
+	Reason             string
+	ReadOnly           bool
+	NotifyUser         bool
+
+	// Session of the admin, which the impersonation's tokens are issued for
+	SessionId *uuid.UUID
+	Client    SessionClientParams
+}
+
+type StartImpersonationServiceRequest struct {
+	Params      StartImpersonationParams
+	Tx          *gorm.DB
+	Config      *configuration.Config
+	RiverClient *river.Client[*sql.Tx]
+}
+
+type ScheduleUserDeletionServiceRequest struct {
+	UserID      uuid.UUID
+	Tx          *gorm.DB
+	Config      *configuration.Config
+	RiverClient *river.Client[*sql.Tx]
+}
+
+type CreateUserDataExportServiceRequest struct {
+	UserID      uuid.UUID
+	Tx          *gorm.DB
+	RiverClient *river.Client[*sql.Tx]
+}
+
+type GetUserDataExportServiceRequest struct {
+	UserID uuid.UUID
+	Tx     *gorm.DB
+}
+
+type EndImpersonationServiceRequest struct {
+	ImpersonationSessionID uuid.UUID
+	Tx                     *gorm.DB
+}
+
+type GetImpersonationsServiceRequest struct {
+	UserID *uuid.UUID
+	Tx     *gorm.DB
+}
+
+type GetLoginLockoutsServiceRequest struct {
+	Tx *gorm.DB
+}
+
+type DeleteLoginLockoutServiceRequest struct {
+	LockoutID uuid.UUID
+	Tx        *gorm.DB
+}
+
+type GetUserIdentitiesServiceRequest struct {
+	UserID uuid.UUID
+	Tx     *gorm.DB
+}
+
+type LinkUserIdentityParams struct {
+	UserID           uuid.UUID
+	Provider         string
+	Code             string
+	State            string
+	RedirectUri      string
+	Reauthentication ReauthenticationParams
+}
+
+type LinkUserIdentityServiceRequest struct {
+	Params      LinkUserIdentityParams
+	Tx          *gorm.DB
+	Config      *configuration.Config
+	RiverClient *river.Client[*sql.Tx]
+}
+
+type DeleteUserIdentityServiceRequest struct {
+	UserID     uuid.UUID
+	IdentityID uuid.UUID
+	Tx         *gorm.DB
+}
+
+type GetUserSessionsServiceRequest struct {
+	UserID uuid.UUID
+	Tx     *gorm.DB
+}
+
+type DeleteUserSessionServiceRequest struct {
+	UserID    uuid.UUID
+	SessionID uuid.UUID
+	Tx        *gorm.DB
+}
+
+type DeleteOtherUserSessionsServiceRequest struct {
+	UserID           uuid.UUID
+	CurrentSessionID uuid.UUID // uuid.Nil if the request wasn't made with a session
+	Tx               *gorm.DB
+}
+
+type GetAccessTokensServiceRequest struct {
+	UserID uuid.UUID
+	Tx     *gorm.DB
+}
+
+type CreateAccessTokenParams struct {
+	UserID           uuid.UUID
+	Name             string
+	Scopes           []constants.UserScope
+	OrganizationID   *uuid.UUID
+	ExpiresAt        *time.Time
+	Reauthentication ReauthenticationParams
+}
+
+type CreateAccessTokenServiceRequest struct {
+	Params      CreateAccessTokenParams
+	Tx          *gorm.DB
+	Config      *configuration.Config
+	RiverClient *river.Client[*sql.Tx]
+}
+
+type AccessTokenDto struct {
+	AccessToken *models.PersonalAccessToken
+	Token       string
+}
+
+type DeleteAccessTokenServiceRequest struct {
+	UserID        uuid.UUID
+	AccessTokenID uuid.UUID
+	Tx            *gorm.DB
+}
+
+type SetInitialPasswordParams struct {
+	UserID           uuid.UUID
+	Password         string
+	Reauthentication ReauthenticationParams
+}
+
+type SetInitialPasswordServiceRequest struct {
+	Params      SetInitialPasswordParams
+	Tx          *gorm.DB
+	Config      *configuration.Config
+	RiverClient *river.Client[*sql.Tx]
+}
+
+type GetUsersCursor struct {
+	UserID    uuid.UUID
+	Direction string
+}
+
+// Type mappers
+func mapUserToResponse(params *UserDto) UserResponse {
+	return UserResponse{
+		Data: UserDataWithMeta{
+			UserData: UserData{
+				Id:   params.User.ID.String(),
+				Type: constants.ApiTypeUser,
+				Attributes: UserAttributes{
+					Name:  params.User.Name,
+					Email: params.User.Email,
+				},
+			},
+			Meta: UserMeta{
 				Token:               params.Token,
+				RefreshToken:        params.RefreshToken,
 				LogoDistributionUrl: params.LogoDistributionUrl,
 				TokenRevocation: UserTokenRevocation{
 					LastIssuedAt: params.User.Revocation.LastValidIssuedAt,
 					CanRefresh:   params.User.Revocation.CanRefresh,
 				},
+				MfaEnabled:          params.User.Mfa.Enabled,
+				MfaRequired:         params.MfaChallengeToken != "",
+				MfaChallengeToken:   params.MfaChallengeToken,
+				EmailVerifiedAt:     params.User.EmailVerifiedAt,
+				PendingEmail:        params.User.PendingEmail,
+				HasPassword:         params.User.HashedPassword != nil,
+				DeletionScheduledAt: params.User.DeletionScheduledAt,
 			},
 		},
 	}
 }
 
-func mapCreateAuthenticatedUserTokenToResponse(req CreateAuthenticatedUserTokenRequest, token string) CreateAuthenticatedUserTokenResponse {
+func mapRefreshAuthenticatedUserTokenToResponse(response *RefreshAuthenticatedUserTokenServiceResponse) RefreshAuthenticatedUserTokenResponse {
+	relationships := CreateAuthenticatedUserTokenRelationships{}
+
+	if response.OrganizationId != nil {
+		relationships.Organization = &OrganizationRelationship{
+			Data: OrganizationRelationshipData{
+				Id:   response.OrganizationId.String(),
+				Type: string(constants.ApiTypeOrganization),
+			},
+		}
+	}
+
+	if response.ImpersonatingUserId != nil {
+		relationships.ImpersonatedUser = &UserRelationship{
+			Data: UserRelationshipData{
+				Id:   response.UserId.String(),
+				Type: string(constants.ApiTypeUser),
+			},
+		}
+	}
+
+	return RefreshAuthenticatedUserTokenResponse{
+		Data: CreateAuthenticatedUserTokenResponseData{
+			Type:          constants.ApiTypeToken,
+			Relationships: relationships,
+			Meta: CreateAuthenticatedUserTokenResponseMeta{
+				Token:                  response.Tokens.AccessToken,
+				RefreshToken:           response.Tokens.RefreshToken,
+				ImpersonationExpiresAt: response.Tokens.ImpersonationExpiresAt,
+			},
+		},
+	}
+}
+
+func mapCreateAuthenticatedUserTokenToResponse(req CreateAuthenticatedUserTokenRequest, tokens *AuthenticatedUserTokenDto) CreateAuthenticatedUserTokenResponse {
 	return CreateAuthenticatedUserTokenResponse{
 		Data: CreateAuthenticatedUserTokenResponseData{
 			Type:          constants.ApiTypeToken,
 			Relationships: req.Data.Relationships,
 			Meta: CreateAuthenticatedUserTokenResponseMeta{
-				Token: token,
+				Token:                  tokens.AccessToken,
+				RefreshToken:           tokens.RefreshToken,
+				ImpersonationExpiresAt: tokens.ImpersonationExpiresAt,
+			},
+		},
+	}
+}
+
+func mapTotpEnrollmentToResponse(enrollment *TotpEnrollmentDto) TotpEnrollmentResponse {
+	response := TotpEnrollmentResponse{}
+	response.Data.Type = constants.ApiTypeTotpEnrollment
+	response.Data.Attributes = TotpEnrollmentAttributes{
+		Secret:     enrollment.Secret,
+		OtpauthUri: enrollment.OtpauthUri,
+	}
+	return response
+}
+
+func mapMfaRecoveryCodesToResponse(recoveryCodes *MfaRecoveryCodesDto) MfaRecoveryCodesResponse {
+	response := MfaRecoveryCodesResponse{}
+	response.Data.Type = constants.ApiTypeMfaRecoveryCodes
+	response.Data.Attributes = MfaRecoveryCodesAttributes{
+		Codes: recoveryCodes.Codes,
+	}
+	return response
+}
+
+func mapPasskeyToData(passkey *models.Passkey) PasskeyData {
+	return PasskeyData{
+		Id:   passkey.ID.String(),
+		Type: constants.ApiTypePasskey,
+		Attributes: PasskeyAttributes{
+			Name:       passkey.Name,
+			CreatedAt:  passkey.CreatedAt,
+			LastUsedAt: passkey.LastUsedAt,
+		},
+	}
+}
+
+func mapPasskeyToResponse(passkey *models.Passkey) PasskeyResponse {
+	return PasskeyResponse{
+		Data: mapPasskeyToData(passkey),
+	}
+}
+
+func mapPasskeysToResponse(passkeys []models.Passkey) PasskeysResponse {
+	data := make([]PasskeyData, 0, len(passkeys))
+	for i := range passkeys {
+		data = append(data, mapPasskeyToData(&passkeys[i]))
+	}
+	return PasskeysResponse{Data: data}
+}
+
+func mapUserIdentityToData(identity *models.UserIdentity) UserIdentityData {
+	return UserIdentityData{
+		Id:   identity.ID.String(),
+		Type: constants.ApiTypeUserIdentity,
+		Attributes: UserIdentityAttributes{
+			Provider:  identity.Provider,
+			Email:     identity.Email,
+			CreatedAt: identity.CreatedAt,
+		},
+	}
+}
+
+func mapUserIdentityToResponse(identity *models.UserIdentity) UserIdentityResponse {
+	return UserIdentityResponse{
+		Data: mapUserIdentityToData(identity),
+	}
+}
+
+func mapUserIdentitiesToResponse(identities []models.UserIdentity) UserIdentitiesResponse {
+	data := make([]UserIdentityData, 0, len(identities))
+	for i := range identities {
+		data = append(data, mapUserIdentityToData(&identities[i]))
+	}
+	return UserIdentitiesResponse{Data: data}
+}
+
+func mapUserSessionToData(session *models.UserSession, currentSessionID uuid.UUID) UserSessionData {
+	return UserSessionData{
+		Id:   session.ID.String(),
+		Type: constants.ApiTypeUserSession,
+		Attributes: UserSessionAttributes{
+			UserAgent:  session.UserAgent,
+			IpAddress:  session.IpAddress,
+			Current:    session.ID == currentSessionID,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+		},
+	}
+}
+
+func mapUserDataExportToResponse(export *models.UserDataExport) UserDataExportResponse {
+	response := UserDataExportResponse{}
+	response.Data.Id = export.ID.String()
+	response.Data.Type = constants.ApiTypeUserDataExport
+	response.Data.Attributes = UserDataExportAttributes{
+		Status:      constants.UserDataExportStatus(export.Status),
+		CreatedAt:   export.CreatedAt,
+		CompletedAt: export.CompletedAt,
+		ExpiresAt:   export.ExpiresAt,
+	}
+	return response
+}
+
+func mapUserToDataExportProfile(user *models.User) UserDataExportProfile {
+	return UserDataExportProfile{
+		Id:              user.ID.String(),
+		Name:            user.Name,
+		Email:           user.Email,
+		PendingEmail:    user.PendingEmail,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		Role:            user.Role,
+		MfaEnabled:      user.Mfa.Enabled,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+	}
+}
+
+func mapMembershipsToDataExport(memberships []models.OrganizationMembership) []UserDataExportMembership {
+	data := make([]UserDataExportMembership, 0, len(memberships))
+	for _, membership := range memberships {
+		data = append(data, UserDataExportMembership{
+			OrganizationId:   membership.OrganizationID.String(),
+			OrganizationName: membership.Organization.Name,
+			Role:             membership.Role,
+			CreatedAt:        membership.CreatedAt,
+		})
+	}
+	return data
+}
+
+func mapInvitationsToDataExport(invitations []models.OrganizationInvitation) []UserDataExportInvitation {
+	data := make([]UserDataExportInvitation, 0, len(invitations))
+	for _, invitation := range invitations {
+		data = append(data, UserDataExportInvitation{
+			Id:               invitation.ID.String(),
+			OrganizationId:   invitation.OrganizationID.String(),
+			OrganizationName: invitation.Organization.Name,
+			Email:            invitation.Email,
+			Role:             invitation.Role,
+			Status:           invitation.Status,
+			CreatedAt:        invitation.CreatedAt,
+		})
+	}
+	return data
+}
+
+func mapUserSessionsToResponse(sessions []models.UserSession, currentSessionID uuid.UUID) UserSessionsResponse {
+	data := make([]UserSessionData, 0, len(sessions))
+	for i := range sessions {
+		data = append(data, mapUserSessionToData(&sessions[i], currentSessionID))
+	}
+	return UserSessionsResponse{Data: data}
+}
+
+func mapAccessTokenToData(accessToken *models.PersonalAccessToken) AccessTokenData {
+	data := AccessTokenData{
+		Id:   accessToken.ID.String(),
+		Type: constants.ApiTypeAccessToken,
+		Attributes: AccessTokenAttributes{
+			Name:        accessToken.Name,
+			TokenPrefix: accessToken.TokenPrefix,
+			Scopes:      authentication.ParseScopes(accessToken.Scopes),
+			CreatedAt:   accessToken.CreatedAt,
+			ExpiresAt:   accessToken.ExpiresAt,
+			LastUsedAt:  accessToken.LastUsedAt,
+			LastUsedIp:  accessToken.LastUsedIp,
+		},
+	}
+
+	if accessToken.OrganizationID != nil {
+		data.Relationships.Organization = &OrganizationRelationship{
+			Data: OrganizationRelationshipData{
+				Id:   accessToken.OrganizationID.String(),
+				Type: string(constants.ApiTypeOrganization),
 			},
+		}
+	}
+
+	return data
+}
+
+func mapAccessTokenDtoToResponse(dto *AccessTokenDto) AccessTokenResponse {
+	data := mapAccessTokenToData(dto.AccessToken)
+	data.Meta = &AccessTokenMeta{
+		Token: dto.Token,
+	}
+	return AccessTokenResponse{Data: data}
+}
+
+func mapAccessTokensToResponse(accessTokens []models.PersonalAccessToken) AccessTokensResponse {
+	data := make([]AccessTokenData, 0, len(accessTokens))
+	for i := range accessTokens {
+		data = append(data, mapAccessTokenToData(&accessTokens[i]))
+	}
+	return AccessTokensResponse{Data: data}
+}
+
+func mapLoginLockoutToData(lockout *models.LoginThrottle) LoginLockoutData {
+	data := LoginLockoutData{
+		Id:   lockout.ID.String(),
+		Type: constants.ApiTypeLoginLockout,
+		Attributes: LoginLockoutAttributes{
+			Scope:          lockout.Scope,
+			Target:         lockout.Target,
+			FailedAttempts: lockout.FailedAttempts,
+			LastFailedAt:   lockout.LastFailedAt,
+		},
+	}
+	if lockout.LockedUntil != nil {
+		data.Attributes.LockedUntil = *lockout.LockedUntil
+	}
+	return data
+}
+
+func mapImpersonationToData(impersonation *models.ImpersonationSession) ImpersonationData {
+	return ImpersonationData{
+		Id:   impersonation.ID.String(),
+		Type: constants.ApiTypeImpersonation,
+		Attributes: ImpersonationAttributes{
+			Reason:       impersonation.Reason,
+			ReadOnly:     impersonation.ReadOnly,
+			UserNotified: impersonation.UserNotified,
+			UserAgent:    impersonation.UserAgent,
+			IpAddress:    impersonation.IpAddress,
+			CreatedAt:    impersonation.CreatedAt,
+			ExpiresAt:    impersonation.ExpiresAt,
+			EndedAt:      impersonation.EndedAt,
+		},
+		Relationships: ImpersonationRelationships{
+			Impersonator:     mapNullableUserRelationship(impersonation.ImpersonatorUserID),
+			ImpersonatedUser: mapNullableUserRelationship(impersonation.ImpersonatedUserID),
 		},
 	}
 }
+
+func mapNullableUserRelationship(userId *uuid.UUID) NullableUserRelationship {
+	if userId == nil {
+		return NullableUserRelationship{Data: nil}
+	}
+
+	return NullableUserRelationship{
+		Data: &UserRelationshipData{
+			Id:   userId.String(),
+			Type: string(constants.ApiTypeUser),
+		},
+	}
+}
+
+func mapImpersonationsToResponse(impersonations []models.ImpersonationSession) ImpersonationsResponse {
+	data := make([]ImpersonationData, 0, len(impersonations))
+	for i := range impersonations {
+		data = append(data, mapImpersonationToData(&impersonations[i]))
+	}
+	return ImpersonationsResponse{Data: data}
+}
+
+func mapLoginLockoutsToResponse(lockouts []models.LoginThrottle) LoginLockoutsResponse {
+	data := make([]LoginLockoutData, 0, len(lockouts))
+	for i := range lockouts {
+		data = append(data, mapLoginLockoutToData(&lockouts[i]))
+	}
+	return LoginLockoutsResponse{Data: data}
+}
+
+func mapOAuthAuthorizationToResponse(authorization *OAuthAuthorizationDto) OAuthAuthorizationResponse {
+	response := OAuthAuthorizationResponse{}
+	response.Data.Type = constants.ApiTypeOAuthAuthorization
+	response.Data.Attributes = OAuthAuthorizationAttributes{
+		AuthorizationUrl: authorization.AuthorizationUrl,
+		State:            authorization.State,
+		ExpiresAt:        authorization.ExpiresAt,
+	}
+	return response
+}
+
+func mapSamlAuthorizationToResponse(authorization *SamlAuthorizationDto) SamlAuthorizationResponse {
+	response := SamlAuthorizationResponse{}
+	response.Data.Type = constants.ApiTypeSamlAuthorization
+	response.Data.Attributes = SamlAuthorizationAttributes{
+		AuthorizationUrl: authorization.AuthorizationUrl,
+		State:            authorization.State,
+		ExpiresAt:        authorization.ExpiresAt,
+	}
+	return response
+}
+
+func mapPasskeyChallengeToResponse(challenge *PasskeyChallengeDto) PasskeyChallengeResponse {
+	response := PasskeyChallengeResponse{}
+	response.Data.Id = challenge.ChallengeId.String()
+	response.Data.Type = constants.ApiTypePasskeyChallenge
+	response.Data.Attributes = PasskeyChallengeAttributes{
+		Options: challenge.Options,
+	}
+	return response
+}
@@ -96,10 +96,11 @@ func TestMapCreateAuthenticatedUserTokenToResponse(t *testing.T) {
 
 		token := "jwt-token-123"
 
-		result := mapCreateAuthenticatedUserTokenToResponse(req, token)
+		result := mapCreateAuthenticatedUserTokenToResponse(req, &AuthenticatedUserTokenDto{AccessToken: token, RefreshToken: "refresh-token"})
 
 		assert.Equal(t, constants.ApiTypeToken, result.Data.Type)
 		assert.Equal(t, token, result.Data.Meta.Token)
+		assert.Equal(t, "refresh-token", result.Data.Meta.RefreshToken)
 		assert.NotNil(t, result.Data.Relationships.Organization)
 		assert.Equal(t, "123", result.Data.Relationships.Organization.Data.Id)
 		assert.Equal(t, "organization", result.Data.Relationships.Organization.Data.Type)
@@ -125,7 +126,7 @@ func TestMapCreateAuthenticatedUserTokenToResponse(t *testing.T) {
 
 		token := "jwt-token-456"
 
-		result := mapCreateAuthenticatedUserTokenToResponse(req, token)
+		result := mapCreateAuthenticatedUserTokenToResponse(req, &AuthenticatedUserTokenDto{AccessToken: token, RefreshToken: "refresh-token"})
 
 		assert.Equal(t, constants.ApiTypeToken, result.Data.Type)
 		assert.Equal(t, token, result.Data.Meta.Token)
@@ -160,7 +161,7 @@ func TestMapCreateAuthenticatedUserTokenToResponse(t *testing.T) {
 
 		token := "jwt-token-789"
 
-		result := mapCreateAuthenticatedUserTokenToResponse(req, token)
+		result := mapCreateAuthenticatedUserTokenToResponse(req, &AuthenticatedUserTokenDto{AccessToken: token, RefreshToken: "refresh-token"})
 
 		assert.Equal(t, token, result.Data.Meta.Token)
 		assert.NotNil(t, result.Data.Relationships.Organization)
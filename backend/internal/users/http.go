@@ -1,31 +1,49 @@
 package users
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 	"reece.start/internal/access"
 	"reece.start/internal/api"
 	"reece.start/internal/constants"
 	"reece.start/internal/middleware"
+	"reece.start/internal/models"
 )
 
 func CreateUserEndpoint(c echo.Context, req CreateUserRequest) error {
 	config := middleware.GetConfig(c)
 	db := middleware.GetDB(c)
 	posthogClient := middleware.GetPostHogClient(c)
+	riverClient := middleware.GetRiverClient(c)
+
+	var user *UserDto
+	err := db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		user, err = createUser(CreateUserServiceRequest{
+			Params: CreateUserParams{
+				Name:     req.Data.Attributes.Name,
+				Email:    req.Data.Attributes.Email,
+				Password: req.Data.Attributes.Password,
+				Client:   getSessionClient(c),
+			},
+			Tx:            tx,
+			Config:        config,
+			PostHogClient: posthogClient,
+		})
+		if err != nil {
+			return err
+		}
 
-	user, err := createUser(CreateUserServiceRequest{
-		Params: CreateUserParams{
-			Name:     req.Data.Attributes.Name,
-			Email:    req.Data.Attributes.Email,
-			Password: req.Data.Attributes.Password,
-		},
-		Tx:            db,
-		Config:        config,
-		PostHogClient: posthogClient,
+		return sendEmailVerification(SendEmailVerificationServiceRequest{
+			UserID:      user.User.ID,
+			Tx:          tx,
+			RiverClient: riverClient,
+		})
 	})
 
 	if err != nil {
@@ -39,18 +57,26 @@ func LoginEndpoint(c echo.Context, req LoginUserRequest) error {
 	config := middleware.GetConfig(c)
 	db := middleware.GetDB(c)
 	minioClient := middleware.GetMinioClient(c)
+	riverClient := middleware.GetRiverClient(c)
 
 	user, err := loginUser(LoginUserServiceRequest{
 		Params: LoginUserParams{
 			Email:    req.Data.Attributes.Email,
 			Password: req.Data.Attributes.Password,
+			Client:   getSessionClient(c),
 		},
-		Tx:          db,
+		Tx:          db.WithContext(c.Request().Context()),
 		Config:      config,
 		MinioClient: minioClient,
+		RiverClient: riverClient,
 	})
 
 	if err != nil {
+		// The same error is returned for throttled accounts and IPs, so it doesn't reveal whether the email exists.
+		// Single sign-on is only required once the password was correct, so it doesn't reveal anything either.
+		if errors.Is(err, api.ErrTooManyLoginAttempts) || errors.Is(err, api.ErrSsoRequired) {
+			return err // Middleware will handle the error response
+		}
 		return api.ErrUnauthorizedInvalidLogin // Middleware will handle the error response
 	}
 
@@ -79,77 +105,153 @@ func GetAuthenticatedUserEndpoint(c echo.Context) error {
 	return c.JSON(http.StatusOK, mapUserToResponse(user))
 }
 
-func CreateAuthenticatedUserTokenEndpoint(c echo.Context, req CreateAuthenticatedUserTokenRequest) error {
-	userId, err := middleware.GetUserIDFromJWT(c)
+func CreateUserDataExportEndpoint(c echo.Context) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
 	if err != nil {
-		return err
+		return err // Middleware will handle the error response
 	}
 
+	// The archive is emailed to the user, an admin impersonating them shouldn't be able to send it
 	impersonatingUserId, _ := middleware.GetImpersonatingUserIDFromJWT(c)
-	var impersonatingUserIdPtr *uuid.UUID
 	if impersonatingUserId != uuid.Nil {
-		impersonatingUserIdPtr = &impersonatingUserId
+		return api.ErrForbiddenImpersonationNotAllowed
 	}
 
-	if req.Data.Relationships.ImpersonatedUser != nil {
-		if impersonatingUserIdPtr != nil {
-			// if the impersonating user id is not nil, then the user is already impersonating someone
-			return api.ErrForbiddenImpersonationNotAllowed
-		}
+	db := middleware.GetDB(c)
+	riverClient := middleware.GetRiverClient(c)
+
+	var export *models.UserDataExport
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		export, err = createUserDataExport(CreateUserDataExportServiceRequest{
+			UserID:      userID,
+			Tx:          tx,
+			RiverClient: riverClient,
+		})
+		return err
+	})
 
-		if err := access.HasAdminAccess(c, []constants.UserScope{constants.UserScopeAdminUsersImpersonate}); err != nil {
-			return err
-		}
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
 
-		// set the user id to the impersonated user id
-		parsedImpersonatingUserId, err := api.ParseUserIDFromString(req.Data.Relationships.ImpersonatedUser.Data.Id)
+	return c.JSON(http.StatusAccepted, mapUserDataExportToResponse(export))
+}
 
-		if err != nil {
-			return err
-		}
+func GetUserDataExportEndpoint(c echo.Context) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+
+	export, err := getUserDataExport(GetUserDataExportServiceRequest{
+		UserID: userID,
+		Tx:     db,
+	})
 
-		actualUserId := userId
-		userId = parsedImpersonatingUserId
-		impersonatingUserIdPtr = &actualUserId
+	if err != nil {
+		return err // Middleware will handle the error response
 	}
 
-	if req.Data.Meta.StopImpersonating {
-		if impersonatingUserIdPtr == nil {
-			// if the impersonating user id is nil, then the user is not impersonating anyone
-			return api.ErrForbiddenImpersonationNotAllowed
-		}
+	return c.JSON(http.StatusOK, mapUserDataExportToResponse(export))
+}
 
-		userId = *impersonatingUserIdPtr
-		impersonatingUserIdPtr = nil
+// SendEmailVerificationEndpoint resends the verification email for the authenticated user's pending or unverified email
+func SendEmailVerificationEndpoint(c echo.Context) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
 	}
 
-	var organizationId *uuid.UUID
-	if req.Data.Relationships.Organization != nil {
-		parsedOrgID, err := api.ParseOrganizationIDFromString(req.Data.Relationships.Organization.Data.Id)
-		if err != nil {
-			return err
-		}
-		organizationId = &parsedOrgID
+	db := middleware.GetDB(c)
+	riverClient := middleware.GetRiverClient(c)
+
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		return sendEmailVerification(SendEmailVerificationServiceRequest{
+			UserID:      userID,
+			Tx:          tx,
+			RiverClient: riverClient,
+		})
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+func ConfirmEmailVerificationEndpoint(c echo.Context, req ConfirmEmailVerificationRequest) error {
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+	riverClient := middleware.GetRiverClient(c)
+
+	err := db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		return confirmEmailVerification(ConfirmEmailVerificationServiceRequest{
+			Params: ConfirmEmailVerificationParams{
+				Token: req.Data.Attributes.Token,
+			},
+			Tx:          tx,
+			Config:      config,
+			RiverClient: riverClient,
+		})
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
 	}
 
-	tx := middleware.GetDB(c)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RequestPasswordResetEndpoint always responds with 202, so it can't be used to find out which emails are registered
+func RequestPasswordResetEndpoint(c echo.Context, req RequestPasswordResetRequest) error {
+	db := middleware.GetDB(c)
 	config := middleware.GetConfig(c)
+	riverClient := middleware.GetRiverClient(c)
 
-	token, err := createAuthenticatedUserToken(CreateAuthenticatedUserTokenServiceRequest{
-		Params: CreateAuthenticatedUserTokenParams{
-			UserId:              userId,
-			OrganizationId:      organizationId,
-			ImpersonatingUserId: impersonatingUserIdPtr,
-		},
-		Tx:     tx,
-		Config: config,
+	err := db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		return requestPasswordReset(RequestPasswordResetServiceRequest{
+			Params: RequestPasswordResetParams{
+				Email:     req.Data.Attributes.Email,
+				IpAddress: c.RealIP(),
+			},
+			Tx:          tx,
+			Config:      config,
+			RiverClient: riverClient,
+		})
 	})
 
 	if err != nil {
-		return err
+		return err // Middleware will handle the error response
 	}
 
-	return c.JSON(http.StatusOK, mapCreateAuthenticatedUserTokenToResponse(req, token))
+	return c.NoContent(http.StatusAccepted)
+}
+
+func ConfirmPasswordResetEndpoint(c echo.Context, req ConfirmPasswordResetRequest) error {
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+	riverClient := middleware.GetRiverClient(c)
+
+	err := db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		return confirmPasswordReset(ConfirmPasswordResetServiceRequest{
+			Params: ConfirmPasswordResetParams{
+				Token:    req.Data.Attributes.Token,
+				Password: req.Data.Attributes.Password,
+			},
+			Tx:          tx,
+			Config:      config,
+			RiverClient: riverClient,
+		})
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.NoContent(http.StatusNoContent)
 }
 
 func UpdateUserEndpoint(c echo.Context, req UpdateUserRequest) error {
@@ -169,23 +271,62 @@ func UpdateUserEndpoint(c echo.Context, req UpdateUserRequest) error {
 		return api.ErrForbiddenOwnProfileOnly // Middleware will handle the error response
 	}
 
+	sessionID, _ := middleware.GetSessionIDFromJWT(c)
+	impersonatingUserID, _ := middleware.GetImpersonatingUserIDFromJWT(c)
+
+	var organizationID *uuid.UUID
+	if parsedOrganizationID, err := middleware.GetOrganizationIDFromJWT(c); err == nil && parsedOrganizationID != uuid.Nil {
+		organizationID = &parsedOrganizationID
+	}
+
 	db := middleware.GetDB(c)
 	minioClient := middleware.GetMinioClient(c)
 	config := middleware.GetConfig(c)
+	riverClient := middleware.GetRiverClient(c)
+	client := getSessionClient(c)
+
+	var user *UserDto
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		user, err = updateUser(UpdateUserServiceRequest{
+			Params: UpdateUserParams{
+				UserID:          userID,
+				Name:            req.Data.Attributes.Name,
+				Email:           req.Data.Attributes.Email,
+				Password:        req.Data.Attributes.Password,
+				Logo:            req.Data.Attributes.Logo,
+				CurrentPassword: req.Data.Attributes.CurrentPassword,
+				SessionID:       sessionID,
+				OrganizationID:  organizationID,
+				IsImpersonating: impersonatingUserID != uuid.Nil,
+				Client:          client,
+			},
+			Tx:          tx,
+			MinioClient: minioClient,
+			Config:      config,
+			RiverClient: riverClient,
+		})
+		if err != nil {
+			return err
+		}
 
-	user, err := updateUser(UpdateUserServiceRequest{
-		Params: UpdateUserParams{
-			UserID:   userID,
-			Name:     req.Data.Attributes.Name,
-			Email:    req.Data.Attributes.Email,
-			Password: req.Data.Attributes.Password,
-			Logo:     req.Data.Attributes.Logo,
-		},
-		Tx:          db,
-		MinioClient: minioClient,
-		Config:      config,
+		// The new email only replaces the current one after it is verified
+		if req.Data.Attributes.Email == "" || user.User.PendingEmail != req.Data.Attributes.Email {
+			return nil
+		}
+
+		return sendEmailVerification(SendEmailVerificationServiceRequest{
+			UserID:      userID,
+			Tx:          tx,
+			RiverClient: riverClient,
+		})
 	})
 
+	recordErr := recordInvalidCurrentPassword(c, err, userID)
+	if recordErr != nil {
+		return recordErr
+	}
+
 	if err != nil {
 		slog.Error("Error updating user", "error", err, "userID", userID)
 		return err // Middleware will handle the error response
@@ -241,6 +382,7 @@ func GetUsersEndpoint(c echo.Context, query GetUsersQuery) error {
 					LastIssuedAt: userDto.User.Revocation.LastValidIssuedAt,
 					CanRefresh:   userDto.User.Revocation.CanRefresh,
 				},
+				MfaEnabled: userDto.User.Mfa.Enabled,
 			},
 		})
 	}
@@ -255,27 +397,228 @@ func GetUsersEndpoint(c echo.Context, query GetUsersQuery) error {
 	})
 }
 
-func GoogleOAuthCallbackEndpoint(c echo.Context, req GoogleOAuthCallbackRequest) error {
+func GetAccessTokensEndpoint(c echo.Context) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+
+	accessTokens, err := getAccessTokens(GetAccessTokensServiceRequest{
+		UserID: userID,
+		Tx:     db,
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusOK, mapAccessTokensToResponse(accessTokens))
+}
+
+func CreateAccessTokenEndpoint(c echo.Context, req CreateAccessTokenRequest) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	var organizationID *uuid.UUID
+	if req.Data.Relationships.Organization != nil {
+		parsedOrgID, err := api.ParseOrganizationIDFromString(req.Data.Relationships.Organization.Data.Id)
+		if err != nil {
+			return err // Middleware will handle the error response
+		}
+		organizationID = &parsedOrgID
+	}
+
 	db := middleware.GetDB(c)
 	config := middleware.GetConfig(c)
-	minioClient := middleware.GetMinioClient(c)
-	posthogClient := middleware.GetPostHogClient(c)
+	riverClient := middleware.GetRiverClient(c)
+
+	var accessToken *AccessTokenDto
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		accessToken, err = createAccessToken(CreateAccessTokenServiceRequest{
+			Params: CreateAccessTokenParams{
+				UserID:           userID,
+				Name:             req.Data.Attributes.Name,
+				Scopes:           req.Data.Attributes.Scopes,
+				OrganizationID:   organizationID,
+				ExpiresAt:        req.Data.Attributes.ExpiresAt,
+				Reauthentication: getReauthenticationParams(c, req.Data.Attributes.CurrentPassword),
+			},
+			Tx:          tx,
+			Config:      config,
+			RiverClient: riverClient,
+		})
+		return err
+	})
 
-	user, err := googleOAuthCallback(GoogleOAuthCallbackServiceRequest{
-		Params: GoogleOAuthCallbackParams{
-			Code:        req.Data.Attributes.Code,
-			State:       req.Data.Attributes.State,
-			RedirectUri: req.Data.Attributes.RedirectUri,
-		},
+	recordErr := recordInvalidCurrentPassword(c, err, userID)
+	if recordErr != nil {
+		return recordErr
+	}
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusCreated, mapAccessTokenDtoToResponse(accessToken))
+}
+
+func DeleteAccessTokenEndpoint(c echo.Context) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	accessTokenID, err := api.ParseAccessTokenIDFromParams(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+
+	err = deleteAccessToken(DeleteAccessTokenServiceRequest{
+		UserID:        userID,
+		AccessTokenID: accessTokenID,
 		Tx:            db,
-		Config:        config,
-		MinioClient:   minioClient,
-		PostHogClient: posthogClient,
 	})
 
 	if err != nil {
 		return err // Middleware will handle the error response
 	}
 
-	return c.JSON(http.StatusOK, mapUserToResponse(user))
+	return c.NoContent(http.StatusNoContent)
+}
+
+func SetInitialPasswordEndpoint(c echo.Context, req SetInitialPasswordRequest) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+	riverClient := middleware.GetRiverClient(c)
+
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		return setInitialPassword(SetInitialPasswordServiceRequest{
+			Params: SetInitialPasswordParams{
+				UserID:           userID,
+				Password:         req.Data.Attributes.Password,
+				Reauthentication: getReauthenticationParams(c, ""),
+			},
+			Tx:          tx,
+			Config:      config,
+			RiverClient: riverClient,
+		})
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func GetImpersonationsEndpoint(c echo.Context, query GetImpersonationsQuery) error {
+	if err := access.HasAdminAccess(c, []constants.UserScope{constants.UserScopeAdminImpersonationsList}); err != nil {
+		return err
+	}
+
+	var userID *uuid.UUID
+	if query.UserId != "" {
+		parsedUserID, err := api.ParseUserIDFromString(query.UserId)
+		if err != nil {
+			return err
+		}
+		userID = &parsedUserID
+	}
+
+	db := middleware.GetDB(c)
+
+	impersonations, err := getImpersonations(GetImpersonationsServiceRequest{
+		UserID: userID,
+		Tx:     db,
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusOK, mapImpersonationsToResponse(impersonations))
+}
+
+func GetLoginLockoutsEndpoint(c echo.Context) error {
+	if err := access.HasAdminAccess(c, []constants.UserScope{constants.UserScopeAdminLoginLockoutsList}); err != nil {
+		return err
+	}
+
+	db := middleware.GetDB(c)
+
+	lockouts, err := getLoginLockouts(GetLoginLockoutsServiceRequest{
+		Tx: db,
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusOK, mapLoginLockoutsToResponse(lockouts))
+}
+
+func DeleteLoginLockoutEndpoint(c echo.Context) error {
+	if err := access.HasAdminAccess(c, []constants.UserScope{constants.UserScopeAdminLoginLockoutsDelete}); err != nil {
+		return err
+	}
+
+	lockoutID, err := api.ParseLoginLockoutIDFromParams(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+
+	err = deleteLoginLockout(DeleteLoginLockoutServiceRequest{
+		LockoutID: lockoutID,
+		Tx:        db,
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// getReauthenticationParams returns what's needed to check that the user making the request is the one changing how
+// they sign in
+func getReauthenticationParams(c echo.Context, currentPassword string) ReauthenticationParams {
+	sessionID, _ := middleware.GetSessionIDFromJWT(c)
+	impersonatingUserID, _ := middleware.GetImpersonatingUserIDFromJWT(c)
+
+	return ReauthenticationParams{
+		CurrentPassword: currentPassword,
+		SessionID:       sessionID,
+		IpAddress:       c.RealIP(),
+		IsImpersonating: impersonatingUserID != uuid.Nil,
+	}
+}
+
+// recordInvalidCurrentPassword counts a wrong current password as a failed login. It's saved outside of the request's
+// transaction, since that is rolled back.
+func recordInvalidCurrentPassword(c echo.Context, err error, userID uuid.UUID) error {
+	if !errors.Is(err, api.ErrCurrentPasswordInvalid) {
+		return nil
+	}
+
+	return recordFailedReauthentication(RecordFailedReauthenticationServiceRequest{
+		UserID:      userID,
+		IpAddress:   c.RealIP(),
+		Tx:          middleware.GetDB(c).WithContext(c.Request().Context()),
+		Config:      middleware.GetConfig(c),
+		RiverClient: middleware.GetRiverClient(c),
+	})
 }
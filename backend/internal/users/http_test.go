@@ -2,13 +2,21 @@ package users_test
 
 import (
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"reece.start/internal/api"
+	"reece.start/internal/authentication"
 	"reece.start/internal/constants"
 	"reece.start/internal/models"
 	"reece.start/test"
+	"reece.start/test/mocks"
 )
 
 func TestCreateUserEndpoint(t *testing.T) {
@@ -47,6 +55,35 @@ func TestCreateUserEndpoint(t *testing.T) {
 		assert.NotEmpty(t, meta["token"])
 	})
 
+	t.Run("PasswordPolicyViolation", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+
+		reqBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"type": constants.ApiTypeUser,
+				"attributes": map[string]interface{}{
+					"name":     "Test User",
+					"email":    "test@example.com",
+					"password": "short",
+				},
+			},
+		}
+
+		rec := tc.MakeRequest(http.MethodPost, "/users", reqBody, nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		var apiErr api.ApiError
+		tc.UnmarshalResponse(rec, &apiErr)
+		assert.Equal(t, api.ErrorCodePasswordPolicyViolation, apiErr.Code)
+		require.Len(t, apiErr.Errors, 1)
+		assert.Equal(t, "password", apiErr.Errors[0].Field)
+		assert.Equal(t, authentication.PasswordRuleMinLength, apiErr.Errors[0].Rule)
+
+		var count int64
+		tc.DB.Model(&models.User{}).Where("email = ?", "test@example.com").Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
 	t.Run("DuplicateEmail", func(t *testing.T) {
 		// Setup test context
 		tc := test.SetupEchoTest(t)
@@ -196,3 +233,1918 @@ func TestUpdateUserEndpoint(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "Updated Name", updatedUser.Name)
 }
+
+func TestRefreshAuthenticatedUserTokenEndpoint(t *testing.T) {
+	t.Run("RevokedTokenCanBeRefreshed", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, token := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+
+		// Revoke every token issued before a point in the future
+		revokedAt := time.Now().Add(time.Minute)
+		err := tc.DB.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]any{
+			"revocation_can_refresh":          true,
+			"revocation_last_valid_issued_at": revokedAt,
+		}).Error
+		require.NoError(t, err)
+
+		// The revoked token is rejected by regular endpoints
+		rec := tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me", nil, token)
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		var apiErr api.ApiError
+		tc.UnmarshalResponse(rec, &apiErr)
+		assert.Equal(t, api.ErrorCodeTokenRefreshRequired, apiErr.Code)
+
+		// But it can still be exchanged for a new token
+		rec = tc.MakeAuthenticatedRequest(http.MethodPost, "/users/me/token/refresh", nil, token)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response map[string]interface{}
+		tc.UnmarshalResponse(rec, &response)
+
+		data := response["data"].(map[string]interface{})
+		meta := data["meta"].(map[string]interface{})
+		assert.Equal(t, string(constants.ApiTypeToken), data["type"])
+		assert.NotEmpty(t, meta["token"])
+	})
+
+	t.Run("RevokedTokenCannotBeRefreshed", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, token := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+
+		revokedAt := time.Now().Add(time.Minute)
+		err := tc.DB.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]any{
+			"revocation_can_refresh":          false,
+			"revocation_last_valid_issued_at": revokedAt,
+		}).Error
+		require.NoError(t, err)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodPost, "/users/me/token/refresh", nil, token)
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		var apiErr api.ApiError
+		tc.UnmarshalResponse(rec, &apiErr)
+		assert.Equal(t, api.ErrorCodeReauthenticationRequired, apiErr.Code)
+	})
+
+	t.Run("ValidToken", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, _, token := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodPost, "/users/me/token/refresh", nil, token)
+		require.Equal(t, http.StatusOK, rec.Code)
+	})
+}
+
+func TestRotateRefreshTokenEndpoint(t *testing.T) {
+	t.Run("LoginReturnsRotatableRefreshToken", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, password, _ := test.CreateTestUser(t, tc)
+
+		reqBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"type": constants.ApiTypeUser,
+				"attributes": map[string]interface{}{
+					"email":    user.Email,
+					"password": password,
+				},
+			},
+		}
+		rec := tc.MakeRequest(http.MethodPost, "/users/login", reqBody, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var loginResponse map[string]interface{}
+		tc.UnmarshalResponse(rec, &loginResponse)
+		loginMeta := loginResponse["data"].(map[string]interface{})["meta"].(map[string]interface{})
+		refreshToken := loginMeta["refreshToken"].(string)
+		require.NotEmpty(t, refreshToken)
+
+		rotateBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"type": constants.ApiTypeToken,
+				"attributes": map[string]interface{}{
+					"refreshToken": refreshToken,
+				},
+			},
+		}
+		rec = tc.MakeRequest(http.MethodPost, "/users/token/rotate", rotateBody, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var rotateResponse map[string]interface{}
+		tc.UnmarshalResponse(rec, &rotateResponse)
+		rotateMeta := rotateResponse["data"].(map[string]interface{})["meta"].(map[string]interface{})
+		assert.NotEmpty(t, rotateMeta["token"])
+		assert.NotEmpty(t, rotateMeta["refreshToken"])
+		assert.NotEqual(t, refreshToken, rotateMeta["refreshToken"])
+
+		// The old refresh token can't be used again
+		rec = tc.MakeRequest(http.MethodPost, "/users/token/rotate", rotateBody, nil)
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		var apiErr api.ApiError
+		tc.UnmarshalResponse(rec, &apiErr)
+		assert.Equal(t, api.ErrorCodeReauthenticationRequired, apiErr.Code)
+	})
+}
+
+func startOAuthAuthorization(tc *test.TestContext, provider string, redirectUri string) (int, map[string]interface{}) {
+	reqBody := map[string]interface{}{
+		"data": map[string]interface{}{
+			"attributes": map[string]interface{}{
+				"redirectUri": redirectUri,
+			},
+		},
+	}
+
+	rec := tc.MakeRequest(http.MethodPost, "/oauth/"+provider+"/authorize", reqBody, nil)
+
+	var response map[string]interface{}
+	tc.UnmarshalResponse(rec, &response)
+	return rec.Code, response
+}
+
+func oauthCallback(tc *test.TestContext, provider string, code string, state string, redirectUri string) (int, map[string]interface{}) {
+	reqBody := map[string]interface{}{
+		"data": map[string]interface{}{
+			"attributes": map[string]interface{}{
+				"code":        code,
+				"state":       state,
+				"redirectUri": redirectUri,
+			},
+		},
+	}
+
+	rec := tc.MakeRequest(http.MethodPost, "/oauth/"+provider+"/callback", reqBody, nil)
+
+	var response map[string]interface{}
+	tc.UnmarshalResponse(rec, &response)
+	return rec.Code, response
+}
+
+// oidcSignIn goes through the whole OAuth flow against the stub OIDC server, signing in as the given user
+func oidcSignIn(t *testing.T, tc *test.TestContext, server *mocks.OIDCServer, user mocks.OIDCUser) (int, map[string]interface{}) {
+	redirectUri := "http://localhost:3000/oauth/oidc/callback"
+
+	code, response := startOAuthAuthorization(tc, "oidc", redirectUri)
+	require.Equal(t, http.StatusCreated, code)
+
+	attributes := response["data"].(map[string]interface{})["attributes"].(map[string]interface{})
+	authorizationCode := server.IssueCode(user, attributes["authorizationUrl"].(string))
+
+	return oauthCallback(tc, "oidc", authorizationCode, attributes["state"].(string), redirectUri)
+}
+
+func TestStartOAuthAuthorizationEndpoint(t *testing.T) {
+	t.Run("ReturnsAuthorizationUrlWithPKCE", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		server := mocks.NewOIDCServer(t)
+		server.Configure(tc.Config)
+
+		code, response := startOAuthAuthorization(tc, "oidc", "http://localhost:3000/oauth/oidc/callback")
+		require.Equal(t, http.StatusCreated, code)
+
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, string(constants.ApiTypeOAuthAuthorization), data["type"])
+
+		attributes := data["attributes"].(map[string]interface{})
+		state := attributes["state"].(string)
+		authorizationUrl := attributes["authorizationUrl"].(string)
+		assert.True(t, strings.HasPrefix(authorizationUrl, server.URL+"/authorize?"))
+		assert.Contains(t, authorizationUrl, "state="+state)
+		assert.Contains(t, authorizationUrl, "code_challenge_method=S256")
+		assert.NotEmpty(t, attributes["expiresAt"])
+
+		// Only a hash of the state is stored
+		var oauthState models.OAuthState
+		require.NoError(t, tc.DB.Where("provider = ?", "oidc").First(&oauthState).Error)
+		assert.NotEqual(t, state, oauthState.StateHash)
+	})
+
+	t.Run("DisallowedRedirectUri", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		server := mocks.NewOIDCServer(t)
+		server.Configure(tc.Config)
+
+		code, _ := startOAuthAuthorization(tc, "oidc", "https://attacker.example.com/oauth/oidc/callback")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("UnknownProvider", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+
+		code, _ := startOAuthAuthorization(tc, "unknown", "http://localhost:3000/oauth/oidc/callback")
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+}
+
+func TestOAuthCallbackEndpoint(t *testing.T) {
+	t.Run("CreatesUserWithIdentity", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		server := mocks.NewOIDCServer(t)
+		server.Configure(tc.Config)
+
+		code, response := oidcSignIn(t, tc, server, mocks.OIDCUser{
+			Subject:       "new-subject",
+			Email:         "oidc-new@example.com",
+			EmailVerified: true,
+			Name:          "OIDC User",
+			Picture:       "https://example.com/picture.png",
+		})
+		require.Equal(t, http.StatusOK, code)
+
+		meta := response["data"].(map[string]interface{})["meta"].(map[string]interface{})
+		assert.NotEmpty(t, meta["token"])
+		assert.Equal(t, "https://example.com/picture.png", meta["logoDistributionUrl"])
+
+		var identity models.UserIdentity
+		require.NoError(t, tc.DB.Where("provider = ? AND subject = ?", "oidc", "new-subject").First(&identity).Error)
+
+		var user models.User
+		require.NoError(t, tc.DB.First(&user, identity.UserID).Error)
+		assert.Equal(t, "oidc-new@example.com", user.Email)
+		assert.NotNil(t, user.EmailVerifiedAt)
+
+		// Signing in again uses the same user
+		code, _ = oidcSignIn(t, tc, server, mocks.OIDCUser{Subject: "new-subject", Email: "oidc-new@example.com"})
+		require.Equal(t, http.StatusOK, code)
+
+		var userCount int64
+		require.NoError(t, tc.DB.Model(&models.User{}).Where("email = ?", "oidc-new@example.com").Count(&userCount).Error)
+		assert.Equal(t, int64(1), userCount)
+	})
+
+	t.Run("LinksVerifiedEmailToExistingUser", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		server := mocks.NewOIDCServer(t)
+		server.Configure(tc.Config)
+		user, _, _ := test.CreateTestUser(t, tc)
+
+		code, _ := oidcSignIn(t, tc, server, mocks.OIDCUser{Subject: "linked-subject", Email: user.Email, EmailVerified: true})
+		require.Equal(t, http.StatusOK, code)
+
+		var identity models.UserIdentity
+		require.NoError(t, tc.DB.Where("provider = ? AND subject = ?", "oidc", "linked-subject").First(&identity).Error)
+		assert.Equal(t, user.ID, identity.UserID)
+	})
+
+	t.Run("RequiresMfaWhenEnabled", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		server := mocks.NewOIDCServer(t)
+		server.Configure(tc.Config)
+		user, _, _ := test.CreateTestUser(t, tc)
+		require.NoError(t, tc.DB.Model(user).Update("mfa_enabled", true).Error)
+
+		code, response := oidcSignIn(t, tc, server, mocks.OIDCUser{Subject: "mfa-subject", Email: user.Email, EmailVerified: true})
+		require.Equal(t, http.StatusOK, code)
+
+		meta := response["data"].(map[string]interface{})["meta"].(map[string]interface{})
+		assert.Empty(t, meta["token"])
+		assert.Equal(t, true, meta["mfaRequired"])
+		assert.NotEmpty(t, meta["mfaChallengeToken"])
+	})
+
+	t.Run("DoesNotLinkUnverifiedEmail", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		server := mocks.NewOIDCServer(t)
+		server.Configure(tc.Config)
+		user, _, _ := test.CreateTestUser(t, tc)
+
+		code, _ := oidcSignIn(t, tc, server, mocks.OIDCUser{Subject: "unverified-subject", Email: user.Email})
+		assert.Equal(t, http.StatusForbidden, code)
+
+		var identityCount int64
+		require.NoError(t, tc.DB.Model(&models.UserIdentity{}).Where("user_id = ?", user.ID).Count(&identityCount).Error)
+		assert.Equal(t, int64(0), identityCount)
+	})
+
+	t.Run("InvalidCode", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		server := mocks.NewOIDCServer(t)
+		server.Configure(tc.Config)
+
+		redirectUri := "http://localhost:3000/oauth/oidc/callback"
+		code, response := startOAuthAuthorization(tc, "oidc", redirectUri)
+		require.Equal(t, http.StatusCreated, code)
+		state := response["data"].(map[string]interface{})["attributes"].(map[string]interface{})["state"].(string)
+
+		code, _ = oauthCallback(tc, "oidc", "not-a-real-code", state, redirectUri)
+		assert.Equal(t, http.StatusUnauthorized, code)
+	})
+
+	t.Run("UnknownState", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		server := mocks.NewOIDCServer(t)
+		server.Configure(tc.Config)
+
+		code, _ := oauthCallback(tc, "oidc", "code", "not-a-real-state", "http://localhost:3000/oauth/oidc/callback")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("StateCannotBeReused", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		server := mocks.NewOIDCServer(t)
+		server.Configure(tc.Config)
+		redirectUri := "http://localhost:3000/oauth/oidc/callback"
+
+		code, response := startOAuthAuthorization(tc, "oidc", redirectUri)
+		require.Equal(t, http.StatusCreated, code)
+		attributes := response["data"].(map[string]interface{})["attributes"].(map[string]interface{})
+		authorizationUrl := attributes["authorizationUrl"].(string)
+		state := attributes["state"].(string)
+
+		user := mocks.OIDCUser{Subject: "reused-subject", Email: "oidc-reused@example.com", EmailVerified: true}
+		code, _ = oauthCallback(tc, "oidc", server.IssueCode(user, authorizationUrl), state, redirectUri)
+		require.Equal(t, http.StatusOK, code)
+
+		code, _ = oauthCallback(tc, "oidc", server.IssueCode(user, authorizationUrl), state, redirectUri)
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("StateForAnotherRedirectUri", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		server := mocks.NewOIDCServer(t)
+		server.Configure(tc.Config)
+
+		code, response := startOAuthAuthorization(tc, "oidc", "http://localhost:3000/oauth/oidc/callback")
+		require.Equal(t, http.StatusCreated, code)
+		attributes := response["data"].(map[string]interface{})["attributes"].(map[string]interface{})
+		authorizationCode := server.IssueCode(mocks.OIDCUser{Subject: "subject"}, attributes["authorizationUrl"].(string))
+
+		code, _ = oauthCallback(tc, "oidc", authorizationCode, attributes["state"].(string), "http://localhost:3000/oauth/google/callback")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("ExpiredState", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		server := mocks.NewOIDCServer(t)
+		server.Configure(tc.Config)
+		redirectUri := "http://localhost:3000/oauth/oidc/callback"
+
+		code, response := startOAuthAuthorization(tc, "oidc", redirectUri)
+		require.Equal(t, http.StatusCreated, code)
+		attributes := response["data"].(map[string]interface{})["attributes"].(map[string]interface{})
+		authorizationCode := server.IssueCode(mocks.OIDCUser{Subject: "subject"}, attributes["authorizationUrl"].(string))
+
+		require.NoError(t, tc.DB.Model(&models.OAuthState{}).Where("provider = ?", "oidc").Update("expires_at", time.Now().Add(-time.Minute)).Error)
+
+		code, _ = oauthCallback(tc, "oidc", authorizationCode, attributes["state"].(string), redirectUri)
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("UnknownProvider", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+
+		code, _ := oauthCallback(tc, "unknown", "code", "state", "http://localhost:3000/oauth/unknown/callback")
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+}
+
+// linkOidcIdentity goes through the OAuth flow against the stub OIDC server, linking the given provider user to the
+// signed in user
+func linkOidcIdentity(t *testing.T, tc *test.TestContext, server *mocks.OIDCServer, token string, user mocks.OIDCUser) (int, map[string]interface{}) {
+	redirectUri := "http://localhost:3000/oauth/oidc/callback"
+
+	code, response := startOAuthAuthorization(tc, "oidc", redirectUri)
+	require.Equal(t, http.StatusCreated, code)
+
+	attributes := response["data"].(map[string]interface{})["attributes"].(map[string]interface{})
+	reqBody := map[string]interface{}{
+		"data": map[string]interface{}{
+			"attributes": map[string]interface{}{
+				"code":        server.IssueCode(user, attributes["authorizationUrl"].(string)),
+				"state":       attributes["state"],
+				"redirectUri": redirectUri,
+			},
+		},
+	}
+
+	rec := tc.MakeAuthenticatedRequest(http.MethodPost, "/users/me/identities/oidc", reqBody, token)
+
+	var linkResponse map[string]interface{}
+	tc.UnmarshalResponse(rec, &linkResponse)
+	return rec.Code, linkResponse
+}
+
+func TestUserIdentityEndpoints(t *testing.T) {
+	t.Run("LinksListsAndUnlinksIdentity", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		server := mocks.NewOIDCServer(t)
+		server.Configure(tc.Config)
+		user, _, token := test.CreateTestUser(t, tc)
+
+		// The provider account doesn't need to have the same email as the user
+		code, response := linkOidcIdentity(t, tc, server, token, mocks.OIDCUser{Subject: "link-subject", Email: "someone-else@example.com"})
+		require.Equal(t, http.StatusCreated, code)
+
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, string(constants.ApiTypeUserIdentity), data["type"])
+		assert.Equal(t, "oidc", data["attributes"].(map[string]interface{})["provider"])
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me/identities", nil, token)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var listResponse map[string]interface{}
+		tc.UnmarshalResponse(rec, &listResponse)
+		require.Len(t, listResponse["data"], 1)
+
+		// The user has a password, so they can unlink the identity
+		rec = tc.MakeAuthenticatedRequest(http.MethodDelete, "/users/me/identities/"+data["id"].(string), nil, token)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		var identityCount int64
+		require.NoError(t, tc.DB.Model(&models.UserIdentity{}).Where("user_id = ?", user.ID).Count(&identityCount).Error)
+		assert.Equal(t, int64(0), identityCount)
+	})
+
+	t.Run("IdentityLinkedToAnotherUser", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		server := mocks.NewOIDCServer(t)
+		server.Configure(tc.Config)
+		_, _, token := test.CreateTestUser(t, tc)
+		_, _, otherToken := test.CreateTestUser(t, tc)
+
+		code, _ := linkOidcIdentity(t, tc, server, otherToken, mocks.OIDCUser{Subject: "taken-subject"})
+		require.Equal(t, http.StatusCreated, code)
+
+		code, _ = linkOidcIdentity(t, tc, server, token, mocks.OIDCUser{Subject: "taken-subject"})
+		assert.Equal(t, http.StatusConflict, code)
+	})
+
+	t.Run("SetsInitialPasswordForOAuthUser", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		server := mocks.NewOIDCServer(t)
+		server.Configure(tc.Config)
+
+		code, response := oidcSignIn(t, tc, server, mocks.OIDCUser{Subject: "password-subject", Email: "oidc-password@example.com", EmailVerified: true})
+		require.Equal(t, http.StatusOK, code)
+
+		data := response["data"].(map[string]interface{})
+		token := data["meta"].(map[string]interface{})["token"].(string)
+		assert.Nil(t, data["meta"].(map[string]interface{})["hasPassword"])
+
+		reqBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"attributes": map[string]interface{}{
+					"password": "password123",
+				},
+			},
+		}
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodPost, "/users/me/password", reqBody, token)
+		require.Equal(t, http.StatusNoContent, rec.Code)
+
+		// The password can be used to sign in
+		loginBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"attributes": map[string]interface{}{
+					"email":    "oidc-password@example.com",
+					"password": "password123",
+				},
+			},
+		}
+		rec = tc.MakeRequest(http.MethodPost, "/users/login", loginBody, nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		// It can't be used to change an existing password
+		rec = tc.MakeAuthenticatedRequest(http.MethodPost, "/users/me/password", reqBody, token)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+}
+
+func login(tc *test.TestContext, email string, password string) *httptest.ResponseRecorder {
+	reqBody := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type": constants.ApiTypeUser,
+			"attributes": map[string]interface{}{
+				"email":    email,
+				"password": password,
+			},
+		},
+	}
+
+	return tc.MakeRequest(http.MethodPost, "/users/login", reqBody, nil)
+}
+
+func TestLoginThrottling(t *testing.T) {
+	t.Run("LocksOutAccountAndEmailsUser", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, password, _ := test.CreateTestUser(t, tc)
+
+		for i := 0; i < tc.Config.LoginLockoutAccountThreshold; i++ {
+			rec := login(tc, user.Email, "wrong-password")
+			require.Equal(t, http.StatusUnauthorized, rec.Code)
+		}
+
+		// Even the right password is rejected while locked out
+		rec := login(tc, user.Email, password)
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+		var jobCount int64
+		err := tc.DB.Raw(`
+			SELECT COUNT(*)
+			FROM river_job
+			WHERE kind = ? AND args->>'userId' = ?
+		`, string(constants.JobKindLoginLockoutEmail), user.ID.String()).Scan(&jobCount).Error
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), jobCount)
+	})
+
+	t.Run("LockoutDoesNotRevealUnknownEmail", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, _ := test.CreateTestUser(t, tc)
+
+		for i := 0; i < tc.Config.LoginLockoutAccountThreshold; i++ {
+			login(tc, user.Email, "wrong-password")
+			login(tc, "nobody@example.com", "wrong-password")
+		}
+
+		knownRec := login(tc, user.Email, "wrong-password")
+		unknownRec := login(tc, "nobody@example.com", "wrong-password")
+		assert.Equal(t, http.StatusTooManyRequests, knownRec.Code)
+		assert.Equal(t, knownRec.Code, unknownRec.Code)
+		assert.Equal(t, knownRec.Body.String(), unknownRec.Body.String())
+
+		var jobCount int64
+		err := tc.DB.Raw(`SELECT COUNT(*) FROM river_job WHERE kind = ?`, string(constants.JobKindLoginLockoutEmail)).Scan(&jobCount).Error
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), jobCount)
+	})
+
+	t.Run("DelaysAttemptsAfterFreeAttempts", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		tc.Config.LoginThrottleMaxDelay = 30
+		user, password, _ := test.CreateTestUser(t, tc)
+
+		for i := 0; i <= tc.Config.LoginThrottleFreeAttempts; i++ {
+			rec := login(tc, user.Email, "wrong-password")
+			require.Equal(t, http.StatusUnauthorized, rec.Code)
+		}
+
+		rec := login(tc, user.Email, password)
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+		// Once the delay has passed the user can sign in, which clears the account's failed attempts
+		require.NoError(t, tc.DB.Model(&models.LoginThrottle{}).Where("scope = ?", "account").Update("next_attempt_at", time.Now().Add(-time.Second)).Error)
+
+		rec = login(tc, user.Email, password)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var throttleCount int64
+		require.NoError(t, tc.DB.Model(&models.LoginThrottle{}).Where("scope = ?", "account").Count(&throttleCount).Error)
+		assert.Equal(t, int64(0), throttleCount)
+	})
+
+	t.Run("AdminListsAndClearsLockouts", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		admin, adminPassword, _ := test.CreateTestUser(t, tc)
+		user, password, _ := test.CreateTestUser(t, tc)
+
+		// Scopes come from the role when the token is issued
+		require.NoError(t, tc.DB.Model(admin).Update("role", string(constants.UserRoleAdmin)).Error)
+		rec := login(tc, admin.Email, adminPassword)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var loginResponse map[string]interface{}
+		tc.UnmarshalResponse(rec, &loginResponse)
+		adminToken := loginResponse["data"].(map[string]interface{})["meta"].(map[string]interface{})["token"].(string)
+
+		for i := 0; i < tc.Config.LoginLockoutAccountThreshold; i++ {
+			login(tc, user.Email, "wrong-password")
+		}
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/login-lockouts", nil, adminToken)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var listResponse map[string]interface{}
+		tc.UnmarshalResponse(rec, &listResponse)
+		lockouts := listResponse["data"].([]interface{})
+		require.Len(t, lockouts, 1)
+
+		lockout := lockouts[0].(map[string]interface{})
+		assert.Equal(t, string(constants.ApiTypeLoginLockout), lockout["type"])
+		assert.Equal(t, "account", lockout["attributes"].(map[string]interface{})["scope"])
+		assert.Equal(t, strings.ToLower(user.Email), lockout["attributes"].(map[string]interface{})["target"])
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodDelete, "/login-lockouts/"+lockout["id"].(string), nil, adminToken)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = login(tc, user.Email, password)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("NonAdminCannotListLockouts", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, _, token := test.CreateTestUser(t, tc)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodGet, "/login-lockouts", nil, token)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
+
+func TestImpersonation(t *testing.T) {
+	// createAdmin signs in a new user with the admin role, since scopes come from the role when the token is issued
+	createAdmin := func(t *testing.T, tc *test.TestContext) (*models.User, string) {
+		admin, password, _ := test.CreateTestUser(t, tc)
+		require.NoError(t, tc.DB.Model(admin).Update("role", string(constants.UserRoleAdmin)).Error)
+		return admin, test.LoginTestUser(t, tc, admin.Email, password)
+	}
+
+	impersonate := func(tc *test.TestContext, token string, userID uuid.UUID, meta map[string]interface{}) *httptest.ResponseRecorder {
+		reqBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"type": constants.ApiTypeToken,
+				"relationships": map[string]interface{}{
+					"impersonatedUser": map[string]interface{}{
+						"data": map[string]interface{}{
+							"id":   userID.String(),
+							"type": constants.ApiTypeUser,
+						},
+					},
+				},
+				"meta": meta,
+			},
+		}
+		return tc.MakeAuthenticatedRequest(http.MethodPost, "/users/me/token", reqBody, token)
+	}
+
+	stopImpersonating := func(tc *test.TestContext, token string) *httptest.ResponseRecorder {
+		reqBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"type": constants.ApiTypeToken,
+				"meta": map[string]interface{}{"stopImpersonating": true},
+			},
+		}
+		return tc.MakeAuthenticatedRequest(http.MethodPost, "/users/me/token", reqBody, token)
+	}
+
+	getTokenMeta := func(tc *test.TestContext, rec *httptest.ResponseRecorder) map[string]interface{} {
+		var response map[string]interface{}
+		tc.UnmarshalResponse(rec, &response)
+		return response["data"].(map[string]interface{})["meta"].(map[string]interface{})
+	}
+
+	getCurrentUserID := func(t *testing.T, tc *test.TestContext, token string) string {
+		rec := tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me", nil, token)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response map[string]interface{}
+		tc.UnmarshalResponse(rec, &response)
+		return response["data"].(map[string]interface{})["id"].(string)
+	}
+
+	t.Run("ReasonRequired", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, adminToken := createAdmin(t, tc)
+		user, _, _ := test.CreateTestUser(t, tc)
+
+		rec := impersonate(tc, adminToken, user.ID, map[string]interface{}{"impersonationReason": "  "})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		var count int64
+		require.NoError(t, tc.DB.Model(&models.ImpersonationSession{}).Count(&count).Error)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("NonAdminCannotImpersonate", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, _, token := test.CreateTestUser(t, tc)
+		user, _, _ := test.CreateTestUser(t, tc)
+
+		rec := impersonate(tc, token, user.ID, map[string]interface{}{"impersonationReason": "Support ticket"})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("ImpersonationIsLoggedAndTimeBoxed", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		admin, adminToken := createAdmin(t, tc)
+		user, _, _ := test.CreateTestUser(t, tc)
+
+		rec := impersonate(tc, adminToken, user.ID, map[string]interface{}{"impersonationReason": "Support ticket 1234"})
+		require.Equal(t, http.StatusOK, rec.Code)
+		meta := getTokenMeta(tc, rec)
+		token := meta["token"].(string)
+		assert.NotEmpty(t, meta["impersonationExpiresAt"])
+
+		assert.Equal(t, user.ID.String(), getCurrentUserID(t, tc, token))
+
+		var impersonation models.ImpersonationSession
+		require.NoError(t, tc.DB.Where("impersonated_user_id = ?", user.ID).First(&impersonation).Error)
+		require.NotNil(t, impersonation.ImpersonatorUserID)
+		assert.Equal(t, admin.ID, *impersonation.ImpersonatorUserID)
+		assert.Equal(t, "Support ticket 1234", impersonation.Reason)
+		assert.False(t, impersonation.ReadOnly)
+		assert.False(t, impersonation.UserNotified)
+		assert.Nil(t, impersonation.EndedAt)
+		assert.WithinDuration(t, time.Now().Add(time.Duration(tc.Config.ImpersonationExpirationTime)*time.Second), impersonation.ExpiresAt, time.Minute)
+
+		// Neither the access token nor the refresh token outlive the impersonation
+		claims, err := authentication.ValidateJWT(tc.Config, token)
+		require.NoError(t, err)
+		assert.Equal(t, impersonation.ID.String(), *claims.ImpersonationSessionId)
+		assert.False(t, claims.ExpiresAt.After(impersonation.ExpiresAt.Add(time.Second)))
+
+		var refreshToken models.RefreshToken
+		require.NoError(t, tc.DB.Where("user_id = ? AND impersonation_session_id = ?", user.ID, impersonation.ID).First(&refreshToken).Error)
+		assert.WithinDuration(t, impersonation.ExpiresAt, refreshToken.ExpiresAt, time.Second)
+
+		rec = stopImpersonating(tc, token)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, admin.ID.String(), getCurrentUserID(t, tc, getTokenMeta(tc, rec)["token"].(string)))
+
+		require.NoError(t, tc.DB.First(&impersonation, impersonation.ID).Error)
+		assert.NotNil(t, impersonation.EndedAt)
+	})
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, adminToken := createAdmin(t, tc)
+		user, _, _ := test.CreateTestUser(t, tc)
+
+		rec := impersonate(tc, adminToken, user.ID, map[string]interface{}{
+			"impersonationReason":   "Support ticket 1234",
+			"impersonationReadOnly": true,
+		})
+		require.Equal(t, http.StatusOK, rec.Code)
+		token := getTokenMeta(tc, rec)["token"].(string)
+
+		assert.Equal(t, user.ID.String(), getCurrentUserID(t, tc, token))
+
+		reqBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"type":       constants.ApiTypeUser,
+				"attributes": map[string]interface{}{"name": "Changed"},
+			},
+		}
+		rec = tc.MakeAuthenticatedRequest(http.MethodPatch, "/users/"+user.ID.String(), reqBody, token)
+		require.Equal(t, http.StatusForbidden, rec.Code)
+
+		var apiErr api.ApiError
+		tc.UnmarshalResponse(rec, &apiErr)
+		assert.Equal(t, api.ErrorCodeImpersonationReadOnly, apiErr.Code)
+
+		// The admin can still refresh and stop impersonating
+		rec = tc.MakeAuthenticatedRequest(http.MethodPost, "/users/me/token/refresh", nil, token)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		rec = stopImpersonating(tc, token)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("CannotCreateAccessToken", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, adminToken := createAdmin(t, tc)
+		user, org, _, _, _ := test.CreateTestUserWithOrganization(t, tc)
+
+		rec := impersonate(tc, adminToken, user.ID, map[string]interface{}{
+			"impersonationReason": "Support ticket 1234",
+		})
+		require.Equal(t, http.StatusOK, rec.Code)
+		token := getTokenMeta(tc, rec)["token"].(string)
+
+		code, _ := createAccessToken(tc, token, map[string]interface{}{
+			"name":   "CI",
+			"scopes": []string{string(constants.UserScopeOrganizationRead)},
+		}, org.ID.String())
+		assert.Equal(t, http.StatusForbidden, code)
+
+		var count int64
+		require.NoError(t, tc.DB.Model(&models.PersonalAccessToken{}).Where("user_id = ?", user.ID).Count(&count).Error)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("NotifiesUser", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, adminToken := createAdmin(t, tc)
+		user, _, _ := test.CreateTestUser(t, tc)
+
+		rec := impersonate(tc, adminToken, user.ID, map[string]interface{}{
+			"impersonationReason":    "Support ticket 1234",
+			"notifyImpersonatedUser": true,
+		})
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var jobCount int64
+		err := tc.DB.Raw(`SELECT COUNT(*) FROM river_job WHERE kind = ?`, string(constants.JobKindImpersonationEmail)).Scan(&jobCount).Error
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), jobCount)
+	})
+
+	t.Run("ExpiredImpersonationRefreshesAsAdmin", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		admin, adminToken := createAdmin(t, tc)
+		user, _, _ := test.CreateTestUser(t, tc)
+
+		rec := impersonate(tc, adminToken, user.ID, map[string]interface{}{"impersonationReason": "Support ticket 1234"})
+		require.Equal(t, http.StatusOK, rec.Code)
+		token := getTokenMeta(tc, rec)["token"].(string)
+
+		err := tc.DB.Model(&models.ImpersonationSession{}).Where("impersonated_user_id = ?", user.ID).Update("expires_at", time.Now().Add(-time.Second)).Error
+		require.NoError(t, err)
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodPost, "/users/me/token/refresh", nil, token)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, admin.ID.String(), getCurrentUserID(t, tc, getTokenMeta(tc, rec)["token"].(string)))
+	})
+
+	t.Run("EndedImpersonationRotatesAsAdmin", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		admin, adminToken := createAdmin(t, tc)
+		user, _, _ := test.CreateTestUser(t, tc)
+
+		rec := impersonate(tc, adminToken, user.ID, map[string]interface{}{"impersonationReason": "Support ticket 1234"})
+		require.Equal(t, http.StatusOK, rec.Code)
+		meta := getTokenMeta(tc, rec)
+
+		rec = stopImpersonating(tc, meta["token"].(string))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		rotateBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"type": constants.ApiTypeToken,
+				"attributes": map[string]interface{}{
+					"refreshToken": meta["refreshToken"],
+				},
+			},
+		}
+		rec = tc.MakeRequest(http.MethodPost, "/users/token/rotate", rotateBody, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response map[string]interface{}
+		tc.UnmarshalResponse(rec, &response)
+		data := response["data"].(map[string]interface{})
+		assert.Nil(t, data["relationships"].(map[string]interface{})["impersonatedUser"])
+		assert.Equal(t, admin.ID.String(), getCurrentUserID(t, tc, data["meta"].(map[string]interface{})["token"].(string)))
+	})
+
+	t.Run("AdminListsImpersonations", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		admin, adminToken := createAdmin(t, tc)
+		user, _, _ := test.CreateTestUser(t, tc)
+		otherUser, _, _ := test.CreateTestUser(t, tc)
+
+		for _, impersonatedUser := range []*models.User{user, otherUser} {
+			rec := impersonate(tc, adminToken, impersonatedUser.ID, map[string]interface{}{"impersonationReason": "Support ticket 1234"})
+			require.Equal(t, http.StatusOK, rec.Code)
+		}
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodGet, "/impersonations?userId="+user.ID.String(), nil, adminToken)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response map[string]interface{}
+		tc.UnmarshalResponse(rec, &response)
+		impersonations := response["data"].([]interface{})
+		require.Len(t, impersonations, 1)
+
+		impersonation := impersonations[0].(map[string]interface{})
+		relationships := impersonation["relationships"].(map[string]interface{})
+		assert.Equal(t, string(constants.ApiTypeImpersonation), impersonation["type"])
+		assert.Equal(t, "Support ticket 1234", impersonation["attributes"].(map[string]interface{})["reason"])
+		assert.Equal(t, admin.ID.String(), relationships["impersonator"].(map[string]interface{})["data"].(map[string]interface{})["id"])
+		assert.Equal(t, user.ID.String(), relationships["impersonatedUser"].(map[string]interface{})["data"].(map[string]interface{})["id"])
+
+		_, _, token := test.CreateTestUser(t, tc)
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/impersonations", nil, token)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
+
+// getSessions lists the sessions of the user the token belongs to
+func getSessions(t *testing.T, tc *test.TestContext, token string) []interface{} {
+	rec := tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me/sessions", nil, token)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var response map[string]interface{}
+	tc.UnmarshalResponse(rec, &response)
+	return response["data"].([]interface{})
+}
+
+func TestUserSessionEndpoints(t *testing.T) {
+	t.Run("ListsAndRevokesSession", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, password, token := test.CreateTestUser(t, tc)
+
+		reqBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"type": constants.ApiTypeUser,
+				"attributes": map[string]interface{}{
+					"email":    user.Email,
+					"password": password,
+				},
+			},
+		}
+		rec := tc.MakeRequest(http.MethodPost, "/users/login", reqBody, map[string]string{"User-Agent": "test-device"})
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var loginResponse map[string]interface{}
+		tc.UnmarshalResponse(rec, &loginResponse)
+		loginMeta := loginResponse["data"].(map[string]interface{})["meta"].(map[string]interface{})
+		otherToken := loginMeta["token"].(string)
+		otherRefreshToken := loginMeta["refreshToken"].(string)
+
+		sessions := getSessions(t, tc, token)
+		require.Len(t, sessions, 2)
+
+		var otherSessionId string
+		for _, s := range sessions {
+			session := s.(map[string]interface{})
+			attributes := session["attributes"].(map[string]interface{})
+			assert.Equal(t, string(constants.ApiTypeUserSession), session["type"])
+			if attributes["current"] == true {
+				continue
+			}
+			otherSessionId = session["id"].(string)
+			assert.Equal(t, "test-device", attributes["userAgent"])
+		}
+		require.NotEmpty(t, otherSessionId)
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodDelete, "/users/me/sessions/"+otherSessionId, nil, token)
+		require.Equal(t, http.StatusNoContent, rec.Code)
+
+		// The revoked session's access token is rejected
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me", nil, otherToken)
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		var apiErr api.ApiError
+		tc.UnmarshalResponse(rec, &apiErr)
+		assert.Equal(t, api.ErrorCodeReauthenticationRequired, apiErr.Code)
+
+		// And so is its refresh token
+		rotateBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"type": constants.ApiTypeToken,
+				"attributes": map[string]interface{}{
+					"refreshToken": otherRefreshToken,
+				},
+			},
+		}
+		rec = tc.MakeRequest(http.MethodPost, "/users/token/rotate", rotateBody, nil)
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		// The current session is unaffected
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me", nil, token)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Len(t, getSessions(t, tc, token), 1)
+	})
+
+	t.Run("SignsOutEverywhereElse", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, password, token := test.CreateTestUser(t, tc)
+
+		otherTokens := make([]string, 0, 2)
+		for i := 0; i < 2; i++ {
+			rec := login(tc, user.Email, password)
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			var loginResponse map[string]interface{}
+			tc.UnmarshalResponse(rec, &loginResponse)
+			otherTokens = append(otherTokens, loginResponse["data"].(map[string]interface{})["meta"].(map[string]interface{})["token"].(string))
+		}
+		require.Len(t, getSessions(t, tc, token), 3)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodDelete, "/users/me/sessions", nil, token)
+		require.Equal(t, http.StatusNoContent, rec.Code)
+
+		for _, otherToken := range otherTokens {
+			rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me", nil, otherToken)
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		}
+
+		sessions := getSessions(t, tc, token)
+		require.Len(t, sessions, 1)
+		assert.Equal(t, true, sessions[0].(map[string]interface{})["attributes"].(map[string]interface{})["current"])
+	})
+
+	t.Run("RotationKeepsSession", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, password, token := test.CreateTestUser(t, tc)
+
+		rec := login(tc, user.Email, password)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var loginResponse map[string]interface{}
+		tc.UnmarshalResponse(rec, &loginResponse)
+		refreshToken := loginResponse["data"].(map[string]interface{})["meta"].(map[string]interface{})["refreshToken"].(string)
+
+		rotateBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"type": constants.ApiTypeToken,
+				"attributes": map[string]interface{}{
+					"refreshToken": refreshToken,
+				},
+			},
+		}
+		rec = tc.MakeRequest(http.MethodPost, "/users/token/rotate", rotateBody, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		assert.Len(t, getSessions(t, tc, token), 2)
+	})
+
+	t.Run("CannotRevokeAnotherUsersSession", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, _, token := test.CreateTestUser(t, tc)
+		_, _, otherToken := test.CreateTestUser(t, tc)
+
+		otherSessions := getSessions(t, tc, otherToken)
+		require.Len(t, otherSessions, 1)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodDelete, "/users/me/sessions/"+otherSessions[0].(map[string]interface{})["id"].(string), nil, token)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me", nil, otherToken)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("InvalidSessionID", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, _, token := test.CreateTestUser(t, tc)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodDelete, "/users/me/sessions/invalid", nil, token)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+// createAccessToken creates a personal access token, returning the response status and body
+func createAccessToken(tc *test.TestContext, token string, attributes map[string]interface{}, organizationId string) (int, map[string]interface{}) {
+	data := map[string]interface{}{
+		"type":       constants.ApiTypeAccessToken,
+		"attributes": attributes,
+	}
+	if organizationId != "" {
+		data["relationships"] = map[string]interface{}{
+			"organization": map[string]interface{}{
+				"data": map[string]interface{}{
+					"id":   organizationId,
+					"type": "organization",
+				},
+			},
+		}
+	}
+
+	rec := tc.MakeAuthenticatedRequest(http.MethodPost, "/users/me/access-tokens", map[string]interface{}{"data": data}, token)
+
+	var response map[string]interface{}
+	tc.UnmarshalResponse(rec, &response)
+	return rec.Code, response
+}
+
+func TestAccessTokenEndpoints(t *testing.T) {
+	t.Run("CreatesUsesAndRevokesToken", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, org, token := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+
+		code, response := createAccessToken(tc, token, map[string]interface{}{
+			"name":   "CI",
+			"scopes": []string{string(constants.UserScopeOrganizationRead)},
+		}, org.ID.String())
+		require.Equal(t, http.StatusCreated, code)
+
+		data := response["data"].(map[string]interface{})
+		accessTokenId := data["id"].(string)
+		accessToken := data["meta"].(map[string]interface{})["token"].(string)
+		assert.True(t, strings.HasPrefix(accessToken, authentication.PersonalAccessTokenPrefix))
+		assert.True(t, strings.HasPrefix(accessToken, data["attributes"].(map[string]interface{})["tokenPrefix"].(string)))
+
+		// The token can be used in place of a JWT
+		rec := tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me", nil, accessToken)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/organizations/"+org.ID.String(), nil, accessToken)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		// But only with the scopes it was granted
+		updateBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"attributes": map[string]interface{}{
+					"name": "Renamed",
+				},
+			},
+		}
+		rec = tc.MakeAuthenticatedRequest(http.MethodPatch, "/organizations/"+org.ID.String(), updateBody, accessToken)
+		require.Equal(t, http.StatusForbidden, rec.Code)
+
+		// The token can't be exchanged for a JWT or used to manage credentials
+		rec = tc.MakeAuthenticatedRequest(http.MethodPost, "/users/me/token", map[string]interface{}{"data": map[string]interface{}{"type": "token"}}, accessToken)
+		require.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me/access-tokens", nil, accessToken)
+		require.Equal(t, http.StatusForbidden, rec.Code)
+
+		// Listing shows when the token was last used, but not the token itself
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me/access-tokens", nil, token)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var listResponse map[string]interface{}
+		tc.UnmarshalResponse(rec, &listResponse)
+		accessTokens := listResponse["data"].([]interface{})
+		require.Len(t, accessTokens, 1)
+		listed := accessTokens[0].(map[string]interface{})
+		assert.Nil(t, listed["meta"])
+		assert.NotEmpty(t, listed["attributes"].(map[string]interface{})["lastUsedAt"])
+		assert.Equal(t, org.ID.String(), listed["relationships"].(map[string]interface{})["organization"].(map[string]interface{})["data"].(map[string]interface{})["id"])
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodDelete, "/users/me/access-tokens/"+accessTokenId, nil, token)
+		require.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me", nil, accessToken)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("NotifiesUser", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, org, _, _, token := test.CreateTestUserWithOrganization(t, tc)
+
+		code, _ := createAccessToken(tc, token, map[string]interface{}{
+			"name":   "CI",
+			"scopes": []string{string(constants.UserScopeOrganizationRead)},
+		}, org.ID.String())
+		require.Equal(t, http.StatusCreated, code)
+
+		assert.Equal(t, int64(1), countCredentialChangeJobs(t, tc, user.Email, constants.CredentialChangeAccessToken))
+	})
+
+	t.Run("StaleSessionNeedsCurrentPassword", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, org, _, password, token := test.CreateTestUserWithOrganization(t, tc)
+
+		signedInAt := time.Now().Add(-time.Duration(tc.Config.ReauthenticationMaxAge+60) * time.Second)
+		require.NoError(t, tc.DB.Model(&models.UserSession{}).Where("user_id = ?", user.ID).Update("created_at", signedInAt).Error)
+
+		attributes := map[string]interface{}{
+			"name":   "CI",
+			"scopes": []string{string(constants.UserScopeOrganizationRead)},
+		}
+		code, response := createAccessToken(tc, token, attributes, org.ID.String())
+		require.Equal(t, http.StatusForbidden, code)
+		assert.Equal(t, api.ErrorCodeCurrentPasswordRequired, response["code"])
+
+		attributes["currentPassword"] = "not-the-password"
+		code, _ = createAccessToken(tc, token, attributes, org.ID.String())
+		require.Equal(t, http.StatusForbidden, code)
+
+		var throttle models.LoginThrottle
+		require.NoError(t, tc.DB.Where("scope = ? AND target = ?", "account", strings.ToLower(user.Email)).First(&throttle).Error)
+		assert.Equal(t, 1, throttle.FailedAttempts)
+
+		attributes["currentPassword"] = password
+		code, _ = createAccessToken(tc, token, attributes, org.ID.String())
+		assert.Equal(t, http.StatusCreated, code)
+	})
+
+	t.Run("ScopesAreBoundedByUser", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, _, token := test.CreateTestUser(t, tc)
+
+		code, _ := createAccessToken(tc, token, map[string]interface{}{
+			"name":   "Admin",
+			"scopes": []string{string(constants.UserScopeAdminUsersList)},
+		}, "")
+		assert.Equal(t, http.StatusForbidden, code)
+	})
+
+	t.Run("RequiresOrganizationMembership", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, _, token := test.CreateTestUser(t, tc)
+		_, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+
+		code, _ := createAccessToken(tc, token, map[string]interface{}{
+			"name":   "Other organization",
+			"scopes": []string{string(constants.UserScopeOrganizationRead)},
+		}, org.ID.String())
+		assert.Equal(t, http.StatusForbidden, code)
+	})
+
+	t.Run("CannotReachOtherOrganization", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, org, _, _, token := test.CreateTestUserWithOrganization(t, tc)
+		otherOrg := test.CreateTestOrganization(t, tc, token)
+
+		code, response := createAccessToken(tc, token, map[string]interface{}{
+			"name":   "CI",
+			"scopes": []string{string(constants.UserScopeOrganizationRead)},
+		}, org.ID.String())
+		require.Equal(t, http.StatusCreated, code)
+		accessToken := response["data"].(map[string]interface{})["meta"].(map[string]interface{})["token"].(string)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodGet, "/organizations/"+org.ID.String(), nil, accessToken)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		// The user is an admin of the other organization too, but the token was only granted access to the first one
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/organizations/"+otherOrg.ID.String(), nil, accessToken)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("ExpiredToken", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, _, token := test.CreateTestUser(t, tc)
+
+		code, response := createAccessToken(tc, token, map[string]interface{}{
+			"name":      "Short lived",
+			"scopes":    []string{},
+			"expiresAt": time.Now().Add(time.Hour).Format(time.RFC3339),
+		}, "")
+		require.Equal(t, http.StatusCreated, code)
+
+		data := response["data"].(map[string]interface{})
+		accessToken := data["meta"].(map[string]interface{})["token"].(string)
+
+		err := tc.DB.Model(&models.PersonalAccessToken{}).Where("id = ?", data["id"]).Update("expires_at", time.Now().Add(-time.Minute)).Error
+		require.NoError(t, err)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me", nil, accessToken)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("ExpiryMustBeInFuture", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, _, token := test.CreateTestUser(t, tc)
+
+		code, _ := createAccessToken(tc, token, map[string]interface{}{
+			"name":      "Already expired",
+			"scopes":    []string{},
+			"expiresAt": time.Now().Add(-time.Hour).Format(time.RFC3339),
+		}, "")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("RevokedByPasswordReset", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, token := test.CreateTestUser(t, tc)
+
+		code, response := createAccessToken(tc, token, map[string]interface{}{
+			"name":   "CI",
+			"scopes": []string{},
+		}, "")
+		require.Equal(t, http.StatusCreated, code)
+		accessToken := response["data"].(map[string]interface{})["meta"].(map[string]interface{})["token"].(string)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me", nil, accessToken)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		// Whoever had access to the account before the reset shouldn't keep it through a token
+		resetToken := createPasswordResetToken(t, tc, user)
+		require.Equal(t, http.StatusNoContent, confirmPasswordReset(tc, resetToken, "new-password-123"))
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me", nil, accessToken)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("CannotRevokeAnotherUsersToken", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, _, token := test.CreateTestUser(t, tc)
+		_, _, otherToken := test.CreateTestUser(t, tc)
+
+		code, response := createAccessToken(tc, otherToken, map[string]interface{}{
+			"name":   "Other",
+			"scopes": []string{},
+		}, "")
+		require.Equal(t, http.StatusCreated, code)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodDelete, "/users/me/access-tokens/"+response["data"].(map[string]interface{})["id"].(string), nil, token)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+// createSamlConnection connects the stub identity provider to the organization, registering the organization's service
+// provider metadata with it the same way an admin would
+func createSamlConnection(t *testing.T, tc *test.TestContext, idp *mocks.SAMLIdentityProvider, organizationID uuid.UUID, ssoRequired bool) *models.OrganizationSamlConnection {
+	rec := tc.MakeRequest(http.MethodGet, "/saml/"+organizationID.String()+"/metadata", nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	idp.RegisterServiceProvider(t, rec.Body.Bytes())
+
+	settings, err := authentication.ParseSamlIdpMetadata(idp.MetadataXML(t))
+	require.NoError(t, err)
+
+	connection := models.OrganizationSamlConnection{
+		OrganizationID: organizationID,
+		IdpEntityID:    settings.EntityID,
+		IdpSsoUrl:      settings.SsoUrl,
+		IdpCertificate: settings.Certificate,
+		EmailAttribute: "email",
+		NameAttribute:  "displayName",
+		DefaultRole:    string(constants.OrganizationRoleMember),
+		SsoRequired:    ssoRequired,
+	}
+	require.NoError(t, tc.DB.Create(&connection).Error)
+
+	return &connection
+}
+
+// createVerifiedDomain adds a domain to the organization that is already verified, without going through DNS
+func createVerifiedDomain(t *testing.T, tc *test.TestContext, organizationID uuid.UUID, domain string) {
+	now := time.Now()
+	require.NoError(t, tc.DB.Create(&models.OrganizationDomain{
+		OrganizationID:    organizationID,
+		Domain:            domain,
+		VerificationToken: "verification-token",
+		VerifiedAt:        &now,
+		JoinPolicy:        string(constants.OrganizationDomainJoinPolicyApproval),
+		DefaultRole:       string(constants.OrganizationRoleMember),
+	}).Error)
+}
+
+// postSamlResponse posts a response to the assertion consumer service the way the browser would
+func postSamlResponse(tc *test.TestContext, organizationID uuid.UUID, samlResponse string, relayState string) *httptest.ResponseRecorder {
+	form := url.Values{}
+	form.Set("SAMLResponse", samlResponse)
+	form.Set("RelayState", relayState)
+
+	req := httptest.NewRequest(http.MethodPost, "/saml/"+organizationID.String()+"/acs", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rec := httptest.NewRecorder()
+	tc.Echo.ServeHTTP(rec, req)
+	return rec
+}
+
+func loginSaml(tc *test.TestContext, code string, state string) (int, map[string]interface{}) {
+	reqBody := map[string]interface{}{
+		"data": map[string]interface{}{
+			"attributes": map[string]interface{}{
+				"code":  code,
+				"state": state,
+			},
+		},
+	}
+
+	rec := tc.MakeRequest(http.MethodPost, "/users/login/saml", reqBody, nil)
+
+	var response map[string]interface{}
+	tc.UnmarshalResponse(rec, &response)
+	return rec.Code, response
+}
+
+// samlSignIn goes through the whole SAML flow against the stub identity provider, signing in as the given user
+func samlSignIn(t *testing.T, tc *test.TestContext, idp *mocks.SAMLIdentityProvider, organizationID uuid.UUID, user mocks.SAMLUser) (int, map[string]interface{}) {
+	rec := tc.MakeRequest(http.MethodPost, "/saml/"+organizationID.String()+"/login", nil, nil)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var response map[string]interface{}
+	tc.UnmarshalResponse(rec, &response)
+	data := response["data"].(map[string]interface{})
+	require.Equal(t, string(constants.ApiTypeSamlAuthorization), data["type"])
+	attributes := data["attributes"].(map[string]interface{})
+
+	samlResponse, relayState := idp.Respond(t, attributes["authorizationUrl"].(string), user)
+	require.Equal(t, attributes["state"], relayState)
+
+	rec = postSamlResponse(tc, organizationID, samlResponse, relayState)
+	if rec.Code != http.StatusSeeOther {
+		var errorResponse map[string]interface{}
+		tc.UnmarshalResponse(rec, &errorResponse)
+		return rec.Code, errorResponse
+	}
+
+	location, err := url.Parse(rec.Header().Get("Location"))
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(location.String(), tc.Config.SamlRedirectUri+"?"))
+
+	return loginSaml(tc, location.Query().Get("code"), location.Query().Get("state"))
+}
+
+func TestSamlLoginEndpoints(t *testing.T) {
+	t.Run("ProvisionsUserAndMembership", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		idp := mocks.NewSAMLIdentityProvider(t)
+		_, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		domain := "acme-" + uuid.New().String()[:8] + ".example.com"
+		email := "saml-new@" + domain
+		createVerifiedDomain(t, tc, org.ID, domain)
+		createSamlConnection(t, tc, idp, org.ID, false)
+
+		code, response := samlSignIn(t, tc, idp, org.ID, mocks.SAMLUser{NameID: "saml-subject", Email: email, Name: "SAML User"})
+		require.Equal(t, http.StatusOK, code)
+
+		meta := response["data"].(map[string]interface{})["meta"].(map[string]interface{})
+		assert.NotEmpty(t, meta["token"])
+
+		var identity models.UserIdentity
+		require.NoError(t, tc.DB.Where("provider = ? AND subject = ?", authentication.SamlIdentityProvider, org.ID.String()+":saml-subject").First(&identity).Error)
+
+		var user models.User
+		require.NoError(t, tc.DB.First(&user, identity.UserID).Error)
+		assert.Equal(t, email, user.Email)
+		assert.Equal(t, "SAML User", user.Name)
+		assert.Nil(t, user.HashedPassword)
+
+		var membership models.OrganizationMembership
+		require.NoError(t, tc.DB.Where("organization_id = ? AND user_id = ?", org.ID, user.ID).First(&membership).Error)
+		assert.Equal(t, string(constants.OrganizationRoleMember), membership.Role)
+
+		// Signing in again uses the same user and membership
+		code, _ = samlSignIn(t, tc, idp, org.ID, mocks.SAMLUser{NameID: "saml-subject", Email: email})
+		require.Equal(t, http.StatusOK, code)
+
+		var membershipCount int64
+		require.NoError(t, tc.DB.Model(&models.OrganizationMembership{}).Where("user_id = ?", user.ID).Count(&membershipCount).Error)
+		assert.Equal(t, int64(1), membershipCount)
+	})
+
+	t.Run("DoesNotCreateUserOutsideVerifiedDomains", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		idp := mocks.NewSAMLIdentityProvider(t)
+		_, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		createSamlConnection(t, tc, idp, org.ID, false)
+
+		// The identity provider could otherwise take an address before its owner signs up
+		code, _ := samlSignIn(t, tc, idp, org.ID, mocks.SAMLUser{NameID: "outside-subject", Email: "someone@example.org"})
+		assert.Equal(t, http.StatusForbidden, code)
+
+		var userCount int64
+		require.NoError(t, tc.DB.Model(&models.User{}).Where("email = ?", "someone@example.org").Count(&userCount).Error)
+		assert.Equal(t, int64(0), userCount)
+	})
+
+	t.Run("LinksUserOnVerifiedDomain", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		idp := mocks.NewSAMLIdentityProvider(t)
+		domain := "acme-" + uuid.New().String()[:8] + ".example.com"
+		user, _, _ := test.CreateTestUserWithOptions(t, tc, test.TestUserOptions{Email: "jane@" + domain})
+		_, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		createVerifiedDomain(t, tc, org.ID, domain)
+		createSamlConnection(t, tc, idp, org.ID, false)
+
+		code, _ := samlSignIn(t, tc, idp, org.ID, mocks.SAMLUser{NameID: "jane-subject", Email: user.Email})
+		require.Equal(t, http.StatusOK, code)
+
+		var identity models.UserIdentity
+		require.NoError(t, tc.DB.Where("provider = ?", authentication.SamlIdentityProvider).First(&identity).Error)
+		assert.Equal(t, user.ID, identity.UserID)
+
+		var membership models.OrganizationMembership
+		require.NoError(t, tc.DB.Where("organization_id = ? AND user_id = ?", org.ID, user.ID).First(&membership).Error)
+	})
+
+	t.Run("DoesNotLinkMemberWithoutVerifiedDomain", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		idp := mocks.NewSAMLIdentityProvider(t)
+		user, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		createSamlConnection(t, tc, idp, org.ID, false)
+
+		// Being a member isn't enough, the identity provider could be asserting any member's email
+		code, _ := samlSignIn(t, tc, idp, org.ID, mocks.SAMLUser{NameID: "member-subject", Email: user.Email})
+		assert.Equal(t, http.StatusConflict, code)
+
+		var identityCount int64
+		require.NoError(t, tc.DB.Model(&models.UserIdentity{}).Where("user_id = ?", user.ID).Count(&identityCount).Error)
+		assert.Equal(t, int64(0), identityCount)
+	})
+
+	t.Run("DoesNotLinkNonMember", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		idp := mocks.NewSAMLIdentityProvider(t)
+		_, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		otherUser, _, _ := test.CreateTestUser(t, tc)
+		createSamlConnection(t, tc, idp, org.ID, false)
+
+		code, _ := samlSignIn(t, tc, idp, org.ID, mocks.SAMLUser{NameID: "other-subject", Email: otherUser.Email})
+		assert.Equal(t, http.StatusConflict, code)
+
+		var identityCount int64
+		require.NoError(t, tc.DB.Model(&models.UserIdentity{}).Where("user_id = ?", otherUser.ID).Count(&identityCount).Error)
+		assert.Equal(t, int64(0), identityCount)
+	})
+
+	t.Run("RejectsUntrustedResponse", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		idp := mocks.NewSAMLIdentityProvider(t)
+		_, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		connection := createSamlConnection(t, tc, idp, org.ID, false)
+
+		// The organization trusts a different identity provider's certificate
+		otherIdp := mocks.NewSAMLIdentityProvider(t)
+		require.NoError(t, tc.DB.Model(connection).Update("idp_certificate", otherIdp.CertificatePem).Error)
+
+		code, _ := samlSignIn(t, tc, idp, org.ID, mocks.SAMLUser{NameID: "saml-subject", Email: "saml-new@example.com"})
+		assert.Equal(t, http.StatusUnauthorized, code)
+	})
+
+	t.Run("ResponseCanOnlyBeUsedOnce", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		idp := mocks.NewSAMLIdentityProvider(t)
+		_, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		domain := "acme-" + uuid.New().String()[:8] + ".example.com"
+		createVerifiedDomain(t, tc, org.ID, domain)
+		createSamlConnection(t, tc, idp, org.ID, false)
+
+		rec := tc.MakeRequest(http.MethodPost, "/saml/"+org.ID.String()+"/login", nil, nil)
+		require.Equal(t, http.StatusCreated, rec.Code)
+		var response map[string]interface{}
+		tc.UnmarshalResponse(rec, &response)
+		attributes := response["data"].(map[string]interface{})["attributes"].(map[string]interface{})
+
+		samlResponse, relayState := idp.Respond(t, attributes["authorizationUrl"].(string), mocks.SAMLUser{NameID: "saml-subject", Email: "saml-new@" + domain})
+
+		rec = postSamlResponse(tc, org.ID, samlResponse, relayState)
+		require.Equal(t, http.StatusSeeOther, rec.Code)
+
+		rec = postSamlResponse(tc, org.ID, samlResponse, relayState)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("CodeRequiresMatchingState", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		idp := mocks.NewSAMLIdentityProvider(t)
+		_, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		domain := "acme-" + uuid.New().String()[:8] + ".example.com"
+		createVerifiedDomain(t, tc, org.ID, domain)
+		createSamlConnection(t, tc, idp, org.ID, false)
+
+		rec := tc.MakeRequest(http.MethodPost, "/saml/"+org.ID.String()+"/login", nil, nil)
+		require.Equal(t, http.StatusCreated, rec.Code)
+		var response map[string]interface{}
+		tc.UnmarshalResponse(rec, &response)
+		attributes := response["data"].(map[string]interface{})["attributes"].(map[string]interface{})
+
+		samlResponse, relayState := idp.Respond(t, attributes["authorizationUrl"].(string), mocks.SAMLUser{NameID: "saml-subject", Email: "saml-new@" + domain})
+
+		rec = postSamlResponse(tc, org.ID, samlResponse, relayState)
+		require.Equal(t, http.StatusSeeOther, rec.Code)
+		location, err := url.Parse(rec.Header().Get("Location"))
+		require.NoError(t, err)
+
+		code, _ := loginSaml(tc, location.Query().Get("code"), "other-state")
+		assert.Equal(t, http.StatusBadRequest, code)
+
+		// The code is consumed by the failed attempt
+		code, _ = loginSaml(tc, location.Query().Get("code"), location.Query().Get("state"))
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("NoConnection", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, org, _ := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+
+		rec := tc.MakeRequest(http.MethodPost, "/saml/"+org.ID.String()+"/login", nil, nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("SsoRequiredBlocksPasswordLogin", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		idp := mocks.NewSAMLIdentityProvider(t)
+		domain := "acme-" + uuid.New().String()[:8] + ".example.com"
+		user, org, _, password, _ := test.CreateTestUserWithOrganizationWithOptions(t, tc, test.TestUserWithOrgOptions{UserEmail: "jane@" + domain})
+		createVerifiedDomain(t, tc, org.ID, domain)
+		createSamlConnection(t, tc, idp, org.ID, true)
+
+		reqBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"attributes": map[string]interface{}{
+					"email":    user.Email,
+					"password": password,
+				},
+			},
+		}
+		rec := tc.MakeRequest(http.MethodPost, "/users/login", reqBody, nil)
+		require.Equal(t, http.StatusForbidden, rec.Code)
+
+		var apiErr api.ApiError
+		tc.UnmarshalResponse(rec, &apiErr)
+		assert.Equal(t, api.ErrorCodeSsoRequired, apiErr.Code)
+		require.NotNil(t, apiErr.Meta)
+		assert.Equal(t, org.ID.String(), apiErr.Meta.OrganizationID)
+
+		// A wrong password doesn't reveal that the organization uses single sign-on
+		reqBody["data"].(map[string]interface{})["attributes"].(map[string]interface{})["password"] = "not-the-password"
+		rec = tc.MakeRequest(http.MethodPost, "/users/login", reqBody, nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		// Signing in through the identity provider still works
+		code, _ := samlSignIn(t, tc, idp, org.ID, mocks.SAMLUser{NameID: "member-subject", Email: user.Email})
+		assert.Equal(t, http.StatusOK, code)
+	})
+}
+
+func TestAccountDeletion(t *testing.T) {
+	countDeletionJobs := func(t *testing.T, tc *test.TestContext) int64 {
+		var count int64
+		require.NoError(t, tc.DB.Raw("SELECT COUNT(*) FROM river_job WHERE kind = ?", string(constants.JobKindUserDeletion)).Scan(&count).Error)
+		return count
+	}
+
+	t.Run("SchedulesDeletionAndSignsOut", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, token := test.CreateTestUser(t, tc)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodDelete, "/users/me", nil, token)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+
+		var response map[string]interface{}
+		tc.UnmarshalResponse(rec, &response)
+		meta := response["data"].(map[string]interface{})["meta"].(map[string]interface{})
+		assert.NotEmpty(t, meta["deletionScheduledAt"])
+
+		var updatedUser models.User
+		require.NoError(t, tc.DB.First(&updatedUser, user.ID).Error)
+		require.NotNil(t, updatedUser.DeletionScheduledAt)
+		assert.WithinDuration(t, time.Now().Add(time.Duration(tc.Config.AccountDeletionGracePeriod)*time.Second), *updatedUser.DeletionScheduledAt, time.Minute)
+		assert.Equal(t, int64(1), countDeletionJobs(t, tc))
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me", nil, token)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("SigningInCancelsDeletion", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, password, token := test.CreateTestUser(t, tc)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodDelete, "/users/me", nil, token)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+
+		test.LoginTestUser(t, tc, user.Email, password)
+
+		var updatedUser models.User
+		require.NoError(t, tc.DB.First(&updatedUser, user.ID).Error)
+		assert.Nil(t, updatedUser.DeletionScheduledAt)
+
+		// The job still runs once the grace period is over, but leaves the user alone
+		require.NoError(t, tc.DB.Exec("UPDATE river_job SET scheduled_at = now(), state = 'available' WHERE kind = ?", string(constants.JobKindUserDeletion)).Error)
+		test.RunAllPendingRiverJobs(t, tc.DB, tc.RiverClient)
+
+		require.NoError(t, tc.DB.First(&updatedUser, user.ID).Error)
+	})
+
+	t.Run("SoleOrganizationAdminCantDelete", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, token := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodDelete, "/users/me", nil, token)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		var updatedUser models.User
+		require.NoError(t, tc.DB.First(&updatedUser, user.ID).Error)
+		assert.Nil(t, updatedUser.DeletionScheduledAt)
+		assert.Equal(t, int64(0), countDeletionJobs(t, tc))
+	})
+
+	t.Run("AdminCanDeleteOnceAnotherAdminExists", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, org, token := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		otherAdmin, _, _ := test.CreateTestUser(t, tc)
+		test.CreateTestOrganizationMembership(t, tc, otherAdmin.ID, org.ID, constants.OrganizationRoleAdmin, token)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodDelete, "/users/me", nil, token)
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+	})
+
+	t.Run("JobKeepsUserWhoBecameSoleOrganizationAdmin", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, org, token := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		otherAdmin, _, _ := test.CreateTestUser(t, tc)
+		test.CreateTestOrganizationMembership(t, tc, otherAdmin.ID, org.ID, constants.OrganizationRoleAdmin, token)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodDelete, "/users/me", nil, token)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+
+		// The other admin is demoted during the grace period
+		require.NoError(t, tc.DB.Model(&models.OrganizationMembership{}).Where("user_id = ? AND organization_id = ?", otherAdmin.ID, org.ID).Update("role", constants.OrganizationRoleMember).Error)
+
+		require.NoError(t, tc.DB.Model(&models.User{}).Where("id = ?", user.ID).Update("deletion_scheduled_at", time.Now().Add(-time.Minute)).Error)
+		require.NoError(t, tc.DB.Exec("UPDATE river_job SET scheduled_at = now(), state = 'available' WHERE kind = ?", string(constants.JobKindUserDeletion)).Error)
+		test.RunAllPendingRiverJobs(t, tc.DB, tc.RiverClient)
+
+		var updatedUser models.User
+		require.NoError(t, tc.DB.First(&updatedUser, user.ID).Error)
+		assert.Nil(t, updatedUser.DeletionScheduledAt)
+
+		var count int64
+		require.NoError(t, tc.DB.Model(&models.OrganizationMembership{}).Where("user_id = ?", user.ID).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+
+		// The user is told why their account is still there
+		require.NoError(t, tc.DB.Raw("SELECT COUNT(*) FROM river_job WHERE kind = ? AND args->>'userId' = ?", string(constants.JobKindUserDeletionCancelledEmail), user.ID.String()).Scan(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("JobDeletesUserOnceDue", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		admin, org, adminToken := test.CreateAuthenticatedTestUser(t, tc, constants.OrganizationRoleAdmin)
+		user, _, token := test.CreateTestUser(t, tc)
+		test.CreateTestOrganizationMembership(t, tc, user.ID, org.ID, constants.OrganizationRoleMember, adminToken)
+
+		impersonation := models.ImpersonationSession{
+			ImpersonatorUserID: &admin.ID,
+			ImpersonatedUserID: &user.ID,
+			Reason:             "Support ticket 1234",
+			ExpiresAt:          time.Now().Add(-time.Hour),
+		}
+		require.NoError(t, tc.DB.Create(&impersonation).Error)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodDelete, "/users/me", nil, token)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+
+		require.NoError(t, tc.DB.Model(&models.User{}).Where("id = ?", user.ID).Update("deletion_scheduled_at", time.Now().Add(-time.Minute)).Error)
+		require.NoError(t, tc.DB.Exec("UPDATE river_job SET scheduled_at = now(), state = 'available' WHERE kind = ?", string(constants.JobKindUserDeletion)).Error)
+		test.RunAllPendingRiverJobs(t, tc.DB, tc.RiverClient)
+
+		var count int64
+		require.NoError(t, tc.DB.Unscoped().Model(&models.User{}).Where("id = ?", user.ID).Count(&count).Error)
+		assert.Equal(t, int64(0), count)
+
+		require.NoError(t, tc.DB.Unscoped().Model(&models.OrganizationMembership{}).Where("user_id = ?", user.ID).Count(&count).Error)
+		assert.Equal(t, int64(0), count)
+
+		// The organization itself is left alone
+		require.NoError(t, tc.DB.Model(&models.Organization{}).Where("id = ?", org.ID).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+
+		// Impersonations of the user are kept for auditing
+		var stored models.ImpersonationSession
+		require.NoError(t, tc.DB.First(&stored, impersonation.ID).Error)
+		assert.Nil(t, stored.ImpersonatedUserID)
+		require.NotNil(t, stored.ImpersonatorUserID)
+		assert.Equal(t, admin.ID, *stored.ImpersonatorUserID)
+	})
+}
+
+func TestUserDataExport(t *testing.T) {
+	getExport := func(tc *test.TestContext, rec *httptest.ResponseRecorder) map[string]interface{} {
+		var response map[string]interface{}
+		tc.UnmarshalResponse(rec, &response)
+		return response["data"].(map[string]interface{})
+	}
+
+	countExportJobs := func(t *testing.T, tc *test.TestContext) int64 {
+		var count int64
+		require.NoError(t, tc.DB.Raw("SELECT COUNT(*) FROM river_job WHERE kind = ?", string(constants.JobKindUserDataExport)).Scan(&count).Error)
+		return count
+	}
+
+	t.Run("StartsExportAndShowsStatus", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, _, token := test.CreateTestUser(t, tc)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodPost, "/users/me/export", nil, token)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+
+		export := getExport(tc, rec)
+		assert.Equal(t, string(constants.ApiTypeUserDataExport), export["type"])
+		assert.Equal(t, string(constants.UserDataExportStatusPending), export["attributes"].(map[string]interface{})["status"])
+		assert.Equal(t, int64(1), countExportJobs(t, tc))
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me/export", nil, token)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, export["id"], getExport(tc, rec)["id"])
+	})
+
+	t.Run("ReusesPendingExport", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, _, token := test.CreateTestUser(t, tc)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodPost, "/users/me/export", nil, token)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		firstExport := getExport(tc, rec)
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodPost, "/users/me/export", nil, token)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, firstExport["id"], getExport(tc, rec)["id"])
+		assert.Equal(t, int64(1), countExportJobs(t, tc))
+	})
+
+	t.Run("ShowsCompletedExport", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, token := test.CreateTestUser(t, tc)
+
+		completedAt := time.Now()
+		expiresAt := completedAt.Add(time.Duration(tc.Config.UserDataExportLinkExpirationTime) * time.Second)
+		require.NoError(t, tc.DB.Create(&models.UserDataExport{
+			UserID:         user.ID,
+			Status:         string(constants.UserDataExportStatusCompleted),
+			FileStorageKey: user.ID.String() + "/export.zip",
+			CompletedAt:    &completedAt,
+			ExpiresAt:      &expiresAt,
+		}).Error)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me/export", nil, token)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		attributes := getExport(tc, rec)["attributes"].(map[string]interface{})
+		assert.Equal(t, string(constants.UserDataExportStatusCompleted), attributes["status"])
+		assert.NotEmpty(t, attributes["completedAt"])
+		assert.NotEmpty(t, attributes["expiresAt"])
+
+		// A new export can be started once the previous one is done
+		rec = tc.MakeAuthenticatedRequest(http.MethodPost, "/users/me/export", nil, token)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, string(constants.UserDataExportStatusPending), getExport(tc, rec)["attributes"].(map[string]interface{})["status"])
+	})
+
+	t.Run("CantSeeAnotherUsersExport", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		_, _, token := test.CreateTestUser(t, tc)
+		_, _, otherToken := test.CreateTestUser(t, tc)
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodPost, "/users/me/export", nil, token)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me/export", nil, otherToken)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+// countCredentialChangeJobs counts the emails queued to tell the user how they sign in changed
+func countCredentialChangeJobs(t *testing.T, tc *test.TestContext, email string, change constants.CredentialChange) int64 {
+	var count int64
+	err := tc.DB.Raw(`
+		SELECT COUNT(*)
+		FROM river_job
+		WHERE kind = ? AND args->>'email' = ? AND args->>'change' = ?
+	`, string(constants.JobKindCredentialChangeEmail), email, string(change)).Scan(&count).Error
+	require.NoError(t, err)
+	return count
+}
+
+func TestCredentialChanges(t *testing.T) {
+	changePassword := func(tc *test.TestContext, user *models.User, token string, attributes map[string]interface{}) *httptest.ResponseRecorder {
+		reqBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"type":       constants.ApiTypeUser,
+				"attributes": attributes,
+			},
+		}
+		return tc.MakeAuthenticatedRequest(http.MethodPatch, "/users/"+user.ID.String(), reqBody, token)
+	}
+
+	t.Run("PasswordChangeSignsOutOtherSessions", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, password, token := test.CreateTestUser(t, tc)
+		otherToken := test.LoginTestUser(t, tc, user.Email, password)
+
+		rec := changePassword(tc, user, token, map[string]interface{}{
+			"password":        "new-password-123",
+			"currentPassword": password,
+		})
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response map[string]interface{}
+		tc.UnmarshalResponse(rec, &response)
+		newToken := response["data"].(map[string]interface{})["meta"].(map[string]interface{})["token"].(string)
+		require.NotEmpty(t, newToken)
+
+		// Only the new token of the session that made the change still works
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me", nil, otherToken)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me", nil, token)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		rec = tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me", nil, newToken)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		assert.Equal(t, int64(1), countCredentialChangeJobs(t, tc, user.Email, constants.CredentialChangePassword))
+		assert.Equal(t, http.StatusOK, login(tc, user.Email, "new-password-123").Code)
+	})
+
+	t.Run("StaleSessionNeedsCurrentPassword", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, password, token := test.CreateTestUser(t, tc)
+
+		signedInAt := time.Now().Add(-time.Duration(tc.Config.ReauthenticationMaxAge+60) * time.Second)
+		require.NoError(t, tc.DB.Model(&models.UserSession{}).Where("user_id = ?", user.ID).Update("created_at", signedInAt).Error)
+
+		rec := changePassword(tc, user, token, map[string]interface{}{"password": "new-password-123"})
+		require.Equal(t, http.StatusForbidden, rec.Code)
+
+		var apiErr api.ApiError
+		tc.UnmarshalResponse(rec, &apiErr)
+		assert.Equal(t, api.ErrorCodeCurrentPasswordRequired, apiErr.Code)
+
+		// The name can still be changed without it
+		rec = changePassword(tc, user, token, map[string]interface{}{"name": "New Name", "email": user.Email})
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = changePassword(tc, user, token, map[string]interface{}{
+			"password":        "new-password-123",
+			"currentPassword": password,
+		})
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("WrongCurrentPasswordCountsAsFailedLogin", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, token := test.CreateTestUser(t, tc)
+
+		rec := changePassword(tc, user, token, map[string]interface{}{
+			"password":        "new-password-123",
+			"currentPassword": "not-the-password",
+		})
+		require.Equal(t, http.StatusForbidden, rec.Code)
+
+		var throttle models.LoginThrottle
+		require.NoError(t, tc.DB.Where("scope = ? AND target = ?", "account", strings.ToLower(user.Email)).First(&throttle).Error)
+		assert.Equal(t, 1, throttle.FailedAttempts)
+
+		assert.Equal(t, int64(0), countCredentialChangeJobs(t, tc, user.Email, constants.CredentialChangePassword))
+	})
+
+	t.Run("EmailChangeNotifiesOldAddress", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, token := test.CreateTestUser(t, tc)
+
+		changeEmail(t, tc, user, token, "changed@example.com")
+
+		// The old address is told once the change goes through
+		assert.Equal(t, int64(0), countCredentialChangeJobs(t, tc, user.Email, constants.CredentialChangeEmail))
+
+		verificationToken := createEmailVerificationToken(t, tc, user, "changed@example.com")
+		require.Equal(t, http.StatusNoContent, confirmEmailVerification(tc, verificationToken))
+
+		assert.Equal(t, int64(1), countCredentialChangeJobs(t, tc, user.Email, constants.CredentialChangeEmail))
+	})
+}
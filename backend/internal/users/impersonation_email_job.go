@@ -0,0 +1,83 @@
+package users
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resend/resend-go/v2"
+	"github.com/riverqueue/river"
+	"gorm.io/gorm"
+	"reece.start/internal/configuration"
+	"reece.start/internal/constants"
+	"reece.start/internal/email"
+	"reece.start/internal/models"
+)
+
+// Lets the user know an admin signed in to their account as them
+type ImpersonationEmailJobArgs struct {
+	ImpersonationSessionId uuid.UUID `json:"impersonationSessionId"`
+}
+
+func (ImpersonationEmailJobArgs) Kind() string {
+	return string(constants.JobKindImpersonationEmail)
+}
+
+type ImpersonationEmailJobWorker struct {
+	river.WorkerDefaults[ImpersonationEmailJobArgs]
+	DB           *gorm.DB
+	Config       *configuration.Config
+	ResendClient *resend.Client
+}
+
+func (w *ImpersonationEmailJobWorker) Work(ctx context.Context, job *river.Job[ImpersonationEmailJobArgs]) error {
+	slog.Info("Sending impersonation email", "impersonationSessionId", job.Args.ImpersonationSessionId)
+
+	var impersonation models.ImpersonationSession
+	err := w.DB.Preload("ImpersonatedUser").First(&impersonation, job.Args.ImpersonationSessionId).Error
+	if err != nil {
+		return err
+	}
+
+	// The user was deleted before the email went out
+	if impersonation.ImpersonatedUser == nil {
+		return nil
+	}
+
+	html, err := email.ImpersonationEmailTemplateParams{
+		User:               *impersonation.ImpersonatedUser,
+		Reason:             impersonation.Reason,
+		ReadOnly:           impersonation.ReadOnly,
+		StartedAt:          impersonation.CreatedAt,
+		ExpiresAt:          impersonation.ExpiresAt,
+		FrontendUrl:        w.Config.FrontendUrl,
+		ServiceName:        constants.ServiceName,
+		ServiceDescription: constants.ServiceDescription,
+	}.ApplyHtmlTemplate()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = email.SendEmail(email.SendEmailRequest{
+		Params: email.SendEmailParams{
+			From:    string(constants.EmailSenderDefault),
+			To:      []string{impersonation.ImpersonatedUser.Email},
+			Subject: "An administrator accessed your " + constants.ServiceName + " account",
+			Html:    html,
+		},
+		ResendClient: w.ResendClient,
+		Config:       w.Config,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (w *ImpersonationEmailJobWorker) Timeout(*river.Job[ImpersonationEmailJobArgs]) time.Duration {
+	return 180 * time.Second
+}
@@ -0,0 +1,74 @@
+package users
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resend/resend-go/v2"
+	"gorm.io/gorm"
+	"reece.start/internal/authentication"
+	"reece.start/internal/configuration"
+	"reece.start/internal/constants"
+	"reece.start/internal/email"
+	"reece.start/internal/models"
+)
+
+// linkTokenEmail describes an email with a single use link in it, like a magic link or a password reset link
+type linkTokenEmail struct {
+	// Model the tokens are stored as, the user's unused ones are deleted so only the newest link can be used
+	Model any
+	// NewToken returns the row to store for a new token, only the hash of the token is stored
+	NewToken          func(userId uuid.UUID, tokenHash string, expiresAt time.Time) any
+	Expiration        time.Duration
+	Subject           string
+	ApplyHtmlTemplate func(user models.User, token string, expiresAt time.Time) (string, error)
+}
+
+// sendLinkTokenEmail creates a new single use token for the user and emails it to them. The token is created here
+// rather than when the link is requested, so the plaintext never has to be stored in the job's arguments.
+func sendLinkTokenEmail(ctx context.Context, db *gorm.DB, config *configuration.Config, resendClient *resend.Client, userId uuid.UUID, linkEmail linkTokenEmail) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		err := tx.First(&user, userId).Error
+		if err != nil {
+			return err
+		}
+
+		// Only the most recently sent link can be used
+		err = tx.Unscoped().Where("user_id = ? AND used_at IS NULL", user.ID).Delete(linkEmail.Model).Error
+		if err != nil {
+			return err
+		}
+
+		token, tokenHash, err := authentication.GenerateOpaqueToken()
+		if err != nil {
+			return err
+		}
+
+		expiresAt := time.Now().Add(linkEmail.Expiration)
+		err = tx.Create(linkEmail.NewToken(user.ID, tokenHash, expiresAt)).Error
+		if err != nil {
+			return err
+		}
+
+		html, err := linkEmail.ApplyHtmlTemplate(user, token, expiresAt)
+		if err != nil {
+			return err
+		}
+
+		// Sent before committing, so the token is only kept if the email went out
+		_, err = email.SendEmail(email.SendEmailRequest{
+			Params: email.SendEmailParams{
+				From:    string(constants.EmailSenderDefault),
+				To:      []string{user.Email},
+				Subject: linkEmail.Subject,
+				Html:    html,
+			},
+			ResendClient: resendClient,
+			Config:       config,
+		})
+
+		return err
+	})
+}
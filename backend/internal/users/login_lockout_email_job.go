@@ -0,0 +1,76 @@
+package users
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resend/resend-go/v2"
+	"github.com/riverqueue/river"
+	"gorm.io/gorm"
+	"reece.start/internal/configuration"
+	"reece.start/internal/constants"
+	"reece.start/internal/email"
+	"reece.start/internal/models"
+)
+
+// Lets the user know their account was locked out after too many failed sign in attempts
+type LoginLockoutEmailJobArgs struct {
+	UserId      uuid.UUID `json:"userId"`
+	LockedUntil time.Time `json:"lockedUntil"`
+}
+
+func (LoginLockoutEmailJobArgs) Kind() string {
+	return string(constants.JobKindLoginLockoutEmail)
+}
+
+type LoginLockoutEmailJobWorker struct {
+	river.WorkerDefaults[LoginLockoutEmailJobArgs]
+	DB           *gorm.DB
+	Config       *configuration.Config
+	ResendClient *resend.Client
+}
+
+func (w *LoginLockoutEmailJobWorker) Work(ctx context.Context, job *river.Job[LoginLockoutEmailJobArgs]) error {
+	slog.Info("Sending login lockout email", "userId", job.Args.UserId)
+
+	var user models.User
+	err := w.DB.First(&user, job.Args.UserId).Error
+	if err != nil {
+		return err
+	}
+
+	html, err := email.LoginLockoutEmailTemplateParams{
+		User:               user,
+		LockedUntil:        job.Args.LockedUntil,
+		FrontendUrl:        w.Config.FrontendUrl,
+		ServiceName:        constants.ServiceName,
+		ServiceDescription: constants.ServiceDescription,
+	}.ApplyHtmlTemplate()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = email.SendEmail(email.SendEmailRequest{
+		Params: email.SendEmailParams{
+			From:    string(constants.EmailSenderDefault),
+			To:      []string{user.Email},
+			Subject: "Your " + constants.ServiceName + " account has been locked",
+			Html:    html,
+		},
+		ResendClient: w.ResendClient,
+		Config:       w.Config,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (w *LoginLockoutEmailJobWorker) Timeout(*river.Job[LoginLockoutEmailJobArgs]) time.Duration {
+	return 180 * time.Second
+}
@@ -0,0 +1,58 @@
+package users
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resend/resend-go/v2"
+	"github.com/riverqueue/river"
+	"gorm.io/gorm"
+	"reece.start/internal/configuration"
+	"reece.start/internal/constants"
+	"reece.start/internal/email"
+	"reece.start/internal/models"
+)
+
+type MagicLinkEmailJobArgs struct {
+	UserId uuid.UUID `json:"userId"`
+}
+
+func (MagicLinkEmailJobArgs) Kind() string {
+	return string(constants.JobKindMagicLinkEmail)
+}
+
+type MagicLinkEmailJobWorker struct {
+	river.WorkerDefaults[MagicLinkEmailJobArgs]
+	DB           *gorm.DB
+	Config       *configuration.Config
+	ResendClient *resend.Client
+}
+
+func (w *MagicLinkEmailJobWorker) Work(ctx context.Context, job *river.Job[MagicLinkEmailJobArgs]) error {
+	slog.Info("Sending magic link email", "userId", job.Args.UserId)
+
+	return sendLinkTokenEmail(ctx, w.DB, w.Config, w.ResendClient, job.Args.UserId, linkTokenEmail{
+		Model: &models.MagicLinkToken{},
+		NewToken: func(userId uuid.UUID, tokenHash string, expiresAt time.Time) any {
+			return &models.MagicLinkToken{UserID: userId, TokenHash: tokenHash, ExpiresAt: expiresAt}
+		},
+		Expiration: time.Duration(w.Config.MagicLinkTokenExpirationTime) * time.Second,
+		Subject:    "Sign in to " + constants.ServiceName,
+		ApplyHtmlTemplate: func(user models.User, token string, expiresAt time.Time) (string, error) {
+			return email.MagicLinkEmailTemplateParams{
+				User:               user,
+				Token:              token,
+				ExpiresAt:          expiresAt,
+				FrontendUrl:        w.Config.FrontendUrl,
+				ServiceName:        constants.ServiceName,
+				ServiceDescription: constants.ServiceDescription,
+			}.ApplyHtmlTemplate()
+		},
+	})
+}
+
+func (w *MagicLinkEmailJobWorker) Timeout(*river.Job[MagicLinkEmailJobArgs]) time.Duration {
+	return 180 * time.Second
+}
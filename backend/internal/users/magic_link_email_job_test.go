@@ -0,0 +1,203 @@
+package users_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"reece.start/internal/authentication"
+	"reece.start/internal/constants"
+	"reece.start/internal/models"
+	"reece.start/test"
+)
+
+func requestMagicLink(tc *test.TestContext, email string) int {
+	reqBody := map[string]interface{}{
+		"data": map[string]interface{}{
+			"attributes": map[string]interface{}{
+				"email": email,
+			},
+		},
+	}
+
+	return tc.MakeRequest(http.MethodPost, "/users/login/magic-link/request", reqBody, nil).Code
+}
+
+// createMagicLinkToken stores a magic link token for the user. The emailed token is only created when the email job
+// runs and only its hash is stored, so tests make their own.
+func createMagicLinkToken(t *testing.T, tc *test.TestContext, user *models.User) string {
+	token, tokenHash, err := authentication.GenerateOpaqueToken()
+	require.NoError(t, err)
+
+	require.NoError(t, tc.DB.Create(&models.MagicLinkToken{
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(time.Duration(tc.Config.MagicLinkTokenExpirationTime) * time.Second),
+	}).Error)
+
+	return token
+}
+
+func loginWithMagicLink(tc *test.TestContext, token string) (int, map[string]interface{}) {
+	reqBody := map[string]interface{}{
+		"data": map[string]interface{}{
+			"attributes": map[string]interface{}{
+				"token": token,
+			},
+		},
+	}
+
+	rec := tc.MakeRequest(http.MethodPost, "/users/login/magic-link", reqBody, nil)
+
+	var response map[string]interface{}
+	tc.UnmarshalResponse(rec, &response)
+	return rec.Code, response
+}
+
+func TestMagicLinkEmailJob(t *testing.T) {
+	t.Run("EnqueuedOnMagicLinkRequest", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, _ := test.CreateTestUser(t, tc)
+
+		require.Equal(t, http.StatusAccepted, requestMagicLink(tc, user.Email))
+
+		// The token is created when the email is sent, so it isn't stored in the job
+		var args string
+		err := tc.DB.Raw(`SELECT args FROM river_job WHERE kind = ?`, string(constants.JobKindMagicLinkEmail)).Scan(&args).Error
+		require.NoError(t, err)
+		assert.NotContains(t, args, "token")
+
+		var tokenCount int64
+		require.NoError(t, tc.DB.Model(&models.MagicLinkToken{}).Where("user_id = ?", user.ID).Count(&tokenCount).Error)
+		assert.Equal(t, int64(0), tokenCount)
+	})
+
+	t.Run("NotEnqueuedForUnknownEmail", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+
+		require.Equal(t, http.StatusAccepted, requestMagicLink(tc, "nobody@example.com"))
+
+		var jobCount int64
+		err := tc.DB.Raw(`SELECT COUNT(*) FROM river_job WHERE kind = ?`, string(constants.JobKindMagicLinkEmail)).Scan(&jobCount).Error
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), jobCount)
+	})
+
+	t.Run("LimitsEmailsPerAddress", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+
+		// Unknown emails are counted too, so the limit doesn't reveal which emails are registered
+		for range tc.Config.EmailLinkRequestAddressLimit {
+			require.Equal(t, http.StatusAccepted, requestMagicLink(tc, "nobody@example.com"))
+		}
+		assert.Equal(t, http.StatusTooManyRequests, requestMagicLink(tc, "nobody@example.com"))
+
+		// Password reset emails count towards the same limit
+		reqBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"attributes": map[string]interface{}{
+					"email": "nobody@example.com",
+				},
+			},
+		}
+		rec := tc.MakeRequest(http.MethodPost, "/users/password-reset", reqBody, nil)
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+		// Other addresses aren't affected
+		assert.Equal(t, http.StatusAccepted, requestMagicLink(tc, "somebody@example.com"))
+	})
+
+	t.Run("ExecutesSuccessfully", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, _ := test.CreateTestUser(t, tc)
+
+		require.Equal(t, http.StatusAccepted, requestMagicLink(tc, user.Email))
+		test.RunAllPendingRiverJobs(t, tc.DB, tc.RiverClient)
+
+		var completedJobCount int64
+		err := tc.DB.Raw(`
+		SELECT COUNT(*)
+		FROM river_job
+		WHERE kind = ? AND args->>'userId' = ? AND state = 'completed'
+	`, string(constants.JobKindMagicLinkEmail), user.ID.String()).Scan(&completedJobCount).Error
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), completedJobCount)
+
+		var tokenCount int64
+		require.NoError(t, tc.DB.Model(&models.MagicLinkToken{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&tokenCount).Error)
+		assert.Equal(t, int64(1), tokenCount)
+	})
+}
+
+func TestLoginMagicLinkEndpoint(t *testing.T) {
+	t.Run("IssuesSessionToken", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, _ := test.CreateTestUserWithOptions(t, tc, test.TestUserOptions{UnverifiedEmail: true})
+
+		token := createMagicLinkToken(t, tc, user)
+
+		code, response := loginWithMagicLink(tc, token)
+		require.Equal(t, http.StatusOK, code)
+
+		meta := response["data"].(map[string]interface{})["meta"].(map[string]interface{})
+		sessionToken := meta["token"].(string)
+		assert.NotEmpty(t, meta["refreshToken"])
+
+		rec := tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me", nil, sessionToken)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		// Opening the link verifies the email
+		var updatedUser models.User
+		require.NoError(t, tc.DB.First(&updatedUser, user.ID).Error)
+		assert.NotNil(t, updatedUser.EmailVerifiedAt)
+	})
+
+	t.Run("TokenCanOnlyBeUsedOnce", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, _ := test.CreateTestUser(t, tc)
+
+		token := createMagicLinkToken(t, tc, user)
+
+		code, _ := loginWithMagicLink(tc, token)
+		assert.Equal(t, http.StatusOK, code)
+
+		code, _ = loginWithMagicLink(tc, token)
+		assert.Equal(t, http.StatusUnauthorized, code)
+	})
+
+	t.Run("NewRequestInvalidatesPreviousToken", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, _ := test.CreateTestUser(t, tc)
+
+		token := createMagicLinkToken(t, tc, user)
+
+		require.Equal(t, http.StatusAccepted, requestMagicLink(tc, user.Email))
+		test.RunAllPendingRiverJobs(t, tc.DB, tc.RiverClient)
+
+		code, _ := loginWithMagicLink(tc, token)
+		assert.Equal(t, http.StatusUnauthorized, code)
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+
+		code, _ := loginWithMagicLink(tc, "not-a-real-token")
+		assert.Equal(t, http.StatusUnauthorized, code)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, _ := test.CreateTestUser(t, tc)
+
+		token := createMagicLinkToken(t, tc, user)
+
+		tc.Config.EnableMagicLinkLogin = false
+
+		assert.Equal(t, http.StatusForbidden, requestMagicLink(tc, user.Email))
+
+		code, _ := loginWithMagicLink(tc, token)
+		assert.Equal(t, http.StatusForbidden, code)
+	})
+}
@@ -0,0 +1,61 @@
+package users
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"reece.start/internal/middleware"
+)
+
+// RequestMagicLinkEndpoint always responds with 202, so it can't be used to find out which emails are registered
+func RequestMagicLinkEndpoint(c echo.Context, req RequestMagicLinkRequest) error {
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+	riverClient := middleware.GetRiverClient(c)
+
+	err := db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		return requestMagicLink(RequestMagicLinkServiceRequest{
+			Params: RequestMagicLinkParams{
+				Email:     req.Data.Attributes.Email,
+				IpAddress: c.RealIP(),
+			},
+			Tx:          tx,
+			Config:      config,
+			RiverClient: riverClient,
+		})
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+func LoginMagicLinkEndpoint(c echo.Context, req LoginMagicLinkRequest) error {
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+	minioClient := middleware.GetMinioClient(c)
+
+	var user *UserDto
+	err := db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		user, err = loginUserWithMagicLink(LoginMagicLinkServiceRequest{
+			Params: LoginMagicLinkParams{
+				Token:  req.Data.Attributes.Token,
+				Client: getSessionClient(c),
+			},
+			Tx:          tx,
+			Config:      config,
+			MinioClient: minioClient,
+		})
+		return err
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusOK, mapUserToResponse(user))
+}
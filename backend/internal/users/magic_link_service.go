@@ -0,0 +1,151 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"reece.start/internal/api"
+	"reece.start/internal/authentication"
+	"reece.start/internal/models"
+	"reece.start/internal/utils"
+)
+
+// requestMagicLink emails a single use sign in link to the user with the given email.
+// Like login, callers shouldn't reveal whether the email is registered, so nothing happens if there is no such user.
+func requestMagicLink(request RequestMagicLinkServiceRequest) error {
+	tx := request.Tx
+	params := request.Params
+	config := request.Config
+	riverClient := request.RiverClient
+
+	if !config.EnableMagicLinkLogin {
+		return api.ErrMagicLinkLoginDisabled
+	}
+
+	err := throttleEmailLinkRequest(tx, config, params.Email, params.IpAddress)
+	if err != nil {
+		return err
+	}
+
+	var user models.User
+	err = tx.Where("email = ?", params.Email).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.Info("Magic link requested for unknown email")
+			return nil
+		}
+		return err
+	}
+
+	// Enqueue background job to send the magic link email, the token is created when it is sent
+	sqlTx := utils.GetGormSQLTx(tx)
+	_, err = riverClient.InsertTx(tx.Statement.Context, sqlTx, MagicLinkEmailJobArgs{
+		UserId: user.ID,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue magic link email job: %w", err)
+	}
+
+	slog.Info("Enqueued magic link email job", "userID", user.ID)
+
+	return nil
+}
+
+// loginUserWithMagicLink signs the user in with a magic link token.
+// The link stands in for the password, so users with MFA enabled still get a challenge token instead of a session.
+func loginUserWithMagicLink(request LoginMagicLinkServiceRequest) (*UserDto, error) {
+	tx := request.Tx
+	params := request.Params
+	config := request.Config
+	minioClient := request.MinioClient
+
+	if !config.EnableMagicLinkLogin {
+		return nil, api.ErrMagicLinkLoginDisabled
+	}
+
+	var magicLinkToken models.MagicLinkToken
+	err := tx.Where("token_hash = ?", authentication.HashOpaqueToken(params.Token)).First(&magicLinkToken).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrInvalidMagicLinkToken
+		}
+		return nil, err
+	}
+
+	if magicLinkToken.UsedAt != nil || time.Now().After(magicLinkToken.ExpiresAt) {
+		return nil, api.ErrInvalidMagicLinkToken
+	}
+
+	// Mark the token as used, only one request can succeed if the link is opened concurrently
+	result := tx.Model(&models.MagicLinkToken{}).Where("id = ? AND used_at IS NULL", magicLinkToken.ID).Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, api.ErrInvalidMagicLinkToken
+	}
+
+	var user models.User
+	if err := tx.First(&user, magicLinkToken.UserID).Error; err != nil {
+		return nil, err
+	}
+
+	err = checkSsoNotRequired(tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Opening the link proves the user controls their email
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		if err := tx.Model(&user).Update("email_verified_at", now).Error; err != nil {
+			return nil, err
+		}
+		user.EmailVerifiedAt = &now
+	}
+
+	if user.Mfa.Enabled {
+		mfaChallengeToken, err := authentication.CreateMfaChallengeJWT(config, user.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		return &UserDto{
+			User:              &user,
+			MfaChallengeToken: mfaChallengeToken,
+		}, nil
+	}
+
+	// Generate the access and refresh tokens for the user
+	tokens, err := createAuthenticatedUserToken(CreateAuthenticatedUserTokenServiceRequest{
+		Params: CreateAuthenticatedUserTokenParams{
+			UserId: user.ID,
+			Client: params.Client,
+		},
+		Tx:     tx,
+		Config: config,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logoDistributionUrl, err := GetUserLogoDistributionUrl(GetUserLogoDistributionUrlServiceRequest{
+		UserID:      user.ID,
+		Tx:          tx,
+		MinioClient: minioClient,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserDto{
+		User:                &user,
+		Token:               tokens.AccessToken,
+		RefreshToken:        tokens.RefreshToken,
+		LogoDistributionUrl: logoDistributionUrl,
+	}, nil
+}
@@ -0,0 +1,154 @@
+package users
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"reece.start/internal/access"
+	"reece.start/internal/api"
+	"reece.start/internal/constants"
+	"reece.start/internal/middleware"
+)
+
+func LoginMfaEndpoint(c echo.Context, req LoginMfaRequest) error {
+	config := middleware.GetConfig(c)
+	db := middleware.GetDB(c)
+	minioClient := middleware.GetMinioClient(c)
+	riverClient := middleware.GetRiverClient(c)
+
+	user, err := loginUserWithMfa(LoginMfaServiceRequest{
+		Params: LoginMfaParams{
+			MfaChallengeToken: req.Data.Attributes.MfaChallengeToken,
+			Code:              req.Data.Attributes.Code,
+			RecoveryCode:      req.Data.Attributes.RecoveryCode,
+			Client:            getSessionClient(c),
+		},
+		Tx:          db,
+		Config:      config,
+		MinioClient: minioClient,
+		RiverClient: riverClient,
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusOK, mapUserToResponse(user))
+}
+
+func StartTotpEnrollmentEndpoint(c echo.Context) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+
+	enrollment, err := startTotpEnrollment(StartTotpEnrollmentServiceRequest{
+		UserID: userID,
+		Tx:     db,
+		Config: config,
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusOK, mapTotpEnrollmentToResponse(enrollment))
+}
+
+func ConfirmTotpEnrollmentEndpoint(c echo.Context, req ConfirmTotpEnrollmentRequest) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+
+	recoveryCodes, err := confirmTotpEnrollment(ConfirmTotpEnrollmentServiceRequest{
+		Params: ConfirmTotpEnrollmentParams{
+			UserID: userID,
+			Code:   req.Data.Attributes.Code,
+		},
+		Tx: db,
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusOK, mapMfaRecoveryCodesToResponse(recoveryCodes))
+}
+
+func RegenerateMfaRecoveryCodesEndpoint(c echo.Context, req RegenerateMfaRecoveryCodesRequest) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+
+	recoveryCodes, err := regenerateMfaRecoveryCodes(RegenerateMfaRecoveryCodesServiceRequest{
+		Params: RegenerateMfaRecoveryCodesParams{
+			UserID: userID,
+			Code:   req.Data.Attributes.Code,
+		},
+		Tx: db,
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusOK, mapMfaRecoveryCodesToResponse(recoveryCodes))
+}
+
+func DisableMfaEndpoint(c echo.Context, req DisableMfaRequest) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+
+	err = disableMfa(DisableMfaServiceRequest{
+		Params: DisableMfaParams{
+			UserID:       userID,
+			Code:         req.Data.Attributes.Code,
+			RecoveryCode: req.Data.Attributes.RecoveryCode,
+		},
+		Tx: db,
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ResetUserMfaEndpoint lets an admin turn off MFA for a user who lost both their authenticator and recovery codes
+func ResetUserMfaEndpoint(c echo.Context) error {
+	if err := access.HasAdminAccess(c, []constants.UserScope{constants.UserScopeAdminUsersMfaReset}); err != nil {
+		return err
+	}
+
+	paramUserID, err := api.ParseUserIDFromString(c.Param("id"))
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+
+	err = resetUserMfa(ResetUserMfaServiceRequest{
+		UserID: paramUserID,
+		Tx:     db,
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,318 @@
+package users
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"reece.start/internal/api"
+	"reece.start/internal/authentication"
+	"reece.start/internal/models"
+)
+
+func startTotpEnrollment(request StartTotpEnrollmentServiceRequest) (*TotpEnrollmentDto, error) {
+	tx := request.Tx
+	config := request.Config
+
+	user, err := getMfaUser(tx, request.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Mfa.Enabled {
+		return nil, api.ErrMfaAlreadyEnabled
+	}
+
+	// Starting over replaces any secret from a previous enrollment that was never confirmed
+	secret, err := authentication.GenerateTotpSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Model(user).Updates(map[string]any{
+		"mfa_totp_secret":    secret,
+		"mfa_last_used_step": 0,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &TotpEnrollmentDto{
+		Secret:     secret,
+		OtpauthUri: authentication.GetTotpUri(config.MfaTotpIssuer, user.Email, secret),
+	}, nil
+}
+
+func confirmTotpEnrollment(request ConfirmTotpEnrollmentServiceRequest) (*MfaRecoveryCodesDto, error) {
+	tx := request.Tx
+	params := request.Params
+
+	user, err := getMfaUser(tx, params.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Mfa.Enabled {
+		return nil, api.ErrMfaAlreadyEnabled
+	}
+
+	if user.Mfa.TotpSecret == "" {
+		return nil, api.ErrMfaEnrollmentNotStarted
+	}
+
+	err = verifyTotpCode(tx, user, params.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Model(user).Update("mfa_enabled", true).Error
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := replaceMfaRecoveryCodes(tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MfaRecoveryCodesDto{Codes: codes}, nil
+}
+
+func regenerateMfaRecoveryCodes(request RegenerateMfaRecoveryCodesServiceRequest) (*MfaRecoveryCodesDto, error) {
+	tx := request.Tx
+	params := request.Params
+
+	user, err := getMfaUser(tx, params.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.Mfa.Enabled {
+		return nil, api.ErrMfaNotEnabled
+	}
+
+	err = verifyTotpCode(tx, user, params.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := replaceMfaRecoveryCodes(tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MfaRecoveryCodesDto{Codes: codes}, nil
+}
+
+func disableMfa(request DisableMfaServiceRequest) error {
+	tx := request.Tx
+	params := request.Params
+
+	user, err := getMfaUser(tx, params.UserID)
+	if err != nil {
+		return err
+	}
+
+	if !user.Mfa.Enabled {
+		return api.ErrMfaNotEnabled
+	}
+
+	err = verifyMfaCode(tx, user, params.Code, params.RecoveryCode)
+	if err != nil {
+		return err
+	}
+
+	return clearMfa(tx, user.ID)
+}
+
+func resetUserMfa(request ResetUserMfaServiceRequest) error {
+	tx := request.Tx
+
+	user, err := getMfaUser(tx, request.UserID)
+	if err != nil {
+		return err
+	}
+
+	return clearMfa(tx, user.ID)
+}
+
+func loginUserWithMfa(request LoginMfaServiceRequest) (*UserDto, error) {
+	tx := request.Tx
+	params := request.Params
+	config := request.Config
+	minioClient := request.MinioClient
+
+	userId, challengeIssuedAt, err := authentication.ValidateMfaChallengeJWT(config, params.MfaChallengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := getMfaUser(tx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.Mfa.Enabled {
+		return nil, api.ErrMfaNotEnabled
+	}
+
+	// Signing in with the password again gets a new challenge, so invalid codes are counted against the user instead
+	err = checkMfaThrottle(tx, user.ID, challengeIssuedAt, params.Client.IpAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	err = verifyMfaCode(tx, user, params.Code, params.RecoveryCode)
+	if err != nil {
+		if errors.Is(err, api.ErrMfaInvalidCode) {
+			recordErr := recordFailedMfaCode(tx, config, request.RiverClient, user.ID, params.Client.IpAddress)
+			if recordErr != nil {
+				return nil, recordErr
+			}
+		}
+		return nil, err
+	}
+
+	// Forget the user's invalid codes, like a password login forgets the failed passwords
+	err = tx.Unscoped().
+		Where("scope = ? AND target = ?", loginThrottleScopeMfa, user.ID.String()).
+		Delete(&models.LoginThrottle{}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate the access and refresh tokens for the user
+	tokens, err := createAuthenticatedUserToken(CreateAuthenticatedUserTokenServiceRequest{
+		Params: CreateAuthenticatedUserTokenParams{
+			UserId: user.ID,
+			Client: params.Client,
+		},
+		Tx:     tx,
+		Config: config,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logoDistributionUrl, err := GetUserLogoDistributionUrl(GetUserLogoDistributionUrlServiceRequest{
+		UserID:      user.ID,
+		Tx:          tx,
+		MinioClient: minioClient,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserDto{
+		User:                user,
+		Token:               tokens.AccessToken,
+		RefreshToken:        tokens.RefreshToken,
+		LogoDistributionUrl: logoDistributionUrl,
+	}, nil
+}
+
+func getMfaUser(tx *gorm.DB, userId uuid.UUID) (*models.User, error) {
+	var user models.User
+	err := tx.First(&user, userId).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// verifyMfaCode accepts either a TOTP code or an unused recovery code
+func verifyMfaCode(tx *gorm.DB, user *models.User, code string, recoveryCode string) error {
+	if code != "" {
+		return verifyTotpCode(tx, user, code)
+	}
+
+	if recoveryCode != "" {
+		return useMfaRecoveryCode(tx, user.ID, recoveryCode)
+	}
+
+	return api.ErrMfaInvalidCode
+}
+
+// verifyTotpCode checks the code against the user's secret and records its time step so the same code can't be used twice
+func verifyTotpCode(tx *gorm.DB, user *models.User, code string) error {
+	step, ok := authentication.ValidateTotpCode(user.Mfa.TotpSecret, code, time.Now(), user.Mfa.LastUsedStep)
+	if !ok {
+		return api.ErrMfaInvalidCode
+	}
+
+	// Only update if no other request has used this (or a later) step in the meantime
+	result := tx.Model(&models.User{}).Where("id = ? AND mfa_last_used_step < ?", user.ID, step).Update("mfa_last_used_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return api.ErrMfaInvalidCode
+	}
+
+	user.Mfa.LastUsedStep = step
+	return nil
+}
+
+// useMfaRecoveryCode marks a recovery code as used, failing if it doesn't exist or was already used
+func useMfaRecoveryCode(tx *gorm.DB, userId uuid.UUID, recoveryCode string) error {
+	result := tx.Model(&models.MfaRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userId, authentication.HashRecoveryCode(recoveryCode)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return api.ErrMfaInvalidCode
+	}
+
+	return nil
+}
+
+// replaceMfaRecoveryCodes invalidates the user's existing recovery codes and returns a new set.
+// The plaintext codes are only ever returned here, only their hashes are stored.
+func replaceMfaRecoveryCodes(tx *gorm.DB, userId uuid.UUID) ([]string, error) {
+	err := tx.Unscoped().Where("user_id = ?", userId).Delete(&models.MfaRecoveryCode{}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := authentication.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	recoveryCodes := make([]models.MfaRecoveryCode, 0, len(hashes))
+	for _, hash := range hashes {
+		recoveryCodes = append(recoveryCodes, models.MfaRecoveryCode{
+			UserID:   userId,
+			CodeHash: hash,
+		})
+	}
+
+	err = tx.Create(&recoveryCodes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// clearMfa turns off MFA for the user and removes their secret and recovery codes
+func clearMfa(tx *gorm.DB, userId uuid.UUID) error {
+	err := tx.Model(&models.User{}).Where("id = ?", userId).Updates(map[string]any{
+		"mfa_enabled":        false,
+		"mfa_totp_secret":    "",
+		"mfa_last_used_step": 0,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Unscoped().Where("user_id = ?", userId).Delete(&models.MfaRecoveryCode{}).Error
+}
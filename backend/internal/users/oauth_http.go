@@ -0,0 +1,155 @@
+package users
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"reece.start/internal/api"
+	"reece.start/internal/middleware"
+	"reece.start/internal/models"
+)
+
+// StartOAuthAuthorizationEndpoint starts an OAuth authorization, returning the provider URL to send the user to.
+// The returned state should be kept by the client (e.g. in a cookie) and compared with the state on the callback.
+func StartOAuthAuthorizationEndpoint(c echo.Context, req StartOAuthAuthorizationRequest) error {
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+
+	var authorization *OAuthAuthorizationDto
+	err := db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		authorization, err = startOAuthAuthorization(StartOAuthAuthorizationServiceRequest{
+			Params: StartOAuthAuthorizationParams{
+				Provider:    c.Param("provider"),
+				RedirectUri: req.Data.Attributes.RedirectUri,
+			},
+			Tx:     tx,
+			Config: config,
+		})
+		return err
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusCreated, mapOAuthAuthorizationToResponse(authorization))
+}
+
+func OAuthCallbackEndpoint(c echo.Context, req OAuthCallbackRequest) error {
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+	minioClient := middleware.GetMinioClient(c)
+	posthogClient := middleware.GetPostHogClient(c)
+
+	var user *UserDto
+	err := db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		user, err = oauthCallback(OAuthCallbackServiceRequest{
+			Params: OAuthCallbackParams{
+				Provider:    c.Param("provider"),
+				Code:        req.Data.Attributes.Code,
+				State:       req.Data.Attributes.State,
+				RedirectUri: req.Data.Attributes.RedirectUri,
+				Client:      getSessionClient(c),
+			},
+			Tx:            tx,
+			Config:        config,
+			MinioClient:   minioClient,
+			PostHogClient: posthogClient,
+		})
+		return err
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusOK, mapUserToResponse(user))
+}
+
+func GetUserIdentitiesEndpoint(c echo.Context) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+
+	identities, err := getUserIdentities(GetUserIdentitiesServiceRequest{
+		UserID: userID,
+		Tx:     db,
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusOK, mapUserIdentitiesToResponse(identities))
+}
+
+func LinkUserIdentityEndpoint(c echo.Context, req OAuthCallbackRequest) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+	riverClient := middleware.GetRiverClient(c)
+
+	var identity *models.UserIdentity
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		identity, err = linkUserIdentity(LinkUserIdentityServiceRequest{
+			Params: LinkUserIdentityParams{
+				UserID:      userID,
+				Provider:    c.Param("provider"),
+				Code:        req.Data.Attributes.Code,
+				State:       req.Data.Attributes.State,
+				RedirectUri: req.Data.Attributes.RedirectUri,
+				// There's no password to enter when coming back from the provider, so the user has to have signed in recently
+				Reauthentication: getReauthenticationParams(c, ""),
+			},
+			Tx:          tx,
+			Config:      config,
+			RiverClient: riverClient,
+		})
+		return err
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusCreated, mapUserIdentityToResponse(identity))
+}
+
+func DeleteUserIdentityEndpoint(c echo.Context) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	identityID, err := api.ParseUserIdentityIDFromParams(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		return deleteUserIdentity(DeleteUserIdentityServiceRequest{
+			UserID:     userID,
+			IdentityID: identityID,
+			Tx:         tx,
+		})
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,386 @@
+package users
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"reece.start/internal/api"
+	"reece.start/internal/authentication"
+	"reece.start/internal/constants"
+	"reece.start/internal/models"
+)
+
+// How long a user has to complete an OAuth authorization at the provider
+const oauthStateExpiry = 10 * time.Minute
+
+// startOAuthAuthorization creates the state for a new OAuth authorization and returns the URL to send the user to
+func startOAuthAuthorization(request StartOAuthAuthorizationServiceRequest) (*OAuthAuthorizationDto, error) {
+	tx := request.Tx
+	config := request.Config
+	params := request.Params
+
+	provider, err := authentication.GetOAuthProvider(config, params.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	if !authentication.IsAllowedOAuthRedirectUri(config, params.RedirectUri) {
+		return nil, api.ErrOAuthRedirectUriNotAllowed
+	}
+
+	authorization, err := authentication.NewOAuthAuthorization()
+	if err != nil {
+		return nil, err
+	}
+
+	authorizationUrl, err := provider.AuthCodeURL(tx.Statement.Context, params.RedirectUri, authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	// Clean up authorizations that were never finished
+	err = tx.Unscoped().Where("expires_at < ?", time.Now()).Delete(&models.OAuthState{}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	state := models.OAuthState{
+		Provider:     provider.Name,
+		StateHash:    authentication.HashOpaqueToken(authorization.State),
+		RedirectUri:  params.RedirectUri,
+		CodeVerifier: authorization.CodeVerifier,
+		Nonce:        authorization.Nonce,
+		ExpiresAt:    time.Now().Add(oauthStateExpiry),
+	}
+	err = tx.Create(&state).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &OAuthAuthorizationDto{
+		AuthorizationUrl: authorizationUrl,
+		State:            authorization.State,
+		ExpiresAt:        state.ExpiresAt,
+	}, nil
+}
+
+// consumeOAuthState loads and deletes the state of an authorization, so each authorization can only be completed once.
+// The callback has to be for the same provider and redirect URI the authorization was started with.
+func consumeOAuthState(tx *gorm.DB, provider string, state string, redirectUri string) (*authentication.OAuthAuthorization, error) {
+	var oauthState models.OAuthState
+	err := tx.Where("state_hash = ? AND provider = ?", authentication.HashOpaqueToken(state), provider).First(&oauthState).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrOAuthStateInvalid
+		}
+		return nil, err
+	}
+
+	result := tx.Unscoped().Delete(&oauthState)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 || time.Now().After(oauthState.ExpiresAt) || oauthState.RedirectUri != redirectUri {
+		return nil, api.ErrOAuthStateInvalid
+	}
+
+	return &authentication.OAuthAuthorization{
+		State:        state,
+		CodeVerifier: oauthState.CodeVerifier,
+		Nonce:        oauthState.Nonce,
+	}, nil
+}
+
+// oauthCallback signs the user in with an OAuth provider, creating the user or linking the identity to an existing
+// user with the same email if this is the first time the identity is used
+func oauthCallback(request OAuthCallbackServiceRequest) (*UserDto, error) {
+	tx := request.Tx
+	config := request.Config
+	minioClient := request.MinioClient
+	posthogClient := request.PostHogClient
+	params := request.Params
+
+	provider, err := authentication.GetOAuthProvider(config, params.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	authorization, err := consumeOAuthState(tx, provider.Name, params.State, params.RedirectUri)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := provider.Exchange(tx.Statement.Context, params.Code, params.RedirectUri, authorization)
+	if err != nil {
+		slog.Warn("OAuth code exchange failed", "provider", params.Provider, "error", err)
+		return nil, api.ErrUnauthorizedInvalidLogin
+	}
+
+	// Check if the identity has been used before
+	var identity models.UserIdentity
+	err = tx.Where("provider = ? AND subject = ?", provider.Name, profile.Subject).First(&identity).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var user models.User
+
+	if err == nil {
+		if err := tx.First(&user, identity.UserID).Error; err != nil {
+			return nil, err
+		}
+
+		// Keep the identity up to date with the provider
+		identity.Email = profile.Email
+		identity.ProfileImage = profile.ProfileImage
+		if err := tx.Save(&identity).Error; err != nil {
+			return nil, err
+		}
+	} else {
+		if profile.Email == "" {
+			slog.Warn("OAuth profile has no email", "provider", provider.Name)
+			return nil, api.ErrUnauthorizedInvalidLogin
+		}
+
+		err = tx.Where("email = ?", profile.Email).First(&user).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+
+		if err == nil {
+			// Link to the existing user with the same email. Both sides have to have verified the address, otherwise
+			// whoever registered it first (or controls the provider account) could take over the other account.
+			if user.EmailVerifiedAt == nil || !profile.EmailVerified {
+				return nil, api.ErrEmailNotVerified
+			}
+		} else {
+			// Create new user
+			var emailVerifiedAt *time.Time
+			if profile.EmailVerified {
+				now := time.Now()
+				emailVerifiedAt = &now
+			}
+
+			user = models.User{
+				Name:            profile.Name,
+				Email:           profile.Email,
+				HashedPassword:  nil, // OAuth users don't have passwords
+				EmailVerifiedAt: emailVerifiedAt,
+			}
+
+			if user.Name == "" {
+				user.Name = profile.Email
+			}
+
+			if err := tx.Create(&user).Error; err != nil {
+				return nil, err
+			}
+
+			// Log user created event to PostHog (OAuth signup)
+			posthogClient.Capture(
+				user.ID.String(),
+				"user created",
+				map[string]any{
+					"user_id":       user.ID.String(),
+					"email":         user.Email,
+					"name":          user.Name,
+					"signup_method": "oauth_" + provider.Name,
+				},
+			)
+		}
+
+		identity = models.UserIdentity{
+			UserID:       user.ID,
+			Provider:     provider.Name,
+			Subject:      profile.Subject,
+			Email:        profile.Email,
+			ProfileImage: profile.ProfileImage,
+		}
+
+		if err := tx.Create(&identity).Error; err != nil {
+			return nil, err
+		}
+
+		slog.Info("Linked OAuth identity to user", "userID", user.ID, "provider", provider.Name)
+	}
+
+	err = checkSsoNotRequired(tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The provider only stands in for the password, users with MFA enabled still have to enter a code
+	if user.Mfa.Enabled {
+		mfaChallengeToken, err := authentication.CreateMfaChallengeJWT(config, user.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		return &UserDto{
+			User:              &user,
+			MfaChallengeToken: mfaChallengeToken,
+		}, nil
+	}
+
+	// Generate the access and refresh tokens for the user
+	tokens, err := createAuthenticatedUserToken(CreateAuthenticatedUserTokenServiceRequest{
+		Params: CreateAuthenticatedUserTokenParams{
+			UserId: user.ID,
+			Client: params.Client,
+		},
+		Tx:     tx,
+		Config: config,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the logo distribution URL (prefers an uploaded logo over identity profile images)
+	logoDistributionUrl, err := GetUserLogoDistributionUrl(GetUserLogoDistributionUrlServiceRequest{
+		UserID:      user.ID,
+		Tx:          tx,
+		MinioClient: minioClient,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserDto{
+		User:                &user,
+		Token:               tokens.AccessToken,
+		RefreshToken:        tokens.RefreshToken,
+		LogoDistributionUrl: logoDistributionUrl,
+	}, nil
+}
+
+func getUserIdentities(request GetUserIdentitiesServiceRequest) ([]models.UserIdentity, error) {
+	tx := request.Tx
+
+	var identities []models.UserIdentity
+	err := tx.Where("user_id = ?", request.UserID).Order("created_at ASC").Find(&identities).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return identities, nil
+}
+
+// linkUserIdentity finishes an OAuth authorization started by a signed in user, and links the provider account to them.
+// Unlike signing in, the emails don't have to match, since the user has proven they control both accounts.
+func linkUserIdentity(request LinkUserIdentityServiceRequest) (*models.UserIdentity, error) {
+	tx := request.Tx
+	config := request.Config
+	params := request.Params
+
+	var user models.User
+	err := tx.First(&user, params.UserID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// A linked account can be used to sign in, so linking one needs the same checks as changing the password
+	err = checkCredentialChange(tx, config, &user, params.Reauthentication)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := authentication.GetOAuthProvider(config, params.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	authorization, err := consumeOAuthState(tx, provider.Name, params.State, params.RedirectUri)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := provider.Exchange(tx.Statement.Context, params.Code, params.RedirectUri, authorization)
+	if err != nil {
+		slog.Warn("OAuth code exchange failed", "provider", params.Provider, "error", err)
+		return nil, api.ErrUnauthorizedInvalidLogin
+	}
+
+	var identity models.UserIdentity
+	err = tx.Where("provider = ? AND subject = ?", provider.Name, profile.Subject).First(&identity).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	linked := err != nil
+
+	if !linked {
+		if identity.UserID != params.UserID {
+			return nil, api.ErrUserIdentityLinked
+		}
+	} else {
+		identity = models.UserIdentity{
+			UserID:   params.UserID,
+			Provider: provider.Name,
+			Subject:  profile.Subject,
+		}
+	}
+
+	identity.Email = profile.Email
+	identity.ProfileImage = profile.ProfileImage
+	if err := tx.Save(&identity).Error; err != nil {
+		if api.IsUniqueConstraintViolation(err) {
+			return nil, api.ErrUserIdentityLinked
+		}
+		return nil, err
+	}
+
+	// Linking an account that's already linked only refreshes its profile
+	if linked {
+		slog.Info("Linked OAuth identity to user", "userID", params.UserID, "provider", provider.Name)
+
+		err = enqueueCredentialChangeEmail(tx, request.RiverClient, CredentialChangeEmailJobArgs{
+			UserId:    user.ID,
+			Change:    constants.CredentialChangeIdentity,
+			Email:     user.Email,
+			Detail:    provider.Name,
+			ChangedAt: time.Now(),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &identity, nil
+}
+
+// deleteUserIdentity unlinks a provider account from the user, as long as the user can still sign in some other way
+func deleteUserIdentity(request DeleteUserIdentityServiceRequest) error {
+	tx := request.Tx
+
+	// Lock the user so concurrent requests can't remove the user's last login methods at the same time
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, request.UserID).Error; err != nil {
+		return err
+	}
+
+	var identity models.UserIdentity
+	err := tx.Where("id = ? AND user_id = ?", request.IdentityID, request.UserID).First(&identity).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return api.ErrUserIdentityNotFound
+		}
+		return err
+	}
+
+	canSignIn, err := hasOtherLoginMethod(tx, &user, identity.ID, uuid.Nil)
+	if err != nil {
+		return err
+	}
+
+	if !canSignIn {
+		return api.ErrLastLoginMethod
+	}
+
+	return tx.Unscoped().Delete(&identity).Error
+}
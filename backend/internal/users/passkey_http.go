@@ -0,0 +1,194 @@
+package users
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"reece.start/internal/api"
+	"reece.start/internal/middleware"
+)
+
+func BeginPasskeyLoginEndpoint(c echo.Context) error {
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+
+	challenge, err := beginPasskeyLogin(BeginPasskeyLoginServiceRequest{
+		Tx:     db,
+		Config: config,
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusOK, mapPasskeyChallengeToResponse(challenge))
+}
+
+func LoginPasskeyEndpoint(c echo.Context, req LoginPasskeyRequest) error {
+	challengeId, err := uuid.Parse(req.Data.Attributes.ChallengeId)
+	if err != nil {
+		return api.ErrPasskeyChallengeInvalid // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+	minioClient := middleware.GetMinioClient(c)
+
+	user, err := finishPasskeyLogin(FinishPasskeyLoginServiceRequest{
+		Params: FinishPasskeyLoginParams{
+			ChallengeId: challengeId,
+			Credential:  req.Data.Attributes.Credential,
+			Client:      getSessionClient(c),
+		},
+		Tx:          db,
+		Config:      config,
+		MinioClient: minioClient,
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusOK, mapUserToResponse(user))
+}
+
+func BeginPasskeyRegistrationEndpoint(c echo.Context) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+
+	challenge, err := beginPasskeyRegistration(BeginPasskeyRegistrationServiceRequest{
+		UserID: userID,
+		Tx:     db,
+		Config: config,
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusOK, mapPasskeyChallengeToResponse(challenge))
+}
+
+func CreatePasskeyEndpoint(c echo.Context, req CreatePasskeyRequest) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	challengeId, err := uuid.Parse(req.Data.Attributes.ChallengeId)
+	if err != nil {
+		return api.ErrPasskeyChallengeInvalid // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+	riverClient := middleware.GetRiverClient(c)
+
+	passkey, err := finishPasskeyRegistration(FinishPasskeyRegistrationServiceRequest{
+		Params: FinishPasskeyRegistrationParams{
+			UserID:           userID,
+			ChallengeId:      challengeId,
+			Name:             req.Data.Attributes.Name,
+			Credential:       req.Data.Attributes.Credential,
+			Reauthentication: getReauthenticationParams(c, req.Data.Attributes.CurrentPassword),
+		},
+		Tx:          db.WithContext(c.Request().Context()),
+		Config:      config,
+		RiverClient: riverClient,
+	})
+
+	recordErr := recordInvalidCurrentPassword(c, err, userID)
+	if recordErr != nil {
+		return recordErr
+	}
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusCreated, mapPasskeyToResponse(passkey))
+}
+
+func GetPasskeysEndpoint(c echo.Context) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+
+	passkeys, err := getPasskeys(GetPasskeysServiceRequest{
+		UserID: userID,
+		Tx:     db,
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusOK, mapPasskeysToResponse(passkeys))
+}
+
+func UpdatePasskeyEndpoint(c echo.Context, req UpdatePasskeyRequest) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	passkeyID, err := api.ParsePasskeyIDFromParams(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+
+	passkey, err := updatePasskey(UpdatePasskeyServiceRequest{
+		Params: UpdatePasskeyParams{
+			UserID:    userID,
+			PasskeyID: passkeyID,
+			Name:      req.Data.Attributes.Name,
+		},
+		Tx: db,
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusOK, mapPasskeyToResponse(passkey))
+}
+
+func DeletePasskeyEndpoint(c echo.Context) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	passkeyID, err := api.ParsePasskeyIDFromParams(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		return deletePasskey(DeletePasskeyServiceRequest{
+			UserID:    userID,
+			PasskeyID: passkeyID,
+			Tx:        tx,
+		})
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
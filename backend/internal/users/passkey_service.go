@@ -0,0 +1,399 @@
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"reece.start/internal/api"
+	"reece.start/internal/authentication"
+	"reece.start/internal/constants"
+	"reece.start/internal/models"
+)
+
+func beginPasskeyRegistration(request BeginPasskeyRegistrationServiceRequest) (*PasskeyChallengeDto, error) {
+	tx := request.Tx
+	config := request.Config
+
+	passkeyUser, err := getPasskeyUser(tx, request.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	webAuthn, err := authentication.NewWebAuthn(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Passkeys have to be discoverable so they can be used without entering an email first, and have to verify the user
+	// (biometrics or PIN) since they replace the password rather than adding a second factor to it
+	requireResidentKey := true
+	creation, session, err := webAuthn.BeginRegistration(
+		passkeyUser,
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			RequireResidentKey: &requireResidentKey,
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			UserVerification:   protocol.VerificationRequired,
+		}),
+		webauthn.WithExclusions(webauthn.Credentials(passkeyUser.WebAuthnCredentials()).CredentialDescriptors()),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	challengeId, err := savePasskeyChallenge(tx, &passkeyUser.User.ID, authentication.PasskeyCeremonyRegistration, session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PasskeyChallengeDto{
+		ChallengeId: challengeId,
+		Options:     creation,
+	}, nil
+}
+
+func finishPasskeyRegistration(request FinishPasskeyRegistrationServiceRequest) (*models.Passkey, error) {
+	tx := request.Tx
+	params := request.Params
+	config := request.Config
+
+	passkeyUser, err := getPasskeyUser(tx, params.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Passkeys replace the password, so adding one needs the same checks as changing it
+	err = checkCredentialChange(tx, config, passkeyUser.User, params.Reauthentication)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := consumePasskeyChallenge(tx, params.ChallengeId, &params.UserID, authentication.PasskeyCeremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+
+	webAuthn, err := authentication.NewWebAuthn(config)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedResponse, err := protocol.ParseCredentialCreationResponseBytes(params.Credential)
+	if err != nil {
+		slog.Info("Invalid passkey registration response", "error", err, "userID", params.UserID)
+		return nil, api.ErrPasskeyVerificationFailed
+	}
+
+	credential, err := webAuthn.CreateCredential(passkeyUser, *session, parsedResponse)
+	if err != nil {
+		slog.Info("Passkey registration failed verification", "error", err, "userID", params.UserID)
+		return nil, api.ErrPasskeyVerificationFailed
+	}
+
+	passkey := authentication.CredentialToPasskey(credential, passkeyUser.User, params.Name)
+
+	// The challenge stays used even if this fails, so only saving the passkey is done in a transaction
+	err = tx.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&passkey).Error
+		if err != nil {
+			if api.IsUniqueConstraintViolation(err) {
+				return api.ErrPasskeyAlreadyExists
+			}
+			return err
+		}
+
+		return enqueueCredentialChangeEmail(tx, request.RiverClient, CredentialChangeEmailJobArgs{
+			UserId:    passkeyUser.User.ID,
+			Change:    constants.CredentialChangePasskey,
+			Email:     passkeyUser.User.Email,
+			Detail:    passkey.Name,
+			ChangedAt: time.Now(),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &passkey, nil
+}
+
+func getPasskeys(request GetPasskeysServiceRequest) ([]models.Passkey, error) {
+	tx := request.Tx
+
+	var passkeys []models.Passkey
+	err := tx.Where("user_id = ?", request.UserID).Order("created_at ASC").Find(&passkeys).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return passkeys, nil
+}
+
+func updatePasskey(request UpdatePasskeyServiceRequest) (*models.Passkey, error) {
+	tx := request.Tx
+	params := request.Params
+
+	passkey, err := getUserPasskey(tx, params.UserID, params.PasskeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	passkey.Name = params.Name
+	err = tx.Save(passkey).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return passkey, nil
+}
+
+func deletePasskey(request DeletePasskeyServiceRequest) error {
+	tx := request.Tx
+
+	// Lock the user so concurrent requests can't remove the user's last login methods at the same time
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, request.UserID).Error; err != nil {
+		return err
+	}
+
+	passkey, err := getUserPasskey(tx, request.UserID, request.PasskeyID)
+	if err != nil {
+		return err
+	}
+
+	canSignIn, err := hasOtherLoginMethod(tx, &user, uuid.Nil, passkey.ID)
+	if err != nil {
+		return err
+	}
+
+	if !canSignIn {
+		return api.ErrLastLoginMethod
+	}
+
+	return tx.Unscoped().Delete(passkey).Error
+}
+
+func beginPasskeyLogin(request BeginPasskeyLoginServiceRequest) (*PasskeyChallengeDto, error) {
+	tx := request.Tx
+	config := request.Config
+
+	webAuthn, err := authentication.NewWebAuthn(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Discoverable login, the authenticator tells us which user the passkey belongs to
+	assertion, session, err := webAuthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return nil, err
+	}
+
+	challengeId, err := savePasskeyChallenge(tx, nil, authentication.PasskeyCeremonyLogin, session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PasskeyChallengeDto{
+		ChallengeId: challengeId,
+		Options:     assertion,
+	}, nil
+}
+
+func finishPasskeyLogin(request FinishPasskeyLoginServiceRequest) (*UserDto, error) {
+	tx := request.Tx
+	params := request.Params
+	config := request.Config
+	minioClient := request.MinioClient
+
+	session, err := consumePasskeyChallenge(tx, params.ChallengeId, nil, authentication.PasskeyCeremonyLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	webAuthn, err := authentication.NewWebAuthn(config)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedResponse, err := protocol.ParseCredentialRequestResponseBytes(params.Credential)
+	if err != nil {
+		slog.Info("Invalid passkey login response", "error", err)
+		return nil, api.ErrPasskeyVerificationFailed
+	}
+
+	// The user handle returned by the authenticator is the user ID set during registration
+	var passkeyUser *authentication.PasskeyUser
+	credential, err := webAuthn.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		userId, err := uuid.FromBytes(userHandle)
+		if err != nil {
+			return nil, err
+		}
+
+		passkeyUser, err = getPasskeyUser(tx, userId)
+		if err != nil {
+			return nil, err
+		}
+		return passkeyUser, nil
+	}, *session, parsedResponse)
+	if err != nil {
+		slog.Info("Passkey login failed verification", "error", err)
+		return nil, api.ErrPasskeyVerificationFailed
+	}
+
+	var passkey models.Passkey
+	err = tx.Where("user_id = ? AND credential_id = ?", passkeyUser.User.ID, credential.ID).First(&passkey).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// A signature counter that didn't increase means the authenticator may have been cloned. The login is rejected
+	// without saving anything, so the counter the genuine authenticator has to beat stays the same and it can keep
+	// signing in.
+	if credential.Authenticator.CloneWarning {
+		slog.Warn("Rejected passkey login, authenticator may be cloned", "userID", passkeyUser.User.ID, "passkeyID", passkey.ID)
+		return nil, api.ErrPasskeyVerificationFailed
+	}
+
+	err = tx.Model(&passkey).Updates(map[string]any{
+		"sign_count":   int64(credential.Authenticator.SignCount),
+		"flags":        uint8(credential.Flags.ProtocolValue()),
+		"last_used_at": time.Now(),
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkSsoNotRequired(tx, passkeyUser.User.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate the access and refresh tokens for the user
+	tokens, err := createAuthenticatedUserToken(CreateAuthenticatedUserTokenServiceRequest{
+		Params: CreateAuthenticatedUserTokenParams{
+			UserId: passkeyUser.User.ID,
+			Client: params.Client,
+		},
+		Tx:     tx,
+		Config: config,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logoDistributionUrl, err := GetUserLogoDistributionUrl(GetUserLogoDistributionUrlServiceRequest{
+		UserID:      passkeyUser.User.ID,
+		Tx:          tx,
+		MinioClient: minioClient,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserDto{
+		User:                passkeyUser.User,
+		Token:               tokens.AccessToken,
+		RefreshToken:        tokens.RefreshToken,
+		LogoDistributionUrl: logoDistributionUrl,
+	}, nil
+}
+
+func getPasskeyUser(tx *gorm.DB, userId uuid.UUID) (*authentication.PasskeyUser, error) {
+	var user models.User
+	err := tx.First(&user, userId).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	var passkeys []models.Passkey
+	err = tx.Where("user_id = ?", userId).Find(&passkeys).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &authentication.PasskeyUser{User: &user, Passkeys: passkeys}, nil
+}
+
+func getUserPasskey(tx *gorm.DB, userId uuid.UUID, passkeyId uuid.UUID) (*models.Passkey, error) {
+	var passkey models.Passkey
+	err := tx.Where("id = ? AND user_id = ?", passkeyId, userId).First(&passkey).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrPasskeyNotFound
+		}
+		return nil, err
+	}
+	return &passkey, nil
+}
+
+// savePasskeyChallenge stores the session of a WebAuthn ceremony until the client sends back the authenticator response
+func savePasskeyChallenge(tx *gorm.DB, userId *uuid.UUID, ceremony string, session *webauthn.SessionData) (uuid.UUID, error) {
+	// Clean up challenges from ceremonies that were never finished
+	err := tx.Unscoped().Where("expires_at < ?", time.Now()).Delete(&models.PasskeyChallenge{}).Error
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	sessionJson, err := json.Marshal(session)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	challenge := models.PasskeyChallenge{
+		UserID:    userId,
+		Ceremony:  ceremony,
+		Session:   string(sessionJson),
+		ExpiresAt: session.Expires,
+	}
+	err = tx.Create(&challenge).Error
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return challenge.ID, nil
+}
+
+// consumePasskeyChallenge loads and deletes a stored challenge, so each challenge can only be answered once
+func consumePasskeyChallenge(tx *gorm.DB, challengeId uuid.UUID, userId *uuid.UUID, ceremony string) (*webauthn.SessionData, error) {
+	query := tx.Where("id = ? AND ceremony = ?", challengeId, ceremony)
+	if userId != nil {
+		query = query.Where("user_id = ?", *userId)
+	} else {
+		query = query.Where("user_id IS NULL")
+	}
+
+	var challenge models.PasskeyChallenge
+	err := query.First(&challenge).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrPasskeyChallengeInvalid
+		}
+		return nil, err
+	}
+
+	result := tx.Unscoped().Delete(&challenge)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 || time.Now().After(challenge.ExpiresAt) {
+		return nil, api.ErrPasskeyChallengeInvalid
+	}
+
+	var session webauthn.SessionData
+	err = json.Unmarshal([]byte(challenge.Session), &session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
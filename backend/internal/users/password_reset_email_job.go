@@ -0,0 +1,58 @@
+package users
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resend/resend-go/v2"
+	"github.com/riverqueue/river"
+	"gorm.io/gorm"
+	"reece.start/internal/configuration"
+	"reece.start/internal/constants"
+	"reece.start/internal/email"
+	"reece.start/internal/models"
+)
+
+type PasswordResetEmailJobArgs struct {
+	UserId uuid.UUID `json:"userId"`
+}
+
+func (PasswordResetEmailJobArgs) Kind() string {
+	return string(constants.JobKindPasswordResetEmail)
+}
+
+type PasswordResetEmailJobWorker struct {
+	river.WorkerDefaults[PasswordResetEmailJobArgs]
+	DB           *gorm.DB
+	Config       *configuration.Config
+	ResendClient *resend.Client
+}
+
+func (w *PasswordResetEmailJobWorker) Work(ctx context.Context, job *river.Job[PasswordResetEmailJobArgs]) error {
+	slog.Info("Sending password reset email", "userId", job.Args.UserId)
+
+	return sendLinkTokenEmail(ctx, w.DB, w.Config, w.ResendClient, job.Args.UserId, linkTokenEmail{
+		Model: &models.PasswordResetToken{},
+		NewToken: func(userId uuid.UUID, tokenHash string, expiresAt time.Time) any {
+			return &models.PasswordResetToken{UserID: userId, TokenHash: tokenHash, ExpiresAt: expiresAt}
+		},
+		Expiration: time.Duration(w.Config.PasswordResetTokenExpirationTime) * time.Second,
+		Subject:    "Reset your " + constants.ServiceName + " password",
+		ApplyHtmlTemplate: func(user models.User, token string, expiresAt time.Time) (string, error) {
+			return email.PasswordResetEmailTemplateParams{
+				User:               user,
+				Token:              token,
+				ExpiresAt:          expiresAt,
+				FrontendUrl:        w.Config.FrontendUrl,
+				ServiceName:        constants.ServiceName,
+				ServiceDescription: constants.ServiceDescription,
+			}.ApplyHtmlTemplate()
+		},
+	})
+}
+
+func (w *PasswordResetEmailJobWorker) Timeout(*river.Job[PasswordResetEmailJobArgs]) time.Duration {
+	return 180 * time.Second
+}
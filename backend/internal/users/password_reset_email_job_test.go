@@ -0,0 +1,163 @@
+package users_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"reece.start/internal/authentication"
+	"reece.start/internal/constants"
+	"reece.start/internal/models"
+	"reece.start/test"
+)
+
+func requestPasswordReset(t *testing.T, tc *test.TestContext, email string) {
+	reqBody := map[string]interface{}{
+		"data": map[string]interface{}{
+			"attributes": map[string]interface{}{
+				"email": email,
+			},
+		},
+	}
+
+	rec := tc.MakeRequest(http.MethodPost, "/users/password-reset", reqBody, nil)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+}
+
+// createPasswordResetToken stores a password reset token for the user. The emailed token is only created when the
+// email job runs and only its hash is stored, so tests make their own.
+func createPasswordResetToken(t *testing.T, tc *test.TestContext, user *models.User) string {
+	token, tokenHash, err := authentication.GenerateOpaqueToken()
+	require.NoError(t, err)
+
+	require.NoError(t, tc.DB.Create(&models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(time.Duration(tc.Config.PasswordResetTokenExpirationTime) * time.Second),
+	}).Error)
+
+	return token
+}
+
+func confirmPasswordReset(tc *test.TestContext, token string, password string) int {
+	reqBody := map[string]interface{}{
+		"data": map[string]interface{}{
+			"attributes": map[string]interface{}{
+				"token":    token,
+				"password": password,
+			},
+		},
+	}
+
+	return tc.MakeRequest(http.MethodPost, "/users/password-reset/confirm", reqBody, nil).Code
+}
+
+func TestPasswordResetEmailJob(t *testing.T) {
+	t.Run("EnqueuedOnPasswordResetRequest", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, _ := test.CreateTestUser(t, tc)
+
+		requestPasswordReset(t, tc, user.Email)
+
+		// The token is created when the email is sent, so it isn't stored in the job
+		var args string
+		err := tc.DB.Raw(`SELECT args FROM river_job WHERE kind = ?`, string(constants.JobKindPasswordResetEmail)).Scan(&args).Error
+		require.NoError(t, err)
+		assert.NotContains(t, args, "token")
+
+		var resetTokenCount int64
+		require.NoError(t, tc.DB.Model(&models.PasswordResetToken{}).Where("user_id = ?", user.ID).Count(&resetTokenCount).Error)
+		assert.Equal(t, int64(0), resetTokenCount)
+	})
+
+	t.Run("NotEnqueuedForUnknownEmail", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+
+		requestPasswordReset(t, tc, "nobody@example.com")
+
+		var jobCount int64
+		err := tc.DB.Raw(`SELECT COUNT(*) FROM river_job WHERE kind = ?`, string(constants.JobKindPasswordResetEmail)).Scan(&jobCount).Error
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), jobCount)
+	})
+
+	t.Run("ExecutesSuccessfully", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, _ := test.CreateTestUser(t, tc)
+
+		requestPasswordReset(t, tc, user.Email)
+		test.RunAllPendingRiverJobs(t, tc.DB, tc.RiverClient)
+
+		var completedJobCount int64
+		err := tc.DB.Raw(`
+		SELECT COUNT(*)
+		FROM river_job
+		WHERE kind = ? AND args->>'userId' = ? AND state = 'completed'
+	`, string(constants.JobKindPasswordResetEmail), user.ID.String()).Scan(&completedJobCount).Error
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), completedJobCount)
+
+		var resetTokenCount int64
+		require.NoError(t, tc.DB.Model(&models.PasswordResetToken{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&resetTokenCount).Error)
+		assert.Equal(t, int64(1), resetTokenCount)
+	})
+}
+
+func TestConfirmPasswordResetEndpoint(t *testing.T) {
+	t.Run("ResetsPasswordAndRevokesSessions", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, oldPassword, oldToken := test.CreateTestUser(t, tc)
+
+		token := createPasswordResetToken(t, tc, user)
+
+		assert.Equal(t, http.StatusNoContent, confirmPasswordReset(tc, token, "new-password-123"))
+
+		// Existing sessions are signed out
+		rec := tc.MakeAuthenticatedRequest(http.MethodGet, "/users/me", nil, oldToken)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		// Only the new password works
+		test.LoginTestUser(t, tc, user.Email, "new-password-123")
+
+		reqBody := map[string]interface{}{
+			"data": map[string]interface{}{
+				"attributes": map[string]interface{}{
+					"email":    user.Email,
+					"password": oldPassword,
+				},
+			},
+		}
+		rec = tc.MakeRequest(http.MethodPost, "/users/login", reqBody, nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("TokenCanOnlyBeUsedOnce", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, _ := test.CreateTestUser(t, tc)
+
+		token := createPasswordResetToken(t, tc, user)
+
+		assert.Equal(t, http.StatusNoContent, confirmPasswordReset(tc, token, "new-password-123"))
+		assert.Equal(t, http.StatusBadRequest, confirmPasswordReset(tc, token, "another-password-123"))
+	})
+
+	t.Run("NewRequestInvalidatesPreviousToken", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, _ := test.CreateTestUser(t, tc)
+
+		token := createPasswordResetToken(t, tc, user)
+
+		requestPasswordReset(t, tc, user.Email)
+		test.RunAllPendingRiverJobs(t, tc.DB, tc.RiverClient)
+
+		assert.Equal(t, http.StatusBadRequest, confirmPasswordReset(tc, token, "new-password-123"))
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+
+		assert.Equal(t, http.StatusBadRequest, confirmPasswordReset(tc, "not-a-real-token", "new-password-123"))
+	})
+}
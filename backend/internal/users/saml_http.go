@@ -0,0 +1,137 @@
+package users
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"reece.start/internal/api"
+	"reece.start/internal/middleware"
+)
+
+func SamlMetadataEndpoint(c echo.Context) error {
+	organizationID, err := api.ParseOrganizationIDFromParams(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+
+	metadata, err := getSamlMetadata(GetSamlMetadataServiceRequest{
+		OrganizationID: organizationID,
+		Tx:             db.WithContext(c.Request().Context()),
+		Config:         config,
+	})
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.Blob(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+func StartSamlLoginEndpoint(c echo.Context) error {
+	organizationID, err := api.ParseOrganizationIDFromParams(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+
+	var authorization *SamlAuthorizationDto
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		authorization, err = startSamlLogin(StartSamlLoginServiceRequest{
+			OrganizationID: organizationID,
+			Tx:             tx,
+			Config:         config,
+		})
+		return err
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusCreated, mapSamlAuthorizationToResponse(authorization))
+}
+
+// SamlAcsEndpoint is the assertion consumer service the identity provider posts responses to through the browser.
+// The browser is redirected to the frontend with a code to exchange for a session.
+func SamlAcsEndpoint(c echo.Context) error {
+	organizationID, err := api.ParseOrganizationIDFromParams(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	samlResponse := c.FormValue("SAMLResponse")
+	relayState := c.FormValue("RelayState")
+	if samlResponse == "" || relayState == "" {
+		return api.ErrSamlLoginInvalid
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+	posthogClient := middleware.GetPostHogClient(c)
+
+	var loginCode *SamlLoginCodeDto
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		loginCode, err = consumeSamlResponse(ConsumeSamlResponseServiceRequest{
+			Params: ConsumeSamlResponseParams{
+				OrganizationID: organizationID,
+				SamlResponse:   samlResponse,
+				RelayState:     relayState,
+			},
+			Tx:            tx,
+			Config:        config,
+			PostHogClient: posthogClient,
+		})
+		return err
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	redirectUrl, err := url.Parse(config.SamlRedirectUri)
+	if err != nil {
+		return err
+	}
+	query := redirectUrl.Query()
+	query.Set("code", loginCode.Code)
+	query.Set("state", loginCode.State)
+	redirectUrl.RawQuery = query.Encode()
+
+	return c.Redirect(http.StatusSeeOther, redirectUrl.String())
+}
+
+func LoginSamlEndpoint(c echo.Context, req LoginSamlRequest) error {
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+	minioClient := middleware.GetMinioClient(c)
+
+	var user *UserDto
+	err := db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+		user, err = loginUserWithSaml(LoginSamlServiceRequest{
+			Params: LoginSamlParams{
+				Code:   req.Data.Attributes.Code,
+				State:  req.Data.Attributes.State,
+				Client: getSessionClient(c),
+			},
+			Tx:          tx,
+			Config:      config,
+			MinioClient: minioClient,
+		})
+		return err
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusOK, mapUserToResponse(user))
+}
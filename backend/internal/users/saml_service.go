@@ -0,0 +1,374 @@
+package users
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"reece.start/internal/api"
+	"reece.start/internal/authentication"
+	"reece.start/internal/models"
+	"reece.start/internal/posthog"
+)
+
+const (
+	// How long a user has to complete a SAML login at the identity provider
+	samlLoginExpiry = 10 * time.Minute
+	// How long the frontend has to exchange the code from the assertion consumer service for a session
+	samlLoginCodeExpiry = time.Minute
+)
+
+// checkSsoNotRequired returns an error if any of the user's organizations requires signing in with SAML. The error says
+// which organization to sign in with, the one the user joined first when there are several.
+func checkSsoNotRequired(tx *gorm.DB, userId uuid.UUID) error {
+	var organizationIds []uuid.UUID
+	err := tx.Model(&models.OrganizationSamlConnection{}).
+		Joins("JOIN organization_memberships ON organization_memberships.organization_id = organization_saml_connections.organization_id AND organization_memberships.deleted_at IS NULL").
+		Where("organization_memberships.user_id = ? AND organization_saml_connections.sso_required = ?", userId, true).
+		Order("organization_memberships.created_at").
+		Limit(1).
+		Pluck("organization_saml_connections.organization_id", &organizationIds).Error
+	if err != nil {
+		return err
+	}
+
+	if len(organizationIds) > 0 {
+		return &api.SsoRequiredError{OrganizationID: organizationIds[0]}
+	}
+
+	return nil
+}
+
+// getSamlConnection returns the SAML connection of an organization
+func getSamlConnection(tx *gorm.DB, organizationId uuid.UUID) (*models.OrganizationSamlConnection, error) {
+	var connection models.OrganizationSamlConnection
+	err := tx.Where("organization_id = ?", organizationId).First(&connection).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrSamlConnectionNotFound
+		}
+		return nil, err
+	}
+
+	return &connection, nil
+}
+
+// getSamlMetadata returns the service provider metadata of an organization, which is available before the
+// organization has connected an identity provider so it can be uploaded to the provider first
+func getSamlMetadata(request GetSamlMetadataServiceRequest) ([]byte, error) {
+	tx := request.Tx
+
+	var organization models.Organization
+	err := tx.Select("id").First(&organization, request.OrganizationID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return authentication.GetSamlServiceProviderMetadata(request.Config, organization.ID)
+}
+
+// startSamlLogin creates a SAML login for an organization and returns the URL of the identity provider to send the
+// user to
+func startSamlLogin(request StartSamlLoginServiceRequest) (*SamlAuthorizationDto, error) {
+	tx := request.Tx
+	config := request.Config
+
+	connection, err := getSamlConnection(tx, request.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	sp, err := authentication.NewSamlServiceProvider(config, connection.OrganizationID, connection)
+	if err != nil {
+		return nil, err
+	}
+
+	state, stateHash, err := authentication.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	authnRequest, err := authentication.MakeSamlAuthnRequest(sp, state)
+	if err != nil {
+		return nil, err
+	}
+
+	// Clean up logins that were never finished
+	err = tx.Unscoped().Where("expires_at < ?", time.Now()).Delete(&models.SamlLogin{}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	login := models.SamlLogin{
+		OrganizationID: connection.OrganizationID,
+		StateHash:      stateHash,
+		RequestID:      authnRequest.RequestID,
+		ExpiresAt:      time.Now().Add(samlLoginExpiry),
+	}
+	err = tx.Create(&login).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &SamlAuthorizationDto{
+		AuthorizationUrl: authnRequest.Url,
+		State:            state,
+		ExpiresAt:        login.ExpiresAt,
+	}, nil
+}
+
+// consumeSamlResponse verifies a response posted by an organization's identity provider and records the user on the
+// login, provisioning the user and their membership if this is their first SSO login. Returns a single use code the
+// frontend exchanges for a session, since the response is posted by the browser without the frontend's involvement.
+func consumeSamlResponse(request ConsumeSamlResponseServiceRequest) (*SamlLoginCodeDto, error) {
+	tx := request.Tx
+	config := request.Config
+	params := request.Params
+
+	var login models.SamlLogin
+	err := tx.Where("state_hash = ? AND organization_id = ?", authentication.HashOpaqueToken(params.RelayState), params.OrganizationID).
+		First(&login).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrSamlLoginInvalid
+		}
+		return nil, err
+	}
+
+	if login.UserID != nil || time.Now().After(login.ExpiresAt) {
+		return nil, api.ErrSamlLoginInvalid
+	}
+
+	connection, err := getSamlConnection(tx, login.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	sp, err := authentication.NewSamlServiceProvider(config, connection.OrganizationID, connection)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := authentication.ParseSamlResponse(sp, connection, params.SamlResponse, login.RequestID)
+	if err != nil {
+		slog.Warn("SAML response rejected", "organizationID", connection.OrganizationID, "error", err)
+		return nil, api.ErrSamlResponseInvalid
+	}
+
+	user, err := provisionSamlUser(tx, connection, profile, request.PostHogClient)
+	if err != nil {
+		return nil, err
+	}
+
+	code, codeHash, err := authentication.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the first response for a login is accepted
+	result := tx.Model(&models.SamlLogin{}).
+		Where("id = ? AND user_id IS NULL", login.ID).
+		Updates(map[string]any{
+			"user_id":    user.ID,
+			"code_hash":  codeHash,
+			"expires_at": time.Now().Add(samlLoginCodeExpiry),
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, api.ErrSamlLoginInvalid
+	}
+
+	return &SamlLoginCodeDto{
+		Code:  code,
+		State: params.RelayState,
+	}, nil
+}
+
+// provisionSamlUser returns the user an identity provider vouched for, creating the user on their first login and
+// adding them to the organization if they aren't a member yet. The identity provider is only trusted by its
+// organization, so accounts are only created or linked for emails on domains the organization has verified.
+func provisionSamlUser(tx *gorm.DB, connection *models.OrganizationSamlConnection, profile *authentication.SamlProfile, posthogClient *posthog.Client) (*models.User, error) {
+	subject := connection.OrganizationID.String() + ":" + profile.Subject
+
+	var identity models.UserIdentity
+	err := tx.Where("provider = ? AND subject = ?", authentication.SamlIdentityProvider, subject).First(&identity).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var user models.User
+
+	if err == nil {
+		if err := tx.First(&user, identity.UserID).Error; err != nil {
+			return nil, err
+		}
+
+		// Keep the identity up to date with the identity provider
+		identity.Email = profile.Email
+		if err := tx.Save(&identity).Error; err != nil {
+			return nil, err
+		}
+	} else {
+		// The identity provider controls which email it asserts, so it can only claim addresses on domains the
+		// organization has proven it owns. Otherwise it could take an address before its owner signs up.
+		isVerifiedDomain, err := authentication.IsVerifiedOrganizationDomain(tx, connection.OrganizationID, profile.Email)
+		if err != nil {
+			return nil, err
+		}
+
+		err = tx.Where("email = ?", profile.Email).First(&user).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+
+		if err == nil {
+			// Other users have to link their account themselves while signed in
+			if !isVerifiedDomain {
+				return nil, api.ErrSamlEmailInUse
+			}
+		} else {
+			if !isVerifiedDomain {
+				return nil, api.ErrSamlEmailDomainNotVerified
+			}
+
+			user = models.User{
+				Name:           profile.Name,
+				Email:          profile.Email,
+				HashedPassword: nil, // SSO users don't have passwords
+			}
+
+			if user.Name == "" {
+				user.Name = profile.Email
+			}
+
+			if err := tx.Create(&user).Error; err != nil {
+				return nil, err
+			}
+
+			// Log user created event to PostHog (SAML signup)
+			posthogClient.Capture(
+				user.ID.String(),
+				"user created",
+				map[string]any{
+					"user_id":       user.ID.String(),
+					"email":         user.Email,
+					"name":          user.Name,
+					"signup_method": "saml",
+				},
+			)
+		}
+
+		identity = models.UserIdentity{
+			UserID:   user.ID,
+			Provider: authentication.SamlIdentityProvider,
+			Subject:  subject,
+			Email:    profile.Email,
+		}
+
+		if err := tx.Create(&identity).Error; err != nil {
+			return nil, err
+		}
+
+		slog.Info("Linked SAML identity to user", "userID", user.ID, "organizationID", connection.OrganizationID)
+	}
+
+	isMember, err := isOrganizationMember(tx, connection.OrganizationID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isMember {
+		membership := models.OrganizationMembership{
+			UserID:         user.ID,
+			OrganizationID: connection.OrganizationID,
+			Role:           connection.DefaultRole,
+		}
+		if err := tx.Create(&membership).Error; err != nil {
+			return nil, err
+		}
+
+		slog.Info("Added SAML user to organization", "userID", user.ID, "organizationID", connection.OrganizationID, "role", membership.Role)
+	}
+
+	return &user, nil
+}
+
+// isOrganizationMember returns whether the user is a member of the organization
+func isOrganizationMember(tx *gorm.DB, organizationId uuid.UUID, userId uuid.UUID) (bool, error) {
+	var count int64
+	err := tx.Model(&models.OrganizationMembership{}).
+		Where("organization_id = ? AND user_id = ?", organizationId, userId).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// loginUserWithSaml exchanges the code from the assertion consumer service for a session in the organization the
+// user signed in to. The state has to match the one the login was started with, so a code can't be used in another
+// browser.
+func loginUserWithSaml(request LoginSamlServiceRequest) (*UserDto, error) {
+	tx := request.Tx
+	config := request.Config
+	params := request.Params
+
+	var login models.SamlLogin
+	err := tx.Where("code_hash = ?", authentication.HashOpaqueToken(params.Code)).First(&login).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrSamlLoginInvalid
+		}
+		return nil, err
+	}
+
+	result := tx.Unscoped().Delete(&login)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 || login.UserID == nil || time.Now().After(login.ExpiresAt) ||
+		login.StateHash != authentication.HashOpaqueToken(params.State) {
+		return nil, api.ErrSamlLoginInvalid
+	}
+
+	var user models.User
+	err = tx.First(&user, *login.UserID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := createAuthenticatedUserToken(CreateAuthenticatedUserTokenServiceRequest{
+		Params: CreateAuthenticatedUserTokenParams{
+			UserId:         user.ID,
+			OrganizationId: &login.OrganizationID,
+			Client:         params.Client,
+		},
+		Tx:     tx,
+		Config: config,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logoDistributionUrl, err := GetUserLogoDistributionUrl(GetUserLogoDistributionUrlServiceRequest{
+		UserID:      user.ID,
+		Tx:          tx,
+		MinioClient: request.MinioClient,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserDto{
+		User:                &user,
+		Token:               tokens.AccessToken,
+		RefreshToken:        tokens.RefreshToken,
+		LogoDistributionUrl: logoDistributionUrl,
+	}, nil
+}
@@ -1,26 +1,34 @@
 package users
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
 	"net/url"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/minio/minio-go/v7"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
+	"github.com/riverqueue/river"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"reece.start/internal/api"
 	"reece.start/internal/authentication"
+	"reece.start/internal/configuration"
 	"reece.start/internal/constants"
 	"reece.start/internal/models"
+	"reece.start/internal/utils"
 )
 
 func createUser(request CreateUserServiceRequest) (*UserDto, error) {
@@ -29,6 +37,15 @@ func createUser(request CreateUserServiceRequest) (*UserDto, error) {
 	config := request.Config
 	posthogClient := request.PostHogClient
 
+	err := authentication.CheckPasswordPolicy(config, authentication.PasswordPolicyParams{
+		Password: params.Password,
+		Name:     params.Name,
+		Email:    params.Email,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	hashedPassword, err := authentication.HashPassword(params.Password, config)
 	if err != nil {
 		return nil, err
@@ -60,10 +77,11 @@ func createUser(request CreateUserServiceRequest) (*UserDto, error) {
 		},
 	)
 
-	// Generate JWT token for the new user
-	token, err := createAuthenticatedUserToken(CreateAuthenticatedUserTokenServiceRequest{
+	// Generate the access and refresh tokens for the new user
+	tokens, err := createAuthenticatedUserToken(CreateAuthenticatedUserTokenServiceRequest{
 		Params: CreateAuthenticatedUserTokenParams{
 			UserId: user.ID,
+			Client: params.Client,
 		},
 		Tx:     tx,
 		Config: config,
@@ -74,66 +92,97 @@ func createUser(request CreateUserServiceRequest) (*UserDto, error) {
 	}
 
 	return &UserDto{
-		User:  user,
-		Token: token,
+		User:         user,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
 	}, nil
 }
 
-func createAuthenticatedUserToken(request CreateAuthenticatedUserTokenServiceRequest) (string, error) {
+// startImpersonation logs an admin starting to impersonate a user, and issues tokens that expire with the
+// impersonation. The user is emailed about it if the admin asked for that.
+func startImpersonation(request StartImpersonationServiceRequest) (*AuthenticatedUserTokenDto, error) {
 	tx := request.Tx
 	config := request.Config
+	params := request.Params
+	riverClient := request.RiverClient
 
 	var user models.User
-	err := tx.First(&user, request.Params.UserId).Error
+	err := tx.First(&user, params.ImpersonatedUserId).Error
 	if err != nil {
-		return "", err
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	impersonation := models.ImpersonationSession{
+		ImpersonatorUserID: &params.ImpersonatorUserId,
+		ImpersonatedUserID: &user.ID,
+		Reason:             strings.TrimSpace(params.Reason),
+		ReadOnly:           params.ReadOnly,
+		UserNotified:       params.NotifyUser,
+		UserAgent:          params.Client.UserAgent,
+		IpAddress:          params.Client.IpAddress,
+		ExpiresAt:          time.Now().Add(time.Duration(config.ImpersonationExpirationTime) * time.Second),
+	}
+
+	err = tx.Create(&impersonation).Error
+	if err != nil {
+		return nil, err
 	}
 
-	var selectMembershipRole SelectMembershipRole = SelectMembershipRole{}
-	scopes := make([]constants.UserScope, 0)
+	slog.Info("Admin started impersonating user", "impersonatorUserID", params.ImpersonatorUserId, "impersonatedUserID", user.ID, "impersonationSessionID", impersonation.ID, "readOnly", impersonation.ReadOnly)
 
-	if request.Params.OrganizationId != nil && *request.Params.OrganizationId != uuid.Nil {
-		err := tx.Model(&models.OrganizationMembership{}).Where("user_id = ? AND organization_id = ?", request.Params.UserId, request.Params.OrganizationId).Select("role").First(&selectMembershipRole).Error
+	if params.NotifyUser {
+		// Enqueue background job to send the impersonation email
+		sqlTx := utils.GetGormSQLTx(tx)
+		_, err = riverClient.InsertTx(tx.Statement.Context, sqlTx, ImpersonationEmailJobArgs{
+			ImpersonationSessionId: impersonation.ID,
+		}, nil)
 		if err != nil {
-			return "", err
+			return nil, fmt.Errorf("failed to enqueue impersonation email job: %w", err)
 		}
-
-		organizationScopes := constants.OrganizationRoleToScopes[constants.OrganizationRole(*selectMembershipRole.Role)]
-		scopes = append(scopes, organizationScopes...)
 	}
 
-	if user.Role != "" {
-		userScopes := constants.UserRoleToScopes[constants.UserRole(user.Role)]
-		scopes = append(scopes, userScopes...)
-	}
+	return createAuthenticatedUserToken(CreateAuthenticatedUserTokenServiceRequest{
+		Params: CreateAuthenticatedUserTokenParams{
+			UserId:                 user.ID,
+			OrganizationId:         params.OrganizationId,
+			ImpersonatingUserId:    &params.ImpersonatorUserId,
+			ImpersonationSessionId: &impersonation.ID,
+			SessionId:              params.SessionId,
+			Client:                 params.Client,
+		},
+		Tx:     tx,
+		Config: config,
+	})
+}
 
-	userRole := constants.UserRole(user.Role)
+// endImpersonation records that the admin stopped impersonating the user. The impersonation's refresh tokens give the
+// admin their own tokens back from then on.
+func endImpersonation(request EndImpersonationServiceRequest) error {
+	return request.Tx.Model(&models.ImpersonationSession{}).
+		Where("id = ? AND ended_at IS NULL", request.ImpersonationSessionID).
+		Update("ended_at", time.Now()).Error
+}
 
-	isImpersonating := false
-	var impersonatingUserId *uuid.UUID
-	if request.Params.ImpersonatingUserId != nil && *request.Params.ImpersonatingUserId != uuid.Nil {
-		isImpersonating = true
-		impersonatingUserId = request.Params.ImpersonatingUserId
+// getActiveImpersonation returns the impersonation if it hasn't ended or expired, and nil otherwise
+func getActiveImpersonation(tx *gorm.DB, impersonationSessionId *uuid.UUID) (*models.ImpersonationSession, error) {
+	// Tokens issued before impersonations were logged don't belong to one
+	if impersonationSessionId == nil {
+		return nil, nil
 	}
 
-	jwtOptions := authentication.JwtOptions{
-		UserId:              request.Params.UserId,
-		OrganizationId:      request.Params.OrganizationId,
-		OrganizationRole:    selectMembershipRole.Role,
-		Scopes:              &scopes,
-		Role:                &userRole,
-		IsImpersonating:     &isImpersonating,
-		ImpersonatingUserId: impersonatingUserId,
+	var impersonations []models.ImpersonationSession
+	err := tx.Where("id = ? AND ended_at IS NULL AND expires_at > ?", *impersonationSessionId, time.Now()).Limit(1).Find(&impersonations).Error
+	if err != nil {
+		return nil, err
 	}
 
-	// Use custom expiry if provided (for OAuth tokens)
-	if request.Params.CustomExpiry != nil {
-		jwtOptions.CustomExpiry = request.Params.CustomExpiry
+	if len(impersonations) == 0 {
+		return nil, nil
 	}
-
-	token, err := authentication.CreateJWT(config, jwtOptions)
-
-	return token, err
+	return &impersonations[0], nil
 }
 
 func loginUser(request LoginUserServiceRequest) (*UserDto, error) {
@@ -142,24 +191,91 @@ func loginUser(request LoginUserServiceRequest) (*UserDto, error) {
 	config := request.Config
 	minioClient := request.MinioClient
 
-	var user models.User
-	err := tx.Where("email = ?", params.Email).First(&user).Error
+	riverClient := request.RiverClient
+
+	// Accounts and IPs with too many recent failed attempts have to wait, whether or not the email is registered
+	err := checkLoginThrottle(tx, params.Email, params.Client.IpAddress)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, api.ErrUnauthorizedInvalidLogin
-		}
+		return nil, err
+	}
+
+	var user models.User
+	err = tx.Where("email = ?", params.Email).First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, err
 	}
 
 	// Check if password matches
-	if !authentication.CheckPasswordHash(params.Password, string(user.HashedPassword)) {
+	if err != nil || !authentication.CheckPasswordHash(params.Password, string(user.HashedPassword)) {
+		var userID *uuid.UUID
+		if err == nil {
+			userID = &user.ID
+		}
+
+		err = recordFailedLogin(RecordFailedLoginServiceRequest{
+			Params: RecordFailedLoginParams{
+				Email:     params.Email,
+				IpAddress: params.Client.IpAddress,
+				UserID:    userID,
+			},
+			Tx:          tx,
+			Config:      config,
+			RiverClient: riverClient,
+		})
+		if err != nil {
+			return nil, err
+		}
+
 		return nil, api.ErrUnauthorizedInvalidLogin
 	}
 
-	// Generate the JWT token for the user
-	token, err := createAuthenticatedUserToken(CreateAuthenticatedUserTokenServiceRequest{
+	err = checkSsoNotRequired(tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Upgrade hashes made with bcrypt or outdated parameters, now that we know the password
+	if authentication.PasswordNeedsRehash(string(user.HashedPassword), config) {
+		hashedPassword, err := authentication.HashPassword(params.Password, config)
+		if err != nil {
+			return nil, err
+		}
+
+		err = tx.Model(&user).Update("hashed_password", hashedPassword).Error
+		if err != nil {
+			return nil, err
+		}
+
+		slog.Info("Upgraded password hash", "userID", user.ID)
+	}
+
+	// Forget the account's failed attempts. The IP's are kept, otherwise signing in to an account of their own would
+	// let an attacker reset the IP's attempts.
+	err = tx.Unscoped().
+		Where("scope = ? AND target = ?", loginThrottleScopeAccount, normalizeLoginThrottleEmail(params.Email)).
+		Delete(&models.LoginThrottle{}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Users with MFA enabled only get a challenge token, which has to be exchanged for a session along with a valid code
+	if user.Mfa.Enabled {
+		mfaChallengeToken, err := authentication.CreateMfaChallengeJWT(config, user.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		return &UserDto{
+			User:              &user,
+			MfaChallengeToken: mfaChallengeToken,
+		}, nil
+	}
+
+	// Generate the access and refresh tokens for the user
+	tokens, err := createAuthenticatedUserToken(CreateAuthenticatedUserTokenServiceRequest{
 		Params: CreateAuthenticatedUserTokenParams{
 			UserId: user.ID,
+			Client: params.Client,
 		},
 		Tx:     tx,
 		Config: config,
@@ -180,7 +296,8 @@ func loginUser(request LoginUserServiceRequest) (*UserDto, error) {
 
 	return &UserDto{
 		User:                &user,
-		Token:               token,
+		Token:               tokens.AccessToken,
+		RefreshToken:        tokens.RefreshToken,
 		LogoDistributionUrl: logoDistributionUrl,
 	}, nil
 }
@@ -227,16 +344,53 @@ func updateUser(request UpdateUserServiceRequest) (*UserDto, error) {
 		return nil, err
 	}
 
+	// Changing how the user signs in needs more than a token, otherwise a stolen token could be used to take the
+	// account over for good
+	changingEmail := params.Email != "" && params.Email != user.Email && params.Email != user.PendingEmail
+	changingPassword := params.Password != ""
+	if changingEmail || changingPassword {
+		// Admins impersonating the user can't change how they sign in
+		if params.IsImpersonating {
+			return nil, api.ErrForbiddenImpersonationNotAllowed
+		}
+
+		err := checkReauthentication(tx, config, &user, params.CurrentPassword, params.SessionID, params.Client.IpAddress)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Update fields if provided
 	if params.Name != "" {
 		user.Name = params.Name
 	}
 
+	// A new email isn't applied until the user confirms it through the verification email
 	if params.Email != "" {
-		user.Email = params.Email
+		if params.Email == user.Email {
+			user.PendingEmail = ""
+		} else if params.Email != user.PendingEmail {
+			var count int64
+			if err := tx.Model(&models.User{}).Where("email = ?", params.Email).Count(&count).Error; err != nil {
+				return nil, err
+			}
+			if count > 0 {
+				return nil, api.ErrUserEmailAlreadyExists
+			}
+			user.PendingEmail = params.Email
+		}
 	}
 
 	if params.Password != "" {
+		err := authentication.CheckPasswordPolicy(config, authentication.PasswordPolicyParams{
+			Password: params.Password,
+			Name:     user.Name,
+			Email:    user.Email,
+		})
+		if err != nil {
+			return nil, err
+		}
+
 		hashedPassword, err := authentication.HashPassword(params.Password, config)
 		if err != nil {
 			return nil, err
@@ -279,6 +433,40 @@ func updateUser(request UpdateUserServiceRequest) (*UserDto, error) {
 		return nil, err
 	}
 
+	userDto := &UserDto{User: &user}
+
+	if changingEmail || changingPassword {
+		tokens, err := revokeOtherSessionsAfterCredentialChange(tx, config, &user, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if tokens != nil {
+			userDto.Token = tokens.AccessToken
+			userDto.RefreshToken = tokens.RefreshToken
+		}
+
+		// Pick up the revocation and anything else changed while issuing the new tokens
+		if err := tx.First(&user, user.ID).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	// The email change is only announced once it is verified, see confirmEmailVerification
+	if changingPassword {
+		slog.Info("Changed user password", "userID", user.ID)
+
+		err := enqueueCredentialChangeEmail(tx, request.RiverClient, CredentialChangeEmailJobArgs{
+			UserId:    user.ID,
+			Change:    constants.CredentialChangePassword,
+			Email:     user.Email,
+			ChangedAt: time.Now(),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	logoDistributionUrl, err := GetUserLogoDistributionUrl(GetUserLogoDistributionUrlServiceRequest{
 		UserID:      user.ID,
 		Tx:          tx,
@@ -288,10 +476,110 @@ func updateUser(request UpdateUserServiceRequest) (*UserDto, error) {
 		return nil, err
 	}
 
-	return &UserDto{
-		User:                &user,
-		LogoDistributionUrl: logoDistributionUrl,
-	}, nil
+	userDto.LogoDistributionUrl = logoDistributionUrl
+
+	return userDto, nil
+}
+
+// checkReauthentication makes sure whoever is changing how the user signs in is the user, and not just someone
+// holding one of their tokens. They have to enter their current password, or have signed in to the session recently.
+// Wrong passwords count as failed logins, the caller has to record them since the transaction is rolled back.
+func checkReauthentication(tx *gorm.DB, config *configuration.Config, user *models.User, currentPassword string, sessionId uuid.UUID, ipAddress string) error {
+	if currentPassword != "" {
+		err := checkLoginThrottle(tx, user.Email, ipAddress)
+		if err != nil {
+			return err
+		}
+
+		if user.HashedPassword == nil || !authentication.CheckPasswordHash(currentPassword, string(user.HashedPassword)) {
+			return api.ErrCurrentPasswordInvalid
+		}
+
+		return nil
+	}
+
+	// Sessions keep their ID when tokens are refreshed, so when the session was created is when the user signed in
+	if sessionId != uuid.Nil {
+		var sessions []models.UserSession
+		err := tx.Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionId, user.ID).Limit(1).Find(&sessions).Error
+		if err != nil {
+			return err
+		}
+
+		maxAge := time.Duration(config.ReauthenticationMaxAge) * time.Second
+		if len(sessions) > 0 && time.Since(sessions[0].CreatedAt) < maxAge {
+			return nil
+		}
+	}
+
+	return api.ErrCurrentPasswordRequired
+}
+
+// checkCredentialChange makes sure a change to how the user signs in is made by the user, and not by an admin
+// impersonating them or someone holding one of their tokens
+func checkCredentialChange(tx *gorm.DB, config *configuration.Config, user *models.User, params ReauthenticationParams) error {
+	if params.IsImpersonating {
+		return api.ErrForbiddenImpersonationNotAllowed
+	}
+
+	return checkReauthentication(tx, config, user, params.CurrentPassword, params.SessionID, params.IpAddress)
+}
+
+// recordFailedReauthentication counts entering the wrong current password as a failed login, so tokens can't be used
+// to guess the password
+func recordFailedReauthentication(request RecordFailedReauthenticationServiceRequest) error {
+	var user models.User
+	err := request.Tx.First(&user, request.UserID).Error
+	if err != nil {
+		return err
+	}
+
+	return recordFailedLogin(RecordFailedLoginServiceRequest{
+		Params: RecordFailedLoginParams{
+			Email:     user.Email,
+			IpAddress: request.IpAddress,
+			UserID:    &user.ID,
+		},
+		Tx:          request.Tx,
+		Config:      request.Config,
+		RiverClient: request.RiverClient,
+	})
+}
+
+// revokeOtherSessionsAfterCredentialChange signs the user out everywhere except the session that made the change, and
+// issues that session new tokens since its old ones are revoked too. No tokens are returned if the change wasn't made
+// with a session.
+func revokeOtherSessionsAfterCredentialChange(tx *gorm.DB, config *configuration.Config, user *models.User, params UpdateUserParams) (*AuthenticatedUserTokenDto, error) {
+	err := authentication.RevokeOtherUserSessions(tx, user.ID, params.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.SessionID == uuid.Nil {
+		return nil, nil
+	}
+
+	return createAuthenticatedUserToken(CreateAuthenticatedUserTokenServiceRequest{
+		Params: CreateAuthenticatedUserTokenParams{
+			UserId:         user.ID,
+			OrganizationId: params.OrganizationID,
+			SessionId:      &params.SessionID,
+			Client:         params.Client,
+		},
+		Tx:     tx,
+		Config: config,
+	})
+}
+
+// enqueueCredentialChangeEmail lets the user know how they sign in was changed
+func enqueueCredentialChangeEmail(tx *gorm.DB, riverClient *river.Client[*sql.Tx], args CredentialChangeEmailJobArgs) error {
+	sqlTx := utils.GetGormSQLTx(tx)
+	_, err := riverClient.InsertTx(tx.Statement.Context, sqlTx, args, nil)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue credential change email job: %w", err)
+	}
+
+	return nil
 }
 
 func GetUserLogoDistributionUrl(request GetUserLogoDistributionUrlServiceRequest) (string, error) {
@@ -316,9 +604,15 @@ func GetUserLogoDistributionUrl(request GetUserLogoDistributionUrlServiceRequest
 		return presignedUrl.String(), nil
 	}
 
-	// If no uploaded logo but Google profile image exists, return that as fallback
-	if user.GoogleProfileImage != "" {
-		return user.GoogleProfileImage, nil
+	// If no uploaded logo, fall back to the profile image of the most recently used linked identity
+	var identities []models.UserIdentity
+	err = tx.Where("user_id = ? AND profile_image <> ''", userID).Order("updated_at DESC").Limit(1).Find(&identities).Error
+	if err != nil {
+		return "", err
+	}
+
+	if len(identities) > 0 {
+		return identities[0].ProfileImage, nil
 	}
 
 	return "", nil
@@ -473,141 +767,865 @@ func getUsers(request GetUsersServiceRequest) (*GetUsersServiceResponse, error)
 	}, nil
 }
 
-type GoogleUserInfo struct {
-	ID      string `json:"id"`
-	Email   string `json:"email"`
-	Name    string `json:"name"`
-	Picture string `json:"picture"`
-}
-
-func googleOAuthCallback(request GoogleOAuthCallbackServiceRequest) (*UserDto, error) {
-	tx := request.Tx
-	config := request.Config
-	minioClient := request.MinioClient
-	posthogClient := request.PostHogClient
-	params := request.Params
+// joinDomainOrganization adds the user to the organization that verified their email's domain, if the domain's join
+// policy auto-joins users
+func joinDomainOrganization(tx *gorm.DB, user *models.User) error {
+	domain, err := authentication.GetJoinableOrganizationDomain(tx, user)
+	if err != nil {
+		return err
+	}
 
-	// Configure OAuth
-	oauth2Config := &oauth2.Config{
-		ClientID:     config.GoogleOAuthClientId,
-		ClientSecret: config.GoogleOAuthClientSecret,
-		RedirectURL:  params.RedirectUri,
-		Scopes: []string{
-			"https://www.googleapis.com/auth/userinfo.email",
-			"https://www.googleapis.com/auth/userinfo.profile",
-		},
-		Endpoint: google.Endpoint,
+	if domain == nil || domain.JoinPolicy != string(constants.OrganizationDomainJoinPolicyAutoJoin) {
+		return nil
 	}
 
-	// Exchange code for token
-	token, err := oauth2Config.Exchange(context.Background(), params.Code)
-	if err != nil {
-		return nil, api.ErrUnauthorizedInvalidLogin
+	membership := models.OrganizationMembership{
+		UserID:         user.ID,
+		OrganizationID: domain.OrganizationID,
+		Role:           domain.DefaultRole,
+	}
+	if err := tx.Create(&membership).Error; err != nil {
+		return err
 	}
 
-	// Get user info from Google
-	client := oauth2Config.Client(context.Background(), token)
-	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
+	slog.Info("Added user to organization through verified domain", "userID", user.ID, "organizationID", domain.OrganizationID, "domain", domain.Domain, "role", membership.Role)
+
+	return nil
+}
+
+// Login Throttling Service Functions
+
+// Failed logins are counted separately for the account (by email) and the client IP
+const (
+	loginThrottleScopeAccount = "account"
+	loginThrottleScopeIp      = "ip"
+	loginThrottleScopeMfa     = "mfa"
+
+	// Emails with magic links or password reset links are counted for the address they are sent to and the client IP
+	loginThrottleScopeEmail   = "email"
+	loginThrottleScopeEmailIp = "email_ip"
+)
+
+func normalizeLoginThrottleEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// checkLoginThrottle returns ErrTooManyLoginAttempts if the account or IP has to wait before trying again
+func checkLoginThrottle(tx *gorm.DB, email string, ipAddress string) error {
+	var count int64
+	err := tx.Model(&models.LoginThrottle{}).
+		Where("(scope = ? AND target = ?) OR (scope = ? AND target = ?)",
+			loginThrottleScopeAccount, normalizeLoginThrottleEmail(email),
+			loginThrottleScopeIp, ipAddress).
+		Where("next_attempt_at > ?", time.Now()).
+		Count(&count).Error
 	if err != nil {
-		return nil, api.ErrUnauthorizedInvalidLogin
+		return err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, api.ErrUnauthorizedInvalidLogin
+	if count > 0 {
+		return api.ErrTooManyLoginAttempts
 	}
 
-	var googleUser GoogleUserInfo
-	if err := json.Unmarshal(body, &googleUser); err != nil {
-		return nil, api.ErrUnauthorizedInvalidLogin
+	return nil
+}
+
+// recordFailedLogin counts a failed login against the account and IP, delaying or locking out further attempts once
+// there have been too many. The user is emailed when their account is locked out.
+func recordFailedLogin(request RecordFailedLoginServiceRequest) error {
+	config := request.Config
+	params := request.Params
+	riverClient := request.RiverClient
+
+	// Failed attempts have to be saved even though the login fails, so they are committed on their own
+	return request.Tx.Transaction(func(tx *gorm.DB) error {
+		accountThrottle, err := incrementLoginThrottle(tx, config, loginThrottleScopeAccount, normalizeLoginThrottleEmail(params.Email), config.LoginLockoutAccountThreshold)
+		if err != nil {
+			return err
+		}
+
+		if params.IpAddress != "" {
+			_, err = incrementLoginThrottle(tx, config, loginThrottleScopeIp, params.IpAddress, config.LoginLockoutIpThreshold)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Only let the user know when the account is first locked, not every time the lockout is extended
+		if params.UserID == nil || accountThrottle.FailedAttempts != config.LoginLockoutAccountThreshold {
+			return nil
+		}
+
+		slog.Warn("Account locked out after too many failed login attempts", "userID", *params.UserID)
+
+		// Enqueue background job to send the lockout email
+		sqlTx := utils.GetGormSQLTx(tx)
+		_, err = riverClient.InsertTx(tx.Statement.Context, sqlTx, LoginLockoutEmailJobArgs{
+			UserId:      *params.UserID,
+			LockedUntil: *accountThrottle.LockedUntil,
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("failed to enqueue login lockout email job: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// checkMfaThrottle returns ErrTooManyLoginAttempts if the user or IP has to wait before trying another MFA code. Once
+// the user was locked out, the challenges issued before then can't be used anymore and ErrMfaChallengeLocked is returned.
+func checkMfaThrottle(tx *gorm.DB, userId uuid.UUID, challengeIssuedAt time.Time, ipAddress string) error {
+	var throttles []models.LoginThrottle
+	err := tx.Where("(scope = ? AND target = ?) OR (scope = ? AND target = ?)",
+		loginThrottleScopeMfa, userId.String(),
+		loginThrottleScopeIp, ipAddress).
+		Find(&throttles).Error
+	if err != nil {
+		return err
 	}
 
-	// Check if user exists by Google ID
-	var user models.User
-	err = tx.Where("google_id = ?", googleUser.ID).First(&user).Error
+	now := time.Now()
+	for _, throttle := range throttles {
+		if throttle.Scope == loginThrottleScopeMfa && throttle.LockedUntil != nil && !challengeIssuedAt.After(throttle.LastFailedAt) {
+			return api.ErrMfaChallengeLocked
+		}
 
-	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		return nil, err
+		if throttle.NextAttemptAt != nil && throttle.NextAttemptAt.After(now) {
+			return api.ErrTooManyLoginAttempts
+		}
 	}
 
-	// If user doesn't exist by Google ID, check by email
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		err = tx.Where("email = ?", googleUser.Email).First(&user).Error
+	return nil
+}
 
-		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, err
+// recordFailedMfaCode counts an invalid MFA code against the user and IP, delaying or locking out further codes once
+// there have been too many. The user is emailed when they are locked out, since someone else may know their password.
+func recordFailedMfaCode(tx *gorm.DB, config *configuration.Config, riverClient *river.Client[*sql.Tx], userId uuid.UUID, ipAddress string) error {
+	// Failed attempts have to be saved even though the login fails, so they are committed on their own
+	return tx.Transaction(func(tx *gorm.DB) error {
+		mfaThrottle, err := incrementLoginThrottle(tx, config, loginThrottleScopeMfa, userId.String(), config.MfaLockoutThreshold)
+		if err != nil {
+			return err
 		}
 
-		// If user exists by email, link Google account
-		if err == nil {
-			user.GoogleId = googleUser.ID
-			if user.GoogleProfileImage == "" && googleUser.Picture != "" {
-				user.GoogleProfileImage = googleUser.Picture
-			}
-			if err := tx.Save(&user).Error; err != nil {
-				return nil, err
-			}
-		} else {
-			// Create new user
-			user = models.User{
-				Name:               googleUser.Name,
-				Email:              googleUser.Email,
-				GoogleId:           googleUser.ID,
-				GoogleProfileImage: googleUser.Picture,
-				HashedPassword:     nil, // OAuth users don't have passwords
+		if ipAddress != "" {
+			_, err = incrementLoginThrottle(tx, config, loginThrottleScopeIp, ipAddress, config.LoginLockoutIpThreshold)
+			if err != nil {
+				return err
 			}
+		}
 
-			if err := tx.Create(&user).Error; err != nil {
-				return nil, err
-			}
+		// Only let the user know when they are first locked out, not every time the lockout is extended
+		if mfaThrottle.FailedAttempts != config.MfaLockoutThreshold {
+			return nil
+		}
 
-			// Log user created event to PostHog (OAuth signup)
-			posthogClient.Capture(
-				user.ID.String(),
-				"user created",
-				map[string]any{
-					"user_id":       user.ID.String(),
-					"email":         user.Email,
-					"name":          user.Name,
-					"signup_method": "oauth_google",
-				},
-			)
+		slog.Warn("Account locked out after too many invalid MFA codes", "userID", userId)
+
+		// Enqueue background job to send the lockout email
+		sqlTx := utils.GetGormSQLTx(tx)
+		_, err = riverClient.InsertTx(tx.Statement.Context, sqlTx, LoginLockoutEmailJobArgs{
+			UserId:      userId,
+			LockedUntil: *mfaThrottle.LockedUntil,
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("failed to enqueue login lockout email job: %w", err)
 		}
-	}
 
-	// Generate JWT token with OAuth token expiration
-	jwtToken, err := createAuthenticatedUserToken(CreateAuthenticatedUserTokenServiceRequest{
-		Params: CreateAuthenticatedUserTokenParams{
-			UserId:       user.ID,
-			CustomExpiry: &token.Expiry,
-		},
-		Tx:     tx,
-		Config: config,
+		return nil
 	})
+}
+
+// throttleEmailLinkRequest counts a request for a magic link or password reset email against the address and IP,
+// returning ErrTooManyEmailRequests once either has requested too many. Requests are counted whether or not a user has
+// the address, so the limit doesn't reveal which emails are registered.
+func throttleEmailLinkRequest(tx *gorm.DB, config *configuration.Config, email string, ipAddress string) error {
+	email = normalizeLoginThrottleEmail(email)
+
+	var count int64
+	err := tx.Model(&models.LoginThrottle{}).
+		Where("(scope = ? AND target = ?) OR (scope = ? AND target = ?)",
+			loginThrottleScopeEmail, email,
+			loginThrottleScopeEmailIp, ipAddress).
+		Where("next_attempt_at > ?", time.Now()).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return api.ErrTooManyEmailRequests
+	}
+
+	_, err = incrementLoginThrottle(tx, config, loginThrottleScopeEmail, email, config.EmailLinkRequestAddressLimit)
+	if err != nil {
+		return err
+	}
 
+	if ipAddress != "" {
+		_, err = incrementLoginThrottle(tx, config, loginThrottleScopeEmailIp, ipAddress, config.EmailLinkRequestIpLimit)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// incrementLoginThrottle adds a failed attempt to the account or IP, and sets when the next attempt is allowed
+func incrementLoginThrottle(tx *gorm.DB, config *configuration.Config, scope string, target string, lockoutThreshold int) (*models.LoginThrottle, error) {
+	now := time.Now()
+	windowStart := now.Add(-time.Duration(config.LoginFailedAttemptWindow) * time.Second)
+
+	// Upsert so concurrent failures are all counted. Attempts are counted from scratch once the last one is outside
+	// the window.
+	throttle := models.LoginThrottle{
+		Scope:          scope,
+		Target:         target,
+		FailedAttempts: 1,
+		LastFailedAt:   now,
+	}
+	err := tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "scope"}, {Name: "target"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"failed_attempts": gorm.Expr("CASE WHEN login_throttles.last_failed_at < ? THEN 1 ELSE login_throttles.failed_attempts + 1 END", windowStart),
+			"last_failed_at":  now,
+			"updated_at":      now,
+		}),
+	}).Create(&throttle).Error
 	if err != nil {
 		return nil, err
 	}
 
-	// Get the logo distribution URL (prefer uploaded logo over Google profile image)
-	logoDistributionUrl, err := GetUserLogoDistributionUrl(GetUserLogoDistributionUrlServiceRequest{
-		UserID:      user.ID,
-		Tx:          tx,
-		MinioClient: minioClient,
-	})
+	// Read the row back, the upsert only knows the values it tried to insert
+	throttle = models.LoginThrottle{}
+	err = tx.Where("scope = ? AND target = ?", scope, target).First(&throttle).Error
 	if err != nil {
 		return nil, err
 	}
 
-	// If no uploaded logo and Google profile image exists, use Google profile image as fallback
-	if logoDistributionUrl == "" && user.GoogleProfileImage != "" {
-		logoDistributionUrl = user.GoogleProfileImage
+	throttle.NextAttemptAt = nil
+	throttle.LockedUntil = nil
+
+	if throttle.FailedAttempts >= lockoutThreshold {
+		lockedUntil := now.Add(time.Duration(config.LoginLockoutDuration) * time.Second)
+		throttle.NextAttemptAt = &lockedUntil
+		throttle.LockedUntil = &lockedUntil
+	} else if delay := getLoginThrottleDelay(config, throttle.FailedAttempts); delay > 0 {
+		nextAttemptAt := now.Add(delay)
+		throttle.NextAttemptAt = &nextAttemptAt
 	}
 
-	return &UserDto{
-		User:                &user,
-		Token:               jwtToken,
-		LogoDistributionUrl: logoDistributionUrl,
+	err = tx.Model(&throttle).Select("next_attempt_at", "locked_until").Updates(&throttle).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &throttle, nil
+}
+
+// getLoginThrottleDelay doubles the wait for every failed attempt after the free ones, up to the max delay
+func getLoginThrottleDelay(config *configuration.Config, failedAttempts int) time.Duration {
+	delayedAttempts := failedAttempts - config.LoginThrottleFreeAttempts
+	if delayedAttempts <= 0 {
+		return 0
+	}
+
+	maxDelay := time.Duration(config.LoginThrottleMaxDelay) * time.Second
+	if delayedAttempts > 16 {
+		return maxDelay
+	}
+
+	return min(time.Second<<(delayedAttempts-1), maxDelay)
+}
+
+func getImpersonations(request GetImpersonationsServiceRequest) ([]models.ImpersonationSession, error) {
+	tx := request.Tx
+
+	query := tx.Order("created_at DESC")
+	if request.UserID != nil {
+		query = query.Where("impersonator_user_id = ? OR impersonated_user_id = ?", *request.UserID, *request.UserID)
+	}
+
+	var impersonations []models.ImpersonationSession
+	err := query.Find(&impersonations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return impersonations, nil
+}
+
+func getLoginLockouts(request GetLoginLockoutsServiceRequest) ([]models.LoginThrottle, error) {
+	tx := request.Tx
+
+	var lockouts []models.LoginThrottle
+	err := tx.Where("locked_until > ?", time.Now()).Order("locked_until DESC").Find(&lockouts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return lockouts, nil
+}
+
+// deleteLoginLockout clears the failed attempts of a locked out account or IP, so it can sign in again straight away
+func deleteLoginLockout(request DeleteLoginLockoutServiceRequest) error {
+	tx := request.Tx
+
+	result := tx.Unscoped().Where("id = ?", request.LockoutID).Delete(&models.LoginThrottle{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return api.ErrLoginLockoutNotFound
+	}
+
+	return nil
+}
+
+// Personal Access Token Service Functions
+
+func getAccessTokens(request GetAccessTokensServiceRequest) ([]models.PersonalAccessToken, error) {
+	tx := request.Tx
+
+	var accessTokens []models.PersonalAccessToken
+	err := tx.Where("user_id = ?", request.UserID).Order("created_at ASC").Find(&accessTokens).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return accessTokens, nil
+}
+
+// createAccessToken creates a personal access token with a subset of the scopes the user currently has
+func createAccessToken(request CreateAccessTokenServiceRequest) (*AccessTokenDto, error) {
+	tx := request.Tx
+	config := request.Config
+	params := request.Params
+
+	var user models.User
+	err := tx.First(&user, params.UserID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens act as the user they belong to, so creating one needs the same checks as changing the password
+	err = checkCredentialChange(tx, config, &user, params.Reauthentication)
+	if err != nil {
+		return nil, err
+	}
+
+	grantedScopes, _, err := authentication.GetGrantedScopes(tx, &user, params.OrganizationID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrForbiddenNoAccess
+		}
+		return nil, err
+	}
+
+	scopes := make([]constants.UserScope, 0, len(params.Scopes))
+	for _, scope := range params.Scopes {
+		if !slices.Contains(grantedScopes, scope) {
+			return nil, api.ErrPersonalAccessTokenScopeDenied
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	token, tokenHash, tokenPrefix, err := authentication.GeneratePersonalAccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken := models.PersonalAccessToken{
+		UserID:         user.ID,
+		Name:           params.Name,
+		TokenHash:      tokenHash,
+		TokenPrefix:    tokenPrefix,
+		Scopes:         authentication.FormatScopes(scopes),
+		OrganizationID: params.OrganizationID,
+		ExpiresAt:      params.ExpiresAt,
+	}
+
+	err = tx.Create(&accessToken).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = enqueueCredentialChangeEmail(tx, request.RiverClient, CredentialChangeEmailJobArgs{
+		UserId:    user.ID,
+		Change:    constants.CredentialChangeAccessToken,
+		Email:     user.Email,
+		Detail:    accessToken.Name,
+		ChangedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &AccessTokenDto{
+		AccessToken: &accessToken,
+		Token:       token,
 	}, nil
 }
+
+func deleteAccessToken(request DeleteAccessTokenServiceRequest) error {
+	tx := request.Tx
+
+	result := tx.Unscoped().Where("id = ? AND user_id = ?", request.AccessTokenID, request.UserID).Delete(&models.PersonalAccessToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return api.ErrAccessTokenNotFound
+	}
+
+	return nil
+}
+
+// hasOtherLoginMethod checks if the user has a password, or a linked identity or passkey other than the ones being
+// removed. Magic links aren't counted, since they can be turned off.
+func hasOtherLoginMethod(tx *gorm.DB, user *models.User, identityID uuid.UUID, passkeyID uuid.UUID) (bool, error) {
+	if user.HashedPassword != nil {
+		return true, nil
+	}
+
+	var passkeyCount int64
+	err := tx.Model(&models.Passkey{}).Where("user_id = ? AND id <> ?", user.ID, passkeyID).Count(&passkeyCount).Error
+	if err != nil {
+		return false, err
+	}
+
+	var identityCount int64
+	err = tx.Model(&models.UserIdentity{}).Where("user_id = ? AND id <> ?", user.ID, identityID).Count(&identityCount).Error
+	if err != nil {
+		return false, err
+	}
+
+	return passkeyCount > 0 || identityCount > 0, nil
+}
+
+// setInitialPassword sets a password for a user that signed up without one, e.g. with an OAuth provider
+func setInitialPassword(request SetInitialPasswordServiceRequest) error {
+	tx := request.Tx
+	config := request.Config
+	params := request.Params
+
+	var user models.User
+	err := tx.First(&user, params.UserID).Error
+	if err != nil {
+		return err
+	}
+
+	// The user has no password to enter, so they have to have signed in recently
+	err = checkCredentialChange(tx, config, &user, params.Reauthentication)
+	if err != nil {
+		return err
+	}
+
+	err = authentication.CheckPasswordPolicy(config, authentication.PasswordPolicyParams{
+		Password: params.Password,
+		Name:     user.Name,
+		Email:    user.Email,
+	})
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := authentication.HashPassword(params.Password, config)
+	if err != nil {
+		return err
+	}
+
+	// Only update the user if they don't have a password, so this can't be used to change an existing one
+	result := tx.Model(&models.User{}).
+		Where("id = ? AND hashed_password IS NULL", params.UserID).
+		Update("hashed_password", hashedPassword)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return api.ErrPasswordAlreadySet
+	}
+
+	slog.Info("Added password to user", "userID", user.ID)
+
+	return enqueueCredentialChangeEmail(tx, request.RiverClient, CredentialChangeEmailJobArgs{
+		UserId:    user.ID,
+		Change:    constants.CredentialChangePasswordAdded,
+		Email:     user.Email,
+		ChangedAt: time.Now(),
+	})
+}
+
+// Email Verification Service Functions
+
+// sendEmailVerification emails a verification link for the user's pending email, or for their current email if it
+// hasn't been verified yet
+func sendEmailVerification(request SendEmailVerificationServiceRequest) error {
+	tx := request.Tx
+	riverClient := request.RiverClient
+
+	var user models.User
+	if err := tx.First(&user, request.UserID).Error; err != nil {
+		return err
+	}
+
+	emailToVerify := getEmailToVerify(&user)
+	if emailToVerify == "" {
+		return api.ErrEmailAlreadyVerified
+	}
+
+	// Enqueue background job to send the verification email
+	sqlTx := utils.GetGormSQLTx(tx)
+	_, err := riverClient.InsertTx(tx.Statement.Context, sqlTx, EmailVerificationEmailJobArgs{
+		UserId: user.ID,
+		Email:  emailToVerify,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue email verification email job: %w", err)
+	}
+
+	slog.Info("Enqueued email verification email job", "userID", user.ID)
+
+	return nil
+}
+
+// confirmEmailVerification marks the email in the token as verified, replacing the user's email if it was pending
+func confirmEmailVerification(request ConfirmEmailVerificationServiceRequest) error {
+	tx := request.Tx
+	params := request.Params
+	config := request.Config
+
+	userId, email, err := authentication.ValidateEmailVerificationJWT(config, params.Token)
+	if err != nil {
+		return api.ErrInvalidEmailVerificationToken
+	}
+
+	var user models.User
+	if err := tx.First(&user, userId).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return api.ErrInvalidEmailVerificationToken
+		}
+		return err
+	}
+
+	now := time.Now()
+
+	switch {
+	case user.PendingEmail != "" && email == user.PendingEmail:
+		// Only one change can go through if the link is submitted concurrently
+		result := tx.Model(&models.User{}).Where("id = ? AND pending_email = ?", user.ID, email).Updates(map[string]any{
+			"email":             email,
+			"pending_email":     "",
+			"email_verified_at": now,
+		})
+		if result.Error != nil {
+			// Someone else may have registered the address since the change was requested
+			if api.IsUniqueConstraintViolation(result.Error) {
+				return api.ErrUserEmailAlreadyExists
+			}
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return api.ErrInvalidEmailVerificationToken
+		}
+
+		slog.Info("Changed user email after verification", "userID", user.ID)
+
+		// Let the old address know, in case the change wasn't made by the user
+		err := enqueueCredentialChangeEmail(tx, request.RiverClient, CredentialChangeEmailJobArgs{
+			UserId:    user.ID,
+			Change:    constants.CredentialChangeEmail,
+			Email:     user.Email,
+			NewEmail:  email,
+			ChangedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+	case email == user.Email:
+		if user.EmailVerifiedAt != nil {
+			return nil
+		}
+
+		err := tx.Model(&models.User{}).Where("id = ? AND email = ?", user.ID, email).Update("email_verified_at", now).Error
+		if err != nil {
+			return err
+		}
+
+		slog.Info("Verified user email", "userID", user.ID)
+	default:
+		// The link was sent for an address the user has since moved away from
+		return api.ErrInvalidEmailVerificationToken
+	}
+
+	return nil
+}
+
+// getEmailToVerify returns the address the user still has to verify, or an empty string if there is none
+func getEmailToVerify(user *models.User) string {
+	if user.PendingEmail != "" {
+		return user.PendingEmail
+	}
+
+	if user.EmailVerifiedAt == nil {
+		return user.Email
+	}
+
+	return ""
+}
+
+// Password Reset Service Functions
+
+// requestPasswordReset emails a password reset link to the user with the given email.
+// Nothing happens if there is no such user, callers shouldn't reveal whether the email is registered.
+func requestPasswordReset(request RequestPasswordResetServiceRequest) error {
+	tx := request.Tx
+	params := request.Params
+	config := request.Config
+	riverClient := request.RiverClient
+
+	err := throttleEmailLinkRequest(tx, config, params.Email, params.IpAddress)
+	if err != nil {
+		return err
+	}
+
+	var user models.User
+	err = tx.Where("email = ?", params.Email).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.Info("Password reset requested for unknown email")
+			return nil
+		}
+		return err
+	}
+
+	// Enqueue background job to send the password reset email, the token is created when it is sent
+	sqlTx := utils.GetGormSQLTx(tx)
+	_, err = riverClient.InsertTx(tx.Statement.Context, sqlTx, PasswordResetEmailJobArgs{
+		UserId: user.ID,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue password reset email job: %w", err)
+	}
+
+	slog.Info("Enqueued password reset email job", "userID", user.ID)
+
+	return nil
+}
+
+// confirmPasswordReset sets a new password using a password reset token, and signs the user out everywhere
+func confirmPasswordReset(request ConfirmPasswordResetServiceRequest) error {
+	tx := request.Tx
+	params := request.Params
+	config := request.Config
+
+	var resetToken models.PasswordResetToken
+	err := tx.Where("token_hash = ?", authentication.HashOpaqueToken(params.Token)).First(&resetToken).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return api.ErrInvalidPasswordResetToken
+		}
+		return err
+	}
+
+	if resetToken.UsedAt != nil || time.Now().After(resetToken.ExpiresAt) {
+		return api.ErrInvalidPasswordResetToken
+	}
+
+	// Check the new password before using up the token, so the user can try again with another one
+	var user models.User
+	err = tx.First(&user, resetToken.UserID).Error
+	if err != nil {
+		return err
+	}
+
+	err = authentication.CheckPasswordPolicy(config, authentication.PasswordPolicyParams{
+		Password: params.Password,
+		Name:     user.Name,
+		Email:    user.Email,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Mark the token as used, only one request can succeed if the link is submitted concurrently
+	result := tx.Model(&models.PasswordResetToken{}).Where("id = ? AND used_at IS NULL", resetToken.ID).Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return api.ErrInvalidPasswordResetToken
+	}
+
+	hashedPassword, err := authentication.HashPassword(params.Password, config)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Model(&models.User{}).Where("id = ?", resetToken.UserID).Update("hashed_password", hashedPassword).Error
+	if err != nil {
+		return err
+	}
+
+	// Whoever had access to the account before the reset shouldn't keep it
+	err = authentication.RevokeUserTokens(tx, resetToken.UserID, false)
+	if err != nil {
+		return err
+	}
+
+	return enqueueCredentialChangeEmail(tx, request.RiverClient, CredentialChangeEmailJobArgs{
+		UserId:    user.ID,
+		Change:    constants.CredentialChangePassword,
+		Email:     user.Email,
+		ChangedAt: time.Now(),
+	})
+}
+
+// Data Export Service Functions
+
+// createUserDataExport starts putting together an archive of the user's data in the background. If one is already
+// being put together, that export is returned instead of starting another.
+func createUserDataExport(request CreateUserDataExportServiceRequest) (*models.UserDataExport, error) {
+	tx := request.Tx
+	riverClient := request.RiverClient
+
+	var pendingExports []models.UserDataExport
+	err := tx.Where("user_id = ? AND status = ?", request.UserID, constants.UserDataExportStatusPending).Limit(1).Find(&pendingExports).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pendingExports) > 0 {
+		return &pendingExports[0], nil
+	}
+
+	export := models.UserDataExport{
+		UserID: request.UserID,
+		Status: string(constants.UserDataExportStatusPending),
+	}
+
+	err = tx.Create(&export).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Enqueue background job to put the archive together and email the download link
+	sqlTx := utils.GetGormSQLTx(tx)
+	_, err = riverClient.InsertTx(tx.Statement.Context, sqlTx, UserDataExportJobArgs{
+		UserDataExportId: export.ID,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enqueue user data export job: %w", err)
+	}
+
+	slog.Info("Started user data export", "userID", request.UserID, "userDataExportID", export.ID)
+
+	return &export, nil
+}
+
+// getUserDataExport returns the user's most recent data export
+func getUserDataExport(request GetUserDataExportServiceRequest) (*models.UserDataExport, error) {
+	var exports []models.UserDataExport
+	err := request.Tx.Where("user_id = ?", request.UserID).Order("created_at DESC").Limit(1).Find(&exports).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(exports) == 0 {
+		return nil, api.ErrUserDataExportNotFound
+	}
+
+	return &exports[0], nil
+}
+
+// buildUserDataExportArchive puts the user's profile, memberships, invitations and uploaded files into a ZIP archive
+func buildUserDataExportArchive(ctx context.Context, tx *gorm.DB, minioClient *minio.Client, user *models.User) ([]byte, error) {
+	var memberships []models.OrganizationMembership
+	err := tx.Preload("Organization").Where("user_id = ?", user.ID).Order("created_at").Find(&memberships).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var sentInvitations []models.OrganizationInvitation
+	err = tx.Preload("Organization").Where("inviting_user_id = ?", user.ID).Order("created_at").Find(&sentInvitations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var receivedInvitations []models.OrganizationInvitation
+	err = tx.Preload("Organization").Where("email = ?", user.Email).Order("created_at").Find(&receivedInvitations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := bytes.Buffer{}
+	archive := zip.NewWriter(&buffer)
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{name: "profile.json", data: mapUserToDataExportProfile(user)},
+		{name: "memberships.json", data: mapMembershipsToDataExport(memberships)},
+		{name: "invitations-sent.json", data: mapInvitationsToDataExport(sentInvitations)},
+		{name: "invitations-received.json", data: mapInvitationsToDataExport(receivedInvitations)},
+	}
+
+	for _, f := range files {
+		file, err := archive.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(f.data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if user.LogoFileStorageKey != "" {
+		object, err := minioClient.GetObject(ctx, string(constants.StorageBucketUserLogos), user.LogoFileStorageKey, minio.GetObjectOptions{})
+		if err != nil {
+			return nil, err
+		}
+		defer object.Close()
+
+		info, err := object.Stat()
+		if err != nil {
+			return nil, err
+		}
+
+		name := "files/logo"
+		extensions, _ := mime.ExtensionsByType(info.ContentType)
+		if len(extensions) > 0 {
+			name += extensions[0]
+		}
+
+		file, err := archive.Create(name)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = io.Copy(file, object)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = archive.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
@@ -1,14 +1,33 @@
 package users
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
+	"slices"
 	"testing"
+	"time"
 
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
 	"github.com/google/uuid"
 	"github.com/minio/minio-go/v7"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/riverdriver/riverdatabasesql"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 	"reece.start/internal/api"
+	"reece.start/internal/authentication"
+	"reece.start/internal/constants"
 	"reece.start/internal/models"
 	testconfig "reece.start/test/config"
 	testdb "reece.start/test/db"
@@ -137,6 +156,47 @@ func TestLoginUser(t *testing.T) {
 		assert.NotEmpty(t, result.Token)
 	})
 
+	t.Run("upgrades legacy bcrypt password hash", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		createdUser, err := createUser(CreateUserServiceRequest{
+			Params: CreateUserParams{
+				Name:     "Test User",
+				Email:    "rehash@example.com",
+				Password: "password123",
+				Timezone: "UTC",
+			},
+			Tx:            tx,
+			Config:        config,
+			PostHogClient: posthogClient,
+		})
+		require.NoError(t, err)
+
+		// Store a hash made before argon2id was introduced
+		legacyHash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+		require.NoError(t, err)
+		err = tx.Model(&models.User{}).Where("id = ?", createdUser.User.ID).Update("hashed_password", legacyHash).Error
+		require.NoError(t, err)
+
+		_, err = loginUser(LoginUserServiceRequest{
+			Params: LoginUserParams{
+				Email:    "rehash@example.com",
+				Password: "password123",
+			},
+			Tx:          tx,
+			Config:      config,
+			MinioClient: minioClient,
+		})
+		require.NoError(t, err)
+
+		var user models.User
+		err = tx.First(&user, createdUser.User.ID).Error
+		require.NoError(t, err)
+		assert.False(t, authentication.PasswordNeedsRehash(string(user.HashedPassword), config))
+		assert.True(t, authentication.CheckPasswordHash("password123", string(user.HashedPassword)))
+	})
+
 	t.Run("returns error for invalid email", func(t *testing.T) {
 		tx := db.Begin()
 		defer tx.Rollback()
@@ -240,6 +300,22 @@ func TestUpdateUser(t *testing.T) {
 	db := testdb.SetupDB(t)
 	var minioClient *minio.Client // nil for tests
 	config := testconfig.CreateTestConfig()
+	riverClient := newInsertOnlyRiverClient(t, db)
+
+	// createUserWithPassword creates a user who can confirm changes to their email or password with currentPassword
+	currentPassword := "currentpassword123"
+	createUserWithPassword := func(t *testing.T, tx *gorm.DB, email string) *models.User {
+		hashedPassword, err := authentication.HashPassword(currentPassword, config)
+		require.NoError(t, err)
+
+		user := &models.User{
+			Name:           "Test User",
+			Email:          email,
+			HashedPassword: hashedPassword,
+		}
+		require.NoError(t, tx.Create(user).Error)
+		return user
+	}
 
 	t.Run("updates user name", func(t *testing.T) {
 		tx := db.Begin()
@@ -270,21 +346,16 @@ func TestUpdateUser(t *testing.T) {
 		assert.Equal(t, "update@example.com", result.User.Email)
 	})
 
-	t.Run("updates user email", func(t *testing.T) {
+	t.Run("stores changed email as pending", func(t *testing.T) {
 		tx := db.Begin()
 		defer tx.Rollback()
 
-		// Create a user first
-		user := &models.User{
-			Name:  "Test User",
-			Email: "oldemail@example.com",
-		}
-		err := tx.Create(user).Error
-		require.NoError(t, err)
+		user := createUserWithPassword(t, tx, "oldemail@example.com")
 
 		params := UpdateUserParams{
-			UserID: user.ID,
-			Email:  "newemail@example.com",
+			UserID:          user.ID,
+			Email:           "newemail@example.com",
+			CurrentPassword: currentPassword,
 		}
 		result, err := updateUser(UpdateUserServiceRequest{
 			Params:      params,
@@ -295,39 +366,202 @@ func TestUpdateUser(t *testing.T) {
 
 		require.NoError(t, err)
 		assert.NotNil(t, result)
-		assert.Equal(t, "newemail@example.com", result.User.Email)
+		assert.Equal(t, "oldemail@example.com", result.User.Email)
+		assert.Equal(t, "newemail@example.com", result.User.PendingEmail)
+	})
+
+	t.Run("returns error when changing to a taken email", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createUserWithPassword(t, tx, "changer@example.com")
+		other := &models.User{Name: "Other User", Email: "taken@example.com"}
+		require.NoError(t, tx.Create(other).Error)
+
+		_, err := updateUser(UpdateUserServiceRequest{
+			Params:      UpdateUserParams{UserID: user.ID, Email: "taken@example.com", CurrentPassword: currentPassword},
+			Tx:          tx,
+			MinioClient: minioClient,
+			Config:      config,
+		})
+
+		assert.ErrorIs(t, err, api.ErrUserEmailAlreadyExists)
 	})
 
 	t.Run("updates user password", func(t *testing.T) {
 		tx := db.Begin()
 		defer tx.Rollback()
 
-		// Create a user first
-		user := &models.User{
-			Name:  "Test User",
-			Email: "password@example.com",
-		}
-		err := tx.Create(user).Error
-		require.NoError(t, err)
+		user := createUserWithPassword(t, tx, "password@example.com")
 
 		params := UpdateUserParams{
-			UserID:   user.ID,
-			Password: "newpassword123",
+			UserID:          user.ID,
+			Password:        "newpassword123",
+			CurrentPassword: currentPassword,
 		}
 		result, err := updateUser(UpdateUserServiceRequest{
 			Params:      params,
 			Tx:          tx,
 			MinioClient: minioClient,
 			Config:      config,
+			RiverClient: riverClient,
 		})
 
 		require.NoError(t, err)
 		assert.NotNil(t, result)
-		assert.NotNil(t, result.User.HashedPassword)
-		assert.NotEmpty(t, result.User.HashedPassword)
+		assert.True(t, authentication.CheckPasswordHash("newpassword123", string(result.User.HashedPassword)))
+
+		// Every token issued before the change is revoked
+		require.NotNil(t, result.User.Revocation.LastValidIssuedAt)
+		assert.False(t, result.User.Revocation.CanRefresh)
+	})
+
+	t.Run("requires current password to change credentials", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createUserWithPassword(t, tx, "nopassword@example.com")
+
+		_, err := updateUser(UpdateUserServiceRequest{
+			Params:      UpdateUserParams{UserID: user.ID, Password: "newpassword123"},
+			Tx:          tx,
+			MinioClient: minioClient,
+			Config:      config,
+		})
+		assert.ErrorIs(t, err, api.ErrCurrentPasswordRequired)
+
+		_, err = updateUser(UpdateUserServiceRequest{
+			Params:      UpdateUserParams{UserID: user.ID, Email: "stolen@example.com"},
+			Tx:          tx,
+			MinioClient: minioClient,
+			Config:      config,
+		})
+		assert.ErrorIs(t, err, api.ErrCurrentPasswordRequired)
+	})
+
+	t.Run("rejects wrong current password", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createUserWithPassword(t, tx, "wrongpassword@example.com")
+
+		_, err := updateUser(UpdateUserServiceRequest{
+			Params:      UpdateUserParams{UserID: user.ID, Password: "newpassword123", CurrentPassword: "notthepassword"},
+			Tx:          tx,
+			MinioClient: minioClient,
+			Config:      config,
+		})
+		assert.ErrorIs(t, err, api.ErrCurrentPasswordInvalid)
+	})
+
+	t.Run("unchanged email doesn't need current password", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createUserWithPassword(t, tx, "unchanged@example.com")
+
+		result, err := updateUser(UpdateUserServiceRequest{
+			Params:      UpdateUserParams{UserID: user.ID, Name: "New Name", Email: user.Email},
+			Tx:          tx,
+			MinioClient: minioClient,
+			Config:      config,
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, "New Name", result.User.Name)
+	})
+
+	t.Run("recent sign in doesn't need current password", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createUserWithPassword(t, tx, "recent@example.com")
+		session := models.UserSession{
+			UserID:     user.ID,
+			LastSeenAt: time.Now(),
+			ExpiresAt:  time.Now().Add(time.Hour),
+		}
+		require.NoError(t, tx.Create(&session).Error)
+
+		result, err := updateUser(UpdateUserServiceRequest{
+			Params:      UpdateUserParams{UserID: user.ID, Password: "newpassword123", SessionID: session.ID},
+			Tx:          tx,
+			MinioClient: minioClient,
+			Config:      config,
+			RiverClient: riverClient,
+		})
+
+		require.NoError(t, err)
+
+		// The session that made the change gets new tokens
+		assert.NotEmpty(t, result.Token)
+		assert.NotEmpty(t, result.RefreshToken)
+	})
+
+	t.Run("old sign in needs current password", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createUserWithPassword(t, tx, "old@example.com")
+		session := models.UserSession{
+			UserID:     user.ID,
+			LastSeenAt: time.Now(),
+			ExpiresAt:  time.Now().Add(time.Hour),
+		}
+		require.NoError(t, tx.Create(&session).Error)
+
+		signedInAt := time.Now().Add(-time.Duration(config.ReauthenticationMaxAge+60) * time.Second)
+		require.NoError(t, tx.Model(&session).Update("created_at", signedInAt).Error)
+
+		_, err := updateUser(UpdateUserServiceRequest{
+			Params:      UpdateUserParams{UserID: user.ID, Password: "newpassword123", SessionID: session.ID},
+			Tx:          tx,
+			MinioClient: minioClient,
+			Config:      config,
+		})
+
+		assert.ErrorIs(t, err, api.ErrCurrentPasswordRequired)
+	})
+
+	t.Run("impersonating admin can't change credentials", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createUserWithPassword(t, tx, "impersonated@example.com")
+
+		_, err := updateUser(UpdateUserServiceRequest{
+			Params:      UpdateUserParams{UserID: user.ID, Password: "newpassword123", CurrentPassword: currentPassword, IsImpersonating: true},
+			Tx:          tx,
+			MinioClient: minioClient,
+			Config:      config,
+		})
+
+		assert.ErrorIs(t, err, api.ErrForbiddenImpersonationNotAllowed)
 	})
 }
 
+// createRecentSession signs the user in, so they can change how they sign in without entering their password
+func createRecentSession(t *testing.T, tx *gorm.DB, userId uuid.UUID) uuid.UUID {
+	session := models.UserSession{
+		UserID:     userId,
+		LastSeenAt: time.Now(),
+		ExpiresAt:  time.Now().Add(time.Hour),
+	}
+	require.NoError(t, tx.Create(&session).Error)
+	return session.ID
+}
+
+// newInsertOnlyRiverClient creates a River client that can enqueue jobs, without any workers to run them
+func newInsertOnlyRiverClient(t *testing.T, db *gorm.DB) *river.Client[*sql.Tx] {
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+
+	riverClient, err := river.NewClient(riverdatabasesql.New(sqlDB), &river.Config{})
+	require.NoError(t, err)
+
+	return riverClient
+}
+
 func TestGetUsers(t *testing.T) {
 	db := testdb.SetupDB(t)
 	var minioClient *minio.Client // nil for tests
@@ -423,3 +657,849 @@ func TestGetUsers(t *testing.T) {
 		assert.LessOrEqual(t, len(result2.Users), 100)
 	})
 }
+
+func TestRotateRefreshToken(t *testing.T) {
+	db := testdb.SetupDB(t)
+	config := testconfig.CreateTestConfig()
+	posthogClient := testmocks.NewMockPosthogClient()
+
+	createTestUser := func(t *testing.T, tx *gorm.DB, email string) *UserDto {
+		user, err := createUser(CreateUserServiceRequest{
+			Params: CreateUserParams{
+				Name:     "Test User",
+				Email:    email,
+				Password: "password123",
+			},
+			Tx:            tx,
+			Config:        config,
+			PostHogClient: posthogClient,
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, user.RefreshToken)
+		return user
+	}
+
+	t.Run("rotates refresh token within the same family", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createTestUser(t, tx, "rotate@example.com")
+
+		result, err := rotateRefreshToken(RotateRefreshTokenServiceRequest{
+			Params: RotateRefreshTokenParams{RefreshToken: user.RefreshToken},
+			Tx:     tx,
+			Config: config,
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, user.User.ID, result.UserId)
+		assert.NotEmpty(t, result.Tokens.AccessToken)
+		assert.NotEmpty(t, result.Tokens.RefreshToken)
+		assert.NotEqual(t, user.RefreshToken, result.Tokens.RefreshToken)
+
+		var oldToken, newToken models.RefreshToken
+		require.NoError(t, tx.Where("token_hash = ?", authentication.HashRefreshToken(user.RefreshToken)).First(&oldToken).Error)
+		require.NoError(t, tx.Where("token_hash = ?", authentication.HashRefreshToken(result.Tokens.RefreshToken)).First(&newToken).Error)
+		assert.NotNil(t, oldToken.RotatedAt)
+		assert.Equal(t, oldToken.FamilyID, newToken.FamilyID)
+	})
+
+	t.Run("reusing a rotated token revokes the family", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createTestUser(t, tx, "reuse@example.com")
+
+		rotated, err := rotateRefreshToken(RotateRefreshTokenServiceRequest{
+			Params: RotateRefreshTokenParams{RefreshToken: user.RefreshToken},
+			Tx:     tx,
+			Config: config,
+		})
+		require.NoError(t, err)
+
+		// Replay the original token
+		_, err = rotateRefreshToken(RotateRefreshTokenServiceRequest{
+			Params: RotateRefreshTokenParams{RefreshToken: user.RefreshToken},
+			Tx:     tx,
+			Config: config,
+		})
+		assert.ErrorIs(t, err, api.ErrInvalidRefreshToken)
+
+		// The latest token in the family no longer works either
+		_, err = rotateRefreshToken(RotateRefreshTokenServiceRequest{
+			Params: RotateRefreshTokenParams{RefreshToken: rotated.Tokens.RefreshToken},
+			Tx:     tx,
+			Config: config,
+		})
+		assert.ErrorIs(t, err, api.ErrInvalidRefreshToken)
+	})
+
+	t.Run("keeps the token usable when issuing the new one fails", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createTestUser(t, tx, "rotate-fails@example.com")
+
+		// A revoked session can't be refreshed, which fails after the token has been marked as rotated
+		require.NoError(t, tx.Model(&models.UserSession{}).Where("user_id = ?", user.User.ID).Update("revoked_at", time.Now()).Error)
+
+		_, err := rotateRefreshToken(RotateRefreshTokenServiceRequest{
+			Params: RotateRefreshTokenParams{RefreshToken: user.RefreshToken},
+			Tx:     tx,
+			Config: config,
+		})
+		assert.ErrorIs(t, err, api.ErrReauthenticationRequired)
+
+		var token models.RefreshToken
+		require.NoError(t, tx.Where("token_hash = ?", authentication.HashRefreshToken(user.RefreshToken)).First(&token).Error)
+		assert.Nil(t, token.RotatedAt)
+		assert.Nil(t, token.RevokedAt)
+	})
+
+	t.Run("returns error for unknown token", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		_, err := rotateRefreshToken(RotateRefreshTokenServiceRequest{
+			Params: RotateRefreshTokenParams{RefreshToken: "not-a-real-token"},
+			Tx:     tx,
+			Config: config,
+		})
+		assert.ErrorIs(t, err, api.ErrInvalidRefreshToken)
+	})
+
+	t.Run("returns error for expired token", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createTestUser(t, tx, "expired@example.com")
+
+		err := tx.Model(&models.RefreshToken{}).Where("user_id = ?", user.User.ID).Update("expires_at", time.Now().Add(-time.Minute)).Error
+		require.NoError(t, err)
+
+		_, err = rotateRefreshToken(RotateRefreshTokenServiceRequest{
+			Params: RotateRefreshTokenParams{RefreshToken: user.RefreshToken},
+			Tx:     tx,
+			Config: config,
+		})
+		assert.ErrorIs(t, err, api.ErrInvalidRefreshToken)
+	})
+
+	t.Run("returns error after tokens are revoked without refresh", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createTestUser(t, tx, "revoked@example.com")
+
+		require.NoError(t, authentication.RevokeUserTokens(tx, user.User.ID, false))
+
+		_, err := rotateRefreshToken(RotateRefreshTokenServiceRequest{
+			Params: RotateRefreshTokenParams{RefreshToken: user.RefreshToken},
+			Tx:     tx,
+			Config: config,
+		})
+		assert.ErrorIs(t, err, api.ErrInvalidRefreshToken)
+	})
+}
+
+func TestMfa(t *testing.T) {
+	db := testdb.SetupDB(t)
+	config := testconfig.CreateTestConfig()
+	posthogClient := testmocks.NewMockPosthogClient()
+	riverClient := newInsertOnlyRiverClient(t, db)
+	var minioClient *minio.Client // nil for tests
+
+	// enrollUser creates a user with TOTP MFA enabled and returns the user, their secret and recovery codes
+	enrollUser := func(t *testing.T, tx *gorm.DB, email string) (*UserDto, string, []string) {
+		user, err := createUser(CreateUserServiceRequest{
+			Params: CreateUserParams{
+				Name:     "Test User",
+				Email:    email,
+				Password: "password123",
+			},
+			Tx:            tx,
+			Config:        config,
+			PostHogClient: posthogClient,
+		})
+		require.NoError(t, err)
+
+		enrollment, err := startTotpEnrollment(StartTotpEnrollmentServiceRequest{
+			UserID: user.User.ID,
+			Tx:     tx,
+			Config: config,
+		})
+		require.NoError(t, err)
+		assert.Contains(t, enrollment.OtpauthUri, enrollment.Secret)
+
+		// Use the previous period's code so the tests can still use the current one
+		code, err := authentication.GenerateTotpCode(enrollment.Secret, time.Now().Add(-30*time.Second))
+		require.NoError(t, err)
+
+		recoveryCodes, err := confirmTotpEnrollment(ConfirmTotpEnrollmentServiceRequest{
+			Params: ConfirmTotpEnrollmentParams{UserID: user.User.ID, Code: code},
+			Tx:     tx,
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, recoveryCodes.Codes)
+
+		return user, enrollment.Secret, recoveryCodes.Codes
+	}
+
+	login := func(t *testing.T, tx *gorm.DB, email string) *UserDto {
+		result, err := loginUser(LoginUserServiceRequest{
+			Params:      LoginUserParams{Email: email, Password: "password123"},
+			Tx:          tx,
+			Config:      config,
+			MinioClient: minioClient,
+		})
+		require.NoError(t, err)
+		return result
+	}
+
+	t.Run("password login requires a second factor", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user, secret, _ := enrollUser(t, tx, "mfa-login@example.com")
+
+		challenge := login(t, tx, "mfa-login@example.com")
+		assert.Empty(t, challenge.Token)
+		assert.Empty(t, challenge.RefreshToken)
+		require.NotEmpty(t, challenge.MfaChallengeToken)
+
+		code, err := authentication.GenerateTotpCode(secret, time.Now())
+		require.NoError(t, err)
+
+		result, err := loginUserWithMfa(LoginMfaServiceRequest{
+			Params:      LoginMfaParams{MfaChallengeToken: challenge.MfaChallengeToken, Code: code},
+			Tx:          tx,
+			Config:      config,
+			MinioClient: minioClient,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, user.User.ID, result.User.ID)
+		assert.NotEmpty(t, result.Token)
+		assert.NotEmpty(t, result.RefreshToken)
+
+		// The same code can't be used again
+		_, err = loginUserWithMfa(LoginMfaServiceRequest{
+			Params:      LoginMfaParams{MfaChallengeToken: challenge.MfaChallengeToken, Code: code},
+			Tx:          tx,
+			Config:      config,
+			MinioClient: minioClient,
+		})
+		assert.ErrorIs(t, err, api.ErrMfaInvalidCode)
+	})
+
+	t.Run("recovery codes can only be used once", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		_, _, recoveryCodes := enrollUser(t, tx, "mfa-recovery@example.com")
+		challenge := login(t, tx, "mfa-recovery@example.com")
+
+		request := LoginMfaServiceRequest{
+			Params:      LoginMfaParams{MfaChallengeToken: challenge.MfaChallengeToken, RecoveryCode: recoveryCodes[0]},
+			Tx:          tx,
+			Config:      config,
+			MinioClient: minioClient,
+		}
+
+		_, err := loginUserWithMfa(request)
+		require.NoError(t, err)
+
+		_, err = loginUserWithMfa(request)
+		assert.ErrorIs(t, err, api.ErrMfaInvalidCode)
+	})
+
+	t.Run("rejects invalid code", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		enrollUser(t, tx, "mfa-invalid@example.com")
+		challenge := login(t, tx, "mfa-invalid@example.com")
+
+		_, err := loginUserWithMfa(LoginMfaServiceRequest{
+			Params:      LoginMfaParams{MfaChallengeToken: challenge.MfaChallengeToken, RecoveryCode: "aaaa-bbbb-cccc-dddd"},
+			Tx:          tx,
+			Config:      config,
+			MinioClient: minioClient,
+		})
+		assert.ErrorIs(t, err, api.ErrMfaInvalidCode)
+	})
+
+	t.Run("locks out the challenge after too many invalid codes", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		_, secret, _ := enrollUser(t, tx, "mfa-lockout@example.com")
+		challenge := login(t, tx, "mfa-lockout@example.com")
+
+		request := LoginMfaServiceRequest{
+			Params:      LoginMfaParams{MfaChallengeToken: challenge.MfaChallengeToken, Code: "000000"},
+			Tx:          tx,
+			Config:      config,
+			MinioClient: minioClient,
+			RiverClient: riverClient,
+		}
+
+		for range config.MfaLockoutThreshold {
+			_, err := loginUserWithMfa(request)
+			require.ErrorIs(t, err, api.ErrMfaInvalidCode)
+		}
+
+		// Even the right code is rejected now
+		code, err := authentication.GenerateTotpCode(secret, time.Now())
+		require.NoError(t, err)
+		request.Params.Code = code
+
+		_, err = loginUserWithMfa(request)
+		assert.ErrorIs(t, err, api.ErrMfaChallengeLocked)
+
+		var jobCount int64
+		require.NoError(t, tx.Raw(`SELECT COUNT(*) FROM river_job WHERE kind = ?`, string(constants.JobKindLoginLockoutEmail)).Scan(&jobCount).Error)
+		assert.Equal(t, int64(1), jobCount)
+	})
+
+	t.Run("cannot enroll twice", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user, _, _ := enrollUser(t, tx, "mfa-twice@example.com")
+
+		_, err := startTotpEnrollment(StartTotpEnrollmentServiceRequest{
+			UserID: user.User.ID,
+			Tx:     tx,
+			Config: config,
+		})
+		assert.ErrorIs(t, err, api.ErrMfaAlreadyEnabled)
+	})
+
+	t.Run("confirm requires enrollment to be started", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user, err := createUser(CreateUserServiceRequest{
+			Params: CreateUserParams{
+				Name:     "Test User",
+				Email:    "mfa-not-started@example.com",
+				Password: "password123",
+			},
+			Tx:            tx,
+			Config:        config,
+			PostHogClient: posthogClient,
+		})
+		require.NoError(t, err)
+
+		_, err = confirmTotpEnrollment(ConfirmTotpEnrollmentServiceRequest{
+			Params: ConfirmTotpEnrollmentParams{UserID: user.User.ID, Code: "123456"},
+			Tx:     tx,
+		})
+		assert.ErrorIs(t, err, api.ErrMfaEnrollmentNotStarted)
+	})
+
+	t.Run("disabling MFA restores password only login", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user, _, recoveryCodes := enrollUser(t, tx, "mfa-disable@example.com")
+
+		err := disableMfa(DisableMfaServiceRequest{
+			Params: DisableMfaParams{UserID: user.User.ID, RecoveryCode: recoveryCodes[0]},
+			Tx:     tx,
+		})
+		require.NoError(t, err)
+
+		result := login(t, tx, "mfa-disable@example.com")
+		assert.NotEmpty(t, result.Token)
+		assert.Empty(t, result.MfaChallengeToken)
+
+		var count int64
+		require.NoError(t, tx.Model(&models.MfaRecoveryCode{}).Where("user_id = ?", user.User.ID).Count(&count).Error)
+		assert.Zero(t, count)
+	})
+
+	t.Run("admin reset turns off MFA", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user, _, _ := enrollUser(t, tx, "mfa-reset@example.com")
+
+		err := resetUserMfa(ResetUserMfaServiceRequest{UserID: user.User.ID, Tx: tx})
+		require.NoError(t, err)
+
+		result := login(t, tx, "mfa-reset@example.com")
+		assert.NotEmpty(t, result.Token)
+	})
+}
+
+func TestPasskeys(t *testing.T) {
+	db := testdb.SetupDB(t)
+	config := testconfig.CreateTestConfig()
+	posthogClient := testmocks.NewMockPosthogClient()
+	var minioClient *minio.Client // nil for tests
+	riverClient := newInsertOnlyRiverClient(t, db)
+
+	createTestUser := func(t *testing.T, tx *gorm.DB, email string) *UserDto {
+		user, err := createUser(CreateUserServiceRequest{
+			Params: CreateUserParams{
+				Name:     "Test User",
+				Email:    email,
+				Password: "password123",
+			},
+			Tx:            tx,
+			Config:        config,
+			PostHogClient: posthogClient,
+		})
+		require.NoError(t, err)
+		return user
+	}
+
+	createTestPasskey := func(t *testing.T, tx *gorm.DB, userId uuid.UUID, name string) *models.Passkey {
+		passkey := models.Passkey{
+			UserID:       userId,
+			Name:         name,
+			CredentialID: []byte(uuid.NewString()),
+			PublicKey:    []byte("public-key"),
+		}
+		require.NoError(t, tx.Create(&passkey).Error)
+		return &passkey
+	}
+
+	t.Run("registration challenge can only be used once", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createTestUser(t, tx, "passkey-challenge@example.com")
+
+		challenge, err := beginPasskeyRegistration(BeginPasskeyRegistrationServiceRequest{
+			UserID: user.User.ID,
+			Tx:     tx,
+			Config: config,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, challenge.Options)
+
+		request := FinishPasskeyRegistrationServiceRequest{
+			Params: FinishPasskeyRegistrationParams{
+				UserID:           user.User.ID,
+				ChallengeId:      challenge.ChallengeId,
+				Name:             "Laptop",
+				Credential:       []byte(`{"id":"invalid"}`),
+				Reauthentication: ReauthenticationParams{SessionID: createRecentSession(t, tx, user.User.ID)},
+			},
+			Tx:          tx,
+			Config:      config,
+			RiverClient: riverClient,
+		}
+
+		_, err = finishPasskeyRegistration(request)
+		assert.ErrorIs(t, err, api.ErrPasskeyVerificationFailed)
+
+		_, err = finishPasskeyRegistration(request)
+		assert.ErrorIs(t, err, api.ErrPasskeyChallengeInvalid)
+	})
+
+	t.Run("registration challenge is bound to the user", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createTestUser(t, tx, "passkey-owner@example.com")
+		otherUser := createTestUser(t, tx, "passkey-other@example.com")
+
+		challenge, err := beginPasskeyRegistration(BeginPasskeyRegistrationServiceRequest{
+			UserID: user.User.ID,
+			Tx:     tx,
+			Config: config,
+		})
+		require.NoError(t, err)
+
+		_, err = finishPasskeyRegistration(FinishPasskeyRegistrationServiceRequest{
+			Params: FinishPasskeyRegistrationParams{
+				UserID:           otherUser.User.ID,
+				ChallengeId:      challenge.ChallengeId,
+				Name:             "Laptop",
+				Credential:       []byte(`{}`),
+				Reauthentication: ReauthenticationParams{SessionID: createRecentSession(t, tx, otherUser.User.ID)},
+			},
+			Tx:          tx,
+			Config:      config,
+			RiverClient: riverClient,
+		})
+		assert.ErrorIs(t, err, api.ErrPasskeyChallengeInvalid)
+	})
+
+	t.Run("registration needs a recent sign in", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createTestUser(t, tx, "passkey-stale@example.com")
+
+		challenge, err := beginPasskeyRegistration(BeginPasskeyRegistrationServiceRequest{
+			UserID: user.User.ID,
+			Tx:     tx,
+			Config: config,
+		})
+		require.NoError(t, err)
+
+		request := FinishPasskeyRegistrationServiceRequest{
+			Params: FinishPasskeyRegistrationParams{
+				UserID:      user.User.ID,
+				ChallengeId: challenge.ChallengeId,
+				Name:        "Laptop",
+				Credential:  []byte(`{"id":"invalid"}`),
+			},
+			Tx:          tx,
+			Config:      config,
+			RiverClient: riverClient,
+		}
+
+		_, err = finishPasskeyRegistration(request)
+		assert.ErrorIs(t, err, api.ErrCurrentPasswordRequired)
+
+		request.Params.Reauthentication = ReauthenticationParams{CurrentPassword: "password123", IsImpersonating: true}
+		_, err = finishPasskeyRegistration(request)
+		assert.ErrorIs(t, err, api.ErrForbiddenImpersonationNotAllowed)
+
+		// The challenge wasn't used by the rejected attempts
+		request.Params.Reauthentication = ReauthenticationParams{CurrentPassword: "password123"}
+		_, err = finishPasskeyRegistration(request)
+		assert.ErrorIs(t, err, api.ErrPasskeyVerificationFailed)
+	})
+
+	t.Run("expired login challenge is rejected", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		challenge, err := beginPasskeyLogin(BeginPasskeyLoginServiceRequest{
+			Tx:     tx,
+			Config: config,
+		})
+		require.NoError(t, err)
+
+		err = tx.Model(&models.PasskeyChallenge{}).Where("id = ?", challenge.ChallengeId).Update("expires_at", time.Now().Add(-time.Minute)).Error
+		require.NoError(t, err)
+
+		_, err = finishPasskeyLogin(FinishPasskeyLoginServiceRequest{
+			Params: FinishPasskeyLoginParams{
+				ChallengeId: challenge.ChallengeId,
+				Credential:  []byte(`{}`),
+			},
+			Tx:          tx,
+			Config:      config,
+			MinioClient: minioClient,
+		})
+		assert.ErrorIs(t, err, api.ErrPasskeyChallengeInvalid)
+	})
+
+	t.Run("rejects a counter that didn't increase without locking the passkey", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createTestUser(t, tx, "passkey-counter@example.com")
+		authenticator := newTestAuthenticator(t)
+		passkey := models.Passkey{
+			UserID:       user.User.ID,
+			Name:         "Laptop",
+			CredentialID: authenticator.credentialID,
+			PublicKey:    authenticator.publicKey,
+			SignCount:    5,
+		}
+		require.NoError(t, tx.Create(&passkey).Error)
+
+		login := func(signCount uint32) error {
+			challenge, err := beginPasskeyLogin(BeginPasskeyLoginServiceRequest{
+				Tx:     tx,
+				Config: config,
+			})
+			require.NoError(t, err)
+
+			_, err = finishPasskeyLogin(FinishPasskeyLoginServiceRequest{
+				Params: FinishPasskeyLoginParams{
+					ChallengeId: challenge.ChallengeId,
+					Credential:  authenticator.sign(t, challenge, user.User.ID, signCount),
+				},
+				Tx:          tx,
+				Config:      config,
+				MinioClient: minioClient,
+			})
+			return err
+		}
+
+		require.NoError(t, login(6))
+
+		// A replayed counter is rejected, and doesn't change what's stored
+		assert.ErrorIs(t, login(6), api.ErrPasskeyVerificationFailed)
+
+		var stored models.Passkey
+		require.NoError(t, tx.First(&stored, passkey.ID).Error)
+		assert.Equal(t, int64(6), stored.SignCount)
+
+		// The genuine authenticator can still sign in
+		require.NoError(t, login(7))
+
+		require.NoError(t, tx.First(&stored, passkey.ID).Error)
+		assert.Equal(t, int64(7), stored.SignCount)
+	})
+
+	t.Run("lists, renames and deletes passkeys", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createTestUser(t, tx, "passkey-manage@example.com")
+		passkey := createTestPasskey(t, tx, user.User.ID, "Laptop")
+		createTestPasskey(t, tx, user.User.ID, "Phone")
+
+		passkeys, err := getPasskeys(GetPasskeysServiceRequest{UserID: user.User.ID, Tx: tx})
+		require.NoError(t, err)
+		assert.Len(t, passkeys, 2)
+
+		updated, err := updatePasskey(UpdatePasskeyServiceRequest{
+			Params: UpdatePasskeyParams{UserID: user.User.ID, PasskeyID: passkey.ID, Name: "Work laptop"},
+			Tx:     tx,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "Work laptop", updated.Name)
+
+		err = deletePasskey(DeletePasskeyServiceRequest{UserID: user.User.ID, PasskeyID: passkey.ID, Tx: tx})
+		require.NoError(t, err)
+
+		passkeys, err = getPasskeys(GetPasskeysServiceRequest{UserID: user.User.ID, Tx: tx})
+		require.NoError(t, err)
+		assert.Len(t, passkeys, 1)
+		assert.Equal(t, "Phone", passkeys[0].Name)
+	})
+
+	t.Run("cannot manage another user's passkeys", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createTestUser(t, tx, "passkey-mine@example.com")
+		otherUser := createTestUser(t, tx, "passkey-theirs@example.com")
+		passkey := createTestPasskey(t, tx, otherUser.User.ID, "Laptop")
+
+		_, err := updatePasskey(UpdatePasskeyServiceRequest{
+			Params: UpdatePasskeyParams{UserID: user.User.ID, PasskeyID: passkey.ID, Name: "Mine now"},
+			Tx:     tx,
+		})
+		assert.ErrorIs(t, err, api.ErrPasskeyNotFound)
+
+		err = deletePasskey(DeletePasskeyServiceRequest{UserID: user.User.ID, PasskeyID: passkey.ID, Tx: tx})
+		assert.ErrorIs(t, err, api.ErrPasskeyNotFound)
+	})
+}
+
+func TestUserIdentities(t *testing.T) {
+	db := testdb.SetupDB(t)
+	config := testconfig.CreateTestConfig()
+	riverClient := newInsertOnlyRiverClient(t, db)
+
+	// Users that signed up with an OAuth provider don't have a password
+	createOAuthUser := func(t *testing.T, tx *gorm.DB, email string) *models.User {
+		user := models.User{Name: "OAuth User", Email: email}
+		require.NoError(t, tx.Create(&user).Error)
+		return &user
+	}
+
+	createTestIdentity := func(t *testing.T, tx *gorm.DB, userId uuid.UUID, provider string) *models.UserIdentity {
+		identity := models.UserIdentity{
+			UserID:   userId,
+			Provider: provider,
+			Subject:  uuid.NewString(),
+		}
+		require.NoError(t, tx.Create(&identity).Error)
+		return &identity
+	}
+
+	t.Run("lists and unlinks identities", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createOAuthUser(t, tx, "identity-list@example.com")
+		google := createTestIdentity(t, tx, user.ID, "google")
+		createTestIdentity(t, tx, user.ID, "github")
+
+		identities, err := getUserIdentities(GetUserIdentitiesServiceRequest{UserID: user.ID, Tx: tx})
+		require.NoError(t, err)
+		assert.Len(t, identities, 2)
+
+		err = deleteUserIdentity(DeleteUserIdentityServiceRequest{UserID: user.ID, IdentityID: google.ID, Tx: tx})
+		require.NoError(t, err)
+
+		identities, err = getUserIdentities(GetUserIdentitiesServiceRequest{UserID: user.ID, Tx: tx})
+		require.NoError(t, err)
+		require.Len(t, identities, 1)
+		assert.Equal(t, "github", identities[0].Provider)
+	})
+
+	t.Run("refuses to remove the last login method", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createOAuthUser(t, tx, "identity-last@example.com")
+		identity := createTestIdentity(t, tx, user.ID, "google")
+
+		err := deleteUserIdentity(DeleteUserIdentityServiceRequest{UserID: user.ID, IdentityID: identity.ID, Tx: tx})
+		assert.ErrorIs(t, err, api.ErrLastLoginMethod)
+
+		// A passkey is another way to sign in
+		passkey := models.Passkey{UserID: user.ID, Name: "Laptop", CredentialID: []byte(uuid.NewString()), PublicKey: []byte("public-key")}
+		require.NoError(t, tx.Create(&passkey).Error)
+
+		err = deleteUserIdentity(DeleteUserIdentityServiceRequest{UserID: user.ID, IdentityID: identity.ID, Tx: tx})
+		require.NoError(t, err)
+
+		// Now the passkey is the last way to sign in
+		err = deletePasskey(DeletePasskeyServiceRequest{UserID: user.ID, PasskeyID: passkey.ID, Tx: tx})
+		assert.ErrorIs(t, err, api.ErrLastLoginMethod)
+	})
+
+	t.Run("cannot unlink another user's identity", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createOAuthUser(t, tx, "identity-mine@example.com")
+		otherUser := createOAuthUser(t, tx, "identity-theirs@example.com")
+		createTestIdentity(t, tx, otherUser.ID, "github")
+		identity := createTestIdentity(t, tx, otherUser.ID, "google")
+
+		err := deleteUserIdentity(DeleteUserIdentityServiceRequest{UserID: user.ID, IdentityID: identity.ID, Tx: tx})
+		assert.ErrorIs(t, err, api.ErrUserIdentityNotFound)
+	})
+
+	t.Run("sets initial password once", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createOAuthUser(t, tx, "identity-password@example.com")
+		reauthentication := ReauthenticationParams{SessionID: createRecentSession(t, tx, user.ID)}
+
+		err := setInitialPassword(SetInitialPasswordServiceRequest{
+			Params:      SetInitialPasswordParams{UserID: user.ID, Password: "password123", Reauthentication: reauthentication},
+			Tx:          tx,
+			Config:      config,
+			RiverClient: riverClient,
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, tx.First(user, user.ID).Error)
+		require.NotNil(t, user.HashedPassword)
+		assert.True(t, authentication.CheckPasswordHash("password123", string(user.HashedPassword)))
+
+		err = setInitialPassword(SetInitialPasswordServiceRequest{
+			Params:      SetInitialPasswordParams{UserID: user.ID, Password: "another-password", Reauthentication: reauthentication},
+			Tx:          tx,
+			Config:      config,
+			RiverClient: riverClient,
+		})
+		assert.ErrorIs(t, err, api.ErrPasswordAlreadySet)
+	})
+
+	t.Run("initial password needs a recent sign in", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		user := createOAuthUser(t, tx, "identity-stale@example.com")
+		sessionId := createRecentSession(t, tx, user.ID)
+
+		signedInAt := time.Now().Add(-time.Duration(config.ReauthenticationMaxAge+60) * time.Second)
+		require.NoError(t, tx.Model(&models.UserSession{}).Where("id = ?", sessionId).Update("created_at", signedInAt).Error)
+
+		err := setInitialPassword(SetInitialPasswordServiceRequest{
+			Params: SetInitialPasswordParams{
+				UserID:           user.ID,
+				Password:         "password123",
+				Reauthentication: ReauthenticationParams{SessionID: sessionId},
+			},
+			Tx:          tx,
+			Config:      config,
+			RiverClient: riverClient,
+		})
+		assert.ErrorIs(t, err, api.ErrCurrentPasswordRequired)
+
+		require.NoError(t, tx.First(user, user.ID).Error)
+		assert.Nil(t, user.HashedPassword)
+	})
+}
+
+func TestGetLoginThrottleDelay(t *testing.T) {
+	config := testconfig.CreateTestConfig()
+	config.LoginThrottleFreeAttempts = 3
+	config.LoginThrottleMaxDelay = 30
+
+	assert.Equal(t, time.Duration(0), getLoginThrottleDelay(config, 1))
+	assert.Equal(t, time.Duration(0), getLoginThrottleDelay(config, 3))
+	assert.Equal(t, 1*time.Second, getLoginThrottleDelay(config, 4))
+	assert.Equal(t, 2*time.Second, getLoginThrottleDelay(config, 5))
+	assert.Equal(t, 16*time.Second, getLoginThrottleDelay(config, 8))
+	assert.Equal(t, 30*time.Second, getLoginThrottleDelay(config, 9))
+	assert.Equal(t, 30*time.Second, getLoginThrottleDelay(config, 100))
+}
+
+// testAuthenticator signs passkey logins with an ES256 key, like a platform authenticator would
+type testAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	publicKey    []byte
+}
+
+func newTestAuthenticator(t *testing.T) *testAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: key.PublicKey.X.FillBytes(make([]byte, 32)),
+		YCoord: key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	require.NoError(t, err)
+
+	return &testAuthenticator{
+		key:          key,
+		credentialID: []byte(uuid.NewString()),
+		publicKey:    publicKey,
+	}
+}
+
+// sign returns the authenticator's response to a login challenge, reporting the given signature counter
+func (a *testAuthenticator) sign(t *testing.T, challenge *PasskeyChallengeDto, userId uuid.UUID, signCount uint32) []byte {
+	options := challenge.Options.(*protocol.CredentialAssertion)
+	config := testconfig.CreateTestConfig()
+
+	clientData, err := json.Marshal(map[string]string{
+		"type":      "webauthn.get",
+		"challenge": options.Response.Challenge.String(),
+		"origin":    config.WebauthnRpOrigins,
+	})
+	require.NoError(t, err)
+
+	// RP ID hash, user present and verified flags, and the signature counter
+	rpIdHash := sha256.Sum256([]byte(config.WebauthnRpId))
+	authenticatorData := append(rpIdHash[:], byte(protocol.FlagUserPresent|protocol.FlagUserVerified))
+	authenticatorData = binary.BigEndian.AppendUint32(authenticatorData, signCount)
+
+	clientDataHash := sha256.Sum256(clientData)
+	signedData := sha256.Sum256(append(slices.Clone(authenticatorData), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, signedData[:])
+	require.NoError(t, err)
+
+	encode := base64.RawURLEncoding.EncodeToString
+	response, err := json.Marshal(map[string]any{
+		"id":    encode(a.credentialID),
+		"rawId": encode(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    encode(clientData),
+			"authenticatorData": encode(authenticatorData),
+			"signature":         encode(signature),
+			"userHandle":        encode(userId[:]),
+		},
+	})
+	require.NoError(t, err)
+
+	return response
+}
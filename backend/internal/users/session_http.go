@@ -0,0 +1,306 @@
+package users
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"reece.start/internal/access"
+	"reece.start/internal/api"
+	"reece.start/internal/constants"
+	"reece.start/internal/middleware"
+)
+
+func CreateAuthenticatedUserTokenEndpoint(c echo.Context, req CreateAuthenticatedUserTokenRequest) error {
+	userId, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err
+	}
+
+	impersonatingUserId, _ := middleware.GetImpersonatingUserIDFromJWT(c)
+	var impersonatingUserIdPtr *uuid.UUID
+	if impersonatingUserId != uuid.Nil {
+		impersonatingUserIdPtr = &impersonatingUserId
+	}
+
+	impersonationSessionId, _ := middleware.GetImpersonationSessionIDFromJWT(c)
+	var impersonationSessionIdPtr *uuid.UUID
+	if impersonationSessionId != uuid.Nil {
+		impersonationSessionIdPtr = &impersonationSessionId
+	}
+
+	startImpersonating := req.Data.Relationships.ImpersonatedUser != nil
+	if startImpersonating {
+		if impersonatingUserIdPtr != nil {
+			// if the impersonating user id is not nil, then the user is already impersonating someone
+			return api.ErrForbiddenImpersonationNotAllowed
+		}
+
+		if err := access.HasAdminAccess(c, []constants.UserScope{constants.UserScopeAdminUsersImpersonate}); err != nil {
+			return err
+		}
+
+		// Every impersonation is logged, along with why it was needed
+		if strings.TrimSpace(req.Data.Meta.ImpersonationReason) == "" {
+			return api.ErrImpersonationReasonRequired
+		}
+
+		// set the user id to the impersonated user id
+		parsedImpersonatingUserId, err := api.ParseUserIDFromString(req.Data.Relationships.ImpersonatedUser.Data.Id)
+
+		if err != nil {
+			return err
+		}
+
+		actualUserId := userId
+		userId = parsedImpersonatingUserId
+		impersonatingUserIdPtr = &actualUserId
+	}
+
+	endImpersonationSessionId := impersonationSessionIdPtr
+	if req.Data.Meta.StopImpersonating {
+		if impersonatingUserIdPtr == nil {
+			// if the impersonating user id is nil, then the user is not impersonating anyone
+			return api.ErrForbiddenImpersonationNotAllowed
+		}
+
+		userId = *impersonatingUserIdPtr
+		impersonatingUserIdPtr = nil
+		impersonationSessionIdPtr = nil
+	}
+
+	var organizationId *uuid.UUID
+	if req.Data.Relationships.Organization != nil {
+		parsedOrgID, err := api.ParseOrganizationIDFromString(req.Data.Relationships.Organization.Data.Id)
+		if err != nil {
+			return err
+		}
+		organizationId = &parsedOrgID
+	}
+
+	// Switching organizations or impersonating someone keeps the current session
+	var sessionIdPtr *uuid.UUID
+	sessionId, _ := middleware.GetSessionIDFromJWT(c)
+	if sessionId != uuid.Nil {
+		sessionIdPtr = &sessionId
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+	riverClient := middleware.GetRiverClient(c)
+
+	var tokens *AuthenticatedUserTokenDto
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		var err error
+
+		if startImpersonating {
+			tokens, err = startImpersonation(StartImpersonationServiceRequest{
+				Params: StartImpersonationParams{
+					ImpersonatorUserId: *impersonatingUserIdPtr,
+					ImpersonatedUserId: userId,
+					OrganizationId:     organizationId,
+					Reason:             req.Data.Meta.ImpersonationReason,
+					ReadOnly:           req.Data.Meta.ImpersonationReadOnly,
+					NotifyUser:         req.Data.Meta.NotifyImpersonatedUser,
+					SessionId:          sessionIdPtr,
+					Client:             getSessionClient(c),
+				},
+				Tx:          tx,
+				Config:      config,
+				RiverClient: riverClient,
+			})
+			return err
+		}
+
+		if req.Data.Meta.StopImpersonating && endImpersonationSessionId != nil {
+			err = endImpersonation(EndImpersonationServiceRequest{
+				ImpersonationSessionID: *endImpersonationSessionId,
+				Tx:                     tx,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		tokens, err = createAuthenticatedUserToken(CreateAuthenticatedUserTokenServiceRequest{
+			Params: CreateAuthenticatedUserTokenParams{
+				UserId:                 userId,
+				OrganizationId:         organizationId,
+				ImpersonatingUserId:    impersonatingUserIdPtr,
+				ImpersonationSessionId: impersonationSessionIdPtr,
+				SessionId:              sessionIdPtr,
+				Client:                 getSessionClient(c),
+			},
+			Tx:     tx,
+			Config: config,
+		})
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, mapCreateAuthenticatedUserTokenToResponse(req, tokens))
+}
+
+func RefreshAuthenticatedUserTokenEndpoint(c echo.Context) error {
+	userId, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err
+	}
+
+	var organizationIdPtr *uuid.UUID
+	organizationId, _ := middleware.GetOrganizationIDFromJWT(c)
+	if organizationId != uuid.Nil {
+		organizationIdPtr = &organizationId
+	}
+
+	var impersonatingUserIdPtr *uuid.UUID
+	impersonatingUserId, _ := middleware.GetImpersonatingUserIDFromJWT(c)
+	if impersonatingUserId != uuid.Nil {
+		impersonatingUserIdPtr = &impersonatingUserId
+	}
+
+	var impersonationSessionIdPtr *uuid.UUID
+	impersonationSessionId, _ := middleware.GetImpersonationSessionIDFromJWT(c)
+	if impersonationSessionId != uuid.Nil {
+		impersonationSessionIdPtr = &impersonationSessionId
+	}
+
+	var sessionIdPtr *uuid.UUID
+	sessionId, _ := middleware.GetSessionIDFromJWT(c)
+	if sessionId != uuid.Nil {
+		sessionIdPtr = &sessionId
+	}
+
+	tx := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+
+	response, err := refreshAuthenticatedUserToken(RefreshAuthenticatedUserTokenServiceRequest{
+		Params: RefreshAuthenticatedUserTokenParams{
+			UserId:                 userId,
+			OrganizationId:         organizationIdPtr,
+			ImpersonatingUserId:    impersonatingUserIdPtr,
+			ImpersonationSessionId: impersonationSessionIdPtr,
+			SessionId:              sessionIdPtr,
+			IssuedAt:               middleware.GetIssuedAtFromJWT(c),
+			Client:                 getSessionClient(c),
+		},
+		Tx:     tx,
+		Config: config,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, mapRefreshAuthenticatedUserTokenToResponse(response))
+}
+
+func RotateRefreshTokenEndpoint(c echo.Context, req RotateRefreshTokenRequest) error {
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+
+	// Not run in a transaction, as revoking a reused token's family has to stick even though the request fails
+	response, err := rotateRefreshToken(RotateRefreshTokenServiceRequest{
+		Params: RotateRefreshTokenParams{
+			RefreshToken: req.Data.Attributes.RefreshToken,
+			Client:       getSessionClient(c),
+		},
+		Tx:     db.WithContext(c.Request().Context()),
+		Config: config,
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusOK, mapRefreshAuthenticatedUserTokenToResponse(response))
+}
+
+func GetUserSessionsEndpoint(c echo.Context) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	// Tokens issued before sessions were tracked don't belong to any session
+	currentSessionID, _ := middleware.GetSessionIDFromJWT(c)
+
+	db := middleware.GetDB(c)
+
+	sessions, err := getUserSessions(GetUserSessionsServiceRequest{
+		UserID: userID,
+		Tx:     db,
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusOK, mapUserSessionsToResponse(sessions, currentSessionID))
+}
+
+func DeleteUserSessionEndpoint(c echo.Context) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	sessionID, err := api.ParseUserSessionIDFromParams(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	db := middleware.GetDB(c)
+
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		return deleteUserSession(DeleteUserSessionServiceRequest{
+			UserID:    userID,
+			SessionID: sessionID,
+			Tx:        tx,
+		})
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func DeleteOtherUserSessionsEndpoint(c echo.Context) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	currentSessionID, _ := middleware.GetSessionIDFromJWT(c)
+
+	db := middleware.GetDB(c)
+
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		return deleteOtherUserSessions(DeleteOtherUserSessionsServiceRequest{
+			UserID:           userID,
+			CurrentSessionID: currentSessionID,
+			Tx:               tx,
+		})
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// getSessionClient describes the client making the request, to be recorded on the session it signs in to
+func getSessionClient(c echo.Context) SessionClientParams {
+	return SessionClientParams{
+		UserAgent: c.Request().UserAgent(),
+		IpAddress: c.RealIP(),
+	}
+}
@@ -0,0 +1,441 @@
+package users
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"reece.start/internal/api"
+	"reece.start/internal/authentication"
+	"reece.start/internal/configuration"
+	"reece.start/internal/constants"
+	"reece.start/internal/models"
+)
+
+func createAuthenticatedUserToken(request CreateAuthenticatedUserTokenServiceRequest) (*AuthenticatedUserTokenDto, error) {
+	tx := request.Tx
+	config := request.Config
+
+	var user models.User
+	err := tx.First(&user, request.Params.UserId).Error
+	if err != nil {
+		return nil, err
+	}
+
+	isImpersonating := false
+	var impersonatingUserId *uuid.UUID
+	if request.Params.ImpersonatingUserId != nil && *request.Params.ImpersonatingUserId != uuid.Nil {
+		isImpersonating = true
+		impersonatingUserId = request.Params.ImpersonatingUserId
+	}
+
+	// Signing in again cancels a scheduled account deletion, the deletion job skips users without one
+	if !isImpersonating && user.DeletionScheduledAt != nil {
+		err = tx.Model(&user).Update("deletion_scheduled_at", nil).Error
+		if err != nil {
+			return nil, err
+		}
+
+		slog.Info("Cancelled scheduled account deletion", "userID", user.ID)
+	}
+
+	// Impersonation tokens expire with the impersonation they are issued for
+	var impersonation *models.ImpersonationSession
+	if isImpersonating {
+		impersonation, err = getActiveImpersonation(tx, request.Params.ImpersonationSessionId)
+		if err != nil {
+			return nil, err
+		}
+
+		if impersonation == nil {
+			return nil, api.ErrImpersonationEnded
+		}
+	}
+
+	// Users are added to the organization that auto-joins their email's domain when they sign in or refresh their token
+	if !isImpersonating {
+		err = joinDomainOrganization(tx, &user)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	scopes, organizationRole, err := authentication.GetGrantedScopes(tx, &user, request.Params.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	userRole := constants.UserRole(user.Role)
+
+	// Tokens belong to the person who signed in, which is the admin while they are impersonating someone
+	sessionUserId := user.ID
+	if impersonatingUserId != nil {
+		sessionUserId = *impersonatingUserId
+	}
+
+	sessionId, err := upsertUserSession(tx, config, sessionUserId, request.Params.SessionId, request.Params.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	jwtOptions := authentication.JwtOptions{
+		UserId:              request.Params.UserId,
+		OrganizationId:      request.Params.OrganizationId,
+		OrganizationRole:    organizationRole,
+		Scopes:              &scopes,
+		Role:                &userRole,
+		IsImpersonating:     &isImpersonating,
+		ImpersonatingUserId: impersonatingUserId,
+		SessionId:           &sessionId,
+	}
+
+	// Use custom expiry if provided (for OAuth tokens)
+	if request.Params.CustomExpiry != nil {
+		jwtOptions.CustomExpiry = request.Params.CustomExpiry
+	}
+
+	refreshTokenExpiresAt := time.Now().Add(time.Duration(config.RefreshTokenExpirationTime) * time.Second)
+	if impersonation != nil {
+		jwtOptions.ImpersonationSessionId = &impersonation.ID
+		jwtOptions.IsReadOnly = &impersonation.ReadOnly
+
+		if impersonation.ExpiresAt.Before(time.Now().Add(time.Duration(config.JwtExpirationTime) * time.Second)) {
+			jwtOptions.CustomExpiry = &impersonation.ExpiresAt
+		}
+		refreshTokenExpiresAt = impersonation.ExpiresAt
+	}
+
+	token, err := authentication.CreateJWT(config, jwtOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pair the access token with a refresh token in the session's family
+	refreshToken, refreshTokenHash, err := authentication.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	refreshTokenModel := models.RefreshToken{
+		UserID:              user.ID,
+		FamilyID:            sessionId,
+		TokenHash:           refreshTokenHash,
+		ExpiresAt:           refreshTokenExpiresAt,
+		OrganizationID:      request.Params.OrganizationId,
+		ImpersonatingUserID: impersonatingUserId,
+	}
+	if impersonation != nil {
+		refreshTokenModel.ImpersonationSessionID = &impersonation.ID
+	}
+
+	err = tx.Create(&refreshTokenModel).Error
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := &AuthenticatedUserTokenDto{
+		AccessToken:  token,
+		RefreshToken: refreshToken,
+	}
+	if impersonation != nil {
+		tokens.ImpersonationExpiresAt = &impersonation.ExpiresAt
+	}
+
+	return tokens, nil
+}
+
+// getRefreshedTokenUser returns who refreshed tokens are issued for, along with the admin impersonating them and the
+// impersonation if there is one. Once the impersonation has ended or expired, the admin gets their own tokens back.
+func getRefreshedTokenUser(tx *gorm.DB, userId uuid.UUID, impersonatingUserId *uuid.UUID, impersonationSessionId *uuid.UUID) (uuid.UUID, *uuid.UUID, *uuid.UUID, error) {
+	if impersonatingUserId == nil {
+		return userId, nil, nil, nil
+	}
+
+	impersonation, err := getActiveImpersonation(tx, impersonationSessionId)
+	if err != nil {
+		return uuid.Nil, nil, nil, err
+	}
+
+	if impersonation == nil {
+		slog.Info("Impersonation is over, refreshing tokens for the impersonating user", "impersonatorUserID", *impersonatingUserId, "impersonatedUserID", userId)
+		return *impersonatingUserId, nil, nil, nil
+	}
+
+	return userId, impersonatingUserId, &impersonation.ID, nil
+}
+
+// upsertUserSession starts a new session for the user, or records that an existing session has been seen again.
+// Returns the ID of the session.
+func upsertUserSession(tx *gorm.DB, config *configuration.Config, userId uuid.UUID, sessionId *uuid.UUID, client SessionClientParams) (uuid.UUID, error) {
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(config.RefreshTokenExpirationTime) * time.Second)
+
+	if sessionId != nil {
+		var session models.UserSession
+		err := tx.Where("id = ?", *sessionId).Limit(1).Find(&session).Error
+		if err != nil {
+			return uuid.Nil, err
+		}
+
+		if session.ID != uuid.Nil {
+			if session.RevokedAt != nil {
+				return uuid.Nil, api.ErrReauthenticationRequired
+			}
+
+			updates := map[string]any{
+				"last_seen_at": now,
+				"expires_at":   expiresAt,
+			}
+			if client.UserAgent != "" {
+				updates["user_agent"] = client.UserAgent
+			}
+			if client.IpAddress != "" {
+				updates["ip_address"] = client.IpAddress
+			}
+
+			err = tx.Model(&models.UserSession{}).Where("id = ?", session.ID).Updates(updates).Error
+			if err != nil {
+				return uuid.Nil, err
+			}
+			return session.ID, nil
+		}
+	}
+
+	// Refresh token families issued before sessions were tracked keep their ID, so existing devices show up as sessions
+	newSessionId := uuid.New()
+	if sessionId != nil {
+		newSessionId = *sessionId
+	}
+
+	err := tx.Create(&models.UserSession{
+		ID:         newSessionId,
+		UserID:     userId,
+		UserAgent:  client.UserAgent,
+		IpAddress:  client.IpAddress,
+		LastSeenAt: now,
+		ExpiresAt:  expiresAt,
+	}).Error
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return newSessionId, nil
+}
+
+// getActiveOrganizationId returns the organization ID if the user is still a member of it, and nil otherwise
+func getActiveOrganizationId(tx *gorm.DB, userId uuid.UUID, organizationId *uuid.UUID) (*uuid.UUID, error) {
+	if organizationId == nil {
+		return nil, nil
+	}
+
+	var count int64
+	err := tx.Model(&models.OrganizationMembership{}).Where("user_id = ? AND organization_id = ?", userId, organizationId).Count(&count).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if count == 0 {
+		slog.Info("User is no longer a member of the token organization, issuing token without it", "userID", userId, "organizationID", organizationId)
+		return nil, nil
+	}
+
+	return organizationId, nil
+}
+
+func refreshAuthenticatedUserToken(request RefreshAuthenticatedUserTokenServiceRequest) (*RefreshAuthenticatedUserTokenServiceResponse, error) {
+	tx := request.Tx
+	config := request.Config
+	params := request.Params
+
+	userId, impersonatingUserId, impersonationSessionId, err := getRefreshedTokenUser(tx, params.UserId, params.ImpersonatingUserId, params.ImpersonationSessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the revocation state directly from the database instead of the cache, since this decides whether a new token is minted
+	var user models.User
+	err = tx.First(&user, userId).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrReauthenticationRequired
+		}
+		return nil, err
+	}
+
+	err = authentication.CheckTokenRevocation(&authentication.JwtClaims{
+		RegisteredClaims: jwt.RegisteredClaims{IssuedAt: params.IssuedAt},
+	}, user.Revocation)
+	if err != nil && !errors.Is(err, api.ErrTokenRefreshRequired) {
+		return nil, err
+	}
+
+	// Keep the active organization only if the user is still a member of it
+	organizationId, err := getActiveOrganizationId(tx, user.ID, params.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := createAuthenticatedUserToken(CreateAuthenticatedUserTokenServiceRequest{
+		Params: CreateAuthenticatedUserTokenParams{
+			UserId:                 user.ID,
+			OrganizationId:         organizationId,
+			ImpersonatingUserId:    impersonatingUserId,
+			ImpersonationSessionId: impersonationSessionId,
+			SessionId:              params.SessionId,
+			Client:                 params.Client,
+		},
+		Tx:     tx,
+		Config: config,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RefreshAuthenticatedUserTokenServiceResponse{
+		Tokens:              tokens,
+		UserId:              user.ID,
+		OrganizationId:      organizationId,
+		ImpersonatingUserId: impersonatingUserId,
+	}, nil
+}
+
+// errRefreshTokenReused is returned from inside the rotation's transaction when the token was already rotated, so
+// the family can be revoked once the transaction has rolled back
+var errRefreshTokenReused = errors.New("refresh token reused")
+
+func rotateRefreshToken(request RotateRefreshTokenServiceRequest) (*RefreshAuthenticatedUserTokenServiceResponse, error) {
+	tx := request.Tx
+	config := request.Config
+	params := request.Params
+
+	var refreshToken models.RefreshToken
+	err := tx.Where("token_hash = ?", authentication.HashRefreshToken(params.RefreshToken)).First(&refreshToken).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if refreshToken.RevokedAt != nil || now.After(refreshToken.ExpiresAt) {
+		return nil, api.ErrInvalidRefreshToken
+	}
+
+	// Marking the token as rotated and issuing its replacement happen together, so a failure part way through leaves
+	// the old token usable instead of signing the user out
+	var response *RefreshAuthenticatedUserTokenServiceResponse
+	err = tx.Transaction(func(tx *gorm.DB) error {
+		// The condition makes this succeed only once, even for concurrent requests
+		result := tx.Model(&models.RefreshToken{}).Where("id = ? AND rotated_at IS NULL", refreshToken.ID).Update("rotated_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errRefreshTokenReused
+		}
+
+		userId, impersonatingUserId, impersonationSessionId, err := getRefreshedTokenUser(tx, refreshToken.UserID, refreshToken.ImpersonatingUserID, refreshToken.ImpersonationSessionID)
+		if err != nil {
+			return err
+		}
+
+		// Keep the active organization only if the user is still a member of it
+		organizationId, err := getActiveOrganizationId(tx, userId, refreshToken.OrganizationID)
+		if err != nil {
+			return err
+		}
+
+		tokens, err := createAuthenticatedUserToken(CreateAuthenticatedUserTokenServiceRequest{
+			Params: CreateAuthenticatedUserTokenParams{
+				UserId:                 userId,
+				OrganizationId:         organizationId,
+				ImpersonatingUserId:    impersonatingUserId,
+				ImpersonationSessionId: impersonationSessionId,
+				SessionId:              &refreshToken.FamilyID,
+				Client:                 params.Client,
+			},
+			Tx:     tx,
+			Config: config,
+		})
+		if err != nil {
+			return err
+		}
+
+		response = &RefreshAuthenticatedUserTokenServiceResponse{
+			Tokens:              tokens,
+			UserId:              userId,
+			OrganizationId:      organizationId,
+			ImpersonatingUserId: impersonatingUserId,
+		}
+		return nil
+	})
+
+	if errors.Is(err, errRefreshTokenReused) {
+		// The token has already been exchanged, so it is being replayed by either the client or someone who stole it.
+		// There is no way to tell which, so revoke the whole family and force the user to sign in again. This is done
+		// outside the rotation's transaction so that it sticks even though the request fails.
+		slog.Warn("Refresh token reuse detected, revoking token family", "userID", refreshToken.UserID, "familyID", refreshToken.FamilyID)
+
+		err := tx.Model(&models.RefreshToken{}).Where("family_id = ? AND revoked_at IS NULL", refreshToken.FamilyID).Update("revoked_at", now).Error
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, api.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// getUserSessions returns the sessions the user is still signed in to, most recently used first
+func getUserSessions(request GetUserSessionsServiceRequest) ([]models.UserSession, error) {
+	tx := request.Tx
+
+	var sessions []models.UserSession
+	err := tx.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", request.UserID, time.Now()).Order("last_seen_at DESC").Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// deleteUserSession signs the user out of one of their sessions
+func deleteUserSession(request DeleteUserSessionServiceRequest) error {
+	tx := request.Tx
+
+	var session models.UserSession
+	err := tx.Where("id = ? AND user_id = ? AND revoked_at IS NULL", request.SessionID, request.UserID).First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return api.ErrUserSessionNotFound
+		}
+		return err
+	}
+
+	return authentication.RevokeSessions(tx, []uuid.UUID{session.ID})
+}
+
+// deleteOtherUserSessions signs the user out of every session except the one the request was made with
+func deleteOtherUserSessions(request DeleteOtherUserSessionsServiceRequest) error {
+	tx := request.Tx
+
+	var sessionIDs []uuid.UUID
+	err := tx.Model(&models.UserSession{}).Where("user_id = ? AND id <> ? AND revoked_at IS NULL", request.UserID, request.CurrentSessionID).Pluck("id", &sessionIDs).Error
+	if err != nil {
+		return err
+	}
+
+	return authentication.RevokeSessions(tx, sessionIDs)
+}
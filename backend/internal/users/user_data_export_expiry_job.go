@@ -0,0 +1,69 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"github.com/riverqueue/river"
+	"gorm.io/gorm"
+	"reece.start/internal/constants"
+	"reece.start/internal/models"
+)
+
+// Removes a user data export's archive once its download link has expired
+type UserDataExportExpiryJobArgs struct {
+	UserDataExportId uuid.UUID `json:"userDataExportId"`
+}
+
+func (UserDataExportExpiryJobArgs) Kind() string {
+	return string(constants.JobKindUserDataExportExpiry)
+}
+
+type UserDataExportExpiryJobWorker struct {
+	river.WorkerDefaults[UserDataExportExpiryJobArgs]
+	DB          *gorm.DB
+	MinioClient *minio.Client
+}
+
+func (w *UserDataExportExpiryJobWorker) Work(ctx context.Context, job *river.Job[UserDataExportExpiryJobArgs]) error {
+	slog.Info("Removing expired user data export", "userDataExportId", job.Args.UserDataExportId)
+
+	var export models.UserDataExport
+	err := w.DB.WithContext(ctx).First(&export, job.Args.UserDataExportId).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Deleting the user removes their exports too
+			slog.Info("User data export was already deleted", "userDataExportId", job.Args.UserDataExportId)
+			return nil
+		}
+		return err
+	}
+
+	if export.FileStorageKey == "" {
+		return nil
+	}
+
+	if w.MinioClient != nil {
+		err = w.MinioClient.RemoveObject(ctx, string(constants.StorageBucketUserDataExports), export.FileStorageKey, minio.RemoveObjectOptions{})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = w.DB.WithContext(ctx).Model(&export).Update("file_storage_key", "").Error
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Removed expired user data export", "userId", export.UserID, "userDataExportId", export.ID)
+
+	return nil
+}
+
+func (w *UserDataExportExpiryJobWorker) Timeout(*river.Job[UserDataExportExpiryJobArgs]) time.Duration {
+	return 180 * time.Second
+}
@@ -0,0 +1,53 @@
+package users_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"reece.start/internal/constants"
+	"reece.start/internal/models"
+	"reece.start/internal/users"
+	"reece.start/test"
+)
+
+func TestUserDataExportExpiryJob(t *testing.T) {
+	t.Run("RemovesArchive", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, _ := test.CreateTestUser(t, tc)
+
+		completedAt := time.Now().Add(-time.Hour)
+		expiresAt := time.Now()
+		export := models.UserDataExport{
+			UserID:         user.ID,
+			Status:         string(constants.UserDataExportStatusCompleted),
+			FileStorageKey: user.ID.String() + "/export.zip",
+			CompletedAt:    &completedAt,
+			ExpiresAt:      &expiresAt,
+		}
+		require.NoError(t, tc.DB.Create(&export).Error)
+
+		_, err := tc.RiverClient.Insert(t.Context(), users.UserDataExportExpiryJobArgs{UserDataExportId: export.ID}, nil)
+		require.NoError(t, err)
+		test.RunAllPendingRiverJobs(t, tc.DB, tc.RiverClient)
+
+		var stored models.UserDataExport
+		require.NoError(t, tc.DB.First(&stored, export.ID).Error)
+		assert.Empty(t, stored.FileStorageKey)
+		assert.Equal(t, string(constants.UserDataExportStatusCompleted), stored.Status)
+	})
+
+	t.Run("ExportAlreadyDeleted", func(t *testing.T) {
+		tc := test.SetupEchoTest(t)
+		user, _, _ := test.CreateTestUser(t, tc)
+
+		export := models.UserDataExport{UserID: user.ID, Status: string(constants.UserDataExportStatusCompleted)}
+		require.NoError(t, tc.DB.Create(&export).Error)
+		require.NoError(t, tc.DB.Unscoped().Delete(&export).Error)
+
+		_, err := tc.RiverClient.Insert(t.Context(), users.UserDataExportExpiryJobArgs{UserDataExportId: export.ID}, nil)
+		require.NoError(t, err)
+		test.RunAllPendingRiverJobs(t, tc.DB, tc.RiverClient)
+	})
+}
@@ -0,0 +1,156 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"github.com/resend/resend-go/v2"
+	"github.com/riverqueue/river"
+	"gorm.io/gorm"
+	"reece.start/internal/configuration"
+	"reece.start/internal/constants"
+	"reece.start/internal/email"
+	"reece.start/internal/models"
+	"reece.start/internal/utils"
+)
+
+// Puts together an archive of a user's data, stores it and emails them a link to download it
+type UserDataExportJobArgs struct {
+	UserDataExportId uuid.UUID `json:"userDataExportId"`
+}
+
+func (UserDataExportJobArgs) Kind() string {
+	return string(constants.JobKindUserDataExport)
+}
+
+type UserDataExportJobWorker struct {
+	river.WorkerDefaults[UserDataExportJobArgs]
+	DB           *gorm.DB
+	Config       *configuration.Config
+	ResendClient *resend.Client
+	MinioClient  *minio.Client
+}
+
+func (w *UserDataExportJobWorker) Work(ctx context.Context, job *river.Job[UserDataExportJobArgs]) error {
+	slog.Info("Exporting user data", "userDataExportId", job.Args.UserDataExportId)
+
+	err := w.export(ctx, job.Args.UserDataExportId)
+	if err != nil && job.Attempt >= job.MaxAttempts {
+		// River won't try again, so the user can ask for a new export
+		failErr := w.DB.WithContext(ctx).Model(&models.UserDataExport{}).
+			Where("id = ?", job.Args.UserDataExportId).
+			Update("status", string(constants.UserDataExportStatusFailed)).Error
+		if failErr != nil {
+			slog.Warn("Failed to mark user data export as failed", "userDataExportId", job.Args.UserDataExportId, "error", failErr)
+		}
+	}
+
+	return err
+}
+
+func (w *UserDataExportJobWorker) export(ctx context.Context, userDataExportId uuid.UUID) error {
+	db := w.DB.WithContext(ctx)
+
+	var export models.UserDataExport
+	err := db.Preload("User").First(&export, userDataExportId).Error
+	if err != nil {
+		return err
+	}
+
+	if export.Status != string(constants.UserDataExportStatusPending) {
+		return nil
+	}
+
+	archive, err := buildUserDataExportArchive(ctx, db, w.MinioClient, &export.User)
+	if err != nil {
+		return err
+	}
+
+	objectName := export.UserID.String() + "/" + export.ID.String() + ".zip"
+	_, err = w.MinioClient.PutObject(ctx, string(constants.StorageBucketUserDataExports), objectName, bytes.NewReader(archive), int64(len(archive)), minio.PutObjectOptions{
+		ContentType: "application/zip",
+	})
+	if err != nil {
+		return err
+	}
+
+	expiresIn := time.Duration(w.Config.UserDataExportLinkExpirationTime) * time.Second
+	downloadUrl, err := w.MinioClient.PresignedGetObject(ctx, string(constants.StorageBucketUserDataExports), objectName, expiresIn, url.Values{
+		"response-content-disposition": []string{`attachment; filename="` + constants.ServiceName + ` data export.zip"`},
+	})
+	if err != nil {
+		return err
+	}
+
+	completedAt := time.Now()
+	expiresAt := completedAt.Add(expiresIn)
+
+	html, err := email.UserDataExportEmailTemplateParams{
+		User:               export.User,
+		DownloadUrl:        downloadUrl.String(),
+		ExpiresAt:          expiresAt,
+		FrontendUrl:        w.Config.FrontendUrl,
+		ServiceName:        constants.ServiceName,
+		ServiceDescription: constants.ServiceDescription,
+	}.ApplyHtmlTemplate()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = email.SendEmail(email.SendEmailRequest{
+		Params: email.SendEmailParams{
+			From:    string(constants.EmailSenderDefault),
+			To:      []string{export.User.Email},
+			Subject: "Your " + constants.ServiceName + " data is ready to download",
+			Html:    html,
+		},
+		ResendClient: w.ResendClient,
+		Config:       w.Config,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&export).Updates(map[string]any{
+			"status":           string(constants.UserDataExportStatusCompleted),
+			"file_storage_key": objectName,
+			"completed_at":     completedAt,
+			"expires_at":       expiresAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		// The archive is removed once the download link stops working
+		riverClient := river.ClientFromContext[*sql.Tx](ctx)
+		_, err = riverClient.InsertTx(ctx, utils.GetGormSQLTx(tx), UserDataExportExpiryJobArgs{
+			UserDataExportId: export.ID,
+		}, &river.InsertOpts{ScheduledAt: expiresAt})
+		if err != nil {
+			return fmt.Errorf("failed to enqueue user data export expiry job: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Exported user data", "userId", export.UserID, "userDataExportId", export.ID)
+
+	return nil
+}
+
+func (w *UserDataExportJobWorker) Timeout(*river.Job[UserDataExportJobArgs]) time.Duration {
+	return 180 * time.Second
+}
@@ -0,0 +1,74 @@
+package users
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/resend/resend-go/v2"
+	"github.com/riverqueue/river"
+	"gorm.io/gorm"
+	"reece.start/internal/configuration"
+	"reece.start/internal/constants"
+	"reece.start/internal/email"
+	"reece.start/internal/models"
+)
+
+// Lets the user know their account wasn't deleted because they're the only admin of an organization
+type UserDeletionCancelledEmailJobArgs struct {
+	UserId uuid.UUID `json:"userId"`
+}
+
+func (UserDeletionCancelledEmailJobArgs) Kind() string {
+	return string(constants.JobKindUserDeletionCancelledEmail)
+}
+
+type UserDeletionCancelledEmailJobWorker struct {
+	river.WorkerDefaults[UserDeletionCancelledEmailJobArgs]
+	DB           *gorm.DB
+	Config       *configuration.Config
+	ResendClient *resend.Client
+}
+
+func (w *UserDeletionCancelledEmailJobWorker) Work(ctx context.Context, job *river.Job[UserDeletionCancelledEmailJobArgs]) error {
+	slog.Info("Sending user deletion cancelled email", "userId", job.Args.UserId)
+
+	var user models.User
+	err := w.DB.First(&user, job.Args.UserId).Error
+	if err != nil {
+		return err
+	}
+
+	html, err := email.UserDeletionCancelledEmailTemplateParams{
+		User:               user,
+		FrontendUrl:        w.Config.FrontendUrl,
+		ServiceName:        constants.ServiceName,
+		ServiceDescription: constants.ServiceDescription,
+	}.ApplyHtmlTemplate()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = email.SendEmail(email.SendEmailRequest{
+		Params: email.SendEmailParams{
+			From:    string(constants.EmailSenderDefault),
+			To:      []string{user.Email},
+			Subject: "Your " + constants.ServiceName + " account wasn't deleted",
+			Html:    html,
+		},
+		ResendClient: w.ResendClient,
+		Config:       w.Config,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (w *UserDeletionCancelledEmailJobWorker) Timeout(*river.Job[UserDeletionCancelledEmailJobArgs]) time.Duration {
+	return 180 * time.Second
+}
@@ -0,0 +1,46 @@
+package users
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"reece.start/internal/api"
+	"reece.start/internal/middleware"
+	"reece.start/internal/models"
+)
+
+func DeleteAuthenticatedUserEndpoint(c echo.Context) error {
+	userID, err := middleware.GetUserIDFromJWT(c)
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	// Only the user themselves can delete their account, not an admin impersonating them
+	impersonatingUserId, _ := middleware.GetImpersonatingUserIDFromJWT(c)
+	if impersonatingUserId != uuid.Nil {
+		return api.ErrForbiddenImpersonationNotAllowed
+	}
+
+	db := middleware.GetDB(c)
+	config := middleware.GetConfig(c)
+	riverClient := middleware.GetRiverClient(c)
+
+	var user *models.User
+	err = db.WithContext(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		user, err = scheduleUserDeletion(ScheduleUserDeletionServiceRequest{
+			UserID:      userID,
+			Tx:          tx,
+			Config:      config,
+			RiverClient: riverClient,
+		})
+		return err
+	})
+
+	if err != nil {
+		return err // Middleware will handle the error response
+	}
+
+	return c.JSON(http.StatusAccepted, mapUserToResponse(&UserDto{User: user}))
+}
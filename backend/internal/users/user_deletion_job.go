@@ -0,0 +1,164 @@
+package users
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"github.com/riverqueue/river"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"reece.start/internal/api"
+	"reece.start/internal/configuration"
+	"reece.start/internal/constants"
+	"reece.start/internal/models"
+	"reece.start/internal/posthog"
+	"reece.start/internal/utils"
+)
+
+// Deletes a user once the grace period after they asked for their account to be deleted is over
+type UserDeletionJobArgs struct {
+	UserId uuid.UUID `json:"userId"`
+}
+
+func (UserDeletionJobArgs) Kind() string {
+	return string(constants.JobKindUserDeletion)
+}
+
+type UserDeletionJobWorker struct {
+	river.WorkerDefaults[UserDeletionJobArgs]
+	DB            *gorm.DB
+	Config        *configuration.Config
+	MinioClient   *minio.Client
+	PostHogClient *posthog.Client
+}
+
+func (w *UserDeletionJobWorker) Work(ctx context.Context, job *river.Job[UserDeletionJobArgs]) error {
+	slog.Info("Deleting user", "userId", job.Args.UserId)
+
+	// Everything is done in one transaction, with the user and the admin memberships of their organizations locked, so
+	// nothing can change whether they can be deleted part way through. Stored files are removed before the user is,
+	// so a failure leaves the user in place and the job is retried.
+	var userId uuid.UUID
+	var soleAdmin bool
+	err := w.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, job.Args.UserId).Error
+		if err != nil {
+			return err
+		}
+		userId = user.ID
+
+		// The user cancelled the deletion by signing in again, or scheduled it again and a later job will delete them
+		if user.DeletionScheduledAt == nil || user.DeletionScheduledAt.After(time.Now()) {
+			slog.Info("User deletion was cancelled or rescheduled", "userId", user.ID)
+			return nil
+		}
+
+		// The user may have been made the only admin of an organization during the grace period
+		err = checkNotSoleOrganizationAdmin(tx, user.ID)
+		if errors.Is(err, api.ErrSoleOrganizationAdmin) {
+			soleAdmin = true
+			return cancelUserDeletion(ctx, tx, &user)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = w.removeStoredFiles(ctx, tx, &user)
+		if err != nil {
+			return err
+		}
+
+		err = w.PostHogClient.DeletePerson(ctx, user.ID.String())
+		if err != nil {
+			return err
+		}
+
+		// Everything else that belongs to the user is removed by the database's cascading deletes. Impersonation
+		// sessions are kept for auditing, with the user's ID cleared.
+		err = tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.OrganizationMembership{}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Unscoped().Delete(&user).Error
+		if err != nil {
+			return err
+		}
+
+		slog.Info("Deleted user", "userId", user.ID)
+		return nil
+	})
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		slog.Info("User was already deleted", "userId", job.Args.UserId)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if soleAdmin {
+		slog.Warn("Not deleting user who is the only admin of an organization", "userId", userId)
+		return river.JobCancel(api.ErrSoleOrganizationAdmin)
+	}
+
+	return nil
+}
+
+// cancelUserDeletion keeps the user's account and emails them to explain why it wasn't deleted
+func cancelUserDeletion(ctx context.Context, tx *gorm.DB, user *models.User) error {
+	err := tx.Model(user).Update("deletion_scheduled_at", nil).Error
+	if err != nil {
+		return err
+	}
+
+	riverClient := river.ClientFromContext[*sql.Tx](ctx)
+	_, err = riverClient.InsertTx(ctx, utils.GetGormSQLTx(tx), UserDeletionCancelledEmailJobArgs{
+		UserId: user.ID,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue user deletion cancelled email job: %w", err)
+	}
+
+	return nil
+}
+
+// removeStoredFiles removes the user's logo and data exports from storage
+func (w *UserDeletionJobWorker) removeStoredFiles(ctx context.Context, tx *gorm.DB, user *models.User) error {
+	if w.MinioClient == nil {
+		return nil
+	}
+
+	if user.LogoFileStorageKey != "" {
+		err := w.MinioClient.RemoveObject(ctx, string(constants.StorageBucketUserLogos), user.LogoFileStorageKey, minio.RemoveObjectOptions{})
+		if err != nil {
+			return err
+		}
+	}
+
+	var exportObjectNames []string
+	err := tx.Model(&models.UserDataExport{}).Where("user_id = ? AND file_storage_key <> ''", user.ID).Pluck("file_storage_key", &exportObjectNames).Error
+	if err != nil {
+		return err
+	}
+
+	for _, objectName := range exportObjectNames {
+		err = w.MinioClient.RemoveObject(ctx, string(constants.StorageBucketUserDataExports), objectName, minio.RemoveObjectOptions{})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *UserDeletionJobWorker) Timeout(*river.Job[UserDeletionJobArgs]) time.Duration {
+	return 180 * time.Second
+}
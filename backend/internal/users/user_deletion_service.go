@@ -0,0 +1,97 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/riverqueue/river"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"reece.start/internal/api"
+	"reece.start/internal/authentication"
+	"reece.start/internal/constants"
+	"reece.start/internal/models"
+	"reece.start/internal/utils"
+)
+
+// scheduleUserDeletion signs the user out everywhere and schedules their account to be deleted once the grace period
+// is over
+func scheduleUserDeletion(request ScheduleUserDeletionServiceRequest) (*models.User, error) {
+	tx := request.Tx
+	config := request.Config
+	riverClient := request.RiverClient
+
+	var user models.User
+	err := tx.First(&user, request.UserID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, api.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	err = checkNotSoleOrganizationAdmin(tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	deletionScheduledAt := time.Now().Add(time.Duration(config.AccountDeletionGracePeriod) * time.Second)
+	err = tx.Model(&user).Update("deletion_scheduled_at", deletionScheduledAt).Error
+	if err != nil {
+		return nil, err
+	}
+	user.DeletionScheduledAt = &deletionScheduledAt
+
+	// Refresh tokens can't be used either, the user has to sign in again to cancel the deletion
+	err = authentication.RevokeUserTokens(tx, user.ID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Enqueue background job to delete the user once the grace period is over
+	sqlTx := utils.GetGormSQLTx(tx)
+	_, err = riverClient.InsertTx(tx.Statement.Context, sqlTx, UserDeletionJobArgs{
+		UserId: user.ID,
+	}, &river.InsertOpts{ScheduledAt: deletionScheduledAt})
+	if err != nil {
+		return nil, fmt.Errorf("failed to enqueue user deletion job: %w", err)
+	}
+
+	slog.Info("Scheduled account deletion", "userID", user.ID, "deletionScheduledAt", deletionScheduledAt)
+
+	return &user, nil
+}
+
+// checkNotSoleOrganizationAdmin returns an error if deleting the user would leave one of their organizations without an
+// admin
+func checkNotSoleOrganizationAdmin(tx *gorm.DB, userId uuid.UUID) error {
+	// Lock the admin memberships of every organization the user is an admin of, so the other admins can't be removed
+	// or demoted until the transaction is over
+	var adminMemberships []models.OrganizationMembership
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("role = ? AND organization_id IN (?)", constants.OrganizationRoleAdmin, tx.Model(&models.OrganizationMembership{}).
+			Select("organization_id").
+			Where("user_id = ? AND role = ?", userId, constants.OrganizationRoleAdmin)).
+		Find(&adminMemberships).Error
+	if err != nil {
+		return err
+	}
+
+	organizationsWithOtherAdmins := make(map[uuid.UUID]bool)
+	for _, membership := range adminMemberships {
+		if membership.UserID != userId {
+			organizationsWithOtherAdmins[membership.OrganizationID] = true
+		}
+	}
+
+	for _, membership := range adminMemberships {
+		if membership.UserID == userId && !organizationsWithOtherAdmins[membership.OrganizationID] {
+			return api.ErrSoleOrganizationAdmin
+		}
+	}
+
+	return nil
+}
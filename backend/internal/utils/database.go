@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"context"
 	"database/sql"
+	"sync"
 
 	"gorm.io/gorm"
 )
@@ -11,3 +13,48 @@ func GetGormSQLTx(tx *gorm.DB) *sql.Tx {
 	sqlTx := tx.Statement.ConnPool.(*sql.Tx)
 	return sqlTx
 }
+
+type afterTransactionKey struct{}
+
+type afterTransactionFuncs struct {
+	mu    sync.Mutex
+	funcs []func()
+}
+
+// WithAfterTransaction returns a context that holds on to the functions passed to AfterTransaction, and a function
+// that runs them. Call it once every transaction started with the context is over.
+func WithAfterTransaction(ctx context.Context) (context.Context, func()) {
+	pending := &afterTransactionFuncs{}
+
+	run := func() {
+		pending.mu.Lock()
+		funcs := pending.funcs
+		pending.funcs = nil
+		pending.mu.Unlock()
+
+		for _, fn := range funcs {
+			fn()
+		}
+	}
+
+	return context.WithValue(ctx, afterTransactionKey{}, pending), run
+}
+
+// AfterTransaction runs fn once tx has been committed or rolled back, for work that mustn't be seen by other requests
+// before the transaction's changes are, like clearing a cache. It runs right away if tx's context doesn't come from
+// WithAfterTransaction.
+func AfterTransaction(tx *gorm.DB, fn func()) {
+	var pending *afterTransactionFuncs
+	if tx.Statement.Context != nil {
+		pending, _ = tx.Statement.Context.Value(afterTransactionKey{}).(*afterTransactionFuncs)
+	}
+
+	if pending == nil {
+		fn()
+		return
+	}
+
+	pending.mu.Lock()
+	pending.funcs = append(pending.funcs, fn)
+	pending.mu.Unlock()
+}
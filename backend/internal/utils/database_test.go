@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestAfterTransaction(t *testing.T) {
+	t.Run("DeferredUntilRun", func(t *testing.T) {
+		ctx, runAfterTransaction := WithAfterTransaction(t.Context())
+		tx := &gorm.DB{Statement: &gorm.Statement{Context: ctx}}
+
+		calls := 0
+		AfterTransaction(tx, func() { calls++ })
+		AfterTransaction(tx, func() { calls++ })
+		assert.Equal(t, 0, calls)
+
+		runAfterTransaction()
+		assert.Equal(t, 2, calls)
+
+		// Each function only runs once
+		runAfterTransaction()
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("RunsRightAwayWithoutContext", func(t *testing.T) {
+		tx := &gorm.DB{Statement: &gorm.Statement{Context: t.Context()}}
+
+		calls := 0
+		AfterTransaction(tx, func() { calls++ })
+		assert.Equal(t, 1, calls)
+	})
+}
@@ -26,9 +26,11 @@ import (
 	stripeGo "github.com/stripe/stripe-go/v83"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"reece.start/internal/authentication"
 	"reece.start/internal/configuration"
 	"reece.start/internal/constants"
 	"reece.start/internal/database"
+	"reece.start/internal/dns"
 	echoServer "reece.start/internal/echo"
 	"reece.start/internal/jobs"
 	appMiddleware "reece.start/internal/middleware"
@@ -43,6 +45,9 @@ func main() {
 		log.Fatalf("Error loading environment variables, %s", err)
 	}
 
+	loadJwtKeyRing(config)
+	loadBreachedPasswords(config)
+
 	sqlDb, gormDb := createDatabaseConnectionPool(config)
 	runDatabaseMigrations(gormDb)
 
@@ -56,7 +61,7 @@ func main() {
 	ctx := context.Background()
 	runRiverMigrations(ctx, sqlDb)
 
-	riverClient := createRiverClient(ctx, config, sqlDb, gormDb, resendClient, stripeClient)
+	riverClient := createRiverClient(ctx, config, sqlDb, gormDb, resendClient, stripeClient, minioClient, posthogClient)
 
 	e := createEchoServer(config, gormDb, minioClient, riverClient, resendClient, stripeClient, posthogClient)
 
@@ -93,6 +98,31 @@ func setupLogger() {
 	slog.SetDefault(logger)
 }
 
+func loadJwtKeyRing(config *configuration.Config) {
+	// Load the key ring up front so a misconfigured key fails on startup instead of on the first login
+	_, err := authentication.GetKeyRing(config)
+	if err != nil {
+		log.Fatalf("Error loading JWT signing keys, %s", err)
+	}
+
+	slog.Info("JWT signing keys loaded")
+}
+
+func loadBreachedPasswords(config *configuration.Config) {
+	// Load the list up front, it can be large and a missing file should fail on startup instead of on the first sign up
+	list, err := authentication.GetBreachedPasswords(config)
+	if err != nil {
+		log.Fatalf("Error loading breached passwords, %s", err)
+	}
+
+	if list == nil {
+		slog.Info("Breached password check disabled")
+		return
+	}
+
+	slog.Info("Breached passwords loaded", "count", list.Count())
+}
+
 func createDatabaseConnectionPool(config *configuration.Config) (*sql.DB, *gorm.DB) {
 	pool, err := pgxpool.New(context.Background(), config.DatabaseUri)
 	if err != nil {
@@ -144,6 +174,7 @@ func initializeStorageBuckets(client *minio.Client) {
 	buckets := []constants.StorageBucket{
 		constants.StorageBucketUserLogos,
 		constants.StorageBucketOrganizationLogos,
+		constants.StorageBucketUserDataExports,
 	}
 
 	for _, bucket := range buckets {
@@ -204,14 +235,18 @@ func createRiverClient(
 	gormDb *gorm.DB,
 	resendClient *resend.Client,
 	stripeClient *stripeGo.Client,
+	minioClient *minio.Client,
+	posthogClient *posthog.Client,
 ) *river.Client[*sql.Tx] {
 	riverClient, err := jobs.NewRiverClient(ctx, jobs.RiverClientConfig{
-		SQLDB:        sqlDb,
-		GormDB:       gormDb,
-		Config:       config,
-		ResendClient: resendClient,
-		StripeClient: stripeClient,
-		StartWorkers: true, // Start workers in production
+		SQLDB:         sqlDb,
+		GormDB:        gormDb,
+		Config:        config,
+		ResendClient:  resendClient,
+		StripeClient:  stripeClient,
+		MinioClient:   minioClient,
+		PostHogClient: posthogClient,
+		StartWorkers:  true, // Start workers in production
 	})
 	if err != nil {
 		log.Fatalf("Error creating/starting river client, %s", err)
@@ -244,6 +279,7 @@ func createEchoServer(
 		ResendClient:  resendClient,
 		StripeClient:  stripeClient,
 		PostHogClient: posthogClient,
+		DnsResolver:   dns.NewResolver(),
 	})
 
 	return e
@@ -8,19 +8,46 @@ import (
 // This provides a consistent test configuration across all test files.
 func CreateTestConfig() *configuration.Config {
 	return &configuration.Config{
-		Test:                   true,
-		Host:                   "localhost",
-		Port:                   "8080",
-		FrontendUrl:            "http://localhost:3000",
-		JwtSecret:              "test-secret",
-		JwtIssuer:              "test-issuer",
-		JwtAudience:            "test-audience",
-		JwtExpirationTime:      3600,
-		StorageEndpoint:        "localhost:9000",
-		StorageAccessKeyId:     "minioadmin",
-		StorageSecretAccessKey: "minioadmin",
-		StorageUseSSL:          false,
-		EnableEmail:            false,
-		ResendApiKey:           "test-key",
+		Test:                                 true,
+		Host:                                 "localhost",
+		Port:                                 "8080",
+		FrontendUrl:                          "http://localhost:3000",
+		ApiUrl:                               "http://localhost:8080",
+		JwtSecret:                            "test-secret",
+		JwtIssuer:                            "test-issuer",
+		JwtAudience:                          "test-audience",
+		JwtExpirationTime:                    3600,
+		JwtLeeway:                            30,
+		RefreshTokenExpirationTime:           86400,
+		ImpersonationExpirationTime:          1800,
+		PasswordMinLength:                    8,
+		PasswordMaxLength:                    128,
+		PasswordResetTokenExpirationTime:     3600,
+		EmailVerificationTokenExpirationTime: 86400,
+		ReauthenticationMaxAge:               300,
+		AccountDeletionGracePeriod:           86400,
+		UserDataExportLinkExpirationTime:     86400,
+		EnableMagicLinkLogin:                 true,
+		MagicLinkTokenExpirationTime:         900,
+		LoginThrottleFreeAttempts:            3,
+		LoginThrottleMaxDelay:                0, // No delays, so tests can make failed attempts back to back
+		LoginLockoutAccountThreshold:         5,
+		LoginLockoutIpThreshold:              20,
+		LoginLockoutDuration:                 900,
+		LoginFailedAttemptWindow:             3600,
+		MfaLockoutThreshold:                  5,
+		EmailLinkRequestAddressLimit:         3,
+		EmailLinkRequestIpLimit:              10,
+		OAuthRedirectUris:                    "http://localhost:3000/oauth/google/callback,http://localhost:3000/oauth/oidc/callback",
+		SamlRedirectUri:                      "http://localhost:3000/saml/callback",
+		WebauthnRpId:                         "localhost",
+		WebauthnRpDisplayName:                "reece-start",
+		WebauthnRpOrigins:                    "http://localhost:3000",
+		StorageEndpoint:                      "localhost:9000",
+		StorageAccessKeyId:                   "minioadmin",
+		StorageSecretAccessKey:               "minioadmin",
+		StorageUseSSL:                        false,
+		EnableEmail:                          false,
+		ResendApiKey:                         "test-key",
 	}
 }
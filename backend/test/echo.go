@@ -36,6 +36,7 @@ type TestContext struct {
 	RiverClient  *river.Client[*sql.Tx]
 	ResendClient *resend.Client
 	StripeClient *stripeGo.Client
+	DnsResolver  *testmocks.MockDnsResolver
 }
 
 // SetupEchoTest sets up all of the testing infrastructure to run integration tests against echo handlers.
@@ -67,15 +68,20 @@ func SetupEchoTest(t *testing.T) *TestContext {
 	// Create mock posthog client - HTTP calls will be intercepted by MockHTTPTransport
 	posthogClient := testmocks.NewMockPosthogClient()
 
+	// Create mock DNS resolver - tests set the records they need on it
+	dnsResolver := testmocks.NewMockDnsResolver()
+
 	// Create River client for background jobs (workers registered but NOT started in tests)
 	// River tables are already created during initial migration in setupSharedPostgresContainer
 	riverClient, err := jobs.NewRiverClient(t.Context(), jobs.RiverClientConfig{
-		SQLDB:        sqlDb,
-		GormDB:       gormDb,
-		Config:       config,
-		ResendClient: resendClient,
-		StripeClient: stripeClient,
-		StartWorkers: false, // Don't start workers in tests
+		SQLDB:         sqlDb,
+		GormDB:        gormDb,
+		Config:        config,
+		ResendClient:  resendClient,
+		StripeClient:  stripeClient,
+		MinioClient:   minioClient,
+		PostHogClient: posthogClient,
+		StartWorkers:  false, // Don't start workers in tests
 	})
 	require.NoError(t, err)
 
@@ -88,6 +94,7 @@ func SetupEchoTest(t *testing.T) *TestContext {
 		ResendClient:  resendClient,
 		StripeClient:  stripeClient,
 		PostHogClient: posthogClient,
+		DnsResolver:   dnsResolver,
 	})
 
 	return &TestContext{
@@ -99,6 +106,7 @@ func SetupEchoTest(t *testing.T) *TestContext {
 		RiverClient:  riverClient,
 		ResendClient: resendClient,
 		StripeClient: stripeClient,
+		DnsResolver:  dnsResolver,
 	}
 }
 
@@ -61,6 +61,9 @@ type TestUserOptions struct {
 	Name     string
 	Email    string
 	Password string
+
+	// Users are created with a verified email unless this is set, since most tests don't go through the verification email
+	UnverifiedEmail bool
 }
 
 // CreateTestUser creates a test user via the API with auto-generated details
@@ -107,6 +110,11 @@ func CreateTestUserWithOptions(t *testing.T, tc *TestContext, opts TestUserOptio
 	meta := data["meta"].(map[string]interface{})
 	token := meta["token"].(string)
 
+	if !opts.UnverifiedEmail {
+		err = tc.DB.Model(&models.User{}).Where("id = ?", userID).Update("email_verified_at", time.Now()).Error
+		require.NoError(t, err)
+	}
+
 	// Fetch the user from DB to return the full model
 	var user models.User
 	err = tc.DB.First(&user, userID).Error
@@ -0,0 +1,45 @@
+package mocks
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+)
+
+// MockDnsResolver serves the TXT records tests set on it. Names without records fail to resolve, like they would
+// with a real DNS server.
+type MockDnsResolver struct {
+	mu         sync.Mutex
+	txtRecords map[string][]string
+}
+
+func NewMockDnsResolver() *MockDnsResolver {
+	return &MockDnsResolver{
+		txtRecords: make(map[string][]string),
+	}
+}
+
+// SetTXTRecords replaces the TXT records of the name
+func (r *MockDnsResolver) SetTXTRecords(name string, values ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.txtRecords[normalizeDnsName(name)] = values
+}
+
+func (r *MockDnsResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	values, ok := r.txtRecords[normalizeDnsName(name)]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	return values, nil
+}
+
+func normalizeDnsName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
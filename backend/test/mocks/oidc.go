@@ -0,0 +1,190 @@
+package mocks
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"reece.start/internal/configuration"
+)
+
+// OIDCUser is the profile the stub OIDC server returns from its userinfo endpoint
+type OIDCUser struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+}
+
+// oidcGrant is an issued authorization code, along with the parameters of the authorization it was issued for
+type oidcGrant struct {
+	user          OIDCUser
+	redirectUri   string
+	codeChallenge string
+	nonce         string
+}
+
+// OIDCServer is a minimal OpenID Connect provider for testing OAuth logins without calling a real provider.
+// It serves a discovery document, a token endpoint that exchanges codes issued with IssueCode (checking the redirect
+// URI and PKCE verifier), and a userinfo endpoint.
+// It listens on localhost, so requests to it pass through MockHTTPTransport.
+type OIDCServer struct {
+	*httptest.Server
+	ClientId     string
+	ClientSecret string
+
+	mu     sync.Mutex
+	codes  map[string]oidcGrant
+	tokens map[string]OIDCUser
+}
+
+// NewOIDCServer starts a stub OIDC server that is shut down when the test finishes
+func NewOIDCServer(t *testing.T) *OIDCServer {
+	s := &OIDCServer{
+		ClientId:     "test-oidc-client",
+		ClientSecret: "test-oidc-secret",
+		codes:        make(map[string]oidcGrant),
+		tokens:       make(map[string]OIDCUser),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.handleDiscovery)
+	mux.HandleFunc("POST /token", s.handleToken)
+	mux.HandleFunc("GET /userinfo", s.handleUserInfo)
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Configure points the generic OIDC provider in the config at the stub server
+func (s *OIDCServer) Configure(config *configuration.Config) {
+	config.OidcOAuthIssuerUrl = s.URL
+	config.OidcOAuthClientId = s.ClientId
+	config.OidcOAuthClientSecret = s.ClientSecret
+	config.OidcOAuthScopes = "openid email profile"
+}
+
+// IssueCode returns a single use authorization code that signs in as the given user, as if they had been sent to
+// authorizationUrl and approved the login
+func (s *OIDCServer) IssueCode(user OIDCUser, authorizationUrl string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parsed, err := url.Parse(authorizationUrl)
+	if err != nil {
+		panic(err)
+	}
+	query := parsed.Query()
+
+	code := uuid.New().String()
+	s.codes[code] = oidcGrant{
+		user:          user,
+		redirectUri:   query.Get("redirect_uri"),
+		codeChallenge: query.Get("code_challenge"),
+		nonce:         query.Get("nonce"),
+	}
+	return code
+}
+
+func (s *OIDCServer) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeOIDCJson(w, http.StatusOK, map[string]any{
+		"issuer":                 s.URL,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"userinfo_endpoint":      s.URL + "/userinfo",
+	})
+}
+
+func (s *OIDCServer) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOIDCJson(w, http.StatusBadRequest, map[string]any{"error": "invalid_request"})
+		return
+	}
+
+	// Clients may authenticate with basic auth or form parameters
+	clientId, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientId = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+
+	if clientId != s.ClientId || clientSecret != s.ClientSecret {
+		writeOIDCJson(w, http.StatusUnauthorized, map[string]any{"error": "invalid_client"})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	grant, ok := s.codes[r.PostForm.Get("code")]
+	if r.PostForm.Get("grant_type") != "authorization_code" || !ok {
+		writeOIDCJson(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant"})
+		return
+	}
+	delete(s.codes, r.PostForm.Get("code"))
+
+	// The code is bound to the redirect URI and PKCE challenge of the authorization it was issued for
+	verifierHash := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if r.PostForm.Get("redirect_uri") != grant.redirectUri ||
+		base64.RawURLEncoding.EncodeToString(verifierHash[:]) != grant.codeChallenge {
+		writeOIDCJson(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant"})
+		return
+	}
+
+	idToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss":   s.URL,
+		"sub":   grant.user.Subject,
+		"aud":   s.ClientId,
+		"nonce": grant.nonce,
+	}).SignedString([]byte(s.ClientSecret))
+	if err != nil {
+		writeOIDCJson(w, http.StatusInternalServerError, map[string]any{"error": "server_error"})
+		return
+	}
+
+	accessToken := uuid.New().String()
+	s.tokens[accessToken] = grant.user
+
+	writeOIDCJson(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func (s *OIDCServer) handleUserInfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	user, ok := s.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	s.mu.Unlock()
+
+	if !ok {
+		writeOIDCJson(w, http.StatusUnauthorized, map[string]any{"error": "invalid_token"})
+		return
+	}
+
+	writeOIDCJson(w, http.StatusOK, map[string]any{
+		"sub":            user.Subject,
+		"email":          user.Email,
+		"email_verified": user.EmailVerified,
+		"name":           user.Name,
+		"picture":        user.Picture,
+	})
+}
+
+func writeOIDCJson(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
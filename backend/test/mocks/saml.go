@@ -0,0 +1,166 @@
+package mocks
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"encoding/xml"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/crewjam/saml"
+)
+
+// SAMLUser is the user the stub identity provider signs in as
+type SAMLUser struct {
+	NameID string
+	Email  string
+	Name   string
+}
+
+// SAMLIdentityProvider is a SAML identity provider for testing SSO logins without a real provider. It signs responses
+// with a self-signed certificate generated for the test. Nothing is served over HTTP, responses are made directly
+// from the authentication request URL the service provider redirected to.
+type SAMLIdentityProvider struct {
+	EntityID       string
+	SsoUrl         string
+	CertificatePem string
+
+	idp              *saml.IdentityProvider
+	serviceProviders map[string]*saml.EntityDescriptor
+}
+
+// NewSAMLIdentityProvider creates a stub identity provider with a new self-signed signing certificate
+func NewSAMLIdentityProvider(t *testing.T) *SAMLIdentityProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating saml identity provider key: %v", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test SAML Identity Provider"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating saml identity provider certificate: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing saml identity provider certificate: %v", err)
+	}
+
+	metadataUrl, _ := url.Parse("https://idp.example.test/metadata")
+	ssoUrl, _ := url.Parse("https://idp.example.test/sso")
+
+	p := &SAMLIdentityProvider{
+		EntityID:         metadataUrl.String(),
+		SsoUrl:           ssoUrl.String(),
+		CertificatePem:   string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		serviceProviders: make(map[string]*saml.EntityDescriptor),
+	}
+
+	p.idp = &saml.IdentityProvider{
+		Key:                     key,
+		Certificate:             certificate,
+		MetadataURL:             *metadataUrl,
+		SSOURL:                  *ssoUrl,
+		ServiceProviderProvider: p,
+	}
+
+	return p
+}
+
+// MetadataXML returns the identity provider's metadata, as an admin would download it from their provider
+func (p *SAMLIdentityProvider) MetadataXML(t *testing.T) string {
+	metadata, err := xml.Marshal(p.idp.Metadata())
+	if err != nil {
+		t.Fatalf("marshalling saml identity provider metadata: %v", err)
+	}
+	return string(metadata)
+}
+
+// RegisterServiceProvider trusts a service provider, as an admin would by uploading its metadata to their provider
+func (p *SAMLIdentityProvider) RegisterServiceProvider(t *testing.T, metadataXML []byte) {
+	var descriptor saml.EntityDescriptor
+	if err := xml.Unmarshal(metadataXML, &descriptor); err != nil {
+		t.Fatalf("parsing saml service provider metadata: %v", err)
+	}
+	p.serviceProviders[descriptor.EntityID] = &descriptor
+}
+
+// GetServiceProvider implements saml.ServiceProviderProvider
+func (p *SAMLIdentityProvider) GetServiceProvider(_ *http.Request, serviceProviderID string) (*saml.EntityDescriptor, error) {
+	descriptor, ok := p.serviceProviders[serviceProviderID]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return descriptor, nil
+}
+
+// Respond signs the user in, as if they had been sent to authorizationUrl and logged in at the provider. Returns the
+// signed response and relay state the provider would post to the service provider's assertion consumer service.
+func (p *SAMLIdentityProvider) Respond(t *testing.T, authorizationUrl string, user SAMLUser) (string, string) {
+	request, err := p.newAuthnRequest(authorizationUrl)
+	if err != nil {
+		t.Fatalf("parsing saml authentication request: %v", err)
+	}
+
+	session := &saml.Session{
+		ID:           "test-session",
+		CreateTime:   time.Now(),
+		ExpireTime:   time.Now().Add(time.Hour),
+		Index:        "1",
+		NameID:       user.NameID,
+		NameIDFormat: string(saml.PersistentNameIDFormat),
+		CustomAttributes: []saml.Attribute{
+			{
+				Name:       "email",
+				NameFormat: "urn:oasis:names:tc:SAML:2.0:attrname-format:basic",
+				Values:     []saml.AttributeValue{{Type: "xs:string", Value: user.Email}},
+			},
+			{
+				Name:       "displayName",
+				NameFormat: "urn:oasis:names:tc:SAML:2.0:attrname-format:basic",
+				Values:     []saml.AttributeValue{{Type: "xs:string", Value: user.Name}},
+			},
+		},
+	}
+
+	if err := (saml.DefaultAssertionMaker{}).MakeAssertion(request, session); err != nil {
+		t.Fatalf("making saml assertion: %v", err)
+	}
+
+	form, err := request.PostBinding()
+	if err != nil {
+		t.Fatalf("making saml response: %v", err)
+	}
+
+	return form.SAMLResponse, form.RelayState
+}
+
+func (p *SAMLIdentityProvider) newAuthnRequest(authorizationUrl string) (*saml.IdpAuthnRequest, error) {
+	httpRequest := httptest.NewRequest(http.MethodGet, authorizationUrl, bytes.NewReader(nil))
+
+	request, err := saml.NewIdpAuthnRequest(p.idp, httpRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
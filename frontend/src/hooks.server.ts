@@ -1,5 +1,11 @@
 import { performAuthenticationCheck } from '$lib/server/auth';
-import { type Handle, type HandleServerError, type Redirect, type HttpError } from '@sveltejs/kit';
+import {
+	type Handle,
+	type HandleFetch,
+	type HandleServerError,
+	type Redirect,
+	type HttpError
+} from '@sveltejs/kit';
 import { sequence } from '@sveltejs/kit/hooks';
 import { paraglideMiddleware } from '$lib/paraglide/server';
 
@@ -78,6 +84,20 @@ const defaultHandle: Handle = async ({ event, resolve }) => {
 
 export const handle = sequence(paraglideHandle, defaultHandle);
 
+// Forward the browser's user agent and address to the API, so sessions show the device that signed in
+// instead of the frontend server
+export const handleFetch: HandleFetch = ({ event, request, fetch }) => {
+	if (request.url.startsWith(`${event.url.origin}/api/`)) {
+		const userAgent = event.request.headers.get('user-agent');
+		if (userAgent) {
+			request.headers.set('user-agent', userAgent);
+		}
+		request.headers.set('x-forwarded-for', event.getClientAddress());
+	}
+
+	return fetch(request);
+};
+
 interface ErrorWithOptionalStatus extends Error {
 	status?: number;
 }
@@ -75,6 +75,28 @@ describe('api', () => {
 			expect(result).toEqual({ id: '123', name: 'John', age: 30 });
 		});
 
+		it('should not parse a body for no content responses', async () => {
+			const mockFetch = vi.fn().mockResolvedValue({
+				ok: true,
+				status: 204,
+				json: async () => {
+					throw new SyntaxError('Unexpected end of JSON input');
+				}
+			});
+
+			const result = await post(
+				'/api/users',
+				{ name: 'John', age: 30 },
+				{
+					fetch: mockFetch,
+					requestSchema,
+					responseSchema: z.undefined()
+				}
+			);
+
+			expect(result).toBeUndefined();
+		});
+
 		it('should warn on invalid request data but still make request', async () => {
 			const consoleSpy = vi.spyOn(console, 'warn').mockImplementation(() => {});
 			const mockFetch = vi.fn().mockResolvedValue({
@@ -135,6 +157,65 @@ describe('api', () => {
 			}
 		});
 
+		it('should include the error code from the response', async () => {
+			const mockFetch = vi.fn().mockResolvedValue({
+				ok: false,
+				status: 401,
+				headers: new Headers({ 'content-type': 'application/json' }),
+				bodyUsed: false,
+				json: async () => ({
+					message: 'token has been revoked and must be refreshed',
+					code: 'token_refresh_required'
+				})
+			});
+
+			try {
+				await post(
+					'/api/users',
+					{ name: 'John', age: 30 },
+					{
+						fetch: mockFetch,
+						requestSchema,
+						responseSchema
+					}
+				);
+			} catch (error) {
+				expect(error).toBeInstanceOf(ApiError);
+				expect((error as ApiError).code).toBe(401);
+				expect((error as ApiError).errorCode).toBe('token_refresh_required');
+			}
+		});
+
+		it('should include the error meta from the response', async () => {
+			const mockFetch = vi.fn().mockResolvedValue({
+				ok: false,
+				status: 403,
+				headers: new Headers({ 'content-type': 'application/json' }),
+				bodyUsed: false,
+				json: async () => ({
+					message: 'your organization requires signing in with single sign-on',
+					code: 'sso_required',
+					meta: { organizationId: 'org-123' }
+				})
+			});
+
+			await expect(
+				post(
+					'/api/users/login',
+					{ name: 'John', age: 30 },
+					{
+						fetch: mockFetch,
+						requestSchema,
+						responseSchema
+					}
+				)
+			).rejects.toMatchObject({
+				code: 403,
+				errorCode: 'sso_required',
+				meta: { organizationId: 'org-123' }
+			});
+		});
+
 		it('should throw ApiError with default message when no message in response', async () => {
 			const mockFetch = vi.fn().mockResolvedValue({
 				ok: false,
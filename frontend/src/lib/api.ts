@@ -4,7 +4,9 @@ import { apiErrorSchema, type ApiErrorResponse } from './schemas/api';
 export class ApiError extends Error {
 	constructor(
 		message: string,
-		public code: number
+		public code: number,
+		public errorCode?: string,
+		public meta?: ApiErrorResponse['meta']
 	) {
 		super(message);
 	}
@@ -73,10 +75,11 @@ export async function post<T extends z.ZodTypeAny, K extends z.ZodTypeAny>(
 		console.log('[API Error] POST', errorJson);
 		const message =
 			errorJson?.message ?? `Request failed with invalid status code: ${response.status}`;
-		throw new ApiError(message, response.status);
+		throw new ApiError(message, response.status, errorJson?.code, errorJson?.meta);
 	}
 
-	const parsedData = await response.json();
+	// Actions without a result respond without a body
+	const parsedData = response.status === 204 ? undefined : await response.json();
 
 	return options.responseSchema.parse(parsedData);
 }
@@ -117,7 +120,7 @@ export async function put<T extends z.ZodTypeAny, K extends z.ZodTypeAny>(
 		console.log('[API Error] PUT', errorJson);
 		const message =
 			errorJson?.message ?? `Request failed with invalid status code: ${response.status}`;
-		throw new ApiError(message, response.status);
+		throw new ApiError(message, response.status, errorJson?.code, errorJson?.meta);
 	}
 
 	const parsedData = await response.json();
@@ -161,7 +164,7 @@ export async function patch<T extends z.ZodTypeAny, K extends z.ZodTypeAny>(
 		console.log('[API Error] PATCH', errorJson);
 		const message =
 			errorJson?.message ?? `Request failed with invalid status code: ${response.status}`;
-		throw new ApiError(message, response.status);
+		throw new ApiError(message, response.status, errorJson?.code, errorJson?.meta);
 	}
 
 	const parsedData = await response.json();
@@ -210,7 +213,7 @@ export async function get<T extends z.ZodTypeAny, K extends z.ZodTypeAny>(
 		console.log('[API Error] GET', errorJson);
 		const message =
 			errorJson?.message ?? `Request failed with invalid status code: ${response.status}`;
-		throw new ApiError(message, response.status);
+		throw new ApiError(message, response.status, errorJson?.code, errorJson?.meta);
 	}
 
 	const parsedData = await response.json();
@@ -243,7 +246,7 @@ export async function del(
 		const errorJson = await getErrorJson(response);
 		const message =
 			errorJson?.message ?? `Request failed with invalid status code: ${response.status}`;
-		throw new ApiError(message, response.status);
+		throw new ApiError(message, response.status, errorJson?.code, errorJson?.meta);
 	}
 }
 
@@ -10,7 +10,15 @@ export const API_TYPES = {
 } as const;
 
 export const apiErrorSchema = z.object({
-	message: z.string()
+	message: z.string(),
+	// Machine readable reason for the error, for errors the frontend handles
+	code: z.string().optional(),
+	// What the frontend needs to recover from the error, like the organization to sign in with single sign-on to
+	meta: z
+		.object({
+			organizationId: z.string().optional()
+		})
+		.optional()
 });
 
 export type ApiErrorResponse = z.infer<typeof apiErrorSchema>;
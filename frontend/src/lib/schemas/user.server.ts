@@ -54,11 +54,24 @@ export const createAuthenticatedUserTokenResponseSchema = z.object({
 				.nullable()
 		}),
 		meta: z.object({
-			token: z.string()
+			token: z.string(),
+			refreshToken: z.string().optional()
+		})
+	})
+});
+
+export const rotateRefreshTokenRequestSchema = z.object({
+	data: z.object({
+		type: z.literal(API_TYPES.token),
+		attributes: z.object({
+			refreshToken: z.string()
 		})
 	})
 });
 
+// Revoked tokens are refreshed for the organization and impersonation they were issued for
+export const refreshAuthenticatedUserTokenRequestSchema = z.object({});
+
 export const impersonateUserFormSchema = z.object({
 	impersonatedUserId: z.string()
 });
@@ -68,6 +81,89 @@ export const signinFormSchema = z.object({
 	password: z.string().min(1)
 });
 
+export const magicLinkFormSchema = z.object({
+	token: z.string().min(1)
+});
+
+export const loginWithMagicLinkRequestSchema = z.object({
+	data: z.object({
+		attributes: z.object({
+			token: z.string()
+		})
+	})
+});
+
+// Users with MFA enabled get a challenge instead of a session token
+export const loginWithMagicLinkResponseSchema = z.object({
+	data: z.object({
+		id: z.string(),
+		type: z.literal(API_TYPES.user),
+		meta: z.object({
+			token: z.string().optional(),
+			refreshToken: z.string().optional(),
+			mfaRequired: z.boolean().optional(),
+			mfaChallengeToken: z.string().optional()
+		})
+	})
+});
+
+export const mfaFormSchema = z
+	.object({
+		code: z.string().optional(),
+		recoveryCode: z.string().optional()
+	})
+	.refine((data) => !!data.code || !!data.recoveryCode, {
+		message: 'Enter a code from your authenticator app or a recovery code'
+	});
+
+export const loginWithMfaRequestSchema = z.object({
+	data: z.object({
+		attributes: z.object({
+			mfaChallengeToken: z.string(),
+			code: z.string().optional(),
+			recoveryCode: z.string().optional()
+		})
+	})
+});
+
+export const loginWithMfaResponseSchema = z.object({
+	data: z.object({
+		id: z.string(),
+		type: z.literal(API_TYPES.user),
+		meta: z.object({
+			token: z.string(),
+			refreshToken: z.string().optional()
+		})
+	})
+});
+
+export const resetPasswordFormSchema = z.object({
+	token: z.string().min(1),
+	password: z.string().min(1),
+	confirmPassword: z.string().min(1)
+});
+
+export const confirmPasswordResetRequestSchema = z.object({
+	data: z.object({
+		attributes: z.object({
+			token: z.string(),
+			password: z.string()
+		})
+	})
+});
+
+export const verifyEmailFormSchema = z.object({
+	token: z.string().min(1)
+});
+
+export const confirmEmailVerificationRequestSchema = z.object({
+	data: z.object({
+		attributes: z.object({
+			token: z.string()
+		})
+	})
+});
+
 export const signupFormSchema = z.object({
 	name: z.string().min(1),
 	email: z.string().min(1),
@@ -89,7 +185,8 @@ export const updateUserSecurityFormSchema = z.object({
 	userId: z.string().min(1),
 	email: z.string().min(1),
 	password: z.string().optional(),
-	confirmPassword: z.string().optional()
+	confirmPassword: z.string().optional(),
+	currentPassword: z.string().optional()
 });
 
 export const googleOAuthCallbackFormSchema = z.object({
@@ -97,3 +194,28 @@ export const googleOAuthCallbackFormSchema = z.object({
 	state: z.string().min(1),
 	redirect: z.string().optional()
 });
+
+export const samlCallbackFormSchema = z.object({
+	code: z.string().min(1),
+	state: z.string().min(1)
+});
+
+export const loginWithSamlRequestSchema = z.object({
+	data: z.object({
+		attributes: z.object({
+			code: z.string(),
+			state: z.string()
+		})
+	})
+});
+
+export const loginWithSamlResponseSchema = z.object({
+	data: z.object({
+		id: z.string(),
+		type: z.literal(API_TYPES.user),
+		meta: z.object({
+			token: z.string(),
+			refreshToken: z.string().optional()
+		})
+	})
+});
@@ -43,7 +43,8 @@ export const createUserRequestSchema = z.object({
 export const createUserResponseSchema = z.object({
 	data: userDataSchema.extend({
 		meta: z.object({
-			token: z.string()
+			token: z.string(),
+			refreshToken: z.string().optional()
 		})
 	})
 });
@@ -54,13 +55,20 @@ export const updateUserRequestSchema = z.object({
 		type: z.literal('user'),
 		attributes: userAttributesSchema.partial().extend({
 			password: z.string().optional(),
+			currentPassword: z.string().optional(),
 			logo: z.string().optional()
 		})
 	})
 });
 
 export const updateUserResponseSchema = z.object({
-	data: userDataSchema
+	data: userDataSchema.extend({
+		meta: userDataSchema.shape.meta.extend({
+			// Changing the email or password signs out every other session and replaces this one's token
+			token: z.string().optional(),
+			refreshToken: z.string().optional()
+		})
+	})
 });
 
 // Pagination schemas for users list
@@ -33,7 +33,8 @@ vi.mock('$lib/api', () => ({
 	ApiError: class ApiError extends Error {
 		constructor(
 			message: string,
-			public code: number
+			public code: number,
+			public errorCode?: string
 		) {
 			super(message);
 		}
@@ -90,7 +91,9 @@ describe('auth', () => {
 		});
 
 		it('should return false when token is expired', () => {
-			mockCookies.get.mockReturnValue('valid-token');
+			mockCookies.get.mockImplementation((name: string) =>
+				name === 'app-session-token' ? 'valid-token' : undefined
+			);
 			const expiredTime = Math.floor(Date.now() / 1000) - 1000; // 1000 seconds ago
 			vi.mocked(jwtDecode).mockReturnValue({ exp: expiredTime } as Partial<JwtClaims>);
 
@@ -108,12 +111,25 @@ describe('auth', () => {
 		});
 
 		it('should return false when exp is missing', () => {
-			mockCookies.get.mockReturnValue('valid-token');
+			mockCookies.get.mockImplementation((name: string) =>
+				name === 'app-session-token' ? 'valid-token' : undefined
+			);
 			vi.mocked(jwtDecode).mockReturnValue({} as Partial<JwtClaims>);
 
 			const result = authModule.isLoggedIn();
 			expect(result).toBe(false);
 		});
+
+		it('should return true when token is expired but a refresh token is present', () => {
+			mockCookies.get.mockImplementation((name: string) =>
+				name === 'app-session-token' ? 'expired-token' : 'refresh-token'
+			);
+			const expiredTime = Math.floor(Date.now() / 1000) - 1000;
+			vi.mocked(jwtDecode).mockReturnValue({ exp: expiredTime } as Partial<JwtClaims>);
+
+			const result = authModule.isLoggedIn();
+			expect(result).toBe(true);
+		});
 	});
 
 	describe('authenticate', () => {
@@ -156,6 +172,109 @@ describe('auth', () => {
 			// Should not throw for valid token
 		});
 
+		it('should rotate the refresh token when the token is expired', async () => {
+			const mockRequestEvent = createMockRequestEvent({
+				url: new URL('https://example.com/app/dashboard')
+			});
+			mockCookies.get.mockImplementation((name: string) =>
+				name === 'app-session-token' ? 'expired-token' : 'refresh-token-to-rotate'
+			);
+			vi.mocked(jwtDecode).mockReturnValue({
+				exp: Math.floor(Date.now() / 1000) - 1000
+			} as Partial<JwtClaims>);
+			vi.mocked(post).mockResolvedValue({
+				data: {
+					type: 'token',
+					meta: { token: 'new-token', refreshToken: 'new-refresh-token' }
+				}
+			} as Awaited<ReturnType<typeof post>>);
+
+			await authModule.performAuthenticationCheck(mockRequestEvent);
+
+			expect(post).toHaveBeenCalledWith(
+				'/api/users/token/rotate',
+				{
+					data: {
+						type: 'token',
+						attributes: { refreshToken: 'refresh-token-to-rotate' }
+					}
+				},
+				expect.any(Object)
+			);
+			expect(mockCookies.set).toHaveBeenCalledWith(
+				'app-session-token',
+				'new-token',
+				expect.any(Object)
+			);
+			expect(mockCookies.set).toHaveBeenCalledWith(
+				'app-refresh-token',
+				'new-refresh-token',
+				expect.any(Object)
+			);
+		});
+
+		it('should share one rotation between parallel requests', async () => {
+			mockCookies.get.mockImplementation((name: string) =>
+				name === 'app-session-token' ? 'expired-token' : 'refresh-token-in-parallel'
+			);
+			vi.mocked(jwtDecode).mockReturnValue({
+				exp: Math.floor(Date.now() / 1000) - 1000
+			} as Partial<JwtClaims>);
+			vi.mocked(post).mockResolvedValue({
+				data: {
+					type: 'token',
+					meta: { token: 'new-token', refreshToken: 'new-refresh-token' }
+				}
+			} as Awaited<ReturnType<typeof post>>);
+
+			await Promise.all([
+				authModule.performAuthenticationCheck(
+					createMockRequestEvent({ url: new URL('https://example.com/app/dashboard') })
+				),
+				authModule.performAuthenticationCheck(
+					createMockRequestEvent({ url: new URL('https://example.com/app/settings') })
+				)
+			]);
+
+			expect(post).toHaveBeenCalledTimes(1);
+		});
+
+		it('should redirect when the refresh token is rejected', async () => {
+			const mockRequestEvent = createMockRequestEvent({
+				url: new URL('https://example.com/app/dashboard')
+			});
+			mockCookies.get.mockImplementation((name: string) =>
+				name === 'app-session-token' ? 'expired-token' : 'revoked-refresh-token'
+			);
+			vi.mocked(jwtDecode).mockReturnValue({
+				exp: Math.floor(Date.now() / 1000) - 1000
+			} as Partial<JwtClaims>);
+			vi.mocked(post).mockRejectedValue(new ApiError('Invalid refresh token', 401));
+
+			await expect(authModule.performAuthenticationCheck(mockRequestEvent)).rejects.toThrow(
+				'redirect'
+			);
+			expect(mockCookies.delete).toHaveBeenCalledWith('app-session-token', { path: '/' });
+			expect(mockCookies.delete).toHaveBeenCalledWith('app-refresh-token', { path: '/' });
+		});
+
+		it('should redirect when the token is expired and there is no refresh token', async () => {
+			const mockRequestEvent = createMockRequestEvent({
+				url: new URL('https://example.com/app/dashboard')
+			});
+			mockCookies.get.mockImplementation((name: string) =>
+				name === 'app-session-token' ? 'expired-token' : undefined
+			);
+			vi.mocked(jwtDecode).mockReturnValue({
+				exp: Math.floor(Date.now() / 1000) - 1000
+			} as Partial<JwtClaims>);
+
+			await expect(authModule.performAuthenticationCheck(mockRequestEvent)).rejects.toThrow(
+				'redirect'
+			);
+			expect(post).not.toHaveBeenCalled();
+		});
+
 		it('should validate admin role for admin paths', async () => {
 			const mockRequestEvent = createMockRequestEvent({
 				url: new URL('https://example.com/app/admin')
@@ -242,11 +361,12 @@ describe('auth', () => {
 			await expect(authModule.getUserAndValidateToken()).rejects.toThrow();
 		});
 
-		it('should refresh token when token is older than lastIssuedAt', async () => {
+		it('should refresh the token and retry when the token was revoked', async () => {
 			const mockRequestEvent = createMockRequestEvent();
 			mockCookies.get.mockReturnValue('valid-token');
-			const iat = Math.floor(Date.now() / 1000) - 100;
-			vi.mocked(jwtDecode).mockReturnValue({ iat } as Partial<JwtClaims>);
+			vi.mocked(jwtDecode).mockReturnValue({
+				iat: Math.floor(Date.now() / 1000) - 100
+			} as Partial<JwtClaims>);
 
 			const mockUser = {
 				data: {
@@ -256,16 +376,13 @@ describe('auth', () => {
 						name: 'John Doe',
 						email: 'john@example.com'
 					},
-					meta: {
-						tokenRevocation: {
-							lastIssuedAt: iat - 200, // Token is older
-							canRefresh: true
-						}
-					}
+					meta: {}
 				}
 			};
 
-			vi.mocked(get).mockResolvedValue(mockUser);
+			vi.mocked(get)
+				.mockRejectedValueOnce(new ApiError('Token revoked', 401, 'token_refresh_required'))
+				.mockResolvedValueOnce(mockUser);
 			vi.mocked(post).mockResolvedValue({
 				data: {
 					type: 'token',
@@ -275,38 +392,52 @@ describe('auth', () => {
 
 			vi.mocked(getRequestEvent).mockReturnValue(mockRequestEvent);
 
-			await authModule.getUserAndValidateToken();
-			expect(post).toHaveBeenCalled();
+			const result = await authModule.getUserAndValidateToken();
+			expect(result.user).toEqual(mockUser);
+			expect(post).toHaveBeenCalledWith('/api/users/me/token/refresh', {}, expect.any(Object));
+			expect(mockCookies.set).toHaveBeenCalledWith(
+				'app-session-token',
+				'new-token',
+				expect.any(Object)
+			);
+			expect(get).toHaveBeenCalledTimes(2);
 		});
 
 		it('should redirect when token cannot be refreshed', async () => {
 			const mockRequestEvent = createMockRequestEvent();
 			mockCookies.get.mockReturnValue('valid-token');
-			const iat = Math.floor(Date.now() / 1000) - 100;
-			vi.mocked(jwtDecode).mockReturnValue({ iat } as Partial<JwtClaims>);
+			vi.mocked(jwtDecode).mockReturnValue({
+				iat: Math.floor(Date.now() / 1000) - 100
+			} as Partial<JwtClaims>);
 
-			const mockUser = {
-				data: {
-					id: 'user-123',
-					type: 'user',
-					attributes: {
-						name: 'John Doe',
-						email: 'john@example.com'
-					},
-					meta: {
-						tokenRevocation: {
-							lastIssuedAt: iat - 200,
-							canRefresh: false // Cannot refresh
-						}
-					}
-				}
-			};
+			vi.mocked(get).mockRejectedValue(
+				new ApiError('Token revoked', 401, 'token_refresh_required')
+			);
+			vi.mocked(post).mockRejectedValue(
+				new ApiError('Sign in again', 401, 'reauthentication_required')
+			);
 
-			vi.mocked(get).mockResolvedValue(mockUser);
+			vi.mocked(getRequestEvent).mockReturnValue(mockRequestEvent);
+
+			await expect(authModule.getUserAndValidateToken()).rejects.toThrow('redirect');
+			expect(mockCookies.delete).toHaveBeenCalledWith('app-session-token', { path: '/' });
+		});
+
+		it('should redirect when the user has to sign in again', async () => {
+			const mockRequestEvent = createMockRequestEvent();
+			mockCookies.get.mockReturnValue('valid-token');
+			vi.mocked(jwtDecode).mockReturnValue({
+				iat: Math.floor(Date.now() / 1000) - 100
+			} as Partial<JwtClaims>);
+
+			vi.mocked(get).mockRejectedValue(
+				new ApiError('Sign in again', 401, 'reauthentication_required')
+			);
 
 			vi.mocked(getRequestEvent).mockReturnValue(mockRequestEvent);
 
 			await expect(authModule.getUserAndValidateToken()).rejects.toThrow('redirect');
+			expect(post).not.toHaveBeenCalled();
 			expect(mockCookies.delete).toHaveBeenCalledWith('app-session-token', { path: '/' });
 		});
 	});
@@ -418,6 +549,47 @@ describe('auth', () => {
 
 			await expect(authModule.refreshUserToken(mockRequestEvent)).rejects.toThrow();
 		});
+
+		it('should refresh a revoked token before switching organizations', async () => {
+			const mockRequestEvent = createMockRequestEvent({
+				params: { organizationId: 'org-123' }
+			});
+
+			vi.mocked(post)
+				.mockRejectedValueOnce(new ApiError('Token revoked', 401, 'token_refresh_required'))
+				.mockResolvedValueOnce({
+					data: {
+						type: 'token',
+						meta: { token: 'refreshed-token' }
+					}
+				} as Awaited<ReturnType<typeof post>>)
+				.mockResolvedValueOnce({
+					data: {
+						type: 'token',
+						meta: { token: 'organization-token' }
+					}
+				} as Awaited<ReturnType<typeof post>>);
+
+			await authModule.refreshUserToken(mockRequestEvent);
+
+			expect(post).toHaveBeenNthCalledWith(
+				2,
+				'/api/users/me/token/refresh',
+				{},
+				expect.any(Object)
+			);
+			expect(post).toHaveBeenNthCalledWith(
+				3,
+				'/api/users/me/token',
+				expect.any(Object),
+				expect.any(Object)
+			);
+			expect(mockCookies.set).toHaveBeenLastCalledWith(
+				'app-session-token',
+				'organization-token',
+				expect.any(Object)
+			);
+		});
 	});
 
 	describe('impersonateUser', () => {
@@ -551,6 +723,21 @@ describe('auth', () => {
 				sameSite: 'strict',
 				maxAge: 60 * 60 * 24 * 30 // 30 days
 			});
+			expect(mockCookies.set).toHaveBeenCalledTimes(1);
+		});
+
+		it('should set the refresh token in cookies when provided', () => {
+			const mockRequestEvent = createMockRequestEvent();
+
+			authModule.setTokenInCookies(mockRequestEvent, 'test-token-123', 'refresh-token-123');
+
+			expect(mockCookies.set).toHaveBeenCalledWith('app-refresh-token', 'refresh-token-123', {
+				path: '/',
+				httpOnly: true,
+				secure: true,
+				sameSite: 'strict',
+				maxAge: 60 * 60 * 24 * 30 // 30 days
+			});
 		});
 	});
 });
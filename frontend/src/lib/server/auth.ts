@@ -5,15 +5,32 @@ import type { JwtClaims } from '$lib/schemas/jwt';
 import { getSelfUserResponseSchema } from '$lib/schemas/user';
 import {
 	createAuthenticatedUserTokenRequestSchema,
-	createAuthenticatedUserTokenResponseSchema
+	createAuthenticatedUserTokenResponseSchema,
+	refreshAuthenticatedUserTokenRequestSchema,
+	rotateRefreshTokenRequestSchema
 } from '$lib/schemas/user.server';
 import { error, redirect, type RequestEvent } from '@sveltejs/kit';
 import { jwtDecode } from 'jwt-decode';
 
+// Error codes the API returns for tokens issued before the user's role or memberships changed
+const TOKEN_REFRESH_REQUIRED = 'token_refresh_required';
+const REAUTHENTICATION_REQUIRED = 'reauthentication_required';
+
+// A refresh token can only be exchanged once, exchanging it again signs the user out everywhere.
+// Browsers send requests in parallel, so requests with the same refresh token share one exchange.
+// The result is kept for requests sent before the browser stored the new cookies.
+const ROTATION_RESULT_TTL = 30 * 1000; // 30 seconds
+const refreshTokenRotations = new Map<string, Promise<{ token: string; refreshToken?: string }>>();
+
 export function isLoggedIn() {
 	const requestEvent = getRequestEvent();
 	const token = getToken(requestEvent);
 
+	// The access token is swapped for a new one on the next request to the app
+	if (getRefreshToken(requestEvent)) {
+		return true;
+	}
+
 	if (!token) {
 		return false;
 	}
@@ -42,7 +59,7 @@ export async function performAuthenticationCheck(requestEvent: RequestEvent) {
 	}
 
 	// Validate token is present and is not expired
-	validateTokenExpiration(requestEvent);
+	await validateTokenExpiration(requestEvent);
 
 	if (url.pathname.startsWith('/app/admin')) {
 		validateTokenAdmin(requestEvent);
@@ -70,12 +87,19 @@ export async function getUserAndValidateToken() {
 	let user;
 
 	try {
-		user = await get('/api/users/me', {
-			fetch: requestEvent.fetch,
-			responseSchema: getSelfUserResponseSchema
-		});
+		user = await withTokenRefresh(requestEvent, () =>
+			get('/api/users/me', {
+				fetch: requestEvent.fetch,
+				responseSchema: getSelfUserResponseSchema
+			})
+		);
 	} catch (apiError) {
 		if (apiError instanceof ApiError) {
+			// The user's tokens were revoked and can't be refreshed, so they have to sign in again
+			if (apiError.errorCode === REAUTHENTICATION_REQUIRED) {
+				signOut(requestEvent);
+			}
+
 			error(apiError.code, apiError.message);
 		}
 
@@ -84,21 +108,6 @@ export async function getUserAndValidateToken() {
 		error(500, 'An unknown error ocurred processing your request, please try again later.');
 	}
 
-	// If the token is older than the last issued at time, refresh the token
-	if (
-		user.data.meta.tokenRevocation?.lastIssuedAt &&
-		iat > new Date(user.data.meta.tokenRevocation.lastIssuedAt).getTime() / 1000
-	) {
-		console.log('Token expired, refreshing');
-		// If the token cannot be refreshed, sign the user out and redirect to the signin page
-		if (!user.data.meta.tokenRevocation.canRefresh) {
-			requestEvent.cookies.delete('app-session-token', { path: '/' });
-			redirect(303, getRedirectUrl(requestEvent));
-		}
-
-		await refreshUserToken(requestEvent);
-	}
-
 	return {
 		user
 	};
@@ -118,56 +127,65 @@ export async function refreshUserToken(
 	});
 
 	let newToken: string;
+	let newRefreshToken: string | undefined;
 	try {
-		const response = await post(
-			'/api/users/me/token',
-			{
-				data: {
-					type: API_TYPES.token,
-					relationships: {
-						...(options?.impersonatedUserId
-							? {
-									impersonatedUser: {
-										data: {
-											id: options.impersonatedUserId,
-											type: API_TYPES.user
+		const response = await withTokenRefresh(requestEvent, () =>
+			post(
+				'/api/users/me/token',
+				{
+					data: {
+						type: API_TYPES.token,
+						relationships: {
+							...(options?.impersonatedUserId
+								? {
+										impersonatedUser: {
+											data: {
+												id: options.impersonatedUserId,
+												type: API_TYPES.user
+											}
 										}
 									}
-								}
-							: {}),
-						...(organizationId
-							? {
-									organization: {
-										data: {
-											id: organizationId,
-											type: API_TYPES.organization
+								: {}),
+							...(organizationId
+								? {
+										organization: {
+											data: {
+												id: organizationId,
+												type: API_TYPES.organization
+											}
 										}
 									}
+								: {})
+						},
+						...(options?.stopImpersonating
+							? {
+									meta: {
+										stopImpersonating: true
+									}
 								}
 							: {})
-					},
-					...(options?.stopImpersonating
-						? {
-								meta: {
-									stopImpersonating: true
-								}
-							}
-						: {})
+					}
+				},
+				{
+					fetch,
+					requestSchema: createAuthenticatedUserTokenRequestSchema,
+					responseSchema: createAuthenticatedUserTokenResponseSchema
 				}
-			},
-			{
-				fetch,
-				requestSchema: createAuthenticatedUserTokenRequestSchema,
-				responseSchema: createAuthenticatedUserTokenResponseSchema
-			}
+			)
 		);
 
 		newToken = response.data.meta.token;
+		newRefreshToken = response.data.meta.refreshToken;
 		console.log('New token', newToken);
 	} catch (apiError) {
 		if (apiError instanceof ApiError) {
 			console.error('Error validating token organization', apiError.code, apiError.message);
 
+			// The user's tokens were revoked and can't be refreshed, so they have to sign in again
+			if (apiError.errorCode === REAUTHENTICATION_REQUIRED) {
+				signOut(requestEvent);
+			}
+
 			if (apiError.code === 404) {
 				// Organization membership not found, return auth error
 				error(404, 'Organization not found');
@@ -178,7 +196,80 @@ export async function refreshUserToken(
 	}
 
 	// Set the token in the cookies
-	setTokenInCookies(requestEvent, newToken);
+	setTokenInCookies(requestEvent, newToken, newRefreshToken);
+}
+
+// Revoked tokens are rejected until they are swapped for a token with the user's current claims.
+// Makes the request, and if the token was revoked, refreshes it and makes the request again.
+async function withTokenRefresh<T>(requestEvent: RequestEvent, request: () => Promise<T>) {
+	try {
+		return await request();
+	} catch (apiError) {
+		if (!(apiError instanceof ApiError) || apiError.errorCode !== TOKEN_REFRESH_REQUIRED) {
+			throw apiError;
+		}
+
+		console.log('Token revoked, refreshing');
+		await refreshRevokedUserToken(requestEvent);
+
+		return await request();
+	}
+}
+
+async function refreshRevokedUserToken(requestEvent: RequestEvent) {
+	// Fails with REAUTHENTICATION_REQUIRED if the user isn't allowed to refresh the token
+	const response = await post(
+		'/api/users/me/token/refresh',
+		{},
+		{
+			fetch: requestEvent.fetch,
+			requestSchema: refreshAuthenticatedUserTokenRequestSchema,
+			responseSchema: createAuthenticatedUserTokenResponseSchema
+		}
+	);
+
+	setTokenInCookies(requestEvent, response.data.meta.token, response.data.meta.refreshToken);
+}
+
+async function rotateRefreshToken(requestEvent: RequestEvent, refreshToken: string) {
+	let rotation = refreshTokenRotations.get(refreshToken);
+
+	if (!rotation) {
+		rotation = post(
+			'/api/users/token/rotate',
+			{
+				data: {
+					type: API_TYPES.token,
+					attributes: {
+						refreshToken
+					}
+				}
+			},
+			{
+				fetch: requestEvent.fetch,
+				requestSchema: rotateRefreshTokenRequestSchema,
+				responseSchema: createAuthenticatedUserTokenResponseSchema
+			}
+		).then((response) => response.data.meta);
+
+		refreshTokenRotations.set(refreshToken, rotation);
+		setTimeout(() => refreshTokenRotations.delete(refreshToken), ROTATION_RESULT_TTL);
+	}
+
+	let tokens;
+	try {
+		tokens = await rotation;
+	} catch (apiError) {
+		// The refresh token expired or was revoked, so the user has to sign in again
+		if (apiError instanceof ApiError) {
+			console.log('Refresh token rejected, redirecting', apiError.code, apiError.message);
+			signOut(requestEvent);
+		}
+
+		throw apiError;
+	}
+
+	setTokenInCookies(requestEvent, tokens.token, tokens.refreshToken);
 }
 
 export async function impersonateUser(requestEvent: RequestEvent, userId: string) {
@@ -217,20 +308,35 @@ function validateTokenAdmin(requestEvent: RequestEvent) {
 	}
 }
 
-function validateTokenExpiration(requestEvent: RequestEvent) {
-	const token = getDefinedToken(requestEvent);
+async function validateTokenExpiration(requestEvent: RequestEvent) {
+	const token = getToken(requestEvent);
+
+	if (token && !isTokenExpired(token)) {
+		return;
+	}
 
+	// Access tokens are short lived, so swap the refresh token for a new pair of tokens
+	const refreshToken = getRefreshToken(requestEvent);
+	if (refreshToken) {
+		console.log('User token expired, rotating refresh token');
+		await rotateRefreshToken(requestEvent, refreshToken);
+		return;
+	}
+
+	console.log('User token expired or not found, redirecting');
+	redirect(303, getRedirectUrl(requestEvent));
+}
+
+function isTokenExpired(token: string) {
 	// parse the token
 	const { exp } = jwtDecode(token);
 
-	if (exp) {
-		const tokenExpirationDate = new Date(exp * 1000);
-
-		if (tokenExpirationDate < new Date()) {
-			console.log('User token expired, redirecting');
-			redirect(303, getRedirectUrl(requestEvent));
-		}
+	if (!exp) {
+		return false;
 	}
+
+	const tokenExpirationDate = new Date(exp * 1000);
+	return tokenExpirationDate < new Date();
 }
 
 async function validateTokenOrganization(requestEvent: RequestEvent) {
@@ -260,6 +366,11 @@ function getToken(requestEvent: RequestEvent) {
 	return token;
 }
 
+function getRefreshToken(requestEvent: RequestEvent) {
+	const { cookies } = requestEvent;
+	return cookies.get('app-refresh-token');
+}
+
 function getDefinedToken(requestEvent: RequestEvent) {
 	const token = getToken(requestEvent);
 
@@ -271,13 +382,22 @@ function getDefinedToken(requestEvent: RequestEvent) {
 	return token;
 }
 
+function signOut(requestEvent: RequestEvent): never {
+	deleteTokensFromCookies(requestEvent);
+	redirect(303, getRedirectUrl(requestEvent));
+}
+
 function getRedirectUrl(requestEvent: RequestEvent) {
 	const { url } = requestEvent;
 	const currentPath = url.href;
 	return `/signin?redirect=${encodeURIComponent(currentPath)}`;
 }
 
-export function setTokenInCookies(requestEvent: RequestEvent, token: string) {
+export function setTokenInCookies(
+	requestEvent: RequestEvent,
+	token: string,
+	refreshToken?: string
+) {
 	const { cookies } = requestEvent;
 	cookies.set('app-session-token', token, {
 		path: '/',
@@ -286,4 +406,21 @@ export function setTokenInCookies(requestEvent: RequestEvent, token: string) {
 		sameSite: 'strict',
 		maxAge: 60 * 60 * 24 * 30 // 30 days
 	});
+
+	// Only set when the API issued a new refresh token, the existing one stays valid otherwise
+	if (refreshToken) {
+		cookies.set('app-refresh-token', refreshToken, {
+			path: '/',
+			httpOnly: true,
+			secure: true,
+			sameSite: 'strict',
+			maxAge: 60 * 60 * 24 * 30 // 30 days
+		});
+	}
+}
+
+export function deleteTokensFromCookies(requestEvent: RequestEvent) {
+	const { cookies } = requestEvent;
+	cookies.delete('app-session-token', { path: '/' });
+	cookies.delete('app-refresh-token', { path: '/' });
 }
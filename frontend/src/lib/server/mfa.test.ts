@@ -0,0 +1,167 @@
+import { describe, it, expect, vi, beforeEach, afterEach } from 'vitest';
+import { redirect } from '@sveltejs/kit';
+import { getRequestEvent } from '$app/server';
+import { ApiError, post } from '$lib/api';
+import { setTokenInCookies } from '$lib/server/auth';
+import * as mfaModule from './mfa';
+
+// Mock SvelteKit server functions
+vi.mock('$app/server', () => ({
+	getRequestEvent: vi.fn()
+}));
+
+vi.mock('@sveltejs/kit', () => ({
+	redirect: vi.fn()
+}));
+
+vi.mock('$lib/api', async (importOriginal) => ({
+	...(await importOriginal<typeof import('$lib/api')>()),
+	post: vi.fn()
+}));
+
+vi.mock('$lib/server/auth', () => ({
+	setTokenInCookies: vi.fn()
+}));
+
+import type { Cookies, RequestEvent } from '@sveltejs/kit';
+
+describe('mfa', () => {
+	const mockCookies = {
+		get: vi.fn(),
+		set: vi.fn(),
+		delete: vi.fn(),
+		getAll: vi.fn(),
+		serialize: vi.fn()
+	} as Cookies & {
+		get: ReturnType<typeof vi.fn>;
+		set: ReturnType<typeof vi.fn>;
+		delete: ReturnType<typeof vi.fn>;
+		getAll: ReturnType<typeof vi.fn>;
+		serialize: ReturnType<typeof vi.fn>;
+	};
+
+	const mockRequestEvent = {
+		cookies: mockCookies,
+		url: new URL('https://example.com/signin'),
+		params: {},
+		fetch: vi.fn(),
+		getClientAddress: vi.fn(),
+		locals: {},
+		platform: undefined,
+		request: new Request('https://example.com/signin'),
+		route: { id: null },
+		setHeaders: vi.fn(),
+		isDataRequest: false,
+		isSubRequest: false,
+		isRemoteRequest: false
+	} as unknown as RequestEvent;
+
+	beforeEach(() => {
+		vi.clearAllMocks();
+		vi.mocked(redirect).mockImplementation(() => {
+			throw new Error('redirect called');
+		});
+
+		vi.mocked(getRequestEvent).mockReturnValue(mockRequestEvent);
+	});
+
+	afterEach(() => {
+		vi.restoreAllMocks();
+	});
+
+	describe('startMfaChallenge', () => {
+		it('should keep the challenge in a cookie and redirect to the code step', () => {
+			expect(() =>
+				mfaModule.startMfaChallenge({
+					challengeToken: 'challenge-token',
+					successRedirectUrl: '/app/settings'
+				})
+			).toThrow('redirect called');
+
+			expect(mockCookies.set).toHaveBeenCalledWith(
+				'mfa_challenge',
+				'challenge-token',
+				expect.objectContaining({
+					path: '/',
+					httpOnly: true,
+					secure: true,
+					sameSite: 'strict',
+					maxAge: 60 * 5
+				})
+			);
+			expect(mockCookies.set).toHaveBeenCalledWith(
+				'mfa_success_redirect',
+				'/app/settings',
+				expect.any(Object)
+			);
+			expect(redirect).toHaveBeenCalledWith(302, '/signin/mfa');
+		});
+	});
+
+	describe('completeMfaChallenge', () => {
+		beforeEach(() => {
+			mockCookies.get.mockImplementation((name: string) =>
+				name === 'mfa_challenge' ? 'challenge-token' : '/app/settings'
+			);
+			vi.mocked(post).mockResolvedValue({
+				data: {
+					id: 'user-id',
+					type: 'user',
+					meta: { token: 'session-token', refreshToken: 'refresh-token' }
+				}
+			});
+		});
+
+		it('should exchange the challenge and code for a session', async () => {
+			const redirectUrl = await mfaModule.completeMfaChallenge({
+				code: '123456',
+				recoveryCode: ''
+			});
+
+			expect(redirectUrl).toBe('/app/settings');
+			expect(post).toHaveBeenCalledWith(
+				'/api/users/login/mfa',
+				{
+					data: {
+						attributes: {
+							mfaChallengeToken: 'challenge-token',
+							code: '123456',
+							recoveryCode: undefined
+						}
+					}
+				},
+				expect.objectContaining({ fetch: mockRequestEvent.fetch })
+			);
+			expect(setTokenInCookies).toHaveBeenCalledWith(
+				mockRequestEvent,
+				'session-token',
+				'refresh-token'
+			);
+			expect(mockCookies.delete).toHaveBeenCalledWith('mfa_challenge', { path: '/' });
+			expect(mockCookies.delete).toHaveBeenCalledWith('mfa_success_redirect', { path: '/' });
+		});
+
+		it('should keep the challenge when the code is wrong', async () => {
+			vi.mocked(post).mockRejectedValue(
+				new ApiError('invalid multi-factor authentication code', 401)
+			);
+
+			await expect(mfaModule.completeMfaChallenge({ code: '000000' })).rejects.toThrow(
+				'invalid multi-factor authentication code'
+			);
+
+			expect(setTokenInCookies).not.toHaveBeenCalled();
+			expect(mockCookies.delete).not.toHaveBeenCalled();
+		});
+
+		it('should reject when there is no challenge', async () => {
+			mockCookies.get.mockReturnValue(undefined);
+
+			await expect(mfaModule.completeMfaChallenge({ code: '123456' })).rejects.toBeInstanceOf(
+				ApiError
+			);
+
+			expect(post).not.toHaveBeenCalled();
+		});
+	});
+});
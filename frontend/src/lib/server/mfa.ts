@@ -0,0 +1,89 @@
+import { getRequestEvent } from '$app/server';
+import { redirect } from '@sveltejs/kit';
+import { ApiError, post } from '$lib/api';
+import { setTokenInCookies } from '$lib/server/auth';
+import { loginWithMfaRequestSchema, loginWithMfaResponseSchema } from '$lib/schemas/user.server';
+
+// Users with MFA enabled get a challenge token instead of a session when they sign in. It's kept in a cookie so it
+// never reaches the browser's JavaScript, and is exchanged for a session once they enter a code.
+const MFA_CHALLENGE_COOKIE = 'mfa_challenge';
+const MFA_SUCCESS_REDIRECT_COOKIE = 'mfa_success_redirect';
+const MFA_CHALLENGE_MAX_AGE = 60 * 5; // 5 minutes, the same as the challenge token
+
+export function startMfaChallenge({
+	challengeToken,
+	successRedirectUrl
+}: {
+	challengeToken: string;
+	successRedirectUrl: string;
+}) {
+	const { cookies } = getRequestEvent();
+	const cookieOptions = {
+		path: '/',
+		httpOnly: true,
+		secure: true,
+		sameSite: 'strict',
+		maxAge: MFA_CHALLENGE_MAX_AGE
+	} as const;
+
+	cookies.set(MFA_CHALLENGE_COOKIE, challengeToken, cookieOptions);
+	cookies.set(MFA_SUCCESS_REDIRECT_COOKIE, successRedirectUrl, cookieOptions);
+
+	redirect(302, '/signin/mfa');
+}
+
+export function hasMfaChallenge() {
+	const { cookies } = getRequestEvent();
+	return !!cookies.get(MFA_CHALLENGE_COOKIE);
+}
+
+// completeMfaChallenge signs the user in with the code they entered, and returns where to send them next
+export async function completeMfaChallenge({
+	code,
+	recoveryCode
+}: {
+	code?: string;
+	recoveryCode?: string;
+}) {
+	const requestEvent = getRequestEvent();
+	const { cookies, fetch } = requestEvent;
+	const challengeToken = cookies.get(MFA_CHALLENGE_COOKIE);
+	const successRedirectUrl = cookies.get(MFA_SUCCESS_REDIRECT_COOKIE) ?? '/app';
+
+	if (!challengeToken) {
+		throw new ApiError('Your sign in has expired, please sign in again', 401);
+	}
+
+	const userWithToken = await post(
+		'/api/users/login/mfa',
+		{
+			data: {
+				attributes: {
+					mfaChallengeToken: challengeToken,
+					code: code || undefined,
+					recoveryCode: recoveryCode || undefined
+				}
+			}
+		},
+		{
+			fetch,
+			requestSchema: loginWithMfaRequestSchema,
+			responseSchema: loginWithMfaResponseSchema
+		}
+	);
+
+	setTokenInCookies(
+		requestEvent,
+		userWithToken.data.meta.token,
+		userWithToken.data.meta.refreshToken
+	);
+	deleteMfaChallengeCookies();
+
+	return successRedirectUrl;
+}
+
+export function deleteMfaChallengeCookies() {
+	const { cookies } = getRequestEvent();
+	cookies.delete(MFA_CHALLENGE_COOKIE, { path: '/' });
+	cookies.delete(MFA_SUCCESS_REDIRECT_COOKIE, { path: '/' });
+}
@@ -1,6 +1,7 @@
 import { describe, it, expect, vi, beforeEach, afterEach } from 'vitest';
 import { redirect } from '@sveltejs/kit';
 import { getRequestEvent } from '$app/server';
+import { post } from '$lib/api';
 import * as oauthModule from './oauth';
 
 // Mock SvelteKit server functions
@@ -12,24 +13,10 @@ vi.mock('@sveltejs/kit', () => ({
 	redirect: vi.fn()
 }));
 
-vi.mock('$env/dynamic/public', () => ({
-	env: {
-		PUBLIC_GOOGLE_OAUTH_CLIENT_ID: 'test-client-id'
-	}
+vi.mock('$lib/api', () => ({
+	post: vi.fn()
 }));
 
-// Mock crypto for state generation
-Object.defineProperty(globalThis, 'crypto', {
-	value: {
-		getRandomValues: (arr: Uint8Array) => {
-			for (let i = 0; i < arr.length; i++) {
-				arr[i] = Math.floor(Math.random() * 256);
-			}
-			return arr;
-		}
-	}
-});
-
 import type { Cookies, RequestEvent } from '@sveltejs/kit';
 
 describe('oauth', () => {
@@ -77,15 +64,30 @@ describe('oauth', () => {
 	});
 
 	describe('performGoogleOAuth', () => {
-		it('should perform OAuth with default redirect URL', () => {
-			expect(() => {
-				oauthModule.performGoogleOAuth(undefined);
-			}).toThrow('redirect called');
+		beforeEach(() => {
+			vi.mocked(post).mockResolvedValue({
+				data: {
+					type: 'oauth-authorization',
+					attributes: {
+						authorizationUrl:
+							'https://accounts.google.com/o/oauth2/v2/auth?client_id=test-client-id&state=server-state-123',
+						state: 'server-state-123',
+						expiresAt: '2025-01-01T00:10:00Z'
+					}
+				}
+			});
+		});
+
+		it('should perform OAuth with default redirect URL', async () => {
+			await expect(oauthModule.performGoogleOAuth(undefined)).rejects.toThrow('redirect called');
 
-			expect(redirect).toHaveBeenCalledWith(302, expect.stringContaining('accounts.google.com'));
+			expect(redirect).toHaveBeenCalledWith(
+				302,
+				'https://accounts.google.com/o/oauth2/v2/auth?client_id=test-client-id&state=server-state-123'
+			);
 			expect(mockCookies.set).toHaveBeenCalledWith(
 				'oauth_state',
-				expect.any(String),
+				'server-state-123',
 				expect.objectContaining({
 					path: '/',
 					httpOnly: true,
@@ -101,10 +103,10 @@ describe('oauth', () => {
 			);
 		});
 
-		it('should perform OAuth with custom redirect URL', () => {
-			expect(() => {
-				oauthModule.performGoogleOAuth('/custom/redirect');
-			}).toThrow('redirect called');
+		it('should perform OAuth with custom redirect URL', async () => {
+			await expect(oauthModule.performGoogleOAuth('/custom/redirect')).rejects.toThrow(
+				'redirect called'
+			);
 
 			expect(mockCookies.set).toHaveBeenCalledWith(
 				'oauth_success_redirect',
@@ -113,36 +115,31 @@ describe('oauth', () => {
 			);
 		});
 
-		it('should generate OAuth URL with correct parameters', () => {
-			try {
-				oauthModule.performGoogleOAuth('/app');
-			} catch {
-				// Expected to throw due to redirect
-			}
-
-			const redirectCall = vi.mocked(redirect).mock.calls[0];
-			const oauthUrl = redirectCall[1] as string;
-
-			expect(oauthUrl).toContain('accounts.google.com/o/oauth2/v2/auth');
-			expect(oauthUrl).toContain('client_id=test-client-id');
-			expect(oauthUrl).toContain('response_type=code');
-			// URLSearchParams encodes spaces as +, not %20
-			expect(oauthUrl).toMatch(/scope=email(\+|%20)profile/);
-			expect(oauthUrl).toContain('access_type=offline');
-			expect(oauthUrl).toContain('redirect_uri=');
-			expect(oauthUrl).toContain('state=');
+		it('should start the authorization with the backend', async () => {
+			await expect(oauthModule.performGoogleOAuth('/app')).rejects.toThrow('redirect called');
+
+			expect(post).toHaveBeenCalledWith(
+				'/api/oauth/google/authorize',
+				{
+					data: {
+						attributes: {
+							redirectUri: 'https://example.com/oauth/google/callback'
+						}
+					}
+				},
+				expect.objectContaining({ fetch: mockRequestEvent.fetch })
+			);
 		});
 
-		it('should generate OAuth URL even with empty client ID', () => {
-			// Note: This test verifies the function doesn't crash with empty client ID
-			// The actual client ID value is tested in the main performGoogleOAuth test
-			try {
-				oauthModule.performGoogleOAuth('/app');
-			} catch {
-				// Expected to throw due to redirect
-			}
+		it('should not redirect when the backend rejects the authorization', async () => {
+			vi.mocked(post).mockRejectedValue(new Error('Redirect URI not allowed'));
+
+			await expect(oauthModule.performGoogleOAuth('/app')).rejects.toThrow(
+				'Redirect URI not allowed'
+			);
 
-			expect(redirect).toHaveBeenCalled();
+			expect(redirect).not.toHaveBeenCalled();
+			expect(mockCookies.set).not.toHaveBeenCalled();
 		});
 	});
 
@@ -1,24 +1,32 @@
 import { getRequestEvent } from '$app/server';
-import { env } from '$env/dynamic/public';
 import { redirect } from '@sveltejs/kit';
-
-// Google OAuth configuration
-const GOOGLE_OAUTH_SCOPE = 'email profile';
-const GOOGLE_OAUTH_RESPONSE_TYPE = 'code';
-const GOOGLE_OAUTH_URL = 'https://accounts.google.com/o/oauth2/v2/auth';
-
-export function performGoogleOAuth(redirect: string | undefined) {
-	const googleClientId = env.PUBLIC_GOOGLE_OAUTH_CLIENT_ID || '';
-
-	performOAuth({
-		clientId: googleClientId,
-		successRedirectUrl: redirect ?? '/app',
-		scope: GOOGLE_OAUTH_SCOPE,
-		responseType: GOOGLE_OAUTH_RESPONSE_TYPE,
-		accessType: 'offline',
-		state: generateState(),
-		oauthUrl: GOOGLE_OAUTH_URL,
-		oauthCallbackUrl: getGoogleOAuthCallbackUrl()
+import { z } from 'zod';
+import { post } from '$lib/api';
+
+// Schemas for starting an OAuth authorization with the backend
+const startOAuthAuthorizationRequestSchema = z.object({
+	data: z.object({
+		attributes: z.object({
+			redirectUri: z.string()
+		})
+	})
+});
+
+const oauthAuthorizationResponseSchema = z.object({
+	data: z.object({
+		type: z.literal('oauth-authorization'),
+		attributes: z.object({
+			authorizationUrl: z.string(),
+			state: z.string(),
+			expiresAt: z.string()
+		})
+	})
+});
+
+export async function performGoogleOAuth(redirect: string | undefined) {
+	await performOAuth({
+		provider: 'google',
+		successRedirectUrl: redirect ?? '/app'
 	});
 }
 
@@ -44,39 +52,34 @@ export function deleteOAuthCookies() {
 	cookies.delete('oauth_success_redirect', { path: '/' });
 }
 
-function performOAuth({
-	clientId,
-	successRedirectUrl,
-	scope,
-	responseType,
-	accessType,
-	state,
-	oauthUrl,
-	oauthCallbackUrl
+async function performOAuth({
+	provider,
+	successRedirectUrl
 }: {
-	clientId: string;
+	provider: string;
 	successRedirectUrl: string;
-	scope: string;
-	responseType: string;
-	accessType: 'offline';
-	state: string;
-	oauthUrl: string;
-	oauthCallbackUrl: string;
 }) {
-	// generate the url
-	const oauthRedirectUrl = generateOAuthUrl({
-		clientId,
-		oauthUrl,
-		oauthCallbackUrl,
-		scope,
-		responseType,
-		state,
-		accessType
-	});
-
-	// save the challenge in the cookies, so we can verify it later
-	const { cookies } = getRequestEvent();
-	// store the challenge
+	const { cookies, fetch } = getRequestEvent();
+
+	// the backend generates the state and PKCE verifier, and builds the provider's authorization url
+	const response = await post(
+		`/api/oauth/${provider}/authorize`,
+		{
+			data: {
+				attributes: {
+					redirectUri: getOAuthCallbackUrl(provider)
+				}
+			}
+		},
+		{
+			fetch,
+			requestSchema: startOAuthAuthorizationRequestSchema,
+			responseSchema: oauthAuthorizationResponseSchema
+		}
+	);
+	const { authorizationUrl, state } = response.data.attributes;
+
+	// store the state, so we can verify the callback comes from the browser that started the login
 	cookies.set('oauth_state', state, {
 		path: '/',
 		httpOnly: true,
@@ -93,46 +96,11 @@ function performOAuth({
 		maxAge: 60 * 5 // 5 minutes
 	});
 
-	redirect(302, oauthRedirectUrl);
-}
-
-function generateState(): string {
-	const array = new Uint8Array(32);
-	crypto.getRandomValues(array);
-	return Array.from(array, (byte) => byte.toString(16).padStart(2, '0')).join('');
+	redirect(302, authorizationUrl);
 }
 
-function getGoogleOAuthCallbackUrl(): string {
+function getOAuthCallbackUrl(provider: string): string {
 	const { url } = getRequestEvent();
 
-	return `${url.origin}/oauth/google/callback`;
-}
-
-function generateOAuthUrl({
-	clientId,
-	oauthUrl,
-	oauthCallbackUrl,
-	scope,
-	responseType,
-	state,
-	accessType
-}: {
-	clientId: string;
-	oauthUrl: string;
-	oauthCallbackUrl: string;
-	scope: string;
-	responseType: string;
-	state: string;
-	accessType: 'offline';
-}) {
-	const params = new URLSearchParams({
-		client_id: clientId,
-		redirect_uri: oauthCallbackUrl,
-		response_type: responseType,
-		scope: scope,
-		state: state,
-		access_type: accessType
-	});
-
-	return `${oauthUrl}?${params.toString()}`;
+	return `${url.origin}/oauth/${provider}/callback`;
 }
@@ -0,0 +1,132 @@
+import { describe, it, expect, vi, beforeEach, afterEach } from 'vitest';
+import { redirect } from '@sveltejs/kit';
+import { getRequestEvent } from '$app/server';
+import { post } from '$lib/api';
+import * as samlModule from './saml';
+
+// Mock SvelteKit server functions
+vi.mock('$app/server', () => ({
+	getRequestEvent: vi.fn()
+}));
+
+vi.mock('@sveltejs/kit', () => ({
+	redirect: vi.fn()
+}));
+
+vi.mock('$lib/api', () => ({
+	post: vi.fn()
+}));
+
+import type { Cookies, RequestEvent } from '@sveltejs/kit';
+
+describe('saml', () => {
+	const mockCookies = {
+		get: vi.fn(),
+		set: vi.fn(),
+		delete: vi.fn(),
+		getAll: vi.fn(),
+		serialize: vi.fn()
+	} as Cookies & {
+		get: ReturnType<typeof vi.fn>;
+		set: ReturnType<typeof vi.fn>;
+		delete: ReturnType<typeof vi.fn>;
+		getAll: ReturnType<typeof vi.fn>;
+		serialize: ReturnType<typeof vi.fn>;
+	};
+
+	const mockRequestEvent = {
+		cookies: mockCookies,
+		url: new URL('https://example.com/signin'),
+		params: {},
+		fetch: vi.fn(),
+		getClientAddress: vi.fn(),
+		locals: {},
+		platform: undefined,
+		request: new Request('https://example.com/signin'),
+		route: { id: null },
+		setHeaders: vi.fn(),
+		isDataRequest: false,
+		isSubRequest: false,
+		isRemoteRequest: false
+	} as unknown as RequestEvent;
+
+	beforeEach(() => {
+		vi.clearAllMocks();
+		vi.mocked(redirect).mockImplementation(() => {
+			throw new Error('redirect called');
+		});
+
+		vi.mocked(getRequestEvent).mockReturnValue(mockRequestEvent);
+	});
+
+	afterEach(() => {
+		vi.restoreAllMocks();
+	});
+
+	describe('performSamlLogin', () => {
+		beforeEach(() => {
+			vi.mocked(post).mockResolvedValue({
+				data: {
+					type: 'saml-authorization',
+					attributes: {
+						authorizationUrl: 'https://idp.example.com/sso?SAMLRequest=request',
+						state: 'server-state-123',
+						expiresAt: '2025-01-01T00:10:00Z'
+					}
+				}
+			});
+		});
+
+		it('should redirect to the identity provider and store the state', async () => {
+			await expect(
+				samlModule.performSamlLogin({ organizationId: 'org-123', successRedirectUrl: '/app' })
+			).rejects.toThrow('redirect called');
+
+			expect(post).toHaveBeenCalledWith(
+				'/api/saml/org-123/login',
+				{},
+				expect.objectContaining({ fetch: mockRequestEvent.fetch })
+			);
+			expect(redirect).toHaveBeenCalledWith(302, 'https://idp.example.com/sso?SAMLRequest=request');
+			expect(mockCookies.set).toHaveBeenCalledWith(
+				'saml_state',
+				'server-state-123',
+				expect.objectContaining({
+					path: '/',
+					httpOnly: true,
+					secure: true,
+					sameSite: 'strict'
+				})
+			);
+			expect(mockCookies.set).toHaveBeenCalledWith(
+				'saml_success_redirect',
+				'/app',
+				expect.any(Object)
+			);
+		});
+	});
+
+	describe('verifySaml', () => {
+		it('should return the redirect url when the state matches', () => {
+			mockCookies.get.mockImplementation((name: string) =>
+				name === 'saml_state' ? 'server-state-123' : '/app/settings'
+			);
+
+			expect(samlModule.verifySaml({ state: 'server-state-123' })).toBe('/app/settings');
+		});
+
+		it('should reject a state from another login', () => {
+			mockCookies.get.mockImplementation((name: string) =>
+				name === 'saml_state' ? 'server-state-123' : '/app'
+			);
+
+			expect(samlModule.verifySaml({ state: 'other-state' })).toBeUndefined();
+		});
+
+		it('should reject when the browser never started a login', () => {
+			mockCookies.get.mockReturnValue(undefined);
+
+			expect(samlModule.verifySaml({ state: 'server-state-123' })).toBeUndefined();
+		});
+	});
+});
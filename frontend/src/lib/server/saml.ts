@@ -0,0 +1,79 @@
+import { getRequestEvent } from '$app/server';
+import { redirect } from '@sveltejs/kit';
+import { z } from 'zod';
+import { post } from '$lib/api';
+
+// Schemas for starting a SAML login with the backend
+const startSamlLoginRequestSchema = z.object({});
+
+const samlAuthorizationResponseSchema = z.object({
+	data: z.object({
+		type: z.literal('saml-authorization'),
+		attributes: z.object({
+			authorizationUrl: z.string(),
+			state: z.string(),
+			expiresAt: z.string()
+		})
+	})
+});
+
+// performSamlLogin sends the user to their organization's identity provider
+export async function performSamlLogin({
+	organizationId,
+	successRedirectUrl
+}: {
+	organizationId: string;
+	successRedirectUrl: string;
+}) {
+	const { cookies, fetch } = getRequestEvent();
+
+	const response = await post(
+		`/api/saml/${organizationId}/login`,
+		{},
+		{
+			fetch,
+			requestSchema: startSamlLoginRequestSchema,
+			responseSchema: samlAuthorizationResponseSchema
+		}
+	);
+	const { authorizationUrl, state } = response.data.attributes;
+
+	// store the state, so we can verify the callback comes from the browser that started the login
+	cookies.set('saml_state', state, {
+		path: '/',
+		httpOnly: true,
+		secure: true,
+		sameSite: 'strict',
+		maxAge: 60 * 10 // 10 minutes, the same as the backend's SAML login
+	});
+	// store the redirect url used on success
+	cookies.set('saml_success_redirect', successRedirectUrl, {
+		path: '/',
+		httpOnly: true,
+		secure: true,
+		sameSite: 'strict',
+		maxAge: 60 * 10 // 10 minutes
+	});
+
+	redirect(302, authorizationUrl);
+}
+
+// verifySaml checks the callback belongs to the login this browser started, and returns where to send
+// the user next
+export function verifySaml({ state }: { state: string }) {
+	const { cookies } = getRequestEvent();
+	const storedState = cookies.get('saml_state');
+	const successRedirectUrl = cookies.get('saml_success_redirect');
+
+	if (!state || !successRedirectUrl || state !== storedState) {
+		return undefined;
+	}
+
+	return successRedirectUrl;
+}
+
+export function deleteSamlCookies() {
+	const { cookies } = getRequestEvent();
+	cookies.delete('saml_state', { path: '/' });
+	cookies.delete('saml_success_redirect', { path: '/' });
+}
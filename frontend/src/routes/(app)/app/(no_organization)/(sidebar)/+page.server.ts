@@ -1,4 +1,4 @@
-import { authenticate, stopImpersonatingUser } from '$lib/server/auth';
+import { authenticate, deleteTokensFromCookies, stopImpersonatingUser } from '$lib/server/auth';
 import { redirect } from '@sveltejs/kit';
 import type { Actions, PageLoad } from './$types';
 
@@ -7,8 +7,8 @@ export const load: PageLoad = async () => {
 };
 
 export const actions = {
-	signout: async ({ cookies }) => {
-		cookies.delete('app-session-token', { path: '/' });
+	signout: async (requestEvent) => {
+		deleteTokensFromCookies(requestEvent);
 		redirect(302, '/signin');
 	},
 	stopImpersonation: async (event) => {
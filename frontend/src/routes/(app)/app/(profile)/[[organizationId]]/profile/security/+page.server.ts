@@ -1,7 +1,7 @@
 import { fail } from '@sveltejs/kit';
 import { ApiError, patch } from '$lib';
 import type { Actions } from './$types';
-import { authenticate } from '$lib/server/auth';
+import { authenticate, setTokenInCookies } from '$lib/server/auth';
 import { updateUserRequestSchema, updateUserResponseSchema } from '$lib/schemas/user';
 import { updateUserSecurityFormSchema } from '$lib/schemas/user.server';
 import { isParseSuccess, parseFormData } from '$lib/server/schema';
@@ -11,14 +11,15 @@ export const load = async () => {
 };
 
 export const actions = {
-	default: async ({ request, fetch }) => {
+	default: async (requestEvent) => {
+		const { request, fetch } = requestEvent;
 		const formData = await parseFormData(request, updateUserSecurityFormSchema);
 
 		if (!isParseSuccess(formData)) {
 			return formData;
 		}
 
-		const { userId, email, password, confirmPassword } = formData;
+		const { userId, email, password, confirmPassword, currentPassword } = formData;
 
 		if (password && password !== confirmPassword) {
 			return fail(400, { success: false, message: 'Passwords do not match.' });
@@ -37,7 +38,8 @@ export const actions = {
 						type: 'user',
 						attributes: {
 							email,
-							password
+							password,
+							currentPassword
 						}
 					}
 				},
@@ -48,6 +50,11 @@ export const actions = {
 				}
 			);
 
+			// Keep this session signed in, its old token was revoked along with every other session's
+			if (user.data.meta.token) {
+				setTokenInCookies(requestEvent, user.data.meta.token, user.data.meta.refreshToken);
+			}
+
 			return {
 				success: true,
 				message: 'User updated successfully',
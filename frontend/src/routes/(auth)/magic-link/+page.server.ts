@@ -0,0 +1,76 @@
+import { fail, redirect } from '@sveltejs/kit';
+import type { Actions } from './$types';
+import { post, ApiError } from '$lib';
+import { setTokenInCookies } from '$lib/server/auth';
+import { startMfaChallenge } from '$lib/server/mfa';
+import {
+	loginWithMagicLinkRequestSchema,
+	loginWithMagicLinkResponseSchema,
+	magicLinkFormSchema
+} from '$lib/schemas/user.server';
+import { isParseSuccess, parseFormData } from '$lib/server/schema';
+
+export const actions = {
+	// Signing in takes a form submission rather than happening on load, so email link scanners don't use up the token
+	default: async (requestEvent) => {
+		const { request, fetch } = requestEvent;
+		const formData = await parseFormData(request, magicLinkFormSchema);
+
+		if (!isParseSuccess(formData)) {
+			return formData;
+		}
+
+		let mfaChallengeToken: string | undefined;
+
+		try {
+			const userWithToken = await post(
+				'/api/users/login/magic-link',
+				{
+					data: {
+						attributes: {
+							token: formData.token
+						}
+					}
+				},
+				{
+					fetch,
+					requestSchema: loginWithMagicLinkRequestSchema,
+					responseSchema: loginWithMagicLinkResponseSchema
+				}
+			);
+
+			const { token, refreshToken } = userWithToken.data.meta;
+
+			if (userWithToken.data.meta.mfaChallengeToken) {
+				mfaChallengeToken = userWithToken.data.meta.mfaChallengeToken;
+			} else if (token) {
+				setTokenInCookies(requestEvent, token, refreshToken);
+			} else {
+				return fail(500, { success: false, message: 'The sign in response was missing a token' });
+			}
+		} catch (error) {
+			if (error instanceof ApiError) {
+				if (error.code === 401) {
+					return fail(401, {
+						success: false,
+						message: 'This sign in link is invalid or has expired, request a new one and try again'
+					});
+				}
+
+				return fail(error.code, { success: false, message: error.message });
+			}
+
+			return fail(500, {
+				success: false,
+				message: 'An unknown error ocurred processing your request, please try again later.'
+			});
+		}
+
+		// Redirecting throws, so it happens outside of the try block
+		if (mfaChallengeToken) {
+			startMfaChallenge({ challengeToken: mfaChallengeToken, successRedirectUrl: '/app' });
+		}
+
+		redirect(302, '/app');
+	}
+} satisfies Actions;
@@ -0,0 +1,55 @@
+import { fail } from '@sveltejs/kit';
+import { z } from 'zod';
+import type { Actions } from './$types';
+import { post, ApiError } from '$lib';
+import {
+	confirmPasswordResetRequestSchema,
+	resetPasswordFormSchema
+} from '$lib/schemas/user.server';
+import { isParseSuccess, parseFormData } from '$lib/server/schema';
+
+export const actions = {
+	default: async ({ request, fetch }) => {
+		const formData = await parseFormData(request, resetPasswordFormSchema);
+
+		if (!isParseSuccess(formData)) {
+			return formData;
+		}
+
+		const { token, password, confirmPassword } = formData;
+
+		if (password !== confirmPassword) {
+			return fail(400, { success: false, message: 'Passwords do not match.' });
+		}
+
+		try {
+			await post(
+				'/api/users/password-reset/confirm',
+				{
+					data: {
+						attributes: {
+							token,
+							password
+						}
+					}
+				},
+				{
+					fetch,
+					requestSchema: confirmPasswordResetRequestSchema,
+					responseSchema: z.undefined()
+				}
+			);
+		} catch (error) {
+			if (error instanceof ApiError) {
+				return fail(error.code, { success: false, message: error.message });
+			}
+
+			return fail(500, {
+				success: false,
+				message: 'An unknown error ocurred processing your request, please try again later.'
+			});
+		}
+
+		return { success: true };
+	}
+} satisfies Actions;
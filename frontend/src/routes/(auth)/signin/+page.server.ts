@@ -4,6 +4,9 @@ import { z } from 'zod';
 import { post, ApiError } from '$lib';
 import { env } from '$env/dynamic/private';
 import { performGoogleOAuth } from '$lib/server/oauth';
+import { setTokenInCookies } from '$lib/server/auth';
+import { startMfaChallenge } from '$lib/server/mfa';
+import { performSamlLogin } from '$lib/server/saml';
 import { signinFormSchema } from '$lib/schemas/user.server';
 import { isParseSuccess, parseFormData } from '$lib/server/schema';
 
@@ -16,6 +19,7 @@ const loginUserRequestSchema = z.object({
 	})
 });
 
+// Users with MFA enabled get a challenge instead of a session token
 const loginUserResponseSchema = z.object({
 	data: z.object({
 		id: z.string(),
@@ -25,13 +29,16 @@ const loginUserResponseSchema = z.object({
 			email: z.string()
 		}),
 		meta: z.object({
-			token: z.string()
+			token: z.string().optional(),
+			refreshToken: z.string().optional(),
+			mfaChallengeToken: z.string().optional()
 		})
 	})
 });
 
 export const actions = {
-	signin: async ({ cookies, request, fetch }) => {
+	signin: async (requestEvent) => {
+		const { request, fetch } = requestEvent;
 		const formData = await parseFormData(request, signinFormSchema);
 
 		if (!isParseSuccess(formData)) {
@@ -47,6 +54,9 @@ export const actions = {
 			return fail(403, { success: false, message: 'Sign in is disabled' });
 		}
 
+		let mfaChallengeToken: string | undefined;
+		let ssoOrganizationId: string | undefined;
+
 		try {
 			const userWithToken = await post(
 				`/api/users/login`,
@@ -65,26 +75,52 @@ export const actions = {
 				}
 			);
 
-			// set session token cookie
-			cookies.set('app-session-token', userWithToken.data.meta.token, {
-				httpOnly: true,
-				secure: env.NODE_ENV === 'production',
-				sameSite: 'strict',
-				path: '/',
-				maxAge: 60 * 60 * 24 * 30 // 30 days
-			});
+			const { token, refreshToken } = userWithToken.data.meta;
+
+			if (userWithToken.data.meta.mfaChallengeToken) {
+				mfaChallengeToken = userWithToken.data.meta.mfaChallengeToken;
+			} else if (token) {
+				// set session token cookies
+				setTokenInCookies(requestEvent, token, refreshToken);
+			} else {
+				return fail(500, { success: false, message: 'The sign in response was missing a token' });
+			}
 		} catch (error) {
 			if (error instanceof ApiError) {
 				if (error.code === 401) {
 					return fail(401, { success: false, message: 'Invalid email or password' });
 				}
 
-				return fail(500, { success: false, message: error.message });
+				if (error.code === 429) {
+					return fail(429, {
+						success: false,
+						message: 'Too many failed sign in attempts, please try again later'
+					});
+				}
+
+				// The password was right, but the user's organization requires its identity provider
+				if (error.errorCode === 'sso_required' && error.meta?.organizationId) {
+					ssoOrganizationId = error.meta.organizationId;
+				} else {
+					return fail(500, { success: false, message: error.message });
+				}
+			} else {
+				return fail(500, {
+					success: false,
+					message: 'An unknown error ocurred processing your request, please try again later.'
+				});
 			}
+		}
+
+		// Redirecting throws, so it happens outside of the try block
+		if (mfaChallengeToken) {
+			startMfaChallenge({ challengeToken: mfaChallengeToken, successRedirectUrl: redirectUrl });
+		}
 
-			return fail(500, {
-				success: false,
-				message: 'An unknown error ocurred processing your request, please try again later.'
+		if (ssoOrganizationId) {
+			await performSamlLogin({
+				organizationId: ssoOrganizationId,
+				successRedirectUrl: redirectUrl
 			});
 		}
 
@@ -94,6 +130,6 @@ export const actions = {
 		const data = await request.formData();
 		const redirectUrl = data.get('redirect') as string | undefined;
 
-		performGoogleOAuth(redirectUrl ?? '/app');
+		await performGoogleOAuth(redirectUrl ?? '/app');
 	}
 } satisfies Actions;
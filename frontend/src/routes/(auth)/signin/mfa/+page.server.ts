@@ -0,0 +1,51 @@
+import { fail, redirect } from '@sveltejs/kit';
+import type { Actions } from './$types';
+import { ApiError } from '$lib';
+import { mfaFormSchema } from '$lib/schemas/user.server';
+import { completeMfaChallenge, hasMfaChallenge } from '$lib/server/mfa';
+import { isParseSuccess, parseFormData } from '$lib/server/schema';
+
+export const load = async () => {
+	// The code step only makes sense right after signing in with a password or magic link
+	if (!hasMfaChallenge()) {
+		redirect(302, '/signin');
+	}
+};
+
+export const actions = {
+	default: async ({ request }) => {
+		const formData = await parseFormData(request, mfaFormSchema);
+
+		if (!isParseSuccess(formData)) {
+			return formData;
+		}
+
+		let redirectUrl: string;
+
+		try {
+			redirectUrl = await completeMfaChallenge(formData);
+		} catch (error) {
+			if (error instanceof ApiError) {
+				if (error.code === 401) {
+					return fail(401, { success: false, message: error.message });
+				}
+
+				if (error.code === 429) {
+					return fail(429, {
+						success: false,
+						message: 'Too many failed sign in attempts, please try again later'
+					});
+				}
+
+				return fail(error.code, { success: false, message: error.message });
+			}
+
+			return fail(500, {
+				success: false,
+				message: 'An unknown error ocurred processing your request, please try again later.'
+			});
+		}
+
+		redirect(302, redirectUrl);
+	}
+} satisfies Actions;
@@ -4,11 +4,13 @@ import { post } from '$lib';
 import { env } from '$env/dynamic/private';
 import { createUserRequestSchema, createUserResponseSchema } from '$lib/schemas/user';
 import { performGoogleOAuth } from '$lib/server/oauth';
+import { setTokenInCookies } from '$lib/server/auth';
 import { signupFormSchema } from '$lib/schemas/user.server';
 import { isParseSuccess, parseFormData } from '$lib/server/schema';
 
 export const actions = {
-	signup: async ({ cookies, request, fetch }) => {
+	signup: async (requestEvent) => {
+		const { request, fetch } = requestEvent;
 		const formData = await parseFormData(request, signupFormSchema);
 
 		if (!isParseSuccess(formData)) {
@@ -43,13 +45,11 @@ export const actions = {
 			}
 		);
 
-		cookies.set('app-session-token', userWithToken.data.meta.token, {
-			httpOnly: true,
-			secure: env.NODE_ENV === 'production',
-			sameSite: 'strict',
-			path: '/',
-			maxAge: 60 * 60 * 24 * 30 // 30 days
-		});
+		setTokenInCookies(
+			requestEvent,
+			userWithToken.data.meta.token,
+			userWithToken.data.meta.refreshToken
+		);
 
 		redirect(302, redirectUrl);
 	},
@@ -57,6 +57,6 @@ export const actions = {
 		const data = await request.formData();
 		const redirectUrl = data.get('redirect') as string | undefined;
 
-		performGoogleOAuth(redirectUrl ?? '/app');
+		await performGoogleOAuth(redirectUrl ?? '/app');
 	}
 } satisfies Actions;
@@ -0,0 +1,48 @@
+import { fail } from '@sveltejs/kit';
+import { z } from 'zod';
+import type { Actions } from './$types';
+import { post, ApiError } from '$lib';
+import {
+	confirmEmailVerificationRequestSchema,
+	verifyEmailFormSchema
+} from '$lib/schemas/user.server';
+import { isParseSuccess, parseFormData } from '$lib/server/schema';
+
+export const actions = {
+	default: async ({ request, fetch }) => {
+		const formData = await parseFormData(request, verifyEmailFormSchema);
+
+		if (!isParseSuccess(formData)) {
+			return formData;
+		}
+
+		try {
+			await post(
+				'/api/users/email-verification/confirm',
+				{
+					data: {
+						attributes: {
+							token: formData.token
+						}
+					}
+				},
+				{
+					fetch,
+					requestSchema: confirmEmailVerificationRequestSchema,
+					responseSchema: z.undefined()
+				}
+			);
+		} catch (error) {
+			if (error instanceof ApiError) {
+				return fail(error.code, { success: false, message: error.message });
+			}
+
+			return fail(500, {
+				success: false,
+				message: 'An unknown error ocurred processing your request, please try again later.'
+			});
+		}
+
+		return { success: true };
+	}
+} satisfies Actions;
@@ -5,6 +5,7 @@ import { createUserResponseSchema } from '$lib/schemas/user';
 import { setTokenInCookies } from '$lib/server/auth';
 import { googleOAuthCallbackFormSchema } from '$lib/schemas/user.server';
 import { isParseSuccess, parseFormData } from '$lib/server/schema';
+import { deleteOAuthCookies, verifyOAuth } from '$lib/server/oauth';
 
 // Schema for Google OAuth callback request
 const GoogleOAuthCallbackRequest = z.object({
@@ -28,6 +29,17 @@ export const actions: Actions = {
 
 		const { code, state, redirect: redirectUrl } = formData;
 
+		// The state has to match the one stored when this browser started the login
+		const isValidState = verifyOAuth({ state });
+		deleteOAuthCookies();
+
+		if (!isValidState) {
+			return {
+				success: false,
+				message: 'Authentication failed. Please try again.'
+			};
+		}
+
 		try {
 			// Get the full redirect URI
 			const origin = url.origin;
@@ -54,7 +66,7 @@ export const actions: Actions = {
 
 			// Set the token using the existing auth function
 			if (response.data.meta.token) {
-				setTokenInCookies(requestEvent, response.data.meta.token);
+				setTokenInCookies(requestEvent, response.data.meta.token, response.data.meta.refreshToken);
 			}
 		} catch (error) {
 			console.error('OAuth callback error:', error);
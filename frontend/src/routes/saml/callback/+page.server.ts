@@ -0,0 +1,66 @@
+import { redirect, type Actions } from '@sveltejs/kit';
+import { post } from '$lib/api';
+import { setTokenInCookies } from '$lib/server/auth';
+import {
+	loginWithSamlRequestSchema,
+	loginWithSamlResponseSchema,
+	samlCallbackFormSchema
+} from '$lib/schemas/user.server';
+import { isParseSuccess, parseFormData } from '$lib/server/schema';
+import { deleteSamlCookies, verifySaml } from '$lib/server/saml';
+
+export const actions: Actions = {
+	// The identity provider posts to the backend, which redirects here. That redirect is a cross site
+	// navigation, so the state cookie is only sent once the page submits this form.
+	default: async (requestEvent) => {
+		const { request, fetch } = requestEvent;
+		const formData = await parseFormData(request, samlCallbackFormSchema);
+
+		if (!isParseSuccess(formData)) {
+			return formData;
+		}
+
+		const { code, state } = formData;
+
+		// The state has to match the one stored when this browser started the login
+		const successRedirectUrl = verifySaml({ state });
+		deleteSamlCookies();
+
+		if (!successRedirectUrl) {
+			return {
+				success: false,
+				message: 'Authentication failed. Please try again.'
+			};
+		}
+
+		try {
+			const response = await post(
+				'/api/users/login/saml',
+				{
+					data: {
+						attributes: {
+							code,
+							state
+						}
+					}
+				},
+				{
+					fetch,
+					requestSchema: loginWithSamlRequestSchema,
+					responseSchema: loginWithSamlResponseSchema
+				}
+			);
+
+			setTokenInCookies(requestEvent, response.data.meta.token, response.data.meta.refreshToken);
+		} catch (error) {
+			console.error('SAML callback error:', error);
+
+			return {
+				success: false,
+				message: error instanceof Error ? error.message : 'Authentication failed. Please try again.'
+			};
+		}
+
+		redirect(302, successRedirectUrl);
+	}
+};